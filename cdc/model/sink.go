@@ -271,6 +271,27 @@ type RowChangedEvent struct {
 	SplitTxn bool `json:"-" msg:"-"`
 	// ReplicatingTs is ts when a table starts replicating events to downstream.
 	ReplicatingTs Ts `json:"-" msg:"-"`
+
+	// Checksum is the checksum of the row attached by the upstream TiDB,
+	// it's nil if the upstream does not compute the checksum.
+	Checksum *RowChecksum `json:"-" msg:"-"`
+
+	// SQLDigest is the digest of the statement which produced the row,
+	// it's empty if the provenance of the row is unavailable.
+	SQLDigest string `json:"-" msg:"-"`
+
+	// TableInfo is the schema of the table when the row is changed.
+	TableInfo *TableInfo `json:"-" msg:"-"`
+}
+
+// RowChecksum is the checksum of a row computed by the upstream TiDB.
+//
+//msgp:ignore RowChecksum
+type RowChecksum struct {
+	// Algorithm is the algorithm used to compute the checksum.
+	Algorithm string
+	// Value is the decimal representation of the checksum.
+	Value string
 }
 
 // GetCommitTs returns the commit timestamp of this event.
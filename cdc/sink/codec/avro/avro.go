@@ -75,7 +75,7 @@ func (a *BatchEncoder) AppendRowChangedEvent(
 		&e.Table.Table,
 	)
 	message.Callback = callback
-	topic = sanitizeTopic(topic)
+	topic = SanitizeTopic(topic)
 
 	if !e.IsDelete() {
 		res, err := a.avroEncode(ctx, e, topic, false)
@@ -142,10 +142,10 @@ func (a *BatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error
 }
 
 // Build Messages
-func (a *BatchEncoder) Build() (messages []*common.Message) {
+func (a *BatchEncoder) Build() ([]*common.Message, error) {
 	old := a.resultBuf
 	a.resultBuf = nil
-	return old
+	return old, nil
 }
 
 const (
@@ -189,7 +189,7 @@ func (a *BatchEncoder) avroEncode(
 		return nil, nil
 	}
 
-	namespace := getAvroNamespace(a.namespace, e.Table)
+	namespace := GetAvroNamespace(a.namespace, e.Table)
 
 	schemaGen := func() (string, error) {
 		schema, err := rowToAvroSchema(
@@ -301,10 +301,10 @@ const (
 	numberPrefix    = "_"
 )
 
-// sanitizeName escapes not permitted chars for avro
+// SanitizeName escapes not permitted chars for avro
 // debezium-core/src/main/java/io/debezium/schema/FieldNameSelector.java
 // https://avro.apache.org/docs/current/spec.html#names
-func sanitizeName(name string) string {
+func SanitizeName(name string) string {
 	changed := false
 	var sb strings.Builder
 	for i, c := range name {
@@ -334,8 +334,8 @@ func sanitizeName(name string) string {
 	return sanitizedName
 }
 
-// sanitizeTopic escapes ".", it may has special meanings for sink connectors
-func sanitizeTopic(name string) string {
+// SanitizeTopic escapes ".", it may has special meanings for sink connectors
+func SanitizeTopic(name string) string {
 	return strings.ReplaceAll(name, ".", replacementChar)
 }
 
@@ -349,8 +349,10 @@ func escapeEnumAndSetOptions(option string) string {
 	return option
 }
 
-func getAvroNamespace(namespace string, tableName *model.TableName) string {
-	return sanitizeName(namespace) + "." + sanitizeName(tableName.Schema)
+// GetAvroNamespace returns the namespace of the Avro schema of the table,
+// which consists of the namespace of the changefeed and the schema of the table.
+func GetAvroNamespace(namespace string, tableName *model.TableName) string {
+	return SanitizeName(namespace) + "." + SanitizeName(tableName.Schema)
 }
 
 type avroSchema struct {
@@ -377,7 +379,7 @@ func rowToAvroSchema(
 ) (string, error) {
 	top := avroSchemaTop{
 		Tp:        "record",
-		Name:      sanitizeName(name),
+		Name:      SanitizeName(name),
 		Namespace: namespace,
 		Fields:    nil,
 	}
@@ -393,7 +395,7 @@ func rowToAvroSchema(
 			return "", err
 		}
 		field := make(map[string]interface{})
-		field["name"] = sanitizeName(col.Name)
+		field["name"] = SanitizeName(col.Name)
 
 		copy := *col
 		copy.Value = copy.Default
@@ -487,9 +489,9 @@ func rowToAvroData(
 
 		// https://pkg.go.dev/github.com/linkedin/goavro/v2#Union
 		if col.Flag.IsNullable() {
-			ret[sanitizeName(col.Name)] = goavro.Union(str, data)
+			ret[SanitizeName(col.Name)] = goavro.Union(str, data)
 		} else {
-			ret[sanitizeName(col.Name)] = data
+			ret[SanitizeName(col.Name)] = data
 		}
 	}
 
@@ -796,6 +798,13 @@ func (r *avroEncodeResult) toEnvelope() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// NewEnvelope wraps the Avro binary data of the schema registered as the
+// registryID into the confluent avro wire format.
+func NewEnvelope(registryID int, data []byte) ([]byte, error) {
+	r := &avroEncodeResult{data: data, registryID: registryID}
+	return r.toEnvelope()
+}
+
 type batchEncoderBuilder struct {
 	namespace          string
 	config             *common.Config
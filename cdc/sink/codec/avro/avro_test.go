@@ -637,7 +637,7 @@ func TestRowToAvroSchema(t *testing.T) {
 		Schema: "testdb",
 		Table:  "rowtoavroschema",
 	}
-	namespace := getAvroNamespace(model.DefaultNamespace, &table)
+	namespace := GetAvroNamespace(model.DefaultNamespace, &table)
 	cols := make([]*model.Column, 0)
 	colInfos := make([]rowcodec.ColInfo, 0)
 
@@ -772,7 +772,7 @@ func TestAvroEncode(t *testing.T) {
 	defer cancel()
 
 	keyCols, keyColInfos := event.HandleKeyColInfos()
-	namespace := getAvroNamespace(encoder.namespace, event.Table)
+	namespace := GetAvroNamespace(encoder.namespace, event.Table)
 
 	keySchema, err := rowToAvroSchema(
 		namespace,
@@ -866,14 +866,14 @@ func TestAvroEnvelope(t *testing.T) {
 func TestSanitizeName(t *testing.T) {
 	t.Parallel()
 
-	require.Equal(t, "normalColumnName123", sanitizeName("normalColumnName123"))
+	require.Equal(t, "normalColumnName123", SanitizeName("normalColumnName123"))
 	require.Equal(
 		t,
 		"_1ColumnNameStartWithNumber",
-		sanitizeName("1ColumnNameStartWithNumber"),
+		SanitizeName("1ColumnNameStartWithNumber"),
 	)
-	require.Equal(t, "A_B", sanitizeName("A.B"))
-	require.Equal(t, "columnNameWith__", sanitizeName("columnNameWith中文"))
+	require.Equal(t, "A_B", SanitizeName("A.B"))
+	require.Equal(t, "columnNameWith__", SanitizeName("columnNameWith中文"))
 }
 
 func TestGetAvroNamespace(t *testing.T) {
@@ -882,7 +882,7 @@ func TestGetAvroNamespace(t *testing.T) {
 	require.Equal(
 		t,
 		"normalNamespace.normalSchema",
-		getAvroNamespace(
+		GetAvroNamespace(
 			"normalNamespace",
 			&model.TableName{Schema: "normalSchema", Table: "normalTable"},
 		),
@@ -890,11 +890,11 @@ func TestGetAvroNamespace(t *testing.T) {
 	require.Equal(
 		t,
 		"_1Namespace._1Schema",
-		getAvroNamespace("1Namespace", &model.TableName{Schema: "1Schema", Table: "normalTable"}),
+		GetAvroNamespace("1Namespace", &model.TableName{Schema: "1Schema", Table: "normalTable"}),
 	)
 	require.Equal(
 		t,
 		"N_amespace.S_chema",
-		getAvroNamespace("N-amespace", &model.TableName{Schema: "S.chema", Table: "normalTable"}),
+		GetAvroNamespace("N-amespace", &model.TableName{Schema: "S.chema", Table: "normalTable"}),
 	)
 }
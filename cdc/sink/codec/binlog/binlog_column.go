@@ -0,0 +1,461 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/parser/charset"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// The column types of the binlog whose storage differs from the column types
+// of TiDB, the fractional seconds are kept since MySQL 5.6.4.
+const (
+	typeTimestamp2 byte = 17
+	typeDatetime2  byte = 18
+	typeTime2      byte = 19
+)
+
+const (
+	// maxDecimalPrecision and maxDecimalScale are the max precision and scale
+	// of the DECIMAL.
+	maxDecimalPrecision = 65
+	maxDecimalScale     = 30
+	// defaultFsp is the fractional seconds precision of the time column whose
+	// schema is unknown, no fraction is lost.
+	defaultFsp = 6
+	// defaultVarcharBytes and defaultCharBytes are the max bytes of the VARCHAR
+	// and the CHAR column whose schema is unknown.
+	defaultVarcharBytes = math.MaxUint16
+	defaultCharBytes    = 255 * 4
+	// defaultBits is the width of the BIT column whose schema is unknown.
+	defaultBits = 64
+	// datetimeIntOffset and timeIntOffset are the offsets of the integer part
+	// of the DATETIME2 and the TIME2, so that they're binary-sortable.
+	datetimeIntOffset = 0x8000000000
+	timeIntOffset     = 0x800000
+	timeOffset        = 0x800000000000
+)
+
+// column is a column of the table map, it maps the column type of TiDB to the
+// column type and the metadata of the binlog, and encodes the values of it.
+type column struct {
+	name     string
+	tp       byte
+	meta     []byte
+	nullable bool
+	unsigned bool
+
+	// size is the size of the integer and the float, the length prefix of the
+	// string and the blob, and the pack length of the enum and the set.
+	size int
+	// fsp is the fractional seconds precision of the time.
+	fsp int
+	// precision and scale are the precision and the scale of the decimal.
+	precision int
+	scale     int
+	// bits is the width of the bit.
+	bits int
+}
+
+// newColumn creates the column of c, the metadata is taken from the field type
+// of the column in the schema, ft, if it's known, or derived from the values
+// of the column otherwise.
+func newColumn(c *model.Column, ft *types.FieldType, values []interface{}) (*column, error) {
+	col := &column{
+		name:     c.Name,
+		tp:       c.Type,
+		nullable: c.Flag.IsNullable(),
+		unsigned: c.Flag.IsUnsigned(),
+	}
+	if ft != nil && ft.GetType() != c.Type {
+		ft = nil
+	}
+	switch c.Type {
+	case mysql.TypeTiny:
+		col.size = 1
+	case mysql.TypeShort:
+		col.size = 2
+	case mysql.TypeInt24:
+		col.size = 3
+	case mysql.TypeLong:
+		col.size = 4
+	case mysql.TypeLonglong:
+		col.size = 8
+	case mysql.TypeFloat:
+		col.size = 4
+		col.meta = []byte{4}
+	case mysql.TypeDouble:
+		col.size = 8
+		col.meta = []byte{8}
+	case mysql.TypeNewDecimal:
+		col.precision, col.scale = decimalPrecision(ft, values)
+		col.meta = []byte{byte(col.precision), byte(col.scale)}
+	case mysql.TypeDate, mysql.TypeNewDate:
+		col.tp = mysql.TypeDate
+	case mysql.TypeDatetime:
+		col.tp = typeDatetime2
+		col.fsp = fsp(ft)
+		col.meta = []byte{byte(col.fsp)}
+	case mysql.TypeTimestamp:
+		col.tp = typeTimestamp2
+		col.fsp = fsp(ft)
+		col.meta = []byte{byte(col.fsp)}
+	case mysql.TypeDuration:
+		col.tp = typeTime2
+		col.fsp = fsp(ft)
+		col.meta = []byte{byte(col.fsp)}
+	case mysql.TypeYear:
+	case mysql.TypeVarchar, mysql.TypeVarString:
+		col.tp = mysql.TypeVarchar
+		n := maxBytes(ft, defaultVarcharBytes)
+		col.size = lengthPrefixSize(n)
+		col.meta = binary.LittleEndian.AppendUint16(nil, uint16(n))
+	case mysql.TypeString:
+		n := maxBytes(ft, defaultCharBytes)
+		col.size = lengthPrefixSize(n)
+		// the high bits of the length are stored in the type byte.
+		col.meta = []byte{mysql.TypeString ^ byte((n&0x300)>>4), byte(n)}
+	case mysql.TypeEnum:
+		col.tp = mysql.TypeString
+		col.size = 2
+		if ft != nil && len(ft.GetElems()) < 256 {
+			col.size = 1
+		}
+		col.meta = []byte{mysql.TypeEnum, byte(col.size)}
+	case mysql.TypeSet:
+		col.tp = mysql.TypeString
+		col.size = 8
+		if ft != nil {
+			if n := (len(ft.GetElems()) + 7) / 8; n <= 4 {
+				col.size = n
+			}
+		}
+		col.meta = []byte{mysql.TypeSet, byte(col.size)}
+	case mysql.TypeTinyBlob, mysql.TypeBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob:
+		col.tp = mysql.TypeBlob
+		col.size = map[byte]int{
+			mysql.TypeTinyBlob:   1,
+			mysql.TypeBlob:       2,
+			mysql.TypeMediumBlob: 3,
+			mysql.TypeLongBlob:   4,
+		}[c.Type]
+		col.meta = []byte{byte(col.size)}
+	case mysql.TypeBit:
+		col.bits = defaultBits
+		if ft != nil && ft.GetFlen() > 0 {
+			col.bits = ft.GetFlen()
+		}
+		col.meta = []byte{byte(col.bits % 8), byte(col.bits / 8)}
+	default:
+		return nil, cerror.ErrBinlogEncodeFailed.GenWithStack(
+			"the type %d of the column %s is not supported", c.Type, c.Name)
+	}
+	return col, nil
+}
+
+// numeric returns whether the signedness of the column is carried by the
+// table map.
+func (c *column) numeric() bool {
+	switch c.tp {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong,
+		mysql.TypeLonglong, mysql.TypeNewDecimal, mysql.TypeFloat, mysql.TypeDouble:
+		return true
+	}
+	return false
+}
+
+// decimalPrecision returns the precision and the scale of the decimal column
+// in the schema, or the ones holding all the values if the schema is unknown.
+func decimalPrecision(ft *types.FieldType, values []interface{}) (int, int) {
+	if ft != nil && ft.GetFlen() > 0 && ft.GetDecimal() >= 0 {
+		return ft.GetFlen(), ft.GetDecimal()
+	}
+	digits, scale := 1, 0
+	for _, value := range values {
+		d, err := toDecimal(value)
+		if err != nil {
+			continue
+		}
+		precision, frac := d.PrecisionAndFrac()
+		if precision-frac > digits {
+			digits = precision - frac
+		}
+		if frac > scale {
+			scale = frac
+		}
+	}
+	if scale > maxDecimalScale {
+		scale = maxDecimalScale
+	}
+	if digits+scale > maxDecimalPrecision {
+		digits = maxDecimalPrecision - scale
+	}
+	return digits + scale, scale
+}
+
+// fsp returns the fractional seconds precision of the time column.
+func fsp(ft *types.FieldType) int {
+	if ft != nil && ft.GetDecimal() >= 0 && ft.GetDecimal() <= types.MaxFsp {
+		return ft.GetDecimal()
+	}
+	return defaultFsp
+}
+
+// maxBytes returns the max bytes of the string column, which is the max
+// characters times the max bytes of a character of the charset.
+func maxBytes(ft *types.FieldType, defaultBytes int) int {
+	if ft == nil || ft.GetFlen() <= 0 {
+		return defaultBytes
+	}
+	maxLen := 4
+	if cs, err := charset.GetCharsetInfo(ft.GetCharset()); err == nil {
+		maxLen = cs.Maxlen
+	}
+	n := ft.GetFlen() * maxLen
+	if n > math.MaxUint16 {
+		n = math.MaxUint16
+	}
+	return n
+}
+
+// lengthPrefixSize returns the size of the length prefix of the string values
+// of the max bytes.
+func lengthPrefixSize(maxBytes int) int {
+	if maxBytes < 256 {
+		return 1
+	}
+	return 2
+}
+
+// appendValue appends the value of the column in the storage format of the
+// binlog, loc is the timezone the TIMESTAMP values are in.
+func (c *column) appendValue(buf []byte, value interface{}, loc *time.Location) ([]byte, error) {
+	sc := &stmtctx.StatementContext{TimeZone: time.UTC}
+	switch c.tp {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong:
+		v, err := toUint64(value)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		return appendUintLE(buf, v, c.size), nil
+	case mysql.TypeFloat, mysql.TypeDouble:
+		v, err := toFloat64(value)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		if c.tp == mysql.TypeFloat {
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v))), nil
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v)), nil
+	case mysql.TypeNewDecimal:
+		d, err := toDecimal(value)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		bin, err := d.ToBin(c.precision, c.scale)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		return append(buf, bin...), nil
+	case mysql.TypeDate:
+		t, err := types.ParseTime(sc, toString(value), mysql.TypeDate, 0)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		ct := t.CoreTime()
+		return appendUintLE(buf, uint64(ct.Day()|ct.Month()<<5|ct.Year()<<9), 3), nil
+	case typeDatetime2:
+		t, err := types.ParseTime(sc, toString(value), mysql.TypeDatetime, c.fsp)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		ct := t.CoreTime()
+		ymd := int64((ct.Year()*13+ct.Month())<<5 | ct.Day())
+		hms := int64(ct.Hour()<<12 | ct.Minute()<<6 | ct.Second())
+		buf = appendUintBE(buf, uint64((ymd<<17|hms)+datetimeIntOffset), 5)
+		return appendFrac(buf, int64(ct.Microsecond()), c.fsp), nil
+	case typeTimestamp2:
+		t, err := types.ParseTime(sc, toString(value), mysql.TypeTimestamp, c.fsp)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		var seconds int64
+		if !t.IsZero() {
+			if loc == nil {
+				loc = time.UTC
+			}
+			gt, err := t.CoreTime().GoTime(loc)
+			if err != nil {
+				return nil, c.wrapError(err)
+			}
+			seconds = gt.Unix()
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(seconds))
+		return appendFrac(buf, int64(t.CoreTime().Microsecond()), c.fsp), nil
+	case typeTime2:
+		d, _, err := types.ParseDuration(sc, toString(value), c.fsp)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		return appendTime2(buf, d.Duration, c.fsp), nil
+	case mysql.TypeYear:
+		v, err := toUint64(value)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		if v != 0 {
+			v -= 1900
+		}
+		return append(buf, byte(v)), nil
+	case mysql.TypeVarchar, mysql.TypeBlob:
+		b := toBytes(value)
+		return append(appendUintLE(buf, uint64(len(b)), c.size), b...), nil
+	case mysql.TypeString:
+		if c.meta[0] == mysql.TypeEnum || c.meta[0] == mysql.TypeSet {
+			v, err := toUint64(value)
+			if err != nil {
+				return nil, c.wrapError(err)
+			}
+			return appendUintLE(buf, v, c.size), nil
+		}
+		b := toBytes(value)
+		return append(appendUintLE(buf, uint64(len(b)), c.size), b...), nil
+	case mysql.TypeBit:
+		v, err := toUint64(value)
+		if err != nil {
+			return nil, c.wrapError(err)
+		}
+		return appendUintBE(buf, v, (c.bits+7)/8), nil
+	}
+	return nil, cerror.ErrBinlogEncodeFailed.GenWithStack(
+		"the type %d of the column %s is not supported", c.tp, c.name)
+}
+
+func (c *column) wrapError(err error) error {
+	return cerror.WrapError(cerror.ErrBinlogEncodeFailed,
+		errors.Annotatef(err, "column %s", c.name))
+}
+
+// appendTime2 appends the duration in the TIME2, see my_time_packed_to_binary
+// of MySQL. The negative durations are packed as the negation of the positive
+// ones, which are split by the arithmetic shift and the truncated remainder.
+func appendTime2(buf []byte, d time.Duration, fsp int) []byte {
+	negative := d < 0
+	if negative {
+		d = -d
+	}
+	hours := int64(d / time.Hour)
+	minutes := int64(d % time.Hour / time.Minute)
+	seconds := int64(d % time.Minute / time.Second)
+	micros := int64(d % time.Second / time.Microsecond)
+	packed := (hours<<12|minutes<<6|seconds)<<24 + micros
+	if negative {
+		packed = -packed
+	}
+	intPart, fracPart := packed>>24, packed%(1<<24)
+	switch fsp {
+	case 1, 2:
+		buf = appendUintBE(buf, uint64(intPart+timeIntOffset), 3)
+		return append(buf, byte(fracPart/10000))
+	case 3, 4:
+		buf = appendUintBE(buf, uint64(intPart+timeIntOffset), 3)
+		return appendUintBE(buf, uint64(fracPart/100), 2)
+	case 5, 6:
+		return appendUintBE(buf, uint64(packed+timeOffset), 6)
+	default:
+		return appendUintBE(buf, uint64(intPart+timeIntOffset), 3)
+	}
+}
+
+// appendFrac appends the fractional seconds of the DATETIME2 and the
+// TIMESTAMP2 in the bytes of the fsp.
+func appendFrac(buf []byte, micros int64, fsp int) []byte {
+	switch fsp {
+	case 1, 2:
+		return append(buf, byte(micros/10000))
+	case 3, 4:
+		return appendUintBE(buf, uint64(micros/100), 2)
+	case 5, 6:
+		return appendUintBE(buf, uint64(micros), 3)
+	}
+	return buf
+}
+
+func appendUintLE(buf []byte, v uint64, n int) []byte {
+	for i := 0; i < n; i++ {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+func appendUintBE(buf []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+func toUint64(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case int64:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	}
+	return 0, errors.Errorf("unexpected value %v of type %T", value, value)
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, errors.Errorf("unexpected value %v of type %T", value, value)
+}
+
+func toDecimal(value interface{}) (*types.MyDecimal, error) {
+	d := new(types.MyDecimal)
+	if err := d.FromString(toBytes(value)); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return d, nil
+}
+
+func toBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case *types.MyDecimal:
+		return []byte(v.String())
+	}
+	return []byte(fmt.Sprintf("%v", value))
+}
+
+func toString(value interface{}) string {
+	return string(toBytes(value))
+}
@@ -0,0 +1,310 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlog
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	"github.com/tikv/client-go/v2/oracle"
+	"go.uber.org/zap"
+)
+
+// BatchEncoder encodes the events into the MySQL binlog events of the ROW
+// format. Each message is led by a format description event, followed by the
+// table map event and the rows event of each row batched, so that it can be
+// parsed by the binlog parsers as a binlog file without the magic number.
+type BatchEncoder struct {
+	messageBuf   []*common.Message
+	callbackBuff []func()
+	curBatchSize int
+	// writer writes the events of the last message.
+	writer *eventWriter
+	// loc is the timezone of the TIMESTAMP values.
+	loc *time.Location
+	// offset is added to the timestamp of the events, see commitTimestamp.
+	offset time.Duration
+
+	// configs
+	MaxMessageBytes int
+	MaxBatchSize    int
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (d *BatchEncoder) AppendRowChangedEvent(
+	_ context.Context,
+	_ string,
+	e *model.RowChangedEvent,
+	callback func(),
+) error {
+	tableMap, rowsType, rows, err := d.rowEvents(e)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	timestamp := commitTimestamp(e.CommitTs, d.offset)
+	length := eventSize(tableMap) + eventSize(rows)
+
+	// for single row that longer than max-message-size, do not send it.
+	header := newEventWriter(timestamp)
+	if len(header.buf)+length+common.MaxRecordOverhead > d.MaxMessageBytes {
+		log.Warn("Single message too large",
+			zap.Int("max-message-size", d.MaxMessageBytes),
+			zap.Int("length", len(header.buf)+length), zap.Any("table", e.Table))
+		return cerror.ErrBinlogEncodeFailed.GenWithStack(
+			"the events of the row of table %s is %d bytes, more than the max message bytes %d",
+			e.Table, len(header.buf)+length, d.MaxMessageBytes)
+	}
+
+	if len(d.messageBuf) == 0 ||
+		d.curBatchSize >= d.MaxBatchSize ||
+		len(d.writer.buf)+length+common.MaxRecordOverhead > d.MaxMessageBytes {
+		// Before we create a new message, we should handle the previous callbacks.
+		d.tryBuildCallback()
+		d.writer = header
+		msg := common.NewMsg(config.ProtocolMySQLBinlog, nil, nil, 0, model.MessageTypeRow, nil, nil)
+		d.messageBuf = append(d.messageBuf, msg)
+		d.curBatchSize = 0
+	}
+
+	d.writer.writeEvent(tableMapEvent, timestamp, tableMap)
+	d.writer.writeEvent(rowsType, timestamp, rows)
+	message := d.messageBuf[len(d.messageBuf)-1]
+	message.Value = d.writer.buf
+	message.Ts = e.CommitTs
+	message.Schema = &e.Table.Schema
+	message.Table = &e.Table.Table
+	message.IncRowsCount()
+
+	if callback != nil {
+		d.callbackBuff = append(d.callbackBuff, callback)
+	}
+
+	d.curBatchSize++
+	return nil
+}
+
+// rowEvents returns the body of the table map event and the rows event of
+// the row, and the type of the rows event.
+func (d *BatchEncoder) rowEvents(e *model.RowChangedEvent) ([]byte, byte, []byte, error) {
+	var rowsType byte
+	var images [][]*model.Column
+	switch {
+	case e.IsDelete():
+		rowsType = deleteRowsEventV2
+		images = [][]*model.Column{presentColumns(e.PreColumns)}
+	case e.IsInsert():
+		rowsType = writeRowsEventV2
+		images = [][]*model.Column{presentColumns(e.Columns)}
+	default:
+		rowsType = updateRowsEventV2
+		images = [][]*model.Column{presentColumns(e.PreColumns), presentColumns(e.Columns)}
+		if len(images[0]) != len(images[1]) {
+			return nil, 0, nil, cerror.ErrBinlogEncodeFailed.GenWithStack(
+				"the update of table %s has %d columns before but %d columns after",
+				e.Table, len(images[0]), len(images[1]))
+		}
+	}
+
+	columns := make([]*column, 0, len(images[0]))
+	for i, c := range images[0] {
+		values := make([]interface{}, 0, len(images))
+		for _, image := range images {
+			values = append(values, image[i].Value)
+		}
+		col, err := newColumn(c, fieldType(e.TableInfo, c.Name), values)
+		if err != nil {
+			return nil, 0, nil, errors.Trace(err)
+		}
+		columns = append(columns, col)
+	}
+
+	tableID := uint64(e.Table.TableID) & (1<<(8*tableIDSize) - 1)
+	tableMap := tableMapEventBody(tableID, e.Table.Schema, e.Table.Table, columns)
+
+	rows := appendTableID(nil, tableID)
+	rows = append(rows, rowsEventStmtEndFlag, 0)
+	// the length of the extra data, which includes the length itself.
+	rows = append(rows, 2, 0)
+	rows = appendLengthEncodedInt(rows, uint64(len(columns)))
+	// all the columns are present in each image.
+	for range images {
+		rows = append(rows, newBitmap(len(columns), func(int) bool { return true })...)
+	}
+	for _, image := range images {
+		rows = append(rows, newBitmap(len(image), func(i int) bool {
+			return image[i].Value == nil
+		})...)
+		for i, c := range image {
+			if c.Value == nil {
+				continue
+			}
+			var err error
+			rows, err = columns[i].appendValue(rows, c.Value, d.loc)
+			if err != nil {
+				return nil, 0, nil, errors.Trace(err)
+			}
+		}
+	}
+	return tableMap, rowsType, rows, nil
+}
+
+// tableMapEventBody builds the body of the TABLE_MAP_EVENT of the columns,
+// the signedness and the names of the columns are carried by the optional
+// metadata.
+func tableMapEventBody(tableID uint64, schema, table string, columns []*column) []byte {
+	body := appendTableID(nil, tableID)
+	// the flags.
+	body = append(body, 0, 0)
+	body = append(body, byte(len(schema)))
+	body = append(body, schema...)
+	body = append(body, 0)
+	body = append(body, byte(len(table)))
+	body = append(body, table...)
+	body = append(body, 0)
+	body = appendLengthEncodedInt(body, uint64(len(columns)))
+	var meta []byte
+	for _, col := range columns {
+		body = append(body, col.tp)
+		meta = append(meta, col.meta...)
+	}
+	body = appendLengthEncodedBytes(body, meta)
+	body = append(body, newBitmap(len(columns), func(i int) bool {
+		return columns[i].nullable
+	})...)
+
+	// the signedness bitmap is of the numeric columns only, in the order of
+	// the most significant bit first.
+	var signedness []byte
+	numerics := 0
+	for _, col := range columns {
+		if !col.numeric() {
+			continue
+		}
+		if numerics%8 == 0 {
+			signedness = append(signedness, 0)
+		}
+		if col.unsigned {
+			signedness[numerics/8] |= 1 << uint(7-numerics%8)
+		}
+		numerics++
+	}
+	if numerics != 0 {
+		body = append(body, optionalMetaSignedness)
+		body = appendLengthEncodedBytes(body, signedness)
+	}
+	var names []byte
+	for _, col := range columns {
+		names = append(names, byte(len(col.name)))
+		names = append(names, col.name...)
+	}
+	body = append(body, optionalMetaColumnName)
+	return appendLengthEncodedBytes(body, names)
+}
+
+// presentColumns returns the columns of the image, the columns absent are nil.
+func presentColumns(columns []*model.Column) []*model.Column {
+	result := make([]*model.Column, 0, len(columns))
+	for _, c := range columns {
+		if c != nil {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// fieldType returns the field type of the column in the schema of the table,
+// nil if the schema is unknown.
+func fieldType(tableInfo *model.TableInfo, name string) *types.FieldType {
+	if tableInfo == nil || tableInfo.TableInfo == nil {
+		return nil
+	}
+	for _, col := range tableInfo.Columns {
+		if col.Name.O == name {
+			return &col.FieldType
+		}
+	}
+	return nil
+}
+
+// commitTimestamp returns the timestamp of the events, which is the physical
+// time of the commit ts plus the offset in seconds.
+func commitTimestamp(ts uint64, offset time.Duration) uint32 {
+	return uint32((oracle.ExtractPhysical(ts) + offset.Milliseconds()) / 1000)
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (d *BatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error) {
+	timestamp := commitTimestamp(e.CommitTs, d.offset)
+	w := newEventWriter(timestamp)
+	w.writeEvent(queryEvent, timestamp, queryEventBody(e.TableInfo.TableName.Schema, e.Query))
+	return common.NewDDLMsg(config.ProtocolMySQLBinlog, nil, w.buf, e), nil
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface, the
+// checkpoint is not carried by the binlog.
+func (d *BatchEncoder) EncodeCheckpointEvent(_ uint64) (*common.Message, error) {
+	return nil, nil
+}
+
+// Build implements the EventBatchEncoder interface
+func (d *BatchEncoder) Build() ([]*common.Message, error) {
+	d.tryBuildCallback()
+	ret := d.messageBuf
+	d.messageBuf = make([]*common.Message, 0)
+	d.writer = nil
+	return ret, nil
+}
+
+// tryBuildCallback will collect all the callbacks into one message's callback.
+func (d *BatchEncoder) tryBuildCallback() {
+	if len(d.messageBuf) != 0 && len(d.callbackBuff) != 0 {
+		lastMsg := d.messageBuf[len(d.messageBuf)-1]
+		callbacks := d.callbackBuff
+		lastMsg.Callback = func() {
+			for _, cb := range callbacks {
+				cb()
+			}
+		}
+		d.callbackBuff = make([]func(), 0)
+	}
+}
+
+type batchEncoderBuilder struct {
+	config *common.Config
+	loc    *time.Location
+}
+
+// Build a BatchEncoder
+func (b *batchEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &BatchEncoder{
+		loc:             b.loc,
+		offset:          b.config.CommitTsOffset,
+		MaxMessageBytes: b.config.MaxMessageBytes,
+		MaxBatchSize:    b.config.MaxBatchSize,
+	}
+}
+
+// NewBatchEncoderBuilder creates a mysql-binlog batchEncoderBuilder, loc is
+// the timezone the TIMESTAMP values of the rows are in.
+func NewBatchEncoderBuilder(config *common.Config, loc *time.Location) codec.EncoderBuilder {
+	return &batchEncoderBuilder{config: config, loc: loc}
+}
@@ -0,0 +1,335 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+// testColumn is a column of the test table, with the value of the row
+// inserted.
+type testColumn struct {
+	name  string
+	tp    byte
+	flen  int
+	dec   int
+	elems []string
+	flag  model.ColumnFlagType
+	value interface{}
+}
+
+var testColumns = []testColumn{
+	{"id", mysql.TypeLonglong, 20, 0, nil, model.PrimaryKeyFlag | model.HandleKeyFlag | model.UnsignedFlag, uint64(1)},
+	{"a", mysql.TypeTiny, 4, 0, nil, model.NullableFlag, int64(-3)},
+	{"f", mysql.TypeFloat, 12, -1, nil, model.NullableFlag, float32(1.5)},
+	{"dbl", mysql.TypeDouble, 22, -1, nil, model.NullableFlag, float64(2.25)},
+	{"dec", mysql.TypeNewDecimal, 10, 2, nil, model.NullableFlag, "-123.45"},
+	{"d", mysql.TypeDate, 10, 0, nil, model.NullableFlag, "2022-11-02"},
+	{"dt", mysql.TypeDatetime, 23, 3, nil, model.NullableFlag, "2022-11-02 10:11:12.345"},
+	{"ts", mysql.TypeTimestamp, 26, 6, nil, model.NullableFlag, "2022-11-02 10:11:12.123456"},
+	{"tm", mysql.TypeDuration, 13, 2, nil, model.NullableFlag, "-01:02:03.40"},
+	{"y", mysql.TypeYear, 4, 0, nil, model.NullableFlag, int64(2022)},
+	{"vc", mysql.TypeVarchar, 80, 0, nil, model.NullableFlag, []byte("hello")},
+	{"c", mysql.TypeString, 4, 0, nil, model.NullableFlag, []byte("ab")},
+	{"e", mysql.TypeEnum, 1, 0, []string{"a", "b"}, model.NullableFlag, uint64(2)},
+	{"s", mysql.TypeSet, 3, 0, []string{"x", "y"}, model.NullableFlag, uint64(3)},
+	{"b", mysql.TypeBlob, 65535, 0, nil, model.NullableFlag | model.BinaryFlag, []byte{1, 2, 3}},
+	{"bit", mysql.TypeBit, 10, 0, nil, model.NullableFlag, uint64(0x2ff)},
+	{"n", mysql.TypeLong, 11, 0, nil, model.NullableFlag, nil},
+}
+
+// the values of the testColumns decoded by the parser.
+var testDecoded = []interface{}{
+	int64(1), int8(-3), float32(1.5), float64(2.25), "-123.45", "2022-11-02",
+	"2022-11-02 10:11:12.345", "2022-11-02 10:11:12.123456", "-01:02:03.40",
+	2022, "hello", "ab", int64(2), int64(3), []byte{1, 2, 3}, int64(0x2ff), nil,
+}
+
+func newTestTable() *model.TableInfo {
+	info := &mm.TableInfo{ID: 42, Name: mm.NewCIStr("t")}
+	for i, c := range testColumns {
+		ft := types.NewFieldType(c.tp)
+		ft.SetFlen(c.flen)
+		ft.SetDecimal(c.dec)
+		ft.SetElems(c.elems)
+		ft.SetCharset(mysql.DefaultCharset)
+		if c.flag.IsBinary() {
+			ft.SetCharset("binary")
+		}
+		info.Columns = append(info.Columns, &mm.ColumnInfo{
+			ID:        int64(i + 1),
+			Name:      mm.NewCIStr(c.name),
+			Offset:    i,
+			FieldType: *ft,
+			State:     mm.StatePublic,
+		})
+	}
+	return model.WrapTableInfo(1, "test", 1, info)
+}
+
+func newTestColumns(update func(c *model.Column)) []*model.Column {
+	columns := make([]*model.Column, 0, len(testColumns))
+	for _, c := range testColumns {
+		col := &model.Column{Name: c.name, Type: c.tp, Flag: c.flag, Value: c.value}
+		if update != nil {
+			update(col)
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+// parse parses the events in the message value.
+func parse(t *testing.T, value []byte) []*replication.BinlogEvent {
+	parser := replication.NewBinlogParser()
+	parser.SetVerifyChecksum(true)
+	parser.SetTimestampStringLocation(time.UTC)
+	var events []*replication.BinlogEvent
+	require.NoError(t, parser.ParseReader(bytes.NewReader(value),
+		func(e *replication.BinlogEvent) error {
+			events = append(events, e)
+			return nil
+		}))
+	return events
+}
+
+func TestBinlogRowEvents(t *testing.T) {
+	t.Parallel()
+
+	tableInfo := newTestTable()
+	commitTs := oracle.ComposeTS(1667385600123, 0)
+	table := &model.TableName{Schema: "test", Table: "t", TableID: 42}
+	insert := &model.RowChangedEvent{
+		CommitTs: commitTs, Table: table, TableInfo: tableInfo,
+		Columns: newTestColumns(nil),
+	}
+	update := &model.RowChangedEvent{
+		CommitTs: commitTs, Table: table, TableInfo: tableInfo,
+		PreColumns: newTestColumns(nil),
+		Columns: newTestColumns(func(c *model.Column) {
+			if c.Name == "vc" {
+				c.Value = []byte("world")
+			}
+		}),
+	}
+	del := &model.RowChangedEvent{
+		CommitTs: commitTs, Table: table, TableInfo: tableInfo,
+		PreColumns: newTestColumns(nil),
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolMySQLBinlog)
+	encoder := NewBatchEncoderBuilder(codecConfig, time.UTC).Build()
+	for _, e := range []*model.RowChangedEvent{insert, update, del} {
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", e, nil))
+	}
+	messages, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, 3, messages[0].GetRowsCount())
+	require.Equal(t, "test", *messages[0].Schema)
+	require.Equal(t, "t", *messages[0].Table)
+
+	events := parse(t, messages[0].Value)
+	require.Len(t, events, 7)
+	require.Equal(t, replication.FORMAT_DESCRIPTION_EVENT, events[0].Header.EventType)
+	pos := uint32(startPosition)
+	for _, e := range events {
+		require.Equal(t, uint32(1667385600), e.Header.Timestamp)
+		pos += e.Header.EventSize
+		require.Equal(t, pos, e.Header.LogPos)
+	}
+
+	// the table map is the same for each row.
+	for _, e := range []*replication.BinlogEvent{events[1], events[3], events[5]} {
+		require.Equal(t, replication.TABLE_MAP_EVENT, e.Header.EventType)
+		tableMap := e.Event.(*replication.TableMapEvent)
+		require.Equal(t, uint64(42), tableMap.TableID)
+		require.Equal(t, "test", string(tableMap.Schema))
+		require.Equal(t, "t", string(tableMap.Table))
+		require.Len(t, tableMap.ColumnName, len(testColumns))
+		for i, c := range testColumns {
+			require.Equal(t, c.name, string(tableMap.ColumnName[i]))
+		}
+		require.Equal(t, []byte{
+			mysql.TypeLonglong, mysql.TypeTiny, mysql.TypeFloat, mysql.TypeDouble,
+			mysql.TypeNewDecimal, mysql.TypeDate, typeDatetime2, typeTimestamp2,
+			typeTime2, mysql.TypeYear, mysql.TypeVarchar, mysql.TypeString,
+			mysql.TypeString, mysql.TypeString, mysql.TypeBlob, mysql.TypeBit,
+			mysql.TypeLong,
+		}, tableMap.ColumnType)
+		require.Equal(t, map[int]bool{
+			0: true, 1: false, 2: false, 3: false, 4: false, 16: false,
+		}, tableMap.UnsignedMap())
+		available, nullable := tableMap.Nullable(0)
+		require.True(t, available)
+		require.False(t, nullable)
+		available, nullable = tableMap.Nullable(1)
+		require.True(t, available)
+		require.True(t, nullable)
+	}
+
+	require.Equal(t, replication.WRITE_ROWS_EVENTv2, events[2].Header.EventType)
+	rows := events[2].Event.(*replication.RowsEvent)
+	require.Equal(t, uint64(42), rows.TableID)
+	require.Equal(t, [][]interface{}{testDecoded}, rows.Rows)
+
+	require.Equal(t, replication.UPDATE_ROWS_EVENTv2, events[4].Header.EventType)
+	after := append([]interface{}{}, testDecoded...)
+	after[10] = "world"
+	require.Equal(t, [][]interface{}{testDecoded, after},
+		events[4].Event.(*replication.RowsEvent).Rows)
+
+	require.Equal(t, replication.DELETE_ROWS_EVENTv2, events[6].Header.EventType)
+	require.Equal(t, [][]interface{}{testDecoded},
+		events[6].Event.(*replication.RowsEvent).Rows)
+}
+
+func TestBinlogRowEventsWithoutSchema(t *testing.T) {
+	t.Parallel()
+
+	// the metadata is derived from the values if the schema is unknown.
+	e := &model.RowChangedEvent{
+		CommitTs: oracle.ComposeTS(1667385600123, 0),
+		Table:    &model.TableName{Schema: "test", Table: "t", TableID: 42},
+		Columns:  newTestColumns(nil),
+	}
+	encoder := NewBatchEncoderBuilder(common.NewConfig(config.ProtocolMySQLBinlog), nil).Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", e, nil))
+	messages, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	events := parse(t, messages[0].Value)
+	require.Len(t, events, 3)
+	tableMap := events[1].Event.(*replication.TableMapEvent)
+	// decimal(5,2), datetime(6), varchar of 65535 bytes and bit(64).
+	require.Equal(t, uint16(5<<8|2), tableMap.ColumnMeta[4])
+	require.Equal(t, uint16(6), tableMap.ColumnMeta[6])
+	require.Equal(t, uint16(65535), tableMap.ColumnMeta[10])
+	require.Equal(t, uint16(8<<8), tableMap.ColumnMeta[15])
+
+	expected := append([]interface{}{}, testDecoded...)
+	expected[6] = "2022-11-02 10:11:12.345000"
+	expected[8] = "-01:02:03.400000"
+	require.Equal(t, [][]interface{}{expected}, events[2].Event.(*replication.RowsEvent).Rows)
+}
+
+func TestBinlogBatch(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolMySQLBinlog)
+	codecConfig.MaxBatchSize = 2
+	encoder := NewBatchEncoderBuilder(codecConfig, time.UTC).Build()
+	tableInfo := newTestTable()
+	called := 0
+	for i := 0; i < 3; i++ {
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "",
+			&model.RowChangedEvent{
+				CommitTs:  oracle.ComposeTS(1667385600123, int64(i)),
+				Table:     &model.TableName{Schema: "test", Table: "t", TableID: 42},
+				TableInfo: tableInfo,
+				Columns:   newTestColumns(nil),
+			}, func() { called++ }))
+	}
+	messages, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, 2, messages[0].GetRowsCount())
+	require.Equal(t, 1, messages[1].GetRowsCount())
+	// each message is led by the format description.
+	require.Len(t, parse(t, messages[0].Value), 5)
+	require.Len(t, parse(t, messages[1].Value), 3)
+	for _, m := range messages {
+		m.Callback()
+	}
+	require.Equal(t, 3, called)
+
+	// the row is rejected if it's larger than the max message bytes.
+	codecConfig.MaxMessageBytes = 256
+	encoder = NewBatchEncoderBuilder(codecConfig, time.UTC).Build()
+	require.Error(t, encoder.AppendRowChangedEvent(context.Background(), "",
+		&model.RowChangedEvent{
+			CommitTs:  oracle.ComposeTS(1667385600123, 0),
+			Table:     &model.TableName{Schema: "test", Table: "t", TableID: 42},
+			TableInfo: tableInfo,
+			Columns:   newTestColumns(nil),
+		}, nil))
+}
+
+func TestBinlogUnsupportedType(t *testing.T) {
+	t.Parallel()
+
+	encoder := NewBatchEncoderBuilder(common.NewConfig(config.ProtocolMySQLBinlog), time.UTC).Build()
+	err := encoder.AppendRowChangedEvent(context.Background(), "", &model.RowChangedEvent{
+		CommitTs: oracle.ComposeTS(1667385600123, 0),
+		Table:    &model.TableName{Schema: "test", Table: "t", TableID: 42},
+		Columns: []*model.Column{
+			{Name: "j", Type: mysql.TypeJSON, Value: `{"a":1}`},
+		},
+	}, nil)
+	require.ErrorContains(t, err, "the type 245 of the column j is not supported")
+}
+
+func TestBinlogDDLEvent(t *testing.T) {
+	t.Parallel()
+
+	encoder := NewBatchEncoderBuilder(common.NewConfig(config.ProtocolMySQLBinlog), time.UTC).Build()
+	message, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs: oracle.ComposeTS(1667385600123, 0),
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "t"},
+		},
+		Query: "create table t(id int primary key)",
+		Type:  mm.ActionCreateTable,
+	})
+	require.NoError(t, err)
+
+	events := parse(t, message.Value)
+	require.Len(t, events, 2)
+	require.Equal(t, replication.QUERY_EVENT, events[1].Header.EventType)
+	query := events[1].Event.(*replication.QueryEvent)
+	require.Equal(t, "test", string(query.Schema))
+	require.Equal(t, "create table t(id int primary key)", string(query.Query))
+
+	// the timestamp of the events is corrected by the offset.
+	codecConfig := common.NewConfig(config.ProtocolMySQLBinlog)
+	codecConfig.CommitTsOffset = -90 * time.Second
+	message, err = NewBatchEncoderBuilder(codecConfig, time.UTC).Build().EncodeDDLEvent(&model.DDLEvent{
+		CommitTs:  oracle.ComposeTS(1667385600123, 0),
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+		Query:     "drop table t",
+		Type:      mm.ActionDropTable,
+	})
+	require.NoError(t, err)
+	for _, e := range parse(t, message.Value) {
+		require.Equal(t, uint32(1667385600-90), e.Header.Timestamp)
+	}
+
+	message, err = encoder.EncodeCheckpointEvent(oracle.ComposeTS(1667385600123, 0))
+	require.NoError(t, err)
+	require.Nil(t, message)
+}
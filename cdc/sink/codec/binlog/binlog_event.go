@@ -0,0 +1,181 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlog
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// The binlog event types emitted, which is the subset of the MySQL 5.7 binlog
+// in the ROW format replicating the changes:
+//   - FORMAT_DESCRIPTION_EVENT leads each message, so that each message can
+//     be parsed alone.
+//   - QUERY_EVENT carries the DDL.
+//   - TABLE_MAP_EVENT describes the table of the rows event following it.
+//   - WRITE_ROWS_EVENT, UPDATE_ROWS_EVENT and DELETE_ROWS_EVENT (v2) carry
+//     the row of the INSERT, the UPDATE and the DELETE.
+//
+// The transaction events, i.e. the GTID, the BEGIN and the XID, are not
+// emitted, since the rows of a transaction may be dispatched to the
+// different partitions.
+const (
+	queryEvent             byte = 2
+	formatDescriptionEvent byte = 15
+	tableMapEvent          byte = 19
+	writeRowsEventV2       byte = 30
+	updateRowsEventV2      byte = 31
+	deleteRowsEventV2      byte = 32
+)
+
+const (
+	binlogVersion = 4
+	// serverVersion is the version of the server in the format description,
+	// the checksum of the events is only recognized after MySQL 5.6.1.
+	serverVersion   = "5.7.25-TiCDC"
+	eventHeaderSize = 19
+	// checksumAlgCRC32 is the binlog_checksum CRC32, the 4 bytes CRC32 of each
+	// event is appended to the event.
+	checksumAlgCRC32 = 1
+	checksumSize     = 4
+	// startPosition is the position of the first event in the binlog file,
+	// which is after the magic number. The messages do not carry the magic
+	// number, but the positions of the events are counted as if they're the
+	// binlog files.
+	startPosition = 4
+	// serverID is the server id of the events.
+	serverID = 1
+	// tableIDSize is the size of the table id in the table map and the rows
+	// events, whose post-header length is not 6.
+	tableIDSize = 6
+	// rowsEventStmtEndFlag marks the last rows event of the statement, after
+	// which the table maps are released by the parsers.
+	rowsEventStmtEndFlag = 0x0001
+)
+
+// The types of the optional metadata of the table map, since MySQL 8.0.1.
+const (
+	optionalMetaSignedness byte = 1
+	optionalMetaColumnName byte = 4
+)
+
+// postHeaderLengths is the post-header length of each event type of MySQL 5.7,
+// indexed by the event type minus 1, which is carried by the format
+// description for the parsers to skip the events not recognized.
+var postHeaderLengths = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 95, 0, 4, 26, 8, 0,
+	0, 0, 8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0, 18, 52, 0,
+}
+
+// eventWriter appends the binlog events to a message, it tracks the position
+// of the events for the header of each event.
+type eventWriter struct {
+	buf []byte
+	pos uint32
+}
+
+// newEventWriter creates an eventWriter of a message led by the format
+// description at the timestamp.
+func newEventWriter(timestamp uint32) *eventWriter {
+	w := &eventWriter{pos: startPosition}
+	body := make([]byte, 0, 2+50+4+1+len(postHeaderLengths)+1)
+	body = binary.LittleEndian.AppendUint16(body, binlogVersion)
+	var version [50]byte
+	copy(version[:], serverVersion)
+	body = append(body, version[:]...)
+	body = binary.LittleEndian.AppendUint32(body, timestamp)
+	body = append(body, eventHeaderSize)
+	body = append(body, postHeaderLengths...)
+	body = append(body, checksumAlgCRC32)
+	w.writeEvent(formatDescriptionEvent, timestamp, body)
+	return w
+}
+
+// eventSize returns the size of the event of the body.
+func eventSize(body []byte) int {
+	return eventHeaderSize + len(body) + checksumSize
+}
+
+// writeEvent appends the event of the body with the header and the checksum.
+func (w *eventWriter) writeEvent(eventType byte, timestamp uint32, body []byte) {
+	size := uint32(eventSize(body))
+	start := len(w.buf)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, timestamp)
+	w.buf = append(w.buf, eventType)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, serverID)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, size)
+	// the position of the next event.
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, w.pos+size)
+	// the flags.
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, 0)
+	w.buf = append(w.buf, body...)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(w.buf[start:]))
+	w.pos += size
+}
+
+// queryEventBody builds the body of the QUERY_EVENT of the query executed in
+// the schema.
+func queryEventBody(schema, query string) []byte {
+	body := make([]byte, 0, 13+len(schema)+1+len(query))
+	// the thread id and the execution time.
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, byte(len(schema)))
+	// the error code and the length of the status variables.
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	body = append(body, schema...)
+	body = append(body, 0)
+	body = append(body, query...)
+	return body
+}
+
+// appendTableID appends the table id in 6 bytes.
+func appendTableID(buf []byte, tableID uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], tableID)
+	return append(buf, b[:tableIDSize]...)
+}
+
+// appendLengthEncodedInt appends the length-encoded integer of the MySQL
+// protocol.
+func appendLengthEncodedInt(buf []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(buf, byte(n))
+	case n < 1<<16:
+		return binary.LittleEndian.AppendUint16(append(buf, 0xfc), uint16(n))
+	case n < 1<<24:
+		return append(buf, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		return binary.LittleEndian.AppendUint64(append(buf, 0xfe), n)
+	}
+}
+
+// appendLengthEncodedBytes appends the length-encoded string of the MySQL
+// protocol.
+func appendLengthEncodedBytes(buf []byte, b []byte) []byte {
+	return append(appendLengthEncodedInt(buf, uint64(len(b))), b...)
+}
+
+// newBitmap returns the bitmap of n bits, whose bit i is set if set(i).
+func newBitmap(n int, set func(i int) bool) []byte {
+	bitmap := make([]byte, (n+7)/8)
+	for i := 0; i < n; i++ {
+		if set(i) {
+			bitmap[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return bitmap
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import "sync"
+
+// ByteBudget caps the total bytes buffered by the encoders sharing it, e.g.
+// the encoders of the partitions multiplexed by a sink, since the buffers may
+// exceed the memory limit in sum even if each of them is small. Once the
+// budget is exceeded, the encoder buffering the most bytes is picked to flush.
+type ByteBudget interface {
+	// NewAccount returns the account of the bytes buffered by an encoder.
+	NewAccount() ByteAccount
+}
+
+// ByteAccount accounts the bytes buffered by an encoder in a ByteBudget,
+// it's safe to use the accounts of a budget concurrently.
+type ByteAccount interface {
+	// Add accounts the bytes newly buffered by the encoder.
+	Add(bytes int)
+	// Reset clears the bytes buffered, after the encoder builds the batch.
+	Reset()
+	// ShouldFlush returns true if the budget is exceeded, and the encoder
+	// buffers the most bytes among the encoders sharing the budget.
+	ShouldFlush() bool
+}
+
+type byteBudget struct {
+	mu       sync.Mutex
+	limit    int
+	total    int
+	accounts []*byteAccount
+}
+
+// NewByteBudget creates a ByteBudget capping the total bytes to the limit.
+func NewByteBudget(limit int) ByteBudget {
+	return &byteBudget{limit: limit}
+}
+
+// NewAccount implements the ByteBudget interface
+func (b *byteBudget) NewAccount() ByteAccount {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a := &byteAccount{budget: b}
+	b.accounts = append(b.accounts, a)
+	return a
+}
+
+type byteAccount struct {
+	budget *byteBudget
+	// bytes is protected by the mutex of the budget.
+	bytes int
+}
+
+// Add implements the ByteAccount interface
+func (a *byteAccount) Add(bytes int) {
+	a.budget.mu.Lock()
+	defer a.budget.mu.Unlock()
+	a.bytes += bytes
+	a.budget.total += bytes
+}
+
+// Reset implements the ByteAccount interface
+func (a *byteAccount) Reset() {
+	a.budget.mu.Lock()
+	defer a.budget.mu.Unlock()
+	a.budget.total -= a.bytes
+	a.bytes = 0
+}
+
+// ShouldFlush implements the ByteAccount interface
+func (a *byteAccount) ShouldFlush() bool {
+	b := a.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.total <= b.limit || a.bytes == 0 {
+		return false
+	}
+	for _, other := range b.accounts {
+		if other.bytes > a.bytes {
+			return false
+		}
+	}
+	return true
+}
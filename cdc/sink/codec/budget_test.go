@@ -0,0 +1,54 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteBudget(t *testing.T) {
+	t.Parallel()
+
+	budget := NewByteBudget(100)
+	a, b, c := budget.NewAccount(), budget.NewAccount(), budget.NewAccount()
+	a.Add(40)
+	b.Add(30)
+	c.Add(30)
+	// the budget is not exceeded.
+	for _, account := range []ByteAccount{a, b, c} {
+		require.False(t, account.ShouldFlush())
+	}
+
+	// the account buffering the most bytes is picked.
+	b.Add(20)
+	require.False(t, a.ShouldFlush())
+	require.True(t, b.ShouldFlush())
+	require.False(t, c.ShouldFlush())
+
+	// the budget is released by the reset.
+	b.Reset()
+	for _, account := range []ByteAccount{a, b, c} {
+		require.False(t, account.ShouldFlush())
+	}
+
+	// the accounts with no bytes are never picked.
+	budget = NewByteBudget(0)
+	a, b = budget.NewAccount(), budget.NewAccount()
+	require.False(t, a.ShouldFlush())
+	a.Add(1)
+	require.True(t, a.ShouldFlush())
+	require.False(t, b.ShouldFlush())
+}
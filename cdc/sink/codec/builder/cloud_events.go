@@ -0,0 +1,270 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	// cloudEventsTypePrefix prefixes the type of the events, which is
+	// followed by the type of the message, i.e. row, ddl or resolved.
+	cloudEventsTypePrefix = "com.pingcap.tidb.cdc."
+	// cloudEventsDefaultContentType is the content type of the payload
+	// of the protocols not listed in cloudEventsContentTypes.
+	cloudEventsDefaultContentType = "application/octet-stream"
+)
+
+// cloudEvent is a CloudEvents 1.0 event in the JSON structured mode, see
+// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/formats/json-format.md.
+// Since the payload is binary, it's carried base64 encoded in data_base64.
+type cloudEvent struct {
+	SpecVersion     string `json:"specversion"`
+	Type            string `json:"type"`
+	Source          string `json:"source"`
+	ID              string `json:"id"`
+	Time            string `json:"time,omitempty"`
+	Subject         string `json:"subject,omitempty"`
+	DataContentType string `json:"datacontenttype"`
+	DataBase64      []byte `json:"data_base64"`
+}
+
+// cloudEventsTypes maps the type of the message to the suffix of the type of
+// the event.
+var cloudEventsTypes = map[model.MessageType]string{
+	model.MessageTypeRow:      "row",
+	model.MessageTypeDDL:      "ddl",
+	model.MessageTypeResolved: "resolved",
+	// the heartbeat is the only message of the unknown type.
+	model.MessageTypeUnknown: "heartbeat",
+}
+
+// cloudEventsContentTypes maps the protocol of the message to the content
+// type of the payload.
+var cloudEventsContentTypes = map[config.Protocol]string{
+	config.ProtocolCanal:     "application/x-protobuf",
+	config.ProtocolCanalJSON: "application/json",
+}
+
+// cloudEventsEncoder wraps the value of each message built by the encoder in
+// a CloudEvents envelope, whose attributes are:
+//
+//	type:            com.pingcap.tidb.cdc.{row,ddl,resolved} by the message type
+//	source:          /tidb/cdc/{namespace}/{changefeed}
+//	id:              {ts}-{the first 8 bytes of the SHA-256 of the value in hex}
+//	time:            the physical time of the commit ts in RFC 3339
+//	subject:         {schema}.{table} of the message, omitted if unknown
+//	datacontenttype: application/x-protobuf for canal, application/json for canal-json
+//
+// The ts of the message batching the rows is the max commit ts of them, and
+// the build time of canal-json, i.e. the `ts` of the value, is excluded from
+// the SHA-256, so that the id is stable for the same batch. The key and the
+// other fields of the message are kept as is. The heartbeat is wrapped as
+// well, while the tombstone is not.
+type cloudEventsEncoder struct {
+	encoderWrapper
+	source string
+	// maxCommitTs is the max commit ts of the rows appended since the last
+	// Build, it's the ts of the row messages built.
+	maxCommitTs uint64
+}
+
+// stableValue returns the value of the message without the fields differing
+// on every encoding of the same events, the value which is not a JSON object,
+// e.g. compressed, is returned as is.
+func stableValue(msg *common.Message) []byte {
+	if msg.Protocol != config.ProtocolCanalJSON {
+		return msg.Value
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Value, &fields); err != nil {
+		return msg.Value
+	}
+	delete(fields, "ts")
+	// the keys of the map are marshalled in order.
+	value, err := json.Marshal(fields)
+	if err != nil {
+		return msg.Value
+	}
+	return value
+}
+
+// wrap wraps the value of the message in the envelope, except for the
+// tombstone, whose nil value deletes the key on the log compaction.
+func (e *cloudEventsEncoder) wrap(msg *common.Message, ts uint64) error {
+	if msg.Value == nil {
+		return nil
+	}
+	sum := sha256.Sum256(stableValue(msg))
+	contentType, ok := cloudEventsContentTypes[msg.Protocol]
+	if !ok {
+		contentType = cloudEventsDefaultContentType
+	}
+	event := &cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Type:            cloudEventsTypePrefix + cloudEventsTypes[msg.Type],
+		Source:          e.source,
+		ID:              strconv.FormatUint(ts, 10) + "-" + hex.EncodeToString(sum[:8]),
+		DataContentType: contentType,
+		DataBase64:      msg.Value,
+	}
+	if ts != 0 {
+		event.Time = oracle.GetTimeFromTS(ts).UTC().Format(time.RFC3339Nano)
+	}
+	if msg.Schema != nil && msg.Table != nil && *msg.Table != "" {
+		event.Subject = *msg.Schema + "." + *msg.Table
+	}
+	value, err := json.Marshal(event)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrEncodeFailed, err)
+	}
+	msg.Value = value
+	return nil
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface
+func (e *cloudEventsEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	msg, err := e.encoder.EncodeCheckpointEvent(ts)
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	if err := e.wrap(msg, ts); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return msg, nil
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *cloudEventsEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	if err := e.encoder.AppendRowChangedEvent(ctx, topic, event, callback); err != nil {
+		return err
+	}
+	if event.CommitTs > e.maxCommitTs {
+		e.maxCommitTs = event.CommitTs
+	}
+	return nil
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (e *cloudEventsEncoder) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	msg, err := e.encoder.EncodeDDLEvent(event)
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	if err := e.wrap(msg, event.CommitTs); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return msg, nil
+}
+
+// EncodeHeartbeat implements the HeartbeatEncoder interface
+func (e *cloudEventsEncoder) EncodeHeartbeat(ts uint64) (*common.Message, error) {
+	msg, err := e.encoderWrapper.EncodeHeartbeat(ts)
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	if err := e.wrap(msg, ts); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return msg, nil
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *cloudEventsEncoder) Build() ([]*common.Message, error) {
+	messages, err := e.encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, msg := range messages {
+		ts := msg.Ts
+		if msg.Type == model.MessageTypeRow {
+			ts = e.maxCommitTs
+		}
+		if err := e.wrap(msg, ts); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	e.maxCommitTs = 0
+	return messages, nil
+}
+
+type cloudEventsEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	source  string
+}
+
+// Build implements the EncoderBuilder interface
+func (b *cloudEventsEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &cloudEventsEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, source: b.source}
+}
+
+// cloudEventsSource returns the source of the events of the changefeed in
+// the ctx.
+func cloudEventsSource(ctx context.Context) string {
+	changefeedID := contextutil.ChangefeedIDFromCtx(ctx)
+	return "/tidb/cdc/" + changefeedID.Namespace + "/" + changefeedID.ID
+}
+
+// cloudEventsMaxMessageBytes returns the max bytes of the messages wrapped,
+// so that the envelopes of them are within the maxMessageBytes. The value
+// grows by 4/3 in base64, and the envelope is accounted by its max length,
+// whose subject is the longest identifiers of escaped characters.
+func cloudEventsMaxMessageBytes(maxMessageBytes int, source string) (int, error) {
+	subject := strings.Repeat("\x00", mysql.MaxDatabaseNameLength) + "." +
+		strings.Repeat("\x00", mysql.MaxTableNameLength)
+	envelope, err := json.Marshal(&cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Type:            cloudEventsTypePrefix + "heartbeat",
+		Source:          source,
+		ID:              strconv.FormatUint(math.MaxUint64, 10) + "-" + strings.Repeat("0", 16),
+		Time:            time.RFC3339Nano,
+		Subject:         subject,
+		DataContentType: cloudEventsDefaultContentType,
+		DataBase64:      []byte{},
+	})
+	if err != nil {
+		return 0, cerror.WrapError(cerror.ErrEncodeFailed, err)
+	}
+	if len(envelope) >= maxMessageBytes {
+		return 0, cerror.ErrCodecInvalidConfig.GenWithStack(
+			"max-message-bytes %d is too small for the CloudEvents envelope", maxMessageBytes)
+	}
+	return (maxMessageBytes - len(envelope)) / 4 * 3, nil
+}
+
+// newCloudEventsEncoderBuilder wraps the builder, so that the messages built
+// by its encoders are CloudEvents of the source.
+func newCloudEventsEncoderBuilder(builder codec.EncoderBuilder, source string) codec.EncoderBuilder {
+	return &cloudEventsEncoderBuilder{builder: builder, source: source}
+}
@@ -0,0 +1,251 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestCloudEventsEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextutil.PutChangefeedIDInCtx(context.Background(),
+		model.ChangeFeedID{Namespace: "default", ID: "test"})
+	newRow := func(commitTs uint64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			},
+		}
+	}
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288270,
+		Query:    "create table test.t(id int primary key)",
+		Type:     timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "t"},
+		},
+	}
+	// unwrap parses the envelope, and checks the attributes shared by all
+	// the events.
+	unwrap := func(value []byte) map[string]interface{} {
+		var event map[string]interface{}
+		require.Nil(t, json.Unmarshal(value, &event))
+		require.Equal(t, "1.0", event["specversion"])
+		require.Equal(t, "/tidb/cdc/default/test", event["source"])
+		require.NotEmpty(t, event["id"])
+		return event
+	}
+	// payload returns the data of the event, which is base64 encoded.
+	payload := func(event map[string]interface{}) []byte {
+		var data struct {
+			DataBase64 []byte `json:"data_base64"`
+		}
+		raw, err := json.Marshal(event)
+		require.Nil(t, err)
+		require.Nil(t, json.Unmarshal(raw, &data))
+		return data.DataBase64
+	}
+	// requirePayload checks the data of the event is the value of the plain
+	// message, except for the build time of canal-json.
+	requirePayload := func(plain *common.Message, event map[string]interface{}) {
+		wrapped := &common.Message{Protocol: plain.Protocol, Value: payload(event)}
+		require.Equal(t, string(stableValue(plain)), string(stableValue(wrapped)))
+	}
+	eventTime := func(ts uint64) string {
+		return oracle.GetTimeFromTS(ts).UTC().Format(time.RFC3339Nano)
+	}
+
+	for _, protocol := range []config.Protocol{config.ProtocolCanal, config.ProtocolCanalJSON} {
+		codecConfig := common.NewConfig(protocol)
+		codecConfig.EnableCloudEvents = true
+		builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+		require.Nil(t, err)
+		plainBuilder, err := NewEventBatchEncoderBuilder(ctx, common.NewConfig(protocol))
+		require.Nil(t, err)
+		encoder, plain := builder.Build(), plainBuilder.Build()
+		contentType := "application/x-protobuf"
+		if protocol == config.ProtocolCanalJSON {
+			contentType = "application/json"
+		}
+
+		// the rows, whose time is the max commit ts of the batch.
+		for _, commitTs := range []uint64{417318403368288260, 417318403368288262} {
+			require.Nil(t, encoder.AppendRowChangedEvent(ctx, "", newRow(commitTs), nil))
+			require.Nil(t, plain.AppendRowChangedEvent(ctx, "", newRow(commitTs), nil))
+		}
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		plainMsgs, err := plain.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, len(plainMsgs))
+		var ids []interface{}
+		for i, msg := range msgs {
+			event := unwrap(msg.Value)
+			require.Equal(t, "com.pingcap.tidb.cdc.row", event["type"])
+			require.Equal(t, eventTime(417318403368288262), event["time"])
+			require.Equal(t, contentType, event["datacontenttype"])
+			requirePayload(plainMsgs[i], event)
+			require.Equal(t, plainMsgs[i].Key, msg.Key)
+			ids = append(ids, event["id"])
+		}
+
+		// the same batch encoded again shares the id.
+		encoder = builder.Build()
+		for _, commitTs := range []uint64{417318403368288260, 417318403368288262} {
+			require.Nil(t, encoder.AppendRowChangedEvent(ctx, "", newRow(commitTs), nil))
+		}
+		msgs, err = encoder.Build()
+		require.NoError(t, err)
+		for i, msg := range msgs {
+			require.Equal(t, ids[i], unwrap(msg.Value)["id"])
+		}
+
+		// the DDL, which carries the subject.
+		msg, err := encoder.EncodeDDLEvent(ddl)
+		require.Nil(t, err)
+		plainMsg, err := plain.EncodeDDLEvent(ddl)
+		require.Nil(t, err)
+		event := unwrap(msg.Value)
+		require.Equal(t, "com.pingcap.tidb.cdc.ddl", event["type"])
+		require.Equal(t, eventTime(ddl.CommitTs), event["time"])
+		require.Equal(t, "test.t", event["subject"])
+		requirePayload(plainMsg, event)
+		require.NotContains(t, ids, event["id"])
+
+		// the checkpoint, if the protocol encodes it.
+		msg, err = encoder.EncodeCheckpointEvent(417318403368288280)
+		require.Nil(t, err)
+		if msg != nil {
+			event = unwrap(msg.Value)
+			require.Equal(t, "com.pingcap.tidb.cdc.resolved", event["type"])
+			require.Equal(t, eventTime(417318403368288280), event["time"])
+		}
+	}
+}
+
+func TestCloudEventsEncoderTombstone(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableCloudEvents = true
+	codecConfig.EnableTombstone = true
+	builder, err := NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		PreColumns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: 1},
+		},
+	}, nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	// the delete is wrapped, while the tombstone is kept as is, so that the
+	// key is deleted on the log compaction.
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal(msgs[0].Value, &event))
+	require.Equal(t, "com.pingcap.tidb.cdc.row", event["type"])
+	require.Nil(t, msgs[1].Value)
+	require.Equal(t, msgs[0].Key, msgs[1].Key)
+}
+
+func TestCloudEventsMaxMessageBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextutil.PutChangefeedIDInCtx(context.Background(),
+		model.ChangeFeedID{Namespace: "default", ID: "test"})
+	newRow := func(size int) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+				{Name: "name", Type: mysql.TypeVarchar, Value: []byte(strings.Repeat("a", size))},
+			},
+		}
+	}
+
+	// the row is either rejected by the encoder, or wrapped in the envelope
+	// within the max-message-bytes.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableCloudEvents = true
+	codecConfig.MaxMessageBytes = 4096
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	accepted, rejected := 0, 0
+	for size := 0; size <= codecConfig.MaxMessageBytes; size += 128 {
+		encoder := builder.Build()
+		if err := encoder.AppendRowChangedEvent(ctx, "", newRow(size), nil); err != nil {
+			require.True(t, cerror.ErrCanalValueTooLarge.Equal(errors.Cause(err)), err)
+			rejected++
+			continue
+		}
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		for _, msg := range msgs {
+			require.LessOrEqual(t, msg.Length(), codecConfig.MaxMessageBytes)
+		}
+		accepted++
+	}
+	require.NotZero(t, accepted)
+	require.NotZero(t, rejected)
+
+	// the max-message-bytes too small for the envelope is rejected.
+	codecConfig.MaxMessageBytes = 256
+	_, err = NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.ErrorContains(t, err, "too small for the CloudEvents envelope")
+}
+
+func TestCloudEventsStableValue(t *testing.T) {
+	t.Parallel()
+
+	// the build time of canal-json is excluded.
+	a := &common.Message{
+		Protocol: config.ProtocolCanalJSON,
+		Value:    []byte(`{"id":0,"database":"test","ts":1792053355876,"type":"INSERT"}`),
+	}
+	b := &common.Message{
+		Protocol: config.ProtocolCanalJSON,
+		Value:    []byte(`{"id":0,"database":"test","ts":1792053355877,"type":"INSERT"}`),
+	}
+	require.Equal(t, stableValue(a), stableValue(b))
+	require.NotContains(t, string(stableValue(a)), `"ts"`)
+
+	// the other values are kept as is.
+	c := &common.Message{Protocol: config.ProtocolCanalJSON, Value: []byte("not json")}
+	require.Equal(t, c.Value, stableValue(c))
+	d := &common.Message{Protocol: config.ProtocolCanal, Value: a.Value}
+	require.Equal(t, a.Value, stableValue(d))
+}
@@ -213,7 +213,7 @@ func codecEncodeRowCase(encoder codec.EventBatchEncoder, events []*model.RowChan
 	}
 
 	if len(events) > 0 {
-		return encoder.Build(), nil
+		return encoder.Build()
 	}
 	return nil, nil
 }
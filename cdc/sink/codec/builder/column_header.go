@@ -0,0 +1,167 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// columnHeaderEncoder copies the values of the columns of the ColumnHeaders
+// into the headers of the messages of the rows, which are produced in the
+// record headers, so that the broker routes and filters the messages by them.
+// The new image of the row is copied, except for the delete, whose old image
+// is copied, and the header of the null value is omitted. Since a message may
+// batch the rows, the header of it is only stamped if all the rows of it
+// share the value. The rows of the messages are known by the number of the
+// rows of each message in the order appended, otherwise the header shared by
+// all the rows appended since the last Build is stamped. The DDL and the
+// checkpoint carry no header.
+type columnHeaderEncoder struct {
+	encoderWrapper
+	// headers maps the columns in lower case to the headers.
+	headers map[string]string
+	// rows are the rows appended since the last Build.
+	rows []columnHeaderRow
+}
+
+type columnHeaderRow struct {
+	table   model.TableName
+	headers map[string]string
+}
+
+// headersOf returns the headers copied from the columns of the row.
+func (e *columnHeaderEncoder) headersOf(event *model.RowChangedEvent) map[string]string {
+	columns := event.Columns
+	if event.IsDelete() {
+		columns = event.PreColumns
+	}
+	var result map[string]string
+	for _, col := range columns {
+		if col == nil || col.Value == nil {
+			continue
+		}
+		header, ok := e.headers[strings.ToLower(col.Name)]
+		if !ok {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string, len(e.headers))
+		}
+		result[header] = model.ColumnValueString(col.Value)
+	}
+	return result
+}
+
+// sharedHeaders returns the headers shared by all the rows.
+func sharedHeaders(rows []columnHeaderRow) map[string]string {
+	if len(rows) == 0 || len(rows[0].headers) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(rows[0].headers))
+	for key, value := range rows[0].headers {
+		result[key] = value
+	}
+	for _, row := range rows[1:] {
+		for key, value := range result {
+			if v, ok := row.headers[key]; !ok || v != value {
+				delete(result, key)
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *columnHeaderEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	if err := e.encoder.AppendRowChangedEvent(ctx, topic, event, callback); err != nil {
+		return err
+	}
+	e.rows = append(e.rows, columnHeaderRow{
+		table:   model.TableName{Schema: event.Table.Schema, Table: event.Table.Table},
+		headers: e.headersOf(event),
+	})
+	return nil
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *columnHeaderEncoder) Build() ([]*common.Message, error) {
+	messages, err := e.encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rows := e.rows
+	e.rows = nil
+
+	// the rows of each message, nil if they're unknown.
+	batches := make([][]columnHeaderRow, 0, len(messages))
+	offset := 0
+	for _, msg := range messages {
+		if msg.Type != model.MessageTypeRow {
+			continue
+		}
+		count := msg.GetRowsCount()
+		if count <= 0 || offset+count > len(rows) {
+			batches = nil
+			break
+		}
+		batch := rows[offset : offset+count]
+		// the rows batched are of the table of the message.
+		if msg.Schema != nil && msg.Table != nil &&
+			(batch[0].table.Schema != *msg.Schema || batch[0].table.Table != *msg.Table) {
+			batches = nil
+			break
+		}
+		batches = append(batches, batch)
+		offset += count
+	}
+	if offset != len(rows) {
+		batches = nil
+	}
+
+	shared := sharedHeaders(rows)
+	i := 0
+	for _, msg := range messages {
+		if msg.Type != model.MessageTypeRow {
+			continue
+		}
+		if batches == nil {
+			msg.Headers = shared
+			continue
+		}
+		msg.Headers = sharedHeaders(batches[i])
+		i++
+	}
+	return messages, nil
+}
+
+type columnHeaderEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	headers map[string]string
+}
+
+// Build implements the EncoderBuilder interface
+func (b *columnHeaderEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &columnHeaderEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, headers: b.headers}
+}
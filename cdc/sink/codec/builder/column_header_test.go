@@ -0,0 +1,107 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnHeaderEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	columns := func(id int64, tenant interface{}) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: id},
+			{Name: "Tenant_ID", Type: mysql.TypeVarchar, Flag: model.NullableFlag, Value: tenant},
+		}
+	}
+	insert := func(id int64, tenant interface{}) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns:  columns(id, tenant),
+		}
+	}
+
+	// canal-json builds a message per row.
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.ColumnHeaders = map[string]string{"tenant_id": "x-tenant"}
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+
+	rows := []*model.RowChangedEvent{
+		insert(1, []byte("acme")),
+		// the header of the null value is omitted.
+		insert(2, nil),
+		// the old image of the delete is copied.
+		{
+			CommitTs:   417318403368288260,
+			Table:      &model.TableName{Schema: "test", Table: "t"},
+			PreColumns: columns(3, []byte("globex")),
+		},
+	}
+	for _, row := range rows {
+		require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, map[string]string{"x-tenant": "acme"}, msgs[0].Headers)
+	require.Equal(t, common.HeaderLength("x-tenant", len("acme")), msgs[0].HeadersLength())
+	require.Nil(t, msgs[1].Headers)
+	require.Equal(t, map[string]string{"x-tenant": "globex"}, msgs[2].Headers)
+
+	// the DDL carries no header.
+	msg, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs:  417318403368288270,
+		Query:     "create table test.t(id int primary key, tenant_id varchar(32))",
+		Type:      timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	})
+	require.NoError(t, err)
+	require.Nil(t, msg.Headers)
+
+	// canal batches the rows into a message, which carries the header only if
+	// all the rows of it share the value.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	codecConfig.ColumnHeaders = map[string]string{"tenant_id": "x-tenant"}
+	builder, err = NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder = builder.Build()
+	for _, row := range []*model.RowChangedEvent{insert(1, []byte("acme")), insert(2, []byte("acme"))} {
+		require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+	}
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, map[string]string{"x-tenant": "acme"}, msgs[0].Headers)
+
+	for _, row := range []*model.RowChangedEvent{insert(1, []byte("acme")), insert(2, []byte("globex"))} {
+		require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+	}
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Nil(t, msgs[0].Headers)
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+
+	"github.com/pingcap/tidb/util/rowcodec"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// columnOrderEncoder reorders the columns of the row events by the rules
+// before they're encoded, so that the positional consumer sees the columns in
+// the order of the downstream table. The columns not listed by the rule are
+// emitted after the ones listed in their original order, or dropped if
+// dropUnlisted is set. The columns are matched by the names emitted, i.e. the
+// aliases of the ColumnSelections. The event is copied rather than changed,
+// since it's shared by the sinks. The DDL and the checkpoint are not changed.
+type columnOrderEncoder struct {
+	encoderWrapper
+	rules        []common.ColumnOrderRule
+	dropUnlisted bool
+}
+
+// orderColumns returns the row event with the columns ordered by the first
+// rule matching its table, the rows of the tables matched by none are
+// returned as is.
+func (e *columnOrderEncoder) orderColumns(event *model.RowChangedEvent) *model.RowChangedEvent {
+	var rule *common.ColumnOrderRule
+	for i := range e.rules {
+		if e.rules[i].MatchTable(*event.Table) {
+			rule = &e.rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return event
+	}
+
+	// order lists the offsets of the columns in the order emitted. The
+	// columns and the old columns share the offsets.
+	columns := event.Columns
+	if len(columns) == 0 {
+		columns = event.PreColumns
+	}
+	listed := make([]int, len(rule.Columns))
+	for i := range listed {
+		listed[i] = -1
+	}
+	var unlisted []int
+	for i, c := range columns {
+		if c == nil {
+			continue
+		}
+		if position, ok := rule.Position(c.Name); ok {
+			listed[position] = i
+		} else if !e.dropUnlisted {
+			unlisted = append(unlisted, i)
+		}
+	}
+	order := make([]int, 0, len(columns))
+	for _, offset := range listed {
+		// the columns listed but missing in the row are skipped.
+		if offset >= 0 {
+			order = append(order, offset)
+		}
+	}
+	order = append(order, unlisted...)
+	// offsets maps the offset of each column to the one emitted, -1 if the
+	// column is dropped.
+	offsets := make([]int, len(columns))
+	for i := range offsets {
+		offsets[i] = -1
+	}
+	for i, offset := range order {
+		offsets[offset] = i
+	}
+
+	result := *event
+	result.Columns = orderColumns(event.Columns, order)
+	result.PreColumns = orderColumns(event.PreColumns, order)
+	if len(event.ColInfos) == len(offsets) {
+		result.ColInfos = make([]rowcodec.ColInfo, 0, len(order))
+		for _, offset := range order {
+			result.ColInfos = append(result.ColInfos, event.ColInfos[offset])
+		}
+	}
+	result.IndexColumns = nil
+	for _, index := range event.IndexColumns {
+		remapped := make([]int, 0, len(index))
+		for _, offset := range index {
+			if offset < 0 || offset >= len(offsets) || offsets[offset] < 0 {
+				remapped = nil
+				break
+			}
+			remapped = append(remapped, offsets[offset])
+		}
+		if remapped != nil {
+			result.IndexColumns = append(result.IndexColumns, remapped)
+		}
+	}
+	return &result
+}
+
+// orderColumns returns the columns at the offsets of the order.
+func orderColumns(columns []*model.Column, order []int) []*model.Column {
+	if len(columns) == 0 {
+		return columns
+	}
+	result := make([]*model.Column, 0, len(order))
+	for _, offset := range order {
+		if offset < len(columns) {
+			result = append(result, columns[offset])
+		}
+	}
+	return result
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *columnOrderEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	return e.encoder.AppendRowChangedEvent(ctx, topic, e.orderColumns(event), callback)
+}
+
+type columnOrderEncoderBuilder struct {
+	builder      codec.EncoderBuilder
+	rules        []common.ColumnOrderRule
+	dropUnlisted bool
+}
+
+// Build implements the EncoderBuilder interface
+func (b *columnOrderEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &columnOrderEncoder{
+		encoderWrapper: encoderWrapper{b.builder.Build()},
+		rules:          b.rules,
+		dropUnlisted:   b.dropUnlisted,
+	}
+}
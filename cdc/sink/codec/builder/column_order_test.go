@@ -0,0 +1,103 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnOrderEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	columns := func(name string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte(name)},
+			{Name: "age", Type: mysql.TypeLong, Value: int64(18)},
+			{Name: "email", Type: mysql.TypeVarchar, Value: []byte(name + "@example.com")},
+		}
+	}
+	event := &model.RowChangedEvent{
+		CommitTs:     417318403368288260,
+		Table:        &model.TableName{Schema: "test", Table: "users"},
+		PreColumns:   columns("bob"),
+		Columns:      columns("alice"),
+		IndexColumns: [][]int{{0}, {1, 3}},
+	}
+	names := func(columns []*model.Column) []string {
+		result := make([]string, 0, len(columns))
+		for _, c := range columns {
+			result = append(result, c.Name)
+		}
+		return result
+	}
+
+	// the reversed order, the columns are emitted by it in the canal entry,
+	// whose columns are positional.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.ColumnOrders = []common.ColumnOrderRule{{
+		TableMatcher: common.MustNewTableMatcher("test.users"), Columns: []string{"EMAIL", "age", "name", "id"},
+	}}
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", event, nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	decoder, err := canal.NewPacketDecoder(msgs[0].Value)
+	require.NoError(t, err)
+	_, ok, err := decoder.HasNext()
+	require.NoError(t, err)
+	require.True(t, ok)
+	row, err := decoder.NextRowChangedEvent()
+	require.NoError(t, err)
+	reversed := []string{"email", "age", "name", "id"}
+	require.Equal(t, reversed, names(row.Columns))
+	require.Equal(t, reversed, names(row.PreColumns))
+	require.Equal(t, "alice@example.com", row.Columns[0].Value)
+
+	// the event is not changed, and the indexes are remapped.
+	require.Equal(t, "id", event.Columns[0].Name)
+	ordered := (&columnOrderEncoder{rules: codecConfig.ColumnOrders}).orderColumns(event)
+	require.Equal(t, reversed, names(ordered.Columns))
+	require.Equal(t, [][]int{{3}, {2, 0}}, ordered.IndexColumns)
+
+	// the columns not listed go last in their original order, or dropped,
+	// and the ones listed but missing are skipped.
+	rules := []common.ColumnOrderRule{{
+		TableMatcher: common.MustNewTableMatcher("test.*"), Columns: []string{"age", "phone", "id"},
+	}}
+	ordered = (&columnOrderEncoder{rules: rules}).orderColumns(event)
+	require.Equal(t, []string{"age", "id", "name", "email"}, names(ordered.Columns))
+	require.Equal(t, [][]int{{1}, {2, 3}}, ordered.IndexColumns)
+	ordered = (&columnOrderEncoder{rules: rules, dropUnlisted: true}).orderColumns(event)
+	require.Equal(t, []string{"age", "id"}, names(ordered.Columns))
+	require.Equal(t, []string{"age", "id"}, names(ordered.PreColumns))
+	require.Equal(t, [][]int{{1}}, ordered.IndexColumns)
+
+	// the rows of the other tables are emitted as is.
+	other := *event
+	other.Table = &model.TableName{Schema: "other", Table: "users"}
+	require.Same(t, &other, (&columnOrderEncoder{rules: rules}).orderColumns(&other))
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+
+	"github.com/pingcap/tidb/util/rowcodec"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// columnSelectionEncoder drops the columns not included and renames the ones
+// aliased by the rules before the row events are encoded, so that the
+// consumer sees the columns by the names of its own representation. The
+// event is copied rather than changed, since it's shared by the sinks. The
+// indexes with any column dropped are dropped, and the features derived from
+// the schema of the table still see the original names. The DDL and the
+// checkpoint are not changed.
+type columnSelectionEncoder struct {
+	encoderWrapper
+	rules []common.ColumnSelectionRule
+}
+
+// selectColumns returns the row event with the columns selected by the first
+// rule matching its table, the rows of the tables matched by none are
+// returned as is.
+func (e *columnSelectionEncoder) selectColumns(event *model.RowChangedEvent) *model.RowChangedEvent {
+	var rule *common.ColumnSelectionRule
+	for i := range e.rules {
+		if e.rules[i].MatchTable(*event.Table) {
+			rule = &e.rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return event
+	}
+
+	// offsets maps the offset of each column to the one selected, -1 if the
+	// column is dropped. The columns and the old columns share the offsets.
+	columns := event.Columns
+	if len(columns) == 0 {
+		columns = event.PreColumns
+	}
+	offsets := make([]int, len(columns))
+	selected := 0
+	for i, c := range columns {
+		offsets[i] = -1
+		if c == nil {
+			continue
+		}
+		if _, include := rule.Select(c.Name); include {
+			offsets[i] = selected
+			selected++
+		}
+	}
+
+	result := *event
+	result.Columns = selectColumns(rule, event.Columns, offsets, selected)
+	result.PreColumns = selectColumns(rule, event.PreColumns, offsets, selected)
+	if len(event.ColInfos) == len(offsets) {
+		result.ColInfos = make([]rowcodec.ColInfo, 0, selected)
+		for i, info := range event.ColInfos {
+			if offsets[i] >= 0 {
+				result.ColInfos = append(result.ColInfos, info)
+			}
+		}
+	}
+	result.IndexColumns = nil
+	for _, index := range event.IndexColumns {
+		remapped := make([]int, 0, len(index))
+		for _, offset := range index {
+			if offset < 0 || offset >= len(offsets) || offsets[offset] < 0 {
+				remapped = nil
+				break
+			}
+			remapped = append(remapped, offsets[offset])
+		}
+		if remapped != nil {
+			result.IndexColumns = append(result.IndexColumns, remapped)
+		}
+	}
+	return &result
+}
+
+// selectColumns returns the columns selected by the rule at the offsets.
+func selectColumns(
+	rule *common.ColumnSelectionRule, columns []*model.Column, offsets []int, selected int,
+) []*model.Column {
+	if len(columns) == 0 {
+		return columns
+	}
+	result := make([]*model.Column, 0, selected)
+	for i, c := range columns {
+		if i >= len(offsets) || offsets[i] < 0 {
+			continue
+		}
+		name, _ := rule.Select(c.Name)
+		if name != c.Name {
+			aliased := *c
+			aliased.Name = name
+			c = &aliased
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *columnSelectionEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	return e.encoder.AppendRowChangedEvent(ctx, topic, e.selectColumns(event), callback)
+}
+
+type columnSelectionEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	rules   []common.ColumnSelectionRule
+}
+
+// Build implements the EncoderBuilder interface
+func (b *columnSelectionEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &columnSelectionEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, rules: b.rules}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnSelectionEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	columns := func(name string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte(name)},
+			{Name: "password", Type: mysql.TypeVarchar, Value: []byte("secret")},
+		}
+	}
+	event := &model.RowChangedEvent{
+		CommitTs:     417318403368288260,
+		Table:        &model.TableName{Schema: "test", Table: "users"},
+		PreColumns:   columns("bob"),
+		Columns:      columns("alice"),
+		IndexColumns: [][]int{{0}, {1, 2}},
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.ColumnSelections = []common.ColumnSelectionRule{{
+		TableMatcher: common.MustNewTableMatcher("test.users"),
+		Columns: []common.ColumnSelection{
+			{Column: "NAME", Alias: "user_name", Include: true},
+			{Column: "password", Include: false},
+		},
+	}}
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", event, nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	var msg struct {
+		Data      []map[string]interface{} `json:"data"`
+		Old       []map[string]interface{} `json:"old"`
+		MySQLType map[string]string        `json:"mysqlType"`
+	}
+	require.NoError(t, json.Unmarshal(msgs[0].Value, &msg))
+	// the name is aliased and the password is excluded, in both images.
+	require.Equal(t, []map[string]interface{}{{"id": "1", "user_name": "alice"}}, msg.Data)
+	require.Equal(t, []map[string]interface{}{{"id": "1", "user_name": "bob"}}, msg.Old)
+	require.Equal(t, map[string]string{"id": "bigint", "user_name": "varchar"}, msg.MySQLType)
+
+	// the event is not changed, and the index with the password is dropped.
+	require.Equal(t, "name", event.Columns[1].Name)
+	require.Len(t, event.Columns, 3)
+	selected := (&columnSelectionEncoder{rules: codecConfig.ColumnSelections}).selectColumns(event)
+	require.Equal(t, [][]int{{0}}, selected.IndexColumns)
+
+	// the rows of the other tables are emitted as is.
+	other := *event
+	other.Table = &model.TableName{Schema: "test", Table: "orders"}
+	require.Same(t, &other, (&columnSelectionEncoder{rules: codecConfig.ColumnSelections}).selectColumns(&other))
+}
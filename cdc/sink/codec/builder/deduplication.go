@@ -0,0 +1,158 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+)
+
+// deduplicationEncoder suppresses the row events whose content hash matches
+// the one of a row event appended recently, i.e. the identical events
+// produced by the retries of the upstream. The callback of the row suppressed
+// is called at once, since nothing is sent for it. The DDL and the checkpoint
+// are not deduplicated.
+type deduplicationEncoder struct {
+	encoderWrapper
+	window *dedupWindow
+}
+
+// dedupWindow remembers the content hashes of the row events appended, the
+// DedupWindowSize ones at most, each for the DedupWindowTTL at most. It's
+// shared by the encoders of the builder, since the retried events are not
+// necessarily appended to the same encoder.
+//
+// The deduplication is approximate: the duplicate is emitted if the hash of it
+// is forgotten, and a distinct event is suppressed if the hashes collide,
+// which is unlikely by the 128 bits hash. The hash covers the commit ts of the
+// event, so that the changes setting the value back and forth are never
+// suppressed, even within the window.
+type dedupWindow struct {
+	mu   sync.Mutex
+	size int
+	ttl  time.Duration
+	// entries is the ring of the hashes in the order they're remembered,
+	// the oldest one is at head.
+	entries []dedupEntry
+	head    int
+	count   int
+	hashes  map[[16]byte]struct{}
+	// now is replaced in the tests.
+	now func() time.Time
+}
+
+type dedupEntry struct {
+	hash [16]byte
+	at   time.Time
+}
+
+func newDedupWindow(size int, ttl time.Duration) *dedupWindow {
+	return &dedupWindow{
+		size:    size,
+		ttl:     ttl,
+		entries: make([]dedupEntry, size),
+		hashes:  make(map[[16]byte]struct{}, size),
+		now:     time.Now,
+	}
+}
+
+// seen returns whether the hash is remembered, and remembers it otherwise.
+// The hash seen is not refreshed, so that it's forgotten as scheduled.
+func (w *dedupWindow) seen(hash [16]byte) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	for w.count > 0 && w.ttl > 0 && now.Sub(w.entries[w.head].at) >= w.ttl {
+		w.forgetOldest()
+	}
+	if _, ok := w.hashes[hash]; ok {
+		return true
+	}
+	if w.count == w.size {
+		w.forgetOldest()
+	}
+	w.entries[(w.head+w.count)%w.size] = dedupEntry{hash: hash, at: now}
+	w.count++
+	w.hashes[hash] = struct{}{}
+	return false
+}
+
+func (w *dedupWindow) forgetOldest() {
+	delete(w.hashes, w.entries[w.head].hash)
+	w.head = (w.head + 1) % w.size
+	w.count--
+}
+
+// rowContentHash returns the hash of the table, the commit ts, and the old
+// and the new image of the row.
+func rowContentHash(event *model.RowChangedEvent) [16]byte {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(event.Table.Schema))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(event.Table.Table))
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], event.CommitTs)
+	_, _ = h.Write(ts[:])
+	for i, columns := range [][]*model.Column{event.PreColumns, event.Columns} {
+		// the images are delimited, so that the insert and the delete of
+		// the same row differ.
+		_, _ = h.Write([]byte{byte(i + 1)})
+		for _, c := range columns {
+			if c == nil {
+				continue
+			}
+			_, _ = h.Write([]byte(c.Name))
+			if c.Value == nil {
+				_, _ = h.Write([]byte{0})
+				continue
+			}
+			_, _ = h.Write([]byte{1})
+			_, _ = h.Write([]byte(model.ColumnValueString(c.Value)))
+			_, _ = h.Write([]byte{0})
+		}
+	}
+	var result [16]byte
+	h.Sum(result[:0])
+	return result
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *deduplicationEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	if e.window.seen(rowContentHash(event)) {
+		if callback != nil {
+			callback()
+		}
+		return nil
+	}
+	return e.encoder.AppendRowChangedEvent(ctx, topic, event, callback)
+}
+
+type deduplicationEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	window  *dedupWindow
+}
+
+// Build implements the EncoderBuilder interface
+func (b *deduplicationEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &deduplicationEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, window: b.window}
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicationEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	table := &model.TableName{Schema: "test", Table: "t"}
+	update := func(commitTs uint64, from, to string) *model.RowChangedEvent {
+		columns := func(v string) []*model.Column {
+			return []*model.Column{
+				{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+				{Name: "v", Type: mysql.TypeVarchar, Value: []byte(v)},
+			}
+		}
+		return &model.RowChangedEvent{
+			CommitTs: commitTs, Table: table, PreColumns: columns(from), Columns: columns(to),
+		}
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.DedupWindowSize = 2
+	codecConfig.DedupWindowTTL = time.Minute
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.Nil(t, err)
+	now := time.Unix(1667385600, 0)
+	window := builder.(*deduplicationEncoderBuilder).window
+	window.now = func() time.Time { return now }
+	encoder := builder.Build()
+
+	// emitted returns how many of the events are emitted, the callback of
+	// each event is called either at once or by the message.
+	emitted := func(events ...*model.RowChangedEvent) int {
+		called := 0
+		for _, e := range events {
+			err := encoder.AppendRowChangedEvent(ctx, "", e, func() { called++ })
+			require.Nil(t, err)
+		}
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		for _, msg := range msgs {
+			msg.Callback()
+		}
+		require.Equal(t, len(events), called)
+		return len(msgs)
+	}
+
+	// the duplicate within the window is suppressed, even if it's appended
+	// to another encoder of the builder.
+	require.Equal(t, 1, emitted(update(1, "a", "b"), update(1, "a", "b")))
+	other := builder.Build()
+	require.Nil(t, other.AppendRowChangedEvent(ctx, "", update(1, "a", "b"), nil))
+	msgs, err := other.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// setting the value back and forth is not a duplicate.
+	require.Equal(t, 2, emitted(update(2, "b", "a"), update(3, "a", "b")))
+
+	// the repeat outside the window is emitted, once the hash is evicted by
+	// the size of the window,
+	require.Equal(t, 1, emitted(update(1, "a", "b")))
+	// or expired.
+	now = now.Add(30 * time.Second)
+	require.Equal(t, 0, emitted(update(1, "a", "b")))
+	now = now.Add(time.Minute)
+	require.Equal(t, 1, emitted(update(1, "a", "b")))
+
+	// the DDL is not deduplicated.
+	ddl := &model.DDLEvent{
+		CommitTs:  1,
+		Query:     "create table t(id int primary key, v varchar(32))",
+		TableInfo: &model.TableInfo{TableName: *table},
+	}
+	for i := 0; i < 2; i++ {
+		msg, err := encoder.EncodeDDLEvent(ddl)
+		require.Nil(t, err)
+		require.NotNil(t, msg)
+	}
+}
+
+func TestRowContentHash(t *testing.T) {
+	t.Parallel()
+
+	table := &model.TableName{Schema: "test", Table: "t"}
+	columns := []*model.Column{
+		{Name: "id", Type: mysql.TypeLonglong, Value: int64(1)},
+		{Name: "v", Type: mysql.TypeVarchar, Value: nil},
+	}
+	insert := &model.RowChangedEvent{CommitTs: 1, Table: table, Columns: columns}
+	del := &model.RowChangedEvent{CommitTs: 1, Table: table, PreColumns: columns}
+	require.Equal(t, rowContentHash(insert), rowContentHash(&model.RowChangedEvent{
+		CommitTs: 1, Table: table, Columns: columns,
+	}))
+	require.NotEqual(t, rowContentHash(insert), rowContentHash(del))
+
+	// the null differs from the empty value.
+	empty := &model.RowChangedEvent{CommitTs: 1, Table: table, Columns: []*model.Column{
+		{Name: "id", Type: mysql.TypeLonglong, Value: int64(1)},
+		{Name: "v", Type: mysql.TypeVarchar, Value: []byte{}},
+	}}
+	require.NotEqual(t, rowContentHash(insert), rowContentHash(empty))
+}
@@ -14,9 +14,14 @@ package builder
 
 import (
 	"context"
+	"sync"
 
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tiflow/cdc/contextutil"
 	"github.com/pingcap/tiflow/cdc/sink/codec"
 	"github.com/pingcap/tiflow/cdc/sink/codec/avro"
+	"github.com/pingcap/tiflow/cdc/sink/codec/binlog"
 	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
 	"github.com/pingcap/tiflow/cdc/sink/codec/common"
 	"github.com/pingcap/tiflow/cdc/sink/codec/craft"
@@ -24,24 +29,325 @@ import (
 	"github.com/pingcap/tiflow/cdc/sink/codec/open"
 	"github.com/pingcap/tiflow/pkg/config"
 	cerror "github.com/pingcap/tiflow/pkg/errors"
+	"go.uber.org/zap"
 )
 
-// NewEventBatchEncoderBuilder returns an EncoderBuilder
-func NewEventBatchEncoderBuilder(ctx context.Context, c *common.Config) (codec.EncoderBuilder, error) {
-	switch c.Protocol {
-	case config.ProtocolDefault, config.ProtocolOpen:
+// EncoderBuilderFactory creates the EncoderBuilder of a protocol registered
+// by RegisterEncoderBuilder by the codec config.
+type EncoderBuilderFactory func(c *common.Config) codec.EncoderBuilder
+
+// encoderBuilderFactory creates an EncoderBuilder by the codec config, the
+// built-in ones may depend on the changefeed in the ctx.
+type encoderBuilderFactory func(ctx context.Context, c *common.Config) (codec.EncoderBuilder, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[config.Protocol]encoderBuilderFactory)
+)
+
+func init() {
+	newOpenBuilder := func(_ context.Context, c *common.Config) (codec.EncoderBuilder, error) {
 		return open.NewBatchEncoderBuilder(c), nil
-	case config.ProtocolCanal:
-		return canal.NewBatchEncoderBuilder(), nil
-	case config.ProtocolAvro:
-		return avro.NewBatchEncoderBuilder(ctx, c)
-	case config.ProtocolMaxwell:
-		return maxwell.NewBatchEncoderBuilder(), nil
-	case config.ProtocolCanalJSON:
-		return canal.NewJSONBatchEncoderBuilder(c), nil
-	case config.ProtocolCraft:
-		return craft.NewBatchEncoderBuilder(c), nil
-	default:
+	}
+	builtin := map[config.Protocol]encoderBuilderFactory{
+		config.ProtocolDefault: newOpenBuilder,
+		config.ProtocolOpen:    newOpenBuilder,
+		config.ProtocolCanal: func(ctx context.Context, c *common.Config) (codec.EncoderBuilder, error) {
+			if err := canal.CheckFeatureLevel(c); err != nil {
+				return nil, err
+			}
+			// the encryptor and the dead letter hook are only provided by
+			// the callers building the canal encoders themselves, see
+			// canal.WithEncryptor and canal.WithDeadLetter.
+			if len(c.EncryptedColumns) != 0 {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					"encrypted-columns requires an encryptor, which is not provided")
+			}
+			if c.DeadLetterRetries != 0 {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					"dead-letter-retries requires a dead letter hook, which is not provided")
+			}
+			return canal.NewBatchEncoderBuilder(c,
+				canal.WithChangefeedID(contextutil.ChangefeedIDFromCtx(ctx)),
+				canal.WithTimezone(contextutil.TimezoneFromCtx(ctx))), nil
+		},
+		config.ProtocolAvro: avro.NewBatchEncoderBuilder,
+		config.ProtocolMaxwell: func(_ context.Context, _ *common.Config) (codec.EncoderBuilder, error) {
+			return maxwell.NewBatchEncoderBuilder(), nil
+		},
+		config.ProtocolCanalJSON: func(_ context.Context, c *common.Config) (codec.EncoderBuilder, error) {
+			return canal.NewJSONBatchEncoderBuilder(c), nil
+		},
+		config.ProtocolCraft: func(_ context.Context, c *common.Config) (codec.EncoderBuilder, error) {
+			return craft.NewBatchEncoderBuilder(c), nil
+		},
+		config.ProtocolCanalColumnar: func(_ context.Context, c *common.Config) (codec.EncoderBuilder, error) {
+			return canal.NewColumnarBatchEncoderBuilder(c), nil
+		},
+		config.ProtocolMySQLBinlog: func(ctx context.Context, c *common.Config) (codec.EncoderBuilder, error) {
+			return binlog.NewBatchEncoderBuilder(c, contextutil.TimezoneFromCtx(ctx)), nil
+		},
+	}
+	for protocol, factory := range builtin {
+		if err := registerEncoderBuilder(protocol, factory); err != nil {
+			log.Panic("register builtin encoder builder failed",
+				zap.Int("protocol", int(protocol)), zap.Error(err))
+		}
+	}
+}
+
+// RegisterEncoderBuilder registers the factory of the EncoderBuilder for the
+// protocol of the name, so that an encoder not maintained in this repository
+// can be selected by the protocol of the sink URI and resolved by
+// NewEventBatchEncoderBuilder. It returns the Protocol allocated for the name,
+// see config.RegisterProtocol, and a name can only be registered once.
+func RegisterEncoderBuilder(name string, factory EncoderBuilderFactory) (config.Protocol, error) {
+	if factory == nil {
+		return config.ProtocolUnknown, cerror.ErrCodecInvalidConfig.GenWithStack(
+			"encoder builder factory for protocol %s is nil", name)
+	}
+	protocol, err := config.RegisterProtocol(name)
+	if err != nil {
+		return config.ProtocolUnknown, errors.Trace(err)
+	}
+	err = registerEncoderBuilder(protocol,
+		func(_ context.Context, c *common.Config) (codec.EncoderBuilder, error) {
+			return factory(c), nil
+		})
+	return protocol, errors.Trace(err)
+}
+
+// registerEncoderBuilder registers the factory of the EncoderBuilder for the
+// protocol, the built-in protocols are registered by it at init.
+func registerEncoderBuilder(protocol config.Protocol, factory encoderBuilderFactory) error {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, ok := factories[protocol]; ok {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			"encoder builder for protocol %s is already registered", protocol)
+	}
+	factories[protocol] = factory
+	return nil
+}
+
+// NewEventBatchEncoderBuilder returns an EncoderBuilder, the encoders built
+// route the events of the tables in the TableProtocols to the encoder of the
+// protocol overridden, see tableProtocolEncoder, and encode the events by
+// the FanoutProtocols as well, see fanoutEncoder, and wrap the messages in the
+// CloudEvents envelope if EnableCloudEvents is set, see cloudEventsEncoder.
+// The messages carry the namespace of the changefeed in the ctx if
+// EnableNamespace is set, see namespaceEncoder, and the TTL hinted by the
+// MessageTTLs, see messageTTLEncoder, and the dense sequence if
+// EnableMessageSequence is set, see messageSequenceEncoder, and the Pulsar
+// schema info if EnablePulsarSchema is set, see pulsarSchemaEncoder. The row
+// events not kept by the RowFilter are dropped, see rowFilterEncoder, and so
+// are the ones not sampled by the RowSamplings, see rowSamplingEncoder. The
+// duplicates of the row events appended recently are suppressed if the
+// DedupWindowSize is set, see deduplicationEncoder. The columns of the row
+// events kept are selected by the ColumnSelections, see
+// columnSelectionEncoder, and ordered by the ColumnOrders, see
+// columnOrderEncoder. The schema and the table routing the messages are
+// prefixed by the RoutingPrefix, see routingPrefixEncoder. The messages are
+// signed by the SigningKey if set, see messageSigningEncoder, and the messages
+// of the rows carry the values of the columns of the ColumnHeaders in the
+// headers, see columnHeaderEncoder. The events of the SuppressedSchemas are
+// not suppressed by the encoders built but by the sinks, see EventSuppressor.
+//
+// The size of the headers stamped by the wrappers is reserved from the
+// MaxMessageBytes of the encoders wrapped, see reserveHeader, so that they
+// split the batches accounting for the headers. The Pulsar schema info is not
+// reserved, since the size of it is unknown until the message is built, and
+// the canal-json messages are not split by the size anyway.
+func NewEventBatchEncoderBuilder(ctx context.Context, c *common.Config) (codec.EncoderBuilder, error) {
+	if c.SigningKey != nil {
+		signer, err := common.NewSigner(c.SigningAlgorithm, c.SigningKeyID, c.SigningKey)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		inner := *c
+		inner.SigningKey = nil
+		inner.SigningKeyID = ""
+		inner.SigningAlgorithm = ""
+		err = reserveHeader(&inner, common.HeaderLength(common.HeaderSignature, signer.Size())+
+			common.HeaderLength(common.HeaderSigningKeyID, len(signer.KeyID())))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &messageSigningEncoderBuilder{builder: builder, signer: signer}, nil
+	}
+	if c.RowFilter != "" {
+		rules, err := parseRowFilters(c.RowFilter)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		inner := *c
+		inner.RowFilter = ""
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &rowFilterEncoderBuilder{builder: builder, rules: rules}, nil
+	}
+	if len(c.RowSamplings) != 0 {
+		inner := *c
+		inner.RowSamplings = nil
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &rowSamplingEncoderBuilder{builder: builder, rules: c.RowSamplings}, nil
+	}
+	if c.DedupWindowSize != 0 {
+		inner := *c
+		inner.DedupWindowSize = 0
+		inner.DedupWindowTTL = 0
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &deduplicationEncoderBuilder{
+			builder: builder,
+			window:  newDedupWindow(c.DedupWindowSize, c.DedupWindowTTL),
+		}, nil
+	}
+	if len(c.ColumnSelections) != 0 {
+		inner := *c
+		inner.ColumnSelections = nil
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &columnSelectionEncoderBuilder{builder: builder, rules: c.ColumnSelections}, nil
+	}
+	if len(c.ColumnOrders) != 0 {
+		inner := *c
+		inner.ColumnOrders = nil
+		inner.ColumnOrderUnlisted = ""
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &columnOrderEncoderBuilder{
+			builder:      builder,
+			rules:        c.ColumnOrders,
+			dropUnlisted: c.ColumnOrderUnlisted == common.ColumnOrderUnlistedDrop,
+		}, nil
+	}
+	if c.RoutingPrefix != "" {
+		inner := *c
+		inner.RoutingPrefix = ""
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &routingPrefixEncoderBuilder{builder: builder, prefix: c.RoutingPrefix}, nil
+	}
+	if c.EnableMessageSequence {
+		inner := *c
+		inner.EnableMessageSequence = false
+		err := reserveHeader(&inner, common.HeaderLength(common.HeaderSequence, common.HeaderUint64Length))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return newMessageSequenceEncoderBuilder(builder), nil
+	}
+	if len(c.MessageTTLs) != 0 {
+		inner := *c
+		inner.MessageTTLs = nil
+		err := reserveHeader(&inner, common.HeaderLength(common.HeaderTTL, common.HeaderUint64Length))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &messageTTLEncoderBuilder{builder: builder, rules: c.MessageTTLs}, nil
+	}
+	if c.EnableNamespace {
+		inner := *c
+		inner.EnableNamespace = false
+		// the empty namespace is not stamped, so nothing is reserved for it.
+		if namespace := contextutil.ChangefeedIDFromCtx(ctx).Namespace; namespace != "" {
+			err := reserveHeader(&inner, common.HeaderLength(common.HeaderNamespace, len(namespace)))
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return newNamespaceEncoderBuilder(ctx, builder), nil
+	}
+	if c.EnablePulsarSchema {
+		inner := *c
+		inner.EnablePulsarSchema = false
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &pulsarSchemaEncoderBuilder{
+			builder:             builder,
+			enableTiDBExtension: c.EnableTiDBExtension,
+		}, nil
+	}
+	if c.EnableCloudEvents {
+		inner := *c
+		inner.EnableCloudEvents = false
+		source := cloudEventsSource(ctx)
+		maxMessageBytes, err := cloudEventsMaxMessageBytes(c.MaxMessageBytes, source)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		inner.MaxMessageBytes = maxMessageBytes
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return newCloudEventsEncoderBuilder(builder, source), nil
+	}
+	if len(c.FanoutProtocols) != 0 {
+		return newFanoutEncoderBuilder(ctx, c)
+	}
+	if len(c.TableProtocols) != 0 {
+		return newTableProtocolEncoderBuilder(ctx, c)
+	}
+	// the headers are copied by the encoder of each protocol, whose messages
+	// carry the rows in the order appended.
+	if len(c.ColumnHeaders) != 0 {
+		inner := *c
+		inner.ColumnHeaders = nil
+		builder, err := NewEventBatchEncoderBuilder(ctx, &inner)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &columnHeaderEncoderBuilder{builder: builder, headers: c.ColumnHeaders}, nil
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[c.Protocol]
+	factoriesMu.RUnlock()
+	if !ok {
 		return nil, cerror.ErrSinkUnknownProtocol.GenWithStackByArgs(c.Protocol)
 	}
+	return factory(ctx, c)
+}
+
+// reserveHeader reserves the expected size of the header from the
+// MaxMessageBytes of the config of the encoders wrapped.
+func reserveHeader(c *common.Config, length int) error {
+	if length >= c.MaxMessageBytes {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			"max-message-bytes %d is too small for the message headers", c.MaxMessageBytes)
+	}
+	c.MaxMessageBytes -= length
+	return nil
 }
@@ -0,0 +1,178 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEncoder struct {
+	rows int
+}
+
+func (e *fakeEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	return nil, nil
+}
+
+func (e *fakeEncoder) AppendRowChangedEvent(
+	_ context.Context, _ string, _ *model.RowChangedEvent, _ func(),
+) error {
+	e.rows++
+	return nil
+}
+
+func (e *fakeEncoder) EncodeDDLEvent(_ *model.DDLEvent) (*common.Message, error) {
+	return nil, nil
+}
+
+func (e *fakeEncoder) Build() ([]*common.Message, error) {
+	return nil, nil
+}
+
+type fakeEncoderBuilder struct{}
+
+func (b *fakeEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &fakeEncoder{}
+}
+
+func TestRegisterEncoderBuilder(t *testing.T) {
+	t.Parallel()
+
+	newFakeBuilder := func(_ *common.Config) codec.EncoderBuilder {
+		return &fakeEncoderBuilder{}
+	}
+	uri := "kafka://127.0.0.1:9092/abc?protocol=fake-protocol"
+	sinkURI, err := url.Parse(uri)
+	require.NoError(t, err)
+	_, err = config.ParseSinkProtocolFromString(sinkURI.Query().Get(config.ProtocolKey))
+	require.ErrorContains(t, err, "unknown 'fake-protocol' message protocol")
+
+	fakeProtocol, err := RegisterEncoderBuilder("fake-protocol", newFakeBuilder)
+	require.NoError(t, err)
+	require.Equal(t, "fake-protocol", fakeProtocol.String())
+
+	// the protocol registered is selected by the sink URI.
+	protocol, err := config.ParseSinkProtocolFromString(sinkURI.Query().Get(config.ProtocolKey))
+	require.NoError(t, err)
+	require.Equal(t, fakeProtocol, protocol)
+	codecConfig := common.NewConfig(protocol)
+	require.NoError(t, codecConfig.Apply(sinkURI, config.GetDefaultReplicaConfig()))
+	require.NoError(t, codecConfig.Validate())
+	b, err := NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.NoError(t, err)
+	require.IsType(t, &fakeEncoder{}, b.Build())
+
+	// duplicate registration is rejected.
+	_, err = RegisterEncoderBuilder("FAKE-PROTOCOL", newFakeBuilder)
+	require.ErrorContains(t, err, "already registered")
+	_, err = RegisterEncoderBuilder("another-fake-protocol", nil)
+	require.ErrorContains(t, err, "is nil")
+
+	// built-in protocols are registered at init.
+	_, err = RegisterEncoderBuilder("canal", newFakeBuilder)
+	require.ErrorContains(t, err, "already registered")
+	b, err = NewEventBatchEncoderBuilder(context.Background(), common.NewConfig(config.ProtocolCanal))
+	require.NoError(t, err)
+	require.IsType(t, &canal.BatchEncoder{}, b.Build())
+
+	// the encrypted columns fail the creation without an encryptor.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	codecConfig.EncryptedColumns = []common.EncryptedColumnsRule{{
+		TableMatcher: common.MustNewTableMatcher("test.users"),
+		KeyID:        "k1",
+		Columns:      []string{"email"},
+	}}
+	_, err = NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.ErrorContains(t, err, "encrypted-columns requires an encryptor")
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	codecConfig.DeadLetterRetries = 2
+	_, err = NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.ErrorContains(t, err, "dead-letter-retries requires a dead letter hook")
+
+	// the features not supported by the feature level fail the creation
+	// only if strict-feature-level is set.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	codecConfig.FeatureLevel = 2
+	codecConfig.EnableSequence = true
+	_, err = NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.NoError(t, err)
+	codecConfig.StrictFeatureLevel = true
+	_, err = NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.ErrorContains(t, err, "enable-sequence not supported by feature-level 2")
+}
+
+func TestEncoderWrappersHeaderOverhead(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextutil.PutChangefeedIDInCtx(context.Background(),
+		model.ChangeFeedID{Namespace: "tenant-with-a-long-name", ID: "test"})
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns:  []*model.Column{{Name: "id", Type: mysql.TypeLong, Value: 1}},
+	}
+	build := func(codecConfig *common.Config, rows int) []*common.Message {
+		builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+		require.NoError(t, err)
+		encoder := builder.Build()
+		for i := 0; i < rows; i++ {
+			require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+		}
+		messages, err := encoder.Build()
+		require.NoError(t, err)
+		return messages
+	}
+
+	// the max-message-bytes fitting exactly two rows without the headers.
+	maxMessageBytes := build(common.NewConfig(config.ProtocolOpen), 2)[0].Length()
+
+	codecConfig := common.NewConfig(config.ProtocolOpen)
+	codecConfig.MaxMessageBytes = maxMessageBytes
+	msgs := build(codecConfig, 4)
+	require.Len(t, msgs, 2)
+	require.Equal(t, 2, msgs[0].GetRowsCount())
+
+	// the headers of the metadata stamped are accounted for when splitting.
+	codecConfig = common.NewConfig(config.ProtocolOpen)
+	codecConfig.MaxMessageBytes = maxMessageBytes
+	codecConfig.EnableNamespace = true
+	codecConfig.EnableMessageSequence = true
+	codecConfig.MessageTTLs = []common.MessageTTLRule{
+		{TableMatcher: common.MustNewTableMatcher("*.*"), Operation: common.MessageTTLOperationAny, TTL: time.Hour},
+	}
+	msgs = build(codecConfig, 4)
+	require.Len(t, msgs, 4)
+	for _, msg := range msgs {
+		require.Equal(t, 1, msg.GetRowsCount())
+		require.NotZero(t, msg.HeadersLength())
+		require.LessOrEqual(t, msg.Length(), maxMessageBytes)
+	}
+
+	// the headers can't be fitted at all.
+	codecConfig.MaxMessageBytes = common.HeaderLength(common.HeaderSequence, common.HeaderUint64Length)
+	_, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.ErrorContains(t, err, "too small for the message headers")
+}
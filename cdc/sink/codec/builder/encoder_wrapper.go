@@ -0,0 +1,75 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// encoderWrapper is embedded by the encoders wrapping another encoder, it
+// forwards the EventBatchEncoder interface and the optional encoder
+// interfaces to the encoder wrapped, so that each wrapper only overrides the
+// methods it changes. The optional interfaces not supported by the encoder
+// wrapped are no-ops, e.g. a nil heartbeat is returned.
+type encoderWrapper struct {
+	encoder codec.EventBatchEncoder
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface
+func (w encoderWrapper) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	return w.encoder.EncodeCheckpointEvent(ts)
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (w encoderWrapper) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	return w.encoder.AppendRowChangedEvent(ctx, topic, event, callback)
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (w encoderWrapper) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	return w.encoder.EncodeDDLEvent(event)
+}
+
+// Build implements the EventBatchEncoder interface
+func (w encoderWrapper) Build() ([]*common.Message, error) {
+	return w.encoder.Build()
+}
+
+// EncodeHeartbeat implements the HeartbeatEncoder interface
+func (w encoderWrapper) EncodeHeartbeat(ts uint64) (*common.Message, error) {
+	if heartbeat, ok := w.encoder.(codec.HeartbeatEncoder); ok {
+		return heartbeat.EncodeHeartbeat(ts)
+	}
+	return nil, nil
+}
+
+// ShouldFlush implements the FlushHintEncoder interface
+func (w encoderWrapper) ShouldFlush() bool {
+	hint, ok := w.encoder.(codec.FlushHintEncoder)
+	return ok && hint.ShouldFlush()
+}
+
+// WaitDDL implements the DDLThrottledEncoder interface
+func (w encoderWrapper) WaitDDL(ctx context.Context) error {
+	if throttled, ok := w.encoder.(codec.DDLThrottledEncoder); ok {
+		return throttled.WaitDDL(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoderWrapperHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	ctx := contextutil.PutChangefeedIDInCtx(context.Background(),
+		model.ChangeFeedID{Namespace: "tenant", ID: "test"})
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableNamespace = true
+	codecConfig.EnableMessageSequence = true
+	codecConfig.RowFilter = "test.*:id = 1"
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+
+	// the heartbeat is forwarded through the wrappers and stamped by them.
+	heartbeat, ok := builder.Build().(codec.HeartbeatEncoder)
+	require.True(t, ok)
+	msg, err := heartbeat.EncodeHeartbeat(417318403368288270)
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	require.Equal(t, "tenant", msg.Namespace)
+	require.Equal(t, uint64(1), msg.Sequence)
+
+	// nil is returned if the protocol doesn't support it.
+	codecConfig.Protocol = config.ProtocolCanalJSON
+	builder, err = NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	heartbeat, ok = builder.Build().(codec.HeartbeatEncoder)
+	require.True(t, ok)
+	msg, err = heartbeat.EncodeHeartbeat(417318403368288270)
+	require.NoError(t, err)
+	require.Nil(t, msg)
+}
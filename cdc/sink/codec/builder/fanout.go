@@ -0,0 +1,125 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// fanoutEncoder encodes each event by the encoder of the Protocol and the
+// encoders of the FanoutProtocols, so that the consumers of the protocols can
+// be validated against each other during the migration of the protocol. Each
+// message built carries the protocol of the encoder building it, so that the
+// sink sends it to the topic of the protocol, see ProtocolTopics. The callback
+// of the row is called once the messages of all the protocols are
+// acknowledged. The DDL, the checkpoint and the heartbeat encoded by the
+// fanout protocols are returned by the next Build, see multiProtocolEncoder.
+type fanoutEncoder struct {
+	multiProtocolEncoder
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface. If any
+// of the encoders fails, the batches of all of them are dropped, so that no
+// protocol emits the row the others failed to encode. The callbacks of the
+// rows dropped are never called, they're replicated again once the sink is
+// restarted by the error.
+func (e *fanoutEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	encoders := e.all()
+	if callback != nil {
+		// the messages of the protocols are acknowledged concurrently.
+		pending := int32(len(encoders))
+		inner := callback
+		callback = func() {
+			if atomic.AddInt32(&pending, -1) == 0 {
+				inner()
+			}
+		}
+	}
+	for _, encoder := range encoders {
+		if err := encoder.AppendRowChangedEvent(ctx, topic, event, callback); err != nil {
+			_, _ = e.Build()
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (e *fanoutEncoder) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	for _, encoder := range e.encoders {
+		msg, err := encoder.EncodeDDLEvent(event)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if msg != nil {
+			e.pending = append(e.pending, msg)
+		}
+	}
+	return e.defaultEncoder.EncodeDDLEvent(event)
+}
+
+type fanoutEncoderBuilder struct {
+	defaultBuilder codec.EncoderBuilder
+	builders       []codec.EncoderBuilder
+}
+
+// Build implements the EncoderBuilder interface
+func (b *fanoutEncoderBuilder) Build() codec.EventBatchEncoder {
+	encoder := &fanoutEncoder{
+		multiProtocolEncoder: multiProtocolEncoder{
+			defaultEncoder: b.defaultBuilder.Build(),
+			encoders:       make([]codec.EventBatchEncoder, 0, len(b.builders)),
+		},
+	}
+	for _, builder := range b.builders {
+		encoder.encoders = append(encoder.encoders, builder.Build())
+	}
+	return encoder
+}
+
+// newFanoutEncoderBuilder creates the builder of the default protocol and each
+// of the FanoutProtocols. The codec config of the fanout protocols is the same
+// as the default one, except the protocol.
+func newFanoutEncoderBuilder(
+	ctx context.Context, c *common.Config,
+) (codec.EncoderBuilder, error) {
+	defaultConfig := *c
+	defaultConfig.FanoutProtocols = nil
+	defaultBuilder, err := NewEventBatchEncoderBuilder(ctx, &defaultConfig)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	result := &fanoutEncoderBuilder{
+		defaultBuilder: defaultBuilder,
+	}
+	for _, protocol := range c.FanoutProtocols {
+		protocolConfig := defaultConfig
+		protocolConfig.Protocol = protocol
+		builder, err := NewEventBatchEncoderBuilder(ctx, &protocolConfig)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		result.builders = append(result.builders, builder)
+	}
+	return result, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/pingcap/errors"
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFanoutEncoder(t *testing.T) {
+	t.Parallel()
+
+	sinkURI, err := url.Parse("kafka://127.0.0.1:9092/abc?protocol=canal&fanout-protocols=canal-json&protocol-topics=canal-json:abc-json")
+	require.NoError(t, err)
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	require.NoError(t, codecConfig.Apply(sinkURI, config.GetDefaultReplicaConfig()))
+	require.NoError(t, codecConfig.Validate())
+
+	builder, err := NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: int64(1)},
+		},
+	}
+	called := 0
+	err = encoder.AppendRowChangedEvent(context.Background(), "", row, func() { called++ })
+	require.NoError(t, err)
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	// the row is encoded by both protocols.
+	require.Equal(t, config.ProtocolCanal, msgs[0].Protocol)
+	decoder, err := canal.NewPacketDecoder(msgs[0].Value)
+	require.NoError(t, err)
+	tp, ok, err := decoder.HasNext()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, model.MessageTypeRow, tp)
+	decoded, err := decoder.NextRowChangedEvent()
+	require.NoError(t, err)
+	require.Equal(t, "t", decoded.Table.Table)
+
+	require.Equal(t, config.ProtocolCanalJSON, msgs[1].Protocol)
+	jsonDecoder := canal.NewBatchDecoder(msgs[1].Value, false)
+	tp, ok, err = jsonDecoder.HasNext()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, model.MessageTypeRow, tp)
+	decoded, err = jsonDecoder.NextRowChangedEvent()
+	require.NoError(t, err)
+	require.Equal(t, "t", decoded.Table.Table)
+
+	// the callback is called once both messages are acknowledged.
+	msgs[1].Callback()
+	require.Equal(t, 0, called)
+	msgs[0].Callback()
+	require.Equal(t, 1, called)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// the DDL is encoded by both protocols, the one of the fanout protocol
+	// is returned by Build.
+	msg, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     "create table test.t(id int primary key)",
+		Type:      timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, config.ProtocolCanal, msg.Protocol)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, config.ProtocolCanalJSON, msgs[0].Protocol)
+	require.Equal(t, model.MessageTypeDDL, msgs[0].Type)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
+
+// failingEncoder fails to append any row to the encoder embedded.
+type failingEncoder struct {
+	codec.EventBatchEncoder
+}
+
+func (failingEncoder) AppendRowChangedEvent(
+	context.Context, string, *model.RowChangedEvent, func(),
+) error {
+	return errors.New("append failed")
+}
+
+func TestFanoutEncoderAppendFailed(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	builder, err := NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.NoError(t, err)
+	encoder := &fanoutEncoder{multiProtocolEncoder{
+		defaultEncoder: builder.Build(),
+		encoders:       []codec.EventBatchEncoder{failingEncoder{builder.Build()}},
+	}}
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: int64(1)},
+		},
+	}
+	// the row appended by the default encoder is dropped along with the
+	// batch of it.
+	err = encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
+	require.ErrorContains(t, err, "append failed")
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
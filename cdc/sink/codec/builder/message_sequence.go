@@ -0,0 +1,94 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"go.uber.org/atomic"
+)
+
+// messageSequenceEncoder stamps a dense sequence onto each message built by
+// the encoder, which starts from 1 and increases by exactly one per message,
+// and is produced in the header of it, so that the consumer detects the loss
+// of the messages by the gaps. Unlike the commit ts, the sequence has no gap
+// unless a message is lost.
+//
+// The sequence is shared by all the encoders built by the same builder, i.e.
+// it's per changefeed, and it persists across the Build calls. It's assigned
+// in the order the messages are built, so the gaps are only meaningful if the
+// messages are sent in that order to a single partition. The heartbeat is
+// stamped as well.
+type messageSequenceEncoder struct {
+	encoderWrapper
+	sequence *atomic.Uint64
+}
+
+func (e *messageSequenceEncoder) stamp(msg *common.Message, err error) (*common.Message, error) {
+	if msg != nil {
+		msg.Sequence = e.sequence.Inc()
+	}
+	return msg, err
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface
+func (e *messageSequenceEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	return e.stamp(e.encoder.EncodeCheckpointEvent(ts))
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (e *messageSequenceEncoder) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	return e.stamp(e.encoder.EncodeDDLEvent(event))
+}
+
+// EncodeHeartbeat implements the HeartbeatEncoder interface
+func (e *messageSequenceEncoder) EncodeHeartbeat(ts uint64) (*common.Message, error) {
+	return e.stamp(e.encoderWrapper.EncodeHeartbeat(ts))
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *messageSequenceEncoder) Build() ([]*common.Message, error) {
+	messages, err := e.encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(messages) == 0 {
+		return messages, nil
+	}
+	// the sequences of the messages built together are allocated at once,
+	// so that they are contiguous.
+	last := e.sequence.Add(uint64(len(messages)))
+	for i, msg := range messages {
+		msg.Sequence = last - uint64(len(messages)-1-i)
+	}
+	return messages, nil
+}
+
+type messageSequenceEncoderBuilder struct {
+	builder  codec.EncoderBuilder
+	sequence *atomic.Uint64
+}
+
+// Build implements the EncoderBuilder interface
+func (b *messageSequenceEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &messageSequenceEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, sequence: b.sequence}
+}
+
+// newMessageSequenceEncoderBuilder wraps the builder, so that the messages
+// built by its encoders carry the sequence shared among them.
+func newMessageSequenceEncoderBuilder(builder codec.EncoderBuilder) codec.EncoderBuilder {
+	return &messageSequenceEncoderBuilder{builder: builder, sequence: atomic.NewUint64(0)}
+}
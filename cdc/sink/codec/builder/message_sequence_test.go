@@ -0,0 +1,111 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageSequenceEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newRow := func(id int) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: id},
+			},
+		}
+	}
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288270,
+		Query:    "create table test.t(id int primary key)",
+		Type:     timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "t"},
+		},
+	}
+
+	// canal-json builds a message per row.
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.EnableMessageSequence = true
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.Nil(t, err)
+	encoder := builder.Build()
+
+	// requireSequences checks the messages carry the sequences following
+	// the expected one by exactly one.
+	expected := uint64(1)
+	requireSequences := func(msgs ...*common.Message) {
+		for _, msg := range msgs {
+			require.Equal(t, expected, msg.Sequence)
+			expected++
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, encoder.AppendRowChangedEvent(ctx, "", newRow(i), nil))
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	requireSequences(msgs...)
+
+	msg, err := encoder.EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	requireSequences(msg)
+
+	// the empty batch does not consume the sequence.
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// the sequence persists across the Build calls.
+	for i := 0; i < 2; i++ {
+		require.Nil(t, encoder.AppendRowChangedEvent(ctx, "", newRow(i), nil))
+	}
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	requireSequences(msgs...)
+
+	// the sequence is shared by the encoders of the same builder.
+	another := builder.Build()
+	require.Nil(t, another.AppendRowChangedEvent(ctx, "", newRow(0), nil))
+	msgs, err = another.Build()
+	require.NoError(t, err)
+	requireSequences(msgs...)
+	require.Nil(t, encoder.AppendRowChangedEvent(ctx, "", newRow(0), nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	requireSequences(msgs...)
+	require.Equal(t, uint64(9), expected)
+
+	// the sequence is not stamped by default.
+	builder, err = NewEventBatchEncoderBuilder(ctx, common.NewConfig(config.ProtocolCanalJSON))
+	require.Nil(t, err)
+	msg, err = builder.Build().EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	require.Zero(t, msg.Sequence)
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSigner signs the data by the sha256 of the key and the data.
+type fakeSigner struct {
+	keyID string
+	key   []byte
+}
+
+func (s *fakeSigner) KeyID() string { return s.keyID }
+
+func (s *fakeSigner) Size() int { return sha256.Size }
+
+func (s *fakeSigner) Sign(data []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, s.key...), data...))
+	return sum[:]
+}
+
+// verify checks the signature of the message as the consumer does.
+func (s *fakeSigner) verify(msg *common.Message) bool {
+	return msg.SigningKeyID == s.keyID && bytes.Equal(msg.Signature, s.Sign(common.SignedBytes(msg)))
+}
+
+func TestMessageSigningEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+		},
+	}
+	ddl := &model.DDLEvent{
+		CommitTs:  417318403368288270,
+		Query:     "create table test.t(id int primary key)",
+		Type:      timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	}
+
+	signer := &fakeSigner{keyID: "k1", key: []byte("secret")}
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.EnableTiDBExtension = true
+	codecConfig.EnableMessageSequence = true
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder := (&messageSigningEncoderBuilder{builder: builder, signer: signer}).Build()
+
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	msg, err := encoder.EncodeDDLEvent(ddl)
+	require.NoError(t, err)
+	msgs = append(msgs, msg)
+	msg, err = encoder.EncodeCheckpointEvent(417318403368288280)
+	require.NoError(t, err)
+	msgs = append(msgs, msg)
+	for _, msg := range msgs {
+		require.True(t, signer.verify(msg))
+		require.Equal(t, common.HeaderLength(common.HeaderSignature, sha256.Size)+
+			common.HeaderLength(common.HeaderSigningKeyID, len("k1"))+
+			common.HeaderLength(common.HeaderSequence, common.HeaderUint64Length),
+			msg.HeadersLength())
+	}
+
+	// the tampered value and metadata fail the verification.
+	tampered := *msgs[0]
+	tampered.Value = append(append([]byte{}, tampered.Value...), ' ')
+	require.False(t, signer.verify(&tampered))
+	tampered = *msgs[0]
+	tampered.Sequence++
+	require.False(t, signer.verify(&tampered))
+	tampered = *msgs[0]
+	tampered.Headers = map[string]string{"x-tenant": "acme"}
+	require.False(t, signer.verify(&tampered))
+
+	// the messages are signed by the HMAC-SHA256 of the key configured.
+	codecConfig = common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.SigningKey = []byte("secret")
+	codecConfig.SigningKeyID = "k2"
+	builder, err = NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder = builder.Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "k2", msgs[0].SigningKeyID)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(common.SignedBytes(msgs[0]))
+	require.True(t, hmac.Equal(mac.Sum(nil), msgs[0].Signature))
+}
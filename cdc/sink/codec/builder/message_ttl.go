@@ -0,0 +1,110 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// messageTTLEncoder stamps the TTL hinted by the rules onto each message built
+// by the encoder, which is produced in the header of it for the consumers
+// honoring it. Since a message may batch the rows of different tables and
+// operations, the TTL of it is the longest one of the rows, and no TTL is
+// hinted if any row has none, so that no row expires earlier than configured.
+// The checkpoint and the heartbeat are not hinted.
+type messageTTLEncoder struct {
+	encoderWrapper
+	rules []common.MessageTTLRule
+	// rows is the number of the rows appended since the last Build,
+	// ttl is the TTL of them.
+	rows int
+	ttl  time.Duration
+}
+
+// ttlOf returns the TTL of the first rule matched, zero if none is matched.
+func (e *messageTTLEncoder) ttlOf(table model.TableName, operation string) time.Duration {
+	for i := range e.rules {
+		if e.rules[i].Match(table, operation) {
+			return e.rules[i].TTL
+		}
+	}
+	return 0
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *messageTTLEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	if err := e.encoder.AppendRowChangedEvent(ctx, topic, event, callback); err != nil {
+		return err
+	}
+	operation := common.MessageTTLOperationInsert
+	if event.IsDelete() {
+		operation = common.MessageTTLOperationDelete
+	} else if event.IsUpdate() {
+		operation = common.MessageTTLOperationUpdate
+	}
+	ttl := e.ttlOf(*event.Table, operation)
+	switch {
+	case e.rows == 0:
+		e.ttl = ttl
+	case ttl == 0 || e.ttl == 0:
+		e.ttl = 0
+	case ttl > e.ttl:
+		e.ttl = ttl
+	}
+	e.rows++
+	return nil
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (e *messageTTLEncoder) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	msg, err := e.encoder.EncodeDDLEvent(event)
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	msg.TTL = e.ttlOf(event.TableInfo.TableName, common.MessageTTLOperationDDL)
+	return msg, nil
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *messageTTLEncoder) Build() ([]*common.Message, error) {
+	messages, err := e.encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, msg := range messages {
+		if msg.Type == model.MessageTypeRow {
+			msg.TTL = e.ttl
+		}
+	}
+	e.rows, e.ttl = 0, 0
+	return messages, nil
+}
+
+type messageTTLEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	rules   []common.MessageTTLRule
+}
+
+// Build implements the EncoderBuilder interface
+func (b *messageTTLEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &messageTTLEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, rules: b.rules}
+}
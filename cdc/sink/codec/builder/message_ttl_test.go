@@ -0,0 +1,103 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageTTLEncoder(t *testing.T) {
+	t.Parallel()
+
+	sinkURI, err := url.Parse("kafka://127.0.0.1:9092/abc?protocol=canal-json" +
+		"&message-ttl=test.cache:*:30s,test.*:delete:1h,test.*:ddl:10m,test.audit:*:0s,test.*:*:1m")
+	require.Nil(t, err)
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	require.Nil(t, codecConfig.Apply(sinkURI, config.GetDefaultReplicaConfig()))
+	require.Nil(t, codecConfig.Validate())
+	builder, err := NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.Nil(t, err)
+	encoder := builder.Build()
+
+	newRow := func(schema, table string, delete bool) *model.RowChangedEvent {
+		columns := []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+		}
+		e := &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: schema, Table: table},
+			Columns:  columns,
+		}
+		if delete {
+			e.PreColumns, e.Columns = columns, nil
+		}
+		return e
+	}
+	// ttl encodes the rows, and returns the TTL of the messages built.
+	ttl := func(rows ...*model.RowChangedEvent) time.Duration {
+		for _, row := range rows {
+			require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", row, nil))
+		}
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.NotEmpty(t, msgs)
+		for _, msg := range msgs[1:] {
+			require.Equal(t, msgs[0].TTL, msg.TTL)
+		}
+		return msgs[0].TTL
+	}
+
+	// the first rule matched applies.
+	require.Equal(t, 30*time.Second, ttl(newRow("test", "cache", false)))
+	require.Equal(t, 30*time.Second, ttl(newRow("test", "cache", true)))
+	require.Equal(t, time.Hour, ttl(newRow("test", "t", true)))
+	require.Equal(t, time.Minute, ttl(newRow("test", "t", false)))
+	require.Zero(t, ttl(newRow("test", "audit", false)))
+	// no TTL is hinted by default.
+	require.Zero(t, ttl(newRow("test1", "t", false)))
+
+	// the batch takes the longest TTL, or none if any row has none.
+	require.Equal(t, time.Minute, ttl(newRow("test", "cache", false), newRow("test", "t", false)))
+	require.Zero(t, ttl(newRow("test", "cache", false), newRow("test", "audit", false)))
+	require.Zero(t, ttl(newRow("test", "audit", false), newRow("test", "cache", false)))
+
+	// the DDL matches the ddl operation.
+	newDDL := func(schema, table string) *model.DDLEvent {
+		return &model.DDLEvent{
+			CommitTs:  417318403368288270,
+			Query:     "create table t(id int primary key)",
+			Type:      timodel.ActionCreateTable,
+			TableInfo: &model.TableInfo{TableName: model.TableName{Schema: schema, Table: table}},
+		}
+	}
+	msg, err := encoder.EncodeDDLEvent(newDDL("test", "t"))
+	require.Nil(t, err)
+	require.Equal(t, 10*time.Minute, msg.TTL)
+	msg, err = encoder.EncodeDDLEvent(newDDL("test", "cache"))
+	require.Nil(t, err)
+	require.Equal(t, 30*time.Second, msg.TTL)
+	msg, err = encoder.EncodeDDLEvent(newDDL("test1", "t"))
+	require.Nil(t, err)
+	require.Zero(t, msg.TTL)
+}
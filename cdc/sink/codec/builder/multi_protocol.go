@@ -0,0 +1,113 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// multiProtocolEncoder is embedded by the encoders encoding the events by the
+// encoder of the Protocol and the encoders of the other protocols. The
+// checkpoint and the heartbeat are encoded by all the encoders, the message
+// of the default encoder is returned, while the ones of the others are
+// returned by the next Build, so that the sink sends each of them to the
+// topic of its protocol.
+type multiProtocolEncoder struct {
+	defaultEncoder codec.EventBatchEncoder
+	// encoders holds the encoder of each protocol other than the Protocol,
+	// in the order of the protocols.
+	encoders []codec.EventBatchEncoder
+	// pending holds the messages encoded by the encoders since the last Build.
+	pending []*common.Message
+}
+
+func (e *multiProtocolEncoder) all() []codec.EventBatchEncoder {
+	return append([]codec.EventBatchEncoder{e.defaultEncoder}, e.encoders...)
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface
+func (e *multiProtocolEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	for _, encoder := range e.encoders {
+		msg, err := encoder.EncodeCheckpointEvent(ts)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if msg != nil {
+			e.pending = append(e.pending, msg)
+		}
+	}
+	return e.defaultEncoder.EncodeCheckpointEvent(ts)
+}
+
+// EncodeHeartbeat implements the HeartbeatEncoder interface
+func (e *multiProtocolEncoder) EncodeHeartbeat(ts uint64) (*common.Message, error) {
+	for _, encoder := range e.encoders {
+		heartbeat, ok := encoder.(codec.HeartbeatEncoder)
+		if !ok {
+			continue
+		}
+		msg, err := heartbeat.EncodeHeartbeat(ts)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if msg != nil {
+			e.pending = append(e.pending, msg)
+		}
+	}
+	if heartbeat, ok := e.defaultEncoder.(codec.HeartbeatEncoder); ok {
+		return heartbeat.EncodeHeartbeat(ts)
+	}
+	return nil, nil
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *multiProtocolEncoder) Build() ([]*common.Message, error) {
+	messages := e.pending
+	e.pending = nil
+	for _, encoder := range append([]codec.EventBatchEncoder{e.defaultEncoder}, e.encoders...) {
+		built, err := encoder.Build()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		messages = append(messages, built...)
+	}
+	return messages, nil
+}
+
+// ShouldFlush implements the FlushHintEncoder interface
+func (e *multiProtocolEncoder) ShouldFlush() bool {
+	for _, encoder := range e.all() {
+		if hint, ok := encoder.(codec.FlushHintEncoder); ok && hint.ShouldFlush() {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitDDL implements the DDLThrottledEncoder interface, it waits for the
+// throttling of each protocol, since the DDL may be encoded by any of them.
+func (e *multiProtocolEncoder) WaitDDL(ctx context.Context) error {
+	for _, encoder := range e.all() {
+		if throttled, ok := encoder.(codec.DDLThrottledEncoder); ok {
+			if err := throttled.WaitDDL(ctx); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// namespaceEncoder stamps the namespace of the changefeed onto each message
+// built by the encoder, which is produced in the header of it, so that the
+// consumers route the messages by the tenant without decoding them. The
+// heartbeat is stamped as well.
+type namespaceEncoder struct {
+	encoderWrapper
+	namespace string
+}
+
+func (e *namespaceEncoder) stamp(msg *common.Message, err error) (*common.Message, error) {
+	if msg != nil {
+		msg.Namespace = e.namespace
+	}
+	return msg, err
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface
+func (e *namespaceEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	return e.stamp(e.encoder.EncodeCheckpointEvent(ts))
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (e *namespaceEncoder) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	return e.stamp(e.encoder.EncodeDDLEvent(event))
+}
+
+// EncodeHeartbeat implements the HeartbeatEncoder interface
+func (e *namespaceEncoder) EncodeHeartbeat(ts uint64) (*common.Message, error) {
+	return e.stamp(e.encoderWrapper.EncodeHeartbeat(ts))
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *namespaceEncoder) Build() ([]*common.Message, error) {
+	messages, err := e.encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, msg := range messages {
+		msg.Namespace = e.namespace
+	}
+	return messages, nil
+}
+
+type namespaceEncoderBuilder struct {
+	builder   codec.EncoderBuilder
+	namespace string
+}
+
+// Build implements the EncoderBuilder interface
+func (b *namespaceEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &namespaceEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, namespace: b.namespace}
+}
+
+// newNamespaceEncoderBuilder wraps the builder, so that the messages built by
+// its encoders carry the namespace of the changefeed in the ctx.
+func newNamespaceEncoderBuilder(
+	ctx context.Context, builder codec.EncoderBuilder,
+) codec.EncoderBuilder {
+	return &namespaceEncoderBuilder{
+		builder:   builder,
+		namespace: contextutil.ChangefeedIDFromCtx(ctx).Namespace,
+	}
+}
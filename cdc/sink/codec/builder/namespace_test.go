@@ -0,0 +1,90 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceEncoder(t *testing.T) {
+	t.Parallel()
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+		},
+	}
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288270,
+		Query:    "create table test.t(id int primary key)",
+		Type:     timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "t"},
+		},
+	}
+
+	for _, protocol := range []config.Protocol{
+		config.ProtocolCanal, config.ProtocolCanalJSON, config.ProtocolOpen,
+	} {
+		for _, namespace := range []string{"tenant1", "tenant2"} {
+			ctx := contextutil.PutChangefeedIDInCtx(context.Background(),
+				model.ChangeFeedID{Namespace: namespace, ID: "test"})
+			codecConfig := common.NewConfig(protocol)
+			codecConfig.EnableNamespace = true
+			builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+			require.Nil(t, err)
+			encoder := builder.Build()
+
+			require.Nil(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+			msgs, err := encoder.Build()
+			require.NoError(t, err)
+			require.NotEmpty(t, msgs)
+			for _, msg := range msgs {
+				require.Equal(t, model.MessageTypeRow, msg.Type)
+				require.Equal(t, namespace, msg.Namespace)
+			}
+
+			msg, err := encoder.EncodeDDLEvent(ddl)
+			require.Nil(t, err)
+			require.Equal(t, model.MessageTypeDDL, msg.Type)
+			require.Equal(t, namespace, msg.Namespace)
+
+			msg, err = encoder.EncodeCheckpointEvent(417318403368288280)
+			require.Nil(t, err)
+			if msg != nil {
+				require.Equal(t, namespace, msg.Namespace)
+			}
+		}
+
+		// the namespace is not stamped by default.
+		ctx := contextutil.PutChangefeedIDInCtx(context.Background(),
+			model.ChangeFeedID{Namespace: "tenant1", ID: "test"})
+		builder, err := NewEventBatchEncoderBuilder(ctx, common.NewConfig(protocol))
+		require.Nil(t, err)
+		msg, err := builder.Build().EncodeDDLEvent(ddl)
+		require.Nil(t, err)
+		require.Empty(t, msg.Namespace)
+	}
+}
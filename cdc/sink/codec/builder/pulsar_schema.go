@@ -0,0 +1,111 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+)
+
+// pulsarSchemaEncoder attaches the Pulsar schema info of the canal-json
+// messages of the rows to them, see canal.JSONPulsarSchemaInfo, which is
+// produced in the header of them, so that the consumers bridging the messages
+// into Pulsar register the schema against the schema registry of Pulsar. The
+// schema info of a table is cached by the version of the table schema, and
+// the messages of the DDL, the checkpoint and the heartbeat carry none.
+type pulsarSchemaEncoder struct {
+	encoderWrapper
+	enableTiDBExtension bool
+	// schemas caches the schema info by table, pending holds the schema
+	// info of the tables of the rows appended since the last Build.
+	schemas map[model.TableName]pulsarSchema
+	pending map[model.TableName][]byte
+}
+
+type pulsarSchema struct {
+	version uint64
+	info    []byte
+}
+
+// schemaInfo returns the schema info of the table of the event.
+func (e *pulsarSchemaEncoder) schemaInfo(
+	table model.TableName, event *model.RowChangedEvent,
+) ([]byte, error) {
+	// the version is unknown if it's 0, so the schema info is not cached.
+	if schema, ok := e.schemas[table]; ok && event.TableInfoVersion != 0 &&
+		schema.version == event.TableInfoVersion {
+		return schema.info, nil
+	}
+	info, err := canal.JSONPulsarSchemaInfo(event, e.enableTiDBExtension)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	e.schemas[table] = pulsarSchema{version: event.TableInfoVersion, info: info}
+	return info, nil
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *pulsarSchemaEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	// the partitions of a table share the schema.
+	table := model.TableName{Schema: event.Table.Schema, Table: event.Table.Table}
+	info, err := e.schemaInfo(table, event)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := e.encoder.AppendRowChangedEvent(ctx, topic, event, callback); err != nil {
+		return err
+	}
+	e.pending[table] = info
+	return nil
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *pulsarSchemaEncoder) Build() ([]*common.Message, error) {
+	messages, err := e.encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, msg := range messages {
+		if msg.Type != model.MessageTypeRow || msg.Protocol != config.ProtocolCanalJSON ||
+			msg.Schema == nil || msg.Table == nil {
+			continue
+		}
+		msg.SchemaInfo = e.pending[model.TableName{Schema: *msg.Schema, Table: *msg.Table}]
+	}
+	e.pending = make(map[model.TableName][]byte)
+	return messages, nil
+}
+
+type pulsarSchemaEncoderBuilder struct {
+	builder             codec.EncoderBuilder
+	enableTiDBExtension bool
+}
+
+// Build implements the EncoderBuilder interface
+func (b *pulsarSchemaEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &pulsarSchemaEncoder{
+		encoderWrapper:      encoderWrapper{b.builder.Build()},
+		enableTiDBExtension: b.enableTiDBExtension,
+		schemas:             make(map[model.TableName]pulsarSchema),
+		pending:             make(map[model.TableName][]byte),
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPulsarSchemaEncoder(t *testing.T) {
+	t.Parallel()
+
+	row := func(table string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs:         417318403368288260,
+			TableInfoVersion: 1,
+			Table:            &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			},
+		}
+	}
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288270,
+		Query:    "create table test.t1(id int primary key)",
+		Type:     timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "t1"},
+		},
+	}
+
+	ctx := context.Background()
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.EnablePulsarSchema = true
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+	_, ok := encoder.(codec.FlushHintEncoder)
+	require.True(t, ok)
+
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row("t1"), nil))
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row("t2"), nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	for i, table := range []string{"t1", "t2"} {
+		expected, err := canal.JSONPulsarSchemaInfo(row(table), false)
+		require.NoError(t, err)
+		require.Equal(t, expected, msgs[i].SchemaInfo)
+	}
+
+	// the DDL carries no schema info.
+	msg, err := encoder.EncodeDDLEvent(ddl)
+	require.NoError(t, err)
+	require.Nil(t, msg.SchemaInfo)
+
+	// the schema info is not attached to the messages of other protocols.
+	codecConfig = common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.EnablePulsarSchema = true
+	codecConfig.TableProtocols = map[model.TableName]config.Protocol{
+		{Schema: "test", Table: "t2"}: config.ProtocolCanal,
+	}
+	builder, err = NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder = builder.Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row("t1"), nil))
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row("t2"), nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	for _, msg := range msgs {
+		if msg.Protocol == config.ProtocolCanalJSON {
+			require.NotNil(t, msg.SchemaInfo)
+		} else {
+			require.Nil(t, msg.SchemaInfo)
+		}
+	}
+}
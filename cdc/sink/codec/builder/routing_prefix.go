@@ -0,0 +1,83 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// routingPrefixEncoder prefixes the schema and the table routing each message
+// built by the encoder, so that the topics of the changefeeds sharing the
+// broker are namespaced. Only the routing metadata of the message is
+// prefixed, the payload still carries the original names.
+type routingPrefixEncoder struct {
+	encoderWrapper
+	prefix string
+}
+
+// stamp prefixes the routing identifiers of the message, which are replaced
+// rather than changed, since they may point to the names of the event.
+func (e *routingPrefixEncoder) stamp(msg *common.Message) {
+	if msg.Schema != nil {
+		schema := e.prefix + *msg.Schema
+		msg.Schema = &schema
+	}
+	if msg.Table != nil {
+		table := e.prefix + *msg.Table
+		msg.Table = &table
+	}
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface
+func (e *routingPrefixEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	msg, err := e.encoder.EncodeCheckpointEvent(ts)
+	if msg != nil {
+		e.stamp(msg)
+	}
+	return msg, err
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (e *routingPrefixEncoder) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	msg, err := e.encoder.EncodeDDLEvent(event)
+	if msg != nil {
+		e.stamp(msg)
+	}
+	return msg, err
+}
+
+// Build implements the EventBatchEncoder interface
+func (e *routingPrefixEncoder) Build() ([]*common.Message, error) {
+	messages, err := e.encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, msg := range messages {
+		e.stamp(msg)
+	}
+	return messages, nil
+}
+
+type routingPrefixEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	prefix  string
+}
+
+// Build implements the EncoderBuilder interface
+func (b *routingPrefixEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &routingPrefixEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, prefix: b.prefix}
+}
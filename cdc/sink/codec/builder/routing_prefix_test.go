@@ -0,0 +1,79 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutingPrefixEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.RoutingPrefix = "cf1_"
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+
+	var payload struct {
+		Database string `json:"database"`
+		Table    string `json:"table"`
+	}
+
+	// the routing identifiers carry the prefix, the payload does not.
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "users"},
+		Columns:  []*model.Column{{Name: "id", Type: mysql.TypeLong, Value: 1}},
+	}
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "", row, nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "cf1_test", *msgs[0].Schema)
+	require.Equal(t, "cf1_users", *msgs[0].Table)
+	require.NoError(t, json.Unmarshal(msgs[0].Value, &payload))
+	require.Equal(t, "test", payload.Database)
+	require.Equal(t, "users", payload.Table)
+	require.Equal(t, "test", row.Table.Schema)
+
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288270,
+		Query:    "create table test.users(id int primary key)",
+		Type:     timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "users"},
+		},
+	}
+	msg, err := encoder.EncodeDDLEvent(ddl)
+	require.NoError(t, err)
+	require.Equal(t, "cf1_test", *msg.Schema)
+	require.Equal(t, "cf1_users", *msg.Table)
+	require.NoError(t, json.Unmarshal(msg.Value, &payload))
+	require.Equal(t, "test", payload.Database)
+	require.Equal(t, "users", payload.Table)
+	// the names of the event are not changed.
+	require.Equal(t, "test", ddl.TableInfo.TableName.Schema)
+	require.Equal(t, "users", ddl.TableInfo.TableName.Table)
+}
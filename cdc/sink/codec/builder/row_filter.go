@@ -0,0 +1,204 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// rowFilterRule keeps only the row events of the tables matched whose column
+// has one of the values, i.e. `column = value` or `column IN (values...)`.
+// The new image of the row is evaluated, except for the delete, whose old
+// image is evaluated. The column name is case-insensitive, the value of the
+// column is compared as the string, and the null value only matches the NULL
+// in the values. The row without the column is not kept.
+type rowFilterRule struct {
+	common.TableMatcher
+	column string
+	values []string
+	// matchNull is whether the NULL is in the values.
+	matchNull bool
+}
+
+// keep returns whether the row event is kept by the rule.
+func (r *rowFilterRule) keep(event *model.RowChangedEvent) bool {
+	columns := event.Columns
+	if event.IsDelete() {
+		columns = event.PreColumns
+	}
+	for _, c := range columns {
+		if c == nil || !strings.EqualFold(c.Name, r.column) {
+			continue
+		}
+		if c.Value == nil {
+			return r.matchNull
+		}
+		value := model.ColumnValueString(c.Value)
+		for _, v := range r.values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// parseRowFilters parses the rules of the row filter in the form of
+// `schema.table:expression` separated by semicolon, where the expression is
+// either `column = value` or `column IN (value, ...)`, e.g.
+// `test.users:status = 'active';test.*:region IN ('us', 'eu', NULL)`. The
+// tables are matched by the pattern of the table filter. The value is either
+// a string quoted by single quotes, in which a single quote is escaped by
+// doubling it, or a bare literal, e.g. a number, and the bare NULL matches
+// the null value. The keyword IN is case-insensitive.
+func parseRowFilters(s string) ([]rowFilterRule, error) {
+	var result []rowFilterRule
+	for _, item := range splitOutsideQuotes(s, ';') {
+		colon := strings.IndexByte(item, ':')
+		if colon < 0 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid row-filter %s`, item)
+		}
+		matcher, err := common.NewTableMatcher(item[:colon])
+		if err != nil {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid row-filter %s`, item)
+		}
+		rule, err := parseRowFilterExpr(item[colon+1:])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		rule.TableMatcher = matcher
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// parseRowFilterExpr parses the expression of the row filter rule.
+func parseRowFilterExpr(expr string) (rowFilterRule, error) {
+	invalid := cerror.ErrCodecInvalidConfig.GenWithStack(
+		`invalid expression %s in row-filter`, expr)
+	rest := strings.TrimSpace(expr)
+	end := strings.IndexAny(rest, " \t=(")
+	if end <= 0 {
+		return rowFilterRule{}, invalid
+	}
+	rule := rowFilterRule{column: rest[:end]}
+	rest = strings.TrimSpace(rest[end:])
+
+	var values []string
+	switch {
+	case strings.HasPrefix(rest, "="):
+		values = []string{strings.TrimSpace(rest[1:])}
+	case len(rest) > 2 && strings.EqualFold(rest[:2], "IN"):
+		list := strings.TrimSpace(rest[2:])
+		if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+			return rowFilterRule{}, invalid
+		}
+		values = splitOutsideQuotes(list[1:len(list)-1], ',')
+	default:
+		return rowFilterRule{}, invalid
+	}
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		switch {
+		case strings.EqualFold(v, "NULL"):
+			rule.matchNull = true
+		case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+			unquoted := v[1 : len(v)-1]
+			if strings.Count(unquoted, "'")%2 != 0 {
+				return rowFilterRule{}, invalid
+			}
+			rule.values = append(rule.values, strings.ReplaceAll(unquoted, "''", "'"))
+		case v != "" && !strings.ContainsAny(v, "'() \t"):
+			rule.values = append(rule.values, v)
+		default:
+			return rowFilterRule{}, invalid
+		}
+	}
+	return rule, nil
+}
+
+// splitOutsideQuotes splits the s by the sep which is not quoted by the
+// single quotes.
+func splitOutsideQuotes(s string, sep byte) []string {
+	var (
+		result []string
+		quoted bool
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				result = append(result, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(result, s[start:])
+}
+
+// rowFilterEncoder drops the row events not kept by the rules before they're
+// encoded, so that the consumer interested in a subset of the rows saves the
+// bandwidth. The callback of the row dropped is called at once, since nothing
+// is sent for it. The DDL and the checkpoint are not filtered.
+type rowFilterEncoder struct {
+	encoderWrapper
+	rules []rowFilterRule
+}
+
+// keep returns whether the row event is kept by the first rule matching its
+// table, the rows of the tables matched by none are kept.
+func (e *rowFilterEncoder) keep(event *model.RowChangedEvent) bool {
+	for i := range e.rules {
+		if e.rules[i].MatchTable(*event.Table) {
+			return e.rules[i].keep(event)
+		}
+	}
+	return true
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *rowFilterEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	if !e.keep(event) {
+		if callback != nil {
+			callback()
+		}
+		return nil
+	}
+	return e.encoder.AppendRowChangedEvent(ctx, topic, event, callback)
+}
+
+type rowFilterEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	rules   []rowFilterRule
+}
+
+// Build implements the EncoderBuilder interface
+func (b *rowFilterEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &rowFilterEncoder{encoderWrapper: encoderWrapper{b.builder.Build()}, rules: b.rules}
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowFilterEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	users := &model.TableName{Schema: "test", Table: "users"}
+	columns := func(id int64, status interface{}) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: id},
+			{Name: "status", Type: mysql.TypeVarchar, Value: status},
+		}
+	}
+	insert := func(id int64, status interface{}) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260, Table: users, Columns: columns(id, status),
+		}
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.RowFilter = "test.users:STATUS IN ('active', vip);test.*:id = 1"
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.Nil(t, err)
+	encoder := builder.Build()
+
+	for _, tc := range []struct {
+		name  string
+		event *model.RowChangedEvent
+		kept  bool
+	}{
+		{"insert matching", insert(1, []byte("active")), true},
+		{"insert matching the IN list", insert(2, "vip"), true},
+		{"insert not matching", insert(3, []byte("inactive")), false},
+		{"insert of null", insert(4, nil), false},
+		{
+			// the new image is evaluated for the update.
+			"update into matching",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260, Table: users,
+				PreColumns: columns(5, "inactive"), Columns: columns(5, "active"),
+			},
+			true,
+		},
+		{
+			"update out of matching",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260, Table: users,
+				PreColumns: columns(6, "active"), Columns: columns(6, "inactive"),
+			},
+			false,
+		},
+		{
+			// the old image is evaluated for the delete.
+			"delete matching",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260, Table: users, PreColumns: columns(7, "active"),
+			},
+			true,
+		},
+		{
+			"delete not matching",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260, Table: users, PreColumns: columns(8, "inactive"),
+			},
+			false,
+		},
+		{
+			"row without the column",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260, Table: users, Columns: columns(9, "active")[:1],
+			},
+			false,
+		},
+		{
+			// the first rule matching the table applies.
+			"other table matching",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260,
+				Table:    &model.TableName{Schema: "test", Table: "orders"},
+				Columns:  columns(1, "inactive"),
+			},
+			true,
+		},
+		{
+			"other table not matching",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260,
+				Table:    &model.TableName{Schema: "test", Table: "orders"},
+				Columns:  columns(2, "active"),
+			},
+			false,
+		},
+		{
+			"table without rules",
+			&model.RowChangedEvent{
+				CommitTs: 417318403368288260,
+				Table:    &model.TableName{Schema: "other", Table: "users"},
+				Columns:  columns(3, "inactive"),
+			},
+			true,
+		},
+	} {
+		called := false
+		err := encoder.AppendRowChangedEvent(ctx, "", tc.event, func() { called = true })
+		require.Nil(t, err, tc.name)
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		if tc.kept {
+			require.Len(t, msgs, 1, tc.name)
+			require.False(t, called, tc.name)
+			msgs[0].Callback()
+		} else {
+			require.Empty(t, msgs, tc.name)
+		}
+		// the callback of the row dropped is called at once.
+		require.True(t, called, tc.name)
+	}
+
+	// the DDL is not filtered.
+	msg, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs: 417318403368288270,
+		Query:    "create table test.users(id int primary key)",
+		Type:     timodel.ActionCreateTable,
+		TableInfo: &model.TableInfo{
+			TableName: *users,
+		},
+	})
+	require.Nil(t, err)
+	require.NotNil(t, msg)
+
+	codecConfig.RowFilter = "test.t:a LIKE 'b'"
+	_, err = NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.ErrorContains(t, err, "invalid expression a LIKE 'b' in row-filter")
+}
+
+func TestParseRowFilters(t *testing.T) {
+	t.Parallel()
+
+	rules, err := parseRowFilters(
+		"test.users:status = 'active';Test.*:region IN ('us', 'it''s;,', 42, null)")
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, "test.users", rules[0].Pattern)
+	require.Equal(t, "status", rules[0].column)
+	require.Equal(t, []string{"active"}, rules[0].values)
+	require.False(t, rules[0].matchNull)
+	require.Equal(t, "Test.*", rules[1].Pattern)
+	require.Equal(t, "region", rules[1].column)
+	require.Equal(t, []string{"us", "it's;,", "42"}, rules[1].values)
+	require.True(t, rules[1].matchNull)
+
+	// the tables are matched by the table filter regardless of the case.
+	require.True(t, rules[1].MatchTable(model.TableName{Schema: "test", Table: "t"}))
+	require.False(t, rules[1].MatchTable(model.TableName{Schema: "test1", Table: "t"}))
+
+	for _, s := range []string{"test.t", "t:a = 1", "test.:a = 1", "!test.t:a = 1"} {
+		_, err = parseRowFilters(s)
+		require.ErrorContains(t, err, "invalid row-filter "+s)
+	}
+	for _, s := range []string{
+		"a", "= 1", "a = ", "a = 'b", "a = b c", "a IN 1", "a IN ('b',)", "a LIKE 'b'",
+	} {
+		_, err = parseRowFilters("test.t:" + s)
+		require.ErrorContains(t, err, "invalid expression "+s+" in row-filter")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// rowSamplingEncoder drops the row events not sampled before they're encoded,
+// so that the consumer monitoring the high-volume tables receives a fraction
+// of the rows. The callback of the row dropped is called at once, since
+// nothing is sent for it. The DDL and the checkpoint are not sampled.
+type rowSamplingEncoder struct {
+	encoderWrapper
+	rules []common.RowSamplingRule
+	rand  *rand.Rand
+}
+
+// sampled returns whether the row event is sampled by the first rule matching
+// its table, the rows of the tables matched by none are all sampled.
+func (e *rowSamplingEncoder) sampled(event *model.RowChangedEvent) bool {
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.MatchTable(*event.Table) {
+			continue
+		}
+		if rule.KeepDeletes && event.IsDelete() {
+			return true
+		}
+		if rule.ByKey {
+			if h, ok := rowKeyHash(event); ok {
+				// the 53 bits of the hash are mapped into [0, 1) as a float64.
+				return float64(h>>11)/(1<<53) < rule.Rate
+			}
+		}
+		return e.rand.Float64() < rule.Rate
+	}
+	return true
+}
+
+// rowKeyHash returns the hash of the table and the handle key of the row, the
+// new image is hashed, except for the delete, whose old image is hashed. It
+// returns false if the row has no handle key.
+func rowKeyHash(event *model.RowChangedEvent) (uint64, bool) {
+	columns := event.Columns
+	if event.IsDelete() {
+		columns = event.PreColumns
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(event.Table.Schema))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(event.Table.Table))
+	found := false
+	for _, c := range columns {
+		if c == nil || !c.Flag.IsHandleKey() {
+			continue
+		}
+		found = true
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(c.Name))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(model.ColumnValueString(c.Value)))
+	}
+	return mix64(h.Sum64()), found
+}
+
+// mix64 is the finalizer of the MurmurHash3, the high bits of the FNV hash of
+// the keys differing in the last bytes only are not well distributed.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *rowSamplingEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	if !e.sampled(event) {
+		if callback != nil {
+			callback()
+		}
+		return nil
+	}
+	return e.encoder.AppendRowChangedEvent(ctx, topic, event, callback)
+}
+
+type rowSamplingEncoderBuilder struct {
+	builder codec.EncoderBuilder
+	rules   []common.RowSamplingRule
+}
+
+// Build implements the EncoderBuilder interface
+func (b *rowSamplingEncoderBuilder) Build() codec.EventBatchEncoder {
+	return &rowSamplingEncoder{
+		encoderWrapper: encoderWrapper{b.builder.Build()},
+		rules:          b.rules,
+		// the encoders are used by a single goroutine each.
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowSamplingEncoder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	logs := &model.TableName{Schema: "test", Table: "logs"}
+	metrics := &model.TableName{Schema: "test", Table: "metrics"}
+	users := &model.TableName{Schema: "other", Table: "users"}
+	columns := func(id int64) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: id},
+			{Name: "msg", Type: mysql.TypeVarchar, Value: []byte("hello")},
+		}
+	}
+	insert := func(table *model.TableName, id int64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{CommitTs: 417318403368288260, Table: table, Columns: columns(id)}
+	}
+	update := func(table *model.TableName, id int64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260, Table: table,
+			PreColumns: columns(id), Columns: columns(id),
+		}
+	}
+	del := func(table *model.TableName, id int64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{CommitTs: 417318403368288260, Table: table, PreColumns: columns(id)}
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+	codecConfig.RowSamplings = []common.RowSamplingRule{
+		{TableMatcher: common.MustNewTableMatcher("test.logs"), Rate: 0.1, KeepDeletes: true},
+		{TableMatcher: common.MustNewTableMatcher("test.*"), Rate: 0.25, ByKey: true},
+	}
+	builder, err := NewEventBatchEncoderBuilder(ctx, codecConfig)
+	require.Nil(t, err)
+	encoder := builder.Build()
+
+	// count returns how many of the events are sampled, the callback of each
+	// event is called either at once or by the message.
+	count := func(events []*model.RowChangedEvent) int {
+		called := 0
+		for _, e := range events {
+			err := encoder.AppendRowChangedEvent(ctx, "", e, func() { called++ })
+			require.Nil(t, err)
+		}
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		dropped := called
+		for _, msg := range msgs {
+			msg.Callback()
+		}
+		require.Equal(t, len(events), called)
+		return len(events) - dropped
+	}
+
+	const total = 20000
+	var events []*model.RowChangedEvent
+	for i := 0; i < total; i++ {
+		events = append(events, insert(logs, int64(i)))
+	}
+	// sampled at random at the rate.
+	require.InDelta(t, total/10, count(events), total/100)
+
+	// the deletes are all kept.
+	events = events[:0]
+	for i := 0; i < 100; i++ {
+		events = append(events, del(logs, int64(i)))
+	}
+	require.Equal(t, 100, count(events))
+
+	// sampled by the key at the rate, the changes of the same row are either
+	// all kept or all dropped.
+	events = events[:0]
+	for i := 0; i < total; i++ {
+		events = append(events, insert(metrics, int64(i)))
+	}
+	require.InDelta(t, total/4, count(events), total/100)
+	for i := int64(0); i < 100; i++ {
+		kept := count([]*model.RowChangedEvent{insert(metrics, i)})
+		require.Equal(t, kept, count([]*model.RowChangedEvent{update(metrics, i)}))
+		require.Equal(t, kept, count([]*model.RowChangedEvent{del(metrics, i)}))
+		// consistent across the encoders.
+		other := builder.Build()
+		require.Nil(t, other.AppendRowChangedEvent(ctx, "", insert(metrics, i), nil))
+		msgs, err := other.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, kept)
+	}
+
+	// the rows of the tables matched by none are all kept.
+	events = events[:0]
+	for i := 0; i < 100; i++ {
+		events = append(events, insert(users, int64(i)))
+	}
+	require.Equal(t, 100, count(events))
+}
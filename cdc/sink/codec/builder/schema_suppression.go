@@ -0,0 +1,89 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"strings"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// EventSuppressor suppresses the row events and the DDL events of the
+// schemas, e.g. the system schemas of TiDB, which confuse the consumers, and
+// the row events of the temporary tables, whose data is ephemeral, as well as
+// the ones of the cached tables if configured. The kind of the table is known
+// by the table info of the event, so the events without it are not
+// suppressed by the kind.
+//
+// The sinks drop the events suppressed before encoding them, so the encoders
+// built are not wrapped for it. A nil EventSuppressor suppresses nothing.
+type EventSuppressor struct {
+	// schemas are the schemas suppressed in lower case.
+	schemas map[string]struct{}
+	// temporary and cached suppress the row events of the temporary tables
+	// and the cached tables respectively.
+	temporary bool
+	cached    bool
+}
+
+// NewEventSuppressor returns the EventSuppressor of the SuppressedSchemas,
+// which suppresses the temporary tables as well unless IncludeTemporaryTables
+// is set, and the cached tables if SuppressCachedTables is set. Nil is
+// returned if nothing is suppressed.
+func NewEventSuppressor(c *common.Config) *EventSuppressor {
+	if len(c.SuppressedSchemas) == 0 && c.IncludeTemporaryTables && !c.SuppressCachedTables {
+		return nil
+	}
+	result := &EventSuppressor{
+		schemas:   make(map[string]struct{}, len(c.SuppressedSchemas)),
+		temporary: !c.IncludeTemporaryTables,
+		cached:    c.SuppressCachedTables,
+	}
+	for _, schema := range c.SuppressedSchemas {
+		result.schemas[strings.ToLower(schema)] = struct{}{}
+	}
+	return result
+}
+
+// SuppressRow returns whether the row event is suppressed, by the schema of
+// it or the kind of the table.
+func (s *EventSuppressor) SuppressRow(event *model.RowChangedEvent) bool {
+	if s == nil {
+		return false
+	}
+	if s.suppressedSchema(event.Table.Schema) {
+		return true
+	}
+	info := event.TableInfo
+	if info == nil || info.TableInfo == nil {
+		return false
+	}
+	if s.temporary && info.TempTableType != timodel.TempTableNone {
+		return true
+	}
+	return s.cached && info.TableCacheStatusType != timodel.TableCacheStatusDisable
+}
+
+// SuppressDDL returns whether the DDL event is suppressed by the schema of it.
+func (s *EventSuppressor) SuppressDDL(event *model.DDLEvent) bool {
+	return s != nil && event.TableInfo != nil && s.suppressedSchema(event.TableInfo.TableName.Schema)
+}
+
+// suppressedSchema returns whether the events of the schema are suppressed.
+func (s *EventSuppressor) suppressedSchema(schema string) bool {
+	_, ok := s.schemas[strings.ToLower(schema)]
+	return ok
+}
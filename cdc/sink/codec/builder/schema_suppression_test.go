@@ -0,0 +1,144 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSuppressorSchemas(t *testing.T) {
+	t.Parallel()
+
+	row := func(schema string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: schema, Table: "t"},
+		}
+	}
+	ddl := func(schema string) *model.DDLEvent {
+		return &model.DDLEvent{
+			CommitTs: 417318403368288270,
+			Query:    "create table t(id int primary key)",
+			Type:     timodel.ActionCreateTable,
+			TableInfo: &model.TableInfo{
+				TableName: model.TableName{Schema: schema, Table: "t"},
+			},
+		}
+	}
+
+	// the other protocols suppress nothing by default.
+	require.Nil(t, NewEventSuppressor(common.NewConfig(config.ProtocolCanalJSON)))
+
+	// the system schemas are suppressed by default for the canal protocol.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	suppressor := NewEventSuppressor(codecConfig)
+	for _, tc := range []struct {
+		schema     string
+		suppressed bool
+	}{
+		{"mysql", true},
+		{"INFORMATION_SCHEMA", true},
+		{"test", false},
+	} {
+		require.Equal(t, tc.suppressed, suppressor.SuppressRow(row(tc.schema)), tc.schema)
+		require.Equal(t, tc.suppressed, suppressor.SuppressDDL(ddl(tc.schema)), tc.schema)
+	}
+	// the DDL without the table info is not suppressed.
+	require.False(t, suppressor.SuppressDDL(&model.DDLEvent{Query: "create database mysql"}))
+
+	// the mysql schema is emitted once removed from the schemas suppressed.
+	codecConfig.SuppressedSchemas = []string{"sys"}
+	suppressor = NewEventSuppressor(codecConfig)
+	require.False(t, suppressor.SuppressRow(row("mysql")))
+	require.False(t, suppressor.SuppressDDL(ddl("mysql")))
+	require.True(t, suppressor.SuppressRow(row("sys")))
+
+	// nothing is suppressed, and the nil suppressor suppresses nothing.
+	codecConfig.SuppressedSchemas = nil
+	codecConfig.IncludeTemporaryTables = true
+	suppressor = NewEventSuppressor(codecConfig)
+	require.Nil(t, suppressor)
+	require.False(t, suppressor.SuppressRow(row("sys")))
+	require.False(t, suppressor.SuppressDDL(ddl("sys")))
+}
+
+func TestEventSuppressorEphemeralTables(t *testing.T) {
+	t.Parallel()
+
+	newTable := func(configure func(info *timodel.TableInfo)) *model.TableInfo {
+		info := &timodel.TableInfo{ID: 1, Name: timodel.NewCIStr("t")}
+		configure(info)
+		return model.WrapTableInfo(1, "test", 1, info)
+	}
+	temporary := newTable(func(info *timodel.TableInfo) { info.TempTableType = timodel.TempTableGlobal })
+	cached := newTable(func(info *timodel.TableInfo) { info.TableCacheStatusType = timodel.TableCacheStatusEnable })
+	normal := newTable(func(*timodel.TableInfo) {})
+
+	// suppressed returns whether the row event and the DDL event of the
+	// table are suppressed.
+	suppressed := func(codecConfig *common.Config, tableInfo *model.TableInfo) (bool, bool) {
+		suppressor := NewEventSuppressor(codecConfig)
+		row := suppressor.SuppressRow(&model.RowChangedEvent{
+			CommitTs:  417318403368288260,
+			Table:     &model.TableName{Schema: "test", Table: "t"},
+			TableInfo: tableInfo,
+		})
+		ddl := suppressor.SuppressDDL(&model.DDLEvent{
+			CommitTs:  417318403368288270,
+			Query:     "create table t(id int primary key)",
+			Type:      timodel.ActionCreateTable,
+			TableInfo: tableInfo,
+		})
+		return row, ddl
+	}
+
+	// the rows of the temporary tables are suppressed by default, while the
+	// DDLs of them and the events of the cached tables are emitted.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	for _, tc := range []struct {
+		name      string
+		tableInfo *model.TableInfo
+		row       bool
+	}{
+		{"temporary", temporary, true},
+		{"cached", cached, false},
+		{"normal", normal, false},
+	} {
+		row, ddl := suppressed(codecConfig, tc.tableInfo)
+		require.Equal(t, tc.row, row, tc.name)
+		require.False(t, ddl, tc.name)
+	}
+
+	// the rows of the temporary tables are included if overridden, even if no
+	// schema is suppressed.
+	codecConfig.SuppressedSchemas = nil
+	codecConfig.IncludeTemporaryTables = true
+	row, ddl := suppressed(codecConfig, temporary)
+	require.False(t, row)
+	require.False(t, ddl)
+
+	// the rows of the cached tables are suppressed if configured.
+	codecConfig.SuppressCachedTables = true
+	row, ddl = suppressed(codecConfig, cached)
+	require.True(t, row)
+	require.False(t, ddl)
+	row, _ = suppressed(codecConfig, normal)
+	require.False(t, row)
+}
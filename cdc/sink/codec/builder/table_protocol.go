@@ -0,0 +1,130 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+)
+
+// tableProtocolEncoder routes the events of each table to the encoder of the
+// protocol overridden for the table, and the other events to the default
+// encoder. Each message built carries the protocol of the encoder building it,
+// so that the sink sends it to the topic of the protocol, see ProtocolTopics.
+// The checkpoint and the heartbeat are encoded by all the encoders, see
+// multiProtocolEncoder.
+type tableProtocolEncoder struct {
+	multiProtocolEncoder
+	// tableEncoders maps each table overridden to one of the encoders.
+	tableEncoders map[model.TableName]codec.EventBatchEncoder
+}
+
+func (e *tableProtocolEncoder) encoderOf(table model.TableName) codec.EventBatchEncoder {
+	if encoder, ok := e.tableEncoders[table]; ok {
+		return encoder
+	}
+	return e.defaultEncoder
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (e *tableProtocolEncoder) AppendRowChangedEvent(
+	ctx context.Context, topic string, event *model.RowChangedEvent, callback func(),
+) error {
+	return e.encoderOf(*event.Table).AppendRowChangedEvent(ctx, topic, event, callback)
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (e *tableProtocolEncoder) EncodeDDLEvent(event *model.DDLEvent) (*common.Message, error) {
+	return e.encoderOf(event.TableInfo.TableName).EncodeDDLEvent(event)
+}
+
+type tableProtocolEncoderBuilder struct {
+	defaultBuilder codec.EncoderBuilder
+	// builders holds the builder of each protocol overridden, in the order of
+	// the protocols, tables maps each table to the index of its builder.
+	builders []codec.EncoderBuilder
+	tables   map[model.TableName]int
+}
+
+// Build implements the EncoderBuilder interface
+func (b *tableProtocolEncoderBuilder) Build() codec.EventBatchEncoder {
+	encoder := &tableProtocolEncoder{
+		multiProtocolEncoder: multiProtocolEncoder{
+			defaultEncoder: b.defaultBuilder.Build(),
+			encoders:       make([]codec.EventBatchEncoder, 0, len(b.builders)),
+		},
+		tableEncoders: make(map[model.TableName]codec.EventBatchEncoder, len(b.tables)),
+	}
+	for _, builder := range b.builders {
+		encoder.encoders = append(encoder.encoders, builder.Build())
+	}
+	for table, i := range b.tables {
+		encoder.tableEncoders[table] = encoder.encoders[i]
+	}
+	return encoder
+}
+
+// newTableProtocolEncoderBuilder creates the builder of the default protocol
+// and each protocol overridden by the TableProtocols. The codec config of the
+// protocols overridden is the same as the default one, except the protocol.
+func newTableProtocolEncoderBuilder(
+	ctx context.Context, c *common.Config,
+) (codec.EncoderBuilder, error) {
+	defaultConfig := *c
+	defaultConfig.TableProtocols = nil
+	defaultBuilder, err := NewEventBatchEncoderBuilder(ctx, &defaultConfig)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	result := &tableProtocolEncoderBuilder{
+		defaultBuilder: defaultBuilder,
+		tables:         make(map[model.TableName]int, len(c.TableProtocols)),
+	}
+	// the protocols are sorted, so that the messages are built in a stable order.
+	// the tables of the Protocol are left to the default encoder.
+	protocolBuilders := make(map[config.Protocol]int)
+	for _, protocol := range c.TableProtocols {
+		if protocol != c.Protocol {
+			protocolBuilders[protocol] = 0
+		}
+	}
+	protocols := make([]config.Protocol, 0, len(protocolBuilders))
+	for protocol := range protocolBuilders {
+		protocols = append(protocols, protocol)
+	}
+	sort.Slice(protocols, func(i, j int) bool { return protocols[i] < protocols[j] })
+	for i, protocol := range protocols {
+		protocolConfig := defaultConfig
+		protocolConfig.Protocol = protocol
+		builder, err := NewEventBatchEncoderBuilder(ctx, &protocolConfig)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		result.builders = append(result.builders, builder)
+		protocolBuilders[protocol] = i
+	}
+	for table, protocol := range c.TableProtocols {
+		if i, ok := protocolBuilders[protocol]; ok {
+			result.tables[table] = i
+		}
+	}
+	return result, nil
+}
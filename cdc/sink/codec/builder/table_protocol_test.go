@@ -0,0 +1,143 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableProtocolEncoder(t *testing.T) {
+	t.Parallel()
+
+	sinkURI, err := url.Parse("kafka://127.0.0.1:9092/abc?protocol=canal&table-protocols=test.t2:canal-json&protocol-topics=canal-json:abc-json")
+	require.Nil(t, err)
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	require.Nil(t, codecConfig.Apply(sinkURI, config.GetDefaultReplicaConfig()))
+	require.Nil(t, codecConfig.Validate())
+	// the checkpoint is encoded by the canal-json with the extension only.
+	codecConfig.EnableTiDBExtension = true
+
+	builder, err := NewEventBatchEncoderBuilder(context.Background(), codecConfig)
+	require.Nil(t, err)
+	encoder := builder.Build()
+
+	newRow := func(table string, id int64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: id},
+			},
+		}
+	}
+	newDDL := func(table string) *model.DDLEvent {
+		return &model.DDLEvent{
+			CommitTs:  417318403368288260,
+			Query:     "create table test." + table + "(id int primary key)",
+			Type:      timodel.ActionCreateTable,
+			TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: table}},
+		}
+	}
+
+	// the DDL of each table is encoded by its protocol.
+	msg, err := encoder.EncodeDDLEvent(newDDL("t1"))
+	require.Nil(t, err)
+	require.Equal(t, config.ProtocolCanal, msg.Protocol)
+	msg, err = encoder.EncodeDDLEvent(newDDL("t2"))
+	require.Nil(t, err)
+	require.Equal(t, config.ProtocolCanalJSON, msg.Protocol)
+
+	// the rows of each table are encoded by its protocol.
+	called := 0
+	for _, row := range []*model.RowChangedEvent{
+		newRow("t1", 1), newRow("t2", 1), newRow("t1", 2), newRow("t2", 2),
+	} {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, func() { called++ })
+		require.Nil(t, err)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+
+	// the rows of t1 are in a canal packet.
+	require.Equal(t, config.ProtocolCanal, msgs[0].Protocol)
+	require.Equal(t, 2, msgs[0].GetRowsCount())
+	decoder, err := canal.NewPacketDecoder(msgs[0].Value)
+	require.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		tp, ok, err := decoder.HasNext()
+		require.Nil(t, err)
+		require.True(t, ok)
+		require.Equal(t, model.MessageTypeRow, tp)
+		row, err := decoder.NextRowChangedEvent()
+		require.Nil(t, err)
+		require.Equal(t, "t1", row.Table.Table)
+	}
+	_, ok, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// each row of t2 is a canal-json message.
+	for _, msg := range msgs[1:] {
+		require.Equal(t, config.ProtocolCanalJSON, msg.Protocol)
+		decoder := canal.NewBatchDecoder(msg.Value, false)
+		tp, ok, err := decoder.HasNext()
+		require.Nil(t, err)
+		require.True(t, ok)
+		require.Equal(t, model.MessageTypeRow, tp)
+		row, err := decoder.NextRowChangedEvent()
+		require.Nil(t, err)
+		require.Equal(t, "t2", row.Table.Table)
+	}
+
+	for _, msg := range msgs {
+		msg.Callback()
+	}
+	require.Equal(t, 4, called)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// the checkpoint is encoded by each protocol, the ones of the protocols
+	// overridden are returned by Build.
+	msg, err = encoder.EncodeCheckpointEvent(417318403368288260)
+	require.Nil(t, err)
+	require.Nil(t, msg)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, config.ProtocolCanalJSON, msgs[0].Protocol)
+	require.Equal(t, model.MessageTypeResolved, msgs[0].Type)
+
+	// so is the heartbeat.
+	heartbeat, ok := encoder.(codec.HeartbeatEncoder)
+	require.True(t, ok)
+	msg, err = heartbeat.EncodeHeartbeat(417318403368288260)
+	require.Nil(t, err)
+	require.Equal(t, config.ProtocolCanal, msg.Protocol)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+}
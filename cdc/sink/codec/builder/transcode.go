@@ -0,0 +1,176 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// jsonLineSeparator separates the canal-json messages in the transcoded data.
+var jsonLineSeparator = []byte("\n")
+
+// transcodable lists the protocols which can be transcoded from and to each
+// other. They share the canal event model, i.e. the rows carry the mysql type
+// and the java sql type of each column, so the events are kept the same after
+// the transcoding, except for the fields the source protocol does not carry,
+// e.g. the commit ts is lost if the source is canal.
+var transcodable = map[config.Protocol]struct{}{
+	config.ProtocolCanal:     {},
+	config.ProtocolCanalJSON: {},
+}
+
+// Transcode decodes the data encoded by the protocol from, and re-encodes
+// the events by the protocol to, with the default codec config. Any pair of
+// the protocols in transcodable is supported, and the data of each protocol is:
+//   - canal: a canal packet, i.e. the value of a message, so the events
+//     transcoded to canal must fit into one packet, e.g. the rows, or a DDL.
+//   - canal-json: the canal-json messages separated by newlines.
+func Transcode(in []byte, from, to config.Protocol) ([]byte, error) {
+	_, fromOK := transcodable[from]
+	_, toOK := transcodable[to]
+	if !fromOK || !toOK {
+		return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+			"transcoding from protocol %d to %d is not supported", int(from), int(to))
+	}
+
+	ctx := context.Background()
+	builder, err := NewEventBatchEncoderBuilder(ctx, common.NewConfig(to))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	encoder := builder.Build()
+
+	decoders, err := newTranscodeDecoders(in, from)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var messages []*common.Message
+	for _, decoder := range decoders {
+		messages, err = transcodeEvents(ctx, decoder, encoder, messages)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	built, err := encoder.Build()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	messages = append(messages, built...)
+
+	if to == config.ProtocolCanal {
+		switch len(messages) {
+		case 0:
+			return nil, nil
+		case 1:
+			return messages[0].Value, nil
+		default:
+			return nil, cerror.ErrEncodeFailed.GenWithStackByArgs(
+				"the events can not be carried by one canal packet")
+		}
+	}
+	values := make([][]byte, 0, len(messages))
+	for _, msg := range messages {
+		values = append(values, msg.Value)
+	}
+	return bytes.Join(values, jsonLineSeparator), nil
+}
+
+// newTranscodeDecoders returns the decoders of the data encoded by the protocol.
+func newTranscodeDecoders(in []byte, protocol config.Protocol) ([]codec.EventBatchDecoder, error) {
+	if protocol == config.ProtocolCanal {
+		decoder, err := canal.NewPacketDecoder(in)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return []codec.EventBatchDecoder{decoder}, nil
+	}
+
+	var decoders []codec.EventBatchDecoder
+	for _, line := range bytes.Split(in, jsonLineSeparator) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		decoders = append(decoders, canal.NewBatchDecoder(line, true))
+	}
+	return decoders, nil
+}
+
+// transcodeEvents re-encodes the events of the decoder, and appends the
+// messages to the result in the order of the events.
+func transcodeEvents(
+	ctx context.Context,
+	decoder codec.EventBatchDecoder,
+	encoder codec.EventBatchEncoder,
+	result []*common.Message,
+) ([]*common.Message, error) {
+	for {
+		tp, hasNext, err := decoder.HasNext()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if !hasNext {
+			return result, nil
+		}
+
+		var msg *common.Message
+		switch tp {
+		case model.MessageTypeRow:
+			row, err := decoder.NextRowChangedEvent()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if err := encoder.AppendRowChangedEvent(ctx, "", row, nil); err != nil {
+				return nil, errors.Trace(err)
+			}
+			continue
+		case model.MessageTypeDDL:
+			ddl, err := decoder.NextDDLEvent()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if msg, err = encoder.EncodeDDLEvent(ddl); err != nil {
+				return nil, errors.Trace(err)
+			}
+		case model.MessageTypeResolved:
+			ts, err := decoder.NextResolvedEvent()
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if msg, err = encoder.EncodeCheckpointEvent(ts); err != nil {
+				return nil, errors.Trace(err)
+			}
+		default:
+			return nil, cerror.ErrCodecDecode.GenWithStack(
+				"unexpected message type %d", tp)
+		}
+		// the rows before the event are flushed first to keep the order.
+		built, err := encoder.Build()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		result = append(result, built...)
+		if msg != nil {
+			result = append(result, msg)
+		}
+	}
+}
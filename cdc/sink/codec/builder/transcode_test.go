@@ -0,0 +1,178 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/canal"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscodeCanalToCanalJSON(t *testing.T) {
+	t.Parallel()
+
+	table := &model.TableName{Schema: "test", Table: "person"}
+	rows := []*model.RowChangedEvent{
+		{
+			CommitTs: 417318403368288260,
+			Table:    table,
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+				{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+			},
+		},
+		{
+			CommitTs: 417318403368288260,
+			Table:    table,
+			PreColumns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 2},
+				{Name: "name", Type: mysql.TypeVarchar, Value: nil},
+			},
+		},
+	}
+
+	encoder := canal.NewBatchEncoderBuilder(common.NewConfig(config.ProtocolCanal)).Build()
+	for _, row := range rows {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
+		require.Nil(t, err)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	out, err := Transcode(msgs[0].Value, config.ProtocolCanal, config.ProtocolCanalJSON)
+	require.Nil(t, err)
+	lines := bytes.Split(out, []byte("\n"))
+	require.Len(t, lines, len(rows))
+	for i, line := range lines {
+		decoder := canal.NewBatchDecoder(line, false)
+		tp, hasNext, err := decoder.HasNext()
+		require.Nil(t, err)
+		require.True(t, hasNext)
+		require.Equal(t, model.MessageTypeRow, tp)
+		row, err := decoder.NextRowChangedEvent()
+		require.Nil(t, err)
+
+		expected := rows[i]
+		require.Equal(t, expected.Table.Schema, row.Table.Schema)
+		require.Equal(t, expected.Table.Table, row.Table.Table)
+		require.Equal(t, expected.IsDelete(), row.IsDelete())
+		expectedColumns, columns := expected.Columns, row.Columns
+		if expected.IsDelete() {
+			expectedColumns, columns = expected.PreColumns, row.PreColumns
+		}
+		require.Len(t, columns, len(expectedColumns))
+		values := make(map[string]interface{})
+		for _, column := range columns {
+			values[column.Name] = column.Value
+		}
+		for _, column := range expectedColumns {
+			if column.Value == nil {
+				require.Nil(t, values[column.Name])
+			} else {
+				// the values are decoded as the strings.
+				require.Equal(t, fmt.Sprint(column.Value), values[column.Name])
+			}
+		}
+	}
+
+	// transcode back to canal, the rows are carried by one packet.
+	back, err := Transcode(out, config.ProtocolCanalJSON, config.ProtocolCanal)
+	require.Nil(t, err)
+	decoder, err := canal.NewPacketDecoder(back)
+	require.Nil(t, err)
+	count := 0
+	for {
+		tp, hasNext, err := decoder.HasNext()
+		require.Nil(t, err)
+		if !hasNext {
+			break
+		}
+		require.Equal(t, model.MessageTypeRow, tp)
+		row, err := decoder.NextRowChangedEvent()
+		require.Nil(t, err)
+		require.Equal(t, rows[count].IsDelete(), row.IsDelete())
+		count++
+	}
+	require.Equal(t, len(rows), count)
+}
+
+func TestTranscodeDDL(t *testing.T) {
+	t.Parallel()
+
+	encoder := canal.NewBatchEncoderBuilder(common.NewConfig(config.ProtocolCanal)).Build()
+	msg, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs: 417318403368288260,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "person"},
+		},
+		Query: "create table person(id int primary key)",
+		Type:  timodel.ActionCreateTable,
+	})
+	require.Nil(t, err)
+
+	out, err := Transcode(msg.Value, config.ProtocolCanal, config.ProtocolCanalJSON)
+	require.Nil(t, err)
+	decoder := canal.NewBatchDecoder(out, false)
+	tp, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	require.Equal(t, model.MessageTypeDDL, tp)
+	ddl, err := decoder.NextDDLEvent()
+	require.Nil(t, err)
+	require.Equal(t, "create table person(id int primary key)", ddl.Query)
+	require.Equal(t, "person", ddl.TableInfo.TableName.Table)
+
+	// the DDL and the rows can not be carried by one canal packet.
+	row, err := Transcode(mustEncodeCanalRow(t), config.ProtocolCanal, config.ProtocolCanalJSON)
+	require.Nil(t, err)
+	in := bytes.Join([][]byte{out, row}, []byte("\n"))
+	_, err = Transcode(in, config.ProtocolCanalJSON, config.ProtocolCanal)
+	require.True(t, cerror.ErrEncodeFailed.Equal(err))
+}
+
+func TestTranscodeUnsupported(t *testing.T) {
+	t.Parallel()
+
+	_, err := Transcode(nil, config.ProtocolCanal, config.ProtocolAvro)
+	require.True(t, cerror.ErrCodecInvalidConfig.Equal(err))
+	_, err = Transcode(nil, config.ProtocolOpen, config.ProtocolCanalJSON)
+	require.True(t, cerror.ErrCodecInvalidConfig.Equal(err))
+}
+
+func mustEncodeCanalRow(t *testing.T) []byte {
+	encoder := canal.NewBatchEncoderBuilder(common.NewConfig(config.ProtocolCanal)).Build()
+	err := encoder.AppendRowChangedEvent(context.Background(), "", &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "person"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+		},
+	}, nil)
+	require.Nil(t, err)
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	return msgs[0].Value
+}
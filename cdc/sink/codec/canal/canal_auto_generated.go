@@ -0,0 +1,101 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// the values of the autoGenerated prop of the columns.
+const (
+	autoIncrement = "autoIncrement"
+	autoRandom    = "autoRandom"
+)
+
+// autoGeneratedColumns maps the columns whose values are generated by TiDB
+// by default to how they're generated, which is autoIncrement for the
+// AUTO_INCREMENT column, and autoRandom for the AUTO_RANDOM primary key.
+// It returns nil if the schema of the table is unknown.
+func autoGeneratedColumns(tableInfo *model.TableInfo) map[string]string {
+	if tableInfo == nil || tableInfo.TableInfo == nil {
+		return nil
+	}
+	result := make(map[string]string)
+	for _, col := range tableInfo.Columns {
+		if !model.IsColCDCVisible(col) {
+			continue
+		}
+		if mysql.HasAutoIncrementFlag(col.GetFlag()) {
+			result[col.Name.O] = autoIncrement
+		}
+	}
+	// the AUTO_RANDOM column is always the clustered primary key.
+	if tableInfo.PKIsHandle && tableInfo.ContainsAutoRandomBits() {
+		if col := tableInfo.GetPkColInfo(); col != nil {
+			result[col.Name.O] = autoRandom
+		}
+	}
+	return result
+}
+
+// appendAutoGenerated stamps how the value of the column is generated by
+// default into the props, if it's an auto generated column.
+func (b *canalEntryBuilder) appendAutoGenerated(column *canal.Column, autoGenerated string) {
+	if autoGenerated == "" || !b.config.EnableAutoGenerated ||
+		!b.featureEnabled(featureAutoGenerated) {
+		return
+	}
+	column.Props = append(column.Props, &canal.Pair{
+		Key:   propAutoGenerated,
+		Value: autoGenerated,
+	})
+}
+
+// appendAutoGeneratedColumns stamps the auto generated columns of the table
+// after the DDL into the header props, i.e. the `autoIncrementColumns` and the
+// `autoRandomColumns`, each of which is omitted if there is no such column.
+func (b *canalEntryBuilder) appendAutoGeneratedColumns(h *canal.Header, tableInfo *model.TableInfo) {
+	if !b.config.EnableAutoGenerated || !b.featureEnabled(featureAutoGenerated) {
+		return
+	}
+	autoGenerated := autoGeneratedColumns(tableInfo)
+	if len(autoGenerated) == 0 {
+		return
+	}
+	var incrementColumns, randomColumns []string
+	for _, col := range tableInfo.Columns {
+		switch autoGenerated[col.Name.O] {
+		case autoIncrement:
+			incrementColumns = append(incrementColumns, b.columnName(col.Name.O))
+		case autoRandom:
+			randomColumns = append(randomColumns, b.columnName(col.Name.O))
+		}
+	}
+	if len(incrementColumns) > 0 {
+		h.Props = append(h.Props, &canal.Pair{
+			Key:   propAutoIncrementColumns,
+			Value: strings.Join(incrementColumns, ","),
+		})
+	}
+	if len(randomColumns) > 0 {
+		h.Props = append(h.Props, &canal.Pair{
+			Key:   propAutoRandomColumns,
+			Value: strings.Join(randomColumns, ","),
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoGenerated(t *testing.T) {
+	t.Parallel()
+
+	// newTableInfo returns the table info of `t(id bigint primary key, seq
+	// bigint, name varchar(32))`, which is tweaked by the callback.
+	newTableInfo := func(tweak func(info *mm.TableInfo, id, seq *mm.ColumnInfo)) *model.TableInfo {
+		newColumn := func(id int64, name string, tp byte) *mm.ColumnInfo {
+			return &mm.ColumnInfo{
+				ID:        id,
+				Name:      mm.NewCIStr(name),
+				FieldType: *types.NewFieldType(tp),
+				State:     mm.StatePublic,
+			}
+		}
+		id := newColumn(1, "id", mysql.TypeLonglong)
+		id.AddFlag(mysql.PriKeyFlag | mysql.NotNullFlag)
+		seq := newColumn(2, "seq", mysql.TypeLonglong)
+		name := newColumn(3, "name", mysql.TypeVarchar)
+		tableInfo := &mm.TableInfo{
+			ID:         1,
+			Name:       mm.NewCIStr("t"),
+			PKIsHandle: true,
+			Columns:    []*mm.ColumnInfo{id, seq, name},
+		}
+		tweak(tableInfo, id, seq)
+		return model.WrapTableInfo(1, "test", 1, tableInfo)
+	}
+	newRow := func(tableInfo *model.TableInfo) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs:  417318403368288260,
+			Table:     &model.TableName{Schema: "test", Table: "t"},
+			TableInfo: tableInfo,
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: 1},
+				{Name: "seq", Type: mysql.TypeLonglong, Value: 2},
+				{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Bob")},
+			},
+		}
+	}
+	// rowProps returns the autoGenerated prop of the columns of the row entry.
+	rowProps := func(codecConfig *common.Config, e *model.RowChangedEvent) map[string]string {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		result := make(map[string]string)
+		for _, column := range rc.GetRowDatas()[0].GetAfterColumns() {
+			for _, p := range column.GetProps() {
+				if p.GetKey() == propAutoGenerated {
+					result[column.GetName()] = p.GetValue()
+				}
+			}
+		}
+		return result
+	}
+	// ddlProps returns the auto generated columns props of the DDL entry.
+	ddlProps := func(codecConfig *common.Config, tableInfo *model.TableInfo) map[string]string {
+		entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(&model.DDLEvent{
+			CommitTs:  417318403368288260,
+			Query:     "create table t(...)",
+			Type:      mm.ActionCreateTable,
+			TableInfo: tableInfo,
+		})
+		require.Nil(t, err)
+		result := make(map[string]string)
+		for _, p := range entry.GetHeader().GetProps() {
+			switch p.GetKey() {
+			case propAutoIncrementColumns, propAutoRandomColumns:
+				result[p.GetKey()] = p.GetValue()
+			}
+		}
+		return result
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableAutoGenerated = true
+
+	// the AUTO_INCREMENT primary key.
+	autoIncrementPK := newTableInfo(func(_ *mm.TableInfo, id, _ *mm.ColumnInfo) {
+		id.AddFlag(mysql.AutoIncrementFlag)
+	})
+	require.Equal(t, map[string]string{"id": autoIncrement},
+		rowProps(codecConfig, newRow(autoIncrementPK)))
+	require.Equal(t, map[string]string{propAutoIncrementColumns: "id"},
+		ddlProps(codecConfig, autoIncrementPK))
+
+	// the AUTO_RANDOM primary key along with an AUTO_INCREMENT column.
+	autoRandomPK := newTableInfo(func(info *mm.TableInfo, _, seq *mm.ColumnInfo) {
+		info.AutoRandomBits = 5
+		seq.AddFlag(mysql.AutoIncrementFlag)
+	})
+	require.Equal(t, map[string]string{"id": autoRandom, "seq": autoIncrement},
+		rowProps(codecConfig, newRow(autoRandomPK)))
+	require.Equal(t, map[string]string{
+		propAutoIncrementColumns: "seq",
+		propAutoRandomColumns:    "id",
+	}, ddlProps(codecConfig, autoRandomPK))
+
+	// nothing is flagged for the table without the auto generated columns,
+	// or the row whose schema is unknown.
+	plain := newTableInfo(func(*mm.TableInfo, *mm.ColumnInfo, *mm.ColumnInfo) {})
+	require.Empty(t, rowProps(codecConfig, newRow(plain)))
+	require.Empty(t, ddlProps(codecConfig, plain))
+	require.Empty(t, rowProps(codecConfig, newRow(nil)))
+
+	// the props are omitted if disabled.
+	require.Empty(t, rowProps(common.NewConfig(config.ProtocolCanal), newRow(autoRandomPK)))
+	require.Empty(t, ddlProps(common.NewConfig(config.ProtocolCanal), autoRandomPK))
+	codecConfig.FeatureLevel = 2
+	require.Empty(t, rowProps(codecConfig, newRow(autoRandomPK)))
+	require.Empty(t, ddlProps(codecConfig, autoRandomPK))
+}
@@ -0,0 +1,396 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/avro"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// The Avro bridge encodes the canal entries of the rows into the Avro
+// payloads, whose schemas are registered against the schema registry, in
+// the confluent avro wire format like the Avro protocol. It keeps the type
+// mapping of canal, i.e. the columns are typed by their canal sqlType:
+//
+//	BIT, BIGINT                            long, BIT as the bits of uint64
+//	TINYINT, SMALLINT, INTEGER             int
+//	REAL                                   float
+//	DOUBLE                                 double
+//	BINARY, VARBINARY, LONGVARBINARY, BLOB bytes
+//	the others, e.g. DECIMAL and DATE      string, the canal column value
+//
+// All the columns are nullable, and carry the `sqlType` and the `mysqlType`
+// of canal in the field attributes. The value of the payload is a record
+// named after the table, with the fields:
+//
+//	eventType   string, the canal event type, e.g. INSERT
+//	executeTime long, the commit time in milliseconds
+//	props       map of string, the props of the canal header
+//	before      the row record of the before columns, nullable
+//	after       the row record of the after columns, nullable
+//
+// The row record has a field for each column of the row, and the columns
+// absent in the image, e.g. the ones of the shrunk old image, are null.
+// The schema is cached by the version of the table schema, see
+// avroSchemaColumns. The options batching the rows into a message, e.g. the
+// tombstone and the insert grouping, do not apply, since each row is a message.
+
+const (
+	avroValueSchemaSuffix = "-value"
+	avroRowRecordSuffix   = "_row"
+)
+
+// avroSchemaManager is the schema manager of the Avro protocol.
+type avroSchemaManager interface {
+	GetCachedOrRegister(
+		ctx context.Context, topicName string, tiSchemaID uint64, schemaGen avro.SchemaGenerator,
+	) (*goavro.Codec, int, error)
+}
+
+// AvroBatchEncoder encodes the rows into the Avro payloads by the canal entry
+// builder, see the Avro bridge above.
+type AvroBatchEncoder struct {
+	namespace     string
+	entryBuilder  *canalEntryBuilder
+	schemaManager avroSchemaManager
+	config        *common.Config
+	messages      []*common.Message
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (d *AvroBatchEncoder) AppendRowChangedEvent(
+	ctx context.Context,
+	topic string,
+	e *model.RowChangedEvent,
+	callback func(),
+) error {
+	e = d.entryBuilder.softDelete(e)
+	entry, err := d.entryBuilder.buildRowEntry(e)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	rowData := entry.RowChange.GetRowDatas()[0]
+	namespace := avro.GetAvroNamespace(d.namespace, e.Table)
+	schemaGen := func() (string, error) {
+		columns, err := d.avroSchemaColumns(e, rowData)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		return avroEntrySchema(namespace, e.Table.Table, columns)
+	}
+	avroCodec, registryID, err := d.schemaManager.GetCachedOrRegister(
+		ctx, avro.SanitizeTopic(topic), e.TableInfoVersion, schemaGen)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	native, err := avroEntryNative(namespace, e.Table.Table, entry.Header, rowData)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	bin, err := avroCodec.BinaryFromNative(nil, native)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrAvroEncodeToBinary, err)
+	}
+	value, err := avro.NewEnvelope(registryID, bin)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := checkMessageSize(len(value), d.config); err != nil {
+		return errors.Trace(err)
+	}
+
+	msg := common.NewMsg(config.ProtocolAvro, nil, value, e.CommitTs,
+		model.MessageTypeRow, &e.Table.Schema, &e.Table.Table)
+	msg.Callback = callback
+	msg.IncRowsCount()
+	d.messages = append(d.messages, msg)
+	return nil
+}
+
+// EncodeCheckpointEvent is no-op, like the Avro protocol.
+func (d *AvroBatchEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	return nil, nil
+}
+
+// EncodeDDLEvent is no-op, like the Avro protocol, since the schema changes
+// are carried by the schema registry.
+func (d *AvroBatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error) {
+	return nil, nil
+}
+
+// Build implements the EventBatchEncoder interface
+func (d *AvroBatchEncoder) Build() ([]*common.Message, error) {
+	ret := d.messages
+	d.messages = nil
+	return ret, nil
+}
+
+// avroField is a field of the Avro record schema.
+type avroField struct {
+	Name string      `json:"name"`
+	Type interface{} `json:"type"`
+	// Default is set for the nullable fields only.
+	Default json.RawMessage `json:"default,omitempty"`
+	// SQLType and MySQLType are the type of the column in canal,
+	// omitted for the fields not of the columns.
+	SQLType   *int32 `json:"sqlType,omitempty"`
+	MySQLType string `json:"mysqlType,omitempty"`
+}
+
+// avroRecord is the Avro record schema.
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+// avroColumnType returns the Avro type of the canal column.
+func avroColumnType(sqlType internal.JavaSQLType) string {
+	switch sqlType {
+	case internal.JavaSQLTypeBIT, internal.JavaSQLTypeBIGINT:
+		return "long"
+	case internal.JavaSQLTypeTINYINT, internal.JavaSQLTypeSMALLINT, internal.JavaSQLTypeINTEGER:
+		return "int"
+	case internal.JavaSQLTypeREAL:
+		return "float"
+	case internal.JavaSQLTypeDOUBLE:
+		return "double"
+	case internal.JavaSQLTypeBINARY, internal.JavaSQLTypeVARBINARY,
+		internal.JavaSQLTypeLONGVARBINARY, internal.JavaSQLTypeBLOB:
+		return "bytes"
+	default:
+		return "string"
+	}
+}
+
+// avroSchemaColumns returns the columns typing the row record, which are the
+// columns of the full image of the row, followed by the ones only in the row
+// data, e.g. the enriched ones. So the row record is the same for the rows of
+// the same schema, no matter whether their images are shrunk.
+func (d *AvroBatchEncoder) avroSchemaColumns(
+	e *model.RowChangedEvent, rowData *canal.RowData,
+) ([]*canal.Column, error) {
+	image := e.Columns
+	if len(image) == 0 {
+		image = e.PreColumns
+	}
+	var columns []*canal.Column
+	for _, column := range image {
+		if column == nil {
+			continue
+		}
+		c, err := d.entryBuilder.buildColumn(column, d.entryBuilder.columnName(column.Name), false)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		columns = append(columns, c)
+	}
+	columns = append(columns, rowData.GetAfterColumns()...)
+	columns = append(columns, rowData.GetBeforeColumns()...)
+
+	var result []*canal.Column
+	seen := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		if _, ok := seen[column.GetName()]; ok {
+			continue
+		}
+		seen[column.GetName()] = struct{}{}
+		result = append(result, column)
+	}
+	return result, nil
+}
+
+// avroRowRecordName returns the full name of the row record of the table.
+func avroRowRecordName(namespace, table string) string {
+	return namespace + "." + avro.SanitizeName(table) + avroRowRecordSuffix
+}
+
+// avroEntrySchema returns the Avro schema of the canal entry of the row.
+func avroEntrySchema(namespace, table string, columns []*canal.Column) (string, error) {
+	rowRecord := &avroRecord{
+		Type: "record",
+		Name: avro.SanitizeName(table) + avroRowRecordSuffix,
+	}
+	for _, column := range columns {
+		sqlType := column.GetSqlType()
+		rowRecord.Fields = append(rowRecord.Fields, avroField{
+			Name:      avro.SanitizeName(column.GetName()),
+			Type:      []interface{}{"null", avroColumnType(internal.JavaSQLType(sqlType))},
+			Default:   json.RawMessage("null"),
+			SQLType:   &sqlType,
+			MySQLType: column.GetMysqlType(),
+		})
+	}
+	top := &avroRecord{
+		Type:      "record",
+		Name:      avro.SanitizeName(table),
+		Namespace: namespace,
+		Fields: []avroField{
+			{Name: "eventType", Type: "string"},
+			{Name: "executeTime", Type: "long"},
+			{Name: "props", Type: map[string]string{"type": "map", "values": "string"}},
+			{Name: "before", Type: []interface{}{"null", rowRecord}},
+			{Name: "after", Type: []interface{}{"null", avroRowRecordName(namespace, table)}},
+		},
+	}
+	b, err := json.Marshal(top)
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrAvroMarshalFailed, err)
+	}
+	return string(b), nil
+}
+
+// avroEntryNative returns the Avro native data of the canal entry of the row.
+func avroEntryNative(
+	namespace, table string, header *canal.Header, rowData *canal.RowData,
+) (map[string]interface{}, error) {
+	props := make(map[string]interface{}, len(header.GetProps()))
+	for _, p := range header.GetProps() {
+		props[p.GetKey()] = p.GetValue()
+	}
+	native := map[string]interface{}{
+		"eventType":   header.GetEventType().String(),
+		"executeTime": header.GetExecuteTime(),
+		"props":       props,
+		"before":      nil,
+		"after":       nil,
+	}
+	for key, columns := range map[string][]*canal.Column{
+		"before": rowData.GetBeforeColumns(),
+		"after":  rowData.GetAfterColumns(),
+	} {
+		if len(columns) == 0 {
+			continue
+		}
+		record := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			value, err := avroColumnNative(column)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			record[avro.SanitizeName(column.GetName())] = value
+		}
+		native[key] = goavro.Union(avroRowRecordName(namespace, table), record)
+	}
+	return native, nil
+}
+
+// avroColumnNative returns the Avro native data of the canal column, which
+// is parsed from the column value by the Avro type of the column.
+func avroColumnNative(column *canal.Column) (interface{}, error) {
+	if column.GetIsNull() {
+		return nil, nil
+	}
+	value := column.GetValue()
+	avroType := avroColumnType(internal.JavaSQLType(column.GetSqlType()))
+	var (
+		result interface{}
+		err    error
+	)
+	switch avroType {
+	case "long":
+		if internal.JavaSQLType(column.GetSqlType()) == internal.JavaSQLTypeBIT {
+			var v uint64
+			v, err = strconv.ParseUint(value, 10, 64)
+			result = int64(v)
+		} else {
+			result, err = strconv.ParseInt(value, 10, 64)
+		}
+	case "int":
+		var v int64
+		v, err = strconv.ParseInt(value, 10, 32)
+		result = int32(v)
+	case "float":
+		var v float64
+		v, err = strconv.ParseFloat(value, 32)
+		result = float32(v)
+	case "double":
+		result, err = strconv.ParseFloat(value, 64)
+	case "bytes":
+		// the binary value is decoded as ISO-8859-1 by canal.
+		result, err = charmap.ISO8859_1.NewEncoder().Bytes([]byte(value))
+	default:
+		result = value
+	}
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrAvroEncodeFailed, err)
+	}
+	return goavro.Union(avroType, result), nil
+}
+
+type avroBatchEncoderBuilder struct {
+	namespace     string
+	config        *common.Config
+	schemaManager avroSchemaManager
+	state         *encoderState
+	op            *encoderOptions
+}
+
+// NewAvroBatchEncoderBuilder creates an EncoderBuilder of the Avro bridge,
+// whose schemas are registered against the schema registry of the config.
+// The serializer option is ignored, since the entries are encoded in Avro.
+func NewAvroBatchEncoderBuilder(
+	ctx context.Context, config *common.Config, opts ...Option,
+) (codec.EncoderBuilder, error) {
+	schemaManager, err := avro.NewAvroSchemaManager(
+		ctx, nil, config.AvroSchemaRegistry, avroValueSchemaSuffix)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	op := newEncoderOptions()
+	for _, opt := range opts {
+		opt(op)
+	}
+	return &avroBatchEncoderBuilder{
+		namespace:     contextutil.ChangefeedIDFromCtx(ctx).Namespace,
+		config:        config,
+		schemaManager: schemaManager,
+		state:         newEncoderState(config),
+		op:            op,
+	}, nil
+}
+
+// Build a AvroBatchEncoder
+func (b *avroBatchEncoderBuilder) Build() codec.EventBatchEncoder {
+	entryBuilder := newCanalEntryBuilder(b.config)
+	entryBuilder.sequencer = b.state.sequencer
+	entryBuilder.seenKeys = b.state.seenKeys
+	entryBuilder.enrichments = b.op.enrichments
+	entryBuilder.gtidSourceID = gtidSourceID(b.op.changefeedID)
+	if b.op.timezone != nil {
+		entryBuilder.timezone = b.op.timezone.String()
+	}
+	entryBuilder.encryptor = b.op.encryptor
+	return &AvroBatchEncoder{
+		namespace:     b.namespace,
+		entryBuilder:  entryBuilder,
+		schemaManager: b.schemaManager,
+		config:        b.config,
+	}
+}
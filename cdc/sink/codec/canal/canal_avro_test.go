@@ -0,0 +1,162 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/linkedin/goavro/v2"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/contextutil"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+const testRegistryURL = "http://127.0.0.1:8081"
+
+// startTestRegistry intercepts the requests to the schema registry, and
+// returns the schemas registered by subject.
+func startTestRegistry(t *testing.T) map[string]string {
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	schemas := make(map[string]string)
+	httpmock.RegisterResponder("GET", testRegistryURL, httpmock.NewStringResponder(200, "{}"))
+	httpmock.RegisterResponder("POST", `=~^`+testRegistryURL+`/subjects/(.+)/versions`,
+		func(req *http.Request) (*http.Response, error) {
+			subject, err := httpmock.GetSubmatch(req, 1)
+			if err != nil {
+				return nil, err
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			var request struct {
+				Schema string `json:"schema"`
+			}
+			if err := json.Unmarshal(body, &request); err != nil {
+				return nil, err
+			}
+			schemas[subject] = request.Schema
+			return httpmock.NewJsonResponse(200, map[string]int{"id": len(schemas)})
+		})
+	return schemas
+}
+
+func TestAvroBatchEncoder(t *testing.T) {
+	schemas := startTestRegistry(t)
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.AvroSchemaRegistry = testRegistryURL
+	ctx := contextutil.PutChangefeedIDInCtx(context.Background(),
+		model.DefaultChangeFeedID("test"))
+	builder, err := NewAvroBatchEncoderBuilder(ctx, codecConfig)
+	require.NoError(t, err)
+	encoder := builder.Build()
+
+	keyFlag := model.HandleKeyFlag | model.PrimaryKeyFlag
+	event := &model.RowChangedEvent{
+		CommitTs:         417318403368288260,
+		Table:            &model.TableName{Schema: "test", Table: "t"},
+		TableInfoVersion: 1,
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: keyFlag, Value: int64(1)},
+			{Name: "score", Type: mysql.TypeLonglong, Value: int64(1 << 40)},
+			{Name: "price", Type: mysql.TypeNewDecimal, Value: "3.14"},
+			{Name: "ratio", Type: mysql.TypeDouble, Value: float64(0.5)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Alice")},
+			{Name: "data", Type: mysql.TypeBlob, Flag: model.BinaryFlag, Value: []byte{0x01, 0xff}},
+			{Name: "note", Type: mysql.TypeVarchar, Value: nil},
+		},
+	}
+	require.NoError(t, encoder.AppendRowChangedEvent(ctx, "canal.topic", event, nil))
+	messages, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, config.ProtocolAvro, messages[0].Protocol)
+
+	// the schema is registered against the sanitized topic.
+	schema, ok := schemas["canal_topic-value"]
+	require.True(t, ok)
+
+	// the columns are typed by their canal sqlType.
+	var top struct {
+		Fields []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(schema), &top))
+	var rowRecord struct {
+		Fields []struct {
+			Name      string        `json:"name"`
+			Type      []interface{} `json:"type"`
+			SQLType   int32         `json:"sqlType"`
+			MySQLType string        `json:"mysqlType"`
+		} `json:"fields"`
+	}
+	var before []json.RawMessage
+	require.Equal(t, "before", top.Fields[3].Name)
+	require.NoError(t, json.Unmarshal(top.Fields[3].Type, &before))
+	require.NoError(t, json.Unmarshal(before[1], &rowRecord))
+	types := make(map[string]interface{})
+	for _, field := range rowRecord.Fields {
+		types[field.Name] = field.Type[1]
+		if field.Name == "price" {
+			require.Equal(t, int32(internal.JavaSQLTypeDECIMAL), field.SQLType)
+			require.Equal(t, "decimal", field.MySQLType)
+		}
+	}
+	require.Equal(t, map[string]interface{}{
+		"id":    "int",
+		"score": "long",
+		"price": "string",
+		"ratio": "double",
+		"name":  "string",
+		"data":  "bytes",
+		"note":  "string",
+	}, types)
+
+	// the payload is in the confluent avro wire format.
+	value := messages[0].Value
+	require.Equal(t, byte(0), value[0])
+	require.Equal(t, uint32(1), binary.BigEndian.Uint32(value[1:5]))
+	avroCodec, err := goavro.NewCodec(schema)
+	require.NoError(t, err)
+	native, _, err := avroCodec.NativeFromBinary(value[5:])
+	require.NoError(t, err)
+	record := native.(map[string]interface{})
+	require.Equal(t, "INSERT", record["eventType"])
+	require.Nil(t, record["before"])
+	after := record["after"].(map[string]interface{})["default.test.t_row"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{
+		"id":    map[string]interface{}{"int": int32(1)},
+		"score": map[string]interface{}{"long": int64(1 << 40)},
+		"price": map[string]interface{}{"string": "3.14"},
+		"ratio": map[string]interface{}{"double": 0.5},
+		"name":  map[string]interface{}{"string": "Alice"},
+		"data":  map[string]interface{}{"bytes": []byte{0x01, 0xff}},
+		"note":  nil,
+	}, after)
+}
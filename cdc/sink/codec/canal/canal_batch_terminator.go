@@ -0,0 +1,51 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// fromBatchEnd builds the canal entry terminating the packet of the rows,
+// which tells the file-based consumers that the batch is complete. It's an
+// ENTRYHEARTBEAT entry like the heartbeat, so that the consumers not
+// expecting it skip it as a no-op, and it's told from the heartbeat by the
+// `batchEnd` prop, which carries the number of the row entries before it.
+func (b *canalEntryBuilder) fromBatchEnd(ts uint64, rowCount int) *Entry {
+	header := b.buildHeader(ts, "", "", canal.EventType_MHEARTBEAT, -1)
+	header.Props = append(header.Props, &canal.Pair{
+		Key:   propBatchEnd,
+		Value: strconv.Itoa(rowCount),
+	})
+	return &Entry{
+		Header:    header,
+		EntryType: canal.EntryType_ENTRYHEARTBEAT,
+	}
+}
+
+// appendBatchEnd appends the terminator to the entries of the packet of the
+// rows, if the batch terminator is enabled.
+func (d *BatchEncoder) appendBatchEnd(rowCount int) error {
+	if !d.config.EnableBatchTerminator {
+		return nil
+	}
+	b, err := d.serializer.Serialize(d.entryBuilder.fromBatchEnd(d.maxCommitTs, rowCount))
+	if err != nil {
+		return serializeError(err)
+	}
+	d.messages.Messages = append(d.messages.Messages, b)
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTerminator(t *testing.T) {
+	t.Parallel()
+
+	row := func(commitTs uint64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			},
+		}
+	}
+	// entries returns the entries of the packet of the message.
+	entries := func(msg *common.Message) []*canal.Entry {
+		packet := &canal.Packet{}
+		require.NoError(t, proto.Unmarshal(msg.Value, packet))
+		messages := &canal.Messages{}
+		require.NoError(t, proto.Unmarshal(packet.GetBody(), messages))
+		var result []*canal.Entry
+		for _, b := range messages.GetMessages() {
+			entry := &canal.Entry{}
+			require.NoError(t, proto.Unmarshal(b, entry))
+			result = append(result, entry)
+		}
+		return result
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableBatchTerminator = true
+	encoder := newBatchEncoder(codecConfig)
+	for _, batch := range [][]uint64{{10, 12, 11}, {20}} {
+		count := 0
+		for _, ts := range batch {
+			err := encoder.AppendRowChangedEvent(context.Background(), "", row(ts), func() { count++ })
+			require.NoError(t, err)
+		}
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		require.Equal(t, len(batch), msgs[0].GetRowsCount())
+		msgs[0].Callback()
+		require.Equal(t, len(batch), count)
+
+		// the terminator is the last entry, after all the rows.
+		result := entries(msgs[0])
+		require.Len(t, result, len(batch)+1)
+		for _, entry := range result[:len(batch)] {
+			require.Equal(t, canal.EntryType_ROWDATA, entry.GetEntryType())
+		}
+		terminator := result[len(batch)]
+		require.Equal(t, canal.EntryType_ENTRYHEARTBEAT, terminator.GetEntryType())
+		require.Equal(t, canal.EventType_MHEARTBEAT, terminator.GetHeader().GetEventType())
+		require.Empty(t, terminator.GetStoreValue())
+		require.Equal(t, []*canal.Pair{
+			{Key: propBatchEnd, Value: strconv.Itoa(len(batch))},
+		}, terminator.GetHeader().GetProps())
+	}
+	// no terminator is emitted for the empty batch.
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// the heartbeat is not a terminator.
+	msg, err := encoder.(*BatchEncoder).EncodeHeartbeat(30)
+	require.NoError(t, err)
+	for _, p := range entries(msg)[0].GetHeader().GetProps() {
+		require.NotEqual(t, propBatchEnd, p.GetKey())
+	}
+
+	// the terminator is opt-in.
+	encoder = newBatchEncoder(common.NewConfig(config.ProtocolCanal))
+	require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", row(10), nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Len(t, entries(msgs[0]), 1)
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// booleanColumns returns the names of the TINYINT(1) columns of the table of
+// the row, which are the booleans in TiDB, nil if the booleans are not
+// normalized or the schema of the table is unknown. The display width is only
+// kept in the schema, the columns of the row carry the type only.
+func booleanColumns(e *model.RowChangedEvent, normalization string) map[string]struct{} {
+	if normalization == "" || normalization == common.BooleanNormalizationNone ||
+		e.TableInfo == nil || e.TableInfo.TableInfo == nil {
+		return nil
+	}
+	result := make(map[string]struct{})
+	for _, col := range e.TableInfo.Columns {
+		if col.GetType() == mysql.TypeTiny && col.GetFlen() == 1 {
+			result[col.Name.O] = struct{}{}
+		}
+	}
+	return result
+}
+
+// normalizeBoolean emits the formatted value of the boolean column as false
+// or true. The other values are returned as is, or fail with
+// ErrCanalInvalidBoolean if the normalization is strict.
+func normalizeBoolean(name, value string, normalization string) (string, error) {
+	switch value {
+	case "0":
+		return "false", nil
+	case "1":
+		return "true", nil
+	}
+	if normalization == common.BooleanNormalizationStrict {
+		return "", encodeError(cerror.ErrCanalUnsupportedType,
+			cerror.ErrCanalInvalidBoolean.GenWithStackByArgs(value, name))
+	}
+	return value, nil
+}
+
+// applyBoolean normalizes the value of the column if it's a boolean column,
+// the null value is kept as is.
+func (b *canalEntryBuilder) applyBoolean(
+	column *canal.Column, c *model.Column, booleans map[string]struct{},
+) error {
+	if _, ok := booleans[c.Name]; !ok || c.Value == nil {
+		return nil
+	}
+	value, err := normalizeBoolean(c.Name, column.Value, b.config.BooleanNormalization)
+	if err != nil {
+		return err
+	}
+	column.Value = value
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBooleanNormalization(t *testing.T) {
+	t.Parallel()
+
+	// the table is `t(id int primary key, flag tinyint(1), level tinyint(4))`.
+	newColumn := func(id int64, name string, flen int, flag uint) *mm.ColumnInfo {
+		ft := types.NewFieldType(mysql.TypeTiny)
+		if name == "id" {
+			ft = types.NewFieldType(mysql.TypeLong)
+		}
+		ft.SetFlen(flen)
+		col := &mm.ColumnInfo{
+			ID:        id,
+			Name:      mm.NewCIStr(name),
+			FieldType: *ft,
+			State:     mm.StatePublic,
+		}
+		col.AddFlag(flag)
+		return col
+	}
+	tableInfo := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:         1,
+		Name:       mm.NewCIStr("t"),
+		PKIsHandle: true,
+		Columns: []*mm.ColumnInfo{
+			newColumn(1, "id", 11, mysql.PriKeyFlag|mysql.NotNullFlag),
+			newColumn(2, "flag", 1, 0),
+			newColumn(3, "level", 4, 0),
+		},
+	})
+	newRow := func(flag interface{}) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs:  417318403368288260,
+			Table:     &model.TableName{Schema: "test", Table: "t"},
+			TableInfo: tableInfo,
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+				{Name: "flag", Type: mysql.TypeTiny, Flag: model.NullableFlag, Value: flag},
+				{Name: "level", Type: mysql.TypeTiny, Flag: model.NullableFlag, Value: int64(1)},
+			},
+		}
+	}
+	// encode returns the values of the flag and the level columns in the
+	// canal and the canal-json messages.
+	encode := func(normalization string, flag interface{}) ([]string, []interface{}, error) {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.BooleanNormalization = normalization
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(newRow(flag))
+		if err != nil {
+			return nil, nil, err
+		}
+		rc := &canal.RowChange{}
+		require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		columns := rc.GetRowDatas()[0].GetAfterColumns()
+		canalValues := []string{columns[1].GetValue(), columns[2].GetValue()}
+
+		codecConfig = common.NewConfig(config.ProtocolCanalJSON)
+		codecConfig.BooleanNormalization = normalization
+		encoder := NewJSONBatchEncoderBuilder(codecConfig).Build()
+		err = encoder.AppendRowChangedEvent(context.Background(), "", newRow(flag), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		messages, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		var msg JSONMessage
+		require.NoError(t, json.Unmarshal(messages[0].Value, &msg))
+		jsonValues := []interface{}{msg.Data[0]["flag"], msg.Data[0]["level"]}
+		return canalValues, jsonValues, nil
+	}
+
+	for _, c := range []struct {
+		normalization string
+		flag          interface{}
+		expected      string
+	}{
+		{common.BooleanNormalizationNone, int64(0), "0"},
+		{common.BooleanNormalizationNone, int64(1), "1"},
+		{common.BooleanNormalizationNone, int64(2), "2"},
+		{common.BooleanNormalizationPassThrough, int64(0), "false"},
+		{common.BooleanNormalizationPassThrough, int64(1), "true"},
+		{common.BooleanNormalizationPassThrough, int64(2), "2"},
+		{common.BooleanNormalizationStrict, int64(0), "false"},
+		{common.BooleanNormalizationStrict, int64(1), "true"},
+	} {
+		canalValues, jsonValues, err := encode(c.normalization, c.flag)
+		require.NoError(t, err)
+		// the TINYINT(4) column is not normalized.
+		require.Equal(t, []string{c.expected, "1"}, canalValues, c)
+		require.Equal(t, []interface{}{c.expected, "1"}, jsonValues, c)
+	}
+
+	// the value other than 0 and 1 is rejected if strict.
+	_, _, err := encode(common.BooleanNormalizationStrict, int64(2))
+	require.True(t, errors.Is(err, cerror.ErrCanalInvalidBoolean), err.Error())
+	require.ErrorContains(t, err, "the value 2 of column flag is not a boolean")
+
+	// the null is kept as is.
+	canalValues, jsonValues, err := encode(common.BooleanNormalizationStrict, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "1"}, canalValues)
+	require.Equal(t, []interface{}{nil, "1"}, jsonValues)
+}
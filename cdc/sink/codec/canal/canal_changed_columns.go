@@ -0,0 +1,92 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// changedColumns returns the positions of the columns changed by the update,
+// which is the offset among the columns of the post-image, along with their
+// names. The column is changed if its value differs from the value of the
+// same name in the pre-image, or it's absent in the pre-image, so the
+// transition between null and a value counts as a change.
+func changedColumns(e *model.RowChangedEvent) ([]int, []string) {
+	preValues := make(map[string]interface{}, len(e.PreColumns))
+	for _, c := range e.PreColumns {
+		if c != nil {
+			preValues[c.Name] = c.Value
+		}
+	}
+	var (
+		positions []int
+		names     []string
+	)
+	position := 0
+	for _, c := range e.Columns {
+		if c == nil {
+			continue
+		}
+		if old, ok := preValues[c.Name]; !ok || !reflect.DeepEqual(old, c.Value) {
+			positions = append(positions, position)
+			names = append(names, c.Name)
+		}
+		position++
+	}
+	return positions, names
+}
+
+// changedColumnsBitmap returns the hex encoded bitmap of the positions,
+// the column at position i is flagged by the bit (i % 8) of the byte (i / 8),
+// the same as the column bitmaps of the MySQL binlog, and the bitmap covers
+// all the columns of the post-image.
+func changedColumnsBitmap(positions []int, columns int) string {
+	bitmap := make([]byte, (columns+7)/8)
+	for _, i := range positions {
+		bitmap[i/8] |= 1 << (i % 8)
+	}
+	return hex.EncodeToString(bitmap)
+}
+
+// appendChangedColumns stamps the columns changed by the update into the
+// header props, in the form configured, the other row changes are skipped.
+func (b *canalEntryBuilder) appendChangedColumns(h *canal.Header, e *model.RowChangedEvent) {
+	if b.config.ChangedColumns == "" || !b.featureEnabled(featureChangedColumns) ||
+		!e.IsUpdate() {
+		return
+	}
+	positions, names := changedColumns(e)
+	var value string
+	if b.config.ChangedColumns == common.ChangedColumnsBitmap {
+		columns := 0
+		for _, c := range e.Columns {
+			if c != nil {
+				columns++
+			}
+		}
+		value = changedColumnsBitmap(positions, columns)
+	} else {
+		for i, name := range names {
+			names[i] = b.columnName(name)
+		}
+		value = strings.Join(names, ",")
+	}
+	h.Props = append(h.Props, &canal.Pair{Key: propChangedColumns, Value: value})
+}
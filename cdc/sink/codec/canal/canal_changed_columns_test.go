@@ -0,0 +1,115 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedColumns(t *testing.T) {
+	t.Parallel()
+
+	column := func(name string, value interface{}) *model.Column {
+		return &model.Column{Name: name, Type: mysql.TypeVarchar, Value: value}
+	}
+	// a partial update changing the columns at 1, 3, 4 and 9.
+	update := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "person"},
+		PreColumns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			column("name", []byte("Bob")),
+			column("age", int64(42)),
+			column("comment", nil),
+			column("note", []byte("vip")),
+			column("c5", nil), column("c6", "a"), column("c7", "b"), column("c8", "c"),
+			column("c9", []byte("d")),
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			column("name", []byte("Alice")),
+			column("age", int64(42)),
+			column("comment", []byte("")),
+			column("note", nil),
+			column("c5", nil), column("c6", "a"), column("c7", "b"), column("c8", "c"),
+			column("c9", []byte("e")),
+		},
+	}
+
+	positions, names := changedColumns(update)
+	require.Equal(t, []int{1, 3, 4, 9}, positions)
+	require.Equal(t, []string{"name", "comment", "note", "c9"}, names)
+	require.Equal(t, "1a02", changedColumnsBitmap(positions, len(update.Columns)))
+	require.Equal(t, "00", changedColumnsBitmap(nil, 1))
+
+	// encode returns the changedColumns prop of the row.
+	encode := func(codecConfig *common.Config, e *model.RowChangedEvent) (string, bool) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propChangedColumns {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.ChangedColumns = common.ChangedColumnsNames
+	value, ok := encode(codecConfig, update)
+	require.True(t, ok)
+	require.Equal(t, "name,comment,note,c9", value)
+
+	codecConfig.ChangedColumns = common.ChangedColumnsBitmap
+	value, ok = encode(codecConfig, update)
+	require.True(t, ok)
+	require.Equal(t, "1a02", value)
+
+	// the names are mapped the same as the columns.
+	codecConfig.ChangedColumns = common.ChangedColumnsNames
+	codecConfig.ColumnNameCase = common.NameCaseUpper
+	value, _ = encode(codecConfig, update)
+	require.Equal(t, "NAME,COMMENT,NOTE,C9", value)
+	codecConfig.ColumnNameCase = common.NameCaseUnchanged
+
+	// the update changing nothing carries the empty prop.
+	noop := &model.RowChangedEvent{
+		CommitTs:   update.CommitTs,
+		Table:      update.Table,
+		PreColumns: update.PreColumns,
+		Columns:    update.PreColumns,
+	}
+	value, ok = encode(codecConfig, noop)
+	require.True(t, ok)
+	require.Empty(t, value)
+
+	// the prop is omitted for the other row changes, or if disabled.
+	_, ok = encode(codecConfig, &model.RowChangedEvent{
+		CommitTs: update.CommitTs,
+		Table:    update.Table,
+		Columns:  update.Columns,
+	})
+	require.False(t, ok)
+	_, ok = encode(common.NewConfig(config.ProtocolCanal), update)
+	require.False(t, ok)
+	codecConfig.FeatureLevel = 2
+	_, ok = encode(codecConfig, update)
+	require.False(t, ok)
+}
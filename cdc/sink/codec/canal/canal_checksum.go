@@ -0,0 +1,156 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// newChecksumHash returns the hash function of the checksum algorithm.
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case common.ChecksumAlgorithmCRC32:
+		return crc32.NewIEEE(), nil
+	case common.ChecksumAlgorithmXXHash:
+		return xxhash.New(), nil
+	default:
+		return nil, cerror.ErrCanalEncodeFailed.GenWithStack(
+			"unknown checksum algorithm %s", algorithm)
+	}
+}
+
+// rowChecksum computes the checksum over the canonical serialization of the
+// columns, which are the after columns of the RowData, or the before columns
+// if the row is deleted. A consumer recomputes the checksum by calling it with
+// the decoded columns.
+//
+// The canonical serialization concatenates the columns sorted by their
+// ordinal, i.e. the position in the RowData, each column is serialized as:
+//
+//	ordinal: 4 bytes, big endian
+//	sqlType: 4 bytes, big endian, the java sql type of the column
+//	isNull:  1 byte, 1 if the value is null, otherwise 0
+//	length:  4 bytes, big endian, the length of the value, only if not null
+//	value:   the value string of the column, only if not null
+//
+// The checksum is the decimal representation of the unsigned hash sum.
+func rowChecksum(algorithm string, columns []*canal.Column) (string, error) {
+	h, err := newChecksumHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	var buf [4]byte
+	for ordinal, col := range columns {
+		binary.BigEndian.PutUint32(buf[:], uint32(ordinal))
+		h.Write(buf[:])
+		binary.BigEndian.PutUint32(buf[:], uint32(col.GetSqlType()))
+		h.Write(buf[:])
+		if col.GetIsNull() {
+			h.Write([]byte{1})
+			continue
+		}
+		h.Write([]byte{0})
+		value := col.GetValue()
+		binary.BigEndian.PutUint32(buf[:], uint32(len(value)))
+		h.Write(buf[:])
+		h.Write([]byte(value))
+	}
+
+	var sum uint64
+	switch s := h.(type) {
+	case hash.Hash32:
+		sum = uint64(s.Sum32())
+	case hash.Hash64:
+		sum = s.Sum64()
+	}
+	return strconv.FormatUint(sum, 10), nil
+}
+
+// checksumColumns returns the columns covered by the checksum of the row,
+// the enriched columns are not covered, since they are not of the row.
+func checksumColumns(h *canal.Header, rowData *canal.RowData) []*canal.Column {
+	if h.GetEventType() == canal.EventType_DELETE {
+		return rowData.BeforeColumns
+	}
+	result := rowData.AfterColumns
+	for i, c := range rowData.AfterColumns {
+		if isEnriched(c) {
+			// the enriched columns are appended after the ones of the row.
+			result = rowData.AfterColumns[:i]
+			break
+		}
+	}
+	return result
+}
+
+// appendRowChecksum stamps the checksum of the row into the header props.
+func (b *canalEntryBuilder) appendRowChecksum(h *canal.Header, rowData *canal.RowData) error {
+	algorithm := b.config.ChecksumAlgorithm
+	if algorithm == "" || !b.featureEnabled(featureRowChecksum) {
+		return nil
+	}
+
+	checksum, err := rowChecksum(algorithm, checksumColumns(h, rowData))
+	if err != nil {
+		return err
+	}
+	h.Props = append(h.Props,
+		&canal.Pair{Key: propChecksumAlgorithm, Value: algorithm},
+		&canal.Pair{Key: propChecksum, Value: checksum},
+	)
+	return nil
+}
+
+// appendUpstreamChecksum propagates the checksum attached by the upstream into
+// the header props as is. If VerifyUpstreamChecksum is set, the checksum is
+// also verified against the encoded columns regardless of the feature level,
+// so that a corruption between TiKV and the encoder is caught before the row
+// is sent to the downstream.
+func (b *canalEntryBuilder) appendUpstreamChecksum(
+	h *canal.Header, rowData *canal.RowData, checksum *model.RowChecksum,
+) error {
+	if checksum == nil {
+		// The upstream does not compute the checksum, there is nothing to
+		// propagate or verify.
+		return nil
+	}
+	if b.featureEnabled(featureUpstreamChecksum) {
+		h.Props = append(h.Props,
+			&canal.Pair{Key: propUpstreamChecksumAlgorithm, Value: checksum.Algorithm},
+			&canal.Pair{Key: propUpstreamChecksum, Value: checksum.Value},
+		)
+	}
+	if !b.config.VerifyUpstreamChecksum {
+		return nil
+	}
+
+	computed, err := rowChecksum(checksum.Algorithm, checksumColumns(h, rowData))
+	if err != nil {
+		return err
+	}
+	if computed != checksum.Value {
+		return cerror.ErrCanalChecksumMismatch.GenWithStackByArgs(checksum.Value, computed)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/tiflow/cdc/model"
+)
+
+// columnOrdinals returns the zero-based ordinal position of the columns by
+// name, which is the offset among the columns visible to CDC in the current
+// schema of the table, so the ordinals of a row are contiguous, even if the
+// table has dropped or virtual generated columns. It returns nil if the
+// ordinal is disabled or the schema of the table is unknown, in which case
+// the index of the columns is left as 0.
+func (b *canalEntryBuilder) columnOrdinals(e *model.RowChangedEvent) map[string]int {
+	if !b.config.EnableColumnOrdinal || !b.featureEnabled(featureColumnOrdinal) ||
+		e.TableInfo == nil || e.TableInfo.TableInfo == nil {
+		return nil
+	}
+	result := make(map[string]int, len(e.TableInfo.RowColumnsOffset))
+	for _, col := range e.TableInfo.Columns {
+		if offset, ok := e.TableInfo.RowColumnsOffset[col.ID]; ok {
+			result[col.Name.O] = offset
+		}
+	}
+	return result
+}
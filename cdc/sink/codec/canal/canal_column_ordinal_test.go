@@ -0,0 +1,113 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnOrdinal(t *testing.T) {
+	t.Parallel()
+
+	newColumn := func(id int64, name string, state mm.SchemaState) *mm.ColumnInfo {
+		return &mm.ColumnInfo{
+			ID:        id,
+			Name:      mm.NewCIStr(name),
+			FieldType: *types.NewFieldType(mysql.TypeVarchar),
+			State:     state,
+		}
+	}
+	newEvent := func(columns ...*mm.ColumnInfo) *model.RowChangedEvent {
+		event := &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "cdc", Table: "person"},
+			TableInfo: model.WrapTableInfo(1, "cdc", 1, &mm.TableInfo{
+				Name:    mm.NewCIStr("person"),
+				Columns: columns,
+			}),
+		}
+		for _, col := range columns {
+			if model.IsColCDCVisible(col) {
+				event.Columns = append(event.Columns, &model.Column{
+					Name: col.Name.O, Type: mysql.TypeVarchar, Value: col.Name.O,
+				})
+			}
+		}
+		return event
+	}
+	// encode returns the index of the after columns by name.
+	encode := func(codecConfig *common.Config, e *model.RowChangedEvent) map[string]int32 {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		result := make(map[string]int32)
+		for _, column := range rc.GetRowDatas()[0].GetAfterColumns() {
+			result[column.GetName()] = column.GetIndex()
+		}
+		return result
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableColumnOrdinal = true
+	event := newEvent(
+		newColumn(1, "id", mm.StatePublic),
+		newColumn(2, "name", mm.StatePublic),
+		newColumn(3, "age", mm.StatePublic),
+		newColumn(4, "email", mm.StatePublic),
+	)
+	require.Equal(t, map[string]int32{
+		"id": 0, "name": 1, "age": 2, "email": 3,
+	}, encode(codecConfig, event))
+
+	// the ordinals reflect the current schema after `age` is dropped.
+	event = newEvent(
+		newColumn(1, "id", mm.StatePublic),
+		newColumn(2, "name", mm.StatePublic),
+		newColumn(4, "email", mm.StatePublic),
+	)
+	require.Equal(t, map[string]int32{
+		"id": 0, "name": 1, "email": 2,
+	}, encode(codecConfig, event))
+
+	// the column being dropped is not visible, and not counted.
+	event = newEvent(
+		newColumn(1, "id", mm.StatePublic),
+		newColumn(2, "name", mm.StatePublic),
+		newColumn(3, "age", mm.StateWriteOnly),
+		newColumn(4, "email", mm.StatePublic),
+	)
+	require.Equal(t, map[string]int32{
+		"id": 0, "name": 1, "email": 2,
+	}, encode(codecConfig, event))
+
+	// the index is left as 0 if disabled, or for the old consumers.
+	require.Equal(t, map[string]int32{
+		"id": 0, "name": 0, "email": 0,
+	}, encode(common.NewConfig(config.ProtocolCanal), event))
+	codecConfig.FeatureLevel = 2
+	require.Equal(t, map[string]int32{
+		"id": 0, "name": 0, "email": 0,
+	}, encode(codecConfig, event))
+}
@@ -0,0 +1,284 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// columnarBatch is the wire shape of a columnar batch, it's a JSON object
+// holding the rows of a table which share the same schema, e.g.
+//
+//	{
+//	  "schema": "test",
+//	  "table": "person",
+//	  "columns": [
+//	    {"name": "id", "mysqlType": "int", "sqlType": 4, "isPk": true},
+//	    {"name": "name", "mysqlType": "varchar", "sqlType": 12}
+//	  ],
+//	  "rowCount": 2,
+//	  "types": ["INSERT", "DELETE"],
+//	  "commitTs": [417318403368288260, 417318403368288261],
+//	  "values": [["1", "2"], ["Bob", null]]
+//	}
+//
+// The values are stored by column, `values[i][j]` is the value of the i-th
+// column of the j-th row, and the j-th item of `types` and `commitTs` are the
+// event type and the commit ts of the j-th row, so all the arrays are aligned
+// by the row index. The values are formatted the same as the canal protocol,
+// and null is kept as the JSON null. A row carries its new image, or the old
+// image if it's a DELETE.
+type columnarBatch struct {
+	Schema   string           `json:"schema"`
+	Table    string           `json:"table"`
+	Columns  []columnarColumn `json:"columns"`
+	RowCount int              `json:"rowCount"`
+	Types    []string         `json:"types"`
+	CommitTs []uint64         `json:"commitTs"`
+	Values   [][]*string      `json:"values"`
+}
+
+// columnarColumn is the schema of a column in the columnar batch.
+type columnarColumn struct {
+	Name      string `json:"name"`
+	MySQLType string `json:"mysqlType"`
+	SQLType   int32  `json:"sqlType"`
+	IsPK      bool   `json:"isPk,omitempty"`
+}
+
+// columnarDDL is the wire shape of a DDL event in the columnar protocol.
+type columnarDDL struct {
+	Schema   string `json:"schema"`
+	Table    string `json:"table"`
+	Query    string `json:"query"`
+	CommitTs uint64 `json:"commitTs"`
+}
+
+// columnarGroup is a columnar batch being built.
+type columnarGroup struct {
+	batch     *columnarBatch
+	callbacks []func()
+}
+
+// ColumnarBatchEncoder buffers the rows by table, and encodes them into the
+// columnar batches, which are suitable to be converted to Parquet.
+type ColumnarBatchEncoder struct {
+	entryBuilder *canalEntryBuilder
+
+	// groups holds the groups in the order they are started,
+	// openGroups holds the group accepting the rows of each table.
+	groups     []*columnarGroup
+	openGroups map[model.TableName]*columnarGroup
+}
+
+// EncodeCheckpointEvent implements the EventBatchEncoder interface
+func (d *ColumnarBatchEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
+	// the checkpoint is not carried by the columnar protocol.
+	return nil, nil
+}
+
+// AppendRowChangedEvent implements the EventBatchEncoder interface
+func (d *ColumnarBatchEncoder) AppendRowChangedEvent(
+	_ context.Context,
+	_ string,
+	e *model.RowChangedEvent,
+	callback func(),
+) error {
+	columns := e.Columns
+	if e.IsDelete() {
+		columns = e.PreColumns
+	}
+	schema := make([]columnarColumn, 0, len(columns))
+	values := make([]*string, 0, len(columns))
+	for _, c := range columns {
+		if c == nil {
+			continue
+		}
+		column, value, err := d.convertColumn(c)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		schema = append(schema, column)
+		values = append(values, value)
+	}
+
+	// the rows of a different schema start a new group, e.g. after a DDL.
+	table := model.TableName{Schema: e.Table.Schema, Table: e.Table.Table}
+	group, ok := d.openGroups[table]
+	if !ok || !columnsEqual(group.batch.Columns, schema) {
+		group = &columnarGroup{
+			batch: &columnarBatch{
+				Schema:  e.Table.Schema,
+				Table:   e.Table.Table,
+				Columns: schema,
+				Values:  make([][]*string, len(schema)),
+			},
+		}
+		d.groups = append(d.groups, group)
+		d.openGroups[table] = group
+	}
+
+	batch := group.batch
+	batch.RowCount++
+	batch.Types = append(batch.Types, convertRowEventType(e).String())
+	batch.CommitTs = append(batch.CommitTs, e.CommitTs)
+	for i, value := range values {
+		batch.Values[i] = append(batch.Values[i], value)
+	}
+	if callback != nil {
+		group.callbacks = append(group.callbacks, callback)
+	}
+	return nil
+}
+
+// convertColumn returns the schema and the formatted value of the column.
+func (d *ColumnarBatchEncoder) convertColumn(
+	c *model.Column,
+) (columnarColumn, *string, error) {
+	mysqlType := getMySQLType(c)
+	javaType, err := getJavaSQLType(c, mysqlType)
+	if err != nil {
+		return columnarColumn{}, nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	}
+	column := columnarColumn{
+		Name:      c.Name,
+		MySQLType: mysqlType,
+		SQLType:   int32(columnarSQLType(c, javaType)),
+		IsPK:      c.Flag.IsPrimaryKey(),
+	}
+	if c.Value == nil {
+		return column, nil, nil
+	}
+	value, err := d.entryBuilder.formatValue(c.Value, javaType)
+	if err != nil {
+		return columnarColumn{}, nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	}
+	return column, &value, nil
+}
+
+// columnarSQLType returns the sql type of the column in the schema. The
+// unsigned integral types are always promoted to the wider type, since canal
+// promotes them by the value, which varies between the rows.
+func columnarSQLType(c *model.Column, javaType internal.JavaSQLType) internal.JavaSQLType {
+	if !c.Flag.IsUnsigned() {
+		return javaType
+	}
+	switch c.Type {
+	case mysql.TypeTiny:
+		return internal.JavaSQLTypeSMALLINT
+	case mysql.TypeShort:
+		return internal.JavaSQLTypeINTEGER
+	case mysql.TypeLong:
+		return internal.JavaSQLTypeBIGINT
+	case mysql.TypeLonglong:
+		return internal.JavaSQLTypeDECIMAL
+	}
+	return javaType
+}
+
+func columnsEqual(a, b []columnarColumn) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeDDLEvent implements the EventBatchEncoder interface
+func (d *ColumnarBatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error) {
+	value, err := json.Marshal(&columnarDDL{
+		Schema:   e.TableInfo.TableName.Schema,
+		Table:    e.TableInfo.TableName.Table,
+		Query:    e.Query,
+		CommitTs: e.CommitTs,
+	})
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	}
+	return common.NewDDLMsg(config.ProtocolCanalColumnar, nil, value, e), nil
+}
+
+// Build implements the EventBatchEncoder interface, it returns a message for
+// each group, in the order the groups are started.
+func (d *ColumnarBatchEncoder) Build() ([]*common.Message, error) {
+	if len(d.groups) == 0 {
+		return nil, nil
+	}
+	ret := make([]*common.Message, 0, len(d.groups))
+	for _, group := range d.groups {
+		batch := group.batch
+		value, err := json.Marshal(batch)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		}
+		var ts uint64
+		for _, commitTs := range batch.CommitTs {
+			if commitTs > ts {
+				ts = commitTs
+			}
+		}
+		schema, table := batch.Schema, batch.Table
+		msg := common.NewMsg(config.ProtocolCanalColumnar, nil, value, ts,
+			model.MessageTypeRow, &schema, &table)
+		msg.SetRowsCount(batch.RowCount)
+		if len(group.callbacks) != 0 {
+			callbacks := group.callbacks
+			msg.Callback = func() {
+				for _, cb := range callbacks {
+					cb()
+				}
+			}
+		}
+		ret = append(ret, msg)
+	}
+	d.groups = nil
+	d.openGroups = make(map[model.TableName]*columnarGroup)
+	return ret, nil
+}
+
+// newColumnarBatchEncoder creates a new ColumnarBatchEncoder.
+func newColumnarBatchEncoder(config *common.Config) codec.EventBatchEncoder {
+	return &ColumnarBatchEncoder{
+		entryBuilder: newCanalEntryBuilder(config),
+		openGroups:   make(map[model.TableName]*columnarGroup),
+	}
+}
+
+type columnarBatchEncoderBuilder struct {
+	config *common.Config
+}
+
+// Build a `ColumnarBatchEncoder`
+func (b *columnarBatchEncoderBuilder) Build() codec.EventBatchEncoder {
+	return newColumnarBatchEncoder(b.config)
+}
+
+// NewColumnarBatchEncoderBuilder creates a columnarBatchEncoderBuilder.
+func NewColumnarBatchEncoderBuilder(config *common.Config) codec.EncoderBuilder {
+	return &columnarBatchEncoderBuilder{config: config}
+}
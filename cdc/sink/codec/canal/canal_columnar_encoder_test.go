@@ -0,0 +1,145 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnarBatchEncoder(t *testing.T) {
+	t.Parallel()
+
+	person := &model.TableName{Schema: "test", Table: "person"}
+	order := &model.TableName{Schema: "test", Table: "order"}
+	personColumns := func(id int64, name interface{}) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: id},
+			{Name: "name", Type: mysql.TypeVarchar, Value: name},
+		}
+	}
+	events := []*model.RowChangedEvent{
+		{CommitTs: 1, Table: person, Columns: personColumns(1, "Alice")},
+		{CommitTs: 2, Table: order, Columns: []*model.Column{
+			{Name: "amount", Type: mysql.TypeLong, Flag: model.UnsignedFlag, Value: uint64(10)},
+		}},
+		{CommitTs: 3, Table: person, Columns: personColumns(2, nil)},
+		{CommitTs: 4, Table: person, PreColumns: personColumns(1, "Alice")},
+		// the schema is changed by a DDL, e.g. a column is added.
+		{CommitTs: 6, Table: person, Columns: append(personColumns(3, "Carol"),
+			&model.Column{Name: "age", Type: mysql.TypeLong, Value: int64(18)})},
+	}
+
+	encoder := NewColumnarBatchEncoderBuilder(
+		common.NewConfig(config.ProtocolCanalColumnar)).Build()
+	count := 0
+	for _, e := range events {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", e, func() { count++ })
+		require.Nil(t, err)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	rest, err := encoder.Build()
+	require.NoError(t, err)
+	require.Nil(t, rest)
+
+	batches := make([]*columnarBatch, 0, len(msgs))
+	for _, msg := range msgs {
+		batch := &columnarBatch{}
+		require.Nil(t, json.Unmarshal(msg.Value, batch))
+		require.Equal(t, batch.RowCount, msg.GetRowsCount())
+		require.Equal(t, batch.Schema, *msg.Schema)
+		require.Equal(t, batch.Table, *msg.Table)
+
+		// all the arrays are aligned by the row index.
+		require.Len(t, batch.Types, batch.RowCount)
+		require.Len(t, batch.CommitTs, batch.RowCount)
+		require.Len(t, batch.Values, len(batch.Columns))
+		for _, values := range batch.Values {
+			require.Len(t, values, batch.RowCount)
+		}
+		batches = append(batches, batch)
+		msg.Callback()
+	}
+	require.Equal(t, len(events), count)
+
+	str := func(s string) *string { return &s }
+	require.Equal(t, &columnarBatch{
+		Schema: "test",
+		Table:  "person",
+		Columns: []columnarColumn{
+			{Name: "id", MySQLType: "bigint", SQLType: int32(internal.JavaSQLTypeBIGINT), IsPK: true},
+			{Name: "name", MySQLType: "varchar", SQLType: int32(internal.JavaSQLTypeVARCHAR)},
+		},
+		RowCount: 3,
+		Types:    []string{"INSERT", "INSERT", "DELETE"},
+		CommitTs: []uint64{1, 3, 4},
+		Values: [][]*string{
+			{str("1"), str("2"), str("1")},
+			{str("Alice"), nil, str("Alice")},
+		},
+	}, batches[0])
+	require.Equal(t, uint64(4), msgs[0].Ts)
+
+	require.Equal(t, "order", batches[1].Table)
+	require.Equal(t, []columnarColumn{{
+		Name: "amount", MySQLType: "int unsigned", SQLType: int32(internal.JavaSQLTypeBIGINT),
+	}}, batches[1].Columns)
+	require.Equal(t, [][]*string{{str("10")}}, batches[1].Values)
+
+	// the rows after the schema change start a new group.
+	require.Equal(t, "person", batches[2].Table)
+	require.Len(t, batches[2].Columns, 3)
+	require.Equal(t, []uint64{6}, batches[2].CommitTs)
+	require.Equal(t, [][]*string{{str("3")}, {str("Carol")}, {str("18")}}, batches[2].Values)
+}
+
+func TestColumnarBatchEncoderDDL(t *testing.T) {
+	t.Parallel()
+
+	encoder := NewColumnarBatchEncoderBuilder(
+		common.NewConfig(config.ProtocolCanalColumnar)).Build()
+	msg, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs: 5,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "person"},
+		},
+		Query: "alter table person add column age int",
+		Type:  mm.ActionAddColumn,
+	})
+	require.Nil(t, err)
+	require.Equal(t, model.MessageTypeDDL, msg.Type)
+	ddl := &columnarDDL{}
+	require.Nil(t, json.Unmarshal(msg.Value, ddl))
+	require.Equal(t, &columnarDDL{
+		Schema:   "test",
+		Table:    "person",
+		Query:    "alter table person add column age int",
+		CommitTs: 5,
+	}, ddl)
+
+	msg, err = encoder.EncodeCheckpointEvent(5)
+	require.Nil(t, err)
+	require.Nil(t, msg)
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// defaultConsistencyLevel is the level of the changefeed without the consistent
+// config, which is the same as redo.ConsistentLevelNone.
+const defaultConsistencyLevel = "none"
+
+// buildConsistencyLevel returns the value of the consistencyLevel prop, which
+// is static in the changefeed, or empty if the prop is not emitted.
+func (b *canalEntryBuilder) buildConsistencyLevel() string {
+	if !b.config.EnableConsistencyLevel || !b.featureEnabled(featureConsistencyLevel) {
+		return ""
+	}
+	if b.config.ConsistencyLevel == "" {
+		return defaultConsistencyLevel
+	}
+	return b.config.ConsistencyLevel
+}
+
+// appendConsistencyLevel stamps the consistent level of the changefeed into
+// the header props, so that the consumer can tell the events replicated with
+// the eventual consistency, i.e. the redo log, from the others.
+func (b *canalEntryBuilder) appendConsistencyLevel(h *canal.Header) {
+	if b.consistencyLevel == "" {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propConsistencyLevel,
+		Value: b.consistencyLevel,
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"net/url"
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyLevel(t *testing.T) {
+	t.Parallel()
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+		},
+	}
+	ddl := &model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     "create table test.t(id int primary key)",
+		Type:      mm.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	}
+	// levels returns the consistencyLevel prop of the row and DDL entries,
+	// the absent prop is returned as empty.
+	levels := func(codecConfig *common.Config) []string {
+		builder := newCanalEntryBuilder(codecConfig)
+		rowEntry, err := builder.fromRowEvent(row)
+		require.Nil(t, err)
+		ddlEntry, err := builder.fromDDLEvent(ddl)
+		require.Nil(t, err)
+		var result []string
+		for _, entry := range []*canal.Entry{rowEntry, ddlEntry} {
+			level := ""
+			for _, p := range entry.GetHeader().GetProps() {
+				if p.GetKey() == propConsistencyLevel {
+					level = p.GetValue()
+				}
+			}
+			result = append(result, level)
+		}
+		return result
+	}
+	// newConfig returns the codec config of the changefeed
+	// with the consistent level.
+	newConfig := func(level string) *common.Config {
+		sinkURI, err := url.Parse("kafka://127.0.0.1:9092/abc?protocol=canal&enable-consistency-level=true")
+		require.Nil(t, err)
+		replicaConfig := config.GetDefaultReplicaConfig()
+		replicaConfig.Consistent.Level = level
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		require.Nil(t, codecConfig.Apply(sinkURI, replicaConfig))
+		return codecConfig
+	}
+
+	require.Equal(t, []string{"eventual", "eventual"}, levels(newConfig("eventual")))
+	require.Equal(t, []string{"none", "none"}, levels(newConfig("none")))
+	require.Equal(t, []string{"none", "none"}, levels(newConfig("")))
+
+	// the prop is not emitted if disabled.
+	codecConfig := newConfig("eventual")
+	codecConfig.EnableConsistencyLevel = false
+	require.Equal(t, []string{"", ""}, levels(codecConfig))
+	codecConfig = newConfig("eventual")
+	codecConfig.FeatureLevel = 2
+	require.Equal(t, []string{"", ""}, levels(codecConfig))
+}
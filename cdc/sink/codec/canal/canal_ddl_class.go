@@ -0,0 +1,112 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// ddlAffectsData classifies the DDL types into the data-affecting ones, which
+// change the existing rows, e.g. remove the rows or change the values of the
+// columns, and the metadata-only ones, which leave the existing rows as they
+// are, e.g. change the comment or the default value. The consumer may resync
+// the table after a data-affecting DDL, since the changes of the rows are not
+// replicated as row events. The types not listed are data-affecting, to be on
+// the safe side.
+var ddlAffectsData = map[mm.ActionType]bool{
+	mm.ActionCreateSchema:                  false,
+	mm.ActionDropSchema:                    true,
+	mm.ActionCreateTable:                   false,
+	mm.ActionDropTable:                     true,
+	mm.ActionAddColumn:                     true,
+	mm.ActionDropColumn:                    true,
+	mm.ActionAddIndex:                      false,
+	mm.ActionDropIndex:                     false,
+	mm.ActionAddForeignKey:                 false,
+	mm.ActionDropForeignKey:                false,
+	mm.ActionTruncateTable:                 true,
+	mm.ActionModifyColumn:                  true,
+	mm.ActionRebaseAutoID:                  false,
+	mm.ActionRenameTable:                   false,
+	mm.ActionSetDefaultValue:               false,
+	mm.ActionShardRowID:                    false,
+	mm.ActionModifyTableComment:            false,
+	mm.ActionRenameIndex:                   false,
+	mm.ActionAddTablePartition:             false,
+	mm.ActionDropTablePartition:            true,
+	mm.ActionCreateView:                    false,
+	mm.ActionModifyTableCharsetAndCollate:  false,
+	mm.ActionTruncateTablePartition:        true,
+	mm.ActionDropView:                      false,
+	mm.ActionRecoverTable:                  true,
+	mm.ActionModifySchemaCharsetAndCollate: false,
+	mm.ActionLockTable:                     false,
+	mm.ActionUnlockTable:                   false,
+	mm.ActionRepairTable:                   true,
+	mm.ActionSetTiFlashReplica:             false,
+	mm.ActionUpdateTiFlashReplicaStatus:    false,
+	mm.ActionAddPrimaryKey:                 false,
+	mm.ActionDropPrimaryKey:                false,
+	mm.ActionCreateSequence:                false,
+	mm.ActionAlterSequence:                 false,
+	mm.ActionDropSequence:                  false,
+	mm.ActionAddColumns:                    true,
+	mm.ActionDropColumns:                   true,
+	mm.ActionModifyTableAutoIdCache:        false,
+	mm.ActionRebaseAutoRandomBase:          false,
+	mm.ActionAlterIndexVisibility:          false,
+	mm.ActionExchangeTablePartition:        true,
+	mm.ActionAddCheckConstraint:            false,
+	mm.ActionDropCheckConstraint:           false,
+	mm.ActionAlterCheckConstraint:          false,
+	mm.ActionRenameTables:                  false,
+	mm.ActionDropIndexes:                   false,
+	mm.ActionAlterTableAttributes:          false,
+	mm.ActionAlterTablePartitionAttributes: false,
+	mm.ActionCreatePlacementPolicy:         false,
+	mm.ActionAlterPlacementPolicy:          false,
+	mm.ActionDropPlacementPolicy:           false,
+	mm.ActionAlterTablePartitionPlacement:  false,
+	mm.ActionModifySchemaDefaultPlacement:  false,
+	mm.ActionAlterTablePlacement:           false,
+	mm.ActionAlterCacheTable:               false,
+	mm.ActionAlterTableStatsOptions:        false,
+	mm.ActionAlterNoCacheTable:             false,
+	mm.ActionCreateTables:                  false,
+	// the multi-schema change may contain any of the changes above.
+	mm.ActionMultiSchemaChange: true,
+	mm.ActionFlashbackCluster:  true,
+}
+
+// isDataAffectingDDL returns whether the DDL of the type changes the existing
+// rows, see ddlAffectsData.
+func isDataAffectingDDL(tp mm.ActionType) bool {
+	affects, ok := ddlAffectsData[tp]
+	return !ok || affects
+}
+
+// appendDDLClassification stamps whether the DDL affects the existing rows
+// into the header props.
+func (b *canalEntryBuilder) appendDDLClassification(h *canal.Header, tp mm.ActionType) {
+	if !b.config.EnableDDLClassification || !b.featureEnabled(featureDDLClassification) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propAffectsData,
+		Value: strconv.FormatBool(isDataAffectingDDL(tp)),
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDDLClassification(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		tp          mm.ActionType
+		affectsData bool
+	}{
+		{tp: mm.ActionModifyTableComment, affectsData: false},
+		{tp: mm.ActionSetDefaultValue, affectsData: false},
+		{tp: mm.ActionAddIndex, affectsData: false},
+		{tp: mm.ActionRenameTable, affectsData: false},
+		{tp: mm.ActionCreateTable, affectsData: false},
+		{tp: mm.ActionAddTablePartition, affectsData: false},
+		{tp: mm.ActionAddColumn, affectsData: true},
+		{tp: mm.ActionDropColumn, affectsData: true},
+		{tp: mm.ActionModifyColumn, affectsData: true},
+		{tp: mm.ActionTruncateTable, affectsData: true},
+		{tp: mm.ActionDropTable, affectsData: true},
+		{tp: mm.ActionDropTablePartition, affectsData: true},
+		{tp: mm.ActionMultiSchemaChange, affectsData: true},
+		// the unknown types are data-affecting.
+		{tp: mm.ActionNone, affectsData: true},
+		{tp: mm.ActionType(255), affectsData: true},
+	}
+	for _, cs := range cases {
+		require.Equal(t, cs.affectsData, isDataAffectingDDL(cs.tp), cs.tp.String())
+	}
+
+	// affectsData returns the affectsData prop of the DDL entry,
+	// the absent prop is returned as empty.
+	affectsData := func(codecConfig *common.Config, tp mm.ActionType) string {
+		ddl := &model.DDLEvent{
+			CommitTs:  417318403368288260,
+			Query:     "alter table test.t comment 'test'",
+			Type:      tp,
+			TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+		}
+		entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propAffectsData {
+				return p.GetValue()
+			}
+		}
+		return ""
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableDDLClassification = true
+	require.Equal(t, "false", affectsData(codecConfig, mm.ActionModifyTableComment))
+	require.Equal(t, "true", affectsData(codecConfig, mm.ActionTruncateTable))
+
+	// the prop is not emitted if disabled.
+	require.Equal(t, "", affectsData(common.NewConfig(config.ProtocolCanal), mm.ActionTruncateTable))
+	codecConfig.FeatureLevel = 2
+	require.Equal(t, "", affectsData(codecConfig, mm.ActionTruncateTable))
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// The algorithms compressing the DDL query, zstd is only used with the
+// dictionary configured.
+const (
+	ddlCompressionGzip = "gzip"
+	ddlCompressionZstd = "zstd"
+)
+
+// compressDDLQuery compresses the query longer than the threshold configured,
+// and stamps the algorithm into the header props, so is the ID of the zstd
+// dictionary if it's configured. Since the sql field of the canal RowChange
+// is a string, the compressed query is encoded by base64.
+// Use DecodeDDLQuery to get the original query back.
+func (b *canalEntryBuilder) compressDDLQuery(h *canal.Header, query string) (string, error) {
+	threshold := b.config.DDLCompressionThreshold
+	if threshold == 0 || len(query) <= threshold ||
+		!b.featureEnabled(featureDDLCompression) {
+		return query, nil
+	}
+	if dict := b.config.DDLCompressionDictionary; dict != nil {
+		return compressDDLQueryZstd(h, query, dict)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(query)); err != nil {
+		return "", encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propDDLCompression,
+		Value: ddlCompressionGzip,
+	})
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func compressDDLQueryZstd(h *canal.Header, query string, dict []byte) (string, error) {
+	id, err := common.ZstdDictionaryID(dict)
+	if err != nil {
+		return "", encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	// the default level barely benefits from the dictionary for the short
+	// inputs like the queries, so the better level is used.
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderDict(dict),
+		zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		return "", encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	compressed := encoder.EncodeAll([]byte(query), nil)
+	if err := encoder.Close(); err != nil {
+		return "", encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	h.Props = append(h.Props,
+		&canal.Pair{Key: propDDLCompression, Value: ddlCompressionZstd},
+		&canal.Pair{Key: propDDLCompressionDict, Value: strconv.FormatUint(uint64(id), 10)},
+	)
+	return base64.StdEncoding.EncodeToString(compressed), nil
+}
+
+// DecodeDDLQuery returns the DDL query of the rowChange, which is inflated
+// if it's compressed as the `ddlCompression` prop of the header tells. The
+// query compressed by zstd is inflated with the dictionary of the ID the
+// `ddlCompressionDict` prop tells, which must be one of the dicts.
+func DecodeDDLQuery(
+	header *canal.Header, rowChange *canal.RowChange, dicts ...[]byte,
+) (string, error) {
+	algorithm, dictID := "", ""
+	for _, p := range header.GetProps() {
+		switch p.GetKey() {
+		case propDDLCompression:
+			algorithm = p.GetValue()
+		case propDDLCompressionDict:
+			dictID = p.GetValue()
+		}
+	}
+	switch algorithm {
+	case "":
+		return rowChange.GetSql(), nil
+	case ddlCompressionGzip, ddlCompressionZstd:
+	default:
+		return "", cerror.ErrCanalDecodeFailed.GenWithStack(
+			"unknown ddl compression %s", algorithm)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(rowChange.GetSql())
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+	if algorithm == ddlCompressionZstd {
+		return decompressDDLQueryZstd(data, dictID, dicts)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+	query, err := io.ReadAll(r)
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+	return string(query), nil
+}
+
+func decompressDDLQueryZstd(data []byte, dictID string, dicts [][]byte) (string, error) {
+	var dict []byte
+	for _, d := range dicts {
+		id, err := common.ZstdDictionaryID(d)
+		if err != nil {
+			return "", cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+		}
+		if strconv.FormatUint(uint64(id), 10) == dictID {
+			dict = d
+			break
+		}
+	}
+	if dict == nil {
+		return "", cerror.ErrCanalDecodeFailed.GenWithStack(
+			"zstd dictionary %s of the ddl compression not found", dictID)
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dict))
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+	defer decoder.Close()
+	query, err := decoder.DecodeAll(data, nil)
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+	return string(query), nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDDLCompression(t *testing.T) {
+	t.Parallel()
+
+	columns := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		columns = append(columns, fmt.Sprintf("c%d varchar(255) not null default ''", i))
+	}
+	query := "CREATE TABLE `test`.`t` (id bigint primary key, " +
+		strings.Join(columns, ", ") + ")"
+	ddl := &model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     query,
+		Type:      mm.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	msg, err := newBatchEncoder(codecConfig).EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	uncompressed := len(msg.Value)
+
+	codecConfig.DDLCompressionThreshold = 4096
+	msg, err = newBatchEncoder(codecConfig).EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	require.Less(t, len(msg.Value)*4, uncompressed)
+
+	decoder, err := NewPacketDecoder(msg.Value)
+	require.Nil(t, err)
+	tp, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	require.Equal(t, model.MessageTypeDDL, tp)
+	decoded, err := decoder.NextDDLEvent()
+	require.Nil(t, err)
+	require.Equal(t, query, decoded.Query)
+
+	// the query not longer than the threshold is not compressed.
+	entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(&model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     "CREATE TABLE `test`.`t` (id bigint primary key)",
+		Type:      mm.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	})
+	require.Nil(t, err)
+	for _, p := range entry.GetHeader().GetProps() {
+		require.NotEqual(t, propDDLCompression, p.GetKey())
+	}
+
+	// the query is not compressed for the old consumers.
+	codecConfig.FeatureLevel = 2
+	entry, err = newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+	require.Nil(t, err)
+	rc := &canal.RowChange{}
+	require.Nil(t, rc.Unmarshal(entry.GetStoreValue()))
+	require.Equal(t, query, rc.GetSql())
+}
+
+func TestDecodeDDLQuery(t *testing.T) {
+	t.Parallel()
+
+	rc := &canal.RowChange{Sql: "create table t (a int)"}
+	query, err := DecodeDDLQuery(&canal.Header{}, rc)
+	require.Nil(t, err)
+	require.Equal(t, "create table t (a int)", query)
+
+	header := &canal.Header{Props: []*canal.Pair{{Key: propDDLCompression, Value: "zstd"}}}
+	_, err = DecodeDDLQuery(header, rc)
+	require.True(t, errors.Is(err, cerror.ErrCanalDecodeFailed))
+
+	header = &canal.Header{Props: []*canal.Pair{{Key: propDDLCompression, Value: ddlCompressionGzip}}}
+	_, err = DecodeDDLQuery(header, rc)
+	require.True(t, errors.Is(err, cerror.ErrCanalDecodeFailed))
+}
+
+func TestDDLCompressionDictionary(t *testing.T) {
+	t.Parallel()
+
+	dict, err := os.ReadFile("testdata/ddl_compression.dict")
+	require.Nil(t, err)
+	id, err := common.ZstdDictionaryID(dict)
+	require.Nil(t, err)
+
+	query := "CREATE TABLE `test`.`orders_1024` (id bigint primary key, name varchar(64), " +
+		"email varchar(255), created_at datetime) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin"
+	ddl := &model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     query,
+		Type:      mm.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "orders_1024"}},
+	}
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.DDLCompressionThreshold = 64
+	codecConfig.DDLCompressionDictionary = dict
+	entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+	require.Nil(t, err)
+	require.Contains(t, entry.GetHeader().GetProps(),
+		&canal.Pair{Key: propDDLCompression, Value: ddlCompressionZstd})
+	require.Contains(t, entry.GetHeader().GetProps(),
+		&canal.Pair{Key: propDDLCompressionDict, Value: fmt.Sprint(id)})
+	rc := &canal.RowChange{}
+	require.Nil(t, rc.Unmarshal(entry.GetStoreValue()))
+	require.Less(t, len(rc.GetSql())*2, len(query))
+
+	// the dictionary is needed to decompress it.
+	decoded, err := DecodeDDLQuery(entry.GetHeader(), rc, dict)
+	require.Nil(t, err)
+	require.Equal(t, query, decoded)
+	_, err = DecodeDDLQuery(entry.GetHeader(), rc)
+	require.ErrorContains(t, err, "not found")
+
+	msg, err := newBatchEncoder(codecConfig).EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	decoder, err := NewPacketDecoder(msg.Value, WithDDLCompressionDictionaries(dict))
+	require.Nil(t, err)
+	_, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	event, err := decoder.NextDDLEvent()
+	require.Nil(t, err)
+	require.Equal(t, query, event.Query)
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+)
+
+// normalizeDDLQuery makes the DDL query single-line, by collapsing the runs of
+// whitespace into a single space and stripping the comments. The string
+// literals and the quoted identifiers are kept as is, and so are the
+// executable comments, i.e. `/*! ... */` and `/*T! ... */`, since they are
+// part of the statement.
+func normalizeDDLQuery(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query))
+	pendingSpace := false
+	write := func(s string) {
+		if pendingSpace && sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		pendingSpace = false
+		sb.WriteString(s)
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case isSpace(c):
+			pendingSpace = true
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			end := quotedEnd(query, i)
+			write(query[i:end])
+			i = end
+		case c == '#' || strings.HasPrefix(query[i:], "--") &&
+			(i+2 == len(query) || isSpace(query[i+2])):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			pendingSpace = true
+			i += end
+		case strings.HasPrefix(query[i:], "/*!"):
+			write("/*!")
+			i += 3
+		case strings.HasPrefix(query[i:], "/*T!"):
+			write("/*T!")
+			i += 4
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			} else {
+				end += 2
+			}
+			pendingSpace = true
+			i += end + 2
+		default:
+			write(query[i : i+1])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// quotedEnd returns the end offset of the string literal or the quoted
+// identifier starting at the offset start. The quote is escaped by doubling
+// it, and by the backslash except in the quoted identifier.
+func quotedEnd(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f', '\v':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDDLQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "  create table t (\n\tid int\n)  ",
+			expected: "create table t ( id int )",
+		},
+		{
+			query:    "create table t (a varchar(10) default 'x\n  y') -- the comment\n",
+			expected: "create table t (a varchar(10) default 'x\n  y')",
+		},
+		{
+			query:    "create table t (a int comment 'it''s  a\\' \"b\"' # comment\n, b int)",
+			expected: "create table t (a int comment 'it''s  a\\' \"b\"' , b int)",
+		},
+		{
+			query:    "create table `t  1`/* comment\n */(a int)",
+			expected: "create table `t  1` (a int)",
+		},
+		{
+			query:    "create table t (id bigint /*T![auto_rand] AUTO_RANDOM(5) */ primary key)",
+			expected: "create table t (id bigint /*T![auto_rand] AUTO_RANDOM(5) */ primary key)",
+		},
+		{
+			query:    "select 1--1",
+			expected: "select 1--1",
+		},
+	}
+	for _, cs := range cases {
+		require.Equal(t, cs.expected, normalizeDDLQuery(cs.query))
+	}
+}
+
+func TestEncodeNormalizedDDLQuery(t *testing.T) {
+	t.Parallel()
+
+	query := "CREATE TABLE person (\n" +
+		"\tid INT PRIMARY KEY, -- the id\n" +
+		"\tname VARCHAR(64) DEFAULT 'first line\n\tsecond line'\n" +
+		")"
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288260,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "cdc", Table: "person"},
+		},
+		Query: query,
+		Type:  mm.ActionCreateTable,
+	}
+	encode := func(normalize bool) string {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.NormalizeDDLQuery = normalize
+		entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		return rc.GetSql()
+	}
+
+	// the original text is preserved by default.
+	require.Equal(t, query, encode(false))
+	require.Equal(t, "CREATE TABLE person ( id INT PRIMARY KEY, "+
+		"name VARCHAR(64) DEFAULT 'first line\n\tsecond line' )", encode(true))
+}
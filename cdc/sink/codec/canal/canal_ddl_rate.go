@@ -0,0 +1,74 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+
+	"github.com/benbjohnson/clock"
+	"github.com/pingcap/errors"
+	"golang.org/x/time/rate"
+)
+
+// ddlLimiter limits the rate of the DDL events by a token bucket, which holds
+// a token only, so the DDL events are spaced evenly at the rate. It's shared
+// by all the encoders built by the same builder, since the sink builds an
+// encoder for each DDL event.
+type ddlLimiter struct {
+	limiter *rate.Limiter
+	// clock is replaced in the tests.
+	clock clock.Clock
+}
+
+// newDDLLimiter returns nil if the rate is not limited.
+func newDDLLimiter(perSecond float64, clk clock.Clock) *ddlLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &ddlLimiter{
+		limiter: rate.NewLimiter(rate.Limit(perSecond), 1),
+		clock:   clk,
+	}
+}
+
+// wait blocks until the next DDL event is allowed, or the context is done.
+// The token is reserved on call, so the DDL events are allowed in the order
+// they call it.
+func (l *ddlLimiter) wait(ctx context.Context) error {
+	now := l.clock.Now()
+	r := l.limiter.ReserveN(now, 1)
+	delay := r.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+	timer := l.clock.Timer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		// return the token, so the next DDL event does not wait for it.
+		r.CancelAt(l.clock.Now())
+		return errors.Trace(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// WaitDDL implements the DDLThrottledEncoder interface, it waits only if the
+// max DDL events per second is set.
+func (d *BatchEncoder) WaitDDL(ctx context.Context) error {
+	if d.ddlLimiter == nil {
+		return nil
+	}
+	return d.ddlLimiter.wait(ctx)
+}
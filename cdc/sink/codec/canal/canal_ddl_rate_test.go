@@ -0,0 +1,116 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanalBatchEncoderMaxDDLPerSecond(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.MaxDDLPerSecond = 2
+	mockClock := clock.NewMock()
+	state := newEncoderState(codecConfig)
+	state.ddlLimiter = newDDLLimiter(codecConfig.MaxDDLPerSecond, mockClock)
+	// the sink builds an encoder for each DDL event.
+	build := func() codec.EventBatchEncoder {
+		return newBatchEncoderWithState(codecConfig, state, newEncoderOptions())
+	}
+
+	// emit waits for the DDL event to be allowed while advancing the clock,
+	// then encodes it, and returns the time it's emitted at with its query.
+	const step = 10 * time.Millisecond
+	emit := func(query string) (time.Time, string) {
+		encoder := build()
+		done := make(chan error, 1)
+		go func() {
+			done <- encoder.(codec.DDLThrottledEncoder).WaitDDL(context.Background())
+		}()
+		for {
+			select {
+			case err := <-done:
+				require.NoError(t, err)
+				msg, err := encoder.EncodeDDLEvent(&model.DDLEvent{
+					CommitTs: 417318403368288260,
+					TableInfo: &model.TableInfo{
+						TableName: model.TableName{Schema: "test", Table: "t"},
+					},
+					Query: query,
+					Type:  mm.ActionAddColumn,
+				})
+				require.NoError(t, err)
+				return mockClock.Now(), decodeDDLQuery(t, msg)
+			case <-time.After(time.Millisecond):
+				mockClock.Add(step)
+			}
+		}
+	}
+
+	// the first DDL event is emitted without waiting.
+	start := mockClock.Now()
+	require.NoError(t, build().(codec.DDLThrottledEncoder).WaitDDL(context.Background()))
+
+	// the DDL events are emitted at the rate, in the order they arrive.
+	last := start
+	for i := 0; i < 4; i++ {
+		query := fmt.Sprintf("alter table t add column c%d int", i)
+		emittedAt, emitted := emit(query)
+		require.Equal(t, query, emitted)
+		require.GreaterOrEqual(t, emittedAt.Sub(last), 500*time.Millisecond)
+		last = emittedAt
+	}
+	require.GreaterOrEqual(t, last.Sub(start), 2*time.Second)
+	require.Less(t, last.Sub(start), 2*time.Second+10*step)
+
+	// the wait is canceled with the context, and the token is returned.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := build().(codec.DDLThrottledEncoder).WaitDDL(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the DDL events are not limited if not set.
+	encoder := newBatchEncoder(common.NewConfig(config.ProtocolCanal))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, encoder.(codec.DDLThrottledEncoder).WaitDDL(context.Background()))
+	}
+}
+
+// decodeDDLQuery returns the query of the DDL message.
+func decodeDDLQuery(t *testing.T, msg *common.Message) string {
+	packet := &canal.Packet{}
+	require.NoError(t, proto.Unmarshal(msg.Value, packet))
+	messages := &canal.Messages{}
+	require.NoError(t, proto.Unmarshal(packet.GetBody(), messages))
+	require.Len(t, messages.GetMessages(), 1)
+	entry := &canal.Entry{}
+	require.NoError(t, proto.Unmarshal(messages.GetMessages()[0], entry))
+	rc := &canal.RowChange{}
+	require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+	return rc.GetSql()
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/tidb/types"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// appendDeclaredType stamps the type declared in the schema of the column
+// into the props, which is the COLUMN_TYPE in the information schema, e.g.
+// `varchar(255)` or `enum('a','b')`, while the mysqlType of the column is the
+// canonical one without the arguments, e.g. `varchar`. It's omitted if the
+// schema of the table is unknown. The DDL entry carries no column, and the
+// declared types are in its query.
+func (b *canalEntryBuilder) appendDeclaredType(column *canal.Column, ft *types.FieldType) {
+	if !b.config.EnableDeclaredType || ft == nil ||
+		!b.featureEnabled(featureDeclaredType) {
+		return
+	}
+	column.Props = append(column.Props, &canal.Pair{
+		Key:   propDeclaredType,
+		Value: ft.InfoSchemaStr(),
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclaredType(t *testing.T) {
+	t.Parallel()
+
+	newColumn := func(name string, ft *types.FieldType) *mm.ColumnInfo {
+		return &mm.ColumnInfo{
+			Name:      mm.NewCIStr(name),
+			FieldType: *ft,
+			State:     mm.StatePublic,
+		}
+	}
+	id := types.NewFieldType(mysql.TypeLong)
+	id.SetFlen(10)
+	id.AddFlag(mysql.UnsignedFlag)
+	name := types.NewFieldType(mysql.TypeVarchar)
+	name.SetFlen(255)
+	status := types.NewFieldType(mysql.TypeEnum)
+	status.SetElems([]string{"active", "inactive"})
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "person"},
+		TableInfo: model.WrapTableInfo(1, "cdc", 1, &mm.TableInfo{
+			Name: mm.NewCIStr("person"),
+			Columns: []*mm.ColumnInfo{
+				newColumn("id", id),
+				newColumn("name", name),
+				newColumn("status", status),
+			},
+		}),
+		Columns: []*model.Column{
+			{
+				Name:  "id",
+				Type:  mysql.TypeLong,
+				Flag:  model.PrimaryKeyFlag | model.UnsignedFlag,
+				Value: uint64(1),
+			},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+			{Name: "status", Type: mysql.TypeEnum, Value: uint64(1)},
+		},
+	}
+	encode := func(codecConfig *common.Config) map[string]*canal.Column {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(event)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		result := make(map[string]*canal.Column)
+		for _, column := range rc.GetRowDatas()[0].GetAfterColumns() {
+			result[column.GetName()] = column
+		}
+		return result
+	}
+	declaredType := func(column *canal.Column) string {
+		for _, p := range column.GetProps() {
+			if p.GetKey() == propDeclaredType {
+				return p.GetValue()
+			}
+		}
+		return ""
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableDeclaredType = true
+	columns := encode(codecConfig)
+	require.Equal(t, "int unsigned", columns["id"].GetMysqlType())
+	require.Equal(t, "int(10) unsigned", declaredType(columns["id"]))
+	require.Equal(t, "varchar", columns["name"].GetMysqlType())
+	require.Equal(t, "varchar(255)", declaredType(columns["name"]))
+	require.Equal(t, "enum", columns["status"].GetMysqlType())
+	require.Equal(t, "enum('active','inactive')", declaredType(columns["status"]))
+
+	// the prop is not emitted if disabled, or for the old consumers.
+	for _, column := range encode(common.NewConfig(config.ProtocolCanal)) {
+		require.Empty(t, declaredType(column))
+	}
+	codecConfig.FeatureLevel = 2
+	for _, column := range encode(codecConfig) {
+		require.Empty(t, declaredType(column))
+	}
+
+	// the prop is omitted if the schema of the table is unknown.
+	codecConfig.FeatureLevel = common.NewConfig(config.ProtocolCanal).FeatureLevel
+	event.TableInfo = nil
+	for _, column := range encode(codecConfig) {
+		require.Empty(t, declaredType(column))
+	}
+}
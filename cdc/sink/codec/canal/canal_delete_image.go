@@ -0,0 +1,25 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import "github.com/pingcap/tiflow/cdc/model"
+
+// deleteImageCompat returns whether the row is a DELETE emitted with the
+// image of the open protocol, which carries all the columns of the old image
+// and renders the null columns as nulls. So the old image is neither shrunk
+// by the key-only and shrink-old-image options, nor are the nulls rendered
+// by the null-representation option.
+func (b *canalEntryBuilder) deleteImageCompat(e *model.RowChangedEvent) bool {
+	return b.config.DeleteImageCompat && e.IsDelete()
+}
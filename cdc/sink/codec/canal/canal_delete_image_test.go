@@ -0,0 +1,138 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/codec/open"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteImage is the old image of a DELETE, the value of each column by
+// name, nil for the null columns.
+type deleteImage map[string]*string
+
+func TestDeleteImageCompat(t *testing.T) {
+	t.Parallel()
+
+	keyFlag := model.HandleKeyFlag | model.PrimaryKeyFlag
+	deleteEvent := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "person"},
+		PreColumns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: keyFlag, Value: 1},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "Alice"},
+			{Name: "email", Type: mysql.TypeVarchar, Value: nil},
+			nil,
+		},
+	}
+
+	// openImage returns the old image of the DELETE in the open protocol.
+	openImage := func(e *model.RowChangedEvent) deleteImage {
+		encoder := open.NewBatchEncoderBuilder(common.NewConfig(config.ProtocolOpen)).Build()
+		err := encoder.AppendRowChangedEvent(context.Background(), "", e, nil)
+		require.NoError(t, err)
+		messages, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		decoder, err := open.NewBatchDecoder(messages[0].Key, messages[0].Value)
+		require.NoError(t, err)
+		tp, ok, err := decoder.HasNext()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, model.MessageTypeRow, tp)
+		decoded, err := decoder.NextRowChangedEvent()
+		require.NoError(t, err)
+		require.True(t, decoded.IsDelete())
+		require.Empty(t, decoded.Columns)
+
+		result := make(deleteImage)
+		for _, column := range decoded.PreColumns {
+			switch v := column.Value.(type) {
+			case nil:
+				result[column.Name] = nil
+			case []byte:
+				value := string(v)
+				result[column.Name] = &value
+			default:
+				value := fmt.Sprint(v)
+				result[column.Name] = &value
+			}
+		}
+		return result
+	}
+	// canalImage returns the old image of the DELETE in the canal protocol.
+	canalImage := func(codecConfig *common.Config, e *model.RowChangedEvent) deleteImage {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.NoError(t, err)
+		rc := &canal.RowChange{}
+		require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		require.Equal(t, canal.EventType_DELETE, rc.GetEventType())
+		rowData := rc.GetRowDatas()[0]
+		require.Empty(t, rowData.GetAfterColumns())
+
+		result := make(deleteImage)
+		for _, column := range rowData.GetBeforeColumns() {
+			if column.GetIsNull() {
+				require.Empty(t, column.GetValue())
+				result[column.GetName()] = nil
+				continue
+			}
+			value := column.GetValue()
+			result[column.GetName()] = &value
+		}
+		return result
+	}
+
+	expected := openImage(deleteEvent)
+	require.Len(t, expected, 3)
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.NullRepresentation = "NULL"
+	codecConfig.KeyOnly = true
+	codecConfig.ShrinkOldImage = true
+
+	// the options shrink the old image and render the nulls.
+	image := canalImage(codecConfig, deleteEvent)
+	require.NotEqual(t, expected, image)
+	require.Len(t, image, 1)
+
+	// the DELETE carries the same image as the open protocol.
+	codecConfig.DeleteImageCompat = true
+	require.Equal(t, expected, canalImage(codecConfig, deleteEvent))
+
+	// the other rows are not affected.
+	updateEvent := &model.RowChangedEvent{
+		CommitTs:   deleteEvent.CommitTs,
+		Table:      deleteEvent.Table,
+		PreColumns: deleteEvent.PreColumns,
+		Columns:    deleteEvent.PreColumns,
+	}
+	entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(updateEvent)
+	require.NoError(t, err)
+	rc := &canal.RowChange{}
+	require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+	rowData := rc.GetRowDatas()[0]
+	require.Len(t, rowData.GetBeforeColumns(), 1)
+	require.Len(t, rowData.GetAfterColumns(), 1)
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// emptyBatchID is the batch id of the empty batch, which is the one the
+// canal server returns if there is nothing to get.
+const emptyBatchID = -1
+
+// emptyBatchMarker returns the message of the empty batch marker, it's a
+// packet of no entry whose batch id is emptyBatchID, so that the consumer
+// resets its timers on it. Unlike the heartbeat and the watermark, it
+// carries no time, and it's emitted only if nothing else is built.
+func (d *BatchEncoder) emptyBatchMarker() (*common.Message, error) {
+	body, err := proto.Marshal(&canal.Messages{BatchId: emptyBatchID})
+	if err != nil {
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	packet := &canal.Packet{
+		VersionPresent: &canal.Packet_Version{
+			Version: CanalPacketVersion,
+		},
+		Type: canal.PacketType_MESSAGES,
+		Body: body,
+	}
+	value, err := proto.Marshal(packet)
+	if err != nil {
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	return common.NewMsg(config.ProtocolCanal, nil, d.frame(value), 0,
+		model.MessageTypeUnknown, nil, nil), nil
+}
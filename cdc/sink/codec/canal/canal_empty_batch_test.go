@@ -0,0 +1,71 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmptyBatchMarker(t *testing.T) {
+	t.Parallel()
+
+	// nothing is built by default.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	msgs, err := newBatchEncoder(codecConfig).Build()
+	require.NoError(t, err)
+	require.Nil(t, msgs)
+
+	codecConfig.EnableEmptyBatchMarker = true
+	encoder := newBatchEncoder(codecConfig)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, model.MessageTypeUnknown, msgs[0].Type)
+	require.Zero(t, msgs[0].GetRowsCount())
+
+	packet := &canal.Packet{}
+	require.Nil(t, proto.Unmarshal(msgs[0].Value, packet))
+	require.Equal(t, canal.PacketType_MESSAGES, packet.GetType())
+	messages := &canal.Messages{}
+	require.Nil(t, proto.Unmarshal(packet.GetBody(), messages))
+	require.Equal(t, int64(emptyBatchID), messages.GetBatchId())
+	require.Empty(t, messages.GetMessages())
+
+	// the consumer sees no event in it.
+	decoder, err := NewPacketDecoder(msgs[0].Value)
+	require.Nil(t, err)
+	_, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.False(t, hasNext)
+
+	// the marker is not emitted if anything is built.
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", testCaseInsert, nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, model.MessageTypeRow, msgs[0].Type)
+	// but emitted again on the next empty flush.
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, model.MessageTypeUnknown, msgs[0].Type)
+}
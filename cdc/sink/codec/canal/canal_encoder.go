@@ -15,17 +15,18 @@ package canal
 
 import (
 	"context"
+	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/golang/protobuf/proto"
 	"github.com/pingcap/errors"
-	"github.com/pingcap/log"
 	"github.com/pingcap/tiflow/cdc/model"
 	"github.com/pingcap/tiflow/cdc/sink/codec"
 	"github.com/pingcap/tiflow/cdc/sink/codec/common"
 	"github.com/pingcap/tiflow/pkg/config"
 	cerror "github.com/pingcap/tiflow/pkg/errors"
 	canal "github.com/pingcap/tiflow/proto/canal"
-	"go.uber.org/zap"
+	"github.com/tikv/client-go/v2/oracle"
 )
 
 // BatchEncoder encodes the events into the byte of a batch into.
@@ -34,53 +35,261 @@ type BatchEncoder struct {
 	callbackBuf  []func()
 	packet       *canal.Packet
 	entryBuilder *canalEntryBuilder
+	serializer   Serializer
+	config       *common.Config
+
+	// keyed makes each row a standalone message keyed by the row key,
+	// keyedMessages holds the messages, they are only used when the
+	// tombstone is enabled or the key serializer is provided.
+	keyed         bool
+	keyedMessages []*common.Message
+
+	// activeTables tracks the tables to emit the watermark for,
+	// watermarks holds the table-scoped watermark messages,
+	// they are only used when the table watermark is enabled.
+	activeTables *activeTables
+	watermarks   []*common.Message
+
+	// pendingRows holds the rows until Build, lastTxns holds the last
+	// transaction of each table built, they are only used when the
+	// transaction row count is enabled.
+	pendingRows []pendingRow
+	lastTxns    map[model.TableName]txnKey
+
+	// group holds the inserts to be emitted as a single entry,
+	// it is only used when the insert grouping is enabled.
+	group *insertGroup
+
+	// pendingCallbacks is the number of the callbacks held until Build.
+	pendingCallbacks int
+
+	// maxCommitTs is the max commit ts of the rows in the batch.
+	maxCommitTs uint64
+
+	// account accounts the bytes of the entries held until Build in the
+	// budget shared with the other encoders, nil if there is no budget.
+	account codec.ByteAccount
+
+	// ddlLimiter limits the rate of the DDL events along with the other
+	// encoders, nil if the rate is not limited.
+	ddlLimiter *ddlLimiter
+
+	// deadLetter shunts the row failing to encode, nil if the encoding
+	// error is returned.
+	deadLetter DeadLetterHook
 }
 
+// DeadLetterHook receives the row failing to encode and the last error of it,
+// the error returned fails the encoding instead. It's only provided by the
+// callers building the canal encoders themselves, see WithDeadLetter.
+type DeadLetterHook func(event *model.RowChangedEvent, err error) error
+
 // EncodeCheckpointEvent implements the EventBatchEncoder interface
 func (d *BatchEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error) {
 	// For canal now, there is no such a corresponding type to ResolvedEvent so far.
-	// Therefore, the event is ignored.
+	// Therefore, the event is ignored, unless it's fanned out into the
+	// table-scoped watermarks, which are returned by Build.
+	if !d.config.EnableTableWatermark {
+		return nil, nil
+	}
+	for _, table := range d.activeTables.drain() {
+		entry := d.entryBuilder.fromWatermark(ts, table)
+		b, err := d.serializer.Serialize(entry)
+		if err != nil {
+			return nil, serializeError(err)
+		}
+		value, err := encodeSingleEntryPacket(b)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		msg := common.NewResolvedMsg(config.ProtocolCanal, nil, d.frame(value), ts)
+		d.stampTimestamp(msg, ts)
+		schema, tableName := table.Schema, table.Table
+		msg.Schema, msg.Table = &schema, &tableName
+		d.watermarks = append(d.watermarks, msg)
+	}
 	return nil, nil
 }
 
-// AppendRowChangedEvent implements the EventBatchEncoder interface
+// AppendRowChangedEvent implements the EventBatchEncoder interface.
+// If the DeadLetterHook is provided, the row failing to encode is handed to
+// the hook and skipped, whose callback is called at once, since the row is
+// done with. The retryable failure is retried by the DeadLetterRetries before
+// that.
 func (d *BatchEncoder) AppendRowChangedEvent(
 	_ context.Context,
 	_ string,
 	e *model.RowChangedEvent,
 	callback func(),
 ) error {
-	entry, err := d.entryBuilder.fromRowEvent(e)
-	if err != nil {
+	e = d.entryBuilder.softDelete(e)
+	// the row is built once, since building it has the side effects, e.g. the
+	// sequence stamped, and only the appending of it is retried.
+	header, rowData, err := d.entryBuilder.buildRow(e)
+	if err == nil {
+		err = d.appendBuiltRow(e, header, rowData, callback)
+		for i := 0; err != nil && d.deadLetter != nil && retryable(err) &&
+			i < d.config.DeadLetterRetries; i++ {
+			err = d.appendBuiltRow(e, header, rowData, callback)
+		}
+	}
+	if err == nil || d.deadLetter == nil {
 		return errors.Trace(err)
 	}
-	b, err := proto.Marshal(entry)
+	if err := d.deadLetter(e, err); err != nil {
+		return errors.Trace(err)
+	}
+	if callback != nil {
+		callback()
+	}
+	return nil
+}
+
+// appendBuiltRow appends the row built to the batch, it leaves the batch
+// unchanged if it fails, so that it can be retried.
+func (d *BatchEncoder) appendBuiltRow(
+	e *model.RowChangedEvent, header *canal.Header, rowData *canal.RowData, callback func(),
+) error {
+	if d.config.EnableTxnRowCount {
+		if err := d.checkPendingRow(header, rowData); err != nil {
+			return errors.Trace(err)
+		}
+		d.pendingRows = append(d.pendingRows, pendingRow{
+			event:    e,
+			header:   header,
+			rowData:  rowData,
+			callback: callback,
+		})
+	} else if err := d.appendRow(e, header, rowData, callback); err != nil {
+		return errors.Trace(err)
+	}
+	if callback != nil {
+		d.pendingCallbacks++
+	}
+	return nil
+}
+
+// appendEntry appends the entry of the row to the batch.
+func (d *BatchEncoder) appendEntry(
+	e *model.RowChangedEvent, entry *Entry, callback func(),
+) error {
+	b, err := d.serializer.Serialize(entry)
 	if err != nil {
-		return cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		return serializeError(err)
+	}
+	// the entry is checked alone, since it can not be split into messages.
+	if err := checkMessageSize(len(b), d.config); err != nil {
+		return errors.Trace(err)
+	}
+	if d.account != nil {
+		d.account.Add(len(b))
+	}
+	if d.config.EnableTableWatermark {
+		d.activeTables.add(e.Table)
+	}
+	if d.keyed {
+		return d.appendKeyedRow(e, b, callback)
 	}
 	d.messages.Messages = append(d.messages.Messages, b)
+	if e.CommitTs > d.maxCommitTs {
+		d.maxCommitTs = e.CommitTs
+	}
 	if callback != nil {
 		d.callbackBuf = append(d.callbackBuf, callback)
 	}
 	return nil
 }
 
+// ShouldFlush implements the FlushHintEncoder interface, it returns true if the
+// callbacks held reach the max pending callbacks, since each of them pins the
+// memory of the event in the sink until the batch is built and sent. It also
+// returns true if the encoder is picked to flush by the shared byte budget.
+func (d *BatchEncoder) ShouldFlush() bool {
+	if d.config.MaxPendingCallbacks > 0 &&
+		d.pendingCallbacks >= d.config.MaxPendingCallbacks {
+		return true
+	}
+	return d.account != nil && d.account.ShouldFlush()
+}
+
+// EstimateSize approximates the size of the encoded event without encoding it,
+// see canalEntryBuilder.EstimateSize for the accuracy.
+func (d *BatchEncoder) EstimateSize(e *model.RowChangedEvent) int {
+	return d.entryBuilder.EstimateSize(e)
+}
+
 // EncodeDDLEvent implements the EventBatchEncoder interface
 func (d *BatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error) {
-	entry, err := d.entryBuilder.fromDDLEvent(e)
+	entry, err := d.entryBuilder.buildDDLEntry(e)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	b, err := d.serializer.Serialize(entry)
 	if err != nil {
+		return nil, serializeError(err)
+	}
+
+	b, err = encodeSingleEntryPacket(b)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := checkMessageSize(len(b), d.config); err != nil {
 		return nil, errors.Trace(err)
 	}
-	b, err := proto.Marshal(entry)
+
+	msg := common.NewDDLMsg(config.ProtocolCanal, nil, d.frame(b), e)
+	d.stampTimestamp(msg, e.CommitTs)
+	return msg, nil
+}
+
+// appendKeyedRow wraps the entry into a standalone packet keyed by the row key,
+// if the row is deleted and the tombstone is enabled, a tombstone with the
+// same key is appended after it, so that the broker can purge the row from a
+// log-compacted topic.
+func (d *BatchEncoder) appendKeyedRow(
+	e *model.RowChangedEvent, entry []byte, callback func(),
+) error {
+	key, err := d.entryBuilder.rowKey(e)
 	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		return errors.Trace(err)
+	}
+	value, err := encodeSingleEntryPacket(entry)
+	if err != nil {
+		return errors.Trace(err)
 	}
 
+	msg := common.NewMsg(config.ProtocolCanal, key, d.frame(value), e.CommitTs,
+		model.MessageTypeRow, &e.Table.Schema, &e.Table.Table)
+	msg.SetRowsCount(1)
+	d.stampTimestamp(msg, e.CommitTs)
+	d.keyedMessages = append(d.keyedMessages, msg)
+
+	if e.IsDelete() && d.config.EnableTombstone {
+		msg = common.NewMsg(config.ProtocolCanal, key, nil, e.CommitTs,
+			model.MessageTypeRow, &e.Table.Schema, &e.Table.Table)
+		d.stampTimestamp(msg, e.CommitTs)
+		d.keyedMessages = append(d.keyedMessages, msg)
+	}
+	// the callback is attached to the last message of the row.
+	msg.Callback = callback
+	return nil
+}
+
+// frame frames the packet if the packet framing is enabled, see framePacket.
+func (d *BatchEncoder) frame(packet []byte) []byte {
+	if !d.config.EnablePacketFraming {
+		return packet
+	}
+	return framePacket(packet)
+}
+
+// encodeSingleEntryPacket wraps the marshalled entry into a canal packet.
+func encodeSingleEntryPacket(entry []byte) ([]byte, error) {
 	messages := new(canal.Messages)
-	messages.Messages = append(messages.Messages, b)
-	b, err = messages.Marshal()
+	messages.Messages = append(messages.Messages, entry)
+	b, err := messages.Marshal()
 	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
 	}
 
 	packet := &canal.Packet{
@@ -92,30 +301,86 @@ func (d *BatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error
 	packet.Body = b
 	b, err = packet.Marshal()
 	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
 	}
-
-	return common.NewDDLMsg(config.ProtocolCanal, nil, b, e), nil
+	return b, nil
 }
 
 // Build implements the EventBatchEncoder interface
-func (d *BatchEncoder) Build() []*common.Message {
+func (d *BatchEncoder) Build() ([]*common.Message, error) {
+	if len(d.pendingRows) != 0 {
+		if err := d.flushPendingRows(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if err := d.flushGroup(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	d.pendingCallbacks = 0
+	if d.account != nil {
+		d.account.Reset()
+	}
+	ret, err := d.buildRows()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(d.watermarks) != 0 {
+		ret = append(ret, d.watermarks...)
+		d.watermarks = nil
+	}
+	if len(ret) == 0 && d.config.EnableEmptyBatchMarker {
+		marker, err := d.emptyBatchMarker()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		ret = append(ret, marker)
+	}
+	return ret, nil
+}
+
+// stampTimestamp sets the timestamp of the message to the physical time of
+// the ts plus the CommitTsOffset, if the timestamp of the records is the
+// commit ts. For the message batching the rows, the ts is the max commit ts
+// of them.
+func (d *BatchEncoder) stampTimestamp(msg *common.Message, ts uint64) {
+	if d.config.MessageTimestamp != common.MessageTimestampCommitTs {
+		return
+	}
+	msg.Timestamp = oracle.GetTimeFromTS(ts).Add(d.config.CommitTsOffset)
+}
+
+// buildRows builds the messages of the row changed events.
+func (d *BatchEncoder) buildRows() ([]*common.Message, error) {
+	if d.keyed {
+		if len(d.keyedMessages) == 0 {
+			return nil, nil
+		}
+		ret := d.keyedMessages
+		d.keyedMessages = nil
+		return ret, nil
+	}
+
 	rowCount := len(d.messages.Messages)
 	if rowCount == 0 {
-		return nil
+		return nil, nil
 	}
 
+	if err := d.appendBatchEnd(rowCount); err != nil {
+		return nil, errors.Trace(err)
+	}
 	err := d.refreshPacketBody()
 	if err != nil {
-		log.Panic("Error when generating Canal packet", zap.Error(err))
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
 	}
 
 	value, err := proto.Marshal(d.packet)
 	if err != nil {
-		log.Panic("Error when serializing Canal packet", zap.Error(err))
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
 	}
-	ret := common.NewMsg(config.ProtocolCanal, nil, value, 0, model.MessageTypeRow, nil, nil)
+	ret := common.NewMsg(config.ProtocolCanal, nil, d.frame(value), 0, model.MessageTypeRow, nil, nil)
 	ret.SetRowsCount(rowCount)
+	d.stampTimestamp(ret, d.maxCommitTs)
+	d.maxCommitTs = 0
 	d.messages.Reset()
 	d.resetPacket()
 
@@ -128,7 +393,7 @@ func (d *BatchEncoder) Build() []*common.Message {
 		}
 		d.callbackBuf = make([]func(), 0)
 	}
-	return []*common.Message{ret}
+	return []*common.Message{ret}, nil
 }
 
 // refreshPacketBody() marshals the messages to the packet body
@@ -155,26 +420,198 @@ func (d *BatchEncoder) resetPacket() {
 	}
 }
 
+// encoderState is the state shared by all the encoders built by the same
+// builder, since the events of a changefeed are encoded by different encoders.
+type encoderState struct {
+	activeTables *activeTables
+	sequencer    *sequencer
+	seenKeys     *seenKeys
+	// budget is nil if the max buffered bytes is not set.
+	budget codec.ByteBudget
+	// ddlLimiter is nil if the max DDL events per second is not set.
+	ddlLimiter *ddlLimiter
+}
+
+func newEncoderState(config *common.Config) *encoderState {
+	state := &encoderState{
+		activeTables: newActiveTables(),
+		sequencer:    newSequencer(),
+		seenKeys:     newSeenKeys(config),
+		ddlLimiter:   newDDLLimiter(config.MaxDDLPerSecond, clock.New()),
+	}
+	if config.MaxBufferedBytes > 0 {
+		state.budget = codec.NewByteBudget(config.MaxBufferedBytes)
+	}
+	return state
+}
+
 // newBatchEncoder creates a new canalBatchEncoder.
-func newBatchEncoder() codec.EventBatchEncoder {
+func newBatchEncoder(config *common.Config) codec.EventBatchEncoder {
+	return newBatchEncoderWithState(config, newEncoderState(config), newEncoderOptions())
+}
+
+// newBatchEncoderWithState creates a new canalBatchEncoder which
+// shares the given state with the other encoders.
+func newBatchEncoderWithState(
+	config *common.Config, state *encoderState, op *encoderOptions,
+) codec.EventBatchEncoder {
+	entryBuilder := newCanalEntryBuilder(config)
+	entryBuilder.sequencer = state.sequencer
+	entryBuilder.seenKeys = state.seenKeys
+	entryBuilder.enrichments = op.enrichments
+	entryBuilder.gtidSourceID = gtidSourceID(op.changefeedID)
+	if op.timezone != nil {
+		entryBuilder.timezone = op.timezone.String()
+	}
+	keySerializer := op.keySerializer
+	if keySerializer == nil {
+		keySerializer = newKeySerializer(config)
+	}
+	if keySerializer != nil {
+		entryBuilder.keySerializer = keySerializer
+	}
+	entryBuilder.encryptor = op.encryptor
+	entryBuilder.nameMapping = op.nameMapping
 	encoder := &BatchEncoder{
 		messages:     &canal.Messages{},
 		callbackBuf:  make([]func(), 0),
-		entryBuilder: newCanalEntryBuilder(),
+		entryBuilder: entryBuilder,
+		serializer:   newPropsCapSerializer(op.serializer, config),
+		config:       config,
+		keyed:        config.EnableTombstone || keySerializer != nil,
+		activeTables: state.activeTables,
+		lastTxns:     make(map[model.TableName]txnKey),
+		ddlLimiter:   state.ddlLimiter,
+		deadLetter:   op.deadLetter,
+	}
+
+	if state.budget != nil {
+		encoder.account = state.budget.NewAccount()
 	}
 
 	encoder.resetPacket()
 	return encoder
 }
 
-type batchEncoderBuilder struct{}
+// Option define the encoderOptions
+type Option func(*encoderOptions)
+
+type encoderOptions struct {
+	serializer  Serializer
+	enrichments map[model.TableName]*Enrichment
+	// changefeedID is the changefeed encoding the events.
+	changefeedID model.ChangeFeedID
+	// timezone is the timezone the TIMESTAMP values are formatted in,
+	// nil means UTC.
+	timezone *time.Location
+	// keySerializer serializes the keys of the rows, nil means the one of
+	// the KeyFormat, see newKeySerializer.
+	keySerializer KeySerializer
+	// encryptor encrypts the columns of the EncryptedColumns.
+	encryptor Encryptor
+	// deadLetter shunts the row failing to encode, see DeadLetterHook.
+	deadLetter DeadLetterHook
+	// nameMapping maps the names of the schemas and tables, see NameMapping.
+	nameMapping NameMapping
+}
+
+func newEncoderOptions() *encoderOptions {
+	return &encoderOptions{
+		serializer: protoSerializer{},
+	}
+}
+
+// WithSerializer provides the Option for the serializer of the entries,
+// they are serialized into the canal protobuf entries by default.
+func WithSerializer(s Serializer) Option {
+	return func(o *encoderOptions) {
+		if s != nil {
+			o.serializer = s
+		}
+	}
+}
+
+// WithKeySerializer provides the Option for the serializer of the keys of
+// the rows, each row is emitted in a standalone message keyed by it if it's
+// provided, which overrides the KeyFormat.
+func WithKeySerializer(s KeySerializer) Option {
+	return func(o *encoderOptions) {
+		o.keySerializer = s
+	}
+}
+
+// WithEnrichments provides the Option for the enrichments of the rows
+// by table, see Enrichment.
+func WithEnrichments(enrichments map[model.TableName]*Enrichment) Option {
+	return func(o *encoderOptions) {
+		o.enrichments = enrichments
+	}
+}
+
+// WithChangefeedID provides the Option for the changefeed encoding the
+// events, which identifies the source of the GTID.
+func WithChangefeedID(id model.ChangeFeedID) Option {
+	return func(o *encoderOptions) {
+		o.changefeedID = id
+	}
+}
+
+// WithTimezone provides the Option for the timezone the TIMESTAMP values
+// are formatted in, i.e. the timezone of the changefeed.
+func WithTimezone(tz *time.Location) Option {
+	return func(o *encoderOptions) {
+		o.timezone = tz
+	}
+}
+
+// WithEncryptor provides the Option for the encryptor of the columns of
+// the EncryptedColumns, see Encryptor.
+func WithEncryptor(e Encryptor) Option {
+	return func(o *encoderOptions) {
+		o.encryptor = e
+	}
+}
+
+// WithDeadLetter provides the Option for the hook shunting the row failing
+// to encode, it's invoked at once for the permanent failure, or once the
+// DeadLetterRetries are exhausted for the retryable one, and the row is
+// skipped unless it returns an error.
+func WithDeadLetter(hook DeadLetterHook) Option {
+	return func(o *encoderOptions) {
+		o.deadLetter = hook
+	}
+}
+
+// WithNameMapping provides the Option for the mapping of the names of the
+// schemas and tables in the row events and the DDL queries, see NameMapping.
+// It's only available to the encoders built by the API, since the sinks build
+// the encoders by the protocol without any option.
+func WithNameMapping(mapping NameMapping) Option {
+	return func(o *encoderOptions) {
+		o.nameMapping = mapping
+	}
+}
+
+type batchEncoderBuilder struct {
+	config *common.Config
+	state  *encoderState
+	op     *encoderOptions
+}
 
 // Build a `canalBatchEncoder`
 func (b *batchEncoderBuilder) Build() codec.EventBatchEncoder {
-	return newBatchEncoder()
+	return newBatchEncoderWithState(b.config, b.state, b.op)
 }
 
 // NewBatchEncoderBuilder creates a canal batchEncoderBuilder.
-func NewBatchEncoderBuilder() codec.EncoderBuilder {
-	return &batchEncoderBuilder{}
+func NewBatchEncoderBuilder(config *common.Config, opts ...Option) codec.EncoderBuilder {
+	op := newEncoderOptions()
+	for _, opt := range opts {
+		opt(op)
+	}
+	return &batchEncoderBuilder{
+		config: config,
+		state:  newEncoderState(config),
+		op:     op,
+	}
 }
@@ -14,26 +14,37 @@
 package canal
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/errors"
+	mm "github.com/pingcap/tidb/parser/model"
 	"github.com/pingcap/tidb/parser/mysql"
 	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
 	canal "github.com/pingcap/tiflow/proto/canal"
 	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
 )
 
 func TestCanalBatchEncoder(t *testing.T) {
 	t.Parallel()
 	s := defaultCanalBatchTester
 	for _, cs := range s.rowCases {
-		encoder := newBatchEncoder()
+		encoder := newBatchEncoder(common.NewConfig(config.ProtocolCanal))
 		for _, row := range cs {
 			err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
 			require.Nil(t, err)
 		}
-		res := encoder.Build()
+		res, err := encoder.Build()
+		require.NoError(t, err)
 
 		if len(cs) == 0 {
 			require.Nil(t, res)
@@ -45,7 +56,7 @@ func TestCanalBatchEncoder(t *testing.T) {
 		require.Equal(t, len(cs), res[0].GetRowsCount())
 
 		packet := &canal.Packet{}
-		err := proto.Unmarshal(res[0].Value, packet)
+		err = proto.Unmarshal(res[0].Value, packet)
 		require.Nil(t, err)
 		require.Equal(t, canal.PacketType_MESSAGES, packet.GetType())
 		messages := &canal.Messages{}
@@ -55,7 +66,7 @@ func TestCanalBatchEncoder(t *testing.T) {
 	}
 
 	for _, cs := range s.ddlCases {
-		encoder := newBatchEncoder()
+		encoder := newBatchEncoder(common.NewConfig(config.ProtocolCanal))
 		for _, ddl := range cs {
 			msg, err := encoder.EncodeDDLEvent(ddl)
 			require.Nil(t, err)
@@ -76,7 +87,7 @@ func TestCanalBatchEncoder(t *testing.T) {
 }
 
 func TestCanalAppendRowChangedEventWithCallback(t *testing.T) {
-	encoder := newBatchEncoder()
+	encoder := newBatchEncoder(common.NewConfig(config.ProtocolCanal))
 	require.NotNil(t, encoder)
 
 	count := 0
@@ -128,7 +139,8 @@ func TestCanalAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 
 	// Empty build makes sure that the callback build logic not broken.
-	msgs := encoder.Build()
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 0, "no message should be built and no panic")
 
 	// Append the events.
@@ -138,8 +150,428 @@ func TestCanalAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 	require.Equal(t, 0, count, "nothing should be called")
 
-	msgs = encoder.Build()
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 1, "expected one message")
 	msgs[0].Callback()
 	require.Equal(t, 15, count, "expected all callbacks to be called")
 }
+
+func TestCanalBatchEncoderWithTombstone(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableTombstone = true
+	encoder := newBatchEncoder(codecConfig)
+
+	table := &model.TableName{Schema: "test", Table: "t"}
+	insert := &model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    table,
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Bob")},
+		},
+	}
+	deleted := &model.RowChangedEvent{
+		CommitTs: 2,
+		Table:    table,
+		PreColumns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Bob")},
+		},
+	}
+
+	count := 0
+	err := encoder.AppendRowChangedEvent(context.Background(), "", insert, func() { count++ })
+	require.Nil(t, err)
+	err = encoder.AppendRowChangedEvent(context.Background(), "", deleted, func() { count++ })
+	require.Nil(t, err)
+
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.NotNil(t, msgs[0].Key)
+	require.NotNil(t, msgs[0].Value)
+	require.Equal(t, 1, msgs[0].GetRowsCount())
+
+	// the delete record and the tombstone
+	require.Equal(t, msgs[0].Key, msgs[1].Key)
+	require.NotNil(t, msgs[1].Value)
+	require.Nil(t, msgs[1].Callback)
+	require.Equal(t, msgs[0].Key, msgs[2].Key)
+	require.Nil(t, msgs[2].Value)
+	require.Equal(t, 0, msgs[2].GetRowsCount())
+
+	msgs[0].Callback()
+	msgs[2].Callback()
+	require.Equal(t, 2, count)
+
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Nil(t, msgs)
+}
+
+func TestCanalBatchEncoderTableWatermark(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableTableWatermark = true
+	builder := NewBatchEncoderBuilder(codecConfig)
+
+	// the rows and the checkpoint are encoded by different encoders.
+	encoder := builder.Build()
+	for _, table := range []string{"t1", "t2", "t1"} {
+		row := &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLong,
+				Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value: 1,
+			}},
+		}
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
+		require.Nil(t, err)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	checkpointTs := uint64(417318403368288261)
+	encoder = builder.Build()
+	msg, err := encoder.EncodeCheckpointEvent(checkpointTs)
+	require.Nil(t, err)
+	require.Nil(t, msg)
+
+	watermarks, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, watermarks, 2)
+	for i, table := range []string{"t1", "t2"} {
+		watermark := watermarks[i]
+		require.Equal(t, model.MessageTypeResolved, watermark.Type)
+		require.Equal(t, checkpointTs, watermark.Ts)
+		require.Equal(t, "test", *watermark.Schema)
+		require.Equal(t, table, *watermark.Table)
+
+		decoder := NewStreamDecoder(bytes.NewReader(framePacket(watermark.Value)))
+		ty, hasNext, err := decoder.HasNext()
+		require.Nil(t, err)
+		require.True(t, hasNext)
+		require.Equal(t, model.MessageTypeResolved, ty)
+		ts, err := decoder.NextResolvedEvent()
+		require.Nil(t, err)
+		require.Equal(t, checkpointTs, ts)
+	}
+
+	// no tables are active since the last checkpoint.
+	msg, err = encoder.EncodeCheckpointEvent(checkpointTs + 1)
+	require.Nil(t, err)
+	require.Nil(t, msg)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 0)
+}
+
+func TestCanalBatchEncoderMaxPendingCallbacks(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.MaxPendingCallbacks = 3
+	encoder := newBatchEncoder(codecConfig).(*BatchEncoder)
+
+	row := &model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{{
+			Name:  "id",
+			Type:  mysql.TypeLong,
+			Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+			Value: 1,
+		}},
+	}
+	called := 0
+	callback := func() { called++ }
+
+	// the flag trips when the callbacks reach the cap.
+	for i := 0; i < 2; i++ {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, callback)
+		require.Nil(t, err)
+		require.False(t, encoder.ShouldFlush())
+	}
+	err := encoder.AppendRowChangedEvent(context.Background(), "", row, callback)
+	require.Nil(t, err)
+	require.True(t, encoder.ShouldFlush())
+
+	// the callbacks held are attached to the batch built.
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, 3, msgs[0].GetRowsCount())
+	require.False(t, encoder.ShouldFlush())
+	msgs[0].Callback()
+	require.Equal(t, 3, called)
+
+	// the count restarts after Build, and the rows without callback
+	// are not counted.
+	for i := 0; i < 3; i++ {
+		err = encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
+		require.Nil(t, err)
+	}
+	err = encoder.AppendRowChangedEvent(context.Background(), "", row, callback)
+	require.Nil(t, err)
+	require.False(t, encoder.ShouldFlush())
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, 4, msgs[0].GetRowsCount())
+	require.Equal(t, 3, called)
+
+	// no limit by default.
+	encoder = newBatchEncoder(common.NewConfig(config.ProtocolCanal)).(*BatchEncoder)
+	for i := 0; i < 10; i++ {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, callback)
+		require.Nil(t, err)
+	}
+	require.False(t, encoder.ShouldFlush())
+}
+
+func TestCanalBatchEncoderMessageTimestamp(t *testing.T) {
+	t.Parallel()
+
+	physical := time.Date(2022, 10, 14, 12, 34, 56, 789000000, time.UTC)
+	newRow := func(commitTs uint64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLong,
+				Flag:  model.PrimaryKeyFlag,
+				Value: int64(1),
+			}},
+		}
+	}
+	commitTs := oracle.GoTimeToTS(physical)
+	ddl := &model.DDLEvent{
+		CommitTs:  commitTs,
+		Query:     "create table test.t (id int primary key)",
+		Type:      mm.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	}
+
+	// the timestamp is left to the producer by default.
+	encoder := newBatchEncoder(common.NewConfig(config.ProtocolCanal))
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow(commitTs), nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.True(t, msgs[0].Timestamp.IsZero())
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.MessageTimestamp = common.MessageTimestampCommitTs
+	encoder = newBatchEncoder(codecConfig)
+
+	// the batch has the max commit ts of the rows.
+	later := oracle.GoTimeToTS(physical.Add(time.Second))
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow(later), nil))
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow(commitTs), nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.True(t, physical.Add(time.Second).Equal(msgs[0].Timestamp))
+
+	// the max commit ts is reset by Build.
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow(commitTs), nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.True(t, physical.Equal(msgs[0].Timestamp))
+
+	msg, err := encoder.EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	require.True(t, physical.Equal(msg.Timestamp))
+}
+
+func TestCanalBatchEncoderMaxBufferedBytes(t *testing.T) {
+	t.Parallel()
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{{
+			Name: "a", Type: mysql.TypeVarchar, Value: strings.Repeat("a", 100),
+		}},
+	}
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(row)
+	require.Nil(t, err)
+	size := proto.Size(entry)
+	// the budget holds 8 rows.
+	codecConfig.MaxBufferedBytes = 8*size + size/2
+
+	builder := NewBatchEncoderBuilder(codecConfig)
+	encoders := make([]codec.EventBatchEncoder, 3)
+	for i := range encoders {
+		encoders[i] = builder.Build()
+	}
+	shouldFlush := func() []bool {
+		result := make([]bool, len(encoders))
+		for i, encoder := range encoders {
+			result[i] = encoder.(codec.FlushHintEncoder).ShouldFlush()
+		}
+		return result
+	}
+	appendRows := func(encoder codec.EventBatchEncoder, n int) {
+		for i := 0; i < n; i++ {
+			require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", row, nil))
+		}
+	}
+
+	// each encoder buffers less than the budget, and so do they in sum.
+	appendRows(encoders[0], 2)
+	appendRows(encoders[1], 3)
+	appendRows(encoders[2], 3)
+	require.Equal(t, []bool{false, false, false}, shouldFlush())
+
+	// the encoder buffering the most bytes is hinted to flush once exceeded.
+	appendRows(encoders[1], 1)
+	require.Equal(t, []bool{false, true, false}, shouldFlush())
+
+	// the budget is released by Build.
+	msgs, err := encoders[1].Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, []bool{false, false, false}, shouldFlush())
+
+	// the encoders of the other builders do not share the budget.
+	other := NewBatchEncoderBuilder(codecConfig).Build()
+	appendRows(other, 8)
+	require.False(t, other.(codec.FlushHintEncoder).ShouldFlush())
+	require.Equal(t, []bool{false, false, false}, shouldFlush())
+}
+
+// flakySerializer fails to serialize the entries of the table of the given
+// name for the times given, -1 means forever. The failure is retryable
+// unless it's permanent.
+type flakySerializer struct {
+	table     string
+	failures  int
+	permanent bool
+	calls     int
+}
+
+func (s *flakySerializer) Serialize(entry *Entry) ([]byte, error) {
+	if entry.Header.GetTableName() == s.table {
+		s.calls++
+		if s.failures < 0 || s.calls <= s.failures {
+			if s.permanent {
+				return nil, errors.New("injected failure")
+			}
+			return nil, cerror.ErrCanalExternalStoreFailed.Wrap(errors.New("injected failure"))
+		}
+	}
+	return protoSerializer{}.Serialize(entry)
+}
+
+func TestCanalBatchEncoderDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	newRow := func(table string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			},
+		}
+	}
+	type deadLetter struct {
+		event *model.RowChangedEvent
+		err   error
+	}
+	var deadLetters []deadLetter
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.DeadLetterRetries = 2
+	hook := WithDeadLetter(func(event *model.RowChangedEvent, err error) error {
+		deadLetters = append(deadLetters, deadLetter{event: event, err: err})
+		return nil
+	})
+
+	// the row failing persistently is dead-lettered after the retries,
+	// and the other rows are encoded.
+	serializer := &flakySerializer{table: "bad", failures: -1}
+	encoder := NewBatchEncoderBuilder(codecConfig, WithSerializer(serializer), hook).Build()
+	called := 0
+	callback := func() { called++ }
+	bad := newRow("bad")
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", bad, callback))
+	require.Equal(t, 3, serializer.calls)
+	require.Len(t, deadLetters, 1)
+	require.Same(t, bad, deadLetters[0].event)
+	requireEncodeErrorClass(t, deadLetters[0].err, cerror.ErrCanalExternalStoreFailed)
+	require.ErrorContains(t, deadLetters[0].err, "injected failure")
+	// the callback of the row dead-lettered is called at once.
+	require.Equal(t, 1, called)
+
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow("good"), callback))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, 1, msgs[0].GetRowsCount())
+	msgs[0].Callback()
+	require.Equal(t, 2, called)
+
+	// the permanent failure is dead-lettered at once.
+	serializer = &flakySerializer{table: "bad", failures: -1, permanent: true}
+	encoder = NewBatchEncoderBuilder(codecConfig, WithSerializer(serializer), hook).Build()
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow("bad"), nil))
+	require.Equal(t, 1, serializer.calls)
+	require.Len(t, deadLetters, 2)
+	requireEncodeErrorClass(t, deadLetters[1].err, cerror.ErrCanalMarshalFailed)
+
+	// the row recovered within the retries is not dead-lettered, and it's
+	// built once, so the sequence is stamped once.
+	sequenced := *codecConfig
+	sequenced.EnableSequence = true
+	serializer = &flakySerializer{table: "bad", failures: 2}
+	encoder = NewBatchEncoderBuilder(&sequenced, WithSerializer(serializer), hook).Build()
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow("bad"), nil))
+	require.Equal(t, 3, serializer.calls)
+	require.Len(t, deadLetters, 2)
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow("good"), nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, []string{
+		formatSequence(417318403368288260, 0),
+		formatSequence(417318403368288260, 1),
+	}, decodeSequences(t, msgs[0].Value))
+
+	// the error returned by the hook fails the encoding.
+	encoder = NewBatchEncoderBuilder(codecConfig,
+		WithSerializer(&flakySerializer{table: "bad", failures: -1}),
+		WithDeadLetter(func(*model.RowChangedEvent, error) error {
+			return errors.New("dead letter unavailable")
+		})).Build()
+	err = encoder.AppendRowChangedEvent(context.Background(), "", newRow("bad"), nil)
+	require.ErrorContains(t, err, "dead letter unavailable")
+
+	// the encoding error is returned without the hook.
+	serializer = &flakySerializer{table: "bad", failures: -1}
+	encoder = NewBatchEncoderBuilder(codecConfig, WithSerializer(serializer)).Build()
+	err = encoder.AppendRowChangedEvent(context.Background(), "", newRow("bad"), nil)
+	requireEncodeErrorClass(t, err, cerror.ErrCanalExternalStoreFailed)
+	require.Equal(t, 1, serializer.calls)
+
+	// the failure of the Build is returned, e.g. the terminator of the batch,
+	// whose table name is empty, fails to serialize.
+	terminated := common.NewConfig(config.ProtocolCanal)
+	terminated.EnableBatchTerminator = true
+	encoder = NewBatchEncoderBuilder(terminated,
+		WithSerializer(&flakySerializer{table: "", failures: -1, permanent: true})).Build()
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow("good"), nil))
+	_, err = encoder.Build()
+	requireEncodeErrorClass(t, err, cerror.ErrCanalMarshalFailed)
+}
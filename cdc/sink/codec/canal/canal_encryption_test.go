@@ -0,0 +1,165 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+// aesEncryptor encrypts the values by AES-GCM, the nonce is prepended to
+// the ciphertext.
+type aesEncryptor struct {
+	keys map[string][]byte
+}
+
+func (e *aesEncryptor) Algorithm() string {
+	return "AES-256-GCM"
+}
+
+func (e *aesEncryptor) aead(keyID string) cipher.AEAD {
+	block, err := aes.NewCipher(e.keys[keyID])
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+func (e *aesEncryptor) Encrypt(keyID string, value []byte) ([]byte, error) {
+	aead := e.aead(keyID)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, value, nil), nil
+}
+
+func (e *aesEncryptor) decrypt(keyID string, value []byte) ([]byte, error) {
+	aead := e.aead(keyID)
+	nonce, ciphertext := value[:aead.NonceSize()], value[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func TestEncryptColumns(t *testing.T) {
+	t.Parallel()
+
+	encryptor := &aesEncryptor{keys: map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+	}}
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	// the column names are case-insensitive.
+	codecConfig.EncryptedColumns = []common.EncryptedColumnsRule{{
+		TableMatcher: common.MustNewTableMatcher("test.t"),
+		KeyID:        "k1",
+		Columns:      []string{"id", "EMAIL", "note"},
+	}}
+	codecConfig.EnableRawStorageValue = true
+
+	columns := func(email string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+			{Name: "email", Type: mysql.TypeVarchar, Flag: model.NullableFlag, Value: []byte(email)},
+			{Name: "note", Type: mysql.TypeVarchar, Flag: model.NullableFlag, Value: nil},
+			{Name: "age", Type: mysql.TypeLong, Flag: model.NullableFlag, Value: int64(18)},
+		}
+	}
+	event := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      &model.TableName{Schema: "test", Table: "t"},
+		PreColumns: columns("bob@example.com"),
+		Columns:    columns("alice@example.com"),
+	}
+
+	builder := newCanalEntryBuilder(codecConfig)
+	builder.encryptor = encryptor
+	entry, err := builder.fromRowEvent(event)
+	require.NoError(t, err)
+	rc := &canal.RowChange{}
+	require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+	rowData := rc.GetRowDatas()[0]
+
+	check := func(columns []*canal.Column, email string) {
+		props := func(c *canal.Column) map[string]string {
+			result := make(map[string]string)
+			for _, p := range c.GetProps() {
+				result[p.GetKey()] = p.GetValue()
+			}
+			return result
+		}
+		for _, c := range columns {
+			switch c.GetName() {
+			case "email":
+				p := props(c)
+				require.Equal(t, "k1", p[propEncryptionKeyID])
+				require.Equal(t, "AES-256-GCM", p[propEncryptionAlgorithm])
+				require.NotContains(t, p, propRawValue)
+				ciphertext, err := base64.StdEncoding.DecodeString(c.GetValue())
+				require.NoError(t, err)
+				plaintext, err := encryptor.decrypt(p[propEncryptionKeyID], ciphertext)
+				require.NoError(t, err)
+				require.Equal(t, email, string(plaintext))
+			case "id":
+				// the key column stays plaintext.
+				require.Equal(t, "1", c.GetValue())
+				require.NotContains(t, props(c), propEncryptionKeyID)
+			case "note":
+				// so does the null.
+				require.True(t, c.GetIsNull())
+				require.NotContains(t, props(c), propEncryptionKeyID)
+			case "age":
+				require.Equal(t, "18", c.GetValue())
+				require.NotContains(t, props(c), propEncryptionKeyID)
+			}
+		}
+	}
+	check(rowData.GetAfterColumns(), "alice@example.com")
+	check(rowData.GetBeforeColumns(), "bob@example.com")
+
+	// the tables matched by none are not affected.
+	other := *event
+	other.Table = &model.TableName{Schema: "test", Table: "t2"}
+	entry, err = builder.fromRowEvent(&other)
+	require.NoError(t, err)
+	rc = &canal.RowChange{}
+	require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+	for _, c := range rc.GetRowDatas()[0].GetAfterColumns() {
+		if c.GetName() == "email" {
+			require.Equal(t, "alice@example.com", c.GetValue())
+		}
+	}
+
+	// the encoder is provided the encryptor by the option, and fails
+	// without it rather than emitting the plaintext.
+	encoder := NewBatchEncoderBuilder(codecConfig, WithEncryptor(encryptor)).Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", event, nil))
+	encoder = NewBatchEncoderBuilder(codecConfig).Build()
+	err = encoder.AppendRowChangedEvent(context.Background(), "", event, nil)
+	require.ErrorContains(t, err, "no encryptor for the encrypted column email")
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// Enrichment enriches the rows of a table with the columns of the related
+// row, e.g. the row of a dimension table, which is looked up by the value of
+// the foreign key column of the row. The columns looked up are appended to
+// the new image of the row with the `enriched` prop, except the ones named
+// the same as a column of the row. The DELETE is not enriched, since it has
+// no new image, neither are the rows in the key-only mode.
+type Enrichment struct {
+	// ForeignKey is the name of the column whose value is looked up,
+	// the row is not enriched if the value is null.
+	ForeignKey string
+	// Lookup returns the columns of the related row, and false if it's not
+	// found, in which case the row is emitted without enrichment. It's called
+	// by the encoder for each row, so it should be fast, e.g. served by a cache.
+	Lookup func(value interface{}) ([]*model.Column, bool)
+}
+
+// enrichColumns returns the columns enriching the new image of the row,
+// see Enrichment.
+func (b *canalEntryBuilder) enrichColumns(e *model.RowChangedEvent) ([]*canal.Column, error) {
+	if len(b.enrichments) == 0 || len(e.Columns) == 0 || b.config.KeyOnly {
+		return nil, nil
+	}
+	enrichment, ok := b.enrichments[model.TableName{Schema: e.Table.Schema, Table: e.Table.Table}]
+	if !ok {
+		return nil, nil
+	}
+
+	var value interface{}
+	names := make(map[string]struct{}, len(e.Columns))
+	for _, column := range e.Columns {
+		if column == nil {
+			continue
+		}
+		names[column.Name] = struct{}{}
+		if column.Name == enrichment.ForeignKey {
+			value = column.Value
+		}
+	}
+	if value == nil {
+		return nil, nil
+	}
+	columns, ok := enrichment.Lookup(value)
+	if !ok {
+		return nil, nil
+	}
+
+	var result []*canal.Column
+	for _, column := range columns {
+		if column == nil {
+			continue
+		}
+		if _, ok := names[column.Name]; ok {
+			continue
+		}
+		c, err := b.buildColumn(column, b.columnName(column.Name), true)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		// the key of the related row does not identify the row.
+		c.IsKey = false
+		c.Props = append(c.Props, &canal.Pair{Key: propEnriched, Value: "true"})
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// isEnriched returns whether the column is enriched.
+func isEnriched(c *canal.Column) bool {
+	for _, p := range c.GetProps() {
+		if p.GetKey() == propEnriched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichment(t *testing.T) {
+	t.Parallel()
+
+	// customers is the fake lookup of the customers by id.
+	customers := map[int64][]*model.Column{
+		1: {
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			{Name: "customer_name", Type: mysql.TypeVarchar, Value: "Bob"},
+			{Name: "customer_tier", Type: mysql.TypeVarchar, Value: "gold"},
+		},
+	}
+	var lookups []interface{}
+	enrichments := map[model.TableName]*Enrichment{
+		{Schema: "shop", Table: "orders"}: {
+			ForeignKey: "customer_id",
+			Lookup: func(value interface{}) ([]*model.Column, bool) {
+				lookups = append(lookups, value)
+				columns, ok := customers[value.(int64)]
+				return columns, ok
+			},
+		},
+	}
+	newColumns := func(customerID interface{}) []*model.Column {
+		return []*model.Column{
+			{
+				Name: "id", Type: mysql.TypeLong,
+				Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: int64(100),
+			},
+			{Name: "customer_id", Type: mysql.TypeLong, Value: customerID},
+			{Name: "amount", Type: mysql.TypeLong, Value: int64(42)},
+		}
+	}
+	orders := &model.TableName{Schema: "shop", Table: "orders", TableID: 1}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.ChecksumAlgorithm = common.ChecksumAlgorithmCRC32
+	// encode returns the after columns and the checksum of the row.
+	encode := func(e *model.RowChangedEvent, opts ...Option) ([]*canal.Column, string) {
+		encoder := NewBatchEncoderBuilder(codecConfig, opts...).Build()
+		require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", e, nil))
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		entries, err := decodePacket(msgs[0].Value)
+		require.Nil(t, err)
+		entry := &canal.Entry{}
+		require.Nil(t, proto.Unmarshal(entries[0], entry))
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		checksum := ""
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propChecksum {
+				checksum = p.GetValue()
+			}
+		}
+		return rc.GetRowDatas()[0].GetAfterColumns(), checksum
+	}
+	names := func(columns []*canal.Column) []string {
+		var result []string
+		for _, c := range columns {
+			result = append(result, c.GetName())
+		}
+		return result
+	}
+
+	insert := &model.RowChangedEvent{CommitTs: 1, Table: orders, Columns: newColumns(int64(1))}
+	plain, checksum := encode(insert)
+	require.Equal(t, []string{"id", "customer_id", "amount"}, names(plain))
+
+	// the columns looked up are appended, except the id of the customer.
+	columns, enrichedChecksum := encode(insert, WithEnrichments(enrichments))
+	require.Equal(t, []interface{}{int64(1)}, lookups)
+	require.Equal(t,
+		[]string{"id", "customer_id", "amount", "customer_name", "customer_tier"}, names(columns))
+	require.Equal(t, plain, columns[:3])
+	for _, c := range columns[3:] {
+		require.True(t, isEnriched(c))
+		require.False(t, c.GetIsKey())
+	}
+	require.Equal(t, "Bob", columns[3].GetValue())
+	require.Equal(t, "gold", columns[4].GetValue())
+	// the checksum covers the columns of the row only.
+	require.Equal(t, checksum, enrichedChecksum)
+
+	// the row is emitted as is if the lookup misses.
+	columns, _ = encode(&model.RowChangedEvent{
+		CommitTs: 1, Table: orders, Columns: newColumns(int64(2)),
+	}, WithEnrichments(enrichments))
+	require.Equal(t, []interface{}{int64(1), int64(2)}, lookups)
+	require.Equal(t, []string{"id", "customer_id", "amount"}, names(columns))
+
+	// the null foreign key is not looked up.
+	columns, _ = encode(&model.RowChangedEvent{
+		CommitTs: 1, Table: orders, Columns: newColumns(nil),
+	}, WithEnrichments(enrichments))
+	require.Len(t, lookups, 2)
+	require.Equal(t, []string{"id", "customer_id", "amount"}, names(columns))
+
+	// the rows of the other tables are not enriched.
+	columns, _ = encode(&model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    &model.TableName{Schema: "shop", Table: "refunds"},
+		Columns:  newColumns(int64(1)),
+	}, WithEnrichments(enrichments))
+	require.Len(t, lookups, 2)
+	require.Equal(t, []string{"id", "customer_id", "amount"}, names(columns))
+
+	// the DELETE is not enriched.
+	columns, _ = encode(&model.RowChangedEvent{
+		CommitTs: 1, Table: orders, PreColumns: newColumns(int64(1)),
+	}, WithEnrichments(enrichments))
+	require.Len(t, lookups, 2)
+	require.Empty(t, columns)
+}
@@ -19,13 +19,15 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/golang/protobuf/proto" // nolint:staticcheck
 	"github.com/pingcap/errors"
 	mm "github.com/pingcap/tidb/parser/model"
 	"github.com/pingcap/tidb/parser/mysql"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
 	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
 	cerror "github.com/pingcap/tiflow/pkg/errors"
 	canal "github.com/pingcap/tiflow/proto/canal"
@@ -41,32 +43,184 @@ const (
 	CanalServerEncode    string = "UTF-8"
 )
 
+// keys of the props carried by the canal entry header
+const (
+	propRowsCount      = "rowsCount"
+	propPartitionCount = "partitionCount"
+	// propOnUpdateColumns lists the columns maintained by
+	// `ON UPDATE CURRENT_TIMESTAMP`, separated by comma.
+	propOnUpdateColumns = "onUpdateColumns"
+	// propChecksumAlgorithm and propChecksum carry the checksum of the row,
+	// see rowChecksum for how it is computed.
+	propChecksumAlgorithm = "checksumAlgorithm"
+	propChecksum          = "checksum"
+	// propUpstreamChecksumAlgorithm and propUpstreamChecksum carry the
+	// checksum of the row attached by the upstream TiDB.
+	propUpstreamChecksumAlgorithm = "upstreamChecksumAlgorithm"
+	propUpstreamChecksum          = "upstreamChecksum"
+	// propWatermarkTs carries the ts of the table-scoped watermark.
+	propWatermarkTs = "watermarkTs"
+	// propBatchEnd carries the number of the row entries of the packet
+	// terminated, see fromBatchEnd.
+	propBatchEnd = "batchEnd"
+	// propSequence carries the sequence of the entry, see sequencer for
+	// the ordering guarantees.
+	propSequence = "sequence"
+	// propTxnRowCount carries the row count of the transaction in the table,
+	// see BatchEncoder.flushPendingRows for when it's emitted.
+	propTxnRowCount = "txnRowCount"
+	// propConsistencyLevel carries the consistent level of the changefeed.
+	propConsistencyLevel = "consistencyLevel"
+	// propSQLDigest carries the digest of the statement producing the row.
+	propSQLDigest = "sqlDigest"
+	// propTimezone carries the timezone the TIMESTAMP values of the row are
+	// formatted in, see appendTimezone.
+	propTimezone = "timezone"
+	// propOriginalSchema and propOriginalTable carry the names replaced
+	// with the safe identifiers, see appendOriginalNames.
+	propOriginalSchema = "originalSchema"
+	propOriginalTable  = "originalTable"
+	// propAffectsData tells whether the DDL changes the existing rows,
+	// see ddlAffectsData for the classification.
+	propAffectsData = "affectsData"
+	// propDDLCompression carries the algorithm compressing the DDL query,
+	// see compressDDLQuery.
+	propDDLCompression = "ddlCompression"
+	// propDDLCompressionDict carries the ID of the zstd dictionary compressing
+	// the DDL query, see compressDDLQuery.
+	propDDLCompressionDict = "ddlCompressionDict"
+	// propSchemaVersion carries the version of the table schema encoding
+	// the entry, see appendSchemaVersion.
+	propSchemaVersion = "schemaVersion"
+	// propSchemaFingerprint carries the fingerprint of the column names and
+	// types of the row, see schemaFingerprint.
+	propSchemaFingerprint = "schemaFingerprint"
+	// propSchemaURL carries the URL of the schema of the row hosted
+	// externally, see schemaURL.
+	propSchemaURL = "schemaUrl"
+	// propGTID carries the GTID of the entry as the canal server does,
+	// see appendGTID.
+	propGTID = "curtGtid"
+	// propSchemaCompatibility tells whether the DDL changes the schema
+	// compatibly, and propBreakingChanges carries the changes breaking it,
+	// see appendSchemaCompatibility.
+	propSchemaCompatibility = "schemaCompatibility"
+	propBreakingChanges     = "breakingChanges"
+	// propWindowID carries the id of the time window of the row,
+	// see windowID.
+	propWindowID = "windowId"
+	// propFirstSeen tells whether the key of the row is seen for the first
+	// time, see seenKeys.
+	propFirstSeen = "firstSeen"
+	// propMessageID carries the id identifying the row change across the
+	// encodings, see messageID.
+	propMessageID = "messageId"
+	// propAutoIncrementColumns and propAutoRandomColumns carry the auto
+	// generated columns of the table after the DDL, see appendAutoGeneratedColumns.
+	propAutoIncrementColumns = "autoIncrementColumns"
+	propAutoRandomColumns    = "autoRandomColumns"
+	// propRowSize carries the size in bytes of the row, see appendRowSize.
+	propRowSize = "rowSize"
+	// propChangedColumns carries the columns changed by the update,
+	// see appendChangedColumns.
+	propChangedColumns = "changedColumns"
+	// propNotNullColumns carries the NOT NULL columns of the table after
+	// the DDL, see appendNotNullColumns.
+	propNotNullColumns = "notNullColumns"
+	// propHandleType carries the type of the handle of the table,
+	// see tableHandle.
+	propHandleType = "handleType"
+)
+
+// keys of the props carried by the canal column
+const (
+	// propTruncated and propOriginalLength are set if the value of the
+	// column is truncated, see truncateValue.
+	propTruncated      = "truncated"
+	propOriginalLength = "originalLength"
+	propCharset        = "charset"
+	// propRawValue carries the raw storage value of the column,
+	// see rawStorageValue.
+	propRawValue = "rawValue"
+	// propDeclaredType carries the type declared in the schema of the
+	// column, see appendDeclaredType.
+	propDeclaredType = "declaredType"
+	// propAutoGenerated tells how the value of the column is generated by
+	// default, see autoGeneratedColumns.
+	propAutoGenerated = "autoGenerated"
+	// propNullable tells whether the column is nullable, see appendNullable.
+	propNullable = "nullable"
+	// propHandle carries the ordinal of the column in the handle of the
+	// table, see handleOrdinals.
+	propHandle = "handle"
+	// propJSONPatch tells the value of the JSON column is the JSON Patch
+	// against the old value, see applyJSONPatch.
+	propJSONPatch = "jsonPatch"
+	// propEnriched is set if the column is not of the row,
+	// but looked up by the Enrichment.
+	propEnriched = "enriched"
+	// propEncryptionKeyID and propEncryptionAlgorithm are set if the value
+	// of the column is encrypted, see encryptColumn.
+	propEncryptionKeyID     = "encryptionKeyId"
+	propEncryptionAlgorithm = "encryptionAlgorithm"
+)
+
 type canalEntryBuilder struct {
 	bytesDecoder *encoding.Decoder // default charset is ISO-8859-1
+	config       *common.Config
+	sequencer    *sequencer
+	// seenKeys is nil if the first seen flag is disabled.
+	seenKeys *seenKeys
+	// consistencyLevel is the value of the consistencyLevel prop,
+	// empty if the prop is not emitted.
+	consistencyLevel string
+	// enrichments enriches the rows by table, see Enrichment.
+	enrichments map[model.TableName]*Enrichment
+	// gtidSourceID is the source id of the GTID, see appendGTID.
+	gtidSourceID string
+	// timezone is the name of the timezone formatting the TIMESTAMP values,
+	// see appendTimezone.
+	timezone string
+	// keySerializer serializes the keys of the rows, see rowKey.
+	keySerializer KeySerializer
+	// encryptor encrypts the columns of the EncryptedColumns,
+	// see encryptColumn.
+	encryptor Encryptor
+	// nameMapping maps the names of the schemas and tables, nil means the
+	// names are not changed, see mapName.
+	nameMapping NameMapping
 }
 
 // newCanalEntryBuilder creates a new canalEntryBuilder
-func newCanalEntryBuilder() *canalEntryBuilder {
-	return &canalEntryBuilder{
-		bytesDecoder: charmap.ISO8859_1.NewDecoder(),
-	}
+func newCanalEntryBuilder(config *common.Config) *canalEntryBuilder {
+	b := &canalEntryBuilder{
+		bytesDecoder:  charmap.ISO8859_1.NewDecoder(),
+		config:        config,
+		sequencer:     newSequencer(),
+		seenKeys:      newSeenKeys(config),
+		gtidSourceID:  gtidSourceID(model.ChangeFeedID{}),
+		timezone:      time.UTC.String(),
+		keySerializer: NewJSONKeySerializer(),
+	}
+	b.consistencyLevel = b.buildConsistencyLevel()
+	return b
 }
 
 // build the header of a canal entry
 func (b *canalEntryBuilder) buildHeader(commitTs uint64, schema string, table string, eventType canal.EventType, rowCount int) *canal.Header {
-	t := convertToCanalTs(commitTs)
+	t := canalTimestamp(commitTs, b.config.TimestampPrecision, b.config.CommitTsOffset)
 	h := &canal.Header{
 		VersionPresent:    &canal.Header_Version{Version: CanalProtocolVersion},
 		ServerenCode:      CanalServerEncode,
 		ExecuteTime:       t,
 		SourceTypePresent: &canal.Header_SourceType{SourceType: canal.Type_MYSQL},
 		SchemaName:        schema,
-		TableName:         table,
+		TableName:         qualifyTable(schema, table, b.config.TableQualification),
 		EventTypePresent:  &canal.Header_EventType{EventType: eventType},
 	}
 	if rowCount > 0 {
 		p := &canal.Pair{
-			Key:   "rowsCount",
+			Key:   propRowsCount,
 			Value: strconv.Itoa(rowCount),
 		}
 		h.Props = append(h.Props, p)
@@ -123,12 +277,31 @@ func (b *canalEntryBuilder) buildColumn(c *model.Column, colName string, updated
 	mysqlType := getMySQLType(c)
 	javaType, err := getJavaSQLType(c, mysqlType)
 	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		return nil, encodeError(cerror.ErrCanalUnsupportedType, err)
 	}
 
-	value, err := b.formatValue(c.Value, javaType)
+	columnValue := c.Value
+	var props []*canal.Pair
+	if truncated, originalLength, ok := truncateValue(
+		c.Value, javaType, b.config.MaxColumnValueLength); ok {
+		columnValue = truncated
+		props = append(props,
+			&canal.Pair{Key: propTruncated, Value: "true"},
+			&canal.Pair{Key: propOriginalLength, Value: strconv.Itoa(originalLength)},
+		)
+	}
+	if c.Charset != "" && b.featureEnabled(featureColumnCharset) {
+		props = append(props, &canal.Pair{Key: propCharset, Value: c.Charset})
+	}
+
+	value, err := b.formatValue(columnValue, javaType)
 	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		return nil, encodeError(cerror.ErrCanalUnsupportedType, err)
+	}
+	if c.Value == nil {
+		value = b.config.NullRepresentation
+	} else {
+		value = b.localizeNumeric(value, javaType)
 	}
 
 	canalColumn := &canal.Column{
@@ -139,35 +312,160 @@ func (b *canalEntryBuilder) buildColumn(c *model.Column, colName string, updated
 		IsNullPresent: &canal.Column_IsNull{IsNull: c.Value == nil},
 		Value:         value,
 		MysqlType:     mysqlType,
+		Props:         props,
 	}
 	return canalColumn, nil
 }
 
+// truncateValue truncates the string or binary value longer than maxLength
+// bytes, and returns the truncated value with its original length in bytes.
+// The text value is truncated at a rune boundary, so that no rune is split,
+// while the binary value is truncated by bytes.
+func truncateValue(
+	value interface{}, javaType internal.JavaSQLType, maxLength int,
+) (interface{}, int, bool) {
+	if maxLength <= 0 {
+		return value, 0, false
+	}
+	switch javaType {
+	case internal.JavaSQLTypeVARCHAR, internal.JavaSQLTypeCHAR, internal.JavaSQLTypeCLOB:
+		var str string
+		switch v := value.(type) {
+		case string:
+			str = v
+		case []byte:
+			str = string(v)
+		default:
+			return value, 0, false
+		}
+		if len(str) <= maxLength {
+			return value, 0, false
+		}
+		n := maxLength
+		for n > 0 && !utf8.RuneStart(str[n]) {
+			n--
+		}
+		return str[:n], len(str), true
+	case internal.JavaSQLTypeBLOB:
+		v, ok := value.([]byte)
+		if !ok || len(v) <= maxLength {
+			return value, 0, false
+		}
+		return v[:maxLength], len(v), true
+	}
+	return value, 0, false
+}
+
 // build the RowData of a canal entry
 func (b *canalEntryBuilder) buildRowData(e *model.RowChangedEvent) (*canal.RowData, error) {
+	var fieldTypes map[string]*types.FieldType
+	if b.config.EnableRawStorageValue || b.config.EnableDeclaredType {
+		fieldTypes = columnFieldTypes(e)
+	}
+	ordinals := b.columnOrdinals(e)
+	jsonPatchColumns := b.jsonPatchColumns(e)
+	var autoGenerated map[string]string
+	if b.config.EnableAutoGenerated {
+		autoGenerated = autoGeneratedColumns(e.TableInfo)
+	}
+	handle := b.handleOrdinals(e)
+	booleans := booleanColumns(e, b.config.BooleanNormalization)
+	deleteImageCompat := b.deleteImageCompat(e)
+	var keyOnlyColumns map[string]struct{}
+	if !deleteImageCompat {
+		keyOnlyColumns = b.keyOnlyColumns(e)
+	}
+	encrypted := b.encryptedColumns(e)
 	var columns []*canal.Column
 	for _, column := range e.Columns {
 		if column == nil {
 			continue
 		}
-		c, err := b.buildColumn(column, column.Name, !e.IsDelete())
+		if keyOnlyColumns != nil {
+			if _, ok := keyOnlyColumns[column.Name]; !ok {
+				continue
+			}
+		}
+		c, err := b.buildColumn(column, b.columnName(column.Name), !e.IsDelete())
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
+		if err := b.applyBoolean(c, column, booleans); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := b.appendRawValue(c, column, fieldTypes[column.Name]); err != nil {
+			return nil, errors.Trace(err)
+		}
+		b.appendDeclaredType(c, fieldTypes[column.Name])
+		b.appendAutoGenerated(c, autoGenerated[column.Name])
+		b.appendNullable(c, column)
+		b.appendHandle(c, handle[column.Name])
+		if err := b.applyJSONPatch(c, column, jsonPatchColumns); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := b.encryptColumn(c, column, encrypted); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if ordinal, ok := ordinals[column.Name]; ok {
+			c.Index = int32(ordinal)
+		}
 		columns = append(columns, c)
 	}
+	var keyColumns map[string]struct{}
+	if !deleteImageCompat {
+		var err error
+		keyColumns, err = b.oldImageKeyColumns(e)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	var preColumns []*canal.Column
 	for _, column := range e.PreColumns {
 		if column == nil {
 			continue
 		}
-		c, err := b.buildColumn(column, column.Name, !e.IsDelete())
+		if keyColumns != nil {
+			if _, ok := keyColumns[column.Name]; !ok {
+				continue
+			}
+		}
+		if keyOnlyColumns != nil {
+			if _, ok := keyOnlyColumns[column.Name]; !ok {
+				continue
+			}
+		}
+		c, err := b.buildColumn(column, b.columnName(column.Name), !e.IsDelete())
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
+		if err := b.applyBoolean(c, column, booleans); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := b.appendRawValue(c, column, fieldTypes[column.Name]); err != nil {
+			return nil, errors.Trace(err)
+		}
+		b.appendDeclaredType(c, fieldTypes[column.Name])
+		b.appendAutoGenerated(c, autoGenerated[column.Name])
+		b.appendNullable(c, column)
+		b.appendHandle(c, handle[column.Name])
+		if err := b.encryptColumn(c, column, encrypted); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if ordinal, ok := ordinals[column.Name]; ok {
+			c.Index = int32(ordinal)
+		}
+		if deleteImageCompat && column.Value == nil {
+			c.Value = ""
+		}
 		preColumns = append(preColumns, c)
 	}
 
+	enriched, err := b.enrichColumns(e)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	columns = append(columns, enriched...)
+
 	rowData := &canal.RowData{}
 	rowData.BeforeColumns = preColumns
 	rowData.AfterColumns = columns
@@ -176,56 +474,206 @@ func (b *canalEntryBuilder) buildRowData(e *model.RowChangedEvent) (*canal.RowDa
 
 // fromRowEvent builds canal entry from cdc RowChangedEvent
 func (b *canalEntryBuilder) fromRowEvent(e *model.RowChangedEvent) (*canal.Entry, error) {
-	eventType := convertRowEventType(e)
-	header := b.buildHeader(e.CommitTs, e.Table.Schema, e.Table.Table, eventType, 1)
-	isDdl := isCanalDDL(eventType) // false
-	rowData, err := b.buildRowData(e)
+	entry, err := b.buildRowEntry(e)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	result, err := entry.toProto()
+	if err != nil {
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	return result, nil
+}
+
+// buildRowEntry builds the Entry of the RowChangedEvent.
+func (b *canalEntryBuilder) buildRowEntry(e *model.RowChangedEvent) (*Entry, error) {
+	header, rowData, err := b.buildRow(e)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return newRowEntry(header, rowData), nil
+}
+
+// buildRow builds the header and the row data of the canal entry of the event.
+func (b *canalEntryBuilder) buildRow(
+	e *model.RowChangedEvent,
+) (*canal.Header, *canal.RowData, error) {
+	eventType := convertRowEventType(e)
+	schema, table := b.mapName(e.Table.Schema, e.Table.Table)
+	header := b.buildHeader(e.CommitTs, schema, table, eventType, 1)
+	b.appendOriginalNames(header, e.Table.Schema, e.Table.Table)
+	b.appendRoutingHints(header)
+	b.appendSequence(header, e.CommitTs)
+	b.appendConsistencyLevel(header)
+	b.appendSQLDigest(header, e)
+	b.appendTimezone(header, e)
+	b.appendSchemaVersion(header, rowSchemaVersion(e))
+	b.appendSchemaFingerprint(header, e)
+	b.appendGTID(header, e.CommitTs)
+	b.appendWindowID(header, e.CommitTs)
+	if err := b.appendFirstSeen(header, e); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if err := b.appendMessageID(header, e); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	b.appendChangedColumns(header, e)
+	checked, err := b.checkSchema(e)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	b.appendHandleType(header, checked.TableInfo)
+	rowData, err := b.buildRowData(checked)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if err := b.appendRowChecksum(header, rowData); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if err := b.appendUpstreamChecksum(header, rowData, e.Checksum); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	b.appendRowSize(header, rowData)
+	return header, rowData, nil
+}
+
+// newRowEntry builds the Entry carrying the rows sharing the header.
+func newRowEntry(header *canal.Header, rowDatas ...*canal.RowData) *Entry {
+	eventType := header.GetEventType()
+	isDdl := isCanalDDL(eventType) // false
 	rc := &canal.RowChange{
 		EventTypePresent: &canal.RowChange_EventType{EventType: eventType},
 		IsDdlPresent:     &canal.RowChange_IsDdl{IsDdl: isDdl},
-		RowDatas:         []*canal.RowData{rowData},
+		RowDatas:         rowDatas,
 	}
-	rcBytes, err := proto.Marshal(rc)
-	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	return &Entry{
+		Header:    header,
+		EntryType: canal.EntryType_ROWDATA,
+		RowChange: rc,
 	}
+}
 
-	// build entry
-	entry := &canal.Entry{
-		Header:           header,
-		EntryTypePresent: &canal.Entry_EntryType{EntryType: canal.EntryType_ROWDATA},
-		StoreValue:       rcBytes,
+// rowKey returns the key of the row serialized by the key serializer, which
+// consists of the schema, the table and the handle key columns of the row.
+// Since the handle key columns are carried by both the pre-image and the
+// post-image, all messages of the same row share the same key.
+func (b *canalEntryBuilder) rowKey(e *model.RowChangedEvent) ([]byte, error) {
+	schema, table := b.mapName(e.Table.Schema, e.Table.Table)
+	handleKeyColumns := e.HandleKeyColumns()
+	columns := make([]KeyColumn, 0, len(handleKeyColumns))
+	for _, col := range handleKeyColumns {
+		javaType, err := getJavaSQLType(col, getMySQLType(col))
+		if err != nil {
+			return nil, encodeError(cerror.ErrCanalUnsupportedType, err)
+		}
+		value, err := b.formatValue(col.Value, javaType)
+		if err != nil {
+			return nil, encodeError(cerror.ErrCanalUnsupportedType, err)
+		}
+		columns = append(columns, KeyColumn{Name: b.columnName(col.Name), Value: value})
 	}
-	return entry, nil
+	data, err := b.keySerializer.SerializeKey(schema, table, columns)
+	if err != nil {
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	return data, nil
+}
+
+// appendRoutingHints stamps the routing metadata into the header props,
+// so that the consumer can validate its own dispatching against the producer.
+func (b *canalEntryBuilder) appendRoutingHints(h *canal.Header) {
+	if !b.config.EnableRoutingHints || b.config.PartitionNum <= 0 ||
+		!b.featureEnabled(featureRoutingHints) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propPartitionCount,
+		Value: strconv.FormatInt(int64(b.config.PartitionNum), 10),
+	})
 }
 
 // fromDDLEvent builds canal entry from cdc DDLEvent
 func (b *canalEntryBuilder) fromDDLEvent(e *model.DDLEvent) (*canal.Entry, error) {
+	entry, err := b.buildDDLEntry(e)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result, err := entry.toProto()
+	if err != nil {
+		return nil, encodeError(cerror.ErrCanalMarshalFailed, err)
+	}
+	return result, nil
+}
+
+// buildDDLEntry builds the Entry of the DDLEvent.
+func (b *canalEntryBuilder) buildDDLEntry(e *model.DDLEvent) (*Entry, error) {
 	eventType := convertDdlEventType(e)
-	header := b.buildHeader(e.CommitTs, e.TableInfo.TableName.Schema, e.TableInfo.TableName.Table, eventType, -1)
+	schema, table := b.mapName(e.TableInfo.TableName.Schema, e.TableInfo.TableName.Table)
+	header := b.buildHeader(e.CommitTs, schema, table, eventType, -1)
+	b.appendOriginalNames(header, e.TableInfo.TableName.Schema, e.TableInfo.TableName.Table)
+	if err := b.appendSchemaCompatibility(header, e); err != nil {
+		return nil, errors.Trace(err)
+	}
+	b.appendSequence(header, e.CommitTs)
+	b.appendConsistencyLevel(header)
+	b.appendDDLClassification(header, e.Type)
+	b.appendSchemaVersion(header, e.TableInfo.TableInfoVersion)
+	b.appendGTID(header, e.CommitTs)
+	b.appendAutoGeneratedColumns(header, e.TableInfo)
+	b.appendNotNullColumns(header, e.TableInfo)
+	b.appendHandleType(header, e.TableInfo)
+	if columns := onUpdateNowColumns(e.TableInfo); len(columns) > 0 &&
+		b.featureEnabled(featureOnUpdateColumns) {
+		for i := range columns {
+			columns[i] = b.columnName(columns[i])
+		}
+		header.Props = append(header.Props, &canal.Pair{
+			Key:   propOnUpdateColumns,
+			Value: strings.Join(columns, ","),
+		})
+	}
+	query, err := b.rewriteDDLQuery(e.Query, e.TableInfo.TableName.Schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if b.config.NormalizeDDLQuery {
+		query = normalizeDDLQuery(query)
+	}
+	query, err = b.compressDDLQuery(header, query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	isDdl := isCanalDDL(eventType)
 	rc := &canal.RowChange{
 		EventTypePresent: &canal.RowChange_EventType{EventType: eventType},
 		IsDdlPresent:     &canal.RowChange_IsDdl{IsDdl: isDdl},
-		Sql:              e.Query,
+		Sql:              query,
 		RowDatas:         nil,
-		DdlSchemaName:    e.TableInfo.TableName.Schema,
-	}
-	rcBytes, err := proto.Marshal(rc)
-	if err != nil {
-		return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		DdlSchemaName:    schema,
 	}
+	return &Entry{
+		Header:    header,
+		EntryType: canal.EntryType_ROWDATA,
+		RowChange: rc,
+	}, nil
+}
 
-	// build entry
-	entry := &canal.Entry{
-		Header:           header,
-		EntryTypePresent: &canal.Entry_EntryType{EntryType: canal.EntryType_ROWDATA},
-		StoreValue:       rcBytes,
+// onUpdateNowColumns returns the name of the columns which are refreshed by
+// TiDB on update, i.e. declared with `ON UPDATE CURRENT_TIMESTAMP`.
+func onUpdateNowColumns(tableInfo *model.TableInfo) []string {
+	if tableInfo == nil || tableInfo.TableInfo == nil {
+		return nil
+	}
+	var result []string
+	for _, col := range tableInfo.Columns {
+		if !model.IsColCDCVisible(col) {
+			continue
+		}
+		if mysql.HasOnUpdateNowFlag(col.GetFlag()) {
+			result = append(result, col.Name.O)
+		}
 	}
-	return entry, nil
+	return result
 }
 
 // convert ts in tidb to timestamp(in ms) in canal
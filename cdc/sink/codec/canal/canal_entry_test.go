@@ -17,10 +17,15 @@ import (
 	"testing"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/errors"
 	mm "github.com/pingcap/tidb/parser/model"
 	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
 	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
 	canal "github.com/pingcap/tiflow/proto/canal"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/encoding/charmap"
@@ -28,7 +33,7 @@ import (
 
 func TestGetMySQLTypeAndJavaSQLType(t *testing.T) {
 	t.Parallel()
-	canalEntryBuilder := newCanalEntryBuilder()
+	canalEntryBuilder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
 	for _, item := range testColumnsTable {
 		obtainedMySQLType := getMySQLType(item.column)
 		require.Equal(t, item.expectedMySQLType, obtainedMySQLType)
@@ -69,7 +74,7 @@ func testInsert(t *testing.T) {
 		},
 	}
 
-	builder := newCanalEntryBuilder()
+	builder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
 	entry, err := builder.fromRowEvent(testCaseInsert)
 	require.Nil(t, err)
 	require.Equal(t, canal.EntryType_ROWDATA, entry.GetEntryType())
@@ -145,7 +150,7 @@ func testUpdate(t *testing.T) {
 			{Name: "name", Type: mysql.TypeVarchar, Value: "Nancy"},
 		},
 	}
-	builder := newCanalEntryBuilder()
+	builder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
 	entry, err := builder.fromRowEvent(testCaseUpdate)
 	require.Nil(t, err)
 	require.Equal(t, canal.EntryType_ROWDATA, entry.GetEntryType())
@@ -218,7 +223,7 @@ func testDelete(t *testing.T) {
 		},
 	}
 
-	builder := newCanalEntryBuilder()
+	builder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
 	entry, err := builder.fromRowEvent(testCaseDelete)
 	require.Nil(t, err)
 	require.Equal(t, canal.EntryType_ROWDATA, entry.GetEntryType())
@@ -261,7 +266,7 @@ func testDdl(t *testing.T) {
 		Query: "create table person(id int, name varchar(32), tiny tinyint unsigned, comment text, primary key(id))",
 		Type:  mm.ActionCreateTable,
 	}
-	builder := newCanalEntryBuilder()
+	builder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
 	entry, err := builder.fromDDLEvent(testCaseDdl)
 	require.Nil(t, err)
 	require.Equal(t, canal.EntryType_ROWDATA, entry.GetEntryType())
@@ -277,3 +282,328 @@ func testDdl(t *testing.T) {
 	require.True(t, rc.GetIsDdl())
 	require.Equal(t, testCaseDdl.TableInfo.TableName.Schema, rc.GetDdlSchemaName())
 }
+
+func TestRoutingHints(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table: &model.TableName{
+			Schema: "cdc",
+			Table:  "person",
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+		},
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.PartitionNum = 3
+	builder := newCanalEntryBuilder(codecConfig)
+	entry, err := builder.fromRowEvent(event)
+	require.Nil(t, err)
+	for _, p := range entry.GetHeader().GetProps() {
+		require.NotEqual(t, propPartitionCount, p.GetKey())
+	}
+
+	codecConfig.EnableRoutingHints = true
+	entry, err = builder.fromRowEvent(event)
+	require.Nil(t, err)
+	props := entry.GetHeader().GetProps()
+	require.Len(t, props, 2)
+	require.Equal(t, propPartitionCount, props[1].GetKey())
+	require.Equal(t, "3", props[1].GetValue())
+}
+
+func TestDDLOnUpdateColumns(t *testing.T) {
+	t.Parallel()
+
+	newColumn := func(name string, tp byte, flag uint) *mm.ColumnInfo {
+		ft := types.NewFieldType(tp)
+		ft.AddFlag(flag)
+		return &mm.ColumnInfo{
+			Name:      mm.NewCIStr(name),
+			FieldType: *ft,
+			State:     mm.StatePublic,
+		}
+	}
+
+	builder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288260,
+		TableInfo: model.WrapTableInfo(1, "cdc", 1, &mm.TableInfo{
+			Name: mm.NewCIStr("person"),
+			Columns: []*mm.ColumnInfo{
+				newColumn("id", mysql.TypeLong, mysql.PriKeyFlag),
+				newColumn("updated_at", mysql.TypeTimestamp, mysql.OnUpdateNowFlag),
+			},
+		}),
+		Query: "create table person(id int primary key, " +
+			"updated_at timestamp default current_timestamp on update current_timestamp)",
+		Type: mm.ActionCreateTable,
+	}
+	entry, err := builder.fromDDLEvent(ddl)
+	require.Nil(t, err)
+	props := entry.GetHeader().GetProps()
+	require.Len(t, props, 1)
+	require.Equal(t, propOnUpdateColumns, props[0].GetKey())
+	require.Equal(t, "updated_at", props[0].GetValue())
+
+	ddl = &model.DDLEvent{
+		CommitTs: 417318403368288260,
+		TableInfo: model.WrapTableInfo(1, "cdc", 1, &mm.TableInfo{
+			Name: mm.NewCIStr("person"),
+			Columns: []*mm.ColumnInfo{
+				newColumn("id", mysql.TypeLong, mysql.PriKeyFlag),
+				newColumn("created_at", mysql.TypeTimestamp, 0),
+			},
+		}),
+		Query: "create table person(id int primary key, created_at timestamp)",
+		Type:  mm.ActionCreateTable,
+	}
+	entry, err = builder.fromDDLEvent(ddl)
+	require.Nil(t, err)
+	require.Empty(t, entry.GetHeader().GetProps())
+}
+
+func TestRowChecksum(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table: &model.TableName{
+			Schema: "cdc",
+			Table:  "person",
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+			{Name: "comment", Type: mysql.TypeBlob, Value: nil},
+		},
+	}
+
+	for _, algorithm := range []string{common.ChecksumAlgorithmCRC32, common.ChecksumAlgorithmXXHash} {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.ChecksumAlgorithm = algorithm
+		builder := newCanalEntryBuilder(codecConfig)
+		entry, err := builder.fromRowEvent(event)
+		require.Nil(t, err)
+
+		props := make(map[string]string)
+		for _, p := range entry.GetHeader().GetProps() {
+			props[p.GetKey()] = p.GetValue()
+		}
+		require.Equal(t, algorithm, props[propChecksumAlgorithm])
+		require.NotEmpty(t, props[propChecksum])
+
+		// the consumer recomputes the checksum over the decoded columns.
+		rc := &canal.RowChange{}
+		err = proto.Unmarshal(entry.GetStoreValue(), rc)
+		require.Nil(t, err)
+		columns := rc.GetRowDatas()[0].GetAfterColumns()
+		checksum, err := rowChecksum(props[propChecksumAlgorithm], columns)
+		require.Nil(t, err)
+		require.Equal(t, props[propChecksum], checksum)
+
+		// any corruption is detected.
+		columns[1].Value = "Alice"
+		checksum, err = rowChecksum(props[propChecksumAlgorithm], columns)
+		require.Nil(t, err)
+		require.NotEqual(t, props[propChecksum], checksum)
+	}
+}
+
+func TestUpstreamChecksum(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table: &model.TableName{
+			Schema: "cdc",
+			Table:  "person",
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+		},
+	}
+	headerProps := func(entry *canal.Entry) map[string]string {
+		props := make(map[string]string)
+		for _, p := range entry.GetHeader().GetProps() {
+			props[p.GetKey()] = p.GetValue()
+		}
+		return props
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.VerifyUpstreamChecksum = true
+	builder := newCanalEntryBuilder(codecConfig)
+
+	// no upstream checksum available, nothing is propagated or verified.
+	entry, err := builder.fromRowEvent(event)
+	require.Nil(t, err)
+	props := headerProps(entry)
+	require.NotContains(t, props, propUpstreamChecksumAlgorithm)
+	require.NotContains(t, props, propUpstreamChecksum)
+
+	rc := &canal.RowChange{}
+	err = proto.Unmarshal(entry.GetStoreValue(), rc)
+	require.Nil(t, err)
+	expected, err := rowChecksum(common.ChecksumAlgorithmCRC32, rc.GetRowDatas()[0].GetAfterColumns())
+	require.Nil(t, err)
+
+	// the upstream checksum is propagated as is.
+	event.Checksum = &model.RowChecksum{
+		Algorithm: common.ChecksumAlgorithmCRC32,
+		Value:     expected,
+	}
+	entry, err = builder.fromRowEvent(event)
+	require.Nil(t, err)
+	props = headerProps(entry)
+	require.Equal(t, common.ChecksumAlgorithmCRC32, props[propUpstreamChecksumAlgorithm])
+	require.Equal(t, expected, props[propUpstreamChecksum])
+
+	// the mismatched checksum is propagated without the verification.
+	event.Checksum = &model.RowChecksum{
+		Algorithm: common.ChecksumAlgorithmCRC32,
+		Value:     "12345",
+	}
+	entry, err = newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal)).fromRowEvent(event)
+	require.Nil(t, err)
+	require.Equal(t, "12345", headerProps(entry)[propUpstreamChecksum])
+
+	// the mismatched checksum is reported with the verification.
+	_, err = builder.fromRowEvent(event)
+	require.True(t, cerror.ErrCanalChecksumMismatch.Equal(errors.Cause(err)))
+}
+
+func TestNullRepresentation(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table: &model.TableName{
+			Schema: "cdc",
+			Table:  "person",
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			{Name: "name", Type: mysql.TypeVarchar, Value: nil},
+			{Name: "comment", Type: mysql.TypeVarchar, Value: "NULL"},
+		},
+	}
+
+	for _, representation := range []string{"", "NULL", "<null>"} {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.NullRepresentation = representation
+		builder := newCanalEntryBuilder(codecConfig)
+		entry, err := builder.fromRowEvent(event)
+		require.Nil(t, err)
+
+		rc := &canal.RowChange{}
+		err = proto.Unmarshal(entry.GetStoreValue(), rc)
+		require.Nil(t, err)
+		columns := rc.GetRowDatas()[0].GetAfterColumns()
+
+		require.False(t, columns[0].GetIsNull())
+		require.Equal(t, "1", columns[0].GetValue())
+		require.True(t, columns[1].GetIsNull())
+		require.Equal(t, representation, columns[1].GetValue())
+		// a value equal to the representation is only told apart by the isNull flag.
+		require.False(t, columns[2].GetIsNull())
+		require.Equal(t, "NULL", columns[2].GetValue())
+	}
+}
+
+func TestTruncateColumnValue(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table: &model.TableName{
+			Schema: "cdc",
+			Table:  "person",
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			{Name: "short", Type: mysql.TypeVarchar, Value: "abc"},
+			// each rune is 3 bytes in UTF-8.
+			{Name: "text", Type: mysql.TypeBlob, Value: []byte("你好世界")},
+			{Name: "blob", Type: mysql.TypeBlob, Flag: model.BinaryFlag, Value: []byte("你好世界")},
+		},
+	}
+	props := func(column *canal.Column) map[string]string {
+		result := make(map[string]string)
+		for _, p := range column.GetProps() {
+			result[p.GetKey()] = p.GetValue()
+		}
+		return result
+	}
+
+	// no truncation by default.
+	entry, err := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal)).fromRowEvent(event)
+	require.Nil(t, err)
+	rc := &canal.RowChange{}
+	err = proto.Unmarshal(entry.GetStoreValue(), rc)
+	require.Nil(t, err)
+	for _, column := range rc.GetRowDatas()[0].GetAfterColumns() {
+		require.Empty(t, column.GetProps())
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.MaxColumnValueLength = 7
+	entry, err = newCanalEntryBuilder(codecConfig).fromRowEvent(event)
+	require.Nil(t, err)
+	rc = &canal.RowChange{}
+	err = proto.Unmarshal(entry.GetStoreValue(), rc)
+	require.Nil(t, err)
+	columns := rc.GetRowDatas()[0].GetAfterColumns()
+
+	require.Equal(t, "1", columns[0].GetValue())
+	require.Empty(t, columns[0].GetProps())
+	require.Equal(t, "abc", columns[1].GetValue())
+	require.Empty(t, columns[1].GetProps())
+
+	// the text is truncated at the rune boundary.
+	require.Equal(t, "你好", columns[2].GetValue())
+	require.Equal(t, map[string]string{
+		propTruncated:      "true",
+		propOriginalLength: "12",
+	}, props(columns[2]))
+
+	// the binary is truncated by bytes.
+	decoded, err := charmap.ISO8859_1.NewEncoder().String(columns[3].GetValue())
+	require.Nil(t, err)
+	require.Equal(t, []byte("你好世界")[:7], []byte(decoded))
+	require.Equal(t, map[string]string{
+		propTruncated:      "true",
+		propOriginalLength: "12",
+	}, props(columns[3]))
+}
+
+func TestJSONArrayColumn(t *testing.T) {
+	t.Parallel()
+
+	// the JSON array is emitted as its JSON text, rather than as a scalar.
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "customers"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			{Name: "zipcodes", Type: mysql.TypeJSON, Value: `[94477, 94536, 94507]`},
+			{Name: "tags", Type: mysql.TypeJSON, Value: `["a", "b"]`},
+			{Name: "empty", Type: mysql.TypeJSON, Value: `[]`},
+		},
+	}
+	entry, err := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal)).fromRowEvent(event)
+	require.Nil(t, err)
+	rc := &canal.RowChange{}
+	require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+	columns := rc.GetRowDatas()[0].GetAfterColumns()
+	require.Len(t, columns, 4)
+	for i, column := range columns[1:] {
+		require.Equal(t, "json", column.GetMysqlType())
+		require.Equal(t, int32(internal.JavaSQLTypeVARCHAR), column.GetSqlType())
+		require.Equal(t, event.Columns[i+1].Value, column.GetValue())
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	gerrors "errors"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// The encode failures are classified into the following classes, so that the
+// sink can tell by errors.Is whether to retry or to stop the changefeed, and
+// all of them are ErrCanalEncodeFailed.
+//   - ErrCanalUnsupportedType: the value can not be encoded as its type,
+//     which is permanent.
+//   - ErrCanalValueTooLarge: the encoded event exceeds the max message bytes,
+//     which is permanent, since there is no way to offload the value.
+//   - ErrCanalMarshalFailed: the entry can not be serialized, which is likely
+//     permanent.
+//   - ErrCanalExternalStoreFailed: the value can not be written to the external
+//     store, which is retryable. It's reserved for the Serializers offloading
+//     the values, whose errors of the class are kept, the canal encoder itself
+//     never returns it.
+//
+// The failures not classified are ErrCanalEncodeFailed only.
+
+// encodeError wraps the err into the class of the encode failure.
+func encodeError(class *errors.Error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return cerror.WrapError(cerror.ErrCanalEncodeFailed, class.Wrap(err))
+}
+
+// serializeError classifies the error returned by the Serializer, which is
+// ErrCanalMarshalFailed unless it's classified as ErrCanalExternalStoreFailed
+// by the Serializer.
+func serializeError(err error) error {
+	if retryable(err) {
+		return cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	}
+	return encodeError(cerror.ErrCanalMarshalFailed, err)
+}
+
+// retryable returns whether the encode failure is retryable, see the classes
+// above.
+func retryable(err error) bool {
+	return gerrors.Is(err, cerror.ErrCanalExternalStoreFailed)
+}
+
+// checkMessageSize returns ErrCanalValueTooLarge if the value of the
+// size can not fit into a message.
+func checkMessageSize(size int, config *common.Config) error {
+	if size+common.MaxRecordOverhead <= config.MaxMessageBytes {
+		return nil
+	}
+	return encodeError(cerror.ErrCanalValueTooLarge,
+		cerror.ErrCanalValueTooLarge.GenWithStackByArgs(size, config.MaxMessageBytes))
+}
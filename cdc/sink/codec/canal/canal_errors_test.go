@@ -0,0 +1,113 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// requireEncodeErrorClass asserts the err is ErrCanalEncodeFailed, and of
+// the class only.
+func requireEncodeErrorClass(t *testing.T, err error, class error) {
+	require.Error(t, err)
+	require.True(t, errors.Is(err, cerror.ErrCanalEncodeFailed), err.Error())
+	for _, c := range []error{
+		cerror.ErrCanalUnsupportedType,
+		cerror.ErrCanalValueTooLarge,
+		cerror.ErrCanalMarshalFailed,
+		cerror.ErrCanalExternalStoreFailed,
+	} {
+		require.Equal(t, c == class, errors.Is(err, c), err.Error())
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	t.Parallel()
+
+	require.Nil(t, encodeError(cerror.ErrCanalMarshalFailed, nil))
+	requireEncodeErrorClass(t,
+		encodeError(cerror.ErrCanalMarshalFailed, errors.New("test")),
+		cerror.ErrCanalMarshalFailed)
+	requireEncodeErrorClass(t,
+		encodeError(cerror.ErrCanalExternalStoreFailed, errors.New("test")),
+		cerror.ErrCanalExternalStoreFailed)
+}
+
+func TestEncodeUnsupportedType(t *testing.T) {
+	t.Parallel()
+
+	encoder := newBatchEncoder(common.NewConfig(config.ProtocolCanal))
+	err := encoder.AppendRowChangedEvent(context.Background(), "", &model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{{
+			Name:  "a",
+			Type:  mysql.TypeLong,
+			Flag:  model.UnsignedFlag,
+			Value: "abc",
+		}},
+	}, nil)
+	requireEncodeErrorClass(t, err, cerror.ErrCanalUnsupportedType)
+}
+
+func TestEncodeValueTooLarge(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal).WithMaxMessageBytes(256)
+	encoder := newBatchEncoder(codecConfig)
+
+	err := encoder.AppendRowChangedEvent(context.Background(), "", &model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{{
+			Name:  "a",
+			Type:  mysql.TypeVarchar,
+			Value: strings.Repeat("a", 1024),
+		}},
+	}, nil)
+	requireEncodeErrorClass(t, err, cerror.ErrCanalValueTooLarge)
+
+	_, err = encoder.EncodeDDLEvent(&model.DDLEvent{
+		CommitTs: 1,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "t"},
+		},
+		Query: "create table t (a varchar(255) comment '" + strings.Repeat("a", 1024) + "')",
+		Type:  mm.ActionCreateTable,
+	})
+	requireEncodeErrorClass(t, err, cerror.ErrCanalValueTooLarge)
+
+	// the small events are not affected.
+	err = encoder.AppendRowChangedEvent(context.Background(), "", &model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{{
+			Name:  "a",
+			Type:  mysql.TypeVarchar,
+			Value: "a",
+		}},
+	}, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,267 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// feature is an optional prop or field of the canal entry, which may not be
+// understood by the old consumers.
+type feature int
+
+const (
+	// featureOnUpdateColumns emits the `onUpdateColumns` prop of the DDL entries.
+	featureOnUpdateColumns feature = iota
+	// featureRoutingHints emits the `partitionCount` prop of the row entries.
+	featureRoutingHints
+	// featureRowChecksum emits the `checksumAlgorithm` and `checksum` props.
+	featureRowChecksum
+	// featureUpstreamChecksum emits the `upstreamChecksumAlgorithm` and
+	// `upstreamChecksum` props.
+	featureUpstreamChecksum
+	// featureColumnCharset emits the `charset` prop of the columns.
+	featureColumnCharset
+	// featureSequence emits the `sequence` prop of the entries.
+	featureSequence
+	// featureTxnRowCount emits the `txnRowCount` prop of the row entries.
+	featureTxnRowCount
+	// featureRawStorageValue emits the `rawValue` prop of the columns.
+	featureRawStorageValue
+	// featureConsistencyLevel emits the `consistencyLevel` prop of the entries.
+	featureConsistencyLevel
+	// featureSQLDigest emits the `sqlDigest` prop of the row entries.
+	featureSQLDigest
+	// featureDDLClassification emits the `affectsData` prop of the DDL entries.
+	featureDDLClassification
+	// featureDDLCompression emits the `ddlCompression` prop of the DDL entries.
+	featureDDLCompression
+	// featureDeclaredType emits the `declaredType` prop of the columns.
+	featureDeclaredType
+	// featureColumnOrdinal emits the `index` field of the columns.
+	featureColumnOrdinal
+	// featureSchemaVersion emits the `schemaVersion` prop of the entries.
+	featureSchemaVersion
+	// featureWindowID emits the `windowId` prop of the row entries.
+	featureWindowID
+	// featureFirstSeen emits the `firstSeen` prop of the row entries.
+	featureFirstSeen
+	// featureMessageID emits the `messageId` prop of the row entries.
+	featureMessageID
+	// featureAutoGenerated emits the `autoGenerated` prop of the columns, and
+	// the `autoIncrementColumns` and `autoRandomColumns` props of the DDL entries.
+	featureAutoGenerated
+	// featureRowSize emits the `rowSize` prop of the row entries.
+	featureRowSize
+	// featureJSONPatch emits the JSON Patch as the value of the JSON columns,
+	// flagged by the `jsonPatch` prop.
+	featureJSONPatch
+	// featureChangedColumns emits the `changedColumns` prop of the row entries.
+	featureChangedColumns
+	// featureSchemaFingerprint emits the `schemaFingerprint` prop of the row entries.
+	featureSchemaFingerprint
+	// featureGTID emits the `gtid` field and the `curtGtid` prop of the entries.
+	featureGTID
+	// featureSchemaURL emits the `schemaUrl` prop of the row entries.
+	featureSchemaURL
+	// featureNullability emits the `nullable` prop of the columns, and the
+	// `notNullColumns` prop of the DDL entries.
+	featureNullability
+	// featureHandle emits the `handle` prop of the columns, and the
+	// `handleType` prop of the entries.
+	featureHandle
+	// featureTimezone emits the `timezone` prop of the row entries.
+	featureTimezone
+	// featureOriginalNames emits the `originalSchema` and the
+	// `originalTable` props of the entries.
+	featureOriginalNames
+	// featureSchemaCompatibility emits the `schemaCompatibility` and the
+	// `breakingChanges` props of the DDL entries.
+	featureSchemaCompatibility
+)
+
+// featureLevels maps each feature to the feature level introduced it.
+// The level 0 is the output before the feature level is introduced, to add a
+// feature, define it above and register it here with a new level, then check
+// it by featureEnabled where the prop or field is emitted.
+var featureLevels = map[feature]int{
+	featureOnUpdateColumns:     1,
+	featureRoutingHints:        1,
+	featureRowChecksum:         1,
+	featureUpstreamChecksum:    1,
+	featureColumnCharset:       2,
+	featureSequence:            3,
+	featureTxnRowCount:         3,
+	featureRawStorageValue:     3,
+	featureConsistencyLevel:    3,
+	featureSQLDigest:           3,
+	featureDDLClassification:   3,
+	featureDDLCompression:      3,
+	featureDeclaredType:        3,
+	featureColumnOrdinal:       3,
+	featureSchemaVersion:       3,
+	featureWindowID:            3,
+	featureFirstSeen:           3,
+	featureMessageID:           3,
+	featureAutoGenerated:       3,
+	featureRowSize:             3,
+	featureJSONPatch:           3,
+	featureChangedColumns:      3,
+	featureSchemaFingerprint:   3,
+	featureGTID:                3,
+	featureSchemaURL:           3,
+	featureNullability:         3,
+	featureHandle:              3,
+	featureTimezone:            3,
+	featureOriginalNames:       3,
+	featureSchemaCompatibility: 3,
+}
+
+// featureEnabled returns whether the feature is supported by the consumer,
+// i.e. the feature level configured is not lower than the level of the feature.
+func (b *canalEntryBuilder) featureEnabled(f feature) bool {
+	return b.config.FeatureLevel >= featureLevels[f]
+}
+
+// featureOptions maps each feature requested by an option to the name of the
+// option and whether it's requested by the config. The features emitted
+// regardless of the config, e.g. the charset of the columns, are not listed.
+var featureOptions = map[feature]struct {
+	name      string
+	requested func(c *common.Config) bool
+}{
+	featureRoutingHints: {"enable-routing-hints", func(c *common.Config) bool {
+		return c.EnableRoutingHints && c.PartitionNum > 0
+	}},
+	featureRowChecksum: {"checksum-algorithm", func(c *common.Config) bool {
+		return c.ChecksumAlgorithm != ""
+	}},
+	featureSequence: {"enable-sequence", func(c *common.Config) bool {
+		return c.EnableSequence
+	}},
+	featureTxnRowCount: {"enable-txn-row-count", func(c *common.Config) bool {
+		return c.EnableTxnRowCount
+	}},
+	featureRawStorageValue: {"enable-raw-storage-value", func(c *common.Config) bool {
+		return c.EnableRawStorageValue
+	}},
+	featureConsistencyLevel: {"enable-consistency-level", func(c *common.Config) bool {
+		return c.EnableConsistencyLevel
+	}},
+	featureSQLDigest: {"enable-sql-digest", func(c *common.Config) bool {
+		return c.EnableSQLDigest
+	}},
+	featureDDLClassification: {"enable-ddl-classification", func(c *common.Config) bool {
+		return c.EnableDDLClassification
+	}},
+	featureDDLCompression: {"ddl-compression-threshold", func(c *common.Config) bool {
+		return c.DDLCompressionThreshold != 0
+	}},
+	featureDeclaredType: {"enable-declared-type", func(c *common.Config) bool {
+		return c.EnableDeclaredType
+	}},
+	featureColumnOrdinal: {"enable-column-ordinal", func(c *common.Config) bool {
+		return c.EnableColumnOrdinal
+	}},
+	featureSchemaVersion: {"enable-schema-version", func(c *common.Config) bool {
+		return c.EnableSchemaVersion
+	}},
+	featureWindowID: {"window-size", func(c *common.Config) bool {
+		return c.WindowSize > 0
+	}},
+	featureFirstSeen: {"first-seen-keys", func(c *common.Config) bool {
+		return c.FirstSeenKeys > 0
+	}},
+	featureMessageID: {"enable-message-id", func(c *common.Config) bool {
+		return c.EnableMessageID
+	}},
+	featureAutoGenerated: {"enable-auto-generated", func(c *common.Config) bool {
+		return c.EnableAutoGenerated
+	}},
+	featureNullability: {"enable-nullability", func(c *common.Config) bool {
+		return c.EnableNullability
+	}},
+	featureHandle: {"enable-handle", func(c *common.Config) bool {
+		return c.EnableHandle
+	}},
+	featureTimezone: {"enable-timezone", func(c *common.Config) bool {
+		return c.EnableTimezone
+	}},
+	featureOriginalNames: {"identifier-handling", func(c *common.Config) bool {
+		return c.IdentifierHandling != "" && c.IdentifierHandling != common.IdentifierHandlingNone
+	}},
+	featureSchemaCompatibility: {"schema-compatibility", func(c *common.Config) bool {
+		return c.SchemaCompatibility == common.SchemaCompatibilityFlag
+	}},
+	featureRowSize: {"row-size", func(c *common.Config) bool {
+		return c.RowSize != ""
+	}},
+	featureJSONPatch: {"enable-json-patch", func(c *common.Config) bool {
+		return c.EnableJSONPatch
+	}},
+	featureChangedColumns: {"changed-columns", func(c *common.Config) bool {
+		return c.ChangedColumns != ""
+	}},
+	featureSchemaFingerprint: {"enable-schema-fingerprint", func(c *common.Config) bool {
+		return c.EnableSchemaFingerprint
+	}},
+	featureGTID: {"enable-gtid", func(c *common.Config) bool {
+		return c.EnableGTID
+	}},
+	featureSchemaURL: {"schema-url-base", func(c *common.Config) bool {
+		return c.SchemaURLBase != ""
+	}},
+}
+
+// downgradedOptions returns the sorted names of the options requesting the
+// features above the feature level configured, which are dropped silently
+// by the encoder.
+func downgradedOptions(config *common.Config) []string {
+	var result []string
+	for f, option := range featureOptions {
+		if config.FeatureLevel < featureLevels[f] && option.requested(config) {
+			result = append(result, option.name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CheckFeatureLevel reports the options requesting the features not supported
+// by the feature level configured. If strict-feature-level is set, an error is
+// returned, otherwise the downgrade is logged once, and the features are
+// dropped from the output.
+func CheckFeatureLevel(config *common.Config) error {
+	options := downgradedOptions(config)
+	if len(options) == 0 {
+		return nil
+	}
+	if config.StrictFeatureLevel {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`%s not supported by feature-level %d`,
+			strings.Join(options, ", "), config.FeatureLevel,
+		)
+	}
+	log.Warn("the features requested are not supported by the feature level, "+
+		"they are dropped from the output",
+		zap.Strings("options", options),
+		zap.Int("featureLevel", config.FeatureLevel))
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureLevel(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table: &model.TableName{
+			Schema: "cdc",
+			Table:  "person",
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			{Name: "name", Type: mysql.TypeVarchar, Charset: "utf8mb4", Value: "Bob"},
+		},
+		Checksum: &model.RowChecksum{
+			Algorithm: common.ChecksumAlgorithmCRC32,
+			Value:     "12345",
+		},
+	}
+	newConfig := func(level int) *common.Config {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.FeatureLevel = level
+		codecConfig.EnableRoutingHints = true
+		codecConfig.PartitionNum = 3
+		codecConfig.ChecksumAlgorithm = common.ChecksumAlgorithmCRC32
+		return codecConfig
+	}
+	encode := func(codecConfig *common.Config) (*canal.Entry, []byte) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(event)
+		require.Nil(t, err)
+		data, err := proto.Marshal(entry)
+		require.Nil(t, err)
+		return entry, data
+	}
+	props := func(entry *canal.Entry) (map[string]string, map[string]string) {
+		headerProps := make(map[string]string)
+		for _, p := range entry.GetHeader().GetProps() {
+			headerProps[p.GetKey()] = p.GetValue()
+		}
+		rc := &canal.RowChange{}
+		err := proto.Unmarshal(entry.GetStoreValue(), rc)
+		require.Nil(t, err)
+		columnProps := make(map[string]string)
+		for _, column := range rc.GetRowDatas()[0].GetAfterColumns() {
+			for _, p := range column.GetProps() {
+				columnProps[column.GetName()+"."+p.GetKey()] = p.GetValue()
+			}
+		}
+		return headerProps, columnProps
+	}
+
+	// the level 0 output is byte-stable, it's the same as the output
+	// without any optional features, no matter which one is configured.
+	plainConfig := common.NewConfig(config.ProtocolCanal)
+	plainConfig.FeatureLevel = 0
+	_, expected := encode(plainConfig)
+	entry, data := encode(newConfig(0))
+	require.Equal(t, expected, data)
+	headerProps, columnProps := props(entry)
+	require.Equal(t, map[string]string{propRowsCount: "1"}, headerProps)
+	require.Empty(t, columnProps)
+
+	// the level 1 introduces the routing hints and the checksums.
+	entry, _ = encode(newConfig(1))
+	headerProps, columnProps = props(entry)
+	require.Equal(t, "3", headerProps[propPartitionCount])
+	require.Equal(t, common.ChecksumAlgorithmCRC32, headerProps[propChecksumAlgorithm])
+	require.NotEmpty(t, headerProps[propChecksum])
+	require.Equal(t, "12345", headerProps[propUpstreamChecksum])
+	require.Empty(t, columnProps)
+
+	// the level 2 introduces the charset of the columns.
+	for _, level := range []int{2, common.FeatureLevelLatest} {
+		entry, _ = encode(newConfig(level))
+		headerProps, columnProps = props(entry)
+		require.Equal(t, "3", headerProps[propPartitionCount])
+		require.Equal(t, map[string]string{"name." + propCharset: "utf8mb4"}, columnProps)
+	}
+}
+
+func TestDowngradedOptions(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableRoutingHints = true
+	codecConfig.PartitionNum = 3
+	codecConfig.ChecksumAlgorithm = common.ChecksumAlgorithmCRC32
+	codecConfig.EnableSequence = true
+	codecConfig.EnableMessageID = true
+
+	// nothing is dropped by the latest feature level.
+	require.Empty(t, downgradedOptions(codecConfig))
+	require.Nil(t, CheckFeatureLevel(codecConfig))
+
+	// the options above the feature level are reported.
+	codecConfig.FeatureLevel = 2
+	require.Equal(t, []string{"enable-message-id", "enable-sequence"},
+		downgradedOptions(codecConfig))
+	codecConfig.FeatureLevel = 0
+	require.Equal(t, []string{
+		"checksum-algorithm", "enable-message-id",
+		"enable-routing-hints", "enable-sequence",
+	}, downgradedOptions(codecConfig))
+
+	// the downgrade is only logged by default.
+	require.Nil(t, CheckFeatureLevel(codecConfig))
+	codecConfig.StrictFeatureLevel = true
+	err := CheckFeatureLevel(codecConfig)
+	require.True(t, cerror.ErrCodecInvalidConfig.Equal(err))
+	require.ErrorContains(t, err, "checksum-algorithm, enable-message-id, "+
+		"enable-routing-hints, enable-sequence not supported by feature-level 0")
+
+	// the options not requesting the features are not reported.
+	codecConfig.PartitionNum = 0
+	codecConfig.ChecksumAlgorithm = ""
+	codecConfig.EnableSequence = false
+	codecConfig.EnableMessageID = false
+	require.Empty(t, downgradedOptions(codecConfig))
+	require.Nil(t, CheckFeatureLevel(codecConfig))
+}
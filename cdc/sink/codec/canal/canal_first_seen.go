@@ -0,0 +1,111 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// seenKeys remembers the keys of the rows seen by table, to tell whether the
+// key of a row is seen for the first time in the stream, for the consumers
+// treating the INSERT and the UPDATE uniformly as the upsert.
+//
+// To bound the memory, the keys remembered for a table are forgotten all at
+// once, when the number of them reaches the limit, or the ttl elapses since
+// they are forgotten last time. Hence the flag is approximate: a key seen
+// before may be flagged as first seen again after it's forgotten, but a key
+// flagged as not first seen is always seen before.
+//
+// It's shared by all the encoders built by the same builder, since the rows
+// of the same key may be encoded by different encoders.
+type seenKeys struct {
+	mu     sync.Mutex
+	limit  int
+	ttl    time.Duration
+	tables map[model.TableName]*seenKeySet
+	// now is replaced in the tests.
+	now func() time.Time
+}
+
+type seenKeySet struct {
+	keys    map[string]struct{}
+	resetAt time.Time
+}
+
+// newSeenKeys returns nil if the first seen flag is disabled.
+func newSeenKeys(config *common.Config) *seenKeys {
+	if config.FirstSeenKeys <= 0 {
+		return nil
+	}
+	return &seenKeys{
+		limit:  config.FirstSeenKeys,
+		ttl:    config.FirstSeenTTL,
+		tables: make(map[model.TableName]*seenKeySet),
+		now:    time.Now,
+	}
+}
+
+// see remembers the key of the table, and returns whether it's seen for the
+// first time since the keys of the table are forgotten last time.
+func (s *seenKeys) see(table model.TableName, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	set, ok := s.tables[table]
+	if !ok {
+		set = &seenKeySet{keys: make(map[string]struct{}), resetAt: now}
+		s.tables[table] = set
+	}
+	if s.ttl > 0 && now.Sub(set.resetAt) >= s.ttl {
+		set.keys = make(map[string]struct{})
+		set.resetAt = now
+	}
+	if _, ok := set.keys[key]; ok {
+		return false
+	}
+	if len(set.keys) >= s.limit {
+		set.keys = make(map[string]struct{})
+		set.resetAt = now
+	}
+	set.keys[key] = struct{}{}
+	return true
+}
+
+// appendFirstSeen stamps whether the key of the row is seen for the first
+// time into the header props. The prop is omitted if the row has no handle
+// key, since it can not be identified.
+func (b *canalEntryBuilder) appendFirstSeen(h *canal.Header, e *model.RowChangedEvent) error {
+	if b.seenKeys == nil || !b.featureEnabled(featureFirstSeen) ||
+		len(e.HandleKeyColumns()) == 0 {
+		return nil
+	}
+	key, err := b.rowKey(e)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// the partitions of a table share the keys.
+	table := model.TableName{Schema: e.Table.Schema, Table: e.Table.Table}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propFirstSeen,
+		Value: strconv.FormatBool(b.seenKeys.see(table, string(key))),
+	})
+	return nil
+}
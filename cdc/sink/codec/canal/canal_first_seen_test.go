@@ -0,0 +1,114 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirstSeen(t *testing.T) {
+	t.Parallel()
+
+	keyFlag := model.HandleKeyFlag | model.PrimaryKeyFlag
+	newRow := func(table string, id int64, flag model.ColumnFlagType) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: flag, Value: id},
+				{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+			},
+		}
+	}
+	// firstSeen returns the firstSeen prop of the row entry and whether it's present.
+	firstSeen := func(b *canalEntryBuilder, e *model.RowChangedEvent) (string, bool) {
+		entry, err := b.fromRowEvent(e)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propFirstSeen {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+	requireFirstSeen := func(b *canalEntryBuilder, e *model.RowChangedEvent, expected string) {
+		value, ok := firstSeen(b, e)
+		require.True(t, ok)
+		require.Equal(t, expected, value)
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.FirstSeenKeys = 2
+	codecConfig.FirstSeenTTL = time.Minute
+	b := newCanalEntryBuilder(codecConfig)
+	now := time.Now()
+	b.seenKeys.now = func() time.Time { return now }
+
+	// the repeated key is not first seen, whether it's inserted or updated.
+	requireFirstSeen(b, newRow("t", 1, keyFlag), "true")
+	requireFirstSeen(b, newRow("t", 1, keyFlag), "false")
+	update := newRow("t", 1, keyFlag)
+	update.PreColumns = update.Columns
+	requireFirstSeen(b, update, "false")
+
+	// the keys are remembered by table.
+	requireFirstSeen(b, newRow("t", 2, keyFlag), "true")
+	requireFirstSeen(b, newRow("t1", 1, keyFlag), "true")
+	requireFirstSeen(b, newRow("t", 2, keyFlag), "false")
+
+	// the keys of the table are forgotten once the limit is hit, so the key
+	// seen before is flagged as first seen again.
+	requireFirstSeen(b, newRow("t", 3, keyFlag), "true")
+	requireFirstSeen(b, newRow("t", 3, keyFlag), "false")
+	requireFirstSeen(b, newRow("t", 1, keyFlag), "true")
+	requireFirstSeen(b, newRow("t1", 1, keyFlag), "false")
+
+	// the keys are forgotten once the ttl elapses.
+	now = now.Add(time.Minute)
+	requireFirstSeen(b, newRow("t", 1, keyFlag), "true")
+	requireFirstSeen(b, newRow("t1", 1, keyFlag), "true")
+
+	// the prop is omitted if the row has no handle key.
+	_, ok := firstSeen(b, newRow("t", 1, 0))
+	require.False(t, ok)
+
+	// the prop is omitted if disabled.
+	_, ok = firstSeen(newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal)), newRow("t", 1, keyFlag))
+	require.False(t, ok)
+	codecConfig.FeatureLevel = 2
+	_, ok = firstSeen(b, newRow("t", 4, keyFlag))
+	require.False(t, ok)
+}
+
+func TestFirstSeenSharedByEncoders(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.FirstSeenKeys = 16
+	builder := NewBatchEncoderBuilder(codecConfig)
+	first := builder.Build().(*BatchEncoder).entryBuilder
+	second := builder.Build().(*BatchEncoder).entryBuilder
+	require.Same(t, first.seenKeys, second.seenKeys)
+
+	table := model.TableName{Schema: "test", Table: "t"}
+	require.True(t, first.seenKeys.see(table, "1"))
+	require.False(t, second.seenKeys.see(table, "1"))
+}
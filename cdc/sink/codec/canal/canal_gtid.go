@@ -0,0 +1,53 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// gtidSourceID returns the source id of the GTID of the changefeed, which is
+// the name-based UUID (version 5) of `{namespace}/{changefeed}` in the nil
+// UUID namespace, e.g. the source id of `default/test` is
+// `85dec281-7006-5713-9208-18706a52809f`.
+func gtidSourceID(id model.ChangeFeedID) string {
+	return uuid.NewSHA1(uuid.Nil, []byte(id.Namespace+"/"+id.ID)).String()
+}
+
+// formatGTID returns the GTID of the transaction committed at the commitTs,
+// which is `{source id}:{commit ts}`, e.g.
+// `85dec281-7006-5713-9208-18706a52809f:417318403368288260`, resembling the
+// `source_id:transaction_id` of the MySQL GTID. Since the commit ts is the
+// transaction id, the rows of a transaction share the GTID, and the GTIDs
+// of the changefeed increase in the commit order, i.e. they're monotonic
+// among the entries of a table, which are emitted in the commit order.
+func formatGTID(sourceID string, commitTs uint64) string {
+	return sourceID + ":" + strconv.FormatUint(commitTs, 10)
+}
+
+// appendGTID stamps the GTID of the event into the gtid field of the header,
+// and the `curtGtid` prop as the canal server does, so that the consumers
+// migrated from the MySQL binlog replication track the position by them.
+func (b *canalEntryBuilder) appendGTID(h *canal.Header, commitTs uint64) {
+	if !b.config.EnableGTID || !b.featureEnabled(featureGTID) {
+		return
+	}
+	gtid := formatGTID(b.gtidSourceID, commitTs)
+	h.Gtid = gtid
+	h.Props = append(h.Props, &canal.Pair{Key: propGTID, Value: gtid})
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	timodel "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGTID(t *testing.T) {
+	t.Parallel()
+
+	changefeedID := model.ChangeFeedID{Namespace: "default", ID: "test"}
+	require.Equal(t, "85dec281-7006-5713-9208-18706a52809f", gtidSourceID(changefeedID))
+	require.NotEqual(t, gtidSourceID(changefeedID),
+		gtidSourceID(model.ChangeFeedID{Namespace: "default", ID: "test2"}))
+
+	newRow := func(commitTs uint64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			},
+		}
+	}
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableGTID = true
+	builder := newCanalEntryBuilder(codecConfig)
+	builder.gtidSourceID = gtidSourceID(changefeedID)
+
+	// gtidOf returns the GTID of the header, which must be the same as
+	// the curtGtid prop.
+	gtidOf := func(h *canal.Header) string {
+		for _, p := range h.GetProps() {
+			if p.GetKey() == propGTID {
+				require.Equal(t, h.GetGtid(), p.GetValue())
+				return h.GetGtid()
+			}
+		}
+		require.Empty(t, h.GetGtid())
+		return ""
+	}
+	var headers []*canal.Header
+	for _, commitTs := range []uint64{417318403368288260, 417318403368288260, 417318403368288262} {
+		entry, err := builder.fromRowEvent(newRow(commitTs))
+		require.Nil(t, err)
+		headers = append(headers, entry.GetHeader())
+	}
+	entry, err := builder.fromDDLEvent(&model.DDLEvent{
+		CommitTs: 417318403368288270,
+		Query:    "alter table test.t add column c int",
+		Type:     timodel.ActionAddColumn,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "t"},
+		},
+	})
+	require.Nil(t, err)
+	headers = append(headers, entry.GetHeader())
+
+	// the GTIDs are parseable by the documented format, and monotonic.
+	format := regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}):([0-9]+)$`)
+	var last uint64
+	for _, h := range headers {
+		parts := format.FindStringSubmatch(gtidOf(h))
+		require.Len(t, parts, 3)
+		sourceID, err := uuid.Parse(parts[1])
+		require.Nil(t, err)
+		require.Equal(t, uuid.NewSHA1(uuid.Nil, []byte("default/test")), sourceID)
+		transactionID, err := strconv.ParseUint(parts[2], 10, 64)
+		require.Nil(t, err)
+		require.GreaterOrEqual(t, transactionID, last)
+		last = transactionID
+	}
+	// the rows of a transaction share the GTID.
+	require.Equal(t, headers[0].GetGtid(), headers[1].GetGtid())
+	require.NotEqual(t, headers[1].GetGtid(), headers[2].GetGtid())
+	require.Equal(t, "85dec281-7006-5713-9208-18706a52809f:417318403368288262", headers[2].GetGtid())
+
+	// the GTID is omitted if disabled, or for the old consumers.
+	entry, err = newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal)).
+		fromRowEvent(newRow(417318403368288260))
+	require.Nil(t, err)
+	require.Empty(t, gtidOf(entry.GetHeader()))
+	codecConfig.FeatureLevel = 2
+	entry, err = newCanalEntryBuilder(codecConfig).fromRowEvent(newRow(417318403368288260))
+	require.Nil(t, err)
+	require.Empty(t, gtidOf(entry.GetHeader()))
+}
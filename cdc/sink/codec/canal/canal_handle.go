@@ -0,0 +1,107 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// the values of the handleType prop, i.e. how the rows of the table are
+// identified in the storage of TiDB.
+const (
+	// handleTypeInt is the integer primary key as the handle.
+	handleTypeInt = "int"
+	// handleTypeCommon is the clustered primary key as the handle,
+	// which may be composite and of any type.
+	handleTypeCommon = "common"
+	// handleTypeRowID is the hidden _tidb_rowid as the handle, which is
+	// not a column of the row, e.g. for the non-clustered primary key.
+	handleTypeRowID = "rowid"
+)
+
+// tableHandle returns the type of the handle of the table, and the names of
+// the handle columns in the order of the handle, which is the order of the
+// columns in the clustered index for the common handle. It's inspected from
+// the table info instead of the flags of the columns, since the handle key
+// flag marks the index used to identify the row by TiCDC, which is not the
+// handle of the storage, e.g. the unique index of the table without the
+// primary key. It returns the empty type if the schema of the table is unknown.
+func tableHandle(tableInfo *model.TableInfo) (string, []string) {
+	if tableInfo == nil || tableInfo.TableInfo == nil {
+		return "", nil
+	}
+	switch {
+	case tableInfo.PKIsHandle:
+		if col := tableInfo.GetPkColInfo(); col != nil {
+			return handleTypeInt, []string{col.Name.O}
+		}
+	case tableInfo.IsCommonHandle:
+		for _, idx := range tableInfo.Indices {
+			if !idx.Primary {
+				continue
+			}
+			columns := make([]string, 0, len(idx.Columns))
+			for _, col := range idx.Columns {
+				columns = append(columns, tableInfo.Columns[col.Offset].Name.O)
+			}
+			return handleTypeCommon, columns
+		}
+	}
+	return handleTypeRowID, nil
+}
+
+// handleOrdinals maps the handle columns of the table of the row to their
+// 1-based ordinals in the handle, nil if the handle is not flagged.
+func (b *canalEntryBuilder) handleOrdinals(e *model.RowChangedEvent) map[string]int {
+	if !b.config.EnableHandle || !b.featureEnabled(featureHandle) {
+		return nil
+	}
+	_, columns := tableHandle(e.TableInfo)
+	if len(columns) == 0 {
+		return nil
+	}
+	result := make(map[string]int, len(columns))
+	for i, name := range columns {
+		result[name] = i + 1
+	}
+	return result
+}
+
+// appendHandle stamps the ordinal of the column in the handle into the props,
+// if it's a handle column.
+func (b *canalEntryBuilder) appendHandle(column *canal.Column, ordinal int) {
+	if ordinal == 0 {
+		return
+	}
+	column.Props = append(column.Props, &canal.Pair{
+		Key:   propHandle,
+		Value: strconv.Itoa(ordinal),
+	})
+}
+
+// appendHandleType stamps the type of the handle of the table into the header
+// props, it's omitted if the schema of the table is unknown.
+func (b *canalEntryBuilder) appendHandleType(h *canal.Header, tableInfo *model.TableInfo) {
+	if !b.config.EnableHandle || !b.featureEnabled(featureHandle) {
+		return
+	}
+	handleType, _ := tableHandle(tableInfo)
+	if handleType == "" {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{Key: propHandleType, Value: handleType})
+}
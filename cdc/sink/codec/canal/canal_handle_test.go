@@ -0,0 +1,212 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	newColumn := func(offset int, name string, tp byte, flag uint) *mm.ColumnInfo {
+		col := &mm.ColumnInfo{
+			ID:        int64(offset + 1),
+			Name:      mm.NewCIStr(name),
+			Offset:    offset,
+			FieldType: *types.NewFieldType(tp),
+			State:     mm.StatePublic,
+		}
+		col.AddFlag(flag)
+		return col
+	}
+	newPrimary := func(columns ...*mm.ColumnInfo) *mm.IndexInfo {
+		idx := &mm.IndexInfo{
+			ID:      1,
+			Name:    mm.NewCIStr("PRIMARY"),
+			Primary: true,
+			Unique:  true,
+			State:   mm.StatePublic,
+		}
+		for _, col := range columns {
+			idx.Columns = append(idx.Columns, &mm.IndexColumn{
+				Name: col.Name, Offset: col.Offset, Length: -1,
+			})
+		}
+		return idx
+	}
+	pkFlag := uint(mysql.PriKeyFlag | mysql.NotNullFlag)
+
+	// `t(id bigint primary key, name varchar(64), age int)`.
+	intID := newColumn(0, "id", mysql.TypeLonglong, pkFlag)
+	intHandle := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:         1,
+		Name:       mm.NewCIStr("t"),
+		PKIsHandle: true,
+		Columns: []*mm.ColumnInfo{
+			intID,
+			newColumn(1, "name", mysql.TypeVarchar, 0),
+			newColumn(2, "age", mysql.TypeLong, 0),
+		},
+		Indices: []*mm.IndexInfo{newPrimary(intID)},
+	})
+	// `t(id varchar(64) primary key clustered, name varchar(64), age int)`.
+	commonID := newColumn(0, "id", mysql.TypeVarchar, pkFlag)
+	commonHandle := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:             2,
+		Name:           mm.NewCIStr("t"),
+		IsCommonHandle: true,
+		Columns: []*mm.ColumnInfo{
+			commonID,
+			newColumn(1, "name", mysql.TypeVarchar, 0),
+			newColumn(2, "age", mysql.TypeLong, 0),
+		},
+		Indices: []*mm.IndexInfo{newPrimary(commonID)},
+	})
+	// `t(id varchar(64), name varchar(64), age int,
+	// primary key(age, id) clustered)`, whose handle is not in the order of
+	// the columns.
+	compositeID := newColumn(0, "id", mysql.TypeVarchar, pkFlag)
+	compositeAge := newColumn(2, "age", mysql.TypeLong, pkFlag)
+	compositeHandle := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:             3,
+		Name:           mm.NewCIStr("t"),
+		IsCommonHandle: true,
+		Columns: []*mm.ColumnInfo{
+			compositeID,
+			newColumn(1, "name", mysql.TypeVarchar, 0),
+			compositeAge,
+		},
+		Indices: []*mm.IndexInfo{newPrimary(compositeAge, compositeID)},
+	})
+	// `t(id varchar(64) primary key nonclustered, name varchar(64), age int)`.
+	rowID := newColumn(0, "id", mysql.TypeVarchar, pkFlag)
+	rowIDHandle := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:   4,
+		Name: mm.NewCIStr("t"),
+		Columns: []*mm.ColumnInfo{
+			rowID,
+			newColumn(1, "name", mysql.TypeVarchar, 0),
+			newColumn(2, "age", mysql.TypeLong, 0),
+		},
+		Indices: []*mm.IndexInfo{newPrimary(rowID)},
+	})
+
+	// handle returns the handleType prop of the row entry of the table, and
+	// the handle prop of the columns, in the before and the after columns.
+	handle := func(
+		codecConfig *common.Config, tableInfo *model.TableInfo,
+	) (string, map[string]string, map[string]string) {
+		// the id is a bigint in the int handle table, a varchar otherwise.
+		idType, id := mysql.TypeVarchar, interface{}([]byte("1"))
+		if tableInfo == intHandle {
+			idType, id = mysql.TypeLonglong, int64(1)
+		}
+		columns := func(name string) []*model.Column {
+			return []*model.Column{
+				{Name: "id", Type: idType, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: id},
+				{Name: "name", Type: mysql.TypeVarchar, Flag: model.NullableFlag, Value: []byte(name)},
+				{Name: "age", Type: mysql.TypeLong, Flag: model.NullableFlag, Value: int64(18)},
+			}
+		}
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(&model.RowChangedEvent{
+			CommitTs:   417318403368288260,
+			Table:      &model.TableName{Schema: "test", Table: "t"},
+			TableInfo:  tableInfo,
+			PreColumns: columns("bob"),
+			Columns:    columns("alice"),
+		})
+		require.NoError(t, err)
+		handleType := ""
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propHandleType {
+				handleType = p.GetValue()
+			}
+		}
+		rc := &canal.RowChange{}
+		require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		collect := func(columns []*canal.Column) map[string]string {
+			result := make(map[string]string)
+			for _, column := range columns {
+				for _, p := range column.GetProps() {
+					if p.GetKey() == propHandle {
+						result[column.GetName()] = p.GetValue()
+					}
+				}
+			}
+			return result
+		}
+		rowData := rc.GetRowDatas()[0]
+		return handleType, collect(rowData.GetBeforeColumns()), collect(rowData.GetAfterColumns())
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableHandle = true
+	for _, tc := range []struct {
+		tableInfo  *model.TableInfo
+		handleType string
+		expected   map[string]string
+	}{
+		{intHandle, handleTypeInt, map[string]string{"id": "1"}},
+		{commonHandle, handleTypeCommon, map[string]string{"id": "1"}},
+		{compositeHandle, handleTypeCommon, map[string]string{"age": "1", "id": "2"}},
+		{rowIDHandle, handleTypeRowID, map[string]string{}},
+	} {
+		handleType, before, after := handle(codecConfig, tc.tableInfo)
+		require.Equal(t, tc.handleType, handleType)
+		require.Equal(t, tc.expected, before)
+		require.Equal(t, tc.expected, after)
+	}
+
+	// the handle type of the table is stamped into the DDL entry as well.
+	entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(&model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     "create table t(...)",
+		Type:      mm.ActionCreateTable,
+		TableInfo: compositeHandle,
+	})
+	require.NoError(t, err)
+	require.Contains(t, entry.GetHeader().GetProps(),
+		&canal.Pair{Key: propHandleType, Value: handleTypeCommon})
+
+	// omitted if the schema of the table is unknown.
+	handleType, before, after := handle(codecConfig, nil)
+	require.Empty(t, handleType)
+	require.Empty(t, before)
+	require.Empty(t, after)
+
+	// not emitted if disabled or not supported by the consumer.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	handleType, before, after = handle(codecConfig, compositeHandle)
+	require.Empty(t, handleType)
+	require.Empty(t, before)
+	require.Empty(t, after)
+
+	codecConfig.EnableHandle = true
+	codecConfig.FeatureLevel = 2
+	handleType, before, after = handle(codecConfig, compositeHandle)
+	require.Empty(t, handleType)
+	require.Empty(t, before)
+	require.Empty(t, after)
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// fromHeartbeat builds the canal entry of a heartbeat. It's an ENTRYHEARTBEAT
+// entry with the MHEARTBEAT event type, which carries nothing but the time
+// in the header, so that it's never mistaken for a real event.
+func (b *canalEntryBuilder) fromHeartbeat(ts uint64) *Entry {
+	header := b.buildHeader(ts, "", "", canal.EventType_MHEARTBEAT, -1)
+	return &Entry{
+		Header:    header,
+		EntryType: canal.EntryType_ENTRYHEARTBEAT,
+	}
+}
+
+// EncodeHeartbeat implements the HeartbeatEncoder interface
+func (d *BatchEncoder) EncodeHeartbeat(ts uint64) (*common.Message, error) {
+	b, err := d.serializer.Serialize(d.entryBuilder.fromHeartbeat(ts))
+	if err != nil {
+		return nil, serializeError(err)
+	}
+	value, err := encodeSingleEntryPacket(b)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// the heartbeat has no progress semantics, so it's not a resolved message.
+	msg := common.NewMsg(config.ProtocolCanal, nil, d.frame(value), ts,
+		model.MessageTypeUnknown, nil, nil)
+	d.stampTimestamp(msg, ts)
+	return msg, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	encoder, ok := newBatchEncoder(common.NewConfig(config.ProtocolCanal)).(codec.HeartbeatEncoder)
+	require.True(t, ok)
+
+	ts := uint64(417318403368288260)
+	msg, err := encoder.EncodeHeartbeat(ts)
+	require.Nil(t, err)
+	require.Equal(t, model.MessageTypeUnknown, msg.Type)
+	require.Equal(t, ts, msg.Ts)
+	require.Nil(t, msg.Key)
+
+	packet := &canal.Packet{}
+	err = proto.Unmarshal(msg.Value, packet)
+	require.Nil(t, err)
+	require.Equal(t, canal.PacketType_MESSAGES, packet.GetType())
+	messages := &canal.Messages{}
+	err = proto.Unmarshal(packet.GetBody(), messages)
+	require.Nil(t, err)
+	require.Len(t, messages.GetMessages(), 1)
+
+	entry := &canal.Entry{}
+	err = proto.Unmarshal(messages.GetMessages()[0], entry)
+	require.Nil(t, err)
+	require.Equal(t, canal.EntryType_ENTRYHEARTBEAT, entry.GetEntryType())
+	require.Empty(t, entry.GetStoreValue())
+
+	header := entry.GetHeader()
+	require.Equal(t, canal.EventType_MHEARTBEAT, header.GetEventType())
+	require.Equal(t, convertToCanalTs(ts), header.GetExecuteTime())
+	require.Empty(t, header.GetSchemaName())
+	require.Empty(t, header.GetTableName())
+	require.Empty(t, header.GetProps())
+
+	// the heartbeat is not surfaced as an event by the decoder.
+	decoder := NewStreamDecoder(bytes.NewReader(framePacket(msg.Value)))
+	_, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.False(t, hasNext)
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"golang.org/x/text/unicode/norm"
+)
+
+// safeIdentifier returns the name emitted in place of the schema or the table
+// name, which is the name itself if it's ASCII and not longer than the
+// IdentifierMaxBytes, so that it's safe as the topic name of the consumers.
+// Otherwise it's transliterated or hashed by the IdentifierHandling.
+func (b *canalEntryBuilder) safeIdentifier(name string) string {
+	handling := b.config.IdentifierHandling
+	if handling == "" || handling == common.IdentifierHandlingNone ||
+		isSafeIdentifier(name, b.config.IdentifierMaxBytes) {
+		return name
+	}
+	if handling == common.IdentifierHandlingHash {
+		return identifierHash(name)
+	}
+	result := transliterate(name)
+	if max := b.config.IdentifierMaxBytes; max > 0 && len(result) > max {
+		// the hash of the original name tells apart the names sharing the
+		// prefix truncated.
+		result = result[:max-common.MinIdentifierMaxBytes] + "_" + identifierHash(name)
+	}
+	return result
+}
+
+func isSafeIdentifier(name string, maxBytes int) bool {
+	if maxBytes > 0 && len(name) > maxBytes {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// transliterate returns the ASCII form of the name, the accents of the letters
+// are stripped, e.g. `café` is `cafe`, and the other non-ASCII characters are
+// escaped as the code points, e.g. `订单` is `u8ba2u5355`.
+func transliterate(name string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case r < utf8.RuneSelf:
+			sb.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+		default:
+			fmt.Fprintf(&sb, "u%04x", r)
+		}
+	}
+	return sb.String()
+}
+
+// identifierHash returns the 16 hex digits of the FNV-1a hash of the name.
+func identifierHash(name string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// appendOriginalNames stamps the names of the schema and the table replaced
+// with the safe identifiers into the header props, so that the consumer
+// recovers them. The names emitted as is are not stamped.
+func (b *canalEntryBuilder) appendOriginalNames(h *canal.Header, schema, table string) {
+	if !b.featureEnabled(featureOriginalNames) {
+		return
+	}
+	schema, table = b.mapOriginalName(schema, table)
+	if schema != b.safeIdentifier(schema) {
+		h.Props = append(h.Props, &canal.Pair{Key: propOriginalSchema, Value: schema})
+	}
+	if table != b.safeIdentifier(table) {
+		h.Props = append(h.Props, &canal.Pair{Key: propOriginalTable, Value: table})
+	}
+}
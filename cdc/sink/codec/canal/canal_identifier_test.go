@@ -0,0 +1,120 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeIdentifier(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	b := newCanalEntryBuilder(codecConfig)
+	require.Equal(t, "订单", b.safeIdentifier("订单"))
+
+	codecConfig.IdentifierHandling = common.IdentifierHandlingTransliterate
+	require.Equal(t, "orders", b.safeIdentifier("orders"))
+	require.Equal(t, "cafe", b.safeIdentifier("café"))
+	require.Equal(t, "u8ba2u5355", b.safeIdentifier("订单"))
+
+	// truncated with the hash suffixed, if it's still too long.
+	codecConfig.IdentifierMaxBytes = 20
+	require.Equal(t, "orders", b.safeIdentifier("orders"))
+	long := b.safeIdentifier("订单_2022_archive")
+	require.Len(t, long, 20)
+	require.Equal(t, "u8b_"+identifierHash("订单_2022_archive"), long)
+	require.NotEqual(t, long, b.safeIdentifier("订单_2023_archive"))
+	// so is the ASCII name too long.
+	require.Equal(t, "arc_"+identifierHash("archived_orders_2022_q1"),
+		b.safeIdentifier("archived_orders_2022_q1"))
+
+	codecConfig.IdentifierHandling = common.IdentifierHandlingHash
+	require.Equal(t, "orders", b.safeIdentifier("orders"))
+	require.Equal(t, identifierHash("订单"), b.safeIdentifier("订单"))
+	require.Len(t, b.safeIdentifier("订单"), 16)
+}
+
+func TestOriginalNames(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.IdentifierHandling = common.IdentifierHandlingTransliterate
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "订单"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+		},
+	}
+	props := func(h *canal.Header) map[string]string {
+		result := make(map[string]string)
+		for _, p := range h.GetProps() {
+			result[p.GetKey()] = p.GetValue()
+		}
+		return result
+	}
+
+	builder := newCanalEntryBuilder(codecConfig)
+	entry, err := builder.fromRowEvent(event)
+	require.NoError(t, err)
+	header := entry.GetHeader()
+	require.Equal(t, "test", header.GetSchemaName())
+	require.Equal(t, "u8ba2u5355", header.GetTableName())
+	p := props(header)
+	require.Equal(t, "订单", p[propOriginalTable])
+	// the schema emitted as is is not stamped.
+	require.NotContains(t, p, propOriginalSchema)
+
+	// the key of the row carries the safe identifier as well.
+	key, err := builder.rowKey(event)
+	require.NoError(t, err)
+	require.Contains(t, string(key), "u8ba2u5355")
+
+	// so does the DDL.
+	entry, err = builder.fromDDLEvent(&model.DDLEvent{
+		CommitTs: 417318403368288260,
+		Query:    "create table 订单(id int primary key)",
+		Type:     mm.ActionCreateTable,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "test", Table: "订单"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "u8ba2u5355", entry.GetHeader().GetTableName())
+	require.Equal(t, "订单", props(entry.GetHeader())[propOriginalTable])
+	// the query names the same table as the header.
+	ddl := &canal.RowChange{}
+	require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), ddl))
+	require.Equal(t, "CREATE TABLE `u8ba2u5355` (`id` INT PRIMARY KEY)", ddl.GetSql())
+
+	// the names are replaced but not stamped if the consumer does not
+	// support the props.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	codecConfig.IdentifierHandling = common.IdentifierHandlingHash
+	codecConfig.FeatureLevel = 2
+	entry, err = newCanalEntryBuilder(codecConfig).fromRowEvent(event)
+	require.NoError(t, err)
+	require.Equal(t, identifierHash("订单"), entry.GetHeader().GetTableName())
+	require.NotContains(t, props(entry.GetHeader()), propOriginalTable)
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// insertGroup holds the consecutive inserts of a table in a transaction,
+// which are emitted as a single entry with multiple rows.
+type insertGroup struct {
+	// event is the first event of the group.
+	event *model.RowChangedEvent
+	key   txnKey
+	// rowKey is the key of the rows of the group if the encoder is keyed,
+	// since the entry is emitted in a message keyed by it.
+	rowKey    []byte
+	header    *canal.Header
+	rowDatas  []*canal.RowData
+	callbacks []func()
+	// size is the sum of the sizes of the entries of the rows alone, which
+	// bounds the size of the entry of the group, since the rows share the
+	// header in it.
+	size int
+}
+
+// accepts returns whether the row can join the group. The rows are grouped only
+// if their headers are the same, so the rows carrying a prop specific to the
+// row in the header, e.g. the checksums or the sequence, are never grouped.
+func (g *insertGroup) accepts(e *model.RowChangedEvent, header *canal.Header, rowKey []byte) bool {
+	return header.GetEventType() == canal.EventType_INSERT &&
+		g.key == newTxnKey(e) && bytes.Equal(g.rowKey, rowKey) && proto.Equal(g.header, header)
+}
+
+func (g *insertGroup) add(rowData *canal.RowData, size int, callback func()) {
+	g.rowDatas = append(g.rowDatas, rowData)
+	g.size += size
+	if callback != nil {
+		g.callbacks = append(g.callbacks, callback)
+	}
+}
+
+// appendRow appends the row to the batch, the inserts are held in the group
+// until a row not joining the group arrives, if the grouping is enabled. The
+// group is flushed before the row would push it over the MaxMessageBytes, and
+// the row too large alone fails the append, so that the group never fails to
+// be flushed by the size.
+func (d *BatchEncoder) appendRow(
+	e *model.RowChangedEvent, header *canal.Header, rowData *canal.RowData, callback func(),
+) error {
+	if !d.config.EnableInsertGrouping {
+		return d.appendEntry(e, newRowEntry(header, rowData), callback)
+	}
+	if header.GetEventType() != canal.EventType_INSERT {
+		if err := d.flushGroup(); err != nil {
+			return errors.Trace(err)
+		}
+		return d.appendEntry(e, newRowEntry(header, rowData), callback)
+	}
+
+	b, err := d.serializer.Serialize(newRowEntry(header, rowData))
+	if err != nil {
+		return serializeError(err)
+	}
+	if err := checkMessageSize(len(b), d.config); err != nil {
+		return errors.Trace(err)
+	}
+	var rowKey []byte
+	if d.keyed {
+		if rowKey, err = d.entryBuilder.rowKey(e); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if d.group != nil && d.group.accepts(e, header, rowKey) &&
+		checkMessageSize(d.group.size+len(b), d.config) == nil {
+		d.group.add(rowData, len(b), callback)
+		return nil
+	}
+	if err := d.flushGroup(); err != nil {
+		return errors.Trace(err)
+	}
+	d.group = &insertGroup{event: e, key: newTxnKey(e), rowKey: rowKey, header: header}
+	d.group.add(rowData, len(b), callback)
+	return nil
+}
+
+// flushGroup appends the entry of the group to the batch.
+func (d *BatchEncoder) flushGroup() error {
+	g := d.group
+	if g == nil {
+		return nil
+	}
+	d.group = nil
+	for _, p := range g.header.GetProps() {
+		if p.GetKey() == propRowsCount {
+			p.Value = strconv.Itoa(len(g.rowDatas))
+		}
+	}
+	entry := newRowEntry(g.header, g.rowDatas...)
+	var callback func()
+	if len(g.callbacks) != 0 {
+		callbacks := g.callbacks
+		callback = func() {
+			for _, cb := range callbacks {
+				cb()
+			}
+		}
+	}
+	return d.appendEntry(g.event, entry, callback)
+}
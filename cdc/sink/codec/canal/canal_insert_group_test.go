@@ -0,0 +1,232 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertGrouping(t *testing.T) {
+	t.Parallel()
+
+	newRow := func(startTs, commitTs uint64, table string, id int64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			StartTs:  startTs,
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLonglong,
+				Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value: id,
+			}},
+		}
+	}
+	newUpdate := func(startTs, commitTs uint64, table string, id int64) *model.RowChangedEvent {
+		row := newRow(startTs, commitTs, table, id)
+		row.PreColumns = row.Columns
+		return row
+	}
+	// entries returns the entries in the message.
+	entries := func(msg *common.Message) []*canal.Entry {
+		packet := &canal.Packet{}
+		require.Nil(t, proto.Unmarshal(msg.Value, packet))
+		messages := &canal.Messages{}
+		require.Nil(t, proto.Unmarshal(packet.GetBody(), messages))
+		var result []*canal.Entry
+		for _, data := range messages.GetMessages() {
+			entry := &canal.Entry{}
+			require.Nil(t, proto.Unmarshal(data, entry))
+			result = append(result, entry)
+		}
+		return result
+	}
+	// rowsCounts returns the number of rows and the rowsCount prop of the entries.
+	rowsCounts := func(entries []*canal.Entry) ([]int, []string) {
+		var counts []int
+		var props []string
+		for _, entry := range entries {
+			rc := &canal.RowChange{}
+			require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+			counts = append(counts, len(rc.GetRowDatas()))
+			for _, p := range entry.GetHeader().GetProps() {
+				if p.GetKey() == propRowsCount {
+					props = append(props, p.GetValue())
+				}
+			}
+		}
+		return counts, props
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableInsertGrouping = true
+	encoder := newBatchEncoder(codecConfig)
+
+	// a multi-value insert is grouped, and the group is broken by an update,
+	// another table, and another transaction.
+	events := []*model.RowChangedEvent{
+		newRow(1, 2, "t1", 1),
+		newRow(1, 2, "t1", 2),
+		newRow(1, 2, "t1", 3),
+		newUpdate(1, 2, "t1", 3),
+		newRow(1, 2, "t1", 4),
+		newRow(1, 2, "t2", 1),
+		newRow(3, 4, "t2", 2),
+		newRow(3, 4, "t2", 3),
+	}
+	called := 0
+	for _, e := range events {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", e, func() { called++ })
+		require.Nil(t, err)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	result := entries(msgs[0])
+	counts, props := rowsCounts(result)
+	require.Equal(t, []int{3, 1, 1, 1, 2}, counts)
+	require.Equal(t, []string{"3", "1", "1", "1", "2"}, props)
+	require.Equal(t, canal.EventType_INSERT, result[0].GetHeader().GetEventType())
+	require.Equal(t, canal.EventType_UPDATE, result[1].GetHeader().GetEventType())
+	msgs[0].Callback()
+	require.Equal(t, len(events), called)
+
+	// all the rows are decoded from the grouped entries in order.
+	decoder, err := NewPacketDecoder(msgs[0].Value)
+	require.Nil(t, err)
+	for _, e := range events {
+		tp, ok, err := decoder.HasNext()
+		require.Nil(t, err)
+		require.True(t, ok)
+		require.Equal(t, model.MessageTypeRow, tp)
+		decoded, err := decoder.NextRowChangedEvent()
+		require.Nil(t, err)
+		require.Equal(t, e.Table.Table, decoded.Table.Table)
+		require.Equal(t, e.IsUpdate(), decoded.IsUpdate())
+		require.Equal(t, fmt.Sprint(e.Columns[0].Value), fmt.Sprint(decoded.Columns[0].Value))
+	}
+	_, ok, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// the rows with the checksum in the header are never grouped.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableInsertGrouping = true
+	codecConfig.ChecksumAlgorithm = common.ChecksumAlgorithmCRC32
+	encoder = newBatchEncoder(codecConfig)
+	for _, e := range events[:3] {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", e, nil)
+		require.Nil(t, err)
+	}
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	counts, _ = rowsCounts(entries(msgs[0]))
+	require.Equal(t, []int{1, 1, 1}, counts)
+
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Nil(t, msgs)
+}
+
+func TestInsertGroupingSplit(t *testing.T) {
+	t.Parallel()
+
+	newRow := func(id int64, name string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			StartTs:  1,
+			CommitTs: 2,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLonglong,
+				Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value: id,
+			}, {
+				Name:  "name",
+				Type:  mysql.TypeVarchar,
+				Value: []byte(name),
+			}},
+		}
+	}
+	// counts returns the number of rows of the entries in the messages.
+	counts := func(msgs []*common.Message) []int {
+		var result []int
+		for _, msg := range msgs {
+			packet := &canal.Packet{}
+			require.Nil(t, proto.Unmarshal(msg.Value, packet))
+			messages := &canal.Messages{}
+			require.Nil(t, proto.Unmarshal(packet.GetBody(), messages))
+			for _, data := range messages.GetMessages() {
+				entry := &canal.Entry{}
+				require.Nil(t, proto.Unmarshal(data, entry))
+				rc := &canal.RowChange{}
+				require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+				result = append(result, len(rc.GetRowDatas()))
+			}
+		}
+		return result
+	}
+
+	// the max-message-bytes fitting two rows alone in a group.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableInsertGrouping = true
+	encoder := newBatchEncoder(codecConfig).(*BatchEncoder)
+	header, rowData, err := encoder.entryBuilder.buildRow(newRow(1, "a"))
+	require.Nil(t, err)
+	b, err := encoder.serializer.Serialize(newRowEntry(header, rowData))
+	require.Nil(t, err)
+	codecConfig.MaxMessageBytes = 2*len(b) + common.MaxRecordOverhead
+
+	// the group is flushed before it exceeds the max-message-bytes.
+	encoder = newBatchEncoder(codecConfig).(*BatchEncoder)
+	for i := 1; i <= 5; i++ {
+		require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", newRow(int64(i), "a"), nil))
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 2, 1}, counts(msgs))
+
+	// the row too large alone fails the append, rather than the Build.
+	err = encoder.AppendRowChangedEvent(context.Background(), "", newRow(1, string(make([]byte, 2*len(b)))), nil)
+	requireEncodeErrorClass(t, err, cerror.ErrCanalValueTooLarge)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Nil(t, msgs)
+
+	// the groups are split by the key if the rows are keyed, so that each
+	// message is keyed by the rows in it.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableInsertGrouping = true
+	keyed := NewBatchEncoderBuilder(codecConfig, WithKeySerializer(NewJSONKeySerializer())).Build()
+	for _, id := range []int64{1, 1, 2} {
+		require.Nil(t, keyed.AppendRowChangedEvent(context.Background(), "", newRow(id, "a"), nil))
+	}
+	msgs, err = keyed.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, []int{2, 1}, counts(msgs))
+	require.NotEqual(t, msgs[0].Key, msgs[1].Key)
+}
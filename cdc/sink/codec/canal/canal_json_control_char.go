@@ -0,0 +1,62 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// byteOrderMark is the BOM, which is not expected in the middle of the text.
+const byteOrderMark = '\uFEFF'
+
+// isJSONInvalidChar returns whether the character breaks the strict JSON
+// parsers, i.e. the BOM and the control characters except for the tab, the
+// line feed and the carriage return, even if they are escaped, e.g. `\u0000`.
+func isJSONInvalidChar(r rune) bool {
+	if r == byteOrderMark {
+		return true
+	}
+	return r < 0x20 && r != '\t' && r != '\n' && r != '\r'
+}
+
+// handleJSONControlChars strips the characters breaking the strict JSON
+// parsers from the value of the column, or fails if the handling is
+// JSONControlCharError. The binary values are kept as is, since they are the
+// bytes decoded as ISO-8859-1 rather than the text.
+func handleJSONControlChars(
+	name, value string, javaType internal.JavaSQLType, handling string,
+) (string, error) {
+	if javaType == internal.JavaSQLTypeBLOB {
+		return value, nil
+	}
+	i := strings.IndexFunc(value, isJSONInvalidChar)
+	if i < 0 {
+		return value, nil
+	}
+	if handling == common.JSONControlCharError {
+		r, _ := utf8.DecodeRuneInString(value[i:])
+		return "", cerror.ErrCanalJSONInvalidChar.GenWithStackByArgs(name, r)
+	}
+	return strings.Map(func(r rune) rune {
+		if isJSONInvalidChar(r) {
+			return -1
+		}
+		return r
+	}, value), nil
+}
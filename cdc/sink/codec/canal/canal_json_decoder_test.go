@@ -18,6 +18,8 @@ import (
 	"testing"
 
 	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -31,7 +33,8 @@ func TestNewCanalJSONBatchDecoder4RowMessage(t *testing.T) {
 		err := encoder.AppendRowChangedEvent(context.Background(), "", testCaseInsert, nil)
 		require.Nil(t, err)
 
-		messages := encoder.Build()
+		messages, err := encoder.Build()
+		require.NoError(t, err)
 		require.Equal(t, 1, len(messages))
 		msg := messages[0]
 
@@ -78,7 +81,7 @@ func TestNewCanalJSONBatchDecoder4RowMessage(t *testing.T) {
 func TestNewCanalJSONBatchDecoder4DDLMessage(t *testing.T) {
 	t.Parallel()
 	for _, encodeEnable := range []bool{false, true} {
-		encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder(), enableTiDBExtension: encodeEnable}
+		encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder(common.NewConfig(config.ProtocolCanalJSON)), enableTiDBExtension: encodeEnable}
 		require.NotNil(t, encoder)
 
 		result, err := encoder.EncodeDDLEvent(testCaseDDL)
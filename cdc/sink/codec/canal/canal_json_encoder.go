@@ -35,6 +35,22 @@ type JSONBatchEncoder struct {
 	// When it is true, canal-json would generate TiDB extension information
 	// which, at the moment, only includes `tidbWaterMarkType` and `_tidb` fields.
 	enableTiDBExtension bool
+	// When it is true, the `old` of INSERT and DELETE events is
+	// an explicitly empty image instead of null.
+	enableEmptyImages bool
+	// controlCharHandling is how the BOM and the control characters in the
+	// values are handled, see handleJSONControlChars.
+	controlCharHandling string
+	// timestampPrecision is the precision of the execution time and the
+	// build time, see canalTimestamp and canalBuildTime.
+	timestampPrecision string
+	// timestampOffset is added to the execution time, see canalTimestamp.
+	timestampOffset time.Duration
+	// booleanNormalization is how the values of the TINYINT(1) columns are
+	// emitted, see normalizeBoolean.
+	booleanNormalization string
+	// tableQualification is how the table is identified, see qualifyTable.
+	tableQualification string
 
 	// messageHolder is used to hold each message and will be reset after each message is encoded.
 	messageHolder canalJSONMessageInterface
@@ -44,12 +60,14 @@ type JSONBatchEncoder struct {
 // newJSONBatchEncoder creates a new JSONBatchEncoder
 func newJSONBatchEncoder(enableTiDBExtension bool) codec.EventBatchEncoder {
 	encoder := &JSONBatchEncoder{
-		builder: newCanalEntryBuilder(),
+		builder: newCanalEntryBuilder(common.NewConfig(config.ProtocolCanalJSON)),
 		messageHolder: &JSONMessage{
 			// for Data field, no matter event type, always be filled with only one item.
 			Data: make([]map[string]interface{}, 1),
 		},
 		enableTiDBExtension: enableTiDBExtension,
+		controlCharHandling: common.JSONControlCharSanitize,
+		timestampPrecision:  common.TimestampPrecisionMillisecond,
 		messages:            make([]*common.Message, 0, 1),
 	}
 
@@ -67,6 +85,7 @@ func (c *JSONBatchEncoder) newJSONMessageForDML(e *model.RowChangedEvent) error
 	isDelete := e.IsDelete()
 	sqlTypeMap := make(map[string]int32, len(e.Columns))
 	mysqlTypeMap := make(map[string]string, len(e.Columns))
+	booleans := booleanColumns(e, c.booleanNormalization)
 
 	filling := func(columns []*model.Column, fillTypes bool) (map[string]interface{}, error) {
 		if len(columns) == 0 {
@@ -84,6 +103,16 @@ func (c *JSONBatchEncoder) newJSONMessageForDML(e *model.RowChangedEvent) error
 				if err != nil {
 					return nil, cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
 				}
+				value, err = handleJSONControlChars(col.Name, value, javaType, c.controlCharHandling)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				if _, ok := booleans[col.Name]; ok && col.Value != nil {
+					value, err = normalizeBoolean(col.Name, value, c.booleanNormalization)
+					if err != nil {
+						return nil, errors.Trace(err)
+					}
+				}
 				if fillTypes {
 					sqlTypeMap[col.Name] = int32(javaType)
 					mysqlTypeMap[col.Name] = mysqlType
@@ -118,12 +147,12 @@ func (c *JSONBatchEncoder) newJSONMessageForDML(e *model.RowChangedEvent) error
 
 	baseMessage.ID = 0 // ignored by both Canal Adapter and Flink
 	baseMessage.Schema = e.Table.Schema
-	baseMessage.Table = e.Table.Table
+	baseMessage.Table = qualifyTable(e.Table.Schema, e.Table.Table, c.tableQualification)
 	baseMessage.PKNames = e.PrimaryKeyColumnNames()
 	baseMessage.IsDDL = false
 	baseMessage.EventType = eventTypeString(e)
-	baseMessage.ExecutionTime = convertToCanalTs(e.CommitTs)
-	baseMessage.BuildTime = time.Now().UnixNano() / 1e6 // ignored by both Canal Adapter and Flink
+	baseMessage.ExecutionTime = canalTimestamp(e.CommitTs, c.timestampPrecision, c.timestampOffset)
+	baseMessage.BuildTime = canalBuildTime(time.Now(), c.timestampPrecision) // ignored by both Canal Adapter and Flink
 	baseMessage.Query = ""
 	baseMessage.SQLType = sqlTypeMap
 	baseMessage.MySQLType = mysqlTypeMap
@@ -132,8 +161,14 @@ func (c *JSONBatchEncoder) newJSONMessageForDML(e *model.RowChangedEvent) error
 
 	if e.IsDelete() {
 		baseMessage.Data[0] = oldData
+		if c.enableEmptyImages {
+			baseMessage.Old = []map[string]interface{}{{}}
+		}
 	} else if e.IsInsert() {
 		baseMessage.Data[0] = data
+		if c.enableEmptyImages {
+			baseMessage.Old = []map[string]interface{}{{}}
+		}
 	} else if e.IsUpdate() {
 		baseMessage.Data[0] = data
 		baseMessage.Old = []map[string]interface{}{oldData}
@@ -159,14 +194,15 @@ func eventTypeString(e *model.RowChangedEvent) string {
 }
 
 func (c *JSONBatchEncoder) newJSONMessageForDDL(e *model.DDLEvent) canalJSONMessageInterface {
+	name := e.TableInfo.TableName
 	msg := &JSONMessage{
 		ID:            0, // ignored by both Canal Adapter and Flink
-		Schema:        e.TableInfo.TableName.Schema,
-		Table:         e.TableInfo.TableName.Table,
+		Schema:        name.Schema,
+		Table:         qualifyTable(name.Schema, name.Table, c.tableQualification),
 		IsDDL:         true,
 		EventType:     convertDdlEventType(e).String(),
-		ExecutionTime: convertToCanalTs(e.CommitTs),
-		BuildTime:     time.Now().UnixNano() / 1e6, // timestamp
+		ExecutionTime: canalTimestamp(e.CommitTs, c.timestampPrecision, c.timestampOffset),
+		BuildTime:     canalBuildTime(time.Now(), c.timestampPrecision), // timestamp
 		Query:         e.Query,
 		tikvTs:        e.CommitTs,
 	}
@@ -187,8 +223,8 @@ func (c *JSONBatchEncoder) newJSONMessage4CheckpointEvent(ts uint64) *canalJSONM
 			ID:            0,
 			IsDDL:         false,
 			EventType:     tidbWaterMarkType,
-			ExecutionTime: convertToCanalTs(ts),
-			BuildTime:     time.Now().UnixNano() / int64(time.Millisecond), // converts to milliseconds
+			ExecutionTime: canalTimestamp(ts, c.timestampPrecision, c.timestampOffset),
+			BuildTime:     canalBuildTime(time.Now(), c.timestampPrecision),
 		},
 		Extensions: &tidbExtension{WatermarkTs: ts},
 	}
@@ -224,8 +260,10 @@ func (c *JSONBatchEncoder) AppendRowChangedEvent(
 		log.Panic("JSONBatchEncoder", zap.Error(err))
 		return nil
 	}
+	// the message carries the bare names of the table, which route it.
+	schema, table := e.Table.Schema, e.Table.Table
 	m := common.NewMsg(config.ProtocolCanalJSON, nil, value, e.CommitTs,
-		model.MessageTypeRow, c.messageHolder.getSchema(), c.messageHolder.getTable())
+		model.MessageTypeRow, &schema, &table)
 	m.IncRowsCount()
 	m.Callback = callback
 
@@ -234,14 +272,14 @@ func (c *JSONBatchEncoder) AppendRowChangedEvent(
 }
 
 // Build implements the EventJSONBatchEncoder interface
-func (c *JSONBatchEncoder) Build() []*common.Message {
+func (c *JSONBatchEncoder) Build() ([]*common.Message, error) {
 	if len(c.messages) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	result := c.messages
 	c.messages = c.messages[:0]
-	return result
+	return result, nil
 }
 
 // EncodeDDLEvent encodes DDL events
@@ -265,5 +303,12 @@ func NewJSONBatchEncoderBuilder(config *common.Config) codec.EncoderBuilder {
 
 // Build a `JSONBatchEncoder`
 func (b *jsonBatchEncoderBuilder) Build() codec.EventBatchEncoder {
-	return newJSONBatchEncoder(b.config.EnableTiDBExtension)
+	encoder := newJSONBatchEncoder(b.config.EnableTiDBExtension).(*JSONBatchEncoder)
+	encoder.enableEmptyImages = b.config.EnableEmptyImages
+	encoder.controlCharHandling = b.config.JSONControlCharHandling
+	encoder.timestampPrecision = b.config.TimestampPrecision
+	encoder.timestampOffset = b.config.CommitTsOffset
+	encoder.booleanNormalization = b.config.BooleanNormalization
+	encoder.tableQualification = b.config.TableQualification
+	return encoder
 }
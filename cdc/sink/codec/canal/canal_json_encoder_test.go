@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/tiflow/cdc/model"
 	"github.com/pingcap/tiflow/cdc/sink/codec/common"
 	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/encoding/charmap"
 )
@@ -124,7 +125,7 @@ func TestNewCanalJSONMessage4DML(t *testing.T) {
 
 func TestNewCanalJSONMessageFromDDL(t *testing.T) {
 	t.Parallel()
-	encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder()}
+	encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder(common.NewConfig(config.ProtocolCanalJSON))}
 	require.NotNil(t, encoder)
 
 	message := encoder.newJSONMessageForDDL(testCaseDDL)
@@ -140,7 +141,7 @@ func TestNewCanalJSONMessageFromDDL(t *testing.T) {
 	require.Equal(t, testCaseDDL.Query, msg.Query)
 	require.Equal(t, "CREATE", msg.EventType)
 
-	encoder = &JSONBatchEncoder{builder: newCanalEntryBuilder(), enableTiDBExtension: true}
+	encoder = &JSONBatchEncoder{builder: newCanalEntryBuilder(common.NewConfig(config.ProtocolCanalJSON)), enableTiDBExtension: true}
 	require.NotNil(t, encoder)
 
 	message = encoder.newJSONMessageForDDL(testCaseDDL)
@@ -166,7 +167,8 @@ func TestBatching(t *testing.T) {
 		require.Nil(t, err)
 
 		if i%100 == 0 {
-			msgs := encoder.Build()
+			msgs, err := encoder.Build()
+			require.NoError(t, err)
 			require.NotNil(t, msgs)
 			require.Len(t, msgs, 100)
 
@@ -188,7 +190,7 @@ func TestEncodeCheckpointEvent(t *testing.T) {
 	t.Parallel()
 	var watermark uint64 = 2333
 	for _, enable := range []bool{false, true} {
-		encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder(), enableTiDBExtension: enable}
+		encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder(common.NewConfig(config.ProtocolCanalJSON)), enableTiDBExtension: enable}
 		require.NotNil(t, encoder)
 
 		msg, err := encoder.EncodeCheckpointEvent(watermark)
@@ -226,7 +228,7 @@ func TestCheckpointEventValueMarshal(t *testing.T) {
 	t.Parallel()
 	var watermark uint64 = 1024
 	encoder := &JSONBatchEncoder{
-		builder:             newCanalEntryBuilder(),
+		builder:             newCanalEntryBuilder(common.NewConfig(config.ProtocolCanalJSON)),
 		enableTiDBExtension: true,
 	}
 	require.NotNil(t, encoder)
@@ -272,7 +274,7 @@ func TestCheckpointEventValueMarshal(t *testing.T) {
 
 func TestDDLEventWithExtensionValueMarshal(t *testing.T) {
 	t.Parallel()
-	encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder(), enableTiDBExtension: true}
+	encoder := &JSONBatchEncoder{builder: newCanalEntryBuilder(common.NewConfig(config.ProtocolCanalJSON)), enableTiDBExtension: true}
 	require.NotNil(t, encoder)
 
 	message := encoder.newJSONMessageForDDL(testCaseDDL)
@@ -361,7 +363,8 @@ func TestCanalJSONAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 
 	// Empty build makes sure that the callback build logic not broken.
-	msgs := encoder.Build()
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 0, "no message should be built and no panic")
 
 	// Append the events.
@@ -371,7 +374,8 @@ func TestCanalJSONAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 	require.Equal(t, 0, count, "nothing should be called")
 
-	msgs = encoder.Build()
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 5, "expected 5 messages")
 	msgs[0].Callback()
 	require.Equal(t, 1, count, "expected one callback be called")
@@ -384,3 +388,106 @@ func TestCanalJSONAppendRowChangedEventWithCallback(t *testing.T) {
 	msgs[4].Callback()
 	require.Equal(t, 15, count, "expected one callback be called")
 }
+
+func TestCanalJSONEmptyImages(t *testing.T) {
+	t.Parallel()
+
+	for _, enable := range []bool{false, true} {
+		cfg := common.NewConfig(config.ProtocolCanalJSON)
+		cfg.EnableEmptyImages = enable
+		encoder := NewJSONBatchEncoderBuilder(cfg).Build()
+
+		for _, event := range []*model.RowChangedEvent{testCaseInsert, testCaseDelete, testCaseUpdate} {
+			err := encoder.AppendRowChangedEvent(context.Background(), "", event, nil)
+			require.Nil(t, err)
+		}
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 3)
+
+		for i, expectEmpty := range []bool{enable, enable, false} {
+			var raw map[string]interface{}
+			err := json.Unmarshal(msgs[i].Value, &raw)
+			require.Nil(t, err)
+			old, ok := raw["old"]
+			require.True(t, ok)
+			if expectEmpty {
+				require.Equal(t, []interface{}{map[string]interface{}{}}, old)
+			} else if i < 2 {
+				require.Nil(t, old)
+			} else {
+				// the UPDATE always carries the old image.
+				require.NotEmpty(t, old)
+			}
+			require.NotEmpty(t, raw["data"])
+
+			// the decoder is not affected by the empty images.
+			decoder := NewBatchDecoder(msgs[i].Value, false)
+			_, hasNext, err := decoder.HasNext()
+			require.Nil(t, err)
+			require.True(t, hasNext)
+			_, err = decoder.NextRowChangedEvent()
+			require.Nil(t, err)
+		}
+	}
+}
+
+func TestCanalJSONControlChars(t *testing.T) {
+	t.Parallel()
+
+	newEvent := func(value string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+				{Name: "name", Type: mysql.TypeVarchar, Value: []byte(value)},
+				// the binary value is kept as is.
+				{
+					Name: "data", Type: mysql.TypeBlob, Flag: model.BinaryFlag,
+					Value: []byte{0x00, 0x0b},
+				},
+			},
+		}
+	}
+	values := map[string]string{
+		"bom":         "\uFEFFAlice",
+		"verticalTab": "Al\vice",
+		"nul":         "Alice\x00",
+	}
+
+	// the characters are stripped from the values by default.
+	encoder := NewJSONBatchEncoderBuilder(common.NewConfig(config.ProtocolCanalJSON)).Build()
+	for name, value := range values {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", newEvent(value), nil)
+		require.Nil(t, err, name)
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		require.NotContains(t, string(msgs[0].Value), "\uFEFF", name)
+
+		var raw struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		require.Nil(t, json.Unmarshal(msgs[0].Value, &raw), name)
+		require.Equal(t, "Alice", raw.Data[0]["name"], name)
+		require.Equal(t, "\x00\v", raw.Data[0]["data"], name)
+	}
+
+	// the encoding fails if the handling is error.
+	cfg := common.NewConfig(config.ProtocolCanalJSON)
+	cfg.JSONControlCharHandling = common.JSONControlCharError
+	encoder = NewJSONBatchEncoderBuilder(cfg).Build()
+	for name, value := range values {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", newEvent(value), nil)
+		require.True(t, cerror.ErrCanalJSONInvalidChar.Equal(err), name)
+		require.ErrorContains(t, err, "column name", name)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// the tab, the line feed and the carriage return are allowed.
+	err = encoder.AppendRowChangedEvent(context.Background(), "", newEvent("Al\tice\r\n"), nil)
+	require.Nil(t, err)
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// jsonPatchOp is an operation of the RFC 6902 JSON Patch, the value is
+// omitted for the remove operation.
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// jsonPointerEscaper escapes the reference token of the RFC 6901 JSON Pointer.
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// decodeJSON decodes the JSON document, keeping the numbers as is.
+func decodeJSON(doc string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(doc))
+	decoder.UseNumber()
+	var result interface{}
+	if err := decoder.Decode(&result); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// diffJSON returns the JSON Patch transforming the old JSON document into the
+// new one. The objects are diffed by the keys in order, the arrays are diffed
+// by the indexes, i.e. the common prefix is diffed element by element, and the
+// elements beyond it are added in order or removed from the end, and any
+// other change replaces the value as a whole.
+func diffJSON(oldDoc, newDoc string) ([]byte, error) {
+	oldValue, err := decodeJSON(oldDoc)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	newValue, err := decodeJSON(newDoc)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ops := make([]jsonPatchOp, 0)
+	if err := diffJSONValue("", oldValue, newValue, &ops); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return json.Marshal(ops)
+}
+
+func diffJSONValue(path string, oldValue, newValue interface{}, ops *[]jsonPatchOp) error {
+	switch o := oldValue.(type) {
+	case map[string]interface{}:
+		n, ok := newValue.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(n))
+		for key := range o {
+			keys = append(keys, key)
+		}
+		for key := range n {
+			if _, ok := o[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "/" + jsonPointerEscaper.Replace(key)
+			oldChild, inOld := o[key]
+			newChild, inNew := n[key]
+			var err error
+			switch {
+			case !inNew:
+				*ops = append(*ops, jsonPatchOp{Op: "remove", Path: child})
+			case !inOld:
+				err = appendJSONPatchOp(ops, "add", child, newChild)
+			default:
+				err = diffJSONValue(child, oldChild, newChild, ops)
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	case []interface{}:
+		n, ok := newValue.([]interface{})
+		if !ok {
+			break
+		}
+		common := len(o)
+		if len(n) < common {
+			common = len(n)
+		}
+		for i := 0; i < common; i++ {
+			err := diffJSONValue(path+"/"+strconv.Itoa(i), o[i], n[i], ops)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		for i := common; i < len(n); i++ {
+			err := appendJSONPatchOp(ops, "add", path+"/"+strconv.Itoa(i), n[i])
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		for i := len(o) - 1; i >= common; i-- {
+			*ops = append(*ops, jsonPatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		return nil
+	}
+	if reflect.DeepEqual(oldValue, newValue) {
+		return nil
+	}
+	return appendJSONPatchOp(ops, "replace", path, newValue)
+}
+
+func appendJSONPatchOp(ops *[]jsonPatchOp, op, path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	*ops = append(*ops, jsonPatchOp{Op: op, Path: path, Value: data})
+	return nil
+}
+
+// jsonPatchColumns returns the old values of the JSON columns of the updated
+// row by name, which the new values are diffed against. It returns nil if the
+// JSON patch is disabled or the row is not updated.
+func (b *canalEntryBuilder) jsonPatchColumns(e *model.RowChangedEvent) map[string]*model.Column {
+	if !b.config.EnableJSONPatch || !e.IsUpdate() ||
+		!b.featureEnabled(featureJSONPatch) {
+		return nil
+	}
+	result := make(map[string]*model.Column)
+	for _, col := range e.PreColumns {
+		if col != nil && col.Type == mysql.TypeJSON && col.Value != nil {
+			result[col.Name] = col
+		}
+	}
+	return result
+}
+
+// applyJSONPatch replaces the new value of the JSON column with the JSON Patch
+// against the old value, and flags it by the `jsonPatch` prop, so that the
+// consumer applies the patch to its copy of the old value. The full value is
+// kept if the old value is null, the new value is null or truncated, or the
+// patch is not shorter than the full value.
+func (b *canalEntryBuilder) applyJSONPatch(
+	column *canal.Column, c *model.Column, oldColumns map[string]*model.Column,
+) error {
+	old, ok := oldColumns[c.Name]
+	if !ok || c.Type != mysql.TypeJSON || c.Value == nil {
+		return nil
+	}
+	for _, p := range column.Props {
+		if p.GetKey() == propTruncated {
+			return nil
+		}
+	}
+	javaType, err := getJavaSQLType(old, getMySQLType(old))
+	if err != nil {
+		return encodeError(cerror.ErrCanalUnsupportedType, err)
+	}
+	oldValue, err := b.formatValue(old.Value, javaType)
+	if err != nil {
+		return encodeError(cerror.ErrCanalUnsupportedType, err)
+	}
+	patch, err := diffJSON(oldValue, column.Value)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	}
+	if len(patch) >= len(column.Value) {
+		return nil
+	}
+	column.Value = string(patch)
+	column.Props = append(column.Props, &canal.Pair{Key: propJSONPatch, Value: "true"})
+	return nil
+}
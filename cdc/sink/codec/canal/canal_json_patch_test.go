@@ -0,0 +1,143 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffJSON(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		oldDoc, newDoc string
+		expected       string
+	}{
+		// the nested field changed.
+		{
+			`{"user": {"name": "Bob", "address": {"city": "Beijing", "zip": 100000}}, "tags": ["a"]}`,
+			`{"user": {"name": "Bob", "address": {"city": "Shanghai", "zip": 100000}}, "tags": ["a"]}`,
+			`[{"op":"replace","path":"/user/address/city","value":"Shanghai"}]`,
+		},
+		// the fields added and removed, the keys are escaped.
+		{
+			`{"a": 1, "b/c": 2}`,
+			`{"a": 1, "d~e": null}`,
+			`[{"op":"remove","path":"/b~1c"},{"op":"add","path":"/d~0e","value":null}]`,
+		},
+		// the elements appended, and the ones removed from the end.
+		{`[1, 2]`, `[1, 3, 4, 5]`, `[{"op":"replace","path":"/1","value":3},` +
+			`{"op":"add","path":"/2","value":4},{"op":"add","path":"/3","value":5}]`},
+		{`[1, 2, 3, 4]`, `[1]`, `[{"op":"remove","path":"/3"},` +
+			`{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`},
+		// the type changed, the value is replaced as a whole.
+		{`{"a": [1]}`, `{"a": {"b": 1}}`, `[{"op":"replace","path":"/a","value":{"b":1}}]`},
+		{`{"a": 1}`, `[1]`, `[{"op":"replace","path":"","value":[1]}]`},
+		// the numbers are kept as is.
+		{`{"a": 1.0}`, `{"a": 1}`, `[{"op":"replace","path":"/a","value":1}]`},
+		{`{"a": 12345678901234567890}`, `{"a": 12345678901234567890}`, `[]`},
+	}
+	for _, c := range cases {
+		patch, err := diffJSON(c.oldDoc, c.newDoc)
+		require.Nil(t, err, c.oldDoc)
+		require.Equal(t, c.expected, string(patch), c.oldDoc)
+	}
+
+	_, err := diffJSON(`{"a": 1}`, `{"a":`)
+	require.Error(t, err)
+}
+
+func TestJSONPatchColumn(t *testing.T) {
+	t.Parallel()
+
+	oldDoc := `{"user": {"name": "Bob", "address": {"city": "Beijing"}}, "bio": "` +
+		strings.Repeat("x", 64) + `"}`
+	newDoc := strings.Replace(oldDoc, "Beijing", "Shanghai", 1)
+	newRow := func(oldValue, newValue interface{}) *model.RowChangedEvent {
+		e := &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+				{Name: "doc", Type: mysql.TypeJSON, Value: newValue},
+			},
+		}
+		if oldValue != "" {
+			e.PreColumns = []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+				{Name: "doc", Type: mysql.TypeJSON, Value: oldValue},
+			}
+		}
+		return e
+	}
+	// docColumn returns the value of the doc column after the change and
+	// whether it's flagged as the JSON Patch.
+	docColumn := func(codecConfig *common.Config, e *model.RowChangedEvent) (string, bool) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		column := rc.GetRowDatas()[0].GetAfterColumns()[1]
+		require.Equal(t, "doc", column.GetName())
+		for _, p := range column.GetProps() {
+			if p.GetKey() == propJSONPatch {
+				require.Equal(t, "true", p.GetValue())
+				return column.GetValue(), true
+			}
+		}
+		return column.GetValue(), false
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableJSONPatch = true
+
+	// only the nested field changed is emitted.
+	value, ok := docColumn(codecConfig, newRow(oldDoc, newDoc))
+	require.True(t, ok)
+	require.Equal(t, `[{"op":"replace","path":"/user/address/city","value":"Shanghai"}]`, value)
+
+	// the full value is emitted if the old value is null, the row is
+	// inserted, or the patch is not shorter.
+	for _, e := range []*model.RowChangedEvent{
+		newRow(nil, newDoc),
+		newRow("", newDoc),
+		newRow(`{"a": 1}`, `{"b": 2}`),
+	} {
+		value, ok = docColumn(codecConfig, e)
+		require.False(t, ok)
+		require.Equal(t, e.Columns[1].Value, value)
+	}
+
+	// the invalid JSON fails the encoding.
+	_, err := newCanalEntryBuilder(codecConfig).fromRowEvent(newRow(`{"a":`, newDoc))
+	require.Error(t, err)
+
+	// the full value is emitted if disabled.
+	value, ok = docColumn(common.NewConfig(config.ProtocolCanal), newRow(oldDoc, newDoc))
+	require.False(t, ok)
+	require.Equal(t, newDoc, value)
+	codecConfig.FeatureLevel = 2
+	value, ok = docColumn(codecConfig, newRow(oldDoc, newDoc))
+	require.False(t, ok)
+	require.Equal(t, newDoc, value)
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/json"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/avro"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// The Pulsar schema of the canal-json messages of the rows is the Pulsar
+// JSON schema, whose definition is an Avro record schema describing the
+// canal-json message of the table, so that the schema registry of Pulsar
+// accepts it. The canal types are mapped as below:
+//
+//	data, old          array of the row record, nullable
+//	sqlType            map of int, nullable
+//	mysqlType          map of string, nullable
+//	pkNames            array of string, nullable
+//	id, es, ts         long
+//	isDdl              boolean
+//	database, table,
+//	type, sql          string
+//	_tidb              the record of commitTs and watermarkTs, nullable,
+//	                   only if the TiDB extension is enabled
+//
+// Since canal-json renders every column value as a string, the row record
+// has a nullable string field for each column of the row, whatever the canal
+// type of it is, and the field carries the `sqlType` and the `mysqlType` of
+// the column in the attributes, like the Avro bridge. The names are
+// sanitized into the Avro names, so the column not named as an Avro name is
+// named differently in the schema from the message.
+
+const (
+	pulsarSchemaTypeJSON  = "JSON"
+	pulsarSchemaRowSuffix = "_row"
+)
+
+// pulsarSchemaInfo is the Pulsar SchemaInfo in the JSON form.
+type pulsarSchemaInfo struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Schema     string            `json:"schema"`
+	Properties map[string]string `json:"properties"`
+}
+
+// JSONPulsarSchemaInfo returns the Pulsar SchemaInfo in the JSON form of the
+// canal-json messages of the rows sharing the schema of the row, which is
+// named after the schema and the table of the row.
+func JSONPulsarSchemaInfo(e *model.RowChangedEvent, enableTiDBExtension bool) ([]byte, error) {
+	image := e.Columns
+	if len(image) == 0 {
+		image = e.PreColumns
+	}
+	rowRecord := &avroRecord{
+		Type: "record",
+		Name: avro.SanitizeName(e.Table.Table) + pulsarSchemaRowSuffix,
+	}
+	for _, column := range image {
+		if column == nil {
+			continue
+		}
+		mysqlType := getMySQLType(column)
+		javaType, err := getJavaSQLType(column, mysqlType)
+		if err != nil {
+			return nil, encodeError(cerror.ErrCanalUnsupportedType, err)
+		}
+		sqlType := int32(javaType)
+		rowRecord.Fields = append(rowRecord.Fields, avroField{
+			Name:      avro.SanitizeName(column.Name),
+			Type:      []interface{}{"null", "string"},
+			Default:   json.RawMessage("null"),
+			SQLType:   &sqlType,
+			MySQLType: mysqlType,
+		})
+	}
+
+	namespace := avro.SanitizeName(e.Table.Schema)
+	nullable := func(t interface{}) interface{} { return []interface{}{"null", t} }
+	array := func(items interface{}) interface{} {
+		return map[string]interface{}{"type": "array", "items": items}
+	}
+	fields := []avroField{
+		{Name: "id", Type: "long"},
+		{Name: "database", Type: "string"},
+		{Name: "table", Type: "string"},
+		{Name: "pkNames", Type: nullable(array("string")), Default: json.RawMessage("null")},
+		{Name: "isDdl", Type: "boolean"},
+		{Name: "type", Type: "string"},
+		{Name: "es", Type: "long"},
+		{Name: "ts", Type: "long"},
+		{Name: "sql", Type: "string"},
+		{
+			Name:    "sqlType",
+			Type:    nullable(map[string]string{"type": "map", "values": "int"}),
+			Default: json.RawMessage("null"),
+		},
+		{
+			Name:    "mysqlType",
+			Type:    nullable(map[string]string{"type": "map", "values": "string"}),
+			Default: json.RawMessage("null"),
+		},
+		{Name: "data", Type: nullable(array(rowRecord)), Default: json.RawMessage("null")},
+		{
+			Name:    "old",
+			Type:    nullable(array(namespace + "." + rowRecord.Name)),
+			Default: json.RawMessage("null"),
+		},
+	}
+	if enableTiDBExtension {
+		fields = append(fields, avroField{
+			Name: "_tidb",
+			Type: nullable(&avroRecord{
+				Type: "record",
+				Name: "tidbExtension",
+				Fields: []avroField{
+					{Name: "commitTs", Type: nullable("long"), Default: json.RawMessage("null")},
+					{Name: "watermarkTs", Type: nullable("long"), Default: json.RawMessage("null")},
+				},
+			}),
+			Default: json.RawMessage("null"),
+		})
+	}
+	schema, err := json.Marshal(&avroRecord{
+		Type:      "record",
+		Name:      avro.SanitizeName(e.Table.Table),
+		Namespace: namespace,
+		Fields:    fields,
+	})
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrCanalMarshalFailed, err)
+	}
+
+	info, err := json.Marshal(&pulsarSchemaInfo{
+		Name:   e.Table.Schema + "." + e.Table.Table,
+		Type:   pulsarSchemaTypeJSON,
+		Schema: string(schema),
+		// the same as the JSON schema of the Pulsar clients, whose fields
+		// are nullable and the time is not converted.
+		Properties: map[string]string{
+			"__alwaysAllowNull":         "true",
+			"__jsr310ConversionEnabled": "false",
+		},
+	})
+	if err != nil {
+		return nil, cerror.WrapError(cerror.ErrCanalMarshalFailed, err)
+	}
+	return info, nil
+}
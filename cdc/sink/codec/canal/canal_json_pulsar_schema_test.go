@@ -0,0 +1,122 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPulsarSchemaInfo(t *testing.T) {
+	t.Parallel()
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "shop", Table: "order"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+			{Name: "item", Type: mysql.TypeVarchar, Value: []byte("book")},
+			{Name: "price", Type: mysql.TypeNewDecimal, Value: "12.50"},
+			{Name: "created_at", Type: mysql.TypeDatetime, Value: "2022-11-02 12:00:00"},
+			{Name: "note", Type: mysql.TypeBlob, Value: nil},
+		},
+	}
+
+	for _, enableTiDBExtension := range []bool{false, true} {
+		data, err := JSONPulsarSchemaInfo(row, enableTiDBExtension)
+		require.NoError(t, err)
+
+		var info pulsarSchemaInfo
+		require.NoError(t, json.Unmarshal(data, &info))
+		require.Equal(t, "shop.order", info.Name)
+		require.Equal(t, "JSON", info.Type)
+		require.Equal(t, map[string]string{
+			"__alwaysAllowNull":         "true",
+			"__jsr310ConversionEnabled": "false",
+		}, info.Properties)
+
+		// the definition is a valid Avro schema.
+		_, err = goavro.NewCodec(info.Schema)
+		require.NoError(t, err)
+
+		var schema struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			Fields    []struct {
+				Name string          `json:"name"`
+				Type json.RawMessage `json:"type"`
+			} `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(info.Schema), &schema))
+		require.Equal(t, "order", schema.Name)
+		require.Equal(t, "shop", schema.Namespace)
+
+		// the fields are the keys of the canal-json message of the row.
+		codecConfig := common.NewConfig(config.ProtocolCanalJSON)
+		codecConfig.EnableTiDBExtension = enableTiDBExtension
+		encoder := NewJSONBatchEncoderBuilder(codecConfig).Build()
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", row, nil))
+		messages, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		var message map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(messages[0].Value, &message))
+		fields := make(map[string]json.RawMessage, len(schema.Fields))
+		for _, f := range schema.Fields {
+			fields[f.Name] = f.Type
+		}
+		require.Len(t, fields, len(message))
+		for key := range message {
+			require.Contains(t, fields, key)
+		}
+
+		// every column is a nullable string typed in the attributes as the
+		// message types it.
+		var dataType []json.RawMessage
+		require.NoError(t, json.Unmarshal(fields["data"], &dataType))
+		require.Len(t, dataType, 2)
+		var array struct {
+			Items struct {
+				Name   string `json:"name"`
+				Fields []struct {
+					Name      string   `json:"name"`
+					Type      []string `json:"type"`
+					SQLType   int32    `json:"sqlType"`
+					MySQLType string   `json:"mysqlType"`
+				} `json:"fields"`
+			} `json:"items"`
+		}
+		require.NoError(t, json.Unmarshal(dataType[1], &array))
+		require.Equal(t, "order_row", array.Items.Name)
+		var sqlType map[string]int32
+		require.NoError(t, json.Unmarshal(message["sqlType"], &sqlType))
+		var mysqlType map[string]string
+		require.NoError(t, json.Unmarshal(message["mysqlType"], &mysqlType))
+		require.Len(t, array.Items.Fields, len(row.Columns))
+		for i, f := range array.Items.Fields {
+			require.Equal(t, row.Columns[i].Name, f.Name)
+			require.Equal(t, []string{"null", "string"}, f.Type)
+			require.Equal(t, sqlType[f.Name], f.SQLType)
+			require.Equal(t, mysqlType[f.Name], f.MySQLType)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/tiflow/cdc/model"
+)
+
+// keyOnlyColumns returns the name of the columns emitted in the key-only mode,
+// nil means all the columns are emitted. They are the handle key columns,
+// i.e. the primary key, or the not null unique key if the table has no
+// primary key. If the row has no handle key, all the columns are emitted,
+// since there is no other way to identify the row.
+func (b *canalEntryBuilder) keyOnlyColumns(e *model.RowChangedEvent) map[string]struct{} {
+	if !b.config.KeyOnly {
+		return nil
+	}
+	columns := e.Columns
+	if len(columns) == 0 {
+		columns = e.PreColumns
+	}
+	var result map[string]struct{}
+	for _, column := range columns {
+		if column == nil || !column.Flag.IsHandleKey() {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]struct{})
+		}
+		result[column.Name] = struct{}{}
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyOnly(t *testing.T) {
+	t.Parallel()
+
+	columns := func(id int64, name string, flag model.ColumnFlagType) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: flag, Value: id},
+			{Name: "name", Type: mysql.TypeVarchar, Value: name},
+			{Name: "email", Type: mysql.TypeVarchar, Value: name + "@pingcap.com"},
+		}
+	}
+	keyFlag := model.HandleKeyFlag | model.PrimaryKeyFlag
+	table := &model.TableName{Schema: "cdc", Table: "person"}
+	insertEvent := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    table,
+		Columns:  columns(1, "Bob", keyFlag),
+	}
+	updateEvent := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      table,
+		PreColumns: columns(1, "Bob", keyFlag),
+		Columns:    columns(1, "Alice", keyFlag),
+	}
+	deleteEvent := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      table,
+		PreColumns: columns(1, "Alice", keyFlag),
+	}
+
+	// encode returns the event type, and the values of the before and the
+	// after columns by name.
+	encode := func(
+		codecConfig *common.Config, e *model.RowChangedEvent,
+	) (canal.EventType, map[string]string, map[string]string) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		require.Equal(t, entry.GetHeader().GetEventType(), rc.GetEventType())
+		values := func(columns []*canal.Column) map[string]string {
+			if len(columns) == 0 {
+				return nil
+			}
+			result := make(map[string]string)
+			for _, c := range columns {
+				result[c.GetName()] = c.GetValue()
+			}
+			return result
+		}
+		rowData := rc.GetRowDatas()[0]
+		return rc.GetEventType(), values(rowData.GetBeforeColumns()), values(rowData.GetAfterColumns())
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.KeyOnly = true
+
+	tp, before, after := encode(codecConfig, insertEvent)
+	require.Equal(t, canal.EventType_INSERT, tp)
+	require.Nil(t, before)
+	require.Equal(t, map[string]string{"id": "1"}, after)
+
+	tp, before, after = encode(codecConfig, updateEvent)
+	require.Equal(t, canal.EventType_UPDATE, tp)
+	require.Equal(t, map[string]string{"id": "1"}, before)
+	require.Equal(t, map[string]string{"id": "1"}, after)
+
+	tp, before, after = encode(codecConfig, deleteEvent)
+	require.Equal(t, canal.EventType_DELETE, tp)
+	require.Equal(t, map[string]string{"id": "1"}, before)
+	require.Nil(t, after)
+
+	// all the columns are emitted by default.
+	_, before, after = encode(common.NewConfig(config.ProtocolCanal), updateEvent)
+	require.Len(t, before, 3)
+	require.Len(t, after, 3)
+
+	// all the columns are emitted if the row has no key to identify it.
+	_, _, after = encode(codecConfig, &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    table,
+		Columns:  columns(1, "Bob", 0),
+	})
+	require.Len(t, after, 3)
+}
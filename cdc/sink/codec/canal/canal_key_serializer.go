@@ -0,0 +1,124 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// KeyColumn is a handle key column of the row, whose name and value are the
+// same as the ones emitted in the entry.
+type KeyColumn struct {
+	Name  string
+	Value string
+}
+
+// KeySerializer serializes the key of the row, which consists of the schema,
+// the table and the handle key columns of the row in the order of the handle
+// key, into the key of the message carrying the row. The key must be the same
+// for the same schema, table and columns, so that the messages of the same
+// row share the same key. The error returned is classified as
+// ErrCanalMarshalFailed.
+type KeySerializer interface {
+	SerializeKey(schema, table string, columns []KeyColumn) ([]byte, error)
+}
+
+// canalRowKey is the key identifying a row.
+type canalRowKey struct {
+	Schema string            `json:"schema"`
+	Table  string            `json:"table"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// jsonKeySerializer serializes the key into a JSON object of the schema, the
+// table and the keys by the column names, it's the default KeySerializer.
+type jsonKeySerializer struct{}
+
+// NewJSONKeySerializer returns the KeySerializer serializing the key into a
+// JSON object, e.g. {"schema":"s","table":"t","keys":{"id":"1"}}. Since the
+// keys are sorted by the column names, the key is stable.
+func NewJSONKeySerializer() KeySerializer {
+	return jsonKeySerializer{}
+}
+
+// SerializeKey implements the KeySerializer interface
+func (jsonKeySerializer) SerializeKey(schema, table string, columns []KeyColumn) ([]byte, error) {
+	key := &canalRowKey{
+		Schema: schema,
+		Table:  table,
+		Keys:   make(map[string]string, len(columns)),
+	}
+	for _, c := range columns {
+		key.Keys[c.Name] = c.Value
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return data, nil
+}
+
+// defaultKeyDelimiter is the delimiter of the delimited keys if it's not
+// configured.
+const defaultKeyDelimiter = "|"
+
+// delimitedKeySerializer serializes the key into the schema, the table and
+// the values of the columns joined by the delimiter.
+type delimitedKeySerializer struct {
+	delimiter string
+	escaper   *strings.Replacer
+}
+
+// NewDelimitedKeySerializer returns the KeySerializer serializing the key
+// into the schema, the table and the values of the columns in the order of
+// the handle key, joined by the delimiter, e.g. s|t|1 for the delimiter |.
+// The backslash and the delimiter in them are escaped by the backslash, so
+// the key is still unambiguous.
+func NewDelimitedKeySerializer(delimiter string) KeySerializer {
+	return &delimitedKeySerializer{
+		delimiter: delimiter,
+		escaper:   strings.NewReplacer(`\`, `\\`, delimiter, `\`+delimiter),
+	}
+}
+
+// SerializeKey implements the KeySerializer interface
+func (s *delimitedKeySerializer) SerializeKey(schema, table string, columns []KeyColumn) ([]byte, error) {
+	parts := make([]string, 0, len(columns)+2)
+	parts = append(parts, s.escaper.Replace(schema), s.escaper.Replace(table))
+	for _, c := range columns {
+		parts = append(parts, s.escaper.Replace(c.Value))
+	}
+	return []byte(strings.Join(parts, s.delimiter)), nil
+}
+
+// newKeySerializer returns the KeySerializer of the key format, or nil if
+// the rows are not keyed by the key format.
+func newKeySerializer(config *common.Config) KeySerializer {
+	switch config.KeyFormat {
+	case common.KeyFormatJSON:
+		return NewJSONKeySerializer()
+	case common.KeyFormatDelimited:
+		delimiter := config.KeyDelimiter
+		if delimiter == "" {
+			delimiter = defaultKeyDelimiter
+		}
+		return NewDelimitedKeySerializer(delimiter)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySerializers(t *testing.T) {
+	t.Parallel()
+
+	columns := []KeyColumn{{Name: "tenant", Value: "acme"}, {Name: "id", Value: "1"}}
+	reversed := []KeyColumn{columns[1], columns[0]}
+
+	serializer := NewJSONKeySerializer()
+	key, err := serializer.SerializeKey("test", "t", columns)
+	require.NoError(t, err)
+	require.Equal(t, `{"schema":"test","table":"t","keys":{"id":"1","tenant":"acme"}}`, string(key))
+	// the keys are sorted by the column names.
+	again, err := serializer.SerializeKey("test", "t", reversed)
+	require.NoError(t, err)
+	require.Equal(t, key, again)
+
+	serializer = NewDelimitedKeySerializer("|")
+	key, err = serializer.SerializeKey("test", "t", columns)
+	require.NoError(t, err)
+	require.Equal(t, "test|t|acme|1", string(key))
+	again, err = serializer.SerializeKey("test", "t", columns)
+	require.NoError(t, err)
+	require.Equal(t, key, again)
+
+	// the delimiter and the backslash are escaped.
+	key, err = serializer.SerializeKey("a|b", `c\d`, []KeyColumn{{Name: "id", Value: `|\`}})
+	require.NoError(t, err)
+	require.Equal(t, `a\|b|c\\d|\|\\`, string(key))
+
+	serializer = NewDelimitedKeySerializer("::")
+	key, err = serializer.SerializeKey("test", "t", []KeyColumn{{Name: "id", Value: "a::b"}})
+	require.NoError(t, err)
+	require.Equal(t, `test::t::a\::b`, string(key))
+}
+
+func TestCanalBatchEncoderKeyFormat(t *testing.T) {
+	t.Parallel()
+
+	table := &model.TableName{Schema: "test", Table: "t"}
+	handleKeyFlag := model.HandleKeyFlag | model.PrimaryKeyFlag
+	update := &model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    table,
+		PreColumns: []*model.Column{
+			{Name: "tenant", Type: mysql.TypeVarchar, Flag: handleKeyFlag, Value: []byte("acme")},
+			{Name: "id", Type: mysql.TypeLong, Flag: handleKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Bob")},
+		},
+		Columns: []*model.Column{
+			{Name: "tenant", Type: mysql.TypeVarchar, Flag: handleKeyFlag, Value: []byte("acme")},
+			{Name: "id", Type: mysql.TypeLong, Flag: handleKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Alice")},
+		},
+	}
+	deleted := &model.RowChangedEvent{
+		CommitTs:   2,
+		Table:      table,
+		PreColumns: update.Columns,
+	}
+
+	for _, c := range []struct {
+		format   string
+		expected string
+	}{
+		{common.KeyFormatJSON, `{"schema":"test","table":"t","keys":{"id":"1","tenant":"acme"}}`},
+		{common.KeyFormatDelimited, "test|t|acme|1"},
+	} {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.KeyFormat = c.format
+		encoder := NewBatchEncoderBuilder(codecConfig).Build()
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", update, nil))
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", deleted, nil))
+
+		// each row is keyed, and no tombstone follows the delete.
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 2)
+		for _, msg := range msgs {
+			require.Equal(t, c.expected, string(msg.Key))
+			require.NotNil(t, msg.Value)
+			require.Equal(t, 1, msg.GetRowsCount())
+		}
+	}
+
+	// the key serializer provided overrides the key format.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.KeyFormat = common.KeyFormatJSON
+	encoder := NewBatchEncoderBuilder(codecConfig,
+		WithKeySerializer(upperKeySerializer{})).Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", update, nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "TEST.T.ACME.1", string(msgs[0].Key))
+
+	// the rows are batched without the key by default.
+	encoder = NewBatchEncoderBuilder(common.NewConfig(config.ProtocolCanal)).Build()
+	require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", update, nil))
+	require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", deleted, nil))
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Nil(t, msgs[0].Key)
+}
+
+// upperKeySerializer joins the upper cased schema, table and values by dots.
+type upperKeySerializer struct{}
+
+func (upperKeySerializer) SerializeKey(schema, table string, columns []KeyColumn) ([]byte, error) {
+	parts := []string{schema, table}
+	for _, c := range columns {
+		parts = append(parts, c.Value)
+	}
+	return []byte(strings.ToUpper(strings.Join(parts, "."))), nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strconv"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// messageID computes the id identifying the row change, which is the same
+// for every encoding of the row change, e.g. when it's resent on retry, so
+// that the consumer deduplicates the rows by it. A consumer reproduces the
+// id by calling it with the decoded entry.
+//
+// The id is the lowercase hex of the SHA-256 digest over the fields below,
+// each field is serialized as its length in 4 bytes big endian, followed by
+// its bytes:
+//
+//	schema:   the upstream schema name, before the name mapping
+//	table:    the upstream table name, before the name mapping
+//	commitTs: the decimal representation of the commit ts
+//	op:       the event type of the entry, i.e. INSERT, UPDATE or DELETE
+//	keys:     a name and a value field for each key column, sorted by name
+//
+// The key columns are the handle key columns of the row, i.e. the primary
+// key or the not null unique key, taken from the after image, or the before
+// image if the row is deleted. The name is the upstream column name, and the
+// value is the value string of the column as formatted in the entry, before
+// the truncation, and empty if the value is null. If the row has no handle
+// key, all the columns of the image are taken instead.
+func messageID(schema, table string, commitTs uint64, op canal.EventType, keys [][2]string) string {
+	h := sha256.New()
+	var buf [4]byte
+	write := func(field string) {
+		binary.BigEndian.PutUint32(buf[:], uint32(len(field)))
+		h.Write(buf[:])
+		h.Write([]byte(field))
+	}
+	write(schema)
+	write(table)
+	write(strconv.FormatUint(commitTs, 10))
+	write(op.String())
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0] < keys[j][0] })
+	for _, key := range keys {
+		write(key[0])
+		write(key[1])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// appendMessageID stamps the id of the row change into the header props,
+// see messageID for how it's computed.
+func (b *canalEntryBuilder) appendMessageID(h *canal.Header, e *model.RowChangedEvent) error {
+	if !b.config.EnableMessageID || !b.featureEnabled(featureMessageID) {
+		return nil
+	}
+	columns := e.HandleKeyColumns()
+	if len(columns) == 0 {
+		columns = e.Columns
+		if e.IsDelete() {
+			columns = e.PreColumns
+		}
+	}
+	keys := make([][2]string, 0, len(columns))
+	for _, col := range columns {
+		if col == nil {
+			continue
+		}
+		javaType, err := getJavaSQLType(col, getMySQLType(col))
+		if err != nil {
+			return encodeError(cerror.ErrCanalUnsupportedType, err)
+		}
+		value, err := b.formatValue(col.Value, javaType)
+		if err != nil {
+			return encodeError(cerror.ErrCanalUnsupportedType, err)
+		}
+		keys = append(keys, [2]string{col.Name, value})
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propMessageID,
+		Value: messageID(e.Table.Schema, e.Table.Table, e.CommitTs, h.GetEventType(), keys),
+	})
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageID(t *testing.T) {
+	t.Parallel()
+
+	keyFlag := model.HandleKeyFlag | model.PrimaryKeyFlag
+	newRow := func(commitTs uint64, flag model.ColumnFlagType) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: flag, Value: 1},
+				{Name: "code", Type: mysql.TypeVarchar, Flag: flag, Value: []byte("a")},
+				{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Bob")},
+			},
+		}
+	}
+	// encode encodes the row by a new encoder, and returns the messageId
+	// prop of the entry and whether it's present.
+	encode := func(
+		codecConfig *common.Config, e *model.RowChangedEvent, opts ...Option,
+	) (string, bool) {
+		encoder := NewBatchEncoderBuilder(codecConfig, opts...).Build()
+		err := encoder.AppendRowChangedEvent(context.Background(), "", e, nil)
+		require.Nil(t, err)
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+
+		packet := &canal.Packet{}
+		require.Nil(t, proto.Unmarshal(msgs[0].Value, packet))
+		messages := &canal.Messages{}
+		require.Nil(t, proto.Unmarshal(packet.GetBody(), messages))
+		require.Len(t, messages.GetMessages(), 1)
+		entry := &canal.Entry{}
+		require.Nil(t, proto.Unmarshal(messages.GetMessages()[0], entry))
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propMessageID {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+	// expected reproduces the id by the documented algorithm.
+	expected := func(fields ...string) string {
+		h := sha256.New()
+		for _, field := range fields {
+			var buf [4]byte
+			binary.BigEndian.PutUint32(buf[:], uint32(len(field)))
+			h.Write(buf[:])
+			h.Write([]byte(field))
+		}
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableMessageID = true
+
+	// the encodings of the same row change by different encoders share the id.
+	id, ok := encode(codecConfig, newRow(417318403368288260, keyFlag))
+	require.True(t, ok)
+	again, ok := encode(codecConfig, newRow(417318403368288260, keyFlag))
+	require.True(t, ok)
+	require.Equal(t, id, again)
+	require.Equal(t, expected("test", "t", "417318403368288260", "INSERT",
+		"code", "a", "id", "1"), id)
+
+	// the id is not affected by the name mapping or the non-key columns.
+	mapping := WithNameMapping(func(schema, table string) (string, string) {
+		return "mapped_" + schema, "mapped_" + table
+	})
+	row := newRow(417318403368288260, keyFlag)
+	row.Columns[2].Value = []byte("Alice")
+	value, ok := encode(codecConfig, row, mapping)
+	require.True(t, ok)
+	require.Equal(t, id, value)
+
+	// the different row changes have different ids.
+	value, ok = encode(codecConfig, newRow(417318403368288261, keyFlag))
+	require.True(t, ok)
+	require.NotEqual(t, id, value)
+	row = newRow(417318403368288260, keyFlag)
+	row.Columns[0].Value = 2
+	value, ok = encode(codecConfig, row)
+	require.True(t, ok)
+	require.NotEqual(t, id, value)
+	row = newRow(417318403368288260, keyFlag)
+	row.PreColumns = row.Columns
+	value, ok = encode(codecConfig, row)
+	require.True(t, ok)
+	require.Equal(t, expected("test", "t", "417318403368288260", "UPDATE",
+		"code", "a", "id", "1"), value)
+	row = newRow(417318403368288260, keyFlag)
+	row.PreColumns, row.Columns = row.Columns, nil
+	value, ok = encode(codecConfig, row)
+	require.True(t, ok)
+	require.Equal(t, expected("test", "t", "417318403368288260", "DELETE",
+		"code", "a", "id", "1"), value)
+
+	// all the columns are taken if the row has no handle key.
+	value, ok = encode(codecConfig, newRow(417318403368288260, 0))
+	require.True(t, ok)
+	require.Equal(t, expected("test", "t", "417318403368288260", "INSERT",
+		"code", "a", "id", "1", "name", "Bob"), value)
+
+	// the prop is omitted if disabled.
+	_, ok = encode(common.NewConfig(config.ProtocolCanal), newRow(417318403368288260, keyFlag))
+	require.False(t, ok)
+	codecConfig.FeatureLevel = 2
+	_, ok = encode(codecConfig, newRow(417318403368288260, keyFlag))
+	require.False(t, ok)
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/format"
+	timodel "github.com/pingcap/tidb/parser/model"
+	_ "github.com/pingcap/tidb/types/parser_driver" // for parsing the literals
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// NameMapping maps the name of a schema and a table to the one emitted,
+// the table is empty if only the schema is mapped.
+type NameMapping func(schema, table string) (string, string)
+
+// mapName maps the schema and table name by the configured name mapping,
+// then transforms the case of them if required, and replaces them with the
+// safe identifiers if they're not safe, see safeIdentifier.
+func (b *canalEntryBuilder) mapName(schema, table string) (string, string) {
+	schema, table = b.mapOriginalName(schema, table)
+	return b.safeIdentifier(schema), b.safeIdentifier(table)
+}
+
+// mapOriginalName maps the schema and table name as mapName does, except
+// that they're not replaced with the safe identifiers.
+func (b *canalEntryBuilder) mapOriginalName(schema, table string) (string, string) {
+	if b.nameMapping != nil {
+		schema, table = b.nameMapping(schema, table)
+	}
+	if b.config.ApplyCaseToTableNames {
+		schema = transformCase(schema, b.config.ColumnNameCase)
+		table = transformCase(table, b.config.ColumnNameCase)
+	}
+	return schema, table
+}
+
+// qualifyTable returns the table name emitted, which is qualified by the
+// schema if required. The empty table, e.g. of the DDL of the schema, is
+// never qualified.
+func qualifyTable(schema, table, qualification string) string {
+	if qualification != common.TableQualificationQualified || table == "" {
+		return table
+	}
+	return schema + "." + table
+}
+
+// columnName transforms the case of the column name as configured.
+func (b *canalEntryBuilder) columnName(name string) string {
+	return transformCase(name, b.config.ColumnNameCase)
+}
+
+// transformCase transforms the case of the name.
+func transformCase(name, nameCase string) string {
+	switch nameCase {
+	case common.NameCaseLower:
+		return strings.ToLower(name)
+	case common.NameCaseUpper:
+		return strings.ToUpper(name)
+	default:
+		return name
+	}
+}
+
+// tableNameMapper rewrites the names of the tables in the AST.
+type tableNameMapper struct {
+	// defaultSchema is the schema of the tables not qualified in the query.
+	defaultSchema string
+	mapping       func(schema, table string) (string, string)
+}
+
+// Enter implements the ast.Visitor interface
+func (m *tableNameMapper) Enter(in ast.Node) (ast.Node, bool) {
+	t, ok := in.(*ast.TableName)
+	if !ok {
+		return in, false
+	}
+	schema := t.Schema.O
+	if schema == "" {
+		schema = m.defaultSchema
+	}
+	newSchema, newTable := m.mapping(schema, t.Name.O)
+	// keep the table unqualified if the schema is not changed.
+	if t.Schema.O != "" || newSchema != schema {
+		t.Schema = timodel.NewCIStr(newSchema)
+	}
+	t.Name = timodel.NewCIStr(newTable)
+	return in, true
+}
+
+// Leave implements the ast.Visitor interface
+func (m *tableNameMapper) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// mapsNames returns true if the names of the schemas and tables may be
+// changed by mapName.
+func (b *canalEntryBuilder) mapsNames() bool {
+	handling := b.config.IdentifierHandling
+	return b.nameMapping != nil || b.config.ApplyCaseToTableNames ||
+		(handling != "" && handling != common.IdentifierHandlingNone)
+}
+
+// rewriteDDLQuery rewrites the names of the schemas and tables in the DDL
+// query by mapName, so that they're the same as the ones of the row events.
+// The query is parsed and restored rather than replaced by string, so that
+// the string literals and comments are never touched, and the restored query
+// is formatted.
+func (b *canalEntryBuilder) rewriteDDLQuery(query, defaultSchema string) (string, error) {
+	if !b.mapsNames() || query == "" {
+		return query, nil
+	}
+	stmts, _, err := parser.New().ParseSQL(query)
+	if err != nil {
+		return "", cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	}
+
+	mapSchema := func(name timodel.CIStr) timodel.CIStr {
+		schema, _ := b.mapName(name.O, "")
+		return timodel.NewCIStr(schema)
+	}
+	restoreFlags := format.DefaultRestoreFlags | format.RestoreTiDBSpecialComment
+	result := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.CreateDatabaseStmt:
+			s.Name = mapSchema(s.Name)
+		case *ast.AlterDatabaseStmt:
+			s.Name = mapSchema(s.Name)
+		case *ast.DropDatabaseStmt:
+			s.Name = mapSchema(s.Name)
+		default:
+			stmt.Accept(&tableNameMapper{
+				defaultSchema: defaultSchema,
+				mapping:       b.mapName,
+			})
+		}
+		var sb strings.Builder
+		if err := stmt.Restore(format.NewRestoreCtx(restoreFlags, &sb)); err != nil {
+			return "", cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+		}
+		result = append(result, sb.String())
+	}
+	return strings.Join(result, ";"), nil
+}
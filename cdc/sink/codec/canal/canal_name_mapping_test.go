@@ -0,0 +1,300 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func newNameMappingBuilder() *canalEntryBuilder {
+	builder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
+	builder.nameMapping = func(schema, table string) (string, string) {
+		return "prefix_" + schema, table
+	}
+	return builder
+}
+
+func TestNameMappingRowEvent(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "person"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+		},
+	}
+	entry, err := newNameMappingBuilder().fromRowEvent(event)
+	require.Nil(t, err)
+	require.Equal(t, "prefix_cdc", entry.GetHeader().GetSchemaName())
+	require.Equal(t, "person", entry.GetHeader().GetTableName())
+	// the event is not modified.
+	require.Equal(t, "cdc", event.Table.Schema)
+}
+
+func TestNameMappingDDLEvent(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		tp       mm.ActionType
+		schema   string
+		table    string
+		query    string
+		expected string
+	}{
+		{
+			tp:     mm.ActionCreateTable,
+			schema: "cdc",
+			table:  "person",
+			// the string literal contains the schema name, which must not be rewritten.
+			query: "CREATE TABLE `cdc`.`person` (`id` INT PRIMARY KEY, " +
+				"`name` VARCHAR(32) DEFAULT 'cdc' COMMENT 'cdc.person')",
+			expected: "CREATE TABLE `prefix_cdc`.`person` (`id` INT PRIMARY KEY," +
+				"`name` VARCHAR(32) DEFAULT _UTF8MB4'cdc' COMMENT 'cdc.person')",
+		},
+		{
+			tp:     mm.ActionAddColumn,
+			schema: "cdc",
+			table:  "person",
+			// the table is not qualified, it's in the schema of the DDL.
+			query:    "ALTER TABLE person ADD COLUMN comment TEXT DEFAULT 'from cdc'",
+			expected: "ALTER TABLE `prefix_cdc`.`person` ADD COLUMN `comment` TEXT DEFAULT _UTF8MB4'from cdc'",
+		},
+		{
+			tp:       mm.ActionRenameTable,
+			schema:   "cdc",
+			table:    "person",
+			query:    "RENAME TABLE `cdc`.`person` TO `cdc`.`people`",
+			expected: "RENAME TABLE `prefix_cdc`.`person` TO `prefix_cdc`.`people`",
+		},
+		{
+			tp:       mm.ActionCreateSchema,
+			schema:   "cdc",
+			query:    "CREATE DATABASE `cdc`",
+			expected: "CREATE DATABASE `prefix_cdc`",
+		},
+	}
+
+	builder := newNameMappingBuilder()
+	for _, tc := range testCases {
+		event := &model.DDLEvent{
+			CommitTs: 417318403368288260,
+			TableInfo: &model.TableInfo{
+				TableName: model.TableName{Schema: tc.schema, Table: tc.table},
+			},
+			Query: tc.query,
+			Type:  tc.tp,
+		}
+		entry, err := builder.fromDDLEvent(event)
+		require.Nil(t, err)
+		require.Equal(t, "prefix_cdc", entry.GetHeader().GetSchemaName())
+		require.Equal(t, tc.table, entry.GetHeader().GetTableName())
+
+		rc := &canal.RowChange{}
+		err = proto.Unmarshal(entry.GetStoreValue(), rc)
+		require.Nil(t, err)
+		require.Equal(t, tc.expected, rc.GetSql())
+		require.Equal(t, "prefix_cdc", rc.GetDdlSchemaName())
+	}
+
+	// the tables in the query are transformed to the case of the header.
+	builder = newNameMappingBuilder()
+	builder.config.ApplyCaseToTableNames = true
+	builder.config.ColumnNameCase = common.NameCaseUpper
+	entry, err := builder.fromDDLEvent(&model.DDLEvent{
+		CommitTs: 417318403368288260,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{Schema: "cdc", Table: "person"},
+		},
+		Query: "ALTER TABLE person ADD COLUMN comment TEXT",
+		Type:  mm.ActionAddColumn,
+	})
+	require.Nil(t, err)
+	require.Equal(t, "PREFIX_CDC", entry.GetHeader().GetSchemaName())
+	require.Equal(t, "PERSON", entry.GetHeader().GetTableName())
+	rc := &canal.RowChange{}
+	err = proto.Unmarshal(entry.GetStoreValue(), rc)
+	require.Nil(t, err)
+	require.Equal(t, "ALTER TABLE `PREFIX_CDC`.`PERSON` ADD COLUMN `comment` TEXT", rc.GetSql())
+
+	// the query is kept as is without the name mapping.
+	entry, err = newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal)).fromDDLEvent(testCaseDDL)
+	require.Nil(t, err)
+	rc = &canal.RowChange{}
+	err = proto.Unmarshal(entry.GetStoreValue(), rc)
+	require.Nil(t, err)
+	require.Equal(t, testCaseDDL.Query, rc.GetSql())
+}
+
+func TestColumnNameCase(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "Shop", Table: "OrderItems"},
+		Columns: []*model.Column{
+			{Name: "OrderID", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+			{Name: "ItemName", Type: mysql.TypeVarchar, Value: "Book"},
+		},
+		PreColumns: []*model.Column{
+			{Name: "OrderID", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: 1},
+			{Name: "ItemName", Type: mysql.TypeVarchar, Value: "Pen"},
+		},
+	}
+
+	testCases := []struct {
+		nameCase       string
+		applyToTables  bool
+		schema, table  string
+		columns        []string
+		expectedRowKey string
+	}{
+		{
+			nameCase:       common.NameCaseUnchanged,
+			schema:         "Shop",
+			table:          "OrderItems",
+			columns:        []string{"OrderID", "ItemName"},
+			expectedRowKey: `{"schema":"Shop","table":"OrderItems","keys":{"OrderID":"1"}}`,
+		},
+		{
+			nameCase:       common.NameCaseLower,
+			schema:         "Shop",
+			table:          "OrderItems",
+			columns:        []string{"orderid", "itemname"},
+			expectedRowKey: `{"schema":"Shop","table":"OrderItems","keys":{"orderid":"1"}}`,
+		},
+		{
+			nameCase:       common.NameCaseLower,
+			applyToTables:  true,
+			schema:         "shop",
+			table:          "orderitems",
+			columns:        []string{"orderid", "itemname"},
+			expectedRowKey: `{"schema":"shop","table":"orderitems","keys":{"orderid":"1"}}`,
+		},
+		{
+			nameCase:       common.NameCaseUpper,
+			applyToTables:  true,
+			schema:         "SHOP",
+			table:          "ORDERITEMS",
+			columns:        []string{"ORDERID", "ITEMNAME"},
+			expectedRowKey: `{"schema":"SHOP","table":"ORDERITEMS","keys":{"ORDERID":"1"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.ColumnNameCase = tc.nameCase
+		codecConfig.ApplyCaseToTableNames = tc.applyToTables
+		builder := newCanalEntryBuilder(codecConfig)
+
+		entry, err := builder.fromRowEvent(event)
+		require.Nil(t, err)
+		require.Equal(t, tc.schema, entry.GetHeader().GetSchemaName())
+		require.Equal(t, tc.table, entry.GetHeader().GetTableName())
+
+		rc := &canal.RowChange{}
+		err = proto.Unmarshal(entry.GetStoreValue(), rc)
+		require.Nil(t, err)
+		rowData := rc.GetRowDatas()[0]
+		for _, columns := range [][]*canal.Column{rowData.GetBeforeColumns(), rowData.GetAfterColumns()} {
+			require.Len(t, columns, 2)
+			for i, column := range columns {
+				require.Equal(t, tc.columns[i], column.GetName())
+			}
+			require.True(t, columns[0].GetIsKey())
+		}
+
+		key, err := builder.rowKey(event)
+		require.Nil(t, err)
+		require.Equal(t, tc.expectedRowKey, string(key))
+	}
+}
+
+func TestTableQualification(t *testing.T) {
+	t.Parallel()
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag, Value: int64(1)},
+		},
+	}
+	ddl := func(table string) *model.DDLEvent {
+		return &model.DDLEvent{
+			CommitTs:  417318403368288260,
+			Query:     "create database test",
+			Type:      mm.ActionCreateSchema,
+			TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: table}},
+		}
+	}
+
+	for _, tc := range []struct {
+		qualification string
+		table         string
+	}{
+		{common.TableQualificationSeparate, "t"},
+		{common.TableQualificationQualified, "test.t"},
+	} {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.TableQualification = tc.qualification
+		builder := newCanalEntryBuilder(codecConfig)
+		entry, err := builder.fromRowEvent(row)
+		require.NoError(t, err)
+		require.Equal(t, "test", entry.GetHeader().GetSchemaName())
+		require.Equal(t, tc.table, entry.GetHeader().GetTableName())
+
+		entry, err = builder.fromDDLEvent(ddl("t"))
+		require.NoError(t, err)
+		require.Equal(t, "test", entry.GetHeader().GetSchemaName())
+		require.Equal(t, tc.table, entry.GetHeader().GetTableName())
+		// the DDL of the schema has no table to qualify.
+		entry, err = builder.fromDDLEvent(ddl(""))
+		require.NoError(t, err)
+		require.Empty(t, entry.GetHeader().GetTableName())
+
+		codecConfig = common.NewConfig(config.ProtocolCanalJSON)
+		codecConfig.TableQualification = tc.qualification
+		encoder := NewJSONBatchEncoderBuilder(codecConfig).Build()
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", row, nil))
+		messages, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		// the message is routed by the bare names.
+		require.Equal(t, "test", *messages[0].Schema)
+		require.Equal(t, "t", *messages[0].Table)
+		var msg JSONMessage
+		require.NoError(t, json.Unmarshal(messages[0].Value, &msg))
+		require.Equal(t, "test", msg.Schema)
+		require.Equal(t, tc.table, msg.Table)
+
+		message, err := encoder.EncodeDDLEvent(ddl("t"))
+		require.NoError(t, err)
+		msg = JSONMessage{}
+		require.NoError(t, json.Unmarshal(message.Value, &msg))
+		require.Equal(t, "test", msg.Schema)
+		require.Equal(t, tc.table, msg.Table)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// appendNullable stamps whether the column is nullable into the props, which
+// is told by the flags of the column, i.e. the column is NOT NULL unless it's
+// flagged nullable, so the primary key columns are never nullable.
+func (b *canalEntryBuilder) appendNullable(column *canal.Column, c *model.Column) {
+	if !b.config.EnableNullability || !b.featureEnabled(featureNullability) {
+		return
+	}
+	column.Props = append(column.Props, &canal.Pair{
+		Key:   propNullable,
+		Value: strconv.FormatBool(c.Flag.IsNullable()),
+	})
+}
+
+// appendNotNullColumns stamps the NOT NULL columns of the table after the DDL
+// into the header props, the other columns of the table are nullable. The
+// prop is empty if all the columns are nullable, and omitted if the schema of
+// the table is unknown, e.g. the table is dropped.
+func (b *canalEntryBuilder) appendNotNullColumns(h *canal.Header, tableInfo *model.TableInfo) {
+	if !b.config.EnableNullability || !b.featureEnabled(featureNullability) ||
+		tableInfo == nil || tableInfo.TableInfo == nil || len(tableInfo.Columns) == 0 {
+		return
+	}
+	var columns []string
+	for _, col := range tableInfo.Columns {
+		if !model.IsColCDCVisible(col) {
+			continue
+		}
+		if mysql.HasNotNullFlag(col.GetFlag()) {
+			columns = append(columns, b.columnName(col.Name.O))
+		}
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propNotNullColumns,
+		Value: strings.Join(columns, ","),
+	})
+}
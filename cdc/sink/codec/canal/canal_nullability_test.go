@@ -0,0 +1,152 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNullability(t *testing.T) {
+	t.Parallel()
+
+	// the table is `t(id bigint primary key, email varchar(64) not null,
+	// nickname varchar(64), age int)`.
+	newColumn := func(id int64, name string, tp byte, flag uint) *mm.ColumnInfo {
+		col := &mm.ColumnInfo{
+			ID:        id,
+			Name:      mm.NewCIStr(name),
+			FieldType: *types.NewFieldType(tp),
+			State:     mm.StatePublic,
+		}
+		col.AddFlag(flag)
+		return col
+	}
+	tableInfo := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:         1,
+		Name:       mm.NewCIStr("t"),
+		PKIsHandle: true,
+		Columns: []*mm.ColumnInfo{
+			newColumn(1, "id", mysql.TypeLonglong, mysql.PriKeyFlag|mysql.NotNullFlag),
+			newColumn(2, "email", mysql.TypeVarchar, mysql.NotNullFlag),
+			newColumn(3, "nickname", mysql.TypeVarchar, 0),
+			newColumn(4, "age", mysql.TypeLong, 0),
+		},
+	})
+	columns := func(nickname []byte) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag | model.HandleKeyFlag, Value: int64(1)},
+			{Name: "email", Type: mysql.TypeVarchar, Value: []byte("bob@example.com")},
+			{Name: "nickname", Type: mysql.TypeVarchar, Flag: model.NullableFlag, Value: nickname},
+			{Name: "age", Type: mysql.TypeLong, Flag: model.NullableFlag, Value: nil},
+		}
+	}
+	update := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      &model.TableName{Schema: "test", Table: "t"},
+		TableInfo:  tableInfo,
+		PreColumns: columns(nil),
+		Columns:    columns([]byte("bob")),
+	}
+	expected := map[string]string{
+		"id": "false", "email": "false", "nickname": "true", "age": "true",
+	}
+
+	// nullable returns the nullable prop of the columns of the row entry,
+	// in the before and the after columns.
+	nullable := func(codecConfig *common.Config) (map[string]string, map[string]string) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(update)
+		require.NoError(t, err)
+		rc := &canal.RowChange{}
+		require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		collect := func(columns []*canal.Column) map[string]string {
+			result := make(map[string]string)
+			for _, column := range columns {
+				for _, p := range column.GetProps() {
+					if p.GetKey() == propNullable {
+						result[column.GetName()] = p.GetValue()
+					}
+				}
+			}
+			return result
+		}
+		rowData := rc.GetRowDatas()[0]
+		return collect(rowData.GetBeforeColumns()), collect(rowData.GetAfterColumns())
+	}
+	// notNullColumns returns the notNullColumns prop of the DDL entry.
+	notNullColumns := func(codecConfig *common.Config, tableInfo *model.TableInfo) (string, bool) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(&model.DDLEvent{
+			CommitTs:  417318403368288260,
+			Query:     "create table t(...)",
+			Type:      mm.ActionCreateTable,
+			TableInfo: tableInfo,
+		})
+		require.NoError(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propNotNullColumns {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableNullability = true
+	before, after := nullable(codecConfig)
+	require.Equal(t, expected, before)
+	require.Equal(t, expected, after)
+	value, ok := notNullColumns(codecConfig, tableInfo)
+	require.True(t, ok)
+	require.Equal(t, "id,email", value)
+
+	// the prop is empty if all the columns are nullable.
+	allNullable := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:      2,
+		Name:    mm.NewCIStr("t"),
+		Columns: []*mm.ColumnInfo{newColumn(1, "note", mysql.TypeVarchar, 0)},
+	})
+	value, ok = notNullColumns(codecConfig, allNullable)
+	require.True(t, ok)
+	require.Empty(t, value)
+
+	// the names are in the column name case.
+	codecConfig.ColumnNameCase = common.NameCaseUpper
+	value, _ = notNullColumns(codecConfig, tableInfo)
+	require.Equal(t, "ID,EMAIL", value)
+
+	// not emitted if disabled or not supported by the consumer.
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	before, after = nullable(codecConfig)
+	require.Empty(t, before)
+	require.Empty(t, after)
+	_, ok = notNullColumns(codecConfig, tableInfo)
+	require.False(t, ok)
+
+	codecConfig.EnableNullability = true
+	codecConfig.FeatureLevel = 2
+	before, after = nullable(codecConfig)
+	require.Empty(t, before)
+	require.Empty(t, after)
+	_, ok = notNullColumns(codecConfig, tableInfo)
+	require.False(t, ok)
+}
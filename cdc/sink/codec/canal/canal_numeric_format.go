@@ -0,0 +1,75 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+)
+
+// isNumericJavaType returns whether the values of the java type are the
+// decimal or the float numbers, whose separators are configurable.
+func isNumericJavaType(javaType internal.JavaSQLType) bool {
+	switch javaType {
+	case internal.JavaSQLTypeDECIMAL, internal.JavaSQLTypeDOUBLE, internal.JavaSQLTypeREAL:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatNumeric formats the dot-separated number value with the decimal
+// separator and the grouping separator, the integer digits are grouped by 3
+// from the right if the grouping separator is not empty, and the sign is
+// kept. The value which is not a plain number, e.g. NaN, is returned as is.
+func formatNumeric(value string, decimalSeparator, groupingSeparator string) string {
+	sign := ""
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		sign, value = value[:1], value[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(value, ".")
+	if integer == "" || strings.Trim(integer, "0123456789") != "" ||
+		strings.Trim(fraction, "0123456789") != "" {
+		return sign + value
+	}
+
+	var sb strings.Builder
+	sb.WriteString(sign)
+	for i := 0; i < len(integer); i++ {
+		if i > 0 && groupingSeparator != "" && (len(integer)-i)%3 == 0 {
+			sb.WriteString(groupingSeparator)
+		}
+		sb.WriteByte(integer[i])
+	}
+	if hasFraction {
+		sb.WriteString(decimalSeparator)
+		sb.WriteString(fraction)
+	}
+	return sb.String()
+}
+
+// localizeNumeric formats the value of the decimal and float columns with
+// the separators configured, the other values are returned as is.
+func (b *canalEntryBuilder) localizeNumeric(value string, javaType internal.JavaSQLType) string {
+	if (b.config.DecimalSeparator == "" && b.config.GroupingSeparator == "") ||
+		!isNumericJavaType(javaType) {
+		return value
+	}
+	decimalSeparator := b.config.DecimalSeparator
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	return formatNumeric(value, decimalSeparator, b.config.GroupingSeparator)
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatNumeric(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value, decimalSeparator, groupingSeparator, expected string
+	}{
+		{"1234567.89", ",", ".", "1.234.567,89"},
+		{"-1234567.89", ",", ".", "-1.234.567,89"},
+		{"123456", ",", ".", "123.456"},
+		{"12345.6", ",", "", "12345,6"},
+		{"0.5", ",", " ", "0,5"},
+		{"-100", ".", "'", "-100"},
+		{"1000", ".", "'", "1'000"},
+		{"NaN", ",", ".", "NaN"},
+		{"+Inf", ",", ".", "+Inf"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expected,
+			formatNumeric(c.value, c.decimalSeparator, c.groupingSeparator), c.value)
+	}
+}
+
+func TestNumericSeparators(t *testing.T) {
+	t.Parallel()
+
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "price"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: int64(1234)},
+			{Name: "amount", Type: mysql.TypeNewDecimal, Value: "1234567.89"},
+			{Name: "rate", Type: mysql.TypeDouble, Value: float64(-9876543.125)},
+			{Name: "note", Type: mysql.TypeVarchar, Value: []byte("1234.5")},
+			{Name: "discount", Type: mysql.TypeNewDecimal, Value: nil},
+		},
+	}
+
+	// values returns the values of the after columns by name.
+	values := func(codecConfig *common.Config) map[string]string {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(event)
+		require.NoError(t, err)
+		rc := &canal.RowChange{}
+		require.NoError(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		result := make(map[string]string)
+		for _, c := range rc.GetRowDatas()[0].GetAfterColumns() {
+			result[c.GetName()] = c.GetValue()
+		}
+		return result
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	require.Equal(t, map[string]string{
+		"id": "1234", "amount": "1234567.89", "rate": "-9876543.125",
+		"note": "1234.5", "discount": "",
+	}, values(codecConfig))
+
+	codecConfig.DecimalSeparator = ","
+	codecConfig.GroupingSeparator = "."
+	require.Equal(t, map[string]string{
+		"id": "1234", "amount": "1.234.567,89", "rate": "-9.876.543,125",
+		"note": "1234.5", "discount": "",
+	}, values(codecConfig))
+
+	codecConfig.GroupingSeparator = ""
+	require.Equal(t, map[string]string{
+		"id": "1234", "amount": "1234567,89", "rate": "-9876543,125",
+		"note": "1234.5", "discount": "",
+	}, values(codecConfig))
+}
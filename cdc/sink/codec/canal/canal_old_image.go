@@ -0,0 +1,104 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// oldImageKeyColumns returns the name of the columns kept in the old image of
+// the row, nil means the old image is not shrunk.
+//
+// The columns of the configured key index are kept, which must be a unique
+// index on not null columns to identify the row. If the index is not found,
+// e.g. the table schema is unknown or the table does not have the index, it
+// falls back to the handle key columns.
+//
+// If only the old image of the UPDATE is shrunk, the primary key columns are
+// kept, see primaryKeyColumns. The old image of the UPDATE of the tables
+// matched by the OldImageColumns keeps the columns of the rule in addition.
+func (b *canalEntryBuilder) oldImageKeyColumns(
+	e *model.RowChangedEvent,
+) (map[string]struct{}, error) {
+	if e.IsUpdate() {
+		for i := range b.config.OldImageColumns {
+			rule := &b.config.OldImageColumns[i]
+			if !rule.MatchTable(*e.Table) {
+				continue
+			}
+			result := primaryKeyColumns(e.PreColumns)
+			for _, column := range e.PreColumns {
+				if column != nil && rule.Contains(column.Name) {
+					result[column.Name] = struct{}{}
+				}
+			}
+			return result, nil
+		}
+	}
+	if b.config.ShrinkUpdateOldImage && e.IsUpdate() {
+		return primaryKeyColumns(e.PreColumns), nil
+	}
+	if !b.config.ShrinkOldImage || len(e.PreColumns) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]struct{})
+	if name := b.config.OldImageKeyIndex; name != "" && e.TableInfo != nil {
+		tableInfo := e.TableInfo
+		for _, index := range tableInfo.Indices {
+			if !strings.EqualFold(index.Name.O, name) {
+				continue
+			}
+			if !tableInfo.IsIndexUnique(index) {
+				return nil, cerror.ErrCanalInvalidKeyIndex.GenWithStackByArgs(
+					name, e.Table.String())
+			}
+			for _, column := range index.Columns {
+				result[tableInfo.Columns[column.Offset].Name.O] = struct{}{}
+			}
+			return result, nil
+		}
+	}
+
+	for _, column := range e.PreColumns {
+		if column != nil && column.Flag.IsHandleKey() {
+			result[column.Name] = struct{}{}
+		}
+	}
+	return result, nil
+}
+
+// primaryKeyColumns returns the name of the primary key columns, so that the
+// consumer can tell whether the primary key is changed by the UPDATE. If the
+// table has no primary key, it falls back to the handle key columns.
+func primaryKeyColumns(columns []*model.Column) map[string]struct{} {
+	result := make(map[string]struct{})
+	for _, column := range columns {
+		if column != nil && column.Flag.IsPrimaryKey() {
+			result[column.Name] = struct{}{}
+		}
+	}
+	if len(result) != 0 {
+		return result
+	}
+	for _, column := range columns {
+		if column != nil && column.Flag.IsHandleKey() {
+			result[column.Name] = struct{}{}
+		}
+	}
+	return result
+}
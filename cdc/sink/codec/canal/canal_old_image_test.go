@@ -0,0 +1,273 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShrinkOldImage(t *testing.T) {
+	t.Parallel()
+
+	newColumn := func(name string, offset int, flag uint) *mm.ColumnInfo {
+		ft := types.NewFieldType(mysql.TypeVarchar)
+		ft.AddFlag(flag)
+		return &mm.ColumnInfo{
+			ID:        int64(offset + 1),
+			Name:      mm.NewCIStr(name),
+			Offset:    offset,
+			FieldType: *ft,
+			State:     mm.StatePublic,
+		}
+	}
+	newIndex := func(name string, unique bool, offset int) *mm.IndexInfo {
+		return &mm.IndexInfo{
+			Name:    mm.NewCIStr(name),
+			Unique:  unique,
+			Columns: []*mm.IndexColumn{{Name: mm.NewCIStr(name), Offset: offset}},
+			State:   mm.StatePublic,
+		}
+	}
+	tableInfo := model.WrapTableInfo(1, "cdc", 1, &mm.TableInfo{
+		Name:       mm.NewCIStr("person"),
+		PKIsHandle: true,
+		Columns: []*mm.ColumnInfo{
+			newColumn("id", 0, mysql.PriKeyFlag|mysql.NotNullFlag),
+			newColumn("email", 1, mysql.UniqueKeyFlag|mysql.NotNullFlag),
+			newColumn("phone", 2, mysql.UniqueKeyFlag),
+			newColumn("name", 3, mysql.MultipleKeyFlag),
+		},
+		Indices: []*mm.IndexInfo{
+			newIndex("uk_email", true, 1),
+			newIndex("uk_phone", true, 2),
+			newIndex("idx_name", false, 3),
+		},
+	})
+
+	columns := func(id, name string) []*model.Column {
+		return []*model.Column{
+			{
+				Name: "id", Type: mysql.TypeVarchar,
+				Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: id,
+			},
+			{Name: "email", Type: mysql.TypeVarchar, Flag: model.UniqueKeyFlag, Value: "bob@pingcap.com"},
+			{Name: "phone", Type: mysql.TypeVarchar, Flag: model.UniqueKeyFlag, Value: nil},
+			{Name: "name", Type: mysql.TypeVarchar, Flag: model.MultipleKeyFlag, Value: name},
+		}
+	}
+	update := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      &model.TableName{Schema: "cdc", Table: "person"},
+		TableInfo:  tableInfo,
+		PreColumns: columns("1", "Bob"),
+		Columns:    columns("1", "Alice"),
+	}
+	deleted := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      &model.TableName{Schema: "cdc", Table: "person"},
+		TableInfo:  tableInfo,
+		PreColumns: columns("1", "Bob"),
+	}
+
+	encode := func(keyIndex string, e *model.RowChangedEvent) (*canal.RowData, error) {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.ShrinkOldImage = true
+		codecConfig.OldImageKeyIndex = keyIndex
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		if err != nil {
+			return nil, err
+		}
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		return rc.GetRowDatas()[0], nil
+	}
+	names := func(columns []*canal.Column) []string {
+		var result []string
+		for _, column := range columns {
+			result = append(result, column.GetName())
+		}
+		return result
+	}
+
+	// the handle key is kept by default.
+	rowData, err := encode("", deleted)
+	require.Nil(t, err)
+	require.Equal(t, []string{"id"}, names(rowData.GetBeforeColumns()))
+
+	// the unique secondary index is kept as the key,
+	// and the new image of the update is not shrunk.
+	for _, keyIndex := range []string{"uk_email", "UK_EMAIL"} {
+		rowData, err = encode(keyIndex, update)
+		require.Nil(t, err)
+		require.Equal(t, []string{"email"}, names(rowData.GetBeforeColumns()))
+		require.Equal(t, []string{"id", "email", "phone", "name"}, names(rowData.GetAfterColumns()))
+	}
+
+	// fall back to the handle key if the index is absent.
+	rowData, err = encode("uk_absent", deleted)
+	require.Nil(t, err)
+	require.Equal(t, []string{"id"}, names(rowData.GetBeforeColumns()))
+	deletedWithoutSchema := *deleted
+	deletedWithoutSchema.TableInfo = nil
+	rowData, err = encode("uk_email", &deletedWithoutSchema)
+	require.Nil(t, err)
+	require.Equal(t, []string{"id"}, names(rowData.GetBeforeColumns()))
+
+	// the index must be unique on not null columns.
+	for _, keyIndex := range []string{"uk_phone", "idx_name"} {
+		_, err = encode(keyIndex, deleted)
+		require.True(t, cerror.ErrCanalInvalidKeyIndex.Equal(err))
+	}
+}
+
+func TestShrinkUpdateOldImage(t *testing.T) {
+	t.Parallel()
+
+	keyFlag := model.HandleKeyFlag | model.PrimaryKeyFlag
+	columns := func(name string, idFlag model.ColumnFlagType) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: idFlag, Value: 1},
+			{Name: "region", Type: mysql.TypeVarchar, Flag: idFlag, Value: "us"},
+			{Name: "email", Type: mysql.TypeVarchar, Flag: model.UniqueKeyFlag, Value: "bob@pingcap.com"},
+			{Name: "name", Type: mysql.TypeVarchar, Value: name},
+		}
+	}
+	table := &model.TableName{Schema: "cdc", Table: "person"}
+	update := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      table,
+		PreColumns: columns("Bob", keyFlag),
+		Columns:    columns("Alice", keyFlag),
+	}
+	deleted := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      table,
+		PreColumns: columns("Bob", keyFlag),
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.ShrinkUpdateOldImage = true
+	encode := func(e *model.RowChangedEvent) *canal.RowData {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		return rc.GetRowDatas()[0]
+	}
+	names := func(columns []*canal.Column) []string {
+		var result []string
+		for _, column := range columns {
+			result = append(result, column.GetName())
+		}
+		return result
+	}
+
+	// the old image of the UPDATE carries exactly the primary key columns,
+	// while the new image is complete.
+	rowData := encode(update)
+	require.Equal(t, []string{"id", "region"}, names(rowData.GetBeforeColumns()))
+	require.Equal(t, []string{"id", "region", "email", "name"}, names(rowData.GetAfterColumns()))
+
+	// the old image of the DELETE is not shrunk.
+	rowData = encode(deleted)
+	require.Equal(t, []string{"id", "region", "email", "name"}, names(rowData.GetBeforeColumns()))
+
+	// fall back to the handle key if the table has no primary key.
+	withoutPK := &model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      table,
+		PreColumns: columns("Bob", model.HandleKeyFlag|model.UniqueKeyFlag),
+		Columns:    columns("Alice", model.HandleKeyFlag|model.UniqueKeyFlag),
+	}
+	rowData = encode(withoutPK)
+	require.Equal(t, []string{"id", "region"}, names(rowData.GetBeforeColumns()))
+}
+
+func TestOldImageColumns(t *testing.T) {
+	t.Parallel()
+
+	columns := func(price int64, status string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "book"},
+			{Name: "price", Type: mysql.TypeLong, Value: price},
+			{Name: "status", Type: mysql.TypeVarchar, Value: status},
+		}
+	}
+	orders := &model.TableName{Schema: "shop", Table: "orders"}
+	update := func(table *model.TableName) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs:   417318403368288260,
+			Table:      table,
+			PreColumns: columns(10, "paid"),
+			Columns:    columns(12, "shipped"),
+		}
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.OldImageColumns = []common.OldImageColumnsRule{
+		// the table patterns and the column names are case-insensitive.
+		{TableMatcher: common.MustNewTableMatcher("Shop.Orders"), Columns: []string{"Price", "status"}},
+		{TableMatcher: common.MustNewTableMatcher("shop.*"), Columns: []string{"STATUS"}},
+	}
+	encode := func(e *model.RowChangedEvent) *canal.RowData {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		return rc.GetRowDatas()[0]
+	}
+	values := func(columns []*canal.Column) map[string]string {
+		result := make(map[string]string)
+		for _, column := range columns {
+			result[column.GetName()] = column.GetValue()
+		}
+		return result
+	}
+
+	// the old image carries the primary key and the audit columns only,
+	// while the new image is complete.
+	rowData := encode(update(orders))
+	require.Equal(t, map[string]string{"id": "1", "price": "10", "status": "paid"},
+		values(rowData.GetBeforeColumns()))
+	require.Equal(t, map[string]string{"id": "1", "name": "book", "price": "12", "status": "shipped"},
+		values(rowData.GetAfterColumns()))
+
+	// the first rule matching the table applies.
+	rowData = encode(update(&model.TableName{Schema: "shop", Table: "items"}))
+	require.Equal(t, map[string]string{"id": "1", "status": "paid"}, values(rowData.GetBeforeColumns()))
+
+	// the tables matched by none are not affected.
+	rowData = encode(update(&model.TableName{Schema: "other", Table: "orders"}))
+	require.Len(t, rowData.GetBeforeColumns(), 4)
+
+	// the old image of the DELETE is not affected.
+	rowData = encode(&model.RowChangedEvent{
+		CommitTs:   417318403368288260,
+		Table:      orders,
+		PreColumns: columns(10, "paid"),
+	})
+	require.Len(t, rowData.GetBeforeColumns(), 4)
+}
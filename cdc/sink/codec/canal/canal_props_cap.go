@@ -0,0 +1,148 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"sort"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// defaultPropsPriority is the priority of the header props from the highest
+// to the lowest one. The props the consumer can not decode or order the
+// entries without come first, then the ones verifying the entries, and the
+// descriptive metadata of the tables comes last, since it can be recovered
+// from the schema. The props not listed are of the lowest priority.
+var defaultPropsPriority = []string{
+	propRowsCount,
+	propDDLCompression,
+	propDDLCompressionDict,
+	propBatchEnd,
+	propWatermarkTs,
+	propSequence,
+	propTxnRowCount,
+	propGTID,
+	propMessageID,
+	propChecksumAlgorithm,
+	propChecksum,
+	propUpstreamChecksumAlgorithm,
+	propUpstreamChecksum,
+	// the original names can not be recovered from the safe identifiers.
+	propOriginalSchema,
+	propOriginalTable,
+	propSchemaVersion,
+	propSchemaFingerprint,
+	propSchemaURL,
+	propPartitionCount,
+	propConsistencyLevel,
+	propFirstSeen,
+	propWindowID,
+	propChangedColumns,
+	propAffectsData,
+	propSchemaCompatibility,
+	propBreakingChanges,
+	propHandleType,
+	propOnUpdateColumns,
+	propAutoIncrementColumns,
+	propAutoRandomColumns,
+	propNotNullColumns,
+	propSQLDigest,
+	propTimezone,
+	propRowSize,
+}
+
+// propsCapSerializer caps the number of the header props of the entries
+// before they are serialized by the wrapped Serializer, the props of the
+// columns are not counted.
+type propsCapSerializer struct {
+	Serializer
+	maxProps int
+	reject   bool
+	// ranks maps the keys of the props to their priorities, the lower the
+	// higher priority, the props not listed are ranked len(ranks).
+	ranks map[string]int
+}
+
+// newPropsCapSerializer wraps the serializer to cap the props by the config,
+// the serializer is returned as is if the props are not capped.
+func newPropsCapSerializer(s Serializer, config *common.Config) Serializer {
+	if config.MaxProps <= 0 {
+		return s
+	}
+	priority := config.PropsPriority
+	if len(priority) == 0 {
+		priority = defaultPropsPriority
+	}
+	ranks := make(map[string]int, len(priority))
+	for _, key := range priority {
+		if _, ok := ranks[key]; !ok {
+			ranks[key] = len(ranks)
+		}
+	}
+	return &propsCapSerializer{
+		Serializer: s,
+		maxProps:   config.MaxProps,
+		reject:     config.PropsOverflow == common.PropsOverflowReject,
+		ranks:      ranks,
+	}
+}
+
+// Serialize implements the Serializer interface, the props of the entry
+// exceeding the max props are dropped in place, or the entry is rejected
+// with ErrCanalTooManyProps.
+func (s *propsCapSerializer) Serialize(entry *Entry) ([]byte, error) {
+	h := entry.Header
+	if len(h.GetProps()) > s.maxProps {
+		if s.reject {
+			table := model.TableName{Schema: h.GetSchemaName(), Table: h.GetTableName()}
+			return nil, cerror.ErrCanalTooManyProps.GenWithStackByArgs(
+				table.String(), len(h.GetProps()), s.maxProps)
+		}
+		h.Props = s.capProps(h.GetProps())
+	}
+	return s.Serializer.Serialize(entry)
+}
+
+// capProps keeps the max props of the highest priorities in their order. The
+// props of the same priority are dropped from the last appended one.
+func (s *propsCapSerializer) capProps(props []*canal.Pair) []*canal.Pair {
+	lowest := len(s.ranks)
+	rank := func(p *canal.Pair) int {
+		if r, ok := s.ranks[p.GetKey()]; ok {
+			return r
+		}
+		return lowest
+	}
+	indexes := make([]int, len(props))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return rank(props[indexes[i]]) < rank(props[indexes[j]])
+	})
+	kept := make([]bool, len(props))
+	for _, i := range indexes[:s.maxProps] {
+		kept[i] = true
+	}
+	result := make([]*canal.Pair, 0, s.maxProps)
+	for i, p := range props {
+		if kept[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropsCap(t *testing.T) {
+	t.Parallel()
+
+	newEntry := func() *Entry {
+		header := &canal.Header{SchemaName: "test", TableName: "t"}
+		for _, key := range []string{
+			propSQLDigest, propRowsCount, "custom", propRowSize,
+			propChecksumAlgorithm, propChecksum, propNotNullColumns,
+		} {
+			header.Props = append(header.Props, &canal.Pair{Key: key, Value: "v"})
+		}
+		return &Entry{Header: header, EntryType: canal.EntryType_ROWDATA}
+	}
+	// serialize returns the keys of the props of the serialized entry.
+	serialize := func(codecConfig *common.Config) ([]string, error) {
+		b, err := newPropsCapSerializer(protoSerializer{}, codecConfig).Serialize(newEntry())
+		if err != nil {
+			return nil, err
+		}
+		entry := &canal.Entry{}
+		require.NoError(t, proto.Unmarshal(b, entry))
+		var keys []string
+		for _, p := range entry.GetHeader().GetProps() {
+			keys = append(keys, p.GetKey())
+		}
+		return keys, nil
+	}
+
+	// not capped by default.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	keys, err := serialize(codecConfig)
+	require.NoError(t, err)
+	require.Len(t, keys, 7)
+
+	// the props of the lowest priorities are dropped, the unknown one first,
+	// and the kept ones are in their order.
+	codecConfig.MaxProps = 4
+	keys, err = serialize(codecConfig)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		propRowsCount, propChecksumAlgorithm, propChecksum, propNotNullColumns,
+	}, keys)
+
+	codecConfig.MaxProps = 2
+	keys, err = serialize(codecConfig)
+	require.NoError(t, err)
+	require.Equal(t, []string{propRowsCount, propChecksumAlgorithm}, keys)
+
+	// not capped if within the max props.
+	codecConfig.MaxProps = 7
+	keys, err = serialize(codecConfig)
+	require.NoError(t, err)
+	require.Len(t, keys, 7)
+
+	// by the configured priority, the props not listed are dropped from the
+	// last appended one.
+	codecConfig.MaxProps = 3
+	codecConfig.PropsPriority = []string{"custom", propSQLDigest}
+	keys, err = serialize(codecConfig)
+	require.NoError(t, err)
+	require.Equal(t, []string{propSQLDigest, propRowsCount, "custom"}, keys)
+
+	// rejected.
+	codecConfig.PropsOverflow = common.PropsOverflowReject
+	_, err = serialize(codecConfig)
+	require.True(t, errors.Is(err, cerror.ErrCanalTooManyProps), err.Error())
+	require.ErrorContains(t, err, "the entry of table test.t carries 7 props, more than the max props 3")
+}
+
+func TestPropsCapReject(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableSQLDigest = true
+	codecConfig.MaxProps = 1
+	codecConfig.PropsOverflow = common.PropsOverflowReject
+	encoder := newBatchEncoder(codecConfig)
+	err := encoder.AppendRowChangedEvent(context.Background(), "", &model.RowChangedEvent{
+		CommitTs:  1,
+		Table:     &model.TableName{Schema: "test", Table: "t"},
+		SQLDigest: "digest",
+		Columns: []*model.Column{{
+			Name:  "a",
+			Type:  mysql.TypeLong,
+			Value: int64(1),
+		}},
+	}, nil)
+	requireEncodeErrorClass(t, err, cerror.ErrCanalMarshalFailed)
+	require.True(t, errors.Is(err, cerror.ErrCanalTooManyProps), err.Error())
+}
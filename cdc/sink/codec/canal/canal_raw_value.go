@@ -0,0 +1,191 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	"github.com/pingcap/tiflow/cdc/model"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// columnFieldTypes returns the field type of the columns by name,
+// nil if the schema of the table is unknown.
+func columnFieldTypes(e *model.RowChangedEvent) map[string]*types.FieldType {
+	if e.TableInfo == nil || e.TableInfo.TableInfo == nil {
+		return nil
+	}
+	result := make(map[string]*types.FieldType, len(e.TableInfo.Columns))
+	for _, col := range e.TableInfo.Columns {
+		result[col.Name.O] = &col.FieldType
+	}
+	return result
+}
+
+// appendRawValue stamps the raw storage value of the column into the props,
+// it's the base64 encoded value in the TiDB row format, i.e. the bytes stored
+// in TiKV. It's omitted for the null values and the unsupported types.
+func (b *canalEntryBuilder) appendRawValue(
+	column *canal.Column, c *model.Column, ft *types.FieldType,
+) error {
+	if !b.config.EnableRawStorageValue || c.Value == nil ||
+		!b.featureEnabled(featureRawStorageValue) {
+		return nil
+	}
+	raw, ok, err := rawStorageValue(c, ft)
+	if err != nil {
+		return cerror.WrapError(cerror.ErrCanalEncodeFailed, err)
+	}
+	if !ok {
+		return nil
+	}
+	column.Props = append(column.Props, &canal.Pair{
+		Key:   propRawValue,
+		Value: base64.StdEncoding.EncodeToString(raw),
+	})
+	return nil
+}
+
+// rawStorageValue encodes the value of the column in the TiDB row format,
+// and returns false if the type is not supported. The timestamp is not
+// supported, since it's stored in UTC but the value is in the time zone of
+// the changefeed. The field type is used for the precision and the fraction
+// of the decimal, the ones of the value are used if it's nil.
+func rawStorageValue(c *model.Column, ft *types.FieldType) ([]byte, bool, error) {
+	switch c.Type {
+	case mysql.TypeTimestamp:
+		return nil, false, nil
+	case mysql.TypeNewDecimal:
+		s, ok := c.Value.(string)
+		if !ok {
+			return nil, false, nil
+		}
+		dec := new(types.MyDecimal)
+		if err := dec.FromString([]byte(s)); err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		precision, frac := dec.PrecisionAndFrac()
+		if ft != nil && ft.GetFlen() > 0 {
+			precision, frac = ft.GetFlen(), ft.GetDecimal()
+		}
+		raw, err := codec.EncodeDecimal(nil, dec, precision, frac)
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		return raw, true, nil
+	case mysql.TypeDate, mysql.TypeNewDate, mysql.TypeDatetime:
+		s, ok := c.Value.(string)
+		if !ok {
+			return nil, false, nil
+		}
+		t, err := types.ParseTime(rawValueStmtCtx(), s, c.Type, types.MaxFsp)
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		packed, err := t.ToPackedUint()
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		return encodeRawUint(nil, packed), true, nil
+	case mysql.TypeDuration:
+		s, ok := c.Value.(string)
+		if !ok {
+			return nil, false, nil
+		}
+		d, _, err := types.ParseDuration(rawValueStmtCtx(), s, types.MaxFsp)
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		return encodeRawInt(nil, int64(d.Duration)), true, nil
+	case mysql.TypeJSON:
+		s, ok := c.Value.(string)
+		if !ok {
+			return nil, false, nil
+		}
+		j, err := types.ParseBinaryJSONFromString(s)
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		return append([]byte{j.TypeCode}, j.Value...), true, nil
+	}
+
+	switch v := c.Value.(type) {
+	case int64:
+		return encodeRawInt(nil, v), true, nil
+	case int:
+		return encodeRawInt(nil, int64(v)), true, nil
+	case uint64:
+		// the unsigned integers, the enum, the set and the bit.
+		return encodeRawUint(nil, v), true, nil
+	case float32:
+		return codec.EncodeFloat(nil, float64(v)), true, nil
+	case float64:
+		return codec.EncodeFloat(nil, v), true, nil
+	case []byte:
+		return v, true, nil
+	case string:
+		return []byte(v), true, nil
+	}
+	return nil, false, nil
+}
+
+func rawValueStmtCtx() *stmtctx.StatementContext {
+	return &stmtctx.StatementContext{TimeZone: time.UTC}
+}
+
+// encodeRawInt encodes the integer in the shortest little endian form,
+// which is the same as the TiDB row format.
+func encodeRawInt(buf []byte, v int64) []byte {
+	var tmp [8]byte
+	switch {
+	case int64(int8(v)) == v:
+		return append(buf, byte(v))
+	case int64(int16(v)) == v:
+		binary.LittleEndian.PutUint16(tmp[:], uint16(v))
+		return append(buf, tmp[:2]...)
+	case int64(int32(v)) == v:
+		binary.LittleEndian.PutUint32(tmp[:], uint32(v))
+		return append(buf, tmp[:4]...)
+	default:
+		binary.LittleEndian.PutUint64(tmp[:], uint64(v))
+		return append(buf, tmp[:8]...)
+	}
+}
+
+// encodeRawUint encodes the unsigned integer in the shortest little endian
+// form, which is the same as the TiDB row format.
+func encodeRawUint(buf []byte, v uint64) []byte {
+	var tmp [8]byte
+	switch {
+	case uint64(uint8(v)) == v:
+		return append(buf, byte(v))
+	case uint64(uint16(v)) == v:
+		binary.LittleEndian.PutUint16(tmp[:], uint16(v))
+		return append(buf, tmp[:2]...)
+	case uint64(uint32(v)) == v:
+		binary.LittleEndian.PutUint32(tmp[:], uint32(v))
+		return append(buf, tmp[:4]...)
+	default:
+		binary.LittleEndian.PutUint64(tmp[:], v)
+		return append(buf, tmp[:8]...)
+	}
+}
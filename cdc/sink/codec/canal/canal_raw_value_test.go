@@ -0,0 +1,124 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawStorageValue(t *testing.T) {
+	t.Parallel()
+
+	newColumn := func(name string, tp byte, flen, decimal int) *mm.ColumnInfo {
+		ft := types.NewFieldType(tp)
+		ft.SetFlen(flen)
+		ft.SetDecimal(decimal)
+		return &mm.ColumnInfo{
+			Name:      mm.NewCIStr(name),
+			FieldType: *ft,
+			State:     mm.StatePublic,
+		}
+	}
+	event := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "account"},
+		TableInfo: model.WrapTableInfo(1, "cdc", 1, &mm.TableInfo{
+			Name: mm.NewCIStr("account"),
+			Columns: []*mm.ColumnInfo{
+				newColumn("id", mysql.TypeLong, 11, 0),
+				newColumn("balance", mysql.TypeNewDecimal, 10, 2),
+				newColumn("updated_at", mysql.TypeDatetime, 0, 3),
+				newColumn("note", mysql.TypeVarchar, 64, 0),
+			},
+		}),
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: int64(300)},
+			{Name: "balance", Type: mysql.TypeNewDecimal, Value: "123.45"},
+			{Name: "updated_at", Type: mysql.TypeDatetime, Value: "2022-10-14 12:34:56.789"},
+			{Name: "note", Type: mysql.TypeVarchar, Value: nil},
+		},
+	}
+	encode := func(enabled bool) map[string]*canal.Column {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.EnableRawStorageValue = enabled
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(event)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		result := make(map[string]*canal.Column)
+		for _, column := range rc.GetRowDatas()[0].GetAfterColumns() {
+			result[column.GetName()] = column
+		}
+		return result
+	}
+	rawValue := func(column *canal.Column) []byte {
+		for _, p := range column.GetProps() {
+			if p.GetKey() == propRawValue {
+				raw, err := base64.StdEncoding.DecodeString(p.GetValue())
+				require.Nil(t, err)
+				return raw
+			}
+		}
+		return nil
+	}
+
+	// the raw value is off by default.
+	for _, column := range encode(false) {
+		require.Nil(t, rawValue(column))
+	}
+
+	columns := encode(true)
+	// the integer is in the shortest little endian form.
+	require.Equal(t, []byte{0x2c, 0x01}, rawValue(columns["id"]))
+
+	// the decimal is in the binary form with the precision and the fraction
+	// of the column, which decodes to the same value as the string.
+	balance := columns["balance"]
+	require.Equal(t, "123.45", balance.GetValue())
+	raw := rawValue(balance)
+	expected, err := codec.EncodeDecimal(nil, types.NewDecFromStringForTest("123.45"), 10, 2)
+	require.Nil(t, err)
+	require.Equal(t, expected, raw)
+	_, dec, precision, frac, err := codec.DecodeDecimal(raw)
+	require.Nil(t, err)
+	require.Equal(t, 10, precision)
+	require.Equal(t, 2, frac)
+	require.Equal(t, balance.GetValue(), dec.String())
+
+	// the datetime is the packed uint, which decodes to the same value as the string.
+	updatedAt := columns["updated_at"]
+	raw = rawValue(updatedAt)
+	require.Len(t, raw, 8)
+	var tm types.Time
+	require.Nil(t, tm.FromPackedUint(binary.LittleEndian.Uint64(raw)))
+	tm.SetType(mysql.TypeDatetime)
+	tm.SetFsp(3)
+	require.Equal(t, updatedAt.GetValue(), tm.String())
+
+	// the raw value is omitted for the null.
+	require.Nil(t, rawValue(columns["note"]))
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// the kinds of the changes breaking the compatibility of the schema, each
+// change is emitted as `kind:column`.
+const (
+	schemaChangeDropped  = "dropped"
+	schemaChangeRenamed  = "renamed"
+	schemaChangeNarrowed = "narrowed"
+	schemaChangeNotNull  = "not-null"
+)
+
+// schemaChanges returns the changes of the schema of the table by the DDL
+// breaking the backward compatibility, i.e. the consumer reading the rows by
+// the old schema fails to read the new ones, or the other way around. The
+// columns are matched by the id, and the changes are:
+//
+//   - dropped, the column is dropped;
+//   - renamed, the column is renamed;
+//   - narrowed, the type of the column can not hold all the values of the old
+//     one, see widened;
+//   - not-null, the column becomes NOT NULL, or it's added as NOT NULL
+//     without the default value.
+//
+// The other changes are compatible, e.g. adding the nullable columns and
+// widening the types. It returns false if the schema before or after the DDL
+// is unknown, e.g. the table is created or dropped.
+func schemaChanges(e *model.DDLEvent) ([]string, bool) {
+	if e.PreTableInfo == nil || e.PreTableInfo.TableInfo == nil ||
+		e.TableInfo == nil || e.TableInfo.TableInfo == nil {
+		return nil, false
+	}
+	columns := make(map[int64]*mm.ColumnInfo, len(e.TableInfo.Columns))
+	for _, col := range e.TableInfo.Columns {
+		if model.IsColCDCVisible(col) {
+			columns[col.ID] = col
+		}
+	}
+	var result []string
+	seen := make(map[int64]struct{}, len(e.PreTableInfo.Columns))
+	for _, old := range e.PreTableInfo.Columns {
+		if !model.IsColCDCVisible(old) {
+			continue
+		}
+		seen[old.ID] = struct{}{}
+		col, ok := columns[old.ID]
+		if !ok {
+			result = append(result, schemaChangeDropped+":"+old.Name.O)
+			continue
+		}
+		if col.Name.L != old.Name.L {
+			result = append(result, schemaChangeRenamed+":"+old.Name.O)
+		}
+		if !widened(&old.FieldType, &col.FieldType) {
+			result = append(result, schemaChangeNarrowed+":"+col.Name.O)
+		}
+		if !mysql.HasNotNullFlag(old.GetFlag()) && mysql.HasNotNullFlag(col.GetFlag()) {
+			result = append(result, schemaChangeNotNull+":"+col.Name.O)
+		}
+	}
+	for _, col := range e.TableInfo.Columns {
+		if _, ok := seen[col.ID]; ok || !model.IsColCDCVisible(col) {
+			continue
+		}
+		// the old rows read by the new schema take the default value.
+		if mysql.HasNotNullFlag(col.GetFlag()) &&
+			col.GetOriginDefaultValue() == nil && col.GetDefaultValue() == nil {
+			result = append(result, schemaChangeNotNull+":"+col.Name.O)
+		}
+	}
+	return result, true
+}
+
+// the ranks of the types of each family, the type of a higher rank holds all
+// the values of the lower ones.
+var (
+	integerRanks = map[byte]int{
+		mysql.TypeTiny: 1, mysql.TypeShort: 2, mysql.TypeInt24: 3,
+		mysql.TypeLong: 4, mysql.TypeLonglong: 5,
+	}
+	floatRanks = map[byte]int{mysql.TypeFloat: 1, mysql.TypeDouble: 2}
+	blobRanks  = map[byte]int{
+		mysql.TypeTinyBlob: 1, mysql.TypeBlob: 2,
+		mysql.TypeMediumBlob: 3, mysql.TypeLongBlob: 4,
+	}
+	stringTypes = map[byte]struct{}{
+		mysql.TypeVarchar: {}, mysql.TypeVarString: {}, mysql.TypeString: {},
+	}
+)
+
+// widened returns whether the new type holds all the values of the old one,
+// i.e. it's the same type or widened within the family of the type:
+//
+//   - the integers of the same signedness, e.g. INT to BIGINT;
+//   - FLOAT to DOUBLE;
+//   - the DECIMAL with no less integral and fractional digits;
+//   - the CHAR and VARCHAR of no less length;
+//   - the BLOB and TEXT of no less length, e.g. TEXT to MEDIUMTEXT;
+//   - the ENUM and SET with the members appended;
+//   - the other types of no less length and precision, e.g. DATETIME(3)
+//     to DATETIME(6).
+func widened(old, new *types.FieldType) bool {
+	oldTp, newTp := old.GetType(), new.GetType()
+	if rank, ok := integerRanks[oldTp]; ok {
+		return integerRanks[newTp] >= rank &&
+			mysql.HasUnsignedFlag(old.GetFlag()) == mysql.HasUnsignedFlag(new.GetFlag())
+	}
+	if rank, ok := floatRanks[oldTp]; ok {
+		return floatRanks[newTp] >= rank
+	}
+	if rank, ok := blobRanks[oldTp]; ok {
+		return blobRanks[newTp] >= rank
+	}
+	if _, ok := stringTypes[oldTp]; ok {
+		_, ok := stringTypes[newTp]
+		return ok && new.GetFlen() >= old.GetFlen()
+	}
+	if oldTp != newTp {
+		return false
+	}
+	switch oldTp {
+	case mysql.TypeNewDecimal:
+		return new.GetFlen()-new.GetDecimal() >= old.GetFlen()-old.GetDecimal() &&
+			new.GetDecimal() >= old.GetDecimal()
+	case mysql.TypeEnum, mysql.TypeSet:
+		// the values are the ordinals of the members.
+		oldElems, newElems := old.GetElems(), new.GetElems()
+		if len(newElems) < len(oldElems) {
+			return false
+		}
+		for i := range oldElems {
+			if oldElems[i] != newElems[i] {
+				return false
+			}
+		}
+		return true
+	default:
+		return new.GetFlen() >= old.GetFlen() && new.GetDecimal() >= old.GetDecimal()
+	}
+}
+
+// appendSchemaCompatibility checks the compatibility of the schema change by
+// the DDL if it's enabled, see schemaChanges. The DDL changing the schema
+// incompatibly is flagged by the props, or fails the encoding, by the
+// SchemaCompatibility. The props are omitted if the schema before or after
+// the DDL is unknown.
+func (b *canalEntryBuilder) appendSchemaCompatibility(h *canal.Header, e *model.DDLEvent) error {
+	switch b.config.SchemaCompatibility {
+	case common.SchemaCompatibilityFlag, common.SchemaCompatibilityError:
+	default:
+		return nil
+	}
+	changes, ok := schemaChanges(e)
+	if !ok {
+		return nil
+	}
+	if b.config.SchemaCompatibility == common.SchemaCompatibilityError {
+		if len(changes) == 0 {
+			return nil
+		}
+		return cerror.ErrCanalBreakingSchemaChange.GenWithStackByArgs(
+			e.TableInfo.TableName.String(), strings.Join(changes, ","))
+	}
+	if !b.featureEnabled(featureSchemaCompatibility) {
+		return nil
+	}
+	if len(changes) == 0 {
+		h.Props = append(h.Props, &canal.Pair{Key: propSchemaCompatibility, Value: "compatible"})
+		return nil
+	}
+	h.Props = append(h.Props,
+		&canal.Pair{Key: propSchemaCompatibility, Value: "breaking"},
+		&canal.Pair{Key: propBreakingChanges, Value: strings.Join(changes, ",")},
+	)
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strings"
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaCompatibility(t *testing.T) {
+	t.Parallel()
+
+	newColumn := func(id int64, name string, tp byte, flen int, flag uint) *mm.ColumnInfo {
+		col := &mm.ColumnInfo{
+			ID:        id,
+			Name:      mm.NewCIStr(name),
+			Offset:    int(id - 1),
+			FieldType: *types.NewFieldType(tp),
+			State:     mm.StatePublic,
+		}
+		col.SetFlen(flen)
+		col.AddFlag(flag)
+		return col
+	}
+	newTable := func(columns ...*mm.ColumnInfo) *model.TableInfo {
+		return model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+			ID:      1,
+			Name:    mm.NewCIStr("t"),
+			Columns: columns,
+		})
+	}
+	// `t(id int not null, name varchar(32))`.
+	pre := newTable(
+		newColumn(1, "id", mysql.TypeLong, 11, mysql.NotNullFlag),
+		newColumn(2, "name", mysql.TypeVarchar, 32, 0),
+	)
+	withDefault := newColumn(3, "age", mysql.TypeLong, 11, mysql.NotNullFlag)
+	require.NoError(t, withDefault.SetOriginDefaultValue("0"))
+
+	for _, tc := range []struct {
+		name     string
+		post     *model.TableInfo
+		expected []string
+	}{
+		{"add nullable column", newTable(pre.Columns[0], pre.Columns[1],
+			newColumn(3, "age", mysql.TypeLong, 11, 0)), nil},
+		{"add not null column with default", newTable(pre.Columns[0], pre.Columns[1],
+			withDefault), nil},
+		{"widen", newTable(
+			newColumn(1, "id", mysql.TypeLonglong, 20, mysql.NotNullFlag),
+			newColumn(2, "name", mysql.TypeVarchar, 64, 0)), nil},
+		{"add not null column", newTable(pre.Columns[0], pre.Columns[1],
+			newColumn(3, "age", mysql.TypeLong, 11, mysql.NotNullFlag)), []string{"not-null:age"}},
+		{"drop column", newTable(pre.Columns[0]), []string{"dropped:name"}},
+		{"rename column", newTable(pre.Columns[0],
+			newColumn(2, "title", mysql.TypeVarchar, 32, 0)), []string{"renamed:name"}},
+		{"narrow", newTable(
+			newColumn(1, "id", mysql.TypeShort, 6, mysql.NotNullFlag),
+			newColumn(2, "name", mysql.TypeVarchar, 16, mysql.NotNullFlag)),
+			[]string{"narrowed:id", "narrowed:name", "not-null:name"}},
+		{"change signedness", newTable(
+			newColumn(1, "id", mysql.TypeLong, 11, mysql.NotNullFlag|mysql.UnsignedFlag),
+			pre.Columns[1]), []string{"narrowed:id"}},
+	} {
+		ddl := &model.DDLEvent{
+			CommitTs:     417318403368288260,
+			Query:        "alter table t ...",
+			Type:         mm.ActionModifyColumn,
+			PreTableInfo: pre,
+			TableInfo:    tc.post,
+		}
+		changes, ok := schemaChanges(ddl)
+		require.True(t, ok, tc.name)
+		require.Equal(t, tc.expected, changes, tc.name)
+
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.SchemaCompatibility = common.SchemaCompatibilityFlag
+		entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+		require.NoError(t, err, tc.name)
+		props := entry.GetHeader().GetProps()
+		if len(tc.expected) == 0 {
+			require.Contains(t, props, &canal.Pair{Key: propSchemaCompatibility, Value: "compatible"})
+			for _, p := range props {
+				require.NotEqual(t, propBreakingChanges, p.GetKey(), tc.name)
+			}
+		} else {
+			require.Contains(t, props, &canal.Pair{Key: propSchemaCompatibility, Value: "breaking"})
+			require.Contains(t, props, &canal.Pair{Key: propBreakingChanges, Value: strings.Join(tc.expected, ",")})
+		}
+
+		codecConfig.SchemaCompatibility = common.SchemaCompatibilityError
+		_, err = newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+		if len(tc.expected) == 0 {
+			require.NoError(t, err, tc.name)
+		} else {
+			require.True(t, cerror.ErrCanalBreakingSchemaChange.Equal(err), tc.name)
+		}
+	}
+
+	// omitted if the schema before the DDL is unknown, or disabled, or not
+	// supported by the consumer.
+	ddl := &model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     "create table t(...)",
+		Type:      mm.ActionCreateTable,
+		TableInfo: pre,
+	}
+	hasCompatibility := func(codecConfig *common.Config, ddl *model.DDLEvent) bool {
+		entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+		require.NoError(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propSchemaCompatibility {
+				return true
+			}
+		}
+		return false
+	}
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.SchemaCompatibility = common.SchemaCompatibilityFlag
+	require.False(t, hasCompatibility(codecConfig, ddl))
+
+	ddl = &model.DDLEvent{
+		CommitTs:     417318403368288260,
+		Query:        "alter table t drop column name",
+		Type:         mm.ActionDropColumn,
+		PreTableInfo: pre,
+		TableInfo:    newTable(pre.Columns[0]),
+	}
+	require.True(t, hasCompatibility(codecConfig, ddl))
+	codecConfig.FeatureLevel = 2
+	require.False(t, hasCompatibility(codecConfig, ddl))
+	codecConfig = common.NewConfig(config.ProtocolCanal)
+	require.False(t, hasCompatibility(codecConfig, ddl))
+}
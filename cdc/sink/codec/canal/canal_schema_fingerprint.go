@@ -0,0 +1,97 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strings"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// schemaFingerprint returns the fingerprint of the column names and types of
+// the row, which is the 64-bit FNV-1a hash in 16 lower case hex digits of
+//
+//	name_1 \x00 type_1 \x00 name_2 \x00 type_2 \x00 ... name_n \x00 type_n \x00
+//
+// over the columns of the post-image, or the pre-image for the delete, in the
+// order of the row. The name is the upstream name of the column, and the type
+// is the type declared in the schema, e.g. `varchar(255)`, or the mysqlType
+// of the column, e.g. `varchar`, if the schema of the table is unknown. So
+// the fingerprint is the same for the rows of a schema, and changes if a
+// column is added, dropped, renamed or changes its type.
+func schemaFingerprint(e *model.RowChangedEvent) string {
+	columns := e.Columns
+	if e.IsDelete() {
+		columns = e.PreColumns
+	}
+	fieldTypes := columnFieldTypes(e)
+	h := fnv.New64a()
+	for _, c := range columns {
+		if c == nil {
+			continue
+		}
+		columnType := getMySQLType(c)
+		if ft, ok := fieldTypes[c.Name]; ok {
+			columnType = ft.InfoSchemaStr()
+		}
+		h.Write([]byte(c.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(columnType))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// schemaURL returns the URL of the schema hosted externally, which is
+//
+//	{base}/{schema}/{table}/{fingerprint}
+//
+// where the schema and the table are the names emitted, escaped as the path
+// segments, and the fingerprint is the schemaFingerprint of the row, so the
+// URL changes if the schema changes. The trailing slashes of the base are
+// trimmed, e.g. the URL of the rows of `test`.`person` with the base
+// `https://schemas.example.com/cdc/` is
+// `https://schemas.example.com/cdc/test/person/{fingerprint}`.
+func schemaURL(base, schema, table, fingerprint string) string {
+	return strings.TrimRight(base, "/") + "/" + url.PathEscape(schema) +
+		"/" + url.PathEscape(table) + "/" + fingerprint
+}
+
+// appendSchemaFingerprint stamps the fingerprint of the schema of the row,
+// and the URL of the schema derived from it, into the header props.
+func (b *canalEntryBuilder) appendSchemaFingerprint(h *canal.Header, e *model.RowChangedEvent) {
+	withFingerprint := b.config.EnableSchemaFingerprint &&
+		b.featureEnabled(featureSchemaFingerprint)
+	withURL := b.config.SchemaURLBase != "" && b.featureEnabled(featureSchemaURL)
+	if !withFingerprint && !withURL {
+		return
+	}
+	fingerprint := schemaFingerprint(e)
+	if withFingerprint {
+		h.Props = append(h.Props, &canal.Pair{
+			Key:   propSchemaFingerprint,
+			Value: fingerprint,
+		})
+	}
+	if withURL {
+		h.Props = append(h.Props, &canal.Pair{
+			Key:   propSchemaURL,
+			Value: schemaURL(b.config.SchemaURLBase, h.GetSchemaName(), h.GetTableName(), fingerprint),
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaFingerprint(t *testing.T) {
+	t.Parallel()
+
+	// newTableInfo returns the schema of the table whose name column is of
+	// the given length.
+	newTableInfo := func(nameLength int) *model.TableInfo {
+		id := types.NewFieldType(mysql.TypeLong)
+		id.SetFlen(11)
+		name := types.NewFieldType(mysql.TypeVarchar)
+		name.SetFlen(nameLength)
+		return model.WrapTableInfo(1, "cdc", 1, &mm.TableInfo{
+			Name: mm.NewCIStr("person"),
+			Columns: []*mm.ColumnInfo{
+				{Name: mm.NewCIStr("id"), FieldType: *id, State: mm.StatePublic},
+				{Name: mm.NewCIStr("name"), FieldType: *name, State: mm.StatePublic},
+			},
+		})
+	}
+	newColumns := func(id int64, name string) []*model.Column {
+		return []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: id},
+			{Name: "name", Type: mysql.TypeVarchar, Value: name},
+		}
+	}
+	table := &model.TableName{Schema: "cdc", Table: "person"}
+	tableInfo := newTableInfo(255)
+	insert := &model.RowChangedEvent{
+		CommitTs:  417318403368288260,
+		Table:     table,
+		TableInfo: tableInfo,
+		Columns:   newColumns(1, "Bob"),
+	}
+	update := &model.RowChangedEvent{
+		CommitTs:   417318403368288262,
+		Table:      table,
+		TableInfo:  tableInfo,
+		PreColumns: newColumns(1, "Bob"),
+		Columns:    newColumns(1, "Alice"),
+	}
+	del := &model.RowChangedEvent{
+		CommitTs:   417318403368288264,
+		Table:      table,
+		TableInfo:  tableInfo,
+		PreColumns: newColumns(2, "Carol"),
+	}
+
+	// the fingerprint follows the documented algorithm.
+	h := fnv.New64a()
+	h.Write([]byte("id\x00int(11)\x00name\x00varchar(255)\x00"))
+	expected := fmt.Sprintf("%016x", h.Sum64())
+	require.Len(t, expected, 16)
+
+	// the fingerprint is stable across the rows of the schema.
+	for _, e := range []*model.RowChangedEvent{insert, update, del} {
+		require.Equal(t, expected, schemaFingerprint(e))
+	}
+
+	// the fingerprint changes after the type of a column changes.
+	altered := &model.RowChangedEvent{
+		CommitTs:  417318403368288270,
+		Table:     table,
+		TableInfo: newTableInfo(512),
+		Columns:   newColumns(1, "Bob"),
+	}
+	require.NotEqual(t, expected, schemaFingerprint(altered))
+	require.Equal(t, schemaFingerprint(altered), schemaFingerprint(&model.RowChangedEvent{
+		CommitTs:  417318403368288272,
+		Table:     table,
+		TableInfo: newTableInfo(512),
+		Columns:   newColumns(3, "Dave"),
+	}))
+
+	// the mysqlType of the columns is hashed if the schema is unknown.
+	unknown := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    table,
+		Columns:  newColumns(1, "Bob"),
+	}
+	h.Reset()
+	h.Write([]byte("id\x00int\x00name\x00varchar\x00"))
+	require.Equal(t, fmt.Sprintf("%016x", h.Sum64()), schemaFingerprint(unknown))
+	unknown.Columns[0].Type = mysql.TypeLonglong
+	h.Reset()
+	h.Write([]byte("id\x00bigint\x00name\x00varchar\x00"))
+	require.Equal(t, fmt.Sprintf("%016x", h.Sum64()), schemaFingerprint(unknown))
+
+	// encode returns the schemaFingerprint prop of the row.
+	encode := func(codecConfig *common.Config, e *model.RowChangedEvent) string {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propSchemaFingerprint {
+				return p.GetValue()
+			}
+		}
+		return ""
+	}
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableSchemaFingerprint = true
+	require.Equal(t, expected, encode(codecConfig, insert))
+	require.Equal(t, expected, encode(codecConfig, del))
+
+	// the prop is omitted if disabled, or for the old consumers.
+	require.Empty(t, encode(common.NewConfig(config.ProtocolCanal), insert))
+	codecConfig.FeatureLevel = 2
+	require.Empty(t, encode(codecConfig, insert))
+}
+
+func TestSchemaURL(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "https://schemas.example.com/cdc/test/person/0123456789abcdef",
+		schemaURL("https://schemas.example.com/cdc/", "test", "person", "0123456789abcdef"))
+	require.Equal(t, "https://schemas.example.com/my%20db/a%2Fb/0123456789abcdef",
+		schemaURL("https://schemas.example.com", "my db", "a/b", "0123456789abcdef"))
+
+	newEvent := func(columnType byte) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: "person"},
+			Columns: []*model.Column{
+				{Name: "id", Type: columnType, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			},
+		}
+	}
+	// encode returns the schemaUrl prop of the row.
+	encode := func(codecConfig *common.Config, e *model.RowChangedEvent) string {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			require.NotEqual(t, propSchemaFingerprint, p.GetKey())
+			if p.GetKey() == propSchemaURL {
+				return p.GetValue()
+			}
+		}
+		return ""
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.SchemaURLBase = "https://schemas.example.com/cdc"
+	event := newEvent(mysql.TypeLong)
+	format := regexp.MustCompile(`^https://schemas\.example\.com/cdc/test/person/([0-9a-f]{16})$`)
+	parts := format.FindStringSubmatch(encode(codecConfig, event))
+	require.Len(t, parts, 2)
+	require.Equal(t, schemaFingerprint(event), parts[1])
+
+	// the URL changes if the schema changes.
+	altered := encode(codecConfig, newEvent(mysql.TypeLonglong))
+	require.Regexp(t, format, altered)
+	require.NotEqual(t, parts[0], altered)
+
+	// the names emitted are in the URL.
+	codecConfig.ColumnNameCase = common.NameCaseUpper
+	codecConfig.ApplyCaseToTableNames = true
+	require.Equal(t, "https://schemas.example.com/cdc/TEST/PERSON/"+parts[1],
+		encode(codecConfig, event))
+
+	// the prop is omitted if disabled, or for the old consumers.
+	require.Empty(t, encode(common.NewConfig(config.ProtocolCanal), event))
+	codecConfig.FeatureLevel = 2
+	require.Empty(t, encode(codecConfig, event))
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"fmt"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// schemaMismatch describes the first mismatch between the columns of the row
+// and the columns visible to CDC in the schema of the table, by the count, the
+// names and the types. It returns empty if they match or the schema is unknown.
+func schemaMismatch(e *model.RowChangedEvent) string {
+	if e.TableInfo == nil || e.TableInfo.TableInfo == nil {
+		return ""
+	}
+	types := make(map[string]byte, len(e.TableInfo.Columns))
+	for _, col := range e.TableInfo.Columns {
+		if model.IsColCDCVisible(col) {
+			types[col.Name.O] = col.GetType()
+		}
+	}
+	for _, columns := range [][]*model.Column{e.PreColumns, e.Columns} {
+		if len(columns) == 0 {
+			continue
+		}
+		count := 0
+		for _, col := range columns {
+			if col == nil {
+				continue
+			}
+			count++
+			tp, ok := types[col.Name]
+			if !ok {
+				return fmt.Sprintf("column %s is not in the schema", col.Name)
+			}
+			if tp != col.Type {
+				return fmt.Sprintf("column %s is of type %d, but %d in the schema",
+					col.Name, col.Type, tp)
+			}
+		}
+		if count != len(types) {
+			return fmt.Sprintf("the row has %d columns, but the schema has %d",
+				count, len(types))
+		}
+	}
+	return ""
+}
+
+// checkSchema checks the columns of the row against the schema of the table,
+// which the type enrichment, e.g. the declared types and the ordinals of the
+// columns, is derived from. On a mismatch, e.g. the row is mounted during a
+// DDL, the row is rejected with ErrCanalSchemaMismatch if the schema-mismatch
+// is reject, otherwise a copy of the row without the schema is returned, so
+// that the columns are emitted without the type enrichment.
+func (b *canalEntryBuilder) checkSchema(e *model.RowChangedEvent) (*model.RowChangedEvent, error) {
+	mismatch := schemaMismatch(e)
+	if mismatch == "" {
+		return e, nil
+	}
+	if b.config.SchemaMismatch == common.SchemaMismatchReject {
+		return nil, cerror.ErrCanalSchemaMismatch.GenWithStackByArgs(e.Table.String(), mismatch)
+	}
+	row := *e
+	row.TableInfo = nil
+	return &row, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaMismatch(t *testing.T) {
+	t.Parallel()
+
+	// the schema of `t(id int primary key, name varchar(32))`.
+	newColumn := func(id int64, name string, tp byte, flen int) *mm.ColumnInfo {
+		ft := types.NewFieldType(tp)
+		ft.SetFlen(flen)
+		return &mm.ColumnInfo{
+			ID:        id,
+			Offset:    int(id - 1),
+			Name:      mm.NewCIStr(name),
+			FieldType: *ft,
+			State:     mm.StatePublic,
+		}
+	}
+	tableInfo := model.WrapTableInfo(1, "test", 1, &mm.TableInfo{
+		ID:   1,
+		Name: mm.NewCIStr("t"),
+		Columns: []*mm.ColumnInfo{
+			newColumn(1, "id", mysql.TypeLong, 11),
+			newColumn(2, "name", mysql.TypeVarchar, 32),
+		},
+	})
+	newRow := func(columns ...*model.Column) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs:  417318403368288260,
+			Table:     &model.TableName{Schema: "test", Table: "t"},
+			TableInfo: tableInfo,
+			Columns:   columns,
+		}
+	}
+	id := &model.Column{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1}
+	name := &model.Column{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Bob")}
+
+	// the rows mounted by another schema, e.g. during the DDL.
+	mismatched := []*model.RowChangedEvent{
+		newRow(id),
+		newRow(id, name, &model.Column{Name: "age", Type: mysql.TypeLong, Value: 1}),
+		newRow(id, &model.Column{Name: "name", Type: mysql.TypeLong, Value: 1}),
+		newRow(id, &model.Column{Name: "title", Type: mysql.TypeVarchar, Value: []byte("Bob")}),
+	}
+	require.Empty(t, schemaMismatch(newRow(id, name)))
+	for _, e := range mismatched {
+		require.NotEmpty(t, schemaMismatch(e))
+	}
+	// the old image is checked as well.
+	update := newRow(id, name)
+	update.PreColumns = []*model.Column{id}
+	require.NotEmpty(t, schemaMismatch(update))
+	mismatched = append(mismatched, update)
+
+	// declaredTypes returns the declaredType prop of the columns after the change.
+	declaredTypes := func(codecConfig *common.Config, e *model.RowChangedEvent) map[string]string {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		result := make(map[string]string)
+		for _, column := range rc.GetRowDatas()[0].GetAfterColumns() {
+			for _, p := range column.GetProps() {
+				if p.GetKey() == propDeclaredType {
+					result[column.GetName()] = p.GetValue()
+				}
+			}
+		}
+		return result
+	}
+
+	// the columns are enriched by the schema matched.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableDeclaredType = true
+	require.Equal(t, map[string]string{"id": "int(11)", "name": "varchar(32)"},
+		declaredTypes(codecConfig, newRow(id, name)))
+
+	// the columns are emitted without the enrichment by default.
+	for _, e := range mismatched {
+		require.Empty(t, declaredTypes(codecConfig, e))
+		// the event is not modified.
+		require.Equal(t, tableInfo, e.TableInfo)
+	}
+
+	// the rows are rejected if the schema-mismatch is reject.
+	codecConfig.SchemaMismatch = common.SchemaMismatchReject
+	require.Equal(t, map[string]string{"id": "int(11)", "name": "varchar(32)"},
+		declaredTypes(codecConfig, newRow(id, name)))
+	for _, e := range mismatched {
+		_, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.True(t, cerror.ErrCanalSchemaMismatch.Equal(err))
+		require.ErrorContains(t, err, "test.t")
+	}
+
+	// the rows of the unknown schema are not checked.
+	row := newRow(id)
+	row.TableInfo = nil
+	_, err := newCanalEntryBuilder(codecConfig).fromRowEvent(row)
+	require.Nil(t, err)
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// appendSchemaVersion stamps the version of the table schema encoding the
+// event into the header props. The version is the commit ts of the DDL
+// creating the schema, so the rows of a schema share the version reported
+// by that DDL, and a consumer caching the schema can tell when to refresh
+// it. The prop is omitted if the version is unknown.
+func (b *canalEntryBuilder) appendSchemaVersion(h *canal.Header, version uint64) {
+	if !b.config.EnableSchemaVersion || version == 0 ||
+		!b.featureEnabled(featureSchemaVersion) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propSchemaVersion,
+		Value: strconv.FormatUint(version, 10),
+	})
+}
+
+// rowSchemaVersion returns the version of the table schema encoding the row,
+// which is 0 if it's unknown.
+func rowSchemaVersion(e *model.RowChangedEvent) uint64 {
+	if e.TableInfoVersion != 0 {
+		return e.TableInfoVersion
+	}
+	if e.TableInfo != nil {
+		return e.TableInfo.TableInfoVersion
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	newTableInfo := func(version uint64, columns ...string) *model.TableInfo {
+		tableInfo := &mm.TableInfo{ID: 1, Name: mm.NewCIStr("t")}
+		for i, name := range columns {
+			tableInfo.Columns = append(tableInfo.Columns, &mm.ColumnInfo{
+				ID:        int64(i + 1),
+				Name:      mm.NewCIStr(name),
+				FieldType: *types.NewFieldType(mysql.TypeLong),
+				State:     mm.StatePublic,
+			})
+		}
+		return model.WrapTableInfo(1, "test", version, tableInfo)
+	}
+	newRow := func(tableInfo *model.TableInfo, commitTs uint64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs:         commitTs,
+			Table:            &model.TableName{Schema: "test", Table: "t"},
+			TableInfo:        tableInfo,
+			TableInfoVersion: tableInfo.TableInfoVersion,
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			},
+		}
+	}
+	// schemaVersion returns the schemaVersion prop of the header and whether it's present.
+	schemaVersion := func(h *canal.Header) (string, bool) {
+		for _, p := range h.GetProps() {
+			if p.GetKey() == propSchemaVersion {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+	rowVersion := func(codecConfig *common.Config, e *model.RowChangedEvent) (string, bool) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		return schemaVersion(entry.GetHeader())
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableSchemaVersion = true
+
+	// the rows of the same schema share the version.
+	before := newTableInfo(417318403368288260, "id")
+	value, ok := rowVersion(codecConfig, newRow(before, 417318403368288261))
+	require.True(t, ok)
+	require.Equal(t, "417318403368288260", value)
+	value, ok = rowVersion(codecConfig, newRow(before, 417318403368288262))
+	require.True(t, ok)
+	require.Equal(t, "417318403368288260", value)
+
+	// the DDL altering the table reports the version of the new schema,
+	// which is carried by the rows after it.
+	after := newTableInfo(417318403368288270, "id", "name")
+	ddl := &model.DDLEvent{
+		CommitTs:     417318403368288270,
+		Query:        "alter table t add column name int",
+		Type:         mm.ActionAddColumn,
+		TableInfo:    after,
+		PreTableInfo: before,
+	}
+	entry, err := newCanalEntryBuilder(codecConfig).fromDDLEvent(ddl)
+	require.Nil(t, err)
+	ddlVersion, ok := schemaVersion(entry.GetHeader())
+	require.True(t, ok)
+	require.Equal(t, "417318403368288270", ddlVersion)
+	value, ok = rowVersion(codecConfig, newRow(after, 417318403368288271))
+	require.True(t, ok)
+	require.Equal(t, ddlVersion, value)
+
+	// the version falls back to the one of the table info of the row.
+	row := newRow(after, 417318403368288271)
+	row.TableInfoVersion = 0
+	value, ok = rowVersion(codecConfig, row)
+	require.True(t, ok)
+	require.Equal(t, ddlVersion, value)
+
+	// the prop is omitted if the version is unknown.
+	row.TableInfo = nil
+	_, ok = rowVersion(codecConfig, row)
+	require.False(t, ok)
+
+	// the prop is omitted if disabled.
+	_, ok = rowVersion(common.NewConfig(config.ProtocolCanal), newRow(before, 417318403368288261))
+	require.False(t, ok)
+	codecConfig.FeatureLevel = 2
+	_, ok = rowVersion(codecConfig, newRow(before, 417318403368288261))
+	require.False(t, ok)
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"fmt"
+	"sync"
+
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// sequencer generates the sequence stamped into each entry, so that the
+// consumer can order the entries globally across partitions after the fact.
+//
+// The sequence is a pair of (ts, counter), compared by the ts first and the
+// counter on tie. The ts is the commit ts of the event, and the counter breaks
+// the tie among the events sharing the same commit ts. Since the events of
+// different tables are not emitted in the commit ts order, an event whose
+// commit ts is lower than the one already sequenced is assigned the higher ts
+// with the next counter, i.e. it's ordered by the emitting order. Hence the
+// sequence is strictly increasing in the order the events are encoded, and is
+// consistent with the commit ts order among the events emitted in that order.
+//
+// It's shared by all the encoders built by the same builder, so the sequence
+// is strictly increasing among all the partitions of the changefeed.
+type sequencer struct {
+	mu      sync.Mutex
+	ts      uint64
+	counter uint64
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{}
+}
+
+// next returns the sequence for the event committed at the commitTs.
+func (s *sequencer) next(commitTs uint64) (uint64, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if commitTs > s.ts {
+		s.ts = commitTs
+		s.counter = 0
+	} else {
+		s.counter++
+	}
+	return s.ts, s.counter
+}
+
+// formatSequence formats the sequence into a fixed width string, so that the
+// sequences can be compared as strings.
+func formatSequence(ts, counter uint64) string {
+	return fmt.Sprintf("%020d-%020d", ts, counter)
+}
+
+// appendSequence stamps the sequence of the event into the header props.
+func (b *canalEntryBuilder) appendSequence(h *canal.Header, commitTs uint64) {
+	if !b.config.EnableSequence || !b.featureEnabled(featureSequence) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propSequence,
+		Value: formatSequence(b.sequencer.next(commitTs)),
+	})
+}
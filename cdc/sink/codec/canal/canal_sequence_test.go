@@ -0,0 +1,141 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencer(t *testing.T) {
+	t.Parallel()
+
+	s := newSequencer()
+	cases := []struct {
+		commitTs uint64
+		ts       uint64
+		counter  uint64
+	}{
+		{commitTs: 100, ts: 100, counter: 0},
+		{commitTs: 100, ts: 100, counter: 1},
+		{commitTs: 101, ts: 101, counter: 0},
+		// the late event is ordered after the sequenced ones.
+		{commitTs: 99, ts: 101, counter: 1},
+		{commitTs: 102, ts: 102, counter: 0},
+	}
+	for _, cs := range cases {
+		ts, counter := s.next(cs.commitTs)
+		require.Equal(t, cs.ts, ts)
+		require.Equal(t, cs.counter, counter)
+	}
+
+	require.Less(t, formatSequence(9, 100), formatSequence(10, 0))
+	require.Less(t, formatSequence(10, 9), formatSequence(10, 10))
+}
+
+func TestSequenceAcrossPartitions(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableSequence = true
+	builder := NewBatchEncoderBuilder(codecConfig)
+
+	// each partition is encoded by its own encoder.
+	const partitionNum = 3
+	encoders := make([]*BatchEncoder, partitionNum)
+	for i := range encoders {
+		encoders[i] = builder.Build().(*BatchEncoder)
+	}
+
+	newRow := func(commitTs uint64, table string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLong,
+				Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value: 1,
+			}},
+		}
+	}
+	// the events in the emitting order, the commit ts are not unique,
+	// and are not ordered across the tables.
+	commitTs := []uint64{100, 100, 100, 101, 103, 102, 103, 103, 104}
+	for i, ts := range commitTs {
+		row := newRow(ts, "t"+string(rune('0'+i%partitionNum)))
+		err := encoders[i%partitionNum].AppendRowChangedEvent(context.Background(), "", row, nil)
+		require.Nil(t, err)
+	}
+
+	sequences := make([][]string, partitionNum)
+	for i, encoder := range encoders {
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		sequences[i] = decodeSequences(t, msgs[0].Value)
+	}
+
+	// merge back to the emitting order, the sequence is strictly increasing.
+	var last string
+	for i := range commitTs {
+		sequence := sequences[i%partitionNum][i/partitionNum]
+		require.Less(t, last, sequence)
+		last = sequence
+	}
+
+	// the DDL shares the sequence with the rows.
+	ddl := &model.DDLEvent{
+		CommitTs:  104,
+		Query:     "create table test.t4(id int primary key)",
+		Type:      1,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t4"}},
+	}
+	msg, err := builder.Build().EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	sequence := decodeSequences(t, msg.Value)[0]
+	require.Less(t, last, sequence)
+	require.Equal(t, formatSequence(104, 1), sequence)
+}
+
+// decodeSequences returns the sequences of the entries in the canal packet.
+func decodeSequences(t *testing.T, value []byte) []string {
+	packet := &canal.Packet{}
+	require.Nil(t, proto.Unmarshal(value, packet))
+	messages := &canal.Messages{}
+	require.Nil(t, proto.Unmarshal(packet.GetBody(), messages))
+
+	var result []string
+	for _, data := range messages.GetMessages() {
+		entry := &canal.Entry{}
+		require.Nil(t, proto.Unmarshal(data, entry))
+		var sequence string
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propSequence {
+				sequence = p.GetValue()
+			}
+		}
+		require.NotEmpty(t, sequence)
+		result = append(result, sequence)
+	}
+	return result
+}
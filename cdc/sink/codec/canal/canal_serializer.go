@@ -0,0 +1,73 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/golang/protobuf/proto" // nolint:staticcheck
+	"github.com/pingcap/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// Entry is the serializer-agnostic form of a canal entry built by the entry
+// builder, i.e. the canal logical model before it's serialized. The header
+// and the row change are the structs of the canal protocol, while the row
+// change is held as is, rather than as the serialized store value.
+type Entry struct {
+	Header    *canal.Header
+	EntryType canal.EntryType
+	// RowChange is nil if the entry carries no store value,
+	// e.g. the watermark and the heartbeat.
+	RowChange *canal.RowChange
+}
+
+// toProto converts the entry into the canal protobuf entry,
+// whose store value is the serialized row change.
+func (e *Entry) toProto() (*canal.Entry, error) {
+	result := &canal.Entry{
+		Header:           e.Header,
+		EntryTypePresent: &canal.Entry_EntryType{EntryType: e.EntryType},
+	}
+	if e.RowChange != nil {
+		b, err := proto.Marshal(e.RowChange)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		result.StoreValue = b
+	}
+	return result, nil
+}
+
+// Serializer serializes the entries, which are then carried in the canal
+// packets. The error returned is classified as ErrCanalMarshalFailed, unless
+// it's ErrCanalExternalStoreFailed, which is retryable.
+type Serializer interface {
+	Serialize(entry *Entry) ([]byte, error)
+}
+
+// protoSerializer serializes the entries into the canal protobuf entries,
+// it's the default Serializer.
+type protoSerializer struct{}
+
+// Serialize implements the Serializer interface
+func (protoSerializer) Serialize(entry *Entry) ([]byte, error) {
+	result, err := entry.toProto()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	b, err := proto.Marshal(result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return b, nil
+}
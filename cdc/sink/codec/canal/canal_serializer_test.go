@@ -0,0 +1,127 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mm "github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonEntry is the entry serialized by the jsonSerializer.
+type jsonEntry struct {
+	EntryType string              `json:"entryType"`
+	EventType string              `json:"eventType"`
+	Schema    string              `json:"schema"`
+	Table     string              `json:"table"`
+	SQL       string              `json:"sql,omitempty"`
+	Rows      []map[string]string `json:"rows,omitempty"`
+}
+
+// jsonSerializer serializes the entries into JSON, it has nothing to do with
+// the protobuf serialization.
+type jsonSerializer struct{}
+
+func (jsonSerializer) Serialize(entry *Entry) ([]byte, error) {
+	result := jsonEntry{
+		EntryType: entry.EntryType.String(),
+		EventType: entry.Header.GetEventType().String(),
+		Schema:    entry.Header.GetSchemaName(),
+		Table:     entry.Header.GetTableName(),
+		SQL:       entry.RowChange.GetSql(),
+	}
+	for _, rowData := range entry.RowChange.GetRowDatas() {
+		row := make(map[string]string)
+		for _, column := range rowData.GetAfterColumns() {
+			row[column.GetName()] = column.GetValue()
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return json.Marshal(result)
+}
+
+func TestSerializer(t *testing.T) {
+	t.Parallel()
+
+	row := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+		},
+	}
+	ddl := &model.DDLEvent{
+		CommitTs:  417318403368288260,
+		Query:     "create table test.t (id int primary key, name varchar(32))",
+		Type:      mm.ActionCreateTable,
+		TableInfo: &model.TableInfo{TableName: model.TableName{Schema: "test", Table: "t"}},
+	}
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+
+	// the default serializer emits the canal protobuf entries.
+	builder := newCanalEntryBuilder(codecConfig)
+	entry, err := builder.buildRowEntry(row)
+	require.Nil(t, err)
+	b, err := protoSerializer{}.Serialize(entry)
+	require.Nil(t, err)
+	pbEntry, err := builder.fromRowEvent(row)
+	require.Nil(t, err)
+	expected, err := proto.Marshal(pbEntry)
+	require.Nil(t, err)
+	require.Equal(t, expected, b)
+
+	// the same entries are serialized into JSON.
+	encoder := NewBatchEncoderBuilder(codecConfig, WithSerializer(jsonSerializer{})).Build()
+	require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", row, nil))
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	entries, err := decodePacket(msgs[0].Value)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	var decoded jsonEntry
+	require.Nil(t, json.Unmarshal(entries[0], &decoded))
+	require.Equal(t, jsonEntry{
+		EntryType: canal.EntryType_ROWDATA.String(),
+		EventType: canal.EventType_INSERT.String(),
+		Schema:    "test",
+		Table:     "t",
+		Rows:      []map[string]string{{"id": "1", "name": "Bob"}},
+	}, decoded)
+
+	msg, err := encoder.EncodeDDLEvent(ddl)
+	require.Nil(t, err)
+	entries, err = decodePacket(msg.Value)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	decoded = jsonEntry{}
+	require.Nil(t, json.Unmarshal(entries[0], &decoded))
+	require.Equal(t, jsonEntry{
+		EntryType: canal.EntryType_ROWDATA.String(),
+		EventType: canal.EventType_CREATE.String(),
+		Schema:    "test",
+		Table:     "t",
+		SQL:       ddl.Query,
+	}, decoded)
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"math/bits"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+const (
+	// headerFixedSize is the size of the fields of the entry header which
+	// do not depend on the event, i.e. the version, the serverenCode, the
+	// executeTime, the sourceType, the eventType and the rowsCount prop.
+	headerFixedSize = 2 + 7 + 7 + 2 + 2 + 16
+	// rowChangeFixedSize is the size of the eventType and isDdl of the row change.
+	rowChangeFixedSize = 2 + 2
+	// entryFixedSize is the size of the entryType of the entry.
+	entryFixedSize = 2
+	// columnFixedSize is the size of the isKey, updated and isNull of the column.
+	columnFixedSize = 2 + 2 + 2
+	// unknownValueSize is the size assumed for the value of an unknown type.
+	unknownValueSize = 16
+)
+
+// EstimateSize approximates the size of the serialized canal entry of the
+// event without building it. The optional props are not taken into account,
+// so the estimation is expected to be within 10% of the actual size when no
+// optional feature is enabled, and it's cheap enough to be called before the
+// event is encoded, e.g. to decide the batching.
+func (b *canalEntryBuilder) EstimateSize(e *model.RowChangedEvent) int {
+	header := headerFixedSize +
+		sizeOfBytesField(len(e.Table.Schema)) + sizeOfBytesField(len(e.Table.Table))
+
+	updated := !e.IsDelete()
+	before := 0
+	for _, c := range e.PreColumns {
+		if c != nil {
+			before += sizeOfBytesField(estimateColumnSize(c, updated))
+		}
+	}
+	after := 0
+	for _, c := range e.Columns {
+		if c != nil {
+			after += sizeOfBytesField(estimateColumnSize(c, updated))
+		}
+	}
+	rowChange := rowChangeFixedSize + sizeOfBytesField(before+after)
+
+	return entryFixedSize + sizeOfBytesField(header) + sizeOfBytesField(rowChange)
+}
+
+// estimateColumnSize approximates the size of the serialized canal column.
+func estimateColumnSize(c *model.Column, updated bool) int {
+	mysqlType := getMySQLType(c)
+	javaType, err := getJavaSQLType(c, mysqlType)
+	if err != nil {
+		javaType = internal.JavaSQLTypeVARCHAR
+	}
+
+	size := sizeOfBytesField(len(c.Name)) + sizeOfBytesField(len(mysqlType))
+	// isKey and updated are omitted if false, isNull is always present.
+	size += 2
+	if c.Flag.IsPrimaryKey() {
+		size += 2
+	}
+	if updated {
+		size += 2
+	}
+	// the negative sql type is encoded as a 10 bytes varint.
+	if javaType < 0 {
+		size += 11
+	} else {
+		size += 1 + sizeOfVarint(uint64(javaType))
+	}
+	if c.Value != nil {
+		size += sizeOfBytesField(estimateValueSize(c.Value, javaType))
+	}
+	return size
+}
+
+// estimateValueSize approximates the length of the value string.
+func estimateValueSize(value interface{}, javaType internal.JavaSQLType) int {
+	var buf [32]byte
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		if javaType != internal.JavaSQLTypeBLOB {
+			return len(v)
+		}
+		// the binary value is decoded by ISO-8859-1,
+		// whose upper half takes 2 bytes in UTF-8.
+		size := len(v)
+		for _, c := range v {
+			if c >= 0x80 {
+				size++
+			}
+		}
+		return size
+	case int64:
+		return len(strconv.AppendInt(buf[:0], v, 10))
+	case uint64:
+		return len(strconv.AppendUint(buf[:0], v, 10))
+	case int:
+		return len(strconv.AppendInt(buf[:0], int64(v), 10))
+	case float32:
+		return len(strconv.AppendFloat(buf[:0], float64(v), 'f', -1, 32))
+	case float64:
+		return len(strconv.AppendFloat(buf[:0], v, 'f', -1, 64))
+	default:
+		return unknownValueSize
+	}
+}
+
+// sizeOfBytesField returns the size of a length-delimited protobuf field
+// with 1 byte tag and the given length.
+func sizeOfBytesField(length int) int {
+	return 1 + sizeOfVarint(uint64(length)) + length
+}
+
+// sizeOfVarint returns the size of the varint encoded x.
+func sizeOfVarint(x uint64) int {
+	return (bits.Len64(x|1) + 6) / 7
+}
+
+// rowSize returns the size in bytes of the row measured by the mode, which is
+// one of:
+//
+//	store-value: the length of the serialized row change of the entry carrying
+//	             the row alone, i.e. the store value, which includes the
+//	             metadata of the columns, e.g. the names and the types.
+//	values:      the total length of the value strings of the columns in both
+//	             the before and the after images, the null values count as 0.
+func rowSize(mode string, header *canal.Header, rowData *canal.RowData) int {
+	if mode == common.RowSizeValues {
+		size := 0
+		for _, c := range rowData.BeforeColumns {
+			size += len(c.GetValue())
+		}
+		for _, c := range rowData.AfterColumns {
+			size += len(c.GetValue())
+		}
+		return size
+	}
+	return proto.Size(newRowEntry(header, rowData).RowChange)
+}
+
+// appendRowSize stamps the size of the row into the header props, the header
+// is not counted in, since the size is a prop of it.
+func (b *canalEntryBuilder) appendRowSize(h *canal.Header, rowData *canal.RowData) {
+	if b.config.RowSize == "" || !b.featureEnabled(featureRowSize) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propRowSize,
+		Value: strconv.Itoa(rowSize(b.config.RowSize, h, rowData)),
+	})
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateSize(t *testing.T) {
+	t.Parallel()
+
+	table := &model.TableName{Schema: "cdc", Table: "person"}
+	wide := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    table,
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: int64(-42)},
+			{Name: "score", Type: mysql.TypeDouble, Value: 3.1415926},
+			{Name: "comment", Type: mysql.TypeBlob, Value: []byte(strings.Repeat("text", 256))},
+			{Name: "data", Type: mysql.TypeBlob, Flag: model.BinaryFlag, Value: []byte{0x00, 0x7f, 0x80, 0xff}},
+			{Name: "deleted", Type: mysql.TypeTiny, Value: nil},
+		},
+	}
+	narrow := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    table,
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+		},
+		PreColumns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: int64(2)},
+		},
+	}
+
+	builder := newCanalEntryBuilder(common.NewConfig(config.ProtocolCanal))
+	for _, event := range []*model.RowChangedEvent{
+		testCaseInsert, testCaseUpdate, testCaseDelete, wide, narrow,
+	} {
+		entry, err := builder.fromRowEvent(event)
+		require.Nil(t, err)
+		actual := proto.Size(entry)
+
+		estimated := builder.EstimateSize(event)
+		require.InEpsilon(t, actual, estimated, 0.1,
+			"actual: %d, estimated: %d", actual, estimated)
+	}
+}
+
+func TestRowSize(t *testing.T) {
+	t.Parallel()
+
+	update := &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "cdc", Table: "person"},
+		PreColumns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Bob")},
+			{Name: "comment", Type: mysql.TypeBlob, Value: nil},
+		},
+		Columns: []*model.Column{
+			{Name: "id", Type: mysql.TypeLonglong, Flag: model.PrimaryKeyFlag, Value: int64(1)},
+			{Name: "name", Type: mysql.TypeVarchar, Value: []byte("Alice")},
+			{Name: "comment", Type: mysql.TypeBlob, Value: []byte(strings.Repeat("text", 64))},
+		},
+	}
+	// encode returns the entry of the row and its rowSize prop.
+	encode := func(codecConfig *common.Config) (*canal.Entry, string) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(update)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propRowSize {
+				return entry, p.GetValue()
+			}
+		}
+		return entry, ""
+	}
+
+	// the size of the store value matches the marshalled row change.
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.RowSize = common.RowSizeStoreValue
+	entry, size := encode(codecConfig)
+	require.Equal(t, strconv.Itoa(len(entry.GetStoreValue())), size)
+
+	// the size of the values only counts the value strings.
+	codecConfig.RowSize = common.RowSizeValues
+	entry, size = encode(codecConfig)
+	require.Equal(t, strconv.Itoa(len("1Bob1Alice")+256), size)
+	rc := &canal.RowChange{}
+	require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+	expected := 0
+	for _, rowData := range rc.GetRowDatas() {
+		for _, c := range append(rowData.GetBeforeColumns(), rowData.GetAfterColumns()...) {
+			expected += len(c.GetValue())
+		}
+	}
+	require.Equal(t, strconv.Itoa(expected), size)
+
+	// the prop is omitted if disabled.
+	_, size = encode(common.NewConfig(config.ProtocolCanal))
+	require.Empty(t, size)
+	codecConfig.FeatureLevel = 2
+	_, size = encode(codecConfig)
+	require.Empty(t, size)
+}
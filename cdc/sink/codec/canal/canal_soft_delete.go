@@ -0,0 +1,53 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+)
+
+// softDelete returns the DELETE as the UPDATE setting the soft delete column
+// to the value configured, and the other events as is. The new image is the
+// old image with the column set, or appended as a varchar if the table does
+// not have the column. The upstream checksum is cleared, since it does not
+// cover the new image. The event is copied, rather than modified in place.
+func (b *canalEntryBuilder) softDelete(e *model.RowChangedEvent) *model.RowChangedEvent {
+	if b.config.SoftDeleteColumn == "" || !e.IsDelete() {
+		return e
+	}
+	columns := make([]*model.Column, 0, len(e.PreColumns)+1)
+	found := false
+	for _, column := range e.PreColumns {
+		if column != nil && column.Name == b.config.SoftDeleteColumn {
+			c := *column
+			c.Value = b.config.SoftDeleteValue
+			column = &c
+			found = true
+		}
+		columns = append(columns, column)
+	}
+	if !found {
+		columns = append(columns, &model.Column{
+			Name:  b.config.SoftDeleteColumn,
+			Type:  mysql.TypeVarchar,
+			Value: b.config.SoftDeleteValue,
+		})
+	}
+
+	result := *e
+	result.Columns = columns
+	result.Checksum = nil
+	return &result
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftDelete(t *testing.T) {
+	t.Parallel()
+
+	newDelete := func(withColumn bool) *model.RowChangedEvent {
+		preColumns := []*model.Column{
+			{
+				Name: "id", Type: mysql.TypeLong,
+				Flag: model.HandleKeyFlag | model.PrimaryKeyFlag, Value: int64(1),
+			},
+			{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+		}
+		if withColumn {
+			preColumns = append(preColumns,
+				&model.Column{Name: "deleted_at", Type: mysql.TypeDatetime, Value: nil})
+		}
+		return &model.RowChangedEvent{
+			CommitTs:   417318403368288260,
+			Table:      &model.TableName{Schema: "test", Table: "t"},
+			PreColumns: preColumns,
+			Checksum:   &model.RowChecksum{Algorithm: "crc32", Value: "1"},
+		}
+	}
+	// encode returns the row change of the single entry encoded.
+	encode := func(codecConfig *common.Config, e *model.RowChangedEvent) *canal.RowChange {
+		encoder := newBatchEncoder(codecConfig)
+		require.Nil(t, encoder.AppendRowChangedEvent(context.Background(), "", e, nil))
+		msgs, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		entries, err := decodePacket(msgs[0].Value)
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+		entry := &canal.Entry{}
+		require.Nil(t, proto.Unmarshal(entries[0], entry))
+		rc := &canal.RowChange{}
+		require.Nil(t, proto.Unmarshal(entry.GetStoreValue(), rc))
+		require.Equal(t, rc.GetEventType(), entry.GetHeader().GetEventType())
+		return rc
+	}
+	values := func(columns []*canal.Column) map[string]string {
+		result := make(map[string]string)
+		for _, c := range columns {
+			result[c.GetName()] = c.GetValue()
+		}
+		return result
+	}
+
+	// the DELETE is emitted as is by default.
+	rc := encode(common.NewConfig(config.ProtocolCanal), newDelete(true))
+	require.Equal(t, canal.EventType_DELETE, rc.GetEventType())
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.SoftDeleteColumn = "deleted_at"
+	codecConfig.SoftDeleteValue = "2022-10-14 12:34:56"
+	e := newDelete(true)
+	rc = encode(codecConfig, e)
+	require.Equal(t, canal.EventType_UPDATE, rc.GetEventType())
+	rowData := rc.GetRowDatas()[0]
+	require.Equal(t, map[string]string{
+		"id": "1", "name": "Bob", "deleted_at": "",
+	}, values(rowData.GetBeforeColumns()))
+	require.Equal(t, map[string]string{
+		"id": "1", "name": "Bob", "deleted_at": "2022-10-14 12:34:56",
+	}, values(rowData.GetAfterColumns()))
+	for _, c := range rowData.GetAfterColumns() {
+		require.Equal(t, c.GetName() == "id", c.GetIsKey())
+		require.False(t, c.GetIsNull())
+	}
+	// the event is not modified.
+	require.True(t, e.IsDelete())
+	require.Nil(t, e.PreColumns[2].Value)
+	require.NotNil(t, e.Checksum)
+
+	// the column is appended if the table does not have it.
+	rc = encode(codecConfig, newDelete(false))
+	require.Equal(t, canal.EventType_UPDATE, rc.GetEventType())
+	require.Equal(t, map[string]string{
+		"id": "1", "name": "Bob", "deleted_at": "2022-10-14 12:34:56",
+	}, values(rc.GetRowDatas()[0].GetAfterColumns()))
+
+	// the other events are not affected.
+	rc = encode(codecConfig, &model.RowChangedEvent{
+		CommitTs: 417318403368288260,
+		Table:    &model.TableName{Schema: "test", Table: "t"},
+		Columns:  newDelete(true).PreColumns,
+	})
+	require.Equal(t, canal.EventType_INSERT, rc.GetEventType())
+	require.Equal(t, "", values(rc.GetRowDatas()[0].GetAfterColumns())["deleted_at"])
+}
@@ -0,0 +1,34 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// appendSQLDigest stamps the digest of the statement producing the row into
+// the header props, so that the consumer can correlate the change with the
+// statement, e.g. in the statement summary of the upstream. The prop is
+// omitted if the row does not carry the digest.
+func (b *canalEntryBuilder) appendSQLDigest(h *canal.Header, e *model.RowChangedEvent) {
+	if !b.config.EnableSQLDigest || e.SQLDigest == "" ||
+		!b.featureEnabled(featureSQLDigest) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propSQLDigest,
+		Value: e.SQLDigest,
+	})
+}
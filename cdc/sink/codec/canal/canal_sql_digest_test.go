@@ -0,0 +1,70 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLDigest(t *testing.T) {
+	t.Parallel()
+
+	const digest = "e6f07d43b5c21db0fbb9a31feac2dc599787763393dd5acbfad80e247eb02ad5"
+	newRow := func(digest string) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs:  417318403368288260,
+			Table:     &model.TableName{Schema: "test", Table: "t"},
+			SQLDigest: digest,
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			},
+		}
+	}
+	// sqlDigest returns the sqlDigest prop of the row entry and whether it's present.
+	sqlDigest := func(codecConfig *common.Config, e *model.RowChangedEvent) (string, bool) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propSQLDigest {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableSQLDigest = true
+
+	// the digest carried by the row is emitted.
+	value, ok := sqlDigest(codecConfig, newRow(digest))
+	require.True(t, ok)
+	require.Equal(t, digest, value)
+
+	// the prop is omitted if the row does not carry the digest.
+	_, ok = sqlDigest(codecConfig, newRow(""))
+	require.False(t, ok)
+
+	// the prop is omitted if disabled.
+	_, ok = sqlDigest(common.NewConfig(config.ProtocolCanal), newRow(digest))
+	require.False(t, ok)
+	codecConfig.FeatureLevel = 2
+	_, ok = sqlDigest(codecConfig, newRow(digest))
+	require.False(t, ok)
+}
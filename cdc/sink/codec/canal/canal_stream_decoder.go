@@ -0,0 +1,354 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/types"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
+	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+const (
+	// packetLengthSize is the size of the length prefix of a framed packet.
+	packetLengthSize = 4
+	// defaultMaxPacketSize is the max size of a framed packet read by the
+	// stream decoder by default, so that a corrupted length prefix doesn't
+	// make the decoder allocate a huge buffer.
+	defaultMaxPacketSize = 64 * 1024 * 1024
+)
+
+// framePacket prefixes the packet with its length, so that the packets
+// concatenated can be decoded by the stream decoder.
+func framePacket(packet []byte) []byte {
+	framed := make([]byte, packetLengthSize, packetLengthSize+len(packet))
+	binary.BigEndian.PutUint32(framed, uint32(len(packet)))
+	return append(framed, packet...)
+}
+
+// streamDecoder decodes the events from a continuous stream of framed canal
+// packets. Each packet is prefixed by its length, in 4 bytes big endian,
+// which is the same framing used by the canal server.
+type streamDecoder struct {
+	// reader is nil if the decoder decodes a single packet.
+	reader io.Reader
+	// maxPacketSize is the max size of a framed packet read from the reader.
+	maxPacketSize uint32
+
+	// entries holds the entries of the current packet not consumed yet.
+	entries [][]byte
+
+	header *canal.Header
+	// rowChange is the current DDL, rowDatas holds the rows of the current
+	// entry not consumed yet, since an entry may carry multiple rows if the
+	// inserts are grouped.
+	rowChange *canal.RowChange
+	rowDatas  []*canal.RowData
+	// watermarkTs is the ts of the current table-scoped watermark, 0 if none.
+	watermarkTs uint64
+	// ddlCompressionDicts are the zstd dictionaries of the DDL compression,
+	// see DecodeDDLQuery.
+	ddlCompressionDicts [][]byte
+}
+
+// DecoderOption is the option of the canal decoders.
+type DecoderOption func(d *streamDecoder)
+
+// WithDDLCompressionDictionaries sets the zstd dictionaries inflating the DDL
+// queries compressed with them, see common.Config.DDLCompressionDictionary.
+func WithDDLCompressionDictionaries(dicts ...[]byte) DecoderOption {
+	return func(d *streamDecoder) {
+		d.ddlCompressionDicts = dicts
+	}
+}
+
+// WithMaxPacketSize sets the max size of a framed packet read by the stream
+// decoder, which should be no less than the max-message-bytes of the encoder.
+func WithMaxPacketSize(size uint32) DecoderOption {
+	return func(d *streamDecoder) {
+		d.maxPacketSize = size
+	}
+}
+
+// NewStreamDecoder return a decoder for the stream of framed canal packets.
+func NewStreamDecoder(r io.Reader, opts ...DecoderOption) codec.EventBatchDecoder {
+	d := &streamDecoder{
+		reader:        r,
+		maxPacketSize: defaultMaxPacketSize,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// NewPacketDecoder return a decoder for a single canal packet, which is not
+// framed, e.g. the value of a message encoded by the canal encoder.
+func NewPacketDecoder(data []byte, opts ...DecoderOption) (codec.EventBatchDecoder, error) {
+	entries, err := decodePacket(data)
+	if err != nil {
+		return nil, err
+	}
+	d := &streamDecoder{
+		entries: entries,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+}
+
+// HasNext implements the EventBatchDecoder interface
+func (d *streamDecoder) HasNext() (model.MessageType, bool, error) {
+	for {
+		if len(d.rowDatas) != 0 {
+			return model.MessageTypeRow, true, nil
+		}
+		if len(d.entries) == 0 {
+			ok, err := d.readPacket()
+			if err != nil || !ok {
+				return model.MessageTypeUnknown, false, err
+			}
+			continue
+		}
+
+		data := d.entries[0]
+		d.entries = d.entries[1:]
+		entry := &canal.Entry{}
+		if err := proto.Unmarshal(data, entry); err != nil {
+			return model.MessageTypeUnknown, false,
+				cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+		}
+		if entry.GetEntryType() == canal.EntryType_TRANSACTIONEND {
+			ts, ok, err := watermarkTsFromHeader(entry.GetHeader())
+			if err != nil {
+				return model.MessageTypeUnknown, false, err
+			}
+			if ok {
+				d.header = entry.GetHeader()
+				d.watermarkTs = ts
+				return model.MessageTypeResolved, true, nil
+			}
+		}
+		// only the row data entries carry the change events.
+		if entry.GetEntryType() != canal.EntryType_ROWDATA {
+			continue
+		}
+		rowChange := &canal.RowChange{}
+		if err := proto.Unmarshal(entry.GetStoreValue(), rowChange); err != nil {
+			return model.MessageTypeUnknown, false,
+				cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+		}
+		d.header = entry.GetHeader()
+		if rowChange.GetIsDdl() {
+			d.rowChange = rowChange
+			return model.MessageTypeDDL, true, nil
+		}
+		d.rowDatas = rowChange.GetRowDatas()
+	}
+}
+
+// readPacket reads the next framed packet from the stream, and returns false
+// if the stream ends at the boundary of packets.
+func (d *streamDecoder) readPacket() (bool, error) {
+	if d.reader == nil {
+		return false, nil
+	}
+	var lengthBuf [packetLengthSize]byte
+	if _, err := io.ReadFull(d.reader, lengthBuf[:]); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return false, cerror.ErrCanalDecodeFailed.GenWithStack(
+				"truncated packet length at the end of the stream")
+		}
+		return false, cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+
+	length := binary.BigEndian.Uint32(lengthBuf[:])
+	if length > d.maxPacketSize {
+		return false, cerror.ErrCanalDecodeFailed.GenWithStack(
+			"packet length %d exceeds the max packet size %d", length, d.maxPacketSize)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(d.reader, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, cerror.ErrCanalDecodeFailed.GenWithStack(
+				"truncated packet at the end of the stream, expected %d bytes", length)
+		}
+		return false, cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+
+	entries, err := decodePacket(data)
+	if err != nil {
+		return false, err
+	}
+	d.entries = entries
+	return true, nil
+}
+
+// decodePacket returns the entries carried by the canal packet.
+func decodePacket(data []byte) ([][]byte, error) {
+	packet := &canal.Packet{}
+	if err := proto.Unmarshal(data, packet); err != nil {
+		return nil, cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+	if packet.GetType() != canal.PacketType_MESSAGES {
+		return nil, cerror.ErrCanalDecodeFailed.GenWithStack(
+			"unexpected packet type %s", packet.GetType())
+	}
+	messages := &canal.Messages{}
+	if err := proto.Unmarshal(packet.GetBody(), messages); err != nil {
+		return nil, cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+	}
+	return messages.GetMessages(), nil
+}
+
+// NextRowChangedEvent implements the EventBatchDecoder interface
+// `HasNext` should be called before this.
+func (d *streamDecoder) NextRowChangedEvent() (*model.RowChangedEvent, error) {
+	if len(d.rowDatas) == 0 {
+		return nil, cerror.ErrCanalDecodeFailed.
+			GenWithStack("not found row changed event message")
+	}
+	result := canalRowData2RowChangedEvent(d.header, d.rowDatas[0])
+	d.rowDatas = d.rowDatas[1:]
+	if len(d.rowDatas) == 0 {
+		d.header = nil
+	}
+	return result, nil
+}
+
+// NextDDLEvent implements the EventBatchDecoder interface
+// `HasNext` should be called before this.
+func (d *streamDecoder) NextDDLEvent() (*model.DDLEvent, error) {
+	if d.rowChange == nil {
+		return nil, cerror.ErrCanalDecodeFailed.
+			GenWithStack("not found ddl event message")
+	}
+	result, err := canalRowChange2DDLEvent(d.header, d.rowChange, d.ddlCompressionDicts...)
+	if err != nil {
+		return nil, err
+	}
+	d.header, d.rowChange = nil, nil
+	return result, nil
+}
+
+// NextResolvedEvent implements the EventBatchDecoder interface
+// `HasNext` should be called before this. The resolved event is the watermark
+// of the table in the header, which is encoded only if the table watermark is
+// enabled.
+func (d *streamDecoder) NextResolvedEvent() (uint64, error) {
+	if d.watermarkTs == 0 {
+		return 0, cerror.ErrCanalDecodeFailed.
+			GenWithStack("not found resolved event message")
+	}
+	ts := d.watermarkTs
+	d.header, d.watermarkTs = nil, 0
+	return ts, nil
+}
+
+// watermarkTsFromHeader returns the ts of the table-scoped watermark carried
+// by the header, and false if the header does not carry one.
+func watermarkTsFromHeader(header *canal.Header) (uint64, bool, error) {
+	for _, p := range header.GetProps() {
+		if p.GetKey() != propWatermarkTs {
+			continue
+		}
+		ts, err := strconv.ParseUint(p.GetValue(), 10, 64)
+		if err != nil {
+			return 0, false, cerror.WrapError(cerror.ErrCanalDecodeFailed, err)
+		}
+		return ts, true, nil
+	}
+	return 0, false, nil
+}
+
+func canalRowData2RowChangedEvent(
+	header *canal.Header, rowData *canal.RowData,
+) *model.RowChangedEvent {
+	// we lost the commitTs from canal message
+	result := &model.RowChangedEvent{
+		Table: &model.TableName{
+			Schema: header.GetSchemaName(),
+			Table:  header.GetTableName(),
+		},
+	}
+	switch header.GetEventType() {
+	case canal.EventType_DELETE:
+		result.PreColumns = canalColumns2RowChangeColumns(rowData.GetBeforeColumns())
+	case canal.EventType_UPDATE:
+		result.PreColumns = canalColumns2RowChangeColumns(rowData.GetBeforeColumns())
+		result.Columns = canalColumns2RowChangeColumns(rowData.GetAfterColumns())
+	default:
+		result.Columns = canalColumns2RowChangeColumns(rowData.GetAfterColumns())
+	}
+	return result
+}
+
+func canalColumns2RowChangeColumns(columns []*canal.Column) []*model.Column {
+	if len(columns) == 0 {
+		return nil
+	}
+	result := make([]*model.Column, 0, len(columns))
+	for _, c := range columns {
+		var value interface{}
+		if !c.GetIsNull() {
+			value = c.GetValue()
+		}
+		mysqlType := types.StrToType(trimUnsignedFromMySQLType(c.GetMysqlType()))
+		col := internal.NewColumn(value, mysqlType).
+			ToCanalJSONFormatColumn(c.GetName(), internal.JavaSQLType(c.GetSqlType()))
+		if strings.HasSuffix(c.GetMysqlType(), " unsigned") {
+			col.Flag.SetIsUnsigned()
+		}
+		if c.GetIsKey() {
+			col.Flag.SetIsHandleKey()
+			col.Flag.SetIsPrimaryKey()
+		}
+		result = append(result, col)
+	}
+	return result
+}
+
+func canalRowChange2DDLEvent(
+	header *canal.Header, rowChange *canal.RowChange, dicts ...[]byte,
+) (*model.DDLEvent, error) {
+	query, err := DecodeDDLQuery(header, rowChange, dicts...)
+	if err != nil {
+		return nil, err
+	}
+	// we lost the startTs and commitTs from canal message
+	result := &model.DDLEvent{
+		Query: query,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{
+				Schema: header.GetSchemaName(),
+				Table:  header.GetTableName(),
+			},
+		},
+	}
+	// hack the DDL Type to be compatible with MySQL sink's logic
+	result.Type = getDDLActionType(result.Query)
+	return result, nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeStream encodes the events into a stream of framed canal packets,
+// the row events are put into one packet, and each DDL event is a packet.
+func encodeStream(t *testing.T, rows []*model.RowChangedEvent, ddls []*model.DDLEvent) []byte {
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnablePacketFraming = true
+	encoder := newBatchEncoder(codecConfig)
+	var buf bytes.Buffer
+	for _, ddl := range ddls {
+		msg, err := encoder.EncodeDDLEvent(ddl)
+		require.Nil(t, err)
+		buf.Write(msg.Value)
+	}
+	for _, row := range rows {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
+		require.Nil(t, err)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	for _, msg := range msgs {
+		buf.Write(msg.Value)
+	}
+	return buf.Bytes()
+}
+
+func TestStreamDecoder(t *testing.T) {
+	t.Parallel()
+
+	expectedDecodedValue := collectExpectedDecodedValue(testColumnsTable)
+	stream := encodeStream(t,
+		[]*model.RowChangedEvent{testCaseInsert, testCaseUpdate, testCaseDelete},
+		[]*model.DDLEvent{testCaseDDL})
+
+	// the stream is read by a reader which returns one byte at a time,
+	// to make sure the partial reads are handled.
+	decoder := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(stream)))
+
+	ty, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	require.Equal(t, model.MessageTypeDDL, ty)
+	ddl, err := decoder.NextDDLEvent()
+	require.Nil(t, err)
+	require.Equal(t, testCaseDDL.TableInfo, ddl.TableInfo)
+	require.Equal(t, testCaseDDL.Query, ddl.Query)
+
+	for _, expected := range []*model.RowChangedEvent{testCaseInsert, testCaseUpdate, testCaseDelete} {
+		ty, hasNext, err = decoder.HasNext()
+		require.Nil(t, err)
+		require.True(t, hasNext)
+		require.Equal(t, model.MessageTypeRow, ty)
+
+		row, err := decoder.NextRowChangedEvent()
+		require.Nil(t, err)
+		require.Equal(t, expected.Table, row.Table)
+		require.Equal(t, expected.IsInsert(), row.IsInsert())
+		require.Equal(t, expected.IsUpdate(), row.IsUpdate())
+		require.Equal(t, expected.IsDelete(), row.IsDelete())
+		for _, col := range append(row.Columns, row.PreColumns...) {
+			value, ok := expectedDecodedValue[col.Name]
+			require.True(t, ok)
+			require.Equal(t, value, col.Value)
+		}
+	}
+
+	ty, hasNext, err = decoder.HasNext()
+	require.Nil(t, err)
+	require.False(t, hasNext)
+	require.Equal(t, model.MessageTypeUnknown, ty)
+
+	_, err = decoder.NextRowChangedEvent()
+	require.NotNil(t, err)
+}
+
+func TestStreamDecoderTruncated(t *testing.T) {
+	t.Parallel()
+
+	stream := encodeStream(t, []*model.RowChangedEvent{testCaseInsert}, []*model.DDLEvent{testCaseDDL})
+
+	// the last packet is truncated.
+	decoder := NewStreamDecoder(bytes.NewReader(stream[:len(stream)-1]))
+	_, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	_, err = decoder.NextDDLEvent()
+	require.Nil(t, err)
+
+	_, hasNext, err = decoder.HasNext()
+	require.ErrorContains(t, err, "truncated packet at the end of the stream")
+	require.False(t, hasNext)
+
+	// the length prefix is truncated.
+	decoder = NewStreamDecoder(bytes.NewReader(stream[:packetLengthSize-1]))
+	_, hasNext, err = decoder.HasNext()
+	require.ErrorContains(t, err, "truncated packet length at the end of the stream")
+	require.False(t, hasNext)
+
+	// an empty stream has no events.
+	decoder = NewStreamDecoder(bytes.NewReader(nil))
+	_, hasNext, err = decoder.HasNext()
+	require.Nil(t, err)
+	require.False(t, hasNext)
+}
+
+func TestStreamDecoderMaxPacketSize(t *testing.T) {
+	t.Parallel()
+
+	stream := encodeStream(t, []*model.RowChangedEvent{testCaseInsert}, nil)
+	length := binary.BigEndian.Uint32(stream[:packetLengthSize])
+
+	// a corrupted length prefix is rejected before the packet is read.
+	corrupted := append([]byte{}, stream...)
+	binary.BigEndian.PutUint32(corrupted, math.MaxUint32)
+	decoder := NewStreamDecoder(bytes.NewReader(corrupted))
+	_, hasNext, err := decoder.HasNext()
+	require.ErrorContains(t, err, "exceeds the max packet size")
+	require.False(t, hasNext)
+
+	decoder = NewStreamDecoder(bytes.NewReader(stream), WithMaxPacketSize(length-1))
+	_, hasNext, err = decoder.HasNext()
+	require.ErrorContains(t, err, "exceeds the max packet size")
+	require.False(t, hasNext)
+
+	decoder = NewStreamDecoder(bytes.NewReader(stream), WithMaxPacketSize(length))
+	_, hasNext, err = decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+}
+
+func TestPacketFraming(t *testing.T) {
+	t.Parallel()
+
+	plainConfig := common.NewConfig(config.ProtocolCanal)
+	framedConfig := common.NewConfig(config.ProtocolCanal)
+	framedConfig.EnablePacketFraming = true
+	framedConfig.EnableTableWatermark = true
+
+	// the output is unframed by default, and the framed one is the same
+	// packet prefixed by its length.
+	plain, err := newBatchEncoder(plainConfig).EncodeDDLEvent(testCaseDDL)
+	require.Nil(t, err)
+	framed, err := newBatchEncoder(framedConfig).EncodeDDLEvent(testCaseDDL)
+	require.Nil(t, err)
+	require.Equal(t, uint32(len(plain.Value)), binary.BigEndian.Uint32(framed.Value))
+	require.Equal(t, plain.Value, framed.Value[packetLengthSize:])
+
+	// all kinds of the messages are framed, so they can be concatenated.
+	encoder := newBatchEncoder(framedConfig)
+	var buf bytes.Buffer
+	heartbeat, err := encoder.(*BatchEncoder).EncodeHeartbeat(testCaseInsert.CommitTs)
+	require.Nil(t, err)
+	buf.Write(heartbeat.Value)
+	err = encoder.AppendRowChangedEvent(context.Background(), "", testCaseInsert, nil)
+	require.Nil(t, err)
+	_, err = encoder.EncodeCheckpointEvent(testCaseInsert.CommitTs)
+	require.Nil(t, err)
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	for _, msg := range msgs {
+		buf.Write(msg.Value)
+	}
+	buf.Write(framed.Value)
+
+	decoder := NewStreamDecoder(&buf)
+	ty, hasNext, err := decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	require.Equal(t, model.MessageTypeRow, ty)
+	row, err := decoder.NextRowChangedEvent()
+	require.Nil(t, err)
+	require.Equal(t, testCaseInsert.Table, row.Table)
+	require.True(t, row.IsInsert())
+
+	ty, hasNext, err = decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	require.Equal(t, model.MessageTypeResolved, ty)
+	ts, err := decoder.NextResolvedEvent()
+	require.Nil(t, err)
+	require.Equal(t, testCaseInsert.CommitTs, ts)
+
+	ty, hasNext, err = decoder.HasNext()
+	require.Nil(t, err)
+	require.True(t, hasNext)
+	require.Equal(t, model.MessageTypeDDL, ty)
+	ddl, err := decoder.NextDDLEvent()
+	require.Nil(t, err)
+	require.Equal(t, testCaseDDL.Query, ddl.Query)
+
+	_, hasNext, err = decoder.HasNext()
+	require.Nil(t, err)
+	require.False(t, hasNext)
+}
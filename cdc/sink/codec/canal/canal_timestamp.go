@@ -0,0 +1,42 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"time"
+
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// canalTimestamp returns the physical time of the ts plus the offset in the
+// precision. Since the physical time of the ts is in milliseconds, the offset
+// is truncated to milliseconds, and the timestamp in microseconds is always a
+// multiple of 1000.
+func canalTimestamp(ts uint64, precision string, offset time.Duration) int64 {
+	physical := convertToCanalTs(ts) + offset.Milliseconds()
+	if precision == common.TimestampPrecisionMicrosecond {
+		return physical * int64(time.Millisecond/time.Microsecond)
+	}
+	return physical
+}
+
+// canalBuildTime returns the time the message is built in the precision,
+// the finer part of the time is truncated instead of rounded, so the build
+// time never goes past the time the message is built.
+func canalBuildTime(now time.Time, precision string) int64 {
+	if precision == common.TimestampPrecisionMicrosecond {
+		return now.UnixMicro()
+	}
+	return now.UnixMilli()
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestCanalTimestamp(t *testing.T) {
+	t.Parallel()
+
+	// the logical part of the ts does not affect the physical time.
+	ts := oracle.ComposeTS(1667385600123, 42)
+	require.Equal(t, int64(1667385600123), canalTimestamp(ts, common.TimestampPrecisionMillisecond, 0))
+	require.Equal(t, int64(1667385600123), canalTimestamp(ts, "", 0))
+	require.Equal(t, int64(1667385600123000), canalTimestamp(ts, common.TimestampPrecisionMicrosecond, 0))
+
+	// the finer part is truncated, even if it's rounded up otherwise.
+	now := time.Unix(1667385600, 123999999)
+	require.Equal(t, int64(1667385600123), canalBuildTime(now, common.TimestampPrecisionMillisecond))
+	require.Equal(t, int64(1667385600123999), canalBuildTime(now, common.TimestampPrecisionMicrosecond))
+}
+
+func TestTimestampPrecision(t *testing.T) {
+	t.Parallel()
+
+	e := *testCaseInsert
+	e.CommitTs = oracle.ComposeTS(1667385600123, 7)
+	for _, c := range []struct {
+		precision string
+		expected  int64
+	}{
+		{common.TimestampPrecisionMillisecond, 1667385600123},
+		{common.TimestampPrecisionMicrosecond, 1667385600123000},
+	} {
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.TimestampPrecision = c.precision
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(&e)
+		require.NoError(t, err)
+		require.Equal(t, c.expected, entry.GetHeader().GetExecuteTime())
+
+		codecConfig = common.NewConfig(config.ProtocolCanalJSON)
+		codecConfig.TimestampPrecision = c.precision
+		encoder := NewJSONBatchEncoderBuilder(codecConfig).Build()
+		before := canalBuildTime(time.Now(), c.precision)
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", &e, nil))
+		after := canalBuildTime(time.Now(), c.precision)
+		messages, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+
+		var msg JSONMessage
+		require.NoError(t, json.Unmarshal(messages[0].Value, &msg))
+		require.Equal(t, c.expected, msg.ExecutionTime)
+		require.GreaterOrEqual(t, msg.BuildTime, before)
+		require.LessOrEqual(t, msg.BuildTime, after)
+	}
+}
+
+func TestCommitTsOffset(t *testing.T) {
+	t.Parallel()
+
+	physical := time.UnixMilli(1667385600123)
+	e := *testCaseInsert
+	e.CommitTs = oracle.GoTimeToTS(physical)
+	for _, offset := range []time.Duration{-1500 * time.Millisecond, 0, 2 * time.Hour} {
+		expected := physical.Add(offset)
+
+		codecConfig := common.NewConfig(config.ProtocolCanal)
+		codecConfig.CommitTsOffset = offset
+		codecConfig.MessageTimestamp = common.MessageTimestampCommitTs
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(&e)
+		require.NoError(t, err)
+		require.Equal(t, expected.UnixMilli(), entry.GetHeader().GetExecuteTime())
+
+		encoder := newBatchEncoder(codecConfig)
+		require.NoError(t, encoder.AppendRowChangedEvent(context.Background(), "", &e, nil))
+		messages, err := encoder.Build()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.True(t, expected.Equal(messages[0].Timestamp))
+
+		codecConfig = common.NewConfig(config.ProtocolCanalJSON)
+		codecConfig.CommitTsOffset = offset
+		codecConfig.TimestampPrecision = common.TimestampPrecisionMicrosecond
+		jsonEncoder := NewJSONBatchEncoderBuilder(codecConfig).Build()
+		require.NoError(t, jsonEncoder.AppendRowChangedEvent(context.Background(), "", &e, nil))
+		messages, err = jsonEncoder.Build()
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		var msg JSONMessage
+		require.NoError(t, json.Unmarshal(messages[0].Value, &msg))
+		require.Equal(t, expected.UnixMicro(), msg.ExecutionTime)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// appendTimezone stamps the timezone the TIMESTAMP values of the row are
+// formatted in into the header props, so that the consumer knows the instant
+// they represent. Only the row carrying the TIMESTAMP columns is stamped,
+// since the other temporal values are not converted by the timezone.
+func (b *canalEntryBuilder) appendTimezone(h *canal.Header, e *model.RowChangedEvent) {
+	if !b.config.EnableTimezone || !b.featureEnabled(featureTimezone) ||
+		!hasTimestampColumn(e) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propTimezone,
+		Value: b.timezone,
+	})
+}
+
+// hasTimestampColumn returns whether the row carries any TIMESTAMP column.
+func hasTimestampColumn(e *model.RowChangedEvent) bool {
+	for _, columns := range [][]*model.Column{e.Columns, e.PreColumns} {
+		for _, c := range columns {
+			if c != nil && c.Type == mysql.TypeTimestamp {
+				return true
+			}
+		}
+	}
+	return false
+}
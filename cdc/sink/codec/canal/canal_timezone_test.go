@@ -0,0 +1,88 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimezone(t *testing.T) {
+	t.Parallel()
+
+	newRow := func(tp byte, value interface{}) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: 417318403368288260,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+				{Name: "ts", Type: tp, Value: value},
+			},
+		}
+	}
+	// timezone returns the timezone prop of the row entry encoded by the
+	// encoder of the options, and whether it's present.
+	timezone := func(codecConfig *common.Config, e *model.RowChangedEvent, opts ...Option) (string, bool) {
+		encoder := NewBatchEncoderBuilder(codecConfig, opts...).Build().(*BatchEncoder)
+		entry, err := encoder.entryBuilder.fromRowEvent(e)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propTimezone {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.Nil(t, err)
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableTimezone = true
+
+	// the timezone formatting the TIMESTAMP values is emitted.
+	row := newRow(mysql.TypeTimestamp, "2022-11-02 18:40:00")
+	value, ok := timezone(codecConfig, row, WithTimezone(shanghai))
+	require.True(t, ok)
+	require.Equal(t, "Asia/Shanghai", value)
+	value, ok = timezone(codecConfig, row, WithTimezone(time.UTC))
+	require.True(t, ok)
+	require.Equal(t, "UTC", value)
+	value, ok = timezone(codecConfig, row)
+	require.True(t, ok)
+	require.Equal(t, "UTC", value)
+
+	// the TIMESTAMP in the old image of the delete counts.
+	deleted := newRow(mysql.TypeTimestamp, "2022-11-02 18:40:00")
+	deleted.PreColumns, deleted.Columns = deleted.Columns, nil
+	value, ok = timezone(codecConfig, deleted, WithTimezone(shanghai))
+	require.True(t, ok)
+	require.Equal(t, "Asia/Shanghai", value)
+
+	// the prop is omitted if the row carries no TIMESTAMP.
+	_, ok = timezone(codecConfig, newRow(mysql.TypeDatetime, "2022-11-02 18:40:00"), WithTimezone(shanghai))
+	require.False(t, ok)
+
+	// the prop is omitted if disabled.
+	_, ok = timezone(common.NewConfig(config.ProtocolCanal), row, WithTimezone(shanghai))
+	require.False(t, ok)
+	codecConfig.FeatureLevel = 2
+	_, ok = timezone(codecConfig, row, WithTimezone(shanghai))
+	require.False(t, ok)
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// txnKey identifies the rows of a transaction in a table.
+type txnKey struct {
+	table    model.TableName
+	startTs  uint64
+	commitTs uint64
+}
+
+func newTxnKey(e *model.RowChangedEvent) txnKey {
+	return txnKey{
+		table:    model.TableName{Schema: e.Table.Schema, Table: e.Table.Table},
+		startTs:  e.StartTs,
+		commitTs: e.CommitTs,
+	}
+}
+
+// pendingRow is a row held until Build,
+// so that the row count of its transaction can be stamped.
+type pendingRow struct {
+	event    *model.RowChangedEvent
+	header   *canal.Header
+	rowData  *canal.RowData
+	callback func()
+}
+
+// checkPendingRow checks the size of the entry of the row to be held, with
+// the row count of its transaction at the max width, so that the row too
+// large fails the append rather than the Build.
+func (d *BatchEncoder) checkPendingRow(header *canal.Header, rowData *canal.RowData) error {
+	props := header.Props
+	defer func() { header.Props = props }()
+	if d.entryBuilder.featureEnabled(featureTxnRowCount) {
+		header.Props = append(props[:len(props):len(props)], &canal.Pair{
+			Key:   propTxnRowCount,
+			Value: strconv.Itoa(math.MaxInt),
+		})
+	}
+	b, err := d.serializer.Serialize(newRowEntry(header, rowData))
+	if err != nil {
+		return serializeError(err)
+	}
+	return checkMessageSize(len(b), d.config)
+}
+
+// flushPendingRows stamps the row count of the transaction into the headers of
+// the pending rows, then appends them to the batch.
+//
+// The transaction is the rows of a table sharing the same start ts and commit
+// ts, and the count is the number of its rows in the batch, so it's only right
+// if the encoder sees the whole transaction in a batch, i.e. it's built at the
+// transaction boundaries. The encoder can only detect a partial transaction
+// after the fact, that is, the transaction continues from the last batch, or
+// it's split into multiple batches by the sink, and the prop is omitted for
+// the rows of such transaction in the batch. Since the count stamped in the
+// earlier batch can not be withdrawn, the transaction row count must not be
+// enabled if the sink may flush partial transactions.
+func (d *BatchEncoder) flushPendingRows() error {
+	counts := make(map[txnKey]int)
+	partial := make(map[txnKey]bool)
+	for _, row := range d.pendingRows {
+		key := newTxnKey(row.event)
+		if counts[key] == 0 {
+			partial[key] = d.lastTxns[key.table] == key
+		} else if row.event.SplitTxn {
+			partial[key] = true
+		}
+		counts[key]++
+	}
+
+	enabled := d.entryBuilder.featureEnabled(featureTxnRowCount)
+	for _, row := range d.pendingRows {
+		key := newTxnKey(row.event)
+		d.lastTxns[key.table] = key
+		if enabled && !partial[key] {
+			row.header.Props = append(row.header.Props, &canal.Pair{
+				Key:   propTxnRowCount,
+				Value: strconv.Itoa(counts[key]),
+			})
+		}
+		if err := d.appendRow(row.event, row.header, row.rowData, row.callback); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	d.pendingRows = nil
+	return nil
+}
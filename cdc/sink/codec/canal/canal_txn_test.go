@@ -0,0 +1,135 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxnRowCount(t *testing.T) {
+	t.Parallel()
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.EnableTxnRowCount = true
+	encoder := newBatchEncoder(codecConfig)
+
+	newRow := func(startTs, commitTs uint64, table string, id int64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			StartTs:  startTs,
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: table},
+			Columns: []*model.Column{{
+				Name:  "id",
+				Type:  mysql.TypeLonglong,
+				Flag:  model.HandleKeyFlag | model.PrimaryKeyFlag,
+				Value: id,
+			}},
+		}
+	}
+	// txnRowCounts returns the txnRowCount prop of the entries in the message,
+	// the absent prop is returned as empty.
+	txnRowCounts := func(msg *common.Message) []string {
+		packet := &canal.Packet{}
+		require.Nil(t, proto.Unmarshal(msg.Value, packet))
+		messages := &canal.Messages{}
+		require.Nil(t, proto.Unmarshal(packet.GetBody(), messages))
+		var result []string
+		for _, data := range messages.GetMessages() {
+			entry := &canal.Entry{}
+			require.Nil(t, proto.Unmarshal(data, entry))
+			count := ""
+			for _, p := range entry.GetHeader().GetProps() {
+				if p.GetKey() == propTxnRowCount {
+					count = p.GetValue()
+				}
+			}
+			result = append(result, count)
+		}
+		return result
+	}
+
+	// a complete transaction of 3 rows, interleaved with a single row
+	// transaction of another table.
+	called := 0
+	for _, row := range []*model.RowChangedEvent{
+		newRow(1, 2, "t1", 1),
+		newRow(1, 2, "t1", 2),
+		newRow(1, 2, "t2", 1),
+		newRow(1, 2, "t1", 3),
+	} {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, func() { called++ })
+		require.Nil(t, err)
+	}
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, 4, msgs[0].GetRowsCount())
+	require.Equal(t, []string{"3", "3", "1", "3"}, txnRowCounts(msgs[0]))
+	msgs[0].Callback()
+	require.Equal(t, 4, called)
+
+	// the transaction of t1 continues from the last batch,
+	// which is partial and the prop is omitted.
+	for _, row := range []*model.RowChangedEvent{
+		newRow(1, 2, "t1", 4),
+		newRow(3, 4, "t1", 1),
+		newRow(3, 4, "t1", 2),
+	} {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
+		require.Nil(t, err)
+	}
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, []string{"", "2", "2"}, txnRowCounts(msgs[0]))
+
+	// the transaction is split by the sink.
+	split := newRow(5, 6, "t1", 2)
+	split.SplitTxn = true
+	for _, row := range []*model.RowChangedEvent{newRow(5, 6, "t1", 1), split} {
+		err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
+		require.Nil(t, err)
+	}
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, []string{"", ""}, txnRowCounts(msgs[0]))
+
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Nil(t, msgs)
+
+	// the row too large fails the append, rather than the Build.
+	codecConfig.MaxMessageBytes = 128
+	encoder = newBatchEncoder(codecConfig)
+	large := newRow(7, 8, "t1", 1)
+	large.Columns = append(large.Columns, &model.Column{
+		Name: "v", Type: mysql.TypeVarchar, Value: make([]byte, 128),
+	})
+	err = encoder.AppendRowChangedEvent(context.Background(), "", large, nil)
+	requireEncodeErrorClass(t, err, cerror.ErrCanalValueTooLarge)
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
+	require.Nil(t, msgs)
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	canal "github.com/pingcap/tiflow/proto/canal"
+)
+
+// activeTables tracks the tables which have had events since the last
+// checkpoint. It's shared by all the encoders built by the same builder,
+// since the rows and the checkpoint are encoded by different encoders.
+type activeTables struct {
+	mu     sync.Mutex
+	tables map[model.TableName]struct{}
+}
+
+func newActiveTables() *activeTables {
+	return &activeTables{
+		tables: make(map[model.TableName]struct{}),
+	}
+}
+
+// add marks the table as active.
+func (a *activeTables) add(table *model.TableName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tables[model.TableName{Schema: table.Schema, Table: table.Table}] = struct{}{}
+}
+
+// drain returns the active tables sorted by name, and resets the tracking.
+func (a *activeTables) drain() []model.TableName {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.tables) == 0 {
+		return nil
+	}
+	result := make([]model.TableName, 0, len(a.tables))
+	for table := range a.tables {
+		result = append(result, table)
+	}
+	a.tables = make(map[model.TableName]struct{})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Schema != result[j].Schema {
+			return result[i].Schema < result[j].Schema
+		}
+		return result[i].Table < result[j].Table
+	})
+	return result
+}
+
+// fromWatermark builds the canal entry carrying the watermark of the table.
+// The watermark is not a change event, so it's a TRANSACTIONEND entry with
+// the ts in the header props.
+func (b *canalEntryBuilder) fromWatermark(ts uint64, table model.TableName) *Entry {
+	header := b.buildHeader(ts, table.Schema, table.Table, canal.EventType_QUERY, -1)
+	header.EventTypePresent = nil
+	header.Props = append(header.Props, &canal.Pair{
+		Key:   propWatermarkTs,
+		Value: strconv.FormatUint(ts, 10),
+	})
+	return &Entry{
+		Header:    header,
+		EntryType: canal.EntryType_TRANSACTIONEND,
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+	"time"
+
+	canal "github.com/pingcap/tiflow/proto/canal"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+// windowID returns the id of the time window the commit ts falls in, which
+// is the number of the windows elapsed since the unix epoch till the physical
+// time of the commit ts. The windows are half-open, i.e. the row committed
+// right at the boundary of two windows is in the later one.
+func windowID(commitTs uint64, windowSize time.Duration) int64 {
+	return oracle.ExtractPhysical(commitTs) / windowSize.Milliseconds()
+}
+
+// appendWindowID stamps the id of the time window of the row into the header
+// props, for the consumers binning the rows into the time windows.
+func (b *canalEntryBuilder) appendWindowID(h *canal.Header, commitTs uint64) {
+	if b.config.WindowSize <= 0 || !b.featureEnabled(featureWindowID) {
+		return
+	}
+	h.Props = append(h.Props, &canal.Pair{
+		Key:   propWindowID,
+		Value: strconv.FormatInt(windowID(commitTs, b.config.WindowSize), 10),
+	})
+}
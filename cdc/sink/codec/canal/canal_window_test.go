@@ -0,0 +1,87 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canal
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/client-go/v2/oracle"
+)
+
+func TestWindowID(t *testing.T) {
+	t.Parallel()
+
+	// the boundary of the windows of the size 1m.
+	boundary := time.Date(2022, 10, 14, 8, 0, 0, 0, time.UTC)
+	commitTs := func(t time.Time, logical int64) uint64 {
+		return oracle.ComposeTS(oracle.GetPhysical(t), logical)
+	}
+	newRow := func(commitTs uint64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "test", Table: "t"},
+			Columns: []*model.Column{
+				{Name: "id", Type: mysql.TypeLong, Flag: model.PrimaryKeyFlag, Value: 1},
+			},
+		}
+	}
+	// windowIDProp returns the windowId prop of the row entry and whether it's present.
+	windowIDProp := func(codecConfig *common.Config, e *model.RowChangedEvent) (string, bool) {
+		entry, err := newCanalEntryBuilder(codecConfig).fromRowEvent(e)
+		require.Nil(t, err)
+		for _, p := range entry.GetHeader().GetProps() {
+			if p.GetKey() == propWindowID {
+				return p.GetValue(), true
+			}
+		}
+		return "", false
+	}
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.WindowSize = time.Minute
+	expected := boundary.Unix() / 60
+
+	// the rows spanning two windows.
+	before, ok := windowIDProp(codecConfig, newRow(commitTs(boundary.Add(-time.Millisecond), 1)))
+	require.True(t, ok)
+	require.Equal(t, strconv.FormatInt(expected-1, 10), before)
+	first, ok := windowIDProp(codecConfig, newRow(commitTs(boundary.Add(-time.Minute), 0)))
+	require.True(t, ok)
+	require.Equal(t, before, first)
+	after, ok := windowIDProp(codecConfig, newRow(commitTs(boundary.Add(time.Second), 0)))
+	require.True(t, ok)
+	require.Equal(t, strconv.FormatInt(expected, 10), after)
+
+	// the row right at the boundary is in the later window, regardless of
+	// the logical part of the commit ts.
+	for _, logical := range []int64{0, 1, 1<<18 - 1} {
+		value, ok := windowIDProp(codecConfig, newRow(commitTs(boundary, logical)))
+		require.True(t, ok)
+		require.Equal(t, after, value)
+	}
+
+	// the prop is omitted if disabled.
+	_, ok = windowIDProp(common.NewConfig(config.ProtocolCanal), newRow(commitTs(boundary, 0)))
+	require.False(t, ok)
+	codecConfig.FeatureLevel = 2
+	_, ok = windowIDProp(codecConfig, newRow(commitTs(boundary, 0)))
+	require.False(t, ok)
+}
@@ -32,10 +32,35 @@ type Config struct {
 	// control batch behavior, only for `open-protocol` and `craft` at the moment.
 	MaxMessageBytes int
 	MaxBatchSize    int
+	// HeadersUnsupported is set if the producer can not produce the record
+	// headers, e.g. the Kafka before 0.11, then the options stamping the
+	// metadata into the headers are rejected, instead of reserving the size
+	// of the headers never produced.
+	HeadersUnsupported bool
+	// PartitionNum is the partition count of the topic which is used to
+	// dispatch the events.
+	PartitionNum int32
 
 	// canal-json only
 	EnableTiDBExtension bool
 
+	// the options of the features, each of which is applied and validated
+	// along with its definition.
+	CanalJSONOptions
+	KeyOptions
+	BatchOptions
+	DDLOptions
+	ProtocolOptions
+	MessageOptions
+	RowOptions
+	SuppressionOptions
+	PropsOptions
+	EntryOptions
+	ColumnOptions
+	NameOptions
+	TimeOptions
+	ImageOptions
+
 	// avro only
 	AvroSchemaRegistry             string
 	AvroDecimalHandlingMode        string
@@ -53,6 +78,27 @@ func NewConfig(protocol config.Protocol) *Config {
 		MaxMessageBytes: config.DefaultMaxMessageBytes,
 		MaxBatchSize:    defaultMaxBatchSize,
 
+		CanalJSONOptions: CanalJSONOptions{
+			JSONControlCharHandling: JSONControlCharSanitize,
+		},
+		SuppressionOptions: defaultSuppressionOptions(protocol),
+		PropsOptions: PropsOptions{
+			FeatureLevel:  FeatureLevelLatest,
+			PropsOverflow: PropsOverflowDrop,
+		},
+		ColumnOptions: ColumnOptions{
+			SchemaMismatch:       SchemaMismatchFallback,
+			BooleanNormalization: BooleanNormalizationNone,
+		},
+		NameOptions: NameOptions{
+			ColumnNameCase:     NameCaseUnchanged,
+			TableQualification: TableQualificationSeparate,
+		},
+		TimeOptions: TimeOptions{
+			MessageTimestamp:   MessageTimestampIngestion,
+			TimestampPrecision: TimestampPrecisionMillisecond,
+		},
+
 		EnableTiDBExtension:            false,
 		AvroSchemaRegistry:             "",
 		AvroDecimalHandlingMode:        "precise",
@@ -67,6 +113,7 @@ const (
 	codecOPTAvroDecimalHandlingMode        = "avro-decimal-handling-mode"
 	codecOPTAvroBigintUnsignedHandlingMode = "avro-bigint-unsigned-handling-mode"
 	codecOPTAvroSchemaRegistry             = "schema-registry"
+	codecOPTPartitionNum                   = "partition-num"
 )
 
 const (
@@ -107,6 +154,14 @@ func (c *Config) Apply(sinkURI *url.URL, config *config.ReplicaConfig) error {
 		c.MaxMessageBytes = a
 	}
 
+	if s := params.Get(codecOPTPartitionNum); s != "" {
+		a, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		c.PartitionNum = int32(a)
+	}
+
 	if s := params.Get(codecOPTAvroDecimalHandlingMode); s != "" {
 		c.AvroDecimalHandlingMode = s
 	}
@@ -115,6 +170,27 @@ func (c *Config) Apply(sinkURI *url.URL, config *config.ReplicaConfig) error {
 		c.AvroBigintUnsignedHandlingMode = s
 	}
 
+	for _, apply := range []func(url.Values) error{
+		c.applyCanalJSONOptions,
+		c.applyKeyOptions,
+		c.applyBatchOptions,
+		c.applyDDLOptions,
+		c.applyProtocolOptions,
+		c.applyMessageOptions,
+		c.applyRowOptions,
+		c.applySuppressionOptions,
+		c.applyPropsOptions,
+		c.applyEntryOptions,
+		c.applyColumnOptions,
+		c.applyNameOptions,
+		c.applyTimeOptions,
+		c.applyImageOptions,
+	} {
+		if err := apply(params); err != nil {
+			return err
+		}
+	}
+
 	if config.Sink != nil && config.Sink.SchemaRegistry != "" {
 		c.AvroSchemaRegistry = config.Sink.SchemaRegistry
 	}
@@ -123,6 +199,10 @@ func (c *Config) Apply(sinkURI *url.URL, config *config.ReplicaConfig) error {
 		c.CSVConfig = config.Sink.CSVConfig
 	}
 
+	if config.Consistent != nil {
+		c.ConsistencyLevel = config.Consistent.Level
+	}
+
 	return nil
 }
 
@@ -132,6 +212,18 @@ func (c *Config) WithMaxMessageBytes(bytes int) *Config {
 	return c
 }
 
+// WithHeadersUnsupported set the `HeadersUnsupported`
+func (c *Config) WithHeadersUnsupported(unsupported bool) *Config {
+	c.HeadersUnsupported = unsupported
+	return c
+}
+
+// WithPartitionNum set the `PartitionNum`
+func (c *Config) WithPartitionNum(num int32) *Config {
+	c.PartitionNum = num
+	return c
+}
+
 // Validate the Config
 func (c *Config) Validate() error {
 	if c.EnableTiDBExtension &&
@@ -141,6 +233,27 @@ func (c *Config) Validate() error {
 		)
 	}
 
+	for _, validate := range []func() error{
+		c.validateCanalJSONOptions,
+		c.validateKeyOptions,
+		c.validateBatchOptions,
+		c.validateDDLOptions,
+		c.validateProtocolOptions,
+		c.validateMessageOptions,
+		c.validateRowOptions,
+		c.validateSuppressionOptions,
+		c.validatePropsOptions,
+		c.validateEntryOptions,
+		c.validateColumnOptions,
+		c.validateNameOptions,
+		c.validateTimeOptions,
+		c.validateImageOptions,
+	} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+
 	if c.Protocol == config.ProtocolAvro {
 		if c.AvroSchemaRegistry == "" {
 			return cerror.ErrCodecInvalidConfig.GenWithStack(
@@ -182,5 +295,11 @@ func (c *Config) Validate() error {
 		)
 	}
 
+	if c.PartitionNum < 0 {
+		return cerror.ErrCodecInvalidConfig.Wrap(
+			errors.Errorf("invalid partition-num %d", c.PartitionNum),
+		)
+	}
+
 	return nil
 }
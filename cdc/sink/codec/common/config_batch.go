@@ -0,0 +1,285 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// BatchOptions are the options of how the canal encoder batches the
+// events into the messages and when it hints the sink to flush them.
+type BatchOptions struct {
+	// EnableBatchTerminator appends a terminator entry to the packet of the
+	// rows, so that the file-based consumers know the batch is complete.
+	EnableBatchTerminator bool
+	// EnableEmptyBatchMarker makes the encoder emit an empty packet if no
+	// event is built, so that the consumer sees a message on each flush.
+	EnableEmptyBatchMarker bool
+	// EnableTableWatermark makes the encoder fan out the checkpoint into a
+	// watermark per table which has had events since the last checkpoint.
+	// Only the MQ sink of v1 supports it.
+	EnableTableWatermark bool
+	// EnableTxnAlignedBatch batches the rows into the messages at the
+	// transaction boundaries only, i.e. each message carries the rows of a
+	// single transaction, exceeding the MaxBatchSize if needed, and the
+	// transaction exceeding the MaxMessageBytes fails the encoding.
+	EnableTxnAlignedBatch bool
+	// EnableInsertGrouping groups the consecutive inserts of a table in a
+	// transaction into a single entry with multiple rows, the group is split
+	// before it exceeds the MaxMessageBytes.
+	EnableInsertGrouping bool
+	// MaxPendingCallbacks is the max number of the callbacks held by the
+	// encoder before it hints the sink to build the batch. 0 means no limit.
+	MaxPendingCallbacks int
+	// DeadLetterRetries is the number of the times to retry encoding the row
+	// failing retryably before it's dead-lettered, see the WithDeadLetter of
+	// the canal encoder. It requires the hook, so the encoder builders
+	// resolved by the protocol reject it.
+	DeadLetterRetries int
+	// MaxBufferedBytes is the max bytes buffered by all the encoders built by
+	// the same builder, the encoder buffering the most bytes is hinted to build
+	// the batch once it's exceeded. 0 means no limit.
+	MaxBufferedBytes int
+	// EnablePacketFraming prefixes each packet with its length, so that the
+	// packets concatenated, e.g. in a file, can be framed by the reader.
+	EnablePacketFraming bool
+	// HeartbeatInterval is the interval for the sink to emit a heartbeat,
+	// 0 means the heartbeat is disabled. Only the MQ sink of v1 supports it.
+	HeartbeatInterval time.Duration
+}
+
+const (
+	codecOPTEnableBatchTerminator  = "enable-batch-terminator"
+	codecOPTEnableEmptyBatchMarker = "enable-empty-batch-marker"
+	codecOPTEnableTableWatermark   = "enable-table-watermark"
+	codecOPTEnableTxnAlignedBatch  = "enable-txn-aligned-batch"
+	codecOPTEnableInsertGrouping   = "enable-insert-grouping"
+	codecOPTMaxPendingCallbacks    = "max-pending-callbacks"
+	codecOPTDeadLetterRetries      = "dead-letter-retries"
+	codecOPTMaxBufferedBytes       = "max-buffered-bytes"
+	codecOPTEnablePacketFraming    = "enable-packet-framing"
+	codecOPTHeartbeatInterval      = "heartbeat-interval"
+)
+
+// applyBatchOptions fills the BatchOptions by the params of the sink URI.
+func (c *Config) applyBatchOptions(params url.Values) error {
+	if s := params.Get(codecOPTEnableBatchTerminator); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableBatchTerminator = b
+	}
+
+	if s := params.Get(codecOPTEnableEmptyBatchMarker); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableEmptyBatchMarker = b
+	}
+
+	if s := params.Get(codecOPTEnableTableWatermark); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableTableWatermark = b
+	}
+
+	if s := params.Get(codecOPTEnableTxnAlignedBatch); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableTxnAlignedBatch = b
+	}
+
+	if s := params.Get(codecOPTEnableInsertGrouping); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableInsertGrouping = b
+	}
+
+	if s := params.Get(codecOPTMaxPendingCallbacks); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.MaxPendingCallbacks = a
+	}
+
+	if s := params.Get(codecOPTDeadLetterRetries); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.DeadLetterRetries = a
+	}
+
+	if s := params.Get(codecOPTMaxBufferedBytes); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.MaxBufferedBytes = a
+	}
+
+	if s := params.Get(codecOPTEnablePacketFraming); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnablePacketFraming = b
+	}
+
+	if s := params.Get(codecOPTHeartbeatInterval); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		c.HeartbeatInterval = d
+	}
+
+	return nil
+}
+
+// validateBatchOptions validates the BatchOptions.
+func (c *Config) validateBatchOptions() error {
+	if c.EnableBatchTerminator {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-batch-terminator only supports canal protocol`,
+			)
+		}
+		// the keyed rows are not batched into a packet.
+		if c.EnableTombstone || c.KeyFormat != "" {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-batch-terminator can not be used with enable-tombstone or key-format`,
+			)
+		}
+	}
+
+	if c.EnableEmptyBatchMarker && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-empty-batch-marker only supports canal protocol`,
+		)
+	}
+
+	if c.EnableTableWatermark && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-table-watermark only supports canal protocol`,
+		)
+	}
+
+	if c.EnableTxnAlignedBatch && c.Protocol != config.ProtocolOpen {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-txn-aligned-batch only supports open-protocol`,
+		)
+	}
+
+	if c.EnableInsertGrouping {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-insert-grouping only supports canal protocol`,
+			)
+		}
+		// the tombstone keys each entry by the row, which is lost by grouping.
+		if c.EnableTombstone {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-insert-grouping can not be used with enable-tombstone`,
+			)
+		}
+		if c.KeyFormat != "" {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-insert-grouping can not be used with key-format`,
+			)
+		}
+	}
+
+	if c.MaxPendingCallbacks != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`max-pending-callbacks only supports canal protocol`,
+			)
+		}
+		if c.MaxPendingCallbacks < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid max-pending-callbacks %d`, c.MaxPendingCallbacks,
+			)
+		}
+	}
+
+	if c.DeadLetterRetries != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`dead-letter-retries only supports canal protocol`,
+			)
+		}
+		if c.DeadLetterRetries < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid dead-letter-retries %d`, c.DeadLetterRetries,
+			)
+		}
+	}
+
+	if c.MaxBufferedBytes != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`max-buffered-bytes only supports canal protocol`,
+			)
+		}
+		if c.MaxBufferedBytes < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid max-buffered-bytes %d`, c.MaxBufferedBytes,
+			)
+		}
+	}
+
+	if c.EnablePacketFraming {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-packet-framing only supports canal protocol`,
+			)
+		}
+		// the tombstone must be a message without value.
+		if c.EnableTombstone {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-packet-framing can not be used with enable-tombstone`,
+			)
+		}
+	}
+
+	if c.HeartbeatInterval != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`heartbeat-interval only supports canal protocol`,
+			)
+		}
+		if c.HeartbeatInterval < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid heartbeat-interval %s`, c.HeartbeatInterval,
+			)
+		}
+	}
+
+	return nil
+}
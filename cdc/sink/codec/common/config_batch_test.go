@@ -0,0 +1,234 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigBatchOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.False(t, c.EnableBatchTerminator)
+				require.False(t, c.EnableEmptyBatchMarker)
+				require.False(t, c.EnablePacketFraming)
+				require.Equal(t, 0, c.MaxPendingCallbacks)
+				require.Equal(t, 0, c.DeadLetterRetries)
+				require.Equal(t, 0, c.MaxBufferedBytes)
+			},
+		},
+		{
+			name:     "enable-table-watermark",
+			protocol: config.ProtocolCanal,
+			query:    "enable-table-watermark=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableTableWatermark)
+			},
+		},
+		{
+			name:        "enable-table-watermark on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-table-watermark=true",
+			validateErr: "enable-table-watermark only supports canal protocol",
+		},
+		{
+			name:     "enable-txn-aligned-batch",
+			protocol: config.ProtocolOpen,
+			query:    "enable-txn-aligned-batch=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableTxnAlignedBatch)
+			},
+		},
+		{
+			name:        "enable-txn-aligned-batch on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-txn-aligned-batch=true",
+			validateErr: "enable-txn-aligned-batch only supports open-protocol",
+		},
+		{
+			name:     "enable-insert-grouping",
+			protocol: config.ProtocolCanal,
+			query:    "enable-insert-grouping=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableInsertGrouping)
+			},
+		},
+		{
+			name:        "enable-insert-grouping with enable-tombstone",
+			protocol:    config.ProtocolCanal,
+			query:       "enable-insert-grouping=true",
+			adjust:      func(c *Config) { c.EnableTombstone = true },
+			validateErr: "enable-insert-grouping can not be used with enable-tombstone",
+		},
+		{
+			name:        "enable-insert-grouping with key-format",
+			protocol:    config.ProtocolCanal,
+			query:       "enable-insert-grouping=true",
+			adjust:      func(c *Config) { c.KeyFormat = KeyFormatJSON },
+			validateErr: "enable-insert-grouping can not be used with key-format",
+		},
+		{
+			name:        "enable-insert-grouping on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-insert-grouping=true",
+			validateErr: "enable-insert-grouping only supports canal protocol",
+		},
+		{
+			name:     "enable-batch-terminator",
+			protocol: config.ProtocolCanal,
+			query:    "enable-batch-terminator=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableBatchTerminator)
+			},
+		},
+		{
+			name:        "enable-batch-terminator with key-format",
+			protocol:    config.ProtocolCanal,
+			query:       "enable-batch-terminator=true",
+			adjust:      func(c *Config) { c.KeyFormat = KeyFormatJSON },
+			validateErr: "enable-batch-terminator can not be used with enable-tombstone or key-format",
+		},
+		{
+			name:        "enable-batch-terminator with enable-tombstone",
+			protocol:    config.ProtocolCanal,
+			query:       "enable-batch-terminator=true",
+			adjust:      func(c *Config) { c.EnableTombstone = true },
+			validateErr: "enable-batch-terminator can not be used with enable-tombstone or key-format",
+		},
+		{
+			name:        "enable-batch-terminator on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-batch-terminator=true",
+			validateErr: "enable-batch-terminator only supports canal protocol",
+		},
+		{
+			name:     "enable-empty-batch-marker",
+			protocol: config.ProtocolCanal,
+			query:    "enable-empty-batch-marker=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableEmptyBatchMarker)
+			},
+		},
+		{
+			name:        "enable-empty-batch-marker on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-empty-batch-marker=true",
+			validateErr: "enable-empty-batch-marker only supports canal protocol",
+		},
+		{
+			name:     "max-pending-callbacks",
+			protocol: config.ProtocolCanal,
+			query:    "max-pending-callbacks=128",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 128, c.MaxPendingCallbacks)
+			},
+		},
+		{
+			name:        "negative max-pending-callbacks",
+			protocol:    config.ProtocolCanal,
+			query:       "max-pending-callbacks=-1",
+			validateErr: "invalid max-pending-callbacks -1",
+		},
+		{
+			name:        "max-pending-callbacks on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "max-pending-callbacks=128",
+			validateErr: "max-pending-callbacks only supports canal protocol",
+		},
+		{
+			name:     "dead-letter-retries",
+			protocol: config.ProtocolCanal,
+			query:    "dead-letter-retries=3",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 3, c.DeadLetterRetries)
+			},
+		},
+		{
+			name:        "negative dead-letter-retries",
+			protocol:    config.ProtocolCanal,
+			query:       "dead-letter-retries=-1",
+			validateErr: "invalid dead-letter-retries -1",
+		},
+		{
+			name:        "dead-letter-retries on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "dead-letter-retries=3",
+			validateErr: "dead-letter-retries only supports canal protocol",
+		},
+		{
+			name:     "max-buffered-bytes",
+			protocol: config.ProtocolCanal,
+			query:    "max-buffered-bytes=67108864",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 67108864, c.MaxBufferedBytes)
+			},
+		},
+		{
+			name:        "negative max-buffered-bytes",
+			protocol:    config.ProtocolCanal,
+			query:       "max-buffered-bytes=-1",
+			validateErr: "invalid max-buffered-bytes -1",
+		},
+		{
+			name:        "max-buffered-bytes on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "max-buffered-bytes=67108864",
+			validateErr: "max-buffered-bytes only supports canal protocol",
+		},
+		{
+			name:     "enable-packet-framing",
+			protocol: config.ProtocolCanal,
+			query:    "enable-packet-framing=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnablePacketFraming)
+			},
+		},
+		{
+			name:        "enable-packet-framing with enable-tombstone",
+			protocol:    config.ProtocolCanal,
+			query:       "enable-packet-framing=true",
+			adjust:      func(c *Config) { c.EnableTombstone = true },
+			validateErr: "enable-packet-framing can not be used with enable-tombstone",
+		},
+		{
+			name:        "enable-packet-framing on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-packet-framing=true",
+			validateErr: "enable-packet-framing only supports canal protocol",
+		},
+		{
+			name:     "heartbeat-interval",
+			protocol: config.ProtocolCanal,
+			query:    "heartbeat-interval=5s",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 5*time.Second, c.HeartbeatInterval)
+			},
+		},
+		{
+			name:        "negative heartbeat-interval",
+			protocol:    config.ProtocolCanal,
+			query:       "heartbeat-interval=-1s",
+			validateErr: "invalid heartbeat-interval -1s",
+		},
+	})
+}
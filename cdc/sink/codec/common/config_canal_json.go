@@ -0,0 +1,93 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// CanalJSONOptions are the options of the canal-json messages.
+type CanalJSONOptions struct {
+	// EnableEmptyImages makes the encoder emit the image absent from the
+	// event as explicitly empty instead of null, i.e. the `old` of INSERT
+	// and DELETE events, since the deleted row is carried by `data`.
+	EnableEmptyImages bool
+	// JSONControlCharHandling is how the encoder handles the BOM and the
+	// control characters in the values, which break the strict JSON parsers,
+	// it's one of JSONControlCharSanitize and JSONControlCharError.
+	JSONControlCharHandling string
+}
+
+const (
+	codecOPTEnableEmptyImages       = "enable-empty-images"
+	codecOPTJSONControlCharHandling = "json-control-char-handling"
+)
+
+const (
+	// JSONControlCharSanitize strips the BOM and the control characters
+	// from the values
+	JSONControlCharSanitize = "sanitize"
+	// JSONControlCharError fails the encoding of the values containing
+	// the BOM or the control characters
+	JSONControlCharError = "error"
+)
+
+// applyCanalJSONOptions fills the CanalJSONOptions by the params of the
+// sink URI.
+func (c *Config) applyCanalJSONOptions(params url.Values) error {
+	if s := params.Get(codecOPTEnableEmptyImages); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableEmptyImages = b
+	}
+
+	if s := params.Get(codecOPTJSONControlCharHandling); s != "" {
+		c.JSONControlCharHandling = s
+	}
+
+	return nil
+}
+
+// validateCanalJSONOptions validates the CanalJSONOptions.
+func (c *Config) validateCanalJSONOptions() error {
+	if c.EnableEmptyImages && c.Protocol != config.ProtocolCanalJSON {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-empty-images only supports canal-json protocol`,
+		)
+	}
+
+	if c.JSONControlCharHandling != "" && c.JSONControlCharHandling != JSONControlCharSanitize {
+		if c.Protocol != config.ProtocolCanalJSON {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`json-control-char-handling only supports canal-json protocol`,
+			)
+		}
+		if c.JSONControlCharHandling != JSONControlCharError {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTJSONControlCharHandling,
+				JSONControlCharSanitize,
+				JSONControlCharError,
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigCanalJSONOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanalJSON,
+			check: func(t *testing.T, c *Config) {
+				require.False(t, c.EnableEmptyImages)
+				require.Equal(t, JSONControlCharSanitize, c.JSONControlCharHandling)
+			},
+		},
+		{
+			name:     "enable-empty-images",
+			protocol: config.ProtocolCanalJSON,
+			query:    "enable-empty-images=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableEmptyImages)
+			},
+		},
+		{
+			name:        "enable-empty-images on canal",
+			protocol:    config.ProtocolCanal,
+			query:       "enable-empty-images=true",
+			validateErr: "enable-empty-images only supports canal-json protocol",
+		},
+		{
+			name:     "json-control-char-handling",
+			protocol: config.ProtocolCanalJSON,
+			query:    "json-control-char-handling=error",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, JSONControlCharError, c.JSONControlCharHandling)
+			},
+		},
+		{
+			name:        "invalid json-control-char-handling",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "json-control-char-handling=escape",
+			validateErr: `json-control-char-handling value could only be "sanitize" or "error"`,
+		},
+		{
+			name:        "json-control-char-handling on canal",
+			protocol:    config.ProtocolCanal,
+			query:       "json-control-char-handling=error",
+			validateErr: "json-control-char-handling only supports canal-json protocol",
+		},
+	})
+}
@@ -0,0 +1,412 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// ColumnOptions are the options of how the canal encoder emits the columns.
+type ColumnOptions struct {
+	// SchemaMismatch is how the encoder handles the row whose columns do not
+	// match the schema of the table, it's one of SchemaMismatchFallback and
+	// SchemaMismatchReject.
+	SchemaMismatch string
+	// EncryptedColumns are the rules of the columns encrypted by the key,
+	// the first rule matching the table of the row applies. The key columns
+	// of the rows are not encrypted, since they route the messages. They
+	// require the Encryptor of the canal encoder, so the encoder builders
+	// resolved by the protocol reject them.
+	EncryptedColumns []EncryptedColumnsRule
+	// NullRepresentation is the value string rendered for null columns,
+	// the isNull flag of the column is always set regardless of it. Since a
+	// non-null column may have the same value, the consumer must rely on the
+	// isNull flag to tell a null from a value equal to the representation.
+	NullRepresentation string
+	// DecimalSeparator is the separator between the integer and the
+	// fractional digits of the decimal and float column values, empty means
+	// the dot.
+	DecimalSeparator string
+	// GroupingSeparator is the separator between the groups of 3 integer
+	// digits of the decimal and float column values, empty means the digits
+	// are not grouped.
+	GroupingSeparator string
+	// MaxColumnValueLength is the max length in bytes of the string and
+	// binary column values, the longer ones are truncated. 0 means no limit.
+	MaxColumnValueLength int
+	// EnableRawStorageValue stamps the raw value stored in TiKV of each
+	// column into the column props, along with the string value.
+	EnableRawStorageValue bool
+	// EnableDeclaredType stamps the type declared in the schema of each
+	// column, e.g. `varchar(255)`, into the column props, along with the
+	// canonical mysqlType.
+	EnableDeclaredType bool
+	// EnableColumnOrdinal sets the index of each column to its zero-based
+	// ordinal position in the current schema of the table.
+	EnableColumnOrdinal bool
+	// EnableAutoGenerated flags the AUTO_INCREMENT and the AUTO_RANDOM
+	// columns in the props, for the consumers merging the streams to avoid
+	// the collisions of the generated keys.
+	EnableAutoGenerated bool
+	// EnableNullability flags whether each column is nullable in the props,
+	// for the consumers generating the strict schemas of the tables.
+	EnableNullability bool
+	// EnableHandle flags the handle columns of each row and the type of the
+	// handle of the table in the props, which are inspected from the schema
+	// of the table, to tell the int handle and the common handle apart.
+	EnableHandle bool
+	// EnableJSONPatch emits the RFC 6902 JSON Patch against the old value
+	// instead of the new value of the JSON columns updated.
+	EnableJSONPatch bool
+	// BooleanNormalization is how the values of the TINYINT(1) columns, which
+	// are the booleans in TiDB, are emitted, it's one of
+	// BooleanNormalizationNone, BooleanNormalizationPassThrough and
+	// BooleanNormalizationStrict. The columns are detected by the display
+	// width in the schema of the table.
+	BooleanNormalization string
+}
+
+const (
+	codecOPTSchemaMismatch        = "schema-mismatch"
+	codecOPTEncryptedColumns      = "encrypted-columns"
+	codecOPTNullRepresentation    = "null-representation"
+	codecOPTDecimalSeparator      = "decimal-separator"
+	codecOPTGroupingSeparator     = "grouping-separator"
+	codecOPTMaxColumnValueLength  = "max-column-value-length"
+	codecOPTEnableRawStorageValue = "enable-raw-storage-value"
+	codecOPTEnableDeclaredType    = "enable-declared-type"
+	codecOPTEnableColumnOrdinal   = "enable-column-ordinal"
+	codecOPTEnableAutoGenerated   = "enable-auto-generated"
+	codecOPTEnableNullability     = "enable-nullability"
+	codecOPTEnableHandle          = "enable-handle"
+	codecOPTEnableJSONPatch       = "enable-json-patch"
+	codecOPTBooleanNormalization  = "boolean-normalization"
+)
+
+const (
+	// BooleanNormalizationNone emits the values of the TINYINT(1) columns
+	// as the numbers
+	BooleanNormalizationNone = "none"
+	// BooleanNormalizationPassThrough emits 0 and 1 of the TINYINT(1) columns
+	// as false and true, and the other values as the numbers
+	BooleanNormalizationPassThrough = "pass-through"
+	// BooleanNormalizationStrict emits 0 and 1 of the TINYINT(1) columns
+	// as false and true, and fails the encoding of the other values
+	BooleanNormalizationStrict = "strict"
+	// SchemaMismatchFallback emits the columns of the row mismatching the
+	// schema without the type enrichment derived from the schema
+	SchemaMismatchFallback = "fallback"
+	// SchemaMismatchReject fails the encoding of the row mismatching the schema
+	SchemaMismatchReject = "reject"
+)
+
+// applyColumnOptions fills the ColumnOptions by the params of the sink URI.
+func (c *Config) applyColumnOptions(params url.Values) error {
+	if s := params.Get(codecOPTSchemaMismatch); s != "" {
+		c.SchemaMismatch = s
+	}
+
+	if s := params.Get(codecOPTEncryptedColumns); s != "" {
+		rules, err := parseEncryptedColumns(s)
+		if err != nil {
+			return err
+		}
+		c.EncryptedColumns = rules
+	}
+
+	if s := params.Get(codecOPTNullRepresentation); s != "" {
+		c.NullRepresentation = s
+	}
+
+	if s := params.Get(codecOPTDecimalSeparator); s != "" {
+		c.DecimalSeparator = s
+	}
+
+	if s := params.Get(codecOPTGroupingSeparator); s != "" {
+		c.GroupingSeparator = s
+	}
+
+	if s := params.Get(codecOPTMaxColumnValueLength); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.MaxColumnValueLength = a
+	}
+
+	if s := params.Get(codecOPTEnableRawStorageValue); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableRawStorageValue = b
+	}
+
+	if s := params.Get(codecOPTEnableDeclaredType); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableDeclaredType = b
+	}
+
+	if s := params.Get(codecOPTEnableColumnOrdinal); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableColumnOrdinal = b
+	}
+
+	if s := params.Get(codecOPTEnableAutoGenerated); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableAutoGenerated = b
+	}
+
+	if s := params.Get(codecOPTEnableNullability); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableNullability = b
+	}
+
+	if s := params.Get(codecOPTEnableHandle); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableHandle = b
+	}
+
+	if s := params.Get(codecOPTEnableJSONPatch); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableJSONPatch = b
+	}
+
+	if s := params.Get(codecOPTBooleanNormalization); s != "" {
+		c.BooleanNormalization = s
+	}
+
+	return nil
+}
+
+// validateColumnOptions validates the ColumnOptions.
+func (c *Config) validateColumnOptions() error {
+	if c.SchemaMismatch != "" && c.SchemaMismatch != SchemaMismatchFallback {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`schema-mismatch only supports canal protocol`,
+			)
+		}
+		if c.SchemaMismatch != SchemaMismatchReject {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTSchemaMismatch,
+				SchemaMismatchFallback,
+				SchemaMismatchReject,
+			)
+		}
+	}
+
+	if len(c.EncryptedColumns) != 0 && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`encrypted-columns only supports canal protocol`,
+		)
+	}
+
+	if c.NullRepresentation != "" && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`null-representation only supports canal protocol`,
+		)
+	}
+
+	if c.DecimalSeparator != "" || c.GroupingSeparator != "" {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`decimal-separator and grouping-separator only support canal protocol`,
+			)
+		}
+		for _, sep := range []struct{ name, value string }{
+			{codecOPTDecimalSeparator, c.DecimalSeparator},
+			{codecOPTGroupingSeparator, c.GroupingSeparator},
+		} {
+			if sep.value != "" && !isNumericSeparator(sep.value) {
+				return cerror.ErrCodecInvalidConfig.GenWithStack(
+					`invalid %s %q, it must be a single character other than the digits and the signs`,
+					sep.name, sep.value,
+				)
+			}
+		}
+		decimalSeparator := c.DecimalSeparator
+		if decimalSeparator == "" {
+			decimalSeparator = "."
+		}
+		if decimalSeparator == c.GroupingSeparator {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`decimal-separator and grouping-separator must be different, but both are %q`,
+				decimalSeparator,
+			)
+		}
+	}
+
+	if c.MaxColumnValueLength != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`max-column-value-length only supports canal protocol`,
+			)
+		}
+		if c.MaxColumnValueLength < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid max-column-value-length %d`, c.MaxColumnValueLength,
+			)
+		}
+	}
+
+	if c.EnableRawStorageValue && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-raw-storage-value only supports canal protocol`,
+		)
+	}
+
+	if c.EnableDeclaredType && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-declared-type only supports canal protocol`,
+		)
+	}
+
+	if c.EnableColumnOrdinal && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-column-ordinal only supports canal protocol`,
+		)
+	}
+
+	if c.EnableAutoGenerated && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-auto-generated only supports canal protocol`,
+		)
+	}
+
+	if c.EnableNullability && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-nullability only supports canal protocol`,
+		)
+	}
+
+	if c.EnableHandle && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-handle only supports canal protocol`,
+		)
+	}
+
+	if c.EnableJSONPatch && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-json-patch only supports canal protocol`,
+		)
+	}
+
+	if c.BooleanNormalization != "" && c.BooleanNormalization != BooleanNormalizationNone {
+		if c.Protocol != config.ProtocolCanal && c.Protocol != config.ProtocolCanalJSON {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`boolean-normalization only supports canal/canal-json protocol`,
+			)
+		}
+		if c.BooleanNormalization != BooleanNormalizationPassThrough &&
+			c.BooleanNormalization != BooleanNormalizationStrict {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s", "%s" or "%s"`,
+				codecOPTBooleanNormalization,
+				BooleanNormalizationNone,
+				BooleanNormalizationPassThrough,
+				BooleanNormalizationStrict,
+			)
+		}
+	}
+
+	return nil
+}
+
+// EncryptedColumnsRule encrypts the columns of the tables matched by the key
+// of the KeyID. The column names are case-insensitive.
+type EncryptedColumnsRule struct {
+	TableMatcher
+	KeyID   string
+	Columns []string
+}
+
+// Contains returns whether the column is listed by the rule. The column name
+// is case-insensitive.
+func (r *EncryptedColumnsRule) Contains(column string) bool {
+	for _, c := range r.Columns {
+		if strings.EqualFold(c, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEncryptedColumns parses the rules of the encrypted columns in the form
+// of `schema.table:key-id:column,...` separated by semicolon, e.g.
+// `test.users:k1:email,phone;test.*:k2:ssn`.
+func parseEncryptedColumns(s string) ([]EncryptedColumnsRule, error) {
+	var result []EncryptedColumnsRule
+	for _, item := range strings.Split(s, ";") {
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid encrypted-columns %s`, item)
+		}
+		matcher, err := NewTableMatcher(parts[0])
+		keyID := strings.TrimSpace(parts[1])
+		if err != nil || keyID == "" {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid encrypted-columns %s`, item)
+		}
+		rule := EncryptedColumnsRule{TableMatcher: matcher, KeyID: keyID}
+		for _, column := range strings.Split(parts[2], ",") {
+			column = strings.TrimSpace(column)
+			if column == "" {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`invalid encrypted-columns %s`, item)
+			}
+			rule.Columns = append(rule.Columns, column)
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// isNumericSeparator returns whether the s could separate the digits of a
+// number unambiguously, i.e. it's a single character which is neither a
+// digit nor a sign.
+func isNumericSeparator(s string) bool {
+	if utf8.RuneCountInString(s) != 1 {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r != utf8.RuneError && !unicode.IsDigit(r) && r != '-' && r != '+'
+}
@@ -0,0 +1,220 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigColumnOptions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, SchemaMismatchFallback, c.SchemaMismatch)
+				require.Equal(t, BooleanNormalizationNone, c.BooleanNormalization)
+				require.Empty(t, c.EncryptedColumns)
+				require.Empty(t, c.NullRepresentation)
+				require.Empty(t, c.DecimalSeparator)
+				require.Empty(t, c.GroupingSeparator)
+				require.Equal(t, 0, c.MaxColumnValueLength)
+				require.False(t, c.EnableRawStorageValue)
+				require.False(t, c.EnableDeclaredType)
+				require.False(t, c.EnableColumnOrdinal)
+				require.False(t, c.EnableAutoGenerated)
+				require.False(t, c.EnableNullability)
+				require.False(t, c.EnableHandle)
+				require.False(t, c.EnableJSONPatch)
+			},
+		},
+		{
+			name:     "schema-mismatch",
+			protocol: config.ProtocolCanal,
+			query:    "schema-mismatch=reject",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, SchemaMismatchReject, c.SchemaMismatch)
+			},
+		},
+		{
+			name:        "invalid schema-mismatch",
+			protocol:    config.ProtocolCanal,
+			query:       "schema-mismatch=ignore",
+			validateErr: `schema-mismatch value could only be "fallback" or "reject"`,
+		},
+		{
+			name:        "schema-mismatch on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "schema-mismatch=reject",
+			validateErr: "schema-mismatch only supports canal protocol",
+		},
+		{
+			name:     "encrypted-columns",
+			protocol: config.ProtocolCanal,
+			query:    "encrypted-columns=" + url.QueryEscape("test.users:k1:email, phone;test.*:k2:ssn"),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []EncryptedColumnsRule{
+					{TableMatcher: MustNewTableMatcher("test.users"), KeyID: "k1", Columns: []string{"email", "phone"}},
+					{TableMatcher: MustNewTableMatcher("test.*"), KeyID: "k2", Columns: []string{"ssn"}},
+				}, c.EncryptedColumns)
+				require.True(t, c.EncryptedColumns[1].MatchTable(model.TableName{Schema: "test", Table: "t"}))
+				require.False(t, c.EncryptedColumns[0].MatchTable(model.TableName{Schema: "test", Table: "t"}))
+			},
+		},
+		{
+			name:        "encrypted-columns on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "encrypted-columns=" + url.QueryEscape("test.users:k1:email"),
+			validateErr: "encrypted-columns only supports canal protocol",
+		},
+		{
+			name:     "null-representation",
+			protocol: config.ProtocolCanal,
+			query:    "null-representation=NULL",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "NULL", c.NullRepresentation)
+			},
+		},
+		{
+			name:        "null-representation on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "null-representation=NULL",
+			validateErr: "null-representation only supports canal protocol",
+		},
+		{
+			name:     "decimal-separator and grouping-separator",
+			protocol: config.ProtocolCanal,
+			query:    "decimal-separator=,&grouping-separator=.",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, ",", c.DecimalSeparator)
+				require.Equal(t, ".", c.GroupingSeparator)
+			},
+		},
+		{
+			name:        "same decimal-separator and grouping-separator",
+			protocol:    config.ProtocolCanal,
+			query:       "grouping-separator=.",
+			validateErr: "must be different",
+		},
+		{
+			name:     "non-breaking space grouping-separator",
+			protocol: config.ProtocolCanal,
+			query:    "grouping-separator=" + url.QueryEscape("\u00a0"),
+		},
+		{
+			name:        "invalid grouping-separator",
+			protocol:    config.ProtocolCanal,
+			query:       "grouping-separator=" + url.QueryEscape("  "),
+			validateErr: "invalid grouping-separator",
+		},
+		{
+			name:        "decimal-separator on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "decimal-separator=,",
+			validateErr: "only support canal protocol",
+		},
+		{
+			name:     "max-column-value-length",
+			protocol: config.ProtocolCanal,
+			query:    "max-column-value-length=1024",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 1024, c.MaxColumnValueLength)
+			},
+		},
+		{
+			name:        "negative max-column-value-length",
+			protocol:    config.ProtocolCanal,
+			query:       "max-column-value-length=-1",
+			validateErr: "invalid max-column-value-length -1",
+		},
+		{
+			name:     "boolean-normalization",
+			protocol: config.ProtocolCanal,
+			query:    "boolean-normalization=strict",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, BooleanNormalizationStrict, c.BooleanNormalization)
+			},
+		},
+		{
+			name:     "boolean-normalization on canal-json",
+			protocol: config.ProtocolCanalJSON,
+			query:    "boolean-normalization=strict",
+		},
+		{
+			name:        "invalid boolean-normalization",
+			protocol:    config.ProtocolCanal,
+			query:       "boolean-normalization=lenient",
+			validateErr: `boolean-normalization value could only be "none", "pass-through" or "strict"`,
+		},
+		{
+			name:        "boolean-normalization on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "boolean-normalization=pass-through",
+			validateErr: "boolean-normalization only supports canal/canal-json protocol",
+		},
+	}
+	for _, sep := range []string{",,", "1", "-", "+"} {
+		testCases = append(testCases, configTestCase{
+			name:        "invalid decimal-separator " + sep,
+			protocol:    config.ProtocolCanal,
+			query:       "grouping-separator=.&decimal-separator=" + url.QueryEscape(sep),
+			validateErr: "invalid decimal-separator",
+		})
+	}
+	// the flags of the canal protocol only.
+	for _, flag := range []struct {
+		option  string
+		enabled func(c *Config) bool
+	}{
+		{"enable-raw-storage-value", func(c *Config) bool { return c.EnableRawStorageValue }},
+		{"enable-declared-type", func(c *Config) bool { return c.EnableDeclaredType }},
+		{"enable-column-ordinal", func(c *Config) bool { return c.EnableColumnOrdinal }},
+		{"enable-auto-generated", func(c *Config) bool { return c.EnableAutoGenerated }},
+		{"enable-nullability", func(c *Config) bool { return c.EnableNullability }},
+		{"enable-handle", func(c *Config) bool { return c.EnableHandle }},
+		{"enable-json-patch", func(c *Config) bool { return c.EnableJSONPatch }},
+	} {
+		flag := flag
+		testCases = append(testCases, configTestCase{
+			name:     flag.option,
+			protocol: config.ProtocolCanal,
+			query:    flag.option + "=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, flag.enabled(c))
+			},
+		}, configTestCase{
+			name:        flag.option + " on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       flag.option + "=true",
+			validateErr: flag.option + " only supports canal protocol",
+		})
+	}
+	runConfigTestCases(t, testCases)
+}
+
+func TestParseEncryptedColumns(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"test.users:email", "test.users::email", "users:k1:email", "test.users:k1:a,,b"} {
+		_, err := parseEncryptedColumns(s)
+		require.ErrorContains(t, err, "ErrCodecInvalidConfig", s)
+	}
+}
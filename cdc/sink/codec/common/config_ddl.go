@@ -0,0 +1,232 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/binary"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// DDLOptions are the options of the DDL events.
+type DDLOptions struct {
+	// MaxDDLPerSecond is the max number of the DDL events emitted per second
+	// by all the encoders built by the same builder, the sink waits before
+	// emitting the DDL event once it's exceeded. 0 means no limit.
+	MaxDDLPerSecond float64
+	// EnableDDLClassification stamps whether the DDL changes the existing
+	// rows into each DDL entry.
+	EnableDDLClassification bool
+	// DDLCompressionThreshold is the length in bytes of the DDL query above
+	// which the query is compressed by gzip. 0 means no compression.
+	DDLCompressionThreshold int
+	// DDLCompressionDictionary is the pre-trained zstd dictionary loaded from
+	// the path configured, the query is compressed by zstd with it instead of
+	// gzip if set. The consumer needs the same dictionary to decompress it.
+	DDLCompressionDictionary []byte
+	// BroadcastDDL makes the sink send the DDL to all the partitions of the
+	// topic, rather than the partition zero only, so that the consumer of each
+	// partition applies the DDL.
+	BroadcastDDL bool
+	// SchemaCompatibility is how the DDL changing the schema of the table
+	// incompatibly is handled, it's one of SchemaCompatibilityNone,
+	// SchemaCompatibilityFlag and SchemaCompatibilityError, see
+	// schemaChanges of the canal encoder for the rules.
+	SchemaCompatibility string
+	// NormalizeDDLQuery makes the DDL query emitted single-line, by
+	// collapsing the whitespace and stripping the comments.
+	NormalizeDDLQuery bool
+}
+
+const (
+	codecOPTMaxDDLPerSecond          = "max-ddl-per-second"
+	codecOPTBroadcastDDL             = "broadcast-ddl"
+	codecOPTSchemaCompatibility      = "schema-compatibility"
+	codecOPTEnableDDLClassification  = "enable-ddl-classification"
+	codecOPTDDLCompressionThreshold  = "ddl-compression-threshold"
+	codecOPTDDLCompressionDictionary = "ddl-compression-dictionary"
+	codecOPTNormalizeDDLQuery        = "normalize-ddl-query"
+)
+
+const (
+	// SchemaCompatibilityNone does not check the compatibility of the DDL
+	SchemaCompatibilityNone = "none"
+	// SchemaCompatibilityFlag stamps the compatibility of the DDL into it
+	SchemaCompatibilityFlag = "flag"
+	// SchemaCompatibilityError fails the encoding of the DDL changing the
+	// schema incompatibly
+	SchemaCompatibilityError = "error"
+)
+
+// applyDDLOptions fills the DDLOptions by the params of the sink URI.
+func (c *Config) applyDDLOptions(params url.Values) error {
+	if s := params.Get(codecOPTMaxDDLPerSecond); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		c.MaxDDLPerSecond = f
+	}
+
+	if s := params.Get(codecOPTBroadcastDDL); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.BroadcastDDL = b
+	}
+
+	if s := params.Get(codecOPTSchemaCompatibility); s != "" {
+		c.SchemaCompatibility = s
+	}
+
+	if s := params.Get(codecOPTEnableDDLClassification); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableDDLClassification = b
+	}
+
+	if s := params.Get(codecOPTDDLCompressionThreshold); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.DDLCompressionThreshold = a
+	}
+
+	if s := params.Get(codecOPTDDLCompressionDictionary); s != "" {
+		dict, err := os.ReadFile(s)
+		if err != nil {
+			return cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+		}
+		c.DDLCompressionDictionary = dict
+	}
+
+	if s := params.Get(codecOPTNormalizeDDLQuery); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.NormalizeDDLQuery = b
+	}
+
+	return nil
+}
+
+// validateDDLOptions validates the DDLOptions.
+func (c *Config) validateDDLOptions() error {
+	if c.MaxDDLPerSecond != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`max-ddl-per-second only supports canal protocol`,
+			)
+		}
+		if c.MaxDDLPerSecond < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid max-ddl-per-second %v`, c.MaxDDLPerSecond,
+			)
+		}
+	}
+
+	// the DDL of the other protocols is always broadcast.
+	if c.BroadcastDDL &&
+		c.Protocol != config.ProtocolCanal && c.Protocol != config.ProtocolCanalJSON {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`broadcast-ddl only supports canal/canal-json protocol`,
+		)
+	}
+
+	if c.SchemaCompatibility != "" && c.SchemaCompatibility != SchemaCompatibilityNone {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`schema-compatibility only supports canal protocol`,
+			)
+		}
+		if c.SchemaCompatibility != SchemaCompatibilityFlag &&
+			c.SchemaCompatibility != SchemaCompatibilityError {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s", "%s" or "%s"`,
+				codecOPTSchemaCompatibility,
+				SchemaCompatibilityNone,
+				SchemaCompatibilityFlag,
+				SchemaCompatibilityError,
+			)
+		}
+	}
+
+	if c.EnableDDLClassification && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-ddl-classification only supports canal protocol`,
+		)
+	}
+
+	if c.DDLCompressionThreshold != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`ddl-compression-threshold only supports canal protocol`,
+			)
+		}
+		if c.DDLCompressionThreshold < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid ddl-compression-threshold %d`, c.DDLCompressionThreshold,
+			)
+		}
+	}
+
+	if c.DDLCompressionDictionary != nil {
+		if c.DDLCompressionThreshold == 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`ddl-compression-dictionary requires ddl-compression-threshold`,
+			)
+		}
+		if _, err := ZstdDictionaryID(c.DDLCompressionDictionary); err != nil {
+			return err
+		}
+	}
+
+	if c.NormalizeDDLQuery && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`normalize-ddl-query only supports canal protocol`,
+		)
+	}
+
+	return nil
+}
+
+// zstdDictionaryMagic is the magic number starting a zstd dictionary.
+const zstdDictionaryMagic = 0xEC30A437
+
+// ZstdDictionaryID returns the ID of the zstd dictionary, which is validated
+// by loading it as the zstd encoder does.
+func ZstdDictionaryID(dict []byte) (uint32, error) {
+	if len(dict) < 8 || binary.LittleEndian.Uint32(dict) != zstdDictionaryMagic {
+		return 0, cerror.ErrCodecInvalidConfig.GenWithStack(
+			"invalid ddl-compression-dictionary, not a zstd dictionary")
+	}
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderDict(dict))
+	if err != nil {
+		return 0, cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return 0, cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+	}
+	return binary.LittleEndian.Uint32(dict[4:8]), nil
+}
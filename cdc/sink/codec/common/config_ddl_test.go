@@ -0,0 +1,186 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigDDLOptions(t *testing.T) {
+	t.Parallel()
+
+	dictPath := filepath.Join(t.TempDir(), "ddl.dict")
+	require.NoError(t, os.WriteFile(dictPath, []byte("not a dictionary"), 0o644))
+	missingDictPath := filepath.Join(t.TempDir(), "missing.dict")
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 0, c.DDLCompressionThreshold)
+				require.Empty(t, c.SchemaCompatibility)
+				require.False(t, c.NormalizeDDLQuery)
+			},
+		},
+		{
+			name:     "max-ddl-per-second",
+			protocol: config.ProtocolCanal,
+			query:    "max-ddl-per-second=0.5",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 0.5, c.MaxDDLPerSecond)
+			},
+		},
+		{
+			name:        "negative max-ddl-per-second",
+			protocol:    config.ProtocolCanal,
+			query:       "max-ddl-per-second=-1",
+			validateErr: "invalid max-ddl-per-second -1",
+		},
+		{
+			name:        "max-ddl-per-second on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "max-ddl-per-second=0.5",
+			validateErr: "max-ddl-per-second only supports canal protocol",
+		},
+		{
+			name:     "broadcast-ddl",
+			protocol: config.ProtocolCanalJSON,
+			query:    "broadcast-ddl=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.BroadcastDDL)
+			},
+		},
+		{
+			name:     "broadcast-ddl on canal",
+			protocol: config.ProtocolCanal,
+			query:    "broadcast-ddl=true",
+		},
+		{
+			name:        "broadcast-ddl on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "broadcast-ddl=true",
+			validateErr: "broadcast-ddl only supports canal/canal-json protocol",
+		},
+		{
+			name:     "schema-compatibility",
+			protocol: config.ProtocolCanal,
+			query:    "schema-compatibility=error",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, SchemaCompatibilityError, c.SchemaCompatibility)
+			},
+		},
+		{
+			name:        "invalid schema-compatibility",
+			protocol:    config.ProtocolCanal,
+			query:       "schema-compatibility=pause",
+			validateErr: `schema-compatibility value could only be "none", "flag" or "error"`,
+		},
+		{
+			name:        "schema-compatibility on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "schema-compatibility=flag",
+			validateErr: "schema-compatibility only supports canal protocol",
+		},
+		{
+			name:     "enable-ddl-classification",
+			protocol: config.ProtocolCanal,
+			query:    "enable-ddl-classification=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableDDLClassification)
+			},
+		},
+		{
+			name:        "enable-ddl-classification on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-ddl-classification=true",
+			validateErr: "enable-ddl-classification only supports canal protocol",
+		},
+		{
+			name:     "ddl-compression-threshold",
+			protocol: config.ProtocolCanal,
+			query:    "ddl-compression-threshold=4096",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 4096, c.DDLCompressionThreshold)
+			},
+		},
+		{
+			name:        "negative ddl-compression-threshold",
+			protocol:    config.ProtocolCanal,
+			query:       "ddl-compression-threshold=-1",
+			validateErr: "invalid ddl-compression-threshold -1",
+		},
+		{
+			name:        "ddl-compression-threshold on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "ddl-compression-threshold=4096",
+			validateErr: "ddl-compression-threshold only supports canal protocol",
+		},
+		{
+			name:     "ddl-compression-dictionary",
+			protocol: config.ProtocolCanal,
+			query: "ddl-compression-threshold=1024&ddl-compression-dictionary=" +
+				url.QueryEscape(dictPath),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []byte("not a dictionary"), c.DDLCompressionDictionary)
+			},
+			validateErr: "not a zstd dictionary",
+		},
+		{
+			// the magic of the dictionary without the valid tables.
+			name:     "ddl-compression-dictionary without the tables",
+			protocol: config.ProtocolCanal,
+			query:    "ddl-compression-threshold=1024",
+			adjust: func(c *Config) {
+				c.DDLCompressionDictionary = append([]byte{0x37, 0xa4, 0x30, 0xec, 1, 0, 0, 0}, make([]byte, 32)...)
+			},
+			validateErr: "ErrCodecInvalidConfig",
+		},
+		{
+			name:     "ddl-compression-dictionary without ddl-compression-threshold",
+			protocol: config.ProtocolCanal,
+			adjust: func(c *Config) {
+				c.DDLCompressionDictionary = append([]byte{0x37, 0xa4, 0x30, 0xec, 1, 0, 0, 0}, make([]byte, 32)...)
+			},
+			validateErr: "requires ddl-compression-threshold",
+		},
+		{
+			name:     "missing ddl-compression-dictionary",
+			protocol: config.ProtocolCanal,
+			query: "ddl-compression-threshold=1024&ddl-compression-dictionary=" +
+				url.QueryEscape(missingDictPath),
+			applyErr: missingDictPath,
+		},
+		{
+			name:     "normalize-ddl-query",
+			protocol: config.ProtocolCanal,
+			query:    "normalize-ddl-query=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.NormalizeDDLQuery)
+			},
+		},
+		{
+			name:        "normalize-ddl-query on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "normalize-ddl-query=true",
+			validateErr: "normalize-ddl-query only supports canal protocol",
+		},
+	})
+}
@@ -0,0 +1,446 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// EntryOptions are the options of the metadata stamped into the canal
+// entries.
+type EntryOptions struct {
+	// EnableRoutingHints stamps the routing metadata, such as the partition
+	// count assumed by the dispatcher, into the entry header props.
+	EnableRoutingHints bool
+	// EnableSequence stamps a sequence into each entry, which is strictly
+	// increasing in the changefeed, so that the consumer can order the
+	// entries across partitions.
+	EnableSequence bool
+	// EnableTxnRowCount stamps the row count of the transaction into each
+	// row entry, it requires the encoder to be built at the transaction
+	// boundaries, see the canal BatchEncoder for the details. The sinks flush
+	// the rows by the batches of a partition, regardless of the transactions,
+	// so it's rejected from the sink URI, and only for the callers of the
+	// encoder building at the transaction boundaries.
+	EnableTxnRowCount bool
+	// EnableSQLDigest stamps the digest of the statement producing the row
+	// into each row entry, if the row carries it.
+	EnableSQLDigest bool
+	// EnableConsistencyLevel stamps the ConsistencyLevel into each entry.
+	EnableConsistencyLevel bool
+	// ConsistencyLevel is the consistent level of the changefeed,
+	// which is derived from the replica config.
+	ConsistencyLevel string
+	// ChecksumAlgorithm is the algorithm used to compute the checksum of
+	// each row, empty means no checksum is computed.
+	ChecksumAlgorithm string
+	// WindowSize is the size of the time windows binning the rows by their
+	// commit time, 0 means the rows are not tagged with the window id.
+	WindowSize time.Duration
+	// FirstSeenKeys is the max number of the keys remembered for each table
+	// to tell whether the key of a row is seen for the first time, 0 means
+	// the rows are not flagged. FirstSeenTTL is the interval to forget the
+	// keys remembered, 0 means they are forgotten only if the limit is hit.
+	FirstSeenKeys int
+	FirstSeenTTL  time.Duration
+	// EnableSchemaVersion stamps the version of the table schema encoding
+	// each event into the props, which is the same for the rows of a schema
+	// and changes after the DDL altering the table.
+	EnableSchemaVersion bool
+	// EnableSchemaFingerprint stamps the fingerprint of the column names and
+	// types of each row into the props, which changes only if the schema
+	// changes, for the consumers detecting the schema drift cheaply.
+	EnableSchemaFingerprint bool
+	// SchemaURLBase is the base URL of the schemas hosted externally, the
+	// URL of the schema of each row is stamped into the props, which is
+	// derived from the base and the schema fingerprint, empty means no URL.
+	SchemaURLBase string
+	// EnableGTID stamps the position resembling the MySQL GTID, which is
+	// derived from the changefeed and the commit ts, into each entry, for the
+	// consumers tracking the position of the MySQL binlog replication.
+	EnableGTID bool
+	// EnableMessageID stamps the id derived from the content of each row
+	// change into the props, which is the same for every encoding of the
+	// row change, for the consumers deduplicating the rows resent on retry.
+	EnableMessageID bool
+	// EnableTimezone stamps the timezone the TIMESTAMP values are formatted
+	// in into each row entry carrying them.
+	EnableTimezone bool
+	// RowSize stamps the size in bytes of each row into the props, it's one
+	// of RowSizeStoreValue and RowSizeValues, empty means no size is stamped.
+	RowSize string
+	// ChangedColumns stamps the columns changed by each update into the
+	// props, it's one of ChangedColumnsNames and ChangedColumnsBitmap,
+	// empty means no changed column is stamped.
+	ChangedColumns string
+	// VerifyUpstreamChecksum makes the encoder verify the checksum attached
+	// by the upstream against the encoded columns.
+	VerifyUpstreamChecksum bool
+}
+
+const (
+	codecOPTEnableRoutingHints      = "enable-routing-hints"
+	codecOPTEnableSequence          = "enable-sequence"
+	codecOPTEnableTxnRowCount       = "enable-txn-row-count"
+	codecOPTEnableConsistencyLevel  = "enable-consistency-level"
+	codecOPTEnableSQLDigest         = "enable-sql-digest"
+	codecOPTChecksumAlgorithm       = "checksum-algorithm"
+	codecOPTVerifyUpstreamChecksum  = "verify-upstream-checksum"
+	codecOPTWindowSize              = "window-size"
+	codecOPTFirstSeenKeys           = "first-seen-keys"
+	codecOPTFirstSeenTTL            = "first-seen-ttl"
+	codecOPTEnableSchemaVersion     = "enable-schema-version"
+	codecOPTEnableSchemaFingerprint = "enable-schema-fingerprint"
+	codecOPTSchemaURLBase           = "schema-url-base"
+	codecOPTEnableGTID              = "enable-gtid"
+	codecOPTEnableMessageID         = "enable-message-id"
+	codecOPTEnableTimezone          = "enable-timezone"
+	codecOPTRowSize                 = "row-size"
+	codecOPTChangedColumns          = "changed-columns"
+)
+
+const (
+	// ChecksumAlgorithmCRC32 is the CRC32 (IEEE) checksum algorithm
+	ChecksumAlgorithmCRC32 = "crc32"
+	// ChecksumAlgorithmXXHash is the 64-bit xxHash checksum algorithm
+	ChecksumAlgorithmXXHash = "xxhash"
+	// RowSizeStoreValue measures the row by the serialized row change of
+	// the entry, i.e. the values along with the metadata of the columns.
+	RowSizeStoreValue = "store-value"
+	// RowSizeValues measures the row by the values of the columns only.
+	RowSizeValues = "values"
+	// ChangedColumnsNames lists the names of the columns changed.
+	ChangedColumnsNames = "names"
+	// ChangedColumnsBitmap flags the columns changed in a bitmap indexed by
+	// the position of the columns in the row.
+	ChangedColumnsBitmap = "bitmap"
+)
+
+// applyEntryOptions fills the EntryOptions by the params of the sink URI.
+func (c *Config) applyEntryOptions(params url.Values) error {
+	if s := params.Get(codecOPTEnableRoutingHints); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableRoutingHints = b
+	}
+
+	if s := params.Get(codecOPTEnableSequence); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableSequence = b
+	}
+
+	if s := params.Get(codecOPTEnableTxnRowCount); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		if b {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-txn-row-count is not supported by the sink, ` +
+					`whose batches may be a part of the transactions`,
+			)
+		}
+	}
+
+	if s := params.Get(codecOPTEnableConsistencyLevel); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableConsistencyLevel = b
+	}
+
+	if s := params.Get(codecOPTEnableSQLDigest); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableSQLDigest = b
+	}
+
+	if s := params.Get(codecOPTChecksumAlgorithm); s != "" {
+		c.ChecksumAlgorithm = s
+	}
+
+	if s := params.Get(codecOPTVerifyUpstreamChecksum); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.VerifyUpstreamChecksum = b
+	}
+
+	if s := params.Get(codecOPTWindowSize); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		c.WindowSize = d
+	}
+
+	if s := params.Get(codecOPTFirstSeenKeys); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.FirstSeenKeys = a
+	}
+
+	if s := params.Get(codecOPTFirstSeenTTL); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		c.FirstSeenTTL = d
+	}
+
+	if s := params.Get(codecOPTEnableSchemaVersion); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableSchemaVersion = b
+	}
+
+	if s := params.Get(codecOPTEnableSchemaFingerprint); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableSchemaFingerprint = b
+	}
+
+	if s := params.Get(codecOPTSchemaURLBase); s != "" {
+		c.SchemaURLBase = s
+	}
+
+	if s := params.Get(codecOPTEnableGTID); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableGTID = b
+	}
+
+	if s := params.Get(codecOPTEnableMessageID); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableMessageID = b
+	}
+
+	if s := params.Get(codecOPTEnableTimezone); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableTimezone = b
+	}
+
+	if s := params.Get(codecOPTRowSize); s != "" {
+		c.RowSize = s
+	}
+
+	if s := params.Get(codecOPTChangedColumns); s != "" {
+		c.ChangedColumns = s
+	}
+
+	return nil
+}
+
+// validateEntryOptions validates the EntryOptions.
+func (c *Config) validateEntryOptions() error {
+	if c.EnableRoutingHints && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-routing-hints only supports canal protocol`,
+		)
+	}
+
+	if c.EnableSequence && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-sequence only supports canal protocol`,
+		)
+	}
+
+	if c.EnableTxnRowCount && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-txn-row-count only supports canal protocol`,
+		)
+	}
+
+	if c.EnableConsistencyLevel && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-consistency-level only supports canal protocol`,
+		)
+	}
+
+	if c.EnableSQLDigest && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-sql-digest only supports canal protocol`,
+		)
+	}
+
+	if c.WindowSize != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`window-size only supports canal protocol`,
+			)
+		}
+		// the physical time of the commit ts is in milliseconds.
+		if c.WindowSize < 0 || c.WindowSize%time.Millisecond != 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid window-size %s, it must be a positive multiple of 1ms`, c.WindowSize,
+			)
+		}
+	}
+
+	if c.FirstSeenKeys != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`first-seen-keys only supports canal protocol`,
+			)
+		}
+		if c.FirstSeenKeys < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid first-seen-keys %d`, c.FirstSeenKeys,
+			)
+		}
+	}
+
+	if c.FirstSeenTTL != 0 {
+		if c.FirstSeenKeys == 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`first-seen-ttl requires first-seen-keys to be set`,
+			)
+		}
+		if c.FirstSeenTTL < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid first-seen-ttl %s`, c.FirstSeenTTL,
+			)
+		}
+	}
+
+	if c.EnableSchemaVersion && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-schema-version only supports canal protocol`,
+		)
+	}
+
+	if c.EnableSchemaFingerprint && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-schema-fingerprint only supports canal protocol`,
+		)
+	}
+
+	if c.SchemaURLBase != "" {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`schema-url-base only supports canal protocol`,
+			)
+		}
+		u, err := url.Parse(c.SchemaURLBase)
+		if err != nil || u.Scheme == "" || u.Host == "" ||
+			u.RawQuery != "" || u.Fragment != "" {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid schema-url-base %s`, c.SchemaURLBase,
+			)
+		}
+	}
+
+	if c.EnableGTID && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-gtid only supports canal protocol`,
+		)
+	}
+
+	if c.EnableMessageID && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-message-id only supports canal protocol`,
+		)
+	}
+
+	if c.EnableTimezone && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-timezone only supports canal protocol`,
+		)
+	}
+
+	if c.RowSize != "" {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`row-size only supports canal protocol`,
+			)
+		}
+		if c.RowSize != RowSizeStoreValue && c.RowSize != RowSizeValues {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTRowSize,
+				RowSizeStoreValue,
+				RowSizeValues,
+			)
+		}
+	}
+
+	if c.ChangedColumns != "" {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`changed-columns only supports canal protocol`,
+			)
+		}
+		if c.ChangedColumns != ChangedColumnsNames && c.ChangedColumns != ChangedColumnsBitmap {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTChangedColumns,
+				ChangedColumnsNames,
+				ChangedColumnsBitmap,
+			)
+		}
+	}
+
+	if c.VerifyUpstreamChecksum && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`verify-upstream-checksum only supports canal protocol`,
+		)
+	}
+
+	if c.ChecksumAlgorithm != "" {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`checksum-algorithm only supports canal protocol`,
+			)
+		}
+		if c.ChecksumAlgorithm != ChecksumAlgorithmCRC32 &&
+			c.ChecksumAlgorithm != ChecksumAlgorithmXXHash {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTChecksumAlgorithm,
+				ChecksumAlgorithmCRC32,
+				ChecksumAlgorithmXXHash,
+			)
+		}
+	}
+
+	return nil
+}
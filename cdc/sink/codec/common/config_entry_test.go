@@ -0,0 +1,274 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigEntryOptions(t *testing.T) {
+	t.Parallel()
+
+	consistentConfig := config.GetDefaultReplicaConfig()
+	consistentConfig.Consistent.Level = "eventual"
+
+	testCases := []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.False(t, c.EnableSchemaVersion)
+				require.False(t, c.EnableSchemaFingerprint)
+				require.False(t, c.EnableGTID)
+				require.False(t, c.EnableMessageID)
+				require.False(t, c.EnableTimezone)
+				require.Empty(t, c.SchemaURLBase)
+				require.Empty(t, c.RowSize)
+				require.Empty(t, c.ChangedColumns)
+			},
+		},
+		{
+			name:     "enable-routing-hints",
+			protocol: config.ProtocolCanal,
+			query:    "enable-routing-hints=true&partition-num=3",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableRoutingHints)
+				require.Equal(t, int32(3), c.PartitionNum)
+			},
+		},
+		{
+			name:        "enable-routing-hints on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-routing-hints=true&partition-num=3",
+			validateErr: "enable-routing-hints only supports canal protocol",
+		},
+		{
+			name:     "checksum-algorithm",
+			protocol: config.ProtocolCanal,
+			query:    "checksum-algorithm=xxhash",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, ChecksumAlgorithmXXHash, c.ChecksumAlgorithm)
+			},
+		},
+		{
+			name:        "invalid checksum-algorithm",
+			protocol:    config.ProtocolCanal,
+			query:       "checksum-algorithm=md5",
+			validateErr: `checksum-algorithm value could only be "crc32" or "xxhash"`,
+		},
+		{
+			name:     "enable-txn-row-count",
+			protocol: config.ProtocolCanal,
+			query:    "enable-txn-row-count=true",
+			applyErr: "enable-txn-row-count is not supported by the sink",
+		},
+		{
+			name:     "enable-txn-row-count by the API",
+			protocol: config.ProtocolCanal,
+			adjust:   func(c *Config) { c.EnableTxnRowCount = true },
+		},
+		{
+			name:        "enable-txn-row-count by the API on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			adjust:      func(c *Config) { c.EnableTxnRowCount = true },
+			validateErr: "enable-txn-row-count only supports canal protocol",
+		},
+		{
+			name:          "enable-consistency-level",
+			protocol:      config.ProtocolCanal,
+			query:         "enable-consistency-level=true",
+			replicaConfig: consistentConfig,
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableConsistencyLevel)
+				require.Equal(t, "eventual", c.ConsistencyLevel)
+			},
+		},
+		{
+			name:          "enable-consistency-level on canal-json",
+			protocol:      config.ProtocolCanalJSON,
+			query:         "enable-consistency-level=true",
+			replicaConfig: consistentConfig,
+			validateErr:   "enable-consistency-level only supports canal protocol",
+		},
+		{
+			name:     "verify-upstream-checksum",
+			protocol: config.ProtocolCanal,
+			query:    "verify-upstream-checksum=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.VerifyUpstreamChecksum)
+			},
+		},
+		{
+			name:        "verify-upstream-checksum on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "verify-upstream-checksum=true",
+			validateErr: "verify-upstream-checksum only supports canal protocol",
+		},
+		{
+			name:     "window-size",
+			protocol: config.ProtocolCanal,
+			query:    "window-size=1m",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, time.Minute, c.WindowSize)
+			},
+		},
+		{
+			name:        "negative window-size",
+			protocol:    config.ProtocolCanal,
+			query:       "window-size=-1m",
+			validateErr: "invalid window-size -1m0s",
+		},
+		{
+			name:        "window-size not in milliseconds",
+			protocol:    config.ProtocolCanal,
+			query:       "window-size=1500us",
+			validateErr: "invalid window-size 1.5ms",
+		},
+		{
+			name:        "window-size on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "window-size=1m",
+			validateErr: "window-size only supports canal protocol",
+		},
+		{
+			name:     "first-seen-keys and first-seen-ttl",
+			protocol: config.ProtocolCanal,
+			query:    "first-seen-keys=1024&first-seen-ttl=1h",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 1024, c.FirstSeenKeys)
+				require.Equal(t, time.Hour, c.FirstSeenTTL)
+			},
+		},
+		{
+			name:        "negative first-seen-ttl",
+			protocol:    config.ProtocolCanal,
+			query:       "first-seen-keys=1024&first-seen-ttl=-1h",
+			validateErr: "invalid first-seen-ttl -1h0m0s",
+		},
+		{
+			name:        "negative first-seen-keys",
+			protocol:    config.ProtocolCanal,
+			query:       "first-seen-keys=-1&first-seen-ttl=1h",
+			validateErr: "invalid first-seen-keys -1",
+		},
+		{
+			name:        "first-seen-ttl without first-seen-keys",
+			protocol:    config.ProtocolCanal,
+			query:       "first-seen-ttl=1h",
+			validateErr: "first-seen-ttl requires first-seen-keys to be set",
+		},
+		{
+			name:        "first-seen-keys on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "first-seen-keys=1024&first-seen-ttl=1h",
+			validateErr: "first-seen-keys only supports canal protocol",
+		},
+		{
+			name:     "schema-url-base",
+			protocol: config.ProtocolCanal,
+			query:    "schema-url-base=" + url.QueryEscape("https://schemas.example.com/cdc/"),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "https://schemas.example.com/cdc/", c.SchemaURLBase)
+			},
+		},
+		{
+			name:        "schema-url-base on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "schema-url-base=" + url.QueryEscape("https://schemas.example.com"),
+			validateErr: "schema-url-base only supports canal protocol",
+		},
+		{
+			name:     "row-size",
+			protocol: config.ProtocolCanal,
+			query:    "row-size=values",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, RowSizeValues, c.RowSize)
+			},
+		},
+		{
+			name:        "invalid row-size",
+			protocol:    config.ProtocolCanal,
+			query:       "row-size=all",
+			validateErr: `row-size value could only be "store-value" or "values"`,
+		},
+		{
+			name:        "row-size on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "row-size=store-value",
+			validateErr: "row-size only supports canal protocol",
+		},
+		{
+			name:     "changed-columns",
+			protocol: config.ProtocolCanal,
+			query:    "changed-columns=bitmap",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, ChangedColumnsBitmap, c.ChangedColumns)
+			},
+		},
+		{
+			name:        "invalid changed-columns",
+			protocol:    config.ProtocolCanal,
+			query:       "changed-columns=all",
+			validateErr: `changed-columns value could only be "names" or "bitmap"`,
+		},
+		{
+			name:        "changed-columns on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "changed-columns=names",
+			validateErr: "changed-columns only supports canal protocol",
+		},
+	}
+	for _, base := range []string{"schemas", "/cdc", "https://", "https://a.com/cdc?v=1", "https://a.com/#x"} {
+		testCases = append(testCases, configTestCase{
+			name:        "invalid schema-url-base " + base,
+			protocol:    config.ProtocolCanal,
+			query:       "schema-url-base=" + url.QueryEscape(base),
+			validateErr: "invalid schema-url-base " + base,
+		})
+	}
+	// the flags of the canal protocol only.
+	for _, flag := range []struct {
+		option  string
+		enabled func(c *Config) bool
+	}{
+		{"enable-sequence", func(c *Config) bool { return c.EnableSequence }},
+		{"enable-sql-digest", func(c *Config) bool { return c.EnableSQLDigest }},
+		{"enable-schema-version", func(c *Config) bool { return c.EnableSchemaVersion }},
+		{"enable-schema-fingerprint", func(c *Config) bool { return c.EnableSchemaFingerprint }},
+		{"enable-gtid", func(c *Config) bool { return c.EnableGTID }},
+		{"enable-message-id", func(c *Config) bool { return c.EnableMessageID }},
+		{"enable-timezone", func(c *Config) bool { return c.EnableTimezone }},
+	} {
+		flag := flag
+		testCases = append(testCases, configTestCase{
+			name:     flag.option,
+			protocol: config.ProtocolCanal,
+			query:    flag.option + "=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, flag.enabled(c))
+			},
+		}, configTestCase{
+			name:        flag.option + " on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       flag.option + "=true",
+			validateErr: flag.option + " only supports canal protocol",
+		})
+	}
+	runConfigTestCases(t, testCases)
+}
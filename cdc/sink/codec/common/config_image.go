@@ -0,0 +1,263 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// ImageOptions are the options of the images of the rows emitted.
+type ImageOptions struct {
+	// ShrinkOldImage makes the old image of the rows, i.e. the before columns
+	// of the DELETE and UPDATE, carry only the key columns of the row.
+	ShrinkOldImage bool
+	// OldImageKeyIndex is the name of the unique index whose columns are kept
+	// in the shrunk old image, the handle key is kept if it's empty or the
+	// table does not have the index.
+	OldImageKeyIndex string
+	// ShrinkUpdateOldImage makes the old image of the UPDATE carry only the
+	// primary key columns of the row, to detect the key moves, while the old
+	// image of the DELETE is not shrunk.
+	ShrinkUpdateOldImage bool
+	// OldImageColumns are the rules of the columns kept in the old image of
+	// the UPDATE, the first rule matching the table of the row applies. The
+	// old image carries the primary key columns and the columns of the rule
+	// only, the tables matched by none are not affected.
+	OldImageColumns []OldImageColumnsRule
+	// SoftDeleteColumn and SoftDeleteValue make the encoder emit the DELETE
+	// as the UPDATE setting the column to the value, whose new image is the
+	// old image with the column set, for the consumers of the soft delete.
+	SoftDeleteColumn string
+	SoftDeleteValue  string
+	// KeyOnly makes the encoder emit only the key columns of the rows,
+	// for the consumers interested in which rows are changed only.
+	KeyOnly bool
+	// DeleteImageCompat makes the DELETE carry the same image as the open
+	// protocol, i.e. all the columns of the old image with the null columns
+	// as nulls, regardless of the options shrinking the image or rendering
+	// the nulls.
+	DeleteImageCompat bool
+}
+
+const (
+	codecOPTShrinkOldImage       = "shrink-old-image"
+	codecOPTOldImageKeyIndex     = "old-image-key-index"
+	codecOPTShrinkUpdateOldImage = "shrink-update-old-image"
+	codecOPTOldImageColumns      = "old-image-columns"
+	codecOPTSoftDeleteColumn     = "soft-delete-column"
+	codecOPTSoftDeleteValue      = "soft-delete-value"
+	codecOPTKeyOnly              = "key-only"
+	codecOPTDeleteImageCompat    = "delete-image-compat"
+)
+
+// applyImageOptions fills the ImageOptions by the params of the sink URI.
+func (c *Config) applyImageOptions(params url.Values) error {
+	if s := params.Get(codecOPTShrinkOldImage); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.ShrinkOldImage = b
+	}
+
+	if s := params.Get(codecOPTOldImageKeyIndex); s != "" {
+		c.OldImageKeyIndex = s
+	}
+
+	if s := params.Get(codecOPTShrinkUpdateOldImage); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.ShrinkUpdateOldImage = b
+	}
+
+	if s := params.Get(codecOPTOldImageColumns); s != "" {
+		rules, err := parseOldImageColumns(s)
+		if err != nil {
+			return err
+		}
+		c.OldImageColumns = rules
+	}
+
+	if s := params.Get(codecOPTKeyOnly); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.KeyOnly = b
+	}
+
+	if s := params.Get(codecOPTDeleteImageCompat); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.DeleteImageCompat = b
+	}
+
+	if s := params.Get(codecOPTSoftDeleteColumn); s != "" {
+		c.SoftDeleteColumn = s
+	}
+
+	if s := params.Get(codecOPTSoftDeleteValue); s != "" {
+		c.SoftDeleteValue = s
+	}
+
+	return nil
+}
+
+// validateImageOptions validates the ImageOptions.
+func (c *Config) validateImageOptions() error {
+	if c.ShrinkOldImage && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`shrink-old-image only supports canal protocol`,
+		)
+	}
+
+	if c.OldImageKeyIndex != "" && !c.ShrinkOldImage {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`old-image-key-index requires shrink-old-image to be enabled`,
+		)
+	}
+
+	if c.ShrinkUpdateOldImage {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`shrink-update-old-image only supports canal protocol`,
+			)
+		}
+		if c.ShrinkOldImage {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`shrink-update-old-image can not be used with shrink-old-image`,
+			)
+		}
+	}
+
+	if len(c.OldImageColumns) != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`old-image-columns only supports canal protocol`,
+			)
+		}
+		if c.ShrinkUpdateOldImage {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`old-image-columns can not be used with shrink-update-old-image`,
+			)
+		}
+	}
+
+	if c.SoftDeleteColumn != "" {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`soft-delete-column only supports canal protocol`,
+			)
+		}
+		if c.SoftDeleteValue == "" {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`soft-delete-column requires soft-delete-value to be set`,
+			)
+		}
+	}
+
+	if c.SoftDeleteValue != "" && c.SoftDeleteColumn == "" {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`soft-delete-value requires soft-delete-column to be set`,
+		)
+	}
+
+	if c.KeyOnly {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`key-only only supports canal protocol`,
+			)
+		}
+		// the upstream checksum covers all the columns of the row.
+		if c.VerifyUpstreamChecksum {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`key-only can not be used with verify-upstream-checksum`,
+			)
+		}
+	}
+
+	if c.DeleteImageCompat {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`delete-image-compat only supports canal protocol`,
+			)
+		}
+		// the soft delete emits the DELETE as an UPDATE.
+		if c.SoftDeleteColumn != "" {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`delete-image-compat can not be used with soft-delete-column`,
+			)
+		}
+	}
+
+	return nil
+}
+
+// OldImageColumnsRule keeps the columns in the old image of the UPDATE of the
+// tables matched, e.g. the audit columns. The column names are
+// case-insensitive.
+type OldImageColumnsRule struct {
+	TableMatcher
+	Columns []string
+}
+
+// Contains returns whether the column is listed by the rule. The column name
+// is case-insensitive.
+func (r *OldImageColumnsRule) Contains(column string) bool {
+	for _, c := range r.Columns {
+		if strings.EqualFold(c, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOldImageColumns parses the rules of the old image columns in the form
+// of `schema.table:column,...` separated by semicolon, e.g.
+// `test.orders:price,status;test.*:status`.
+func parseOldImageColumns(s string) ([]OldImageColumnsRule, error) {
+	var result []OldImageColumnsRule
+	for _, item := range strings.Split(s, ";") {
+		colon := strings.IndexByte(item, ':')
+		if colon < 0 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid old-image-columns %s`, item)
+		}
+		matcher, err := NewTableMatcher(item[:colon])
+		if err != nil {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid old-image-columns %s`, item)
+		}
+		rule := OldImageColumnsRule{TableMatcher: matcher}
+		for _, column := range strings.Split(item[colon+1:], ",") {
+			column = strings.TrimSpace(column)
+			if column == "" {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`invalid old-image-columns %s`, item)
+			}
+			rule.Columns = append(rule.Columns, column)
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigImageOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.False(t, c.ShrinkUpdateOldImage)
+				require.Empty(t, c.OldImageColumns)
+				require.False(t, c.KeyOnly)
+				require.False(t, c.DeleteImageCompat)
+			},
+		},
+		{
+			name:     "shrink-old-image and old-image-key-index",
+			protocol: config.ProtocolCanal,
+			query:    "shrink-old-image=true&old-image-key-index=uk_email",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.ShrinkOldImage)
+				require.Equal(t, "uk_email", c.OldImageKeyIndex)
+			},
+		},
+		{
+			name:        "old-image-key-index without shrink-old-image",
+			protocol:    config.ProtocolCanal,
+			query:       "old-image-key-index=uk_email",
+			validateErr: "old-image-key-index requires shrink-old-image to be enabled",
+		},
+		{
+			name:        "shrink-old-image on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "shrink-old-image=true&old-image-key-index=uk_email",
+			validateErr: "shrink-old-image only supports canal protocol",
+		},
+		{
+			name:     "shrink-update-old-image",
+			protocol: config.ProtocolCanal,
+			query:    "shrink-update-old-image=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.ShrinkUpdateOldImage)
+			},
+		},
+		{
+			name:        "shrink-update-old-image with shrink-old-image",
+			protocol:    config.ProtocolCanal,
+			query:       "shrink-update-old-image=true&shrink-old-image=true",
+			validateErr: "shrink-update-old-image can not be used with shrink-old-image",
+		},
+		{
+			name:        "shrink-update-old-image on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "shrink-update-old-image=true",
+			validateErr: "shrink-update-old-image only supports canal protocol",
+		},
+		{
+			name:     "old-image-columns",
+			protocol: config.ProtocolCanal,
+			query:    "old-image-columns=" + url.QueryEscape("shop.orders:price, status;shop.*:status"),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []OldImageColumnsRule{
+					{TableMatcher: MustNewTableMatcher("shop.orders"), Columns: []string{"price", "status"}},
+					{TableMatcher: MustNewTableMatcher("shop.*"), Columns: []string{"status"}},
+				}, c.OldImageColumns)
+				rule := c.OldImageColumns[1]
+				require.True(t, rule.MatchTable(model.TableName{Schema: "shop", Table: "t"}))
+				require.False(t, rule.MatchTable(model.TableName{Schema: "test", Table: "t"}))
+			},
+		},
+		{
+			name:     "old-image-columns with shrink-update-old-image",
+			protocol: config.ProtocolCanal,
+			query: "shrink-update-old-image=true&old-image-columns=" +
+				url.QueryEscape("shop.orders:price"),
+			validateErr: "old-image-columns can not be used with shrink-update-old-image",
+		},
+		{
+			name:        "old-image-columns on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "old-image-columns=" + url.QueryEscape("shop.orders:price"),
+			validateErr: "old-image-columns only supports canal protocol",
+		},
+		{
+			name:     "key-only",
+			protocol: config.ProtocolCanal,
+			query:    "key-only=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.KeyOnly)
+			},
+		},
+		{
+			name:        "key-only with verify-upstream-checksum",
+			protocol:    config.ProtocolCanal,
+			query:       "key-only=true&verify-upstream-checksum=true",
+			validateErr: "key-only can not be used with verify-upstream-checksum",
+		},
+		{
+			name:        "key-only on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "key-only=true",
+			validateErr: "key-only only supports canal protocol",
+		},
+		{
+			name:     "delete-image-compat",
+			protocol: config.ProtocolCanal,
+			query:    "delete-image-compat=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.DeleteImageCompat)
+			},
+		},
+		{
+			name:     "delete-image-compat with soft-delete-column",
+			protocol: config.ProtocolCanal,
+			query: "delete-image-compat=true" +
+				"&soft-delete-column=deleted_at&soft-delete-value=1",
+			validateErr: "delete-image-compat can not be used with soft-delete-column",
+		},
+		{
+			name:        "delete-image-compat on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "delete-image-compat=true",
+			validateErr: "delete-image-compat only supports canal protocol",
+		},
+		{
+			name:     "soft-delete-column and soft-delete-value",
+			protocol: config.ProtocolCanal,
+			query:    "soft-delete-column=deleted_at&soft-delete-value=2022-10-14%2000:00:00",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "deleted_at", c.SoftDeleteColumn)
+				require.Equal(t, "2022-10-14 00:00:00", c.SoftDeleteValue)
+			},
+		},
+		{
+			name:        "soft-delete-column without soft-delete-value",
+			protocol:    config.ProtocolCanal,
+			query:       "soft-delete-column=deleted_at",
+			validateErr: "soft-delete-column requires soft-delete-value to be set",
+		},
+		{
+			name:        "soft-delete-value without soft-delete-column",
+			protocol:    config.ProtocolCanal,
+			query:       "soft-delete-value=2022-10-14%2000:00:00",
+			validateErr: "soft-delete-value requires soft-delete-column to be set",
+		},
+		{
+			name:        "soft-delete-column on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "soft-delete-column=deleted_at&soft-delete-value=2022-10-14%2000:00:00",
+			validateErr: "soft-delete-column only supports canal protocol",
+		},
+	})
+}
+
+func TestParseOldImageColumns(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"shop.orders", "orders:price", "shop.:price", "shop.orders:price,"} {
+		_, err := parseOldImageColumns(s)
+		require.ErrorContains(t, err, "invalid old-image-columns "+s)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// KeyOptions are the options keying the canal messages by the rows.
+type KeyOptions struct {
+	// EnableTombstone makes the encoder key each row by its handle key,
+	// and follow every DELETE with a tombstone for log-compacted topics.
+	EnableTombstone bool
+	// KeyFormat makes the encoder key each row by its handle key serialized
+	// in the format, it's one of KeyFormatJSON and KeyFormatDelimited, empty
+	// means the rows are not keyed unless the tombstone is enabled.
+	KeyFormat string
+	// KeyDelimiter is the delimiter of the KeyFormatDelimited keys, empty
+	// means the vertical bar.
+	KeyDelimiter string
+}
+
+const (
+	codecOPTEnableTombstone = "enable-tombstone"
+	codecOPTKeyFormat       = "key-format"
+	codecOPTKeyDelimiter    = "key-delimiter"
+)
+
+const (
+	// KeyFormatJSON serializes the keys into the JSON objects
+	KeyFormatJSON = "json"
+	// KeyFormatDelimited serializes the keys into the delimited strings
+	KeyFormatDelimited = "delimited"
+)
+
+// applyKeyOptions fills the KeyOptions by the params of the sink URI.
+func (c *Config) applyKeyOptions(params url.Values) error {
+	if s := params.Get(codecOPTEnableTombstone); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableTombstone = b
+	}
+
+	if s := params.Get(codecOPTKeyFormat); s != "" {
+		c.KeyFormat = s
+	}
+
+	if s := params.Get(codecOPTKeyDelimiter); s != "" {
+		c.KeyDelimiter = s
+	}
+
+	return nil
+}
+
+// validateKeyOptions validates the KeyOptions.
+func (c *Config) validateKeyOptions() error {
+	if c.EnableTombstone && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-tombstone only supports canal protocol`,
+		)
+	}
+
+	if c.KeyFormat != "" {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`key-format only supports canal protocol`,
+			)
+		}
+		if c.KeyFormat != KeyFormatJSON && c.KeyFormat != KeyFormatDelimited {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTKeyFormat,
+				KeyFormatJSON,
+				KeyFormatDelimited,
+			)
+		}
+	}
+
+	if c.KeyDelimiter != "" && c.KeyFormat != KeyFormatDelimited {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`key-delimiter requires key-format to be "%s"`, KeyFormatDelimited,
+		)
+	}
+
+	return nil
+}
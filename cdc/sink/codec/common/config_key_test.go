@@ -0,0 +1,68 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigKeyOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.Empty(t, c.KeyFormat)
+				require.Empty(t, c.KeyDelimiter)
+			},
+		},
+		{
+			name:     "key-format and key-delimiter",
+			protocol: config.ProtocolCanal,
+			query:    "key-format=delimited&key-delimiter=:",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, KeyFormatDelimited, c.KeyFormat)
+				require.Equal(t, ":", c.KeyDelimiter)
+			},
+		},
+		{
+			name:        "key-delimiter without the delimited key-format",
+			protocol:    config.ProtocolCanal,
+			query:       "key-format=json&key-delimiter=:",
+			validateErr: `key-delimiter requires key-format to be "delimited"`,
+		},
+		{
+			name:     "json key-format",
+			protocol: config.ProtocolCanal,
+			query:    "key-format=json",
+		},
+		{
+			name:        "invalid key-format",
+			protocol:    config.ProtocolCanal,
+			query:       "key-format=avro",
+			validateErr: `key-format value could only be "json" or "delimited"`,
+		},
+		{
+			name:        "key-format on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "key-format=json",
+			validateErr: "key-format only supports canal protocol",
+		},
+	})
+}
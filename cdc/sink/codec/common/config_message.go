@@ -0,0 +1,338 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// MessageOptions are the options of the messages, which wrap, sign and
+// route them, and stamp the metadata into their headers.
+type MessageOptions struct {
+	// EnableCloudEvents wraps the value of each message in a CloudEvents
+	// envelope in the JSON structured mode, with the payload in base64.
+	EnableCloudEvents bool
+	// EnablePulsarSchema attaches the Pulsar schema info of the canal-json
+	// messages of the rows to them in the header, so that the consumers
+	// bridging the messages into Pulsar register the schema of them against
+	// the schema registry of Pulsar.
+	EnablePulsarSchema bool
+	// ColumnHeaders maps the columns to the headers of the messages, the
+	// value of the column of the rows is copied into the header, so that the
+	// broker routes and filters the messages by it. The column names are in
+	// lower case, and the header is omitted if the value is null.
+	ColumnHeaders map[string]string
+	// EnableNamespace stamps the namespace of the changefeed onto each
+	// message, which is produced in the header of it, so that the consumers
+	// route the messages by the tenant.
+	EnableNamespace bool
+	// RoutingPrefix prefixes the schema and the table routing the messages,
+	// so that the topics of the changefeeds sharing the broker are
+	// namespaced. The names in the payload are not prefixed.
+	RoutingPrefix string
+	// MessageTTLs are the rules of the TTL hint of the messages, the first
+	// rule matching the table and the operation of the events applies, and
+	// no TTL is hinted if none matches.
+	MessageTTLs []MessageTTLRule
+	// EnableMessageSequence stamps a sequence increasing by exactly one onto
+	// each message, so that the consumer of the single partition detects the
+	// loss of the messages by the gaps.
+	EnableMessageSequence bool
+	// SigningKey is the key loaded from the path configured, each message is
+	// signed by it if set, see Signer. SigningKeyID identifies the key to
+	// the consumer, and SigningAlgorithm is the algorithm of the signature.
+	SigningKey       []byte
+	SigningKeyID     string
+	SigningAlgorithm string
+}
+
+const (
+	codecOPTSigningKeyFile        = "signing-key-file"
+	codecOPTSigningKeyID          = "signing-key-id"
+	codecOPTSigningAlgorithm      = "signing-algorithm"
+	codecOPTEnableCloudEvents     = "enable-cloud-events"
+	codecOPTEnablePulsarSchema    = "enable-pulsar-schema"
+	codecOPTColumnHeaders         = "column-headers"
+	codecOPTEnableNamespace       = "enable-namespace"
+	codecOPTMessageTTL            = "message-ttl"
+	codecOPTEnableMessageSequence = "enable-message-sequence"
+	codecOPTRoutingPrefix         = "routing-prefix"
+)
+
+// applyMessageOptions fills the MessageOptions by the params of the sink URI.
+func (c *Config) applyMessageOptions(params url.Values) error {
+	if s := params.Get(codecOPTSigningKeyFile); s != "" {
+		key, err := os.ReadFile(s)
+		if err != nil {
+			return cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+		}
+		c.SigningKey = key
+	}
+
+	if s := params.Get(codecOPTSigningKeyID); s != "" {
+		c.SigningKeyID = s
+	}
+
+	if s := params.Get(codecOPTSigningAlgorithm); s != "" {
+		c.SigningAlgorithm = s
+	}
+
+	if s := params.Get(codecOPTEnableCloudEvents); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableCloudEvents = b
+	}
+
+	if s := params.Get(codecOPTEnablePulsarSchema); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnablePulsarSchema = b
+	}
+
+	if s := params.Get(codecOPTColumnHeaders); s != "" {
+		headers, err := parseColumnHeaders(s)
+		if err != nil {
+			return err
+		}
+		c.ColumnHeaders = headers
+	}
+
+	if s := params.Get(codecOPTEnableNamespace); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableNamespace = b
+	}
+
+	if s := params.Get(codecOPTMessageTTL); s != "" {
+		rules, err := parseMessageTTLs(s)
+		if err != nil {
+			return err
+		}
+		c.MessageTTLs = rules
+	}
+
+	if s := params.Get(codecOPTRoutingPrefix); s != "" {
+		c.RoutingPrefix = s
+	}
+
+	if s := params.Get(codecOPTEnableMessageSequence); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.EnableMessageSequence = b
+	}
+
+	return nil
+}
+
+// validateMessageOptions validates the MessageOptions.
+func (c *Config) validateMessageOptions() error {
+	if c.SigningKey != nil {
+		if c.SigningKeyID == "" {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`signing-key-file requires signing-key-id`,
+			)
+		}
+		if _, err := NewSigner(c.SigningAlgorithm, c.SigningKeyID, c.SigningKey); err != nil {
+			return err
+		}
+	} else if c.SigningKeyID != "" || c.SigningAlgorithm != "" {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`signing-key-id and signing-algorithm require signing-key-file`,
+		)
+	}
+
+	// the prefix ends up in the topic names, so it's limited to the
+	// characters legal in them.
+	for _, r := range c.RoutingPrefix {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) ||
+			r == '.' || r == '_' || r == '-')) {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid routing-prefix %s`, c.RoutingPrefix,
+			)
+		}
+	}
+
+	if c.EnableCloudEvents && c.Protocol != config.ProtocolCanal &&
+		c.Protocol != config.ProtocolCanalJSON {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-cloud-events only supports canal/canal-json protocol`,
+		)
+	}
+
+	if c.EnablePulsarSchema {
+		if c.Protocol != config.ProtocolCanalJSON {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-pulsar-schema only supports canal-json protocol`,
+			)
+		}
+		// the schema describes the canal-json message, not the envelope.
+		if c.EnableCloudEvents {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`enable-pulsar-schema can not be used with enable-cloud-events`,
+			)
+		}
+	}
+
+	if c.HeadersUnsupported {
+		for _, option := range []struct {
+			name    string
+			enabled bool
+		}{
+			{codecOPTEnableNamespace, c.EnableNamespace},
+			{codecOPTMessageTTL, len(c.MessageTTLs) != 0},
+			{codecOPTEnableMessageSequence, c.EnableMessageSequence},
+			{codecOPTEnablePulsarSchema, c.EnablePulsarSchema},
+			{codecOPTColumnHeaders, len(c.ColumnHeaders) != 0},
+			{codecOPTSigningKeyFile, c.SigningKey != nil},
+		} {
+			if option.enabled {
+				return cerror.ErrCodecInvalidConfig.GenWithStack(
+					`%s requires the record headers, which are not supported by the producer`,
+					option.name,
+				)
+			}
+		}
+	}
+
+	// the headers stamped by the encoder are reserved.
+	for _, header := range c.ColumnHeaders {
+		switch header {
+		case HeaderNamespace, HeaderTTL, HeaderSequence, HeaderSchemaInfo,
+			HeaderSignature, HeaderSigningKeyID:
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`the header %s in column-headers is reserved`, header,
+			)
+		}
+	}
+
+	// the gaps of the sequence are only detectable within a partition.
+	if c.EnableMessageSequence && c.PartitionNum > 1 {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`enable-message-sequence requires a single partition, but partition-num is %d`,
+			c.PartitionNum,
+		)
+	}
+
+	return nil
+}
+
+// parseColumnHeaders parses the mapping of the columns to the headers in the
+// form of `column:header` separated by comma, e.g.
+// `tenant_id:x-tenant,region:x-region`. The column names are case-insensitive.
+func parseColumnHeaders(s string) (map[string]string, error) {
+	result := make(map[string]string)
+	headers := make(map[string]struct{})
+	for _, item := range strings.Split(s, ",") {
+		sep := strings.IndexByte(item, ':')
+		if sep <= 0 || sep+1 >= len(item) {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid column-headers %s`, s)
+		}
+		column, header := strings.ToLower(item[:sep]), item[sep+1:]
+		if _, ok := result[column]; ok {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`duplicate column %s in column-headers`, column)
+		}
+		if _, ok := headers[header]; ok {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`duplicate header %s in column-headers`, header)
+		}
+		result[column] = header
+		headers[header] = struct{}{}
+	}
+	return result, nil
+}
+
+// the operations of the MessageTTLRule.
+const (
+	MessageTTLOperationAny    = "*"
+	MessageTTLOperationInsert = "insert"
+	MessageTTLOperationUpdate = "update"
+	MessageTTLOperationDelete = "delete"
+	MessageTTLOperationDDL    = "ddl"
+)
+
+// MessageTTLRule hints the TTL of the messages of the events of the tables
+// and the operation matched. The operation matches any if it's `*`, and the
+// zero TTL hints no TTL.
+type MessageTTLRule struct {
+	TableMatcher
+	Operation string
+	TTL       time.Duration
+}
+
+// Match returns whether the event of the operation on the table is matched.
+func (r *MessageTTLRule) Match(table model.TableName, operation string) bool {
+	return r.MatchTable(table) &&
+		(r.Operation == MessageTTLOperationAny || r.Operation == operation)
+}
+
+// parseMessageTTLs parses the rules of the message TTL in the form of
+// `schema.table:operation:ttl` separated by comma, e.g.
+// `test.cache:*:30s,test.*:delete:1h`. The operation is one of insert, update,
+// delete, ddl and `*`. The tables are matched by the pattern of the table
+// filter, so the dots in the names must be quoted by the backticks.
+func parseMessageTTLs(s string) ([]MessageTTLRule, error) {
+	var result []MessageTTLRule
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid message-ttl %s`, s)
+		}
+		matcher, err := NewTableMatcher(parts[0])
+		if err != nil {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid message-ttl %s`, s)
+		}
+		switch parts[1] {
+		case MessageTTLOperationAny, MessageTTLOperationInsert, MessageTTLOperationUpdate,
+			MessageTTLOperationDelete, MessageTTLOperationDDL:
+		default:
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid operation %s in message-ttl`, parts[1])
+		}
+		ttl, err := time.ParseDuration(parts[2])
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+		}
+		if ttl < 0 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid ttl %s in message-ttl`, parts[2])
+		}
+		result = append(result, MessageTTLRule{
+			TableMatcher: matcher,
+			Operation:    parts[1],
+			TTL:          ttl,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigMessageOptions(t *testing.T) {
+	t.Parallel()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "signing.pem")
+	require.NoError(t, os.WriteFile(keyPath,
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	signing := "signing-key-id=k1&signing-algorithm=ed25519&signing-key-file=" + url.QueryEscape(keyPath)
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanalJSON,
+			check: func(t *testing.T, c *Config) {
+				require.False(t, c.EnableCloudEvents)
+				require.False(t, c.EnablePulsarSchema)
+				require.False(t, c.EnableNamespace)
+				require.False(t, c.EnableMessageSequence)
+				require.Empty(t, c.ColumnHeaders)
+				require.Empty(t, c.MessageTTLs)
+				require.Nil(t, c.SigningKey)
+			},
+		},
+		{
+			name:     "signing-key-file",
+			protocol: config.ProtocolCanal,
+			query:    signing,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "k1", c.SigningKeyID)
+				require.Equal(t, SigningAlgorithmEd25519, c.SigningAlgorithm)
+				require.NotEmpty(t, c.SigningKey)
+			},
+		},
+		{
+			// the PEM key is not the secret of the HMAC, but any bytes are.
+			name:     "signing-key-file with hmac-sha256",
+			protocol: config.ProtocolCanal,
+			query:    signing,
+			adjust:   func(c *Config) { c.SigningAlgorithm = SigningAlgorithmHMACSHA256 },
+		},
+		{
+			name:        "signing-key-file not PEM encoded",
+			protocol:    config.ProtocolCanal,
+			query:       signing,
+			adjust:      func(c *Config) { c.SigningKey = []byte("secret") },
+			validateErr: "the signing key of ed25519 is not PEM encoded",
+		},
+		{
+			name:        "invalid signing-algorithm",
+			protocol:    config.ProtocolCanal,
+			query:       signing,
+			adjust:      func(c *Config) { c.SigningAlgorithm = "rsa" },
+			validateErr: "invalid signing-algorithm rsa",
+		},
+		{
+			name:     "signing-key-file without signing-key-id",
+			protocol: config.ProtocolCanal,
+			query:    "signing-key-file=" + url.QueryEscape(keyPath),
+			adjust: func(c *Config) {
+				c.SigningAlgorithm = SigningAlgorithmEd25519
+			},
+			validateErr: "signing-key-file requires signing-key-id",
+		},
+		{
+			name:        "signing-key-id without signing-key-file",
+			protocol:    config.ProtocolCanal,
+			query:       "signing-key-id=k1&signing-algorithm=hmac-sha256",
+			validateErr: "signing-key-id and signing-algorithm require signing-key-file",
+		},
+		{
+			name:     "missing signing-key-file",
+			protocol: config.ProtocolCanal,
+			query: "signing-key-id=k1&signing-key-file=" +
+				url.QueryEscape(filepath.Join(t.TempDir(), "missing.pem")),
+			applyErr: "ErrCodecInvalidConfig",
+		},
+		{
+			name:     "enable-cloud-events",
+			protocol: config.ProtocolCanalJSON,
+			query:    "enable-cloud-events=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableCloudEvents)
+			},
+		},
+		{
+			name:     "enable-cloud-events on canal",
+			protocol: config.ProtocolCanal,
+			query:    "enable-cloud-events=true",
+		},
+		{
+			name:        "enable-cloud-events on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "enable-cloud-events=true",
+			validateErr: "enable-cloud-events only supports canal/canal-json protocol",
+		},
+		{
+			name:     "enable-pulsar-schema",
+			protocol: config.ProtocolCanalJSON,
+			query:    "enable-pulsar-schema=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnablePulsarSchema)
+			},
+		},
+		{
+			name:        "enable-pulsar-schema with enable-cloud-events",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "enable-pulsar-schema=true&enable-cloud-events=true",
+			validateErr: "enable-pulsar-schema can not be used with enable-cloud-events",
+		},
+		{
+			name:        "enable-pulsar-schema on canal",
+			protocol:    config.ProtocolCanal,
+			query:       "enable-pulsar-schema=true",
+			validateErr: "enable-pulsar-schema only supports canal-json protocol",
+		},
+		{
+			name:     "column-headers",
+			protocol: config.ProtocolCanalJSON,
+			query:    "column-headers=Tenant_ID:x-tenant,region:x-region",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, map[string]string{"tenant_id": "x-tenant", "region": "x-region"},
+					c.ColumnHeaders)
+			},
+		},
+		{
+			name:        "column-headers with reserved header",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "column-headers=shard:" + HeaderSequence,
+			validateErr: "the header sequence in column-headers is reserved",
+		},
+		{
+			// the headers are rejected if the producer can not produce them.
+			name:        "column-headers with headers unsupported",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "column-headers=region:x-region",
+			adjust:      func(c *Config) { c.WithHeadersUnsupported(true) },
+			validateErr: "column-headers requires the record headers, which are not supported by the producer",
+		},
+		{
+			name:     "enable-namespace",
+			protocol: config.ProtocolOpen,
+			query:    "enable-namespace=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableNamespace)
+			},
+		},
+		{
+			name:     "message-ttl",
+			protocol: config.ProtocolOpen,
+			query:    "message-ttl=test.cache:*:30s,test.*:delete:1h,*.*:ddl:0s",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []MessageTTLRule{
+					{TableMatcher: MustNewTableMatcher("test.cache"), Operation: MessageTTLOperationAny, TTL: 30 * time.Second},
+					{TableMatcher: MustNewTableMatcher("test.*"), Operation: MessageTTLOperationDelete, TTL: time.Hour},
+					{TableMatcher: MustNewTableMatcher("*.*"), Operation: MessageTTLOperationDDL, TTL: 0},
+				}, c.MessageTTLs)
+				rule := c.MessageTTLs[1]
+				require.True(t, rule.Match(model.TableName{Schema: "test", Table: "t"}, MessageTTLOperationDelete))
+				require.False(t, rule.Match(model.TableName{Schema: "test", Table: "t"}, MessageTTLOperationInsert))
+				require.False(t, rule.Match(model.TableName{Schema: "test1", Table: "t"}, MessageTTLOperationDelete))
+			},
+		},
+		{
+			name:     "enable-message-sequence",
+			protocol: config.ProtocolOpen,
+			query:    "enable-message-sequence=true&partition-num=1",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.EnableMessageSequence)
+			},
+		},
+		{
+			name:        "enable-message-sequence with multiple partitions",
+			protocol:    config.ProtocolOpen,
+			query:       "enable-message-sequence=true&partition-num=3",
+			validateErr: "enable-message-sequence requires a single partition, but partition-num is 3",
+		},
+		{
+			name:        "enable-message-sequence with headers unsupported",
+			protocol:    config.ProtocolOpen,
+			query:       "enable-message-sequence=true",
+			adjust:      func(c *Config) { c.WithHeadersUnsupported(true) },
+			validateErr: "enable-message-sequence requires the record headers",
+		},
+		{
+			name:     "routing-prefix",
+			protocol: config.ProtocolCanalJSON,
+			query:    "routing-prefix=cf1_",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "cf1_", c.RoutingPrefix)
+			},
+		},
+		{
+			name:        "invalid routing-prefix",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "routing-prefix=" + url.QueryEscape("cf/1"),
+			validateErr: "invalid routing-prefix cf/1",
+		},
+	})
+}
+
+func TestParseColumnHeaders(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"tenant_id", ":x-tenant", "tenant_id:"} {
+		_, err := parseColumnHeaders(s)
+		require.ErrorContains(t, err, "invalid column-headers "+s)
+	}
+	_, err := parseColumnHeaders("tenant_id:x-tenant,TENANT_ID:x-tenant-2")
+	require.ErrorContains(t, err, "duplicate column tenant_id in column-headers")
+	_, err = parseColumnHeaders("tenant_id:x-tenant,region:x-tenant")
+	require.ErrorContains(t, err, "duplicate header x-tenant in column-headers")
+}
+
+func TestParseMessageTTLs(t *testing.T) {
+	t.Parallel()
+
+	// the tables are matched by the table filter, in which the dots of the
+	// names are quoted by the backticks.
+	rules, err := parseMessageTTLs("Test.`t.2`:*:1s")
+	require.NoError(t, err)
+	require.True(t, rules[0].Match(model.TableName{Schema: "test", Table: "t.2"}, MessageTTLOperationDDL))
+	require.False(t, rules[0].Match(model.TableName{Schema: "test", Table: "t"}, MessageTTLOperationDDL))
+
+	for _, s := range []string{"test.t", "test.t:*", "t:*:1s", ".t:*:1s", "test.:*:1s", "test.t.2:*:1s"} {
+		_, err = parseMessageTTLs(s)
+		require.ErrorContains(t, err, "invalid message-ttl "+s)
+	}
+	_, err = parseMessageTTLs("test.t:upsert:1s")
+	require.ErrorContains(t, err, "invalid operation upsert in message-ttl")
+	_, err = parseMessageTTLs("test.t:*:1x")
+	require.Error(t, err)
+	_, err = parseMessageTTLs("test.t:*:-1s")
+	require.ErrorContains(t, err, "invalid ttl -1s in message-ttl")
+}
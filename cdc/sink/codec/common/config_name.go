@@ -0,0 +1,187 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// NameOptions are the options of the names of the schemas, the tables and
+// the columns emitted.
+type NameOptions struct {
+	// ColumnNameCase transforms the case of the column names emitted,
+	// it's one of NameCaseLower, NameCaseUpper and NameCaseUnchanged.
+	ColumnNameCase string
+	// ApplyCaseToTableNames makes the schema and table names emitted
+	// follow the ColumnNameCase too.
+	ApplyCaseToTableNames bool
+	// TableQualification is how the table of the events is identified, it's
+	// one of TableQualificationSeparate, the bare table name with the schema
+	// in its own field, and TableQualificationQualified, the table name
+	// qualified by the schema, i.e. `schema.table`. The schema field is
+	// emitted in both modes.
+	TableQualification string
+	// IdentifierHandling is how the names of the schemas and the tables
+	// containing the non-ASCII characters or exceeding the
+	// IdentifierMaxBytes are emitted, it's one of IdentifierHandlingNone,
+	// IdentifierHandlingTransliterate and IdentifierHandlingHash. The
+	// original names are carried by the props, see appendOriginalNames.
+	IdentifierHandling string
+	// IdentifierMaxBytes is the max length of the names of the schemas and
+	// the tables emitted as is, 0 means they're not limited.
+	IdentifierMaxBytes int
+}
+
+const (
+	codecOPTColumnNameCase        = "column-name-case"
+	codecOPTApplyCaseToTableNames = "apply-case-to-table-names"
+	codecOPTTableQualification    = "table-qualification"
+	codecOPTIdentifierHandling    = "identifier-handling"
+	codecOPTIdentifierMaxBytes    = "identifier-max-bytes"
+)
+
+const (
+	// NameCaseUnchanged keeps the case of the names
+	NameCaseUnchanged = "unchanged"
+	// NameCaseLower transforms the names to lower case
+	NameCaseLower = "lower"
+	// NameCaseUpper transforms the names to upper case
+	NameCaseUpper = "upper"
+	// TableQualificationSeparate emits the bare table name, with the schema
+	// in its own field
+	TableQualificationSeparate = "separate"
+	// TableQualificationQualified emits the table name qualified by the
+	// schema, i.e. `schema.table`
+	TableQualificationQualified = "qualified"
+	// IdentifierHandlingNone emits the names as is
+	IdentifierHandlingNone = "none"
+	// IdentifierHandlingTransliterate emits the names transliterated into
+	// ASCII, truncated with the hash suffixed if they're still too long
+	IdentifierHandlingTransliterate = "transliterate"
+	// IdentifierHandlingHash emits the hash of the names
+	IdentifierHandlingHash = "hash"
+)
+
+// applyNameOptions fills the NameOptions by the params of the sink URI.
+func (c *Config) applyNameOptions(params url.Values) error {
+	if s := params.Get(codecOPTColumnNameCase); s != "" {
+		c.ColumnNameCase = s
+	}
+
+	if s := params.Get(codecOPTApplyCaseToTableNames); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.ApplyCaseToTableNames = b
+	}
+
+	if s := params.Get(codecOPTTableQualification); s != "" {
+		c.TableQualification = s
+	}
+
+	if s := params.Get(codecOPTIdentifierHandling); s != "" {
+		c.IdentifierHandling = s
+	}
+
+	if s := params.Get(codecOPTIdentifierMaxBytes); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.IdentifierMaxBytes = a
+	}
+
+	return nil
+}
+
+// validateNameOptions validates the NameOptions.
+func (c *Config) validateNameOptions() error {
+	if c.ColumnNameCase != "" && c.ColumnNameCase != NameCaseUnchanged {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`column-name-case only supports canal protocol`,
+			)
+		}
+		if c.ColumnNameCase != NameCaseLower && c.ColumnNameCase != NameCaseUpper {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s", "%s" or "%s"`,
+				codecOPTColumnNameCase,
+				NameCaseUnchanged,
+				NameCaseLower,
+				NameCaseUpper,
+			)
+		}
+	}
+
+	if c.TableQualification != "" && c.TableQualification != TableQualificationSeparate {
+		if c.Protocol != config.ProtocolCanal && c.Protocol != config.ProtocolCanalJSON {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`table-qualification only supports canal/canal-json protocol`,
+			)
+		}
+		if c.TableQualification != TableQualificationQualified {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTTableQualification,
+				TableQualificationSeparate,
+				TableQualificationQualified,
+			)
+		}
+	}
+
+	if c.IdentifierHandling != "" && c.IdentifierHandling != IdentifierHandlingNone {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`identifier-handling only supports canal protocol`,
+			)
+		}
+		if c.IdentifierHandling != IdentifierHandlingTransliterate &&
+			c.IdentifierHandling != IdentifierHandlingHash {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s", "%s" or "%s"`,
+				codecOPTIdentifierHandling,
+				IdentifierHandlingNone,
+				IdentifierHandlingTransliterate,
+				IdentifierHandlingHash,
+			)
+		}
+	}
+
+	if c.IdentifierMaxBytes != 0 {
+		if c.IdentifierHandling == "" || c.IdentifierHandling == IdentifierHandlingNone {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`identifier-max-bytes requires identifier-handling to be set`,
+			)
+		}
+		// the names exceeding it are truncated with the hash suffixed.
+		if c.IdentifierMaxBytes < MinIdentifierMaxBytes {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid identifier-max-bytes %d, it must be at least %d`,
+				c.IdentifierMaxBytes, MinIdentifierMaxBytes,
+			)
+		}
+	}
+
+	return nil
+}
+
+// MinIdentifierMaxBytes is the min of the IdentifierMaxBytes, which is the
+// length of the hash suffixed to the names truncated, i.e. `_` and 16 hex
+// digits.
+const MinIdentifierMaxBytes = 17
@@ -0,0 +1,105 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigNameOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, NameCaseUnchanged, c.ColumnNameCase)
+				require.Equal(t, TableQualificationSeparate, c.TableQualification)
+				require.Empty(t, c.IdentifierHandling)
+			},
+		},
+		{
+			name:     "column-name-case",
+			protocol: config.ProtocolCanal,
+			query:    "column-name-case=lower&apply-case-to-table-names=true",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, NameCaseLower, c.ColumnNameCase)
+				require.True(t, c.ApplyCaseToTableNames)
+			},
+		},
+		{
+			name:        "invalid column-name-case",
+			protocol:    config.ProtocolCanal,
+			query:       "column-name-case=camel",
+			validateErr: `column-name-case value could only be "unchanged", "lower" or "upper"`,
+		},
+		{
+			name:     "table-qualification",
+			protocol: config.ProtocolCanalJSON,
+			query:    "table-qualification=qualified",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, TableQualificationQualified, c.TableQualification)
+			},
+		},
+		{
+			name:        "table-qualification on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "table-qualification=qualified",
+			validateErr: "table-qualification only supports canal/canal-json protocol",
+		},
+		{
+			name:        "invalid table-qualification",
+			protocol:    config.ProtocolCanal,
+			query:       "table-qualification=joined",
+			validateErr: `table-qualification value could only be "separate" or "qualified"`,
+		},
+		{
+			name:     "identifier-handling and identifier-max-bytes",
+			protocol: config.ProtocolCanal,
+			query:    "identifier-handling=transliterate&identifier-max-bytes=32",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, IdentifierHandlingTransliterate, c.IdentifierHandling)
+				require.Equal(t, 32, c.IdentifierMaxBytes)
+			},
+		},
+		{
+			name:        "too small identifier-max-bytes",
+			protocol:    config.ProtocolCanal,
+			query:       "identifier-handling=transliterate&identifier-max-bytes=16",
+			validateErr: "invalid identifier-max-bytes 16, it must be at least 17",
+		},
+		{
+			name:        "identifier-max-bytes without identifier-handling",
+			protocol:    config.ProtocolCanal,
+			query:       "identifier-handling=none&identifier-max-bytes=32",
+			validateErr: "identifier-max-bytes requires identifier-handling to be set",
+		},
+		{
+			name:        "invalid identifier-handling",
+			protocol:    config.ProtocolCanal,
+			query:       "identifier-handling=escape&identifier-max-bytes=32",
+			validateErr: `identifier-handling value could only be "none", "transliterate" or "hash"`,
+		},
+		{
+			name:        "identifier-handling on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "identifier-handling=hash&identifier-max-bytes=32",
+			validateErr: "identifier-handling only supports canal protocol",
+		},
+	})
+}
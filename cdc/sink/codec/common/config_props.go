@@ -0,0 +1,164 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// PropsOptions are the options gating and limiting the props of the canal
+// entries.
+type PropsOptions struct {
+	// FeatureLevel gates the optional props and fields emitted, so that the
+	// old consumers are not broken by the new ones. The level 0 is the output
+	// before the feature level is introduced.
+	FeatureLevel int
+	// StrictFeatureLevel fails the creation of the encoder if the features
+	// requested are not supported by the FeatureLevel, instead of logging
+	// the downgrade and dropping them.
+	StrictFeatureLevel bool
+	// MaxProps is the max number of the header props of each entry, so that
+	// the metadata does not dominate the payload. 0 means no limit.
+	MaxProps int
+	// PropsOverflow is how the encoder handles the entry carrying more props
+	// than MaxProps, it's one of PropsOverflowDrop and PropsOverflowReject.
+	PropsOverflow string
+	// PropsPriority is the keys of the props from the highest priority to the
+	// lowest one, the props of the lowest priority are dropped first when the
+	// props overflow. Empty means the default priority of the encoder.
+	PropsPriority []string
+}
+
+const (
+	codecOPTFeatureLevel       = "feature-level"
+	codecOPTStrictFeatureLevel = "strict-feature-level"
+	codecOPTMaxProps           = "max-props"
+	codecOPTPropsOverflow      = "props-overflow"
+	codecOPTPropsPriority      = "props-priority"
+)
+
+const (
+	// FeatureLevelLatest is the feature level enabling all the features
+	FeatureLevelLatest = math.MaxInt32
+	// PropsOverflowDrop drops the props of the lowest priority exceeding
+	// the max props
+	PropsOverflowDrop = "drop"
+	// PropsOverflowReject fails the encoding of the entry exceeding the max props
+	PropsOverflowReject = "reject"
+)
+
+// applyPropsOptions fills the PropsOptions by the params of the sink URI.
+func (c *Config) applyPropsOptions(params url.Values) error {
+	if s := params.Get(codecOPTFeatureLevel); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.FeatureLevel = a
+	}
+
+	if s := params.Get(codecOPTStrictFeatureLevel); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.StrictFeatureLevel = b
+	}
+
+	if s := params.Get(codecOPTMaxProps); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.MaxProps = a
+	}
+
+	if s := params.Get(codecOPTPropsOverflow); s != "" {
+		c.PropsOverflow = s
+	}
+
+	if s := params.Get(codecOPTPropsPriority); s != "" {
+		c.PropsPriority = strings.Split(s, ",")
+	}
+
+	return nil
+}
+
+// validatePropsOptions validates the PropsOptions.
+func (c *Config) validatePropsOptions() error {
+	if c.FeatureLevel != FeatureLevelLatest {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`feature-level only supports canal protocol`,
+			)
+		}
+		if c.FeatureLevel < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid feature-level %d`, c.FeatureLevel,
+			)
+		}
+	}
+
+	if c.StrictFeatureLevel && c.Protocol != config.ProtocolCanal {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`strict-feature-level only supports canal protocol`,
+		)
+	}
+
+	if c.MaxProps != 0 {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`max-props only supports canal protocol`,
+			)
+		}
+		if c.MaxProps < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid max-props %d`, c.MaxProps,
+			)
+		}
+	}
+
+	if c.PropsOverflow != "" && c.PropsOverflow != PropsOverflowDrop &&
+		c.PropsOverflow != PropsOverflowReject {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`%s value could only be "%s" or "%s"`,
+			codecOPTPropsOverflow,
+			PropsOverflowDrop,
+			PropsOverflowReject,
+		)
+	}
+
+	if len(c.PropsPriority) > 0 {
+		if c.MaxProps == 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`props-priority requires max-props to be set`,
+			)
+		}
+		for _, key := range c.PropsPriority {
+			if key == "" {
+				return cerror.ErrCodecInvalidConfig.GenWithStack(
+					`invalid props-priority %s`, strings.Join(c.PropsPriority, ","),
+				)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigPropsOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, FeatureLevelLatest, c.FeatureLevel)
+				require.False(t, c.StrictFeatureLevel)
+				require.Equal(t, 0, c.MaxProps)
+				require.Equal(t, PropsOverflowDrop, c.PropsOverflow)
+			},
+		},
+		{
+			name:     "feature-level",
+			protocol: config.ProtocolCanal,
+			query:    "feature-level=1",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 1, c.FeatureLevel)
+			},
+		},
+		{
+			name:        "negative feature-level",
+			protocol:    config.ProtocolCanal,
+			query:       "feature-level=-1",
+			validateErr: "invalid feature-level -1",
+		},
+		{
+			name:        "feature-level on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "feature-level=0",
+			validateErr: "feature-level only supports canal protocol",
+		},
+		{
+			name:     "strict-feature-level",
+			protocol: config.ProtocolCanal,
+			query:    "strict-feature-level=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.StrictFeatureLevel)
+			},
+		},
+		{
+			name:        "strict-feature-level on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "strict-feature-level=true",
+			validateErr: "strict-feature-level only supports canal protocol",
+		},
+		{
+			name:     "max-props",
+			protocol: config.ProtocolCanal,
+			query:    "max-props=8&props-overflow=reject&props-priority=rowsCount,sequence",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 8, c.MaxProps)
+				require.Equal(t, PropsOverflowReject, c.PropsOverflow)
+				require.Equal(t, []string{"rowsCount", "sequence"}, c.PropsPriority)
+			},
+		},
+		{
+			name:        "empty props-priority",
+			protocol:    config.ProtocolCanal,
+			query:       "max-props=8&props-priority=rowsCount,",
+			validateErr: "invalid props-priority rowsCount,",
+		},
+		{
+			name:        "invalid props-overflow",
+			protocol:    config.ProtocolCanal,
+			query:       "max-props=8&props-overflow=truncate",
+			validateErr: `props-overflow value could only be "drop" or "reject"`,
+		},
+		{
+			name:        "negative max-props",
+			protocol:    config.ProtocolCanal,
+			query:       "max-props=-1",
+			validateErr: "invalid max-props -1",
+		},
+		{
+			name:        "props-priority without max-props",
+			protocol:    config.ProtocolCanal,
+			query:       "props-priority=rowsCount",
+			validateErr: "props-priority requires max-props to be set",
+		},
+		{
+			name:        "max-props on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "max-props=8",
+			validateErr: "max-props only supports canal protocol",
+		},
+	})
+}
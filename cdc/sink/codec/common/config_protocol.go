@@ -0,0 +1,198 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// ProtocolOptions are the options encoding the events by the protocols
+// other than the Protocol.
+type ProtocolOptions struct {
+	// TableProtocols overrides the protocol of the events of the tables,
+	// the events of the other tables are encoded by the Protocol. The
+	// messages of each protocol overridden are sent to its ProtocolTopics.
+	TableProtocols map[model.TableName]config.Protocol
+	// FanoutProtocols are the protocols the events are encoded by as well,
+	// besides the Protocol, e.g. to dual-write during the migration of the
+	// protocol. The messages of each of them are sent to its ProtocolTopics.
+	FanoutProtocols []config.Protocol
+	// ProtocolTopics maps each protocol other than the Protocol, i.e. the
+	// ones of the TableProtocols and the FanoutProtocols, to the topic its
+	// messages are sent to, so that the consumers of each topic decode a
+	// single protocol.
+	ProtocolTopics map[config.Protocol]string
+}
+
+const (
+	codecOPTTableProtocols  = "table-protocols"
+	codecOPTFanoutProtocols = "fanout-protocols"
+	codecOPTProtocolTopics  = "protocol-topics"
+)
+
+// applyProtocolOptions fills the ProtocolOptions by the params of the sink URI.
+func (c *Config) applyProtocolOptions(params url.Values) error {
+	if s := params.Get(codecOPTTableProtocols); s != "" {
+		protocols, err := parseTableProtocols(s)
+		if err != nil {
+			return err
+		}
+		c.TableProtocols = protocols
+	}
+
+	if s := params.Get(codecOPTFanoutProtocols); s != "" {
+		protocols, err := parseFanoutProtocols(s)
+		if err != nil {
+			return err
+		}
+		c.FanoutProtocols = protocols
+	}
+
+	if s := params.Get(codecOPTProtocolTopics); s != "" {
+		topics, err := parseProtocolTopics(s)
+		if err != nil {
+			return err
+		}
+		c.ProtocolTopics = topics
+	}
+
+	return nil
+}
+
+// validateProtocolOptions validates the ProtocolOptions.
+func (c *Config) validateProtocolOptions() error {
+	for _, protocol := range c.FanoutProtocols {
+		if protocol == c.Protocol {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`fanout-protocols must not contain the protocol %s`, protocol,
+			)
+		}
+	}
+
+	// the messages of the protocols other than the Protocol are sent to the
+	// topics of their own.
+	protocols := append([]config.Protocol(nil), c.FanoutProtocols...)
+	for _, protocol := range c.TableProtocols {
+		protocols = append(protocols, protocol)
+	}
+	for _, protocol := range protocols {
+		if _, ok := c.ProtocolTopics[protocol]; !ok && protocol != c.Protocol {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`protocol-topics has no topic for the protocol %s`, protocol,
+			)
+		}
+	}
+	for protocol := range c.ProtocolTopics {
+		if protocol == c.Protocol {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`protocol-topics must not contain the protocol %s`, protocol,
+			)
+		}
+		used := false
+		for _, p := range protocols {
+			used = used || p == protocol
+		}
+		if !used {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`protocol-topics contains the protocol %s not in use`, protocol,
+			)
+		}
+	}
+
+	return nil
+}
+
+// parseTableProtocols parses the table protocols in the form of
+// `schema.table:protocol` separated by comma, e.g.
+// `test.t1:canal-json,test.t2:open-protocol`. The schema must not contain
+// a dot, while the table may.
+func parseTableProtocols(s string) (map[model.TableName]config.Protocol, error) {
+	result := make(map[model.TableName]config.Protocol)
+	for _, item := range strings.Split(s, ",") {
+		sep := strings.LastIndexByte(item, ':')
+		dot := strings.IndexByte(item, '.')
+		if sep < 0 || dot <= 0 || dot+1 >= sep {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid table-protocols %s`, s)
+		}
+		protocol, err := config.ParseSinkProtocolFromString(item[sep+1:])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		table := model.TableName{Schema: item[:dot], Table: item[dot+1 : sep]}
+		if _, ok := result[table]; ok {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`duplicate table %s in table-protocols`, table)
+		}
+		result[table] = protocol
+	}
+	return result, nil
+}
+
+// parseFanoutProtocols parses the fanout protocols separated by comma, e.g.
+// `canal-json,open-protocol`.
+func parseFanoutProtocols(s string) ([]config.Protocol, error) {
+	var result []config.Protocol
+	for _, item := range strings.Split(s, ",") {
+		protocol, err := config.ParseSinkProtocolFromString(item)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, p := range result {
+			if p == protocol {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`duplicate protocol %s in fanout-protocols`, protocol)
+			}
+		}
+		result = append(result, protocol)
+	}
+	return result, nil
+}
+
+// parseProtocolTopics parses the topics of the protocols in the form of
+// `protocol:topic` separated by comma, e.g.
+// `canal-json:orders-json,open-protocol:orders-open`.
+func parseProtocolTopics(s string) (map[config.Protocol]string, error) {
+	result := make(map[config.Protocol]string)
+	topics := make(map[string]struct{})
+	for _, item := range strings.Split(s, ",") {
+		sep := strings.IndexByte(item, ':')
+		if sep <= 0 || sep+1 >= len(item) {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid protocol-topics %s`, s)
+		}
+		protocol, err := config.ParseSinkProtocolFromString(item[:sep])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		topic := item[sep+1:]
+		if _, ok := result[protocol]; ok {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`duplicate protocol %s in protocol-topics`, protocol)
+		}
+		if _, ok := topics[topic]; ok {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`duplicate topic %s in protocol-topics`, topic)
+		}
+		result[protocol] = topic
+		topics[topic] = struct{}{}
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigProtocolOptions(t *testing.T) {
+	t.Parallel()
+
+	const (
+		tableProtocols  = "table-protocols=test.t1:canal-json,test.t.2:open-protocol"
+		fanoutProtocols = "fanout-protocols=canal-json,open-protocol"
+		protocolTopics  = "protocol-topics=canal-json:abc-json,open-protocol:abc-open"
+	)
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "table-protocols",
+			protocol: config.ProtocolCanal,
+			query:    tableProtocols + "&" + protocolTopics,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, map[model.TableName]config.Protocol{
+					{Schema: "test", Table: "t1"}:  config.ProtocolCanalJSON,
+					{Schema: "test", Table: "t.2"}: config.ProtocolOpen,
+				}, c.TableProtocols)
+				require.Equal(t, map[config.Protocol]string{
+					config.ProtocolCanalJSON: "abc-json",
+					config.ProtocolOpen:      "abc-open",
+				}, c.ProtocolTopics)
+			},
+		},
+		{
+			// the protocols other than the protocol have the topics of their own.
+			name:        "table-protocols without the protocol-topics",
+			protocol:    config.ProtocolCanal,
+			query:       tableProtocols + "&protocol-topics=canal-json:abc-json",
+			validateErr: "protocol-topics has no topic for the protocol open-protocol",
+		},
+		{
+			name:     "table-protocols of the protocol",
+			protocol: config.ProtocolCanal,
+			query:    "table-protocols=test.t1:canal-json,test.t.2:canal&protocol-topics=canal-json:abc-json",
+		},
+		{
+			name:     "protocol-topics of the protocol",
+			protocol: config.ProtocolCanal,
+			query: "table-protocols=test.t1:canal-json" +
+				"&protocol-topics=canal-json:abc-json,canal:abc-canal",
+			validateErr: "protocol-topics must not contain the protocol canal",
+		},
+		{
+			name:     "protocol-topics of the protocol not in use",
+			protocol: config.ProtocolCanal,
+			query: "table-protocols=test.t1:canal-json" +
+				"&protocol-topics=canal-json:abc-json,avro:abc-avro",
+			validateErr: "protocol-topics contains the protocol avro not in use",
+		},
+		{
+			name:     "fanout-protocols",
+			protocol: config.ProtocolCanal,
+			query:    fanoutProtocols + "&" + protocolTopics,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []config.Protocol{config.ProtocolCanalJSON, config.ProtocolOpen}, c.FanoutProtocols)
+			},
+		},
+		{
+			name:        "fanout-protocols without the protocol-topics",
+			protocol:    config.ProtocolCanal,
+			query:       fanoutProtocols + "&protocol-topics=canal-json:abc-json",
+			validateErr: "protocol-topics has no topic for the protocol open-protocol",
+		},
+		{
+			name:        "fanout-protocols of the protocol",
+			protocol:    config.ProtocolCanalJSON,
+			query:       fanoutProtocols + "&" + protocolTopics,
+			validateErr: "fanout-protocols must not contain the protocol canal-json",
+		},
+	})
+}
+
+func TestParseProtocolTopics(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"canal-json", "canal-json:", ":abc"} {
+		_, err := parseProtocolTopics(s)
+		require.ErrorContains(t, err, "invalid protocol-topics "+s)
+	}
+	_, err := parseProtocolTopics("unknown:abc")
+	require.Error(t, err)
+	_, err = parseProtocolTopics("canal-json:abc,canal-json:def")
+	require.ErrorContains(t, err, "duplicate protocol canal-json in protocol-topics")
+	_, err = parseProtocolTopics("canal-json:abc,open-protocol:abc")
+	require.ErrorContains(t, err, "duplicate topic abc in protocol-topics")
+}
+
+func TestParseTableProtocols(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"test.t1", "t1:canal-json", ".t1:canal-json", "test.:canal-json"} {
+		_, err := parseTableProtocols(s)
+		require.ErrorContains(t, err, "invalid table-protocols "+s)
+	}
+	_, err := parseTableProtocols("test.t1:unknown")
+	require.Error(t, err)
+	_, err = parseTableProtocols("test.t1:canal,test.t1:canal-json")
+	require.ErrorContains(t, err, "duplicate table test.t1 in table-protocols")
+}
+
+func TestParseFanoutProtocols(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseFanoutProtocols("canal-json,unknown")
+	require.Error(t, err)
+	_, err = parseFanoutProtocols("canal-json,canal-json")
+	require.ErrorContains(t, err, "duplicate protocol canal-json in fanout-protocols")
+}
@@ -0,0 +1,397 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// RowOptions are the options filtering the row events and shaping
+// their columns before they are encoded.
+type RowOptions struct {
+	// RowFilter is the rules of the row filter, which are parsed by the
+	// builder of the encoder, the first rule matching the table of the row
+	// event applies, and the rows of the tables matched by none are kept.
+	// The rows not kept are dropped by the encoder.
+	RowFilter string
+	// RowSamplings are the rules of the row sampling, the first rule
+	// matching the table of the row event applies, and the rows of the
+	// tables matched by none are all kept. The rows not sampled are dropped
+	// by the encoder.
+	RowSamplings []RowSamplingRule
+	// DedupWindowSize is the max number of the content hashes of the row
+	// events remembered to suppress the duplicates, 0 means the duplicates
+	// are not suppressed. DedupWindowTTL is the interval to forget the
+	// hashes remembered, 0 means they are forgotten only if the size is hit.
+	DedupWindowSize int
+	DedupWindowTTL  time.Duration
+	// ColumnSelections are the rules selecting and aliasing the columns of
+	// the row events, loaded from the file of the column-selection, the
+	// first rule matching the table of the row event applies.
+	ColumnSelections []ColumnSelectionRule
+	// ColumnOrders are the rules of the order of the columns of the row
+	// events, the first rule matching the table of the row event applies.
+	// The columns not listed by the rule are emitted after the ones listed,
+	// or dropped, by the ColumnOrderUnlisted.
+	ColumnOrders        []ColumnOrderRule
+	ColumnOrderUnlisted string
+}
+
+const (
+	codecOPTRowFilter           = "row-filter"
+	codecOPTRowSampling         = "row-sampling"
+	codecOPTDedupWindowSize     = "dedup-window-size"
+	codecOPTDedupWindowTTL      = "dedup-window-ttl"
+	codecOPTColumnSelection     = "column-selection"
+	codecOPTColumnOrder         = "column-order"
+	codecOPTColumnOrderUnlisted = "column-order-unlisted"
+)
+
+const (
+	// ColumnOrderUnlistedLast emits the columns not listed by the column
+	// order after the ones listed, in their original order
+	ColumnOrderUnlistedLast = "last"
+	// ColumnOrderUnlistedDrop drops the columns not listed by the column order
+	ColumnOrderUnlistedDrop = "drop"
+)
+
+// applyRowOptions fills the RowOptions by the params of the sink URI.
+func (c *Config) applyRowOptions(params url.Values) error {
+	if s := params.Get(codecOPTRowFilter); s != "" {
+		c.RowFilter = s
+	}
+
+	if s := params.Get(codecOPTColumnSelection); s != "" {
+		data, err := os.ReadFile(s)
+		if err != nil {
+			return cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+		}
+		rules, err := parseColumnSelections(data)
+		if err != nil {
+			return err
+		}
+		c.ColumnSelections = rules
+	}
+
+	if s := params.Get(codecOPTColumnOrder); s != "" {
+		rules, err := parseColumnOrders(s)
+		if err != nil {
+			return err
+		}
+		c.ColumnOrders = rules
+	}
+
+	if s := params.Get(codecOPTColumnOrderUnlisted); s != "" {
+		c.ColumnOrderUnlisted = s
+	}
+
+	if s := params.Get(codecOPTRowSampling); s != "" {
+		rules, err := parseRowSamplings(s)
+		if err != nil {
+			return err
+		}
+		c.RowSamplings = rules
+	}
+
+	if s := params.Get(codecOPTDedupWindowSize); s != "" {
+		a, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.DedupWindowSize = a
+	}
+
+	if s := params.Get(codecOPTDedupWindowTTL); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		c.DedupWindowTTL = d
+	}
+
+	return nil
+}
+
+// validateRowOptions validates the RowOptions.
+func (c *Config) validateRowOptions() error {
+	if c.DedupWindowSize < 0 {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`invalid dedup-window-size %d`, c.DedupWindowSize,
+		)
+	}
+
+	if c.DedupWindowTTL != 0 {
+		if c.DedupWindowSize == 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`dedup-window-ttl requires dedup-window-size to be set`,
+			)
+		}
+		if c.DedupWindowTTL < 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid dedup-window-ttl %s`, c.DedupWindowTTL,
+			)
+		}
+	}
+
+	if c.ColumnOrderUnlisted != "" {
+		if c.ColumnOrderUnlisted != ColumnOrderUnlistedLast &&
+			c.ColumnOrderUnlisted != ColumnOrderUnlistedDrop {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTColumnOrderUnlisted,
+				ColumnOrderUnlistedLast,
+				ColumnOrderUnlistedDrop,
+			)
+		}
+		if len(c.ColumnOrders) == 0 {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`column-order-unlisted requires column-order to be set`,
+			)
+		}
+	}
+
+	return nil
+}
+
+// the options of the RowSamplingRule.
+const (
+	rowSamplingOptionKeepDeletes = "keep-deletes"
+	rowSamplingOptionByKey       = "by-key"
+)
+
+// RowSamplingRule samples the row events of the tables matched, a fraction
+// of the rows, the Rate, is kept. The deletes are all kept if KeepDeletes is set, so that the
+// consumer does not keep the rows deleted upstream. The rows are sampled by
+// the hash of the handle key if ByKey is set, so that the changes of the same
+// row are either all kept or all dropped, and at random otherwise.
+type RowSamplingRule struct {
+	TableMatcher
+	Rate        float64
+	KeepDeletes bool
+	ByKey       bool
+}
+
+// parseRowSamplings parses the rules of the row sampling in the form of
+// `schema.table:rate[:option...]` separated by comma, where the rate is
+// either `1/N` or the percentage `P%`, and the option is keep-deletes or
+// by-key, e.g. `test.logs:1/10:keep-deletes:by-key,test.*:5%`.
+func parseRowSamplings(s string) ([]RowSamplingRule, error) {
+	var result []RowSamplingRule
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid row-sampling %s`, item)
+		}
+		matcher, err := NewTableMatcher(parts[0])
+		if err != nil {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid row-sampling %s`, item)
+		}
+		rate, err := parseSamplingRate(parts[1])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		rule := RowSamplingRule{TableMatcher: matcher, Rate: rate}
+		for _, option := range parts[2:] {
+			switch option {
+			case rowSamplingOptionKeepDeletes:
+				rule.KeepDeletes = true
+			case rowSamplingOptionByKey:
+				rule.ByKey = true
+			default:
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`invalid option %s in row-sampling`, option)
+			}
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// parseSamplingRate parses the rate of the row sampling, either `1/N` or
+// `P%`, into the fraction in (0, 1].
+func parseSamplingRate(s string) (float64, error) {
+	invalid := cerror.ErrCodecInvalidConfig.GenWithStack(
+		`invalid rate %s in row-sampling`, s)
+	var rate float64
+	switch {
+	case strings.HasPrefix(s, "1/"):
+		n, err := strconv.ParseUint(s[2:], 10, 64)
+		if err != nil || n == 0 {
+			return 0, invalid
+		}
+		rate = 1 / float64(n)
+	case strings.HasSuffix(s, "%"):
+		p, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		if err != nil {
+			return 0, invalid
+		}
+		rate = p / 100
+	default:
+		return 0, invalid
+	}
+	if !(rate > 0 && rate <= 1) {
+		return 0, invalid
+	}
+	return rate, nil
+}
+
+// ColumnSelection selects a column of the table, which is emitted by the
+// Alias if it's set, and dropped if it's not included.
+type ColumnSelection struct {
+	Column  string
+	Alias   string
+	Include bool
+}
+
+// ColumnSelectionRule selects the columns of the row events of the tables
+// matched, the columns not listed are emitted as is.
+type ColumnSelectionRule struct {
+	TableMatcher
+	Columns []ColumnSelection
+}
+
+// Select returns the name the column is emitted by, and whether it's
+// included. The column name is case-insensitive.
+func (r *ColumnSelectionRule) Select(column string) (string, bool) {
+	for i := range r.Columns {
+		if strings.EqualFold(r.Columns[i].Column, column) {
+			if r.Columns[i].Alias != "" {
+				return r.Columns[i].Alias, r.Columns[i].Include
+			}
+			return column, r.Columns[i].Include
+		}
+	}
+	return column, true
+}
+
+// parseColumnSelections parses the rules of the column selection from the
+// JSON object mapping `schema.table` to the columns selected, e.g.
+//
+//	{"test.users": [
+//	    {"column": "name", "alias": "user_name"},
+//	    {"column": "password", "include": false}
+//	]}
+//
+// where the include defaults to true. Since the object is unordered, the
+// rules of the exact tables are matched before the ones with the wildcards.
+func parseColumnSelections(data []byte) ([]ColumnSelectionRule, error) {
+	var tables map[string][]struct {
+		Column  string `json:"column"`
+		Alias   string `json:"alias"`
+		Include *bool  `json:"include"`
+	}
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+	}
+	result := make([]ColumnSelectionRule, 0, len(tables))
+	for name, columns := range tables {
+		matcher, err := NewTableMatcher(name)
+		if err != nil {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid table %s in column-selection`, name)
+		}
+		rule := ColumnSelectionRule{TableMatcher: matcher}
+		names := make(map[string]struct{}, len(columns))
+		for _, c := range columns {
+			include := c.Include == nil || *c.Include
+			if c.Column == "" || (c.Alias != "" && !include) {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`invalid column %q of %s in column-selection`, c.Column, name)
+			}
+			emitted := strings.ToLower(c.Column)
+			if c.Alias != "" {
+				emitted = strings.ToLower(c.Alias)
+			}
+			if _, ok := names[emitted]; ok && include {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`duplicate column %s of %s in column-selection`, emitted, name)
+			}
+			names[emitted] = struct{}{}
+			rule.Columns = append(rule.Columns, ColumnSelection{
+				Column: c.Column, Alias: c.Alias, Include: include,
+			})
+		}
+		result = append(result, rule)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if wi, wj := result[i].wildcards(), result[j].wildcards(); wi != wj {
+			return wi < wj
+		}
+		return result[i].Pattern < result[j].Pattern
+	})
+	return result, nil
+}
+
+// ColumnOrderRule orders the columns of the row events of the tables matched
+// by the Columns.
+type ColumnOrderRule struct {
+	TableMatcher
+	Columns []string
+}
+
+// Position returns the position of the column in the order, and whether
+// it's listed. The column name is case-insensitive.
+func (r *ColumnOrderRule) Position(column string) (int, bool) {
+	for i, c := range r.Columns {
+		if strings.EqualFold(c, column) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// parseColumnOrders parses the rules of the column order in the form of
+// `schema.table:column,...` separated by semicolon, e.g.
+// `test.users:id,name,age;test.*:id`.
+func parseColumnOrders(s string) ([]ColumnOrderRule, error) {
+	var result []ColumnOrderRule
+	for _, item := range strings.Split(s, ";") {
+		colon := strings.IndexByte(item, ':')
+		if colon < 0 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid column-order %s`, item)
+		}
+		matcher, err := NewTableMatcher(item[:colon])
+		if err != nil {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`invalid column-order %s`, item)
+		}
+		rule := ColumnOrderRule{TableMatcher: matcher}
+		for _, column := range strings.Split(item[colon+1:], ",") {
+			column = strings.TrimSpace(column)
+			if column == "" {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`invalid column-order %s`, item)
+			}
+			if _, ok := rule.Position(column); ok {
+				return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+					`duplicate column %s in column-order %s`, column, item)
+			}
+			rule.Columns = append(rule.Columns, column)
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
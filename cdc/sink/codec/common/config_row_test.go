@@ -0,0 +1,199 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRowOptions(t *testing.T) {
+	t.Parallel()
+
+	selectionPath := filepath.Join(t.TempDir(), "columns.json")
+	require.NoError(t, os.WriteFile(selectionPath, []byte(`{
+		"test.*": [{"column": "password", "include": false}],
+		"test.users": [
+			{"column": "name", "alias": "user_name"},
+			{"column": "password", "include": false},
+			{"column": "email", "include": true}
+		]
+	}`), 0o644))
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolOpen,
+			check: func(t *testing.T, c *Config) {
+				require.Empty(t, c.RowFilter)
+				require.Empty(t, c.RowSamplings)
+				require.Zero(t, c.DedupWindowSize)
+			},
+		},
+		{
+			name:     "row-filter",
+			protocol: config.ProtocolOpen,
+			query: "row-filter=" + url.QueryEscape(
+				"test.users:status = 'active';test.*:region IN ('us', 'it''s;,', 42, null)"),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, "test.users:status = 'active';test.*:region IN ('us', 'it''s;,', 42, null)",
+					c.RowFilter)
+			},
+		},
+		{
+			name:     "row-sampling",
+			protocol: config.ProtocolOpen,
+			query:    "row-sampling=" + url.QueryEscape("test.logs:1/10:keep-deletes:by-key,test.*:2.5%"),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []RowSamplingRule{
+					{TableMatcher: MustNewTableMatcher("test.logs"), Rate: 0.1, KeepDeletes: true, ByKey: true},
+					{TableMatcher: MustNewTableMatcher("test.*"), Rate: 0.025},
+				}, c.RowSamplings)
+				sampling := c.RowSamplings[1]
+				require.True(t, sampling.MatchTable(model.TableName{Schema: "test", Table: "t"}))
+				require.False(t, sampling.MatchTable(model.TableName{Schema: "test1", Table: "t"}))
+			},
+		},
+		{
+			name:     "dedup-window-size and dedup-window-ttl",
+			protocol: config.ProtocolOpen,
+			query:    "dedup-window-size=1024&dedup-window-ttl=10s",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, 1024, c.DedupWindowSize)
+				require.Equal(t, 10*time.Second, c.DedupWindowTTL)
+			},
+		},
+		{
+			name:        "negative dedup-window-ttl",
+			protocol:    config.ProtocolOpen,
+			query:       "dedup-window-size=1024&dedup-window-ttl=-1s",
+			validateErr: "invalid dedup-window-ttl -1s",
+		},
+		{
+			name:        "negative dedup-window-size",
+			protocol:    config.ProtocolOpen,
+			query:       "dedup-window-size=-1&dedup-window-ttl=1s",
+			validateErr: "invalid dedup-window-size -1",
+		},
+		{
+			name:        "dedup-window-ttl without dedup-window-size",
+			protocol:    config.ProtocolOpen,
+			query:       "dedup-window-ttl=1s",
+			validateErr: "dedup-window-ttl requires dedup-window-size to be set",
+		},
+		{
+			name:     "column-selection",
+			protocol: config.ProtocolCanalJSON,
+			query:    "column-selection=" + url.QueryEscape(selectionPath),
+			check: func(t *testing.T, c *Config) {
+				// the exact table is matched before the wildcard.
+				require.Equal(t, []ColumnSelectionRule{
+					{TableMatcher: MustNewTableMatcher("test.users"), Columns: []ColumnSelection{
+						{Column: "name", Alias: "user_name", Include: true},
+						{Column: "password", Include: false},
+						{Column: "email", Include: true},
+					}},
+					{TableMatcher: MustNewTableMatcher("test.*"), Columns: []ColumnSelection{
+						{Column: "password", Include: false},
+					}},
+				}, c.ColumnSelections)
+				selection := c.ColumnSelections[0]
+				require.True(t, selection.MatchTable(model.TableName{Schema: "test", Table: "users"}))
+				name, include := selection.Select("Name")
+				require.Equal(t, "user_name", name)
+				require.True(t, include)
+				_, include = selection.Select("password")
+				require.False(t, include)
+				name, include = selection.Select("age")
+				require.Equal(t, "age", name)
+				require.True(t, include)
+			},
+		},
+		{
+			name:     "column-order and column-order-unlisted",
+			protocol: config.ProtocolOpen,
+			query:    "column-order-unlisted=drop&column-order=" + url.QueryEscape("test.users:age, name,id;test.*:id"),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []ColumnOrderRule{
+					{TableMatcher: MustNewTableMatcher("test.users"), Columns: []string{"age", "name", "id"}},
+					{TableMatcher: MustNewTableMatcher("test.*"), Columns: []string{"id"}},
+				}, c.ColumnOrders)
+				require.Equal(t, ColumnOrderUnlistedDrop, c.ColumnOrderUnlisted)
+				position, ok := c.ColumnOrders[0].Position("NAME")
+				require.True(t, ok)
+				require.Equal(t, 1, position)
+				_, ok = c.ColumnOrders[0].Position("email")
+				require.False(t, ok)
+			},
+		},
+		{
+			name:        "invalid column-order-unlisted",
+			protocol:    config.ProtocolOpen,
+			query:       "column-order-unlisted=first&column-order=" + url.QueryEscape("test.*:id"),
+			validateErr: `column-order-unlisted value could only be "last" or "drop"`,
+		},
+		{
+			name:        "column-order-unlisted without column-order",
+			protocol:    config.ProtocolOpen,
+			query:       "column-order-unlisted=last",
+			validateErr: "column-order-unlisted requires column-order to be set",
+		},
+	})
+}
+
+func TestParseRowSamplings(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"test.t", "t:1/2", "test.:1/2"} {
+		_, err := parseRowSamplings(s)
+		require.ErrorContains(t, err, "invalid row-sampling "+s)
+	}
+	for _, s := range []string{"1/0", "1/x", "2/3", "0%", "101%", "x%", "0.5"} {
+		_, err := parseRowSamplings("test.t:" + s)
+		require.ErrorContains(t, err, "invalid rate "+s+" in row-sampling")
+	}
+	_, err := parseRowSamplings("test.t:1/2:keep-updates")
+	require.ErrorContains(t, err, "invalid option keep-updates in row-sampling")
+}
+
+func TestParseColumnSelections(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{
+		`[]`,
+		`{"users": [{"column": "name"}]}`,
+		`{"test.users": [{"alias": "name"}]}`,
+		`{"test.users": [{"column": "name", "alias": "n", "include": false}]}`,
+		`{"test.users": [{"column": "name", "alias": "email"}, {"column": "email"}]}`,
+	} {
+		_, err := parseColumnSelections([]byte(s))
+		require.ErrorContains(t, err, "ErrCodecInvalidConfig", s)
+	}
+}
+
+func TestParseColumnOrders(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"test.users", "users:id", "test.users:id,,name", "test.users:id,ID"} {
+		_, err := parseColumnOrders(s)
+		require.ErrorContains(t, err, "ErrCodecInvalidConfig", s)
+	}
+}
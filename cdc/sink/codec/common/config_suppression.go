@@ -0,0 +1,133 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// SuppressionOptions are the options of the events suppressed by the sink.
+// Only the canal protocol suppresses the events by default, see
+// defaultSuppressionOptions.
+type SuppressionOptions struct {
+	// SuppressedSchemas are the schemas whose row events and DDL events are
+	// suppressed by the sink, which are the system schemas of TiDB by
+	// default, see DefaultSuppressedSchemas. The names are case-insensitive,
+	// and nil means no schema is suppressed.
+	SuppressedSchemas []string
+	// IncludeTemporaryTables includes the row events of the temporary tables
+	// of TiDB, which are suppressed by default, since the data of them is
+	// ephemeral. The DDL events of them are not suppressed, so that the
+	// schemas of the global temporary tables are replicated.
+	IncludeTemporaryTables bool
+	// SuppressCachedTables suppresses the row events of the cached tables of
+	// TiDB, whose data is persistent, so they are emitted by default.
+	SuppressCachedTables bool
+}
+
+const (
+	codecOPTSuppressedSchemas      = "suppressed-schemas"
+	codecOPTIncludeTemporaryTables = "include-temporary-tables"
+	codecOPTSuppressCachedTables   = "suppress-cached-tables"
+
+	// SuppressedSchemasNone is the suppressed-schemas suppressing no schema.
+	SuppressedSchemasNone = "none"
+)
+
+// defaultSuppressionOptions returns the SuppressionOptions of the protocol.
+// The events of the system schemas and the temporary tables are suppressed
+// by default for the canal protocol only, the other protocols emit them
+// unless configured, as they did before the suppression.
+func defaultSuppressionOptions(protocol config.Protocol) SuppressionOptions {
+	if protocol != config.ProtocolCanal {
+		return SuppressionOptions{IncludeTemporaryTables: true}
+	}
+	return SuppressionOptions{SuppressedSchemas: DefaultSuppressedSchemas()}
+}
+
+// applySuppressionOptions fills the SuppressionOptions by the params of the
+// sink URI.
+func (c *Config) applySuppressionOptions(params url.Values) error {
+	// the suppressed schemas present but empty are kept empty rather than
+	// nil, so that they're rejected instead of mistaken for the defaults.
+	if _, ok := params[codecOPTSuppressedSchemas]; ok {
+		c.SuppressedSchemas = []string{}
+		value := strings.TrimSpace(params.Get(codecOPTSuppressedSchemas))
+		if strings.EqualFold(value, SuppressedSchemasNone) {
+			c.SuppressedSchemas = nil
+		} else {
+			for _, schema := range strings.Split(value, ",") {
+				if schema = strings.TrimSpace(schema); schema != "" {
+					c.SuppressedSchemas = append(c.SuppressedSchemas, strings.ToLower(schema))
+				}
+			}
+		}
+	}
+
+	if s := params.Get(codecOPTIncludeTemporaryTables); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.IncludeTemporaryTables = b
+	}
+
+	if s := params.Get(codecOPTSuppressCachedTables); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		c.SuppressCachedTables = b
+	}
+
+	return nil
+}
+
+// validateSuppressionOptions validates the SuppressionOptions.
+func (c *Config) validateSuppressionOptions() error {
+	if c.SuppressedSchemas != nil && len(c.SuppressedSchemas) == 0 {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`suppressed-schemas is empty, set it to "%s" to suppress no schema`,
+			SuppressedSchemasNone)
+	}
+	seen := make(map[string]struct{}, len(c.SuppressedSchemas))
+	for _, schema := range c.SuppressedSchemas {
+		if schema == "" {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`empty schema in suppressed-schemas`)
+		}
+		// the names are case-insensitive.
+		schema = strings.ToLower(schema)
+		if _, ok := seen[schema]; ok {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`duplicate schema %s in suppressed-schemas`, schema)
+		}
+		seen[schema] = struct{}{}
+	}
+	return nil
+}
+
+// DefaultSuppressedSchemas returns the system schemas of TiDB, whose events
+// are suppressed by default for the canal protocol.
+func DefaultSuppressedSchemas() []string {
+	return []string{
+		"mysql", "sys", "information_schema", "performance_schema",
+		"metrics_schema", "inspection_schema",
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSuppressionOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, DefaultSuppressedSchemas(), c.SuppressedSchemas)
+				require.False(t, c.IncludeTemporaryTables)
+				require.False(t, c.SuppressCachedTables)
+			},
+		},
+		{
+			name:     "default of the other protocols",
+			protocol: config.ProtocolOpen,
+			check: func(t *testing.T, c *Config) {
+				require.Nil(t, c.SuppressedSchemas)
+				require.True(t, c.IncludeTemporaryTables)
+			},
+		},
+		{
+			name:     "suppressed-schemas",
+			protocol: config.ProtocolCanal,
+			query:    "suppressed-schemas=" + url.QueryEscape("Sys, metrics_schema,,"),
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, []string{"sys", "metrics_schema"}, c.SuppressedSchemas)
+			},
+		},
+		{
+			name:     "none suppresses nothing",
+			protocol: config.ProtocolCanal,
+			query:    "suppressed-schemas=None",
+			check: func(t *testing.T, c *Config) {
+				require.Nil(t, c.SuppressedSchemas)
+			},
+		},
+		{
+			// present but empty is not mistaken for the defaults.
+			name:        "empty suppressed-schemas",
+			protocol:    config.ProtocolCanal,
+			query:       "suppressed-schemas=" + url.QueryEscape(" ,"),
+			validateErr: `suppressed-schemas is empty, set it to "none"`,
+		},
+		{
+			name:        "duplicate suppressed-schemas",
+			protocol:    config.ProtocolCanal,
+			adjust:      func(c *Config) { c.SuppressedSchemas = []string{"mysql", "MySQL"} },
+			validateErr: "duplicate schema mysql in suppressed-schemas",
+		},
+		{
+			name:        "empty schema in suppressed-schemas",
+			protocol:    config.ProtocolCanal,
+			adjust:      func(c *Config) { c.SuppressedSchemas = []string{""} },
+			validateErr: "empty schema in suppressed-schemas",
+		},
+		{
+			name:     "include-temporary-tables and suppress-cached-tables",
+			protocol: config.ProtocolCanal,
+			query:    "include-temporary-tables=true&suppress-cached-tables=true",
+			check: func(t *testing.T, c *Config) {
+				require.True(t, c.IncludeTemporaryTables)
+				require.True(t, c.SuppressCachedTables)
+			},
+		},
+	})
+}
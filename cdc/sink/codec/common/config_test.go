@@ -204,3 +204,62 @@ func TestConfigApplyValidate(t *testing.T) {
 	err = c.Validate()
 	require.ErrorContains(t, err, "invalid max-batch-size -1")
 }
+
+// configTestCase is a case of applying the options in the sink URI to the
+// config of the protocol, and validating the config applied.
+type configTestCase struct {
+	name     string
+	protocol config.Protocol
+	// query is the query of the sink URI applied.
+	query string
+	// replicaConfig is the replica config applied, the default one if nil.
+	replicaConfig *config.ReplicaConfig
+	// applyErr is the error expected of applying the sink URI, the config is
+	// not validated if it's set.
+	applyErr string
+	// check checks the config applied if set.
+	check func(t *testing.T, c *Config)
+	// adjust adjusts the config applied before it's validated if set.
+	adjust func(c *Config)
+	// validateErr is the error expected of validating the config, which is
+	// valid if it's empty.
+	validateErr string
+}
+
+// runConfigTestCases runs each of the cases in a subtest, so that a case
+// failing doesn't hide the others.
+func runConfigTestCases(t *testing.T, testCases []configTestCase) {
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sinkURI, err := url.Parse("kafka://127.0.0.1:9092/abc?" + tc.query)
+			require.NoError(t, err)
+			replicaConfig := tc.replicaConfig
+			if replicaConfig == nil {
+				replicaConfig = config.GetDefaultReplicaConfig()
+			}
+
+			c := NewConfig(tc.protocol)
+			err = c.Apply(sinkURI, replicaConfig)
+			if tc.applyErr != "" {
+				require.ErrorContains(t, err, tc.applyErr)
+				return
+			}
+			require.NoError(t, err)
+			if tc.check != nil {
+				tc.check(t, c)
+			}
+			if tc.adjust != nil {
+				tc.adjust(c)
+			}
+			err = c.Validate()
+			if tc.validateErr != "" {
+				require.ErrorContains(t, err, tc.validateErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
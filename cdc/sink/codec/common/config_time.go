@@ -0,0 +1,126 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// TimeOptions are the options of the timestamps emitted.
+type TimeOptions struct {
+	// MessageTimestamp is the timestamp of the records in the broker,
+	// it's one of MessageTimestampIngestion and MessageTimestampCommitTs.
+	MessageTimestamp string
+	// TimestampPrecision is the precision of the physical timestamps emitted,
+	// i.e. the execute time and the build time, it's one of
+	// TimestampPrecisionMillisecond and TimestampPrecisionMicrosecond. The
+	// finer part of the timestamps is always truncated instead of rounded,
+	// so a timestamp never goes past the time it stands for, and the physical
+	// time of the commit ts, which is in milliseconds, is emitted exactly.
+	TimestampPrecision string
+	// CommitTsOffset is added to the physical timestamps derived from the
+	// commit ts, i.e. the execute time, the timestamp of the records and the
+	// timestamp of the binlog events, to correct the skew of the PD clock.
+	// It's signed and in milliseconds in the sink URI.
+	CommitTsOffset time.Duration
+}
+
+const (
+	codecOPTMessageTimestamp   = "message-timestamp"
+	codecOPTTimestampPrecision = "timestamp-precision"
+	codecOPTCommitTsOffset     = "commit-ts-offset"
+)
+
+const (
+	// MessageTimestampIngestion leaves the timestamp of the records to the
+	// time they are sent
+	MessageTimestampIngestion = "ingestion"
+	// MessageTimestampCommitTs sets the timestamp of the records to the
+	// physical time of the commit ts of the events
+	MessageTimestampCommitTs = "commit-ts"
+	// TimestampPrecisionMillisecond emits the timestamps in milliseconds
+	TimestampPrecisionMillisecond = "millisecond"
+	// TimestampPrecisionMicrosecond emits the timestamps in microseconds
+	TimestampPrecisionMicrosecond = "microsecond"
+)
+
+// applyTimeOptions fills the TimeOptions by the params of the sink URI.
+func (c *Config) applyTimeOptions(params url.Values) error {
+	if s := params.Get(codecOPTMessageTimestamp); s != "" {
+		c.MessageTimestamp = s
+	}
+
+	if s := params.Get(codecOPTTimestampPrecision); s != "" {
+		c.TimestampPrecision = s
+	}
+
+	if s := params.Get(codecOPTCommitTsOffset); s != "" {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		c.CommitTsOffset = time.Duration(ms) * time.Millisecond
+	}
+
+	return nil
+}
+
+// validateTimeOptions validates the TimeOptions.
+func (c *Config) validateTimeOptions() error {
+	if c.MessageTimestamp != "" && c.MessageTimestamp != MessageTimestampIngestion {
+		if c.Protocol != config.ProtocolCanal {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`message-timestamp only supports canal protocol`,
+			)
+		}
+		if c.MessageTimestamp != MessageTimestampCommitTs {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTMessageTimestamp,
+				MessageTimestampIngestion,
+				MessageTimestampCommitTs,
+			)
+		}
+	}
+
+	if c.TimestampPrecision != "" && c.TimestampPrecision != TimestampPrecisionMillisecond {
+		if c.Protocol != config.ProtocolCanal && c.Protocol != config.ProtocolCanalJSON {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`timestamp-precision only supports canal/canal-json protocol`,
+			)
+		}
+		if c.TimestampPrecision != TimestampPrecisionMicrosecond {
+			return cerror.ErrCodecInvalidConfig.GenWithStack(
+				`%s value could only be "%s" or "%s"`,
+				codecOPTTimestampPrecision,
+				TimestampPrecisionMillisecond,
+				TimestampPrecisionMicrosecond,
+			)
+		}
+	}
+
+	if c.CommitTsOffset != 0 && c.Protocol != config.ProtocolCanal &&
+		c.Protocol != config.ProtocolCanalJSON && c.Protocol != config.ProtocolMySQLBinlog {
+		return cerror.ErrCodecInvalidConfig.GenWithStack(
+			`commit-ts-offset only supports canal/canal-json/mysql-binlog protocol`,
+		)
+	}
+
+	return nil
+}
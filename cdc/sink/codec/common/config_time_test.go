@@ -0,0 +1,103 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigTimeOptions(t *testing.T) {
+	t.Parallel()
+
+	runConfigTestCases(t, []configTestCase{
+		{
+			name:     "default",
+			protocol: config.ProtocolCanal,
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, MessageTimestampIngestion, c.MessageTimestamp)
+				require.Equal(t, TimestampPrecisionMillisecond, c.TimestampPrecision)
+				require.Zero(t, c.CommitTsOffset)
+			},
+		},
+		{
+			name:     "message-timestamp",
+			protocol: config.ProtocolCanal,
+			query:    "message-timestamp=commit-ts",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, MessageTimestampCommitTs, c.MessageTimestamp)
+			},
+		},
+		{
+			name:        "invalid message-timestamp",
+			protocol:    config.ProtocolCanal,
+			query:       "message-timestamp=now",
+			validateErr: `message-timestamp value could only be "ingestion" or "commit-ts"`,
+		},
+		{
+			name:        "message-timestamp on canal-json",
+			protocol:    config.ProtocolCanalJSON,
+			query:       "message-timestamp=commit-ts",
+			validateErr: "message-timestamp only supports canal protocol",
+		},
+		{
+			name:     "timestamp-precision",
+			protocol: config.ProtocolCanalJSON,
+			query:    "timestamp-precision=microsecond",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, TimestampPrecisionMicrosecond, c.TimestampPrecision)
+			},
+		},
+		{
+			name:     "timestamp-precision on canal",
+			protocol: config.ProtocolCanal,
+			query:    "timestamp-precision=microsecond",
+		},
+		{
+			name:        "invalid timestamp-precision",
+			protocol:    config.ProtocolCanal,
+			query:       "timestamp-precision=nanosecond",
+			validateErr: `timestamp-precision value could only be "millisecond" or "microsecond"`,
+		},
+		{
+			name:        "timestamp-precision on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "timestamp-precision=microsecond",
+			validateErr: "timestamp-precision only supports canal/canal-json protocol",
+		},
+		{
+			name:     "commit-ts-offset",
+			protocol: config.ProtocolCanalJSON,
+			query:    "commit-ts-offset=-1500",
+			check: func(t *testing.T, c *Config) {
+				require.Equal(t, -1500*time.Millisecond, c.CommitTsOffset)
+			},
+		},
+		{
+			name:        "commit-ts-offset on open-protocol",
+			protocol:    config.ProtocolOpen,
+			query:       "commit-ts-offset=-1500",
+			validateErr: "commit-ts-offset only supports canal/canal-json/mysql-binlog protocol",
+		},
+		{
+			name:     "commit-ts-offset not in milliseconds",
+			protocol: config.ProtocolCanal,
+			query:    "commit-ts-offset=1s",
+			applyErr: "invalid syntax",
+		},
+	})
+}
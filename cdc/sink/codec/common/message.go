@@ -15,8 +15,10 @@ package common
 
 import (
 	"encoding/binary"
+	"sort"
 	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/pingcap/tiflow/cdc/model"
 	"github.com/pingcap/tiflow/pkg/config"
 	"github.com/tikv/client-go/v2/oracle"
@@ -29,6 +31,27 @@ import (
 // which will be treated as `version = 2` by sarama producer.
 const MaxRecordOverhead = 5*binary.MaxVarintLen32 + binary.MaxVarintLen64 + 1
 
+// The keys of the headers carrying the metadata of the message, which the
+// broker counts toward the size of the message.
+const (
+	HeaderNamespace    = "namespace"
+	HeaderTTL          = "ttl"
+	HeaderSequence     = "sequence"
+	HeaderSchemaInfo   = "schema-info"
+	HeaderSignature    = "signature"
+	HeaderSigningKeyID = "signing-key-id"
+)
+
+// HeaderUint64Length is the length of the value of the headers carrying an
+// integer, e.g. the TTL in milliseconds and the sequence, in big endian.
+const HeaderUint64Length = 8
+
+// HeaderLength returns the expected size of a record header of the key and
+// the value of the length, the lengths of them are encoded as varints.
+func HeaderLength(key string, valueLength int) int {
+	return 2*binary.MaxVarintLen32 + len(key) + valueLength
+}
+
 // Message represents an message to the sink
 type Message struct {
 	Key       []byte
@@ -40,13 +63,115 @@ type Message struct {
 	Protocol  config.Protocol   // protocol
 	rowsCount int               // rows in one Message
 	Callback  func()            // Callback function will be called when the message is sent to the sink.
+	// Timestamp is the timestamp of the record in the broker,
+	// zero means the time the producer sends it.
+	Timestamp time.Time
+	// Namespace is the namespace of the changefeed emitting the message,
+	// it's only set if the encoder is built with EnableNamespace, and
+	// produced in the header of HeaderNamespace.
+	Namespace string
+	// TTL is the hint of the time to live of the message in the broker,
+	// zero means no TTL is hinted. It's produced in the header of HeaderTTL
+	// in milliseconds.
+	TTL time.Duration
+	// Sequence is the dense sequence of the message in the changefeed, which
+	// starts from 1, zero means it's not stamped, see EnableMessageSequence.
+	// It's produced in the header of HeaderSequence.
+	Sequence uint64
+	// SchemaInfo is the Pulsar SchemaInfo in the JSON form of the value,
+	// nil means no schema is attached, see EnablePulsarSchema. It's produced
+	// in the header of HeaderSchemaInfo.
+	SchemaInfo []byte
+	// Signature is the signature of the SignedBytes of the message by the key
+	// of the SigningKeyID, nil means it's not signed, see Signer. They're
+	// produced in the headers of HeaderSignature and HeaderSigningKeyID.
+	Signature    []byte
+	SigningKeyID string
+	// Headers are the headers copied from the columns of the rows, nil
+	// means none is copied, see ColumnHeaders. They're produced in the
+	// order of the keys.
+	Headers map[string]string
 }
 
-// Length returns the expected size of the Kafka message
-// We didn't append any `Headers` when send the message, so ignore the calculations related to it.
-// If `ProducerMessage` Headers fields used, this method should also adjust.
+// Length returns the expected size of the Kafka message, including the
+// headers carrying the metadata of it, see HeadersLength.
 func (m *Message) Length() int {
-	return len(m.Key) + len(m.Value) + MaxRecordOverhead
+	return len(m.Key) + len(m.Value) + m.HeadersLength() + MaxRecordOverhead
+}
+
+// HeadersLength returns the expected size of the headers carrying the
+// metadata of the message, which are only present if stamped.
+func (m *Message) HeadersLength() int {
+	length := 0
+	if m.Namespace != "" {
+		length += HeaderLength(HeaderNamespace, len(m.Namespace))
+	}
+	if m.TTL != 0 {
+		length += HeaderLength(HeaderTTL, HeaderUint64Length)
+	}
+	if m.Sequence != 0 {
+		length += HeaderLength(HeaderSequence, HeaderUint64Length)
+	}
+	if m.SchemaInfo != nil {
+		length += HeaderLength(HeaderSchemaInfo, len(m.SchemaInfo))
+	}
+	for key, value := range m.Headers {
+		length += HeaderLength(key, len(value))
+	}
+	if m.Signature != nil {
+		length += HeaderLength(HeaderSignature, len(m.Signature)) +
+			HeaderLength(HeaderSigningKeyID, len(m.SigningKeyID))
+	}
+	return length
+}
+
+// RecordHeaders returns the record headers carrying the metadata of the
+// message stamped and the Headers, which are produced along with it, nil if
+// none is stamped, since the headers are not supported by the Kafka before
+// 0.11.
+func (m *Message) RecordHeaders() []sarama.RecordHeader {
+	var headers []sarama.RecordHeader
+	if m.Namespace != "" {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(HeaderNamespace), Value: []byte(m.Namespace),
+		})
+	}
+	if m.TTL != 0 {
+		headers = append(headers, uint64Header(HeaderTTL, uint64(m.TTL.Milliseconds())))
+	}
+	if m.Sequence != 0 {
+		headers = append(headers, uint64Header(HeaderSequence, m.Sequence))
+	}
+	if m.SchemaInfo != nil {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(HeaderSchemaInfo), Value: m.SchemaInfo,
+		})
+	}
+	keys := make([]string, 0, len(m.Headers))
+	for key := range m.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(key), Value: []byte(m.Headers[key]),
+		})
+	}
+	if m.Signature != nil {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(HeaderSignature), Value: m.Signature,
+		}, sarama.RecordHeader{
+			Key: []byte(HeaderSigningKeyID), Value: []byte(m.SigningKeyID),
+		})
+	}
+	return headers
+}
+
+// uint64Header returns the record header carrying the integer in big endian.
+func uint64Header(key string, value uint64) sarama.RecordHeader {
+	header := sarama.RecordHeader{Key: []byte(key), Value: make([]byte, HeaderUint64Length)}
+	binary.BigEndian.PutUint64(header.Value, value)
+	return header
 }
 
 // PhysicalTime returns physical time part of Ts in time.Time
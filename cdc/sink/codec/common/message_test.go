@@ -15,7 +15,9 @@ package common
 
 import (
 	"testing"
+	"time"
 
+	"github.com/Shopify/sarama"
 	timodel "github.com/pingcap/tidb/parser/model"
 	"github.com/pingcap/tidb/parser/mysql"
 	"github.com/pingcap/tidb/parser/types"
@@ -113,3 +115,47 @@ func TestCreate(t *testing.T) {
 	require.Nil(t, msg.Table)
 	require.Equal(t, config.ProtocolCanal, msg.Protocol)
 }
+
+func TestLength(t *testing.T) {
+	t.Parallel()
+
+	msg := NewMsg(config.ProtocolOpen, []byte("key1"), []byte("value1"), 1234, model.MessageTypeRow, nil, nil)
+	require.Equal(t, 10+MaxRecordOverhead, msg.Length())
+	require.Zero(t, msg.HeadersLength())
+
+	// the headers of the metadata stamped are counted.
+	msg.Namespace = "tenant"
+	msg.TTL = time.Hour
+	msg.Sequence = 1
+	msg.SchemaInfo = []byte("{}")
+	headers := HeaderLength(HeaderNamespace, 6) + HeaderLength(HeaderTTL, HeaderUint64Length) +
+		HeaderLength(HeaderSequence, HeaderUint64Length) + HeaderLength(HeaderSchemaInfo, 2)
+	require.Equal(t, headers, msg.HeadersLength())
+	require.Equal(t, 10+headers+MaxRecordOverhead, msg.Length())
+}
+
+func TestRecordHeaders(t *testing.T) {
+	t.Parallel()
+
+	// nil if none is stamped, which is required by the Kafka before 0.11.
+	msg := NewMsg(config.ProtocolOpen, []byte("key1"), []byte("value1"), 1234, model.MessageTypeRow, nil, nil)
+	require.Nil(t, msg.RecordHeaders())
+
+	msg.Namespace = "tenant"
+	msg.TTL = time.Hour
+	msg.Sequence = 258
+	msg.SchemaInfo = []byte("{}")
+	msg.Headers = map[string]string{"region": "eu", "level": "1"}
+	msg.Signature = []byte("signature")
+	msg.SigningKeyID = "key-1"
+	require.Equal(t, []sarama.RecordHeader{
+		{Key: []byte(HeaderNamespace), Value: []byte("tenant")},
+		{Key: []byte(HeaderTTL), Value: []byte{0, 0, 0, 0, 0, 0x36, 0xee, 0x80}},
+		{Key: []byte(HeaderSequence), Value: []byte{0, 0, 0, 0, 0, 0, 1, 2}},
+		{Key: []byte(HeaderSchemaInfo), Value: []byte("{}")},
+		{Key: []byte("level"), Value: []byte("1")},
+		{Key: []byte("region"), Value: []byte("eu")},
+		{Key: []byte(HeaderSignature), Value: []byte("signature")},
+		{Key: []byte(HeaderSigningKeyID), Value: []byte("key-1")},
+	}, msg.RecordHeaders())
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"sort"
+	"time"
+
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// the algorithms of the signature of the messages.
+const (
+	SigningAlgorithmHMACSHA256 = "hmac-sha256"
+	SigningAlgorithmEd25519    = "ed25519"
+)
+
+// Signer signs the messages, so that the consumer verifies the authenticity
+// of them by the key of the KeyID, see SignedBytes. The signature is stamped
+// when the messages are built, so the signing must not fail, i.e. the key is
+// local to the signer.
+type Signer interface {
+	// KeyID returns the id of the key, by which the consumer looks up the
+	// key verifying the signature.
+	KeyID() string
+	// Size returns the length of the signature in bytes.
+	Size() int
+	// Sign returns the signature of the data.
+	Sign(data []byte) []byte
+}
+
+type hmacSigner struct {
+	keyID string
+	key   []byte
+}
+
+func (s *hmacSigner) KeyID() string { return s.keyID }
+
+func (s *hmacSigner) Size() int { return sha256.Size }
+
+func (s *hmacSigner) Sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write(data)
+	return mac.Sum(nil)
+}
+
+type ed25519Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+func (s *ed25519Signer) KeyID() string { return s.keyID }
+
+func (s *ed25519Signer) Size() int { return ed25519.SignatureSize }
+
+func (s *ed25519Signer) Sign(data []byte) []byte {
+	return ed25519.Sign(s.key, data)
+}
+
+// NewSigner returns the Signer of the algorithm by the key, which is the
+// secret of the HMAC-SHA256, or the PEM encoded PKCS #8 private key of the
+// Ed25519. The empty algorithm is the HMAC-SHA256.
+func NewSigner(algorithm, keyID string, key []byte) (Signer, error) {
+	switch algorithm {
+	case "", SigningAlgorithmHMACSHA256:
+		if len(key) == 0 {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(`empty signing key`)
+		}
+		return &hmacSigner{keyID: keyID, key: key}, nil
+	case SigningAlgorithmEd25519:
+		block, _ := pem.Decode(key)
+		if block == nil {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`the signing key of %s is not PEM encoded`, algorithm)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+		}
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+				`the signing key is not the private key of %s`, algorithm)
+		}
+		return &ed25519Signer{keyID: keyID, key: private}, nil
+	default:
+		return nil, cerror.ErrCodecInvalidConfig.GenWithStack(
+			`invalid signing-algorithm %s`, algorithm)
+	}
+}
+
+// SignedBytes returns the canonical bytes of the message signed, which are
+// the key, the value, the namespace and the schema info, each prefixed by
+// its length as an uvarint, followed by the TTL in milliseconds and the
+// sequence, each as an uint64 in big endian, followed by the number of the
+// Headers as an uvarint and the key and the value of each header in the order
+// of the keys, prefixed by the lengths as well. The metadata not stamped is
+// empty or zero.
+func SignedBytes(m *Message) []byte {
+	result := make([]byte, 0, len(m.Key)+len(m.Value)+len(m.Namespace)+
+		len(m.SchemaInfo)+4*binary.MaxVarintLen64+2*HeaderUint64Length)
+	for _, field := range [][]byte{m.Key, m.Value, []byte(m.Namespace), m.SchemaInfo} {
+		result = binary.AppendUvarint(result, uint64(len(field)))
+		result = append(result, field...)
+	}
+	result = binary.BigEndian.AppendUint64(result, uint64(m.TTL/time.Millisecond))
+	result = binary.BigEndian.AppendUint64(result, m.Sequence)
+	keys := make([]string, 0, len(m.Headers))
+	for key := range m.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result = binary.AppendUvarint(result, uint64(len(keys)))
+	for _, key := range keys {
+		for _, field := range []string{key, m.Headers[key]} {
+			result = binary.AppendUvarint(result, uint64(len(field)))
+			result = append(result, field...)
+		}
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strings"
+
+	tfilter "github.com/pingcap/tidb/util/table-filter"
+	"github.com/pingcap/tiflow/cdc/model"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
+)
+
+// TableMatcher matches the tables of a rule by a pattern in the syntax of
+// the table filter, e.g. `test.*` or "test.`t.2`", regardless of the case,
+// like the matchers of the dispatch rules.
+type TableMatcher struct {
+	// Pattern is the pattern the tables are matched by.
+	Pattern string
+	filter  tfilter.Filter
+}
+
+// NewTableMatcher creates a TableMatcher by the pattern, which matches a
+// single set of tables, so neither the negation nor the import of the
+// table filter is allowed.
+func NewTableMatcher(pattern string) (TableMatcher, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || strings.ContainsAny(pattern[:1], "!@.") {
+		return TableMatcher{}, cerror.ErrCodecInvalidConfig.GenWithStack(
+			`invalid table pattern %s`, pattern)
+	}
+	f, err := tfilter.Parse([]string{pattern})
+	if err != nil {
+		return TableMatcher{}, cerror.WrapError(cerror.ErrCodecInvalidConfig, err)
+	}
+	return TableMatcher{Pattern: pattern, filter: tfilter.CaseInsensitive(f)}, nil
+}
+
+// MustNewTableMatcher is like NewTableMatcher but panics on the invalid
+// pattern, it's used by the rules defined in the code, e.g. in the tests.
+func MustNewTableMatcher(pattern string) TableMatcher {
+	m, err := NewTableMatcher(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MatchTable returns whether the table is matched.
+func (m TableMatcher) MatchTable(table model.TableName) bool {
+	return m.filter != nil && m.filter.MatchTable(table.Schema, table.Table)
+}
+
+// wildcards returns the weight of the wildcards in the pattern, the ones in
+// the schema weigh more than the ones in the table, so that the rules of the
+// more specific tables are matched first.
+func (m TableMatcher) wildcards() int {
+	schema, table, _ := strings.Cut(m.Pattern, ".")
+	n := 0
+	if strings.ContainsAny(schema, "*?[") {
+		n += 2
+	}
+	if strings.ContainsAny(table, "*?[") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableMatcher(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		pattern string
+		matched []model.TableName
+		missed  []model.TableName
+	}{
+		{
+			pattern: "test.users",
+			matched: []model.TableName{{Schema: "test", Table: "users"}, {Schema: "TEST", Table: "Users"}},
+			missed:  []model.TableName{{Schema: "test", Table: "users1"}, {Schema: "test1", Table: "users"}},
+		},
+		{
+			pattern: "Test.*",
+			matched: []model.TableName{{Schema: "test", Table: "users"}, {Schema: "test", Table: "t.2"}},
+			missed:  []model.TableName{{Schema: "test1", Table: "users"}},
+		},
+		{
+			pattern: "test.`t.2`",
+			matched: []model.TableName{{Schema: "test", Table: "t.2"}},
+			missed:  []model.TableName{{Schema: "test", Table: "t"}},
+		},
+		{
+			pattern: "db?.t[0-9]",
+			matched: []model.TableName{{Schema: "db1", Table: "t2"}},
+			missed:  []model.TableName{{Schema: "db", Table: "t2"}, {Schema: "db1", Table: "ta"}},
+		},
+	} {
+		m, err := NewTableMatcher(tc.pattern)
+		require.NoError(t, err, tc.pattern)
+		require.Equal(t, tc.pattern, m.Pattern)
+		for _, table := range tc.matched {
+			require.True(t, m.MatchTable(table), "%s %s", tc.pattern, table)
+		}
+		for _, table := range tc.missed {
+			require.False(t, m.MatchTable(table), "%s %s", tc.pattern, table)
+		}
+	}
+
+	for _, pattern := range []string{"", "test", "test.", ".t", "test.t.2", "!test.t", "@rules.txt"} {
+		_, err := NewTableMatcher(pattern)
+		require.Error(t, err, pattern)
+	}
+
+	// the zero matcher matches nothing.
+	require.False(t, TableMatcher{}.MatchTable(model.TableName{Schema: "test", Table: "t"}))
+
+	require.Less(t, MustNewTableMatcher("test.t").wildcards(), MustNewTableMatcher("test.t*").wildcards())
+	require.Less(t, MustNewTableMatcher("test.*").wildcards(), MustNewTableMatcher("*.t").wildcards())
+}
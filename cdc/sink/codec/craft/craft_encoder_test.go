@@ -46,7 +46,8 @@ func TestCraftMaxMessageBytes(t *testing.T) {
 		require.Nil(t, err)
 	}
 
-	messages := encoder.Build()
+	messages, err := encoder.Build()
+	require.NoError(t, err)
 	for _, msg := range messages {
 		require.LessOrEqual(t, msg.Length(), 256)
 	}
@@ -73,7 +74,8 @@ func TestCraftMaxBatchSize(t *testing.T) {
 		require.Nil(t, err)
 	}
 
-	messages := encoder.Build()
+	messages, err := encoder.Build()
+	require.NoError(t, err)
 	sum := 0
 	for _, msg := range messages {
 		decoder, err := newBatchDecoder(msg.Value)
@@ -171,7 +173,8 @@ func testBatchCodec(
 		}
 		// test normal decode
 		if len(cs) > 0 {
-			res := encoder.Build()
+			res, err := encoder.Build()
+			require.NoError(t, err)
 			require.Len(t, res, 1)
 			decoder, err := newDecoder(res[0].Value)
 			require.Nil(t, err)
@@ -266,7 +269,8 @@ func TestCraftAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 
 	// Empty build makes sure that the callback build logic not broken.
-	msgs := encoder.Build()
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 0, "no message should be built and no panic")
 
 	// Append the events.
@@ -276,7 +280,8 @@ func TestCraftAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 	require.Equal(t, 0, count, "nothing should be called")
 
-	msgs = encoder.Build()
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 3, "expected 3 messages")
 	msgs[0].Callback()
 	require.Equal(t, 3, count, "expected 2 callbacks to be called")
@@ -68,9 +68,9 @@ func (b *BatchEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error)
 }
 
 // Build implements the EventBatchEncoder interface
-func (b *BatchEncoder) Build() (messages []*common.Message) {
+func (b *BatchEncoder) Build() ([]*common.Message, error) {
 	if b.batchSize == 0 {
-		return nil
+		return nil, nil
 	}
 
 	ret := common.NewMsg(config.ProtocolCsv, nil, b.valueBuf.Bytes(), 0, model.MessageTypeRow, nil, nil)
@@ -85,7 +85,7 @@ func (b *BatchEncoder) Build() (messages []*common.Message) {
 		b.valueBuf.Reset()
 		b.callbackBuf = make([]func(), 0)
 	}
-	return []*common.Message{ret}
+	return []*common.Message{ret}, nil
 }
 
 // newBatchEncoder creates a new csv BatchEncoder.
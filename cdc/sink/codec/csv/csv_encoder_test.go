@@ -63,7 +63,8 @@ func TestCSVBatchCodec(t *testing.T) {
 			err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
 			require.Nil(t, err)
 		}
-		messages := encoder.Build()
+		messages, err := encoder.Build()
+		require.NoError(t, err)
 		if len(cs) == 0 {
 			require.Nil(t, messages)
 			continue
@@ -126,7 +127,8 @@ func TestCSVAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 
 	// Empty build makes sure that the callback build logic not broken.
-	msgs := encoder.Build()
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 0, "no message should be built and no panic")
 
 	// Append the events.
@@ -136,7 +138,8 @@ func TestCSVAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 	require.Equal(t, 0, count, "nothing should be called")
 
-	msgs = encoder.Build()
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 1, "expected one message")
 	msgs[0].Callback()
 	require.Equal(t, 10, count, "expected all callbacks to be called")
@@ -29,6 +29,8 @@ const (
 type EventBatchEncoder interface {
 	// EncodeCheckpointEvent appends a checkpoint event into the batch.
 	// This event will be broadcast to all partitions to signal a global checkpoint.
+	// An encoder may also fan out the checkpoint into table-scoped watermarks,
+	// which are returned by Build and routed to the topic of each table.
 	EncodeCheckpointEvent(ts uint64) (*common.Message, error)
 	// AppendRowChangedEvent appends the calling context, a row changed event and the dispatch
 	// topic into the batch
@@ -36,7 +38,32 @@ type EventBatchEncoder interface {
 	// EncodeDDLEvent appends a DDL event into the batch
 	EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error)
 	// Build builds the batch and returns the bytes of key and value.
-	Build() []*common.Message
+	// The error is returned if the batch can not be built.
+	Build() ([]*common.Message, error)
+}
+
+// HeartbeatEncoder is an abstraction for the encoders supporting the heartbeat.
+type HeartbeatEncoder interface {
+	// EncodeHeartbeat encodes a heartbeat, which is a keepalive of the
+	// producer without any progress semantics.
+	EncodeHeartbeat(ts uint64) (*common.Message, error)
+}
+
+// FlushHintEncoder is an abstraction for the encoders hinting the sink to build
+// the batch early, besides the limits of the sink.
+type FlushHintEncoder interface {
+	// ShouldFlush returns true if the sink should Build the batch
+	// before appending more events.
+	ShouldFlush() bool
+}
+
+// DDLThrottledEncoder is an abstraction for the encoders limiting the rate of
+// the DDL events, the sink waits on it before encoding each DDL event.
+type DDLThrottledEncoder interface {
+	// WaitDDL blocks until the next DDL event is allowed to be emitted,
+	// or the context is done. The DDL events waiting are allowed in the
+	// order they call it.
+	WaitDDL(ctx context.Context) error
 }
 
 // EncoderBuilder builds encoder with context.
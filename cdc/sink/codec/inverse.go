@@ -0,0 +1,61 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+)
+
+// InverseRowChangedEvent returns the event undoing the change of e, i.e. the
+// downstream returns to the state before e is applied after applying it:
+//   - INSERT, which has Columns only, is inverted to a DELETE of the inserted
+//     row, whose PreColumns are the Columns of e.
+//   - DELETE, which has PreColumns only, is inverted to an INSERT of the
+//     deleted row, whose Columns are the PreColumns of e.
+//   - UPDATE, which has both, is inverted to an UPDATE with the images
+//     swapped, i.e. the PreColumns are the Columns of e, and vice versa.
+//
+// The other fields are copied from e, except the upstream Checksum, which is
+// computed on the image replaced by the inversion, and the SplitTxn, which
+// marks the first row of a transaction in the original order, they are both
+// cleared. The columns are shared with e rather than copied.
+func InverseRowChangedEvent(e *model.RowChangedEvent) *model.RowChangedEvent {
+	inverse := *e
+	inverse.PreColumns, inverse.Columns = e.Columns, e.PreColumns
+	inverse.Checksum = nil
+	inverse.SplitTxn = false
+	return &inverse
+}
+
+// EncodeInverse encodes the inverse of the events in the reverse order by the
+// encoder, so that applying the result undoes the changes of the events, e.g.
+// to roll the downstream back to the point in time before the events. The
+// events must be in the order they are applied, and the encoder must be built
+// for it, since the messages already in the batch are returned as well.
+func EncodeInverse(
+	ctx context.Context, encoder EventBatchEncoder, topic string, events []*model.RowChangedEvent,
+) ([]*common.Message, error) {
+	for i := len(events) - 1; i >= 0; i-- {
+		inverse := InverseRowChangedEvent(events[i])
+		if err := encoder.AppendRowChangedEvent(ctx, topic, inverse, nil); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	messages, err := encoder.Build()
+	return messages, errors.Trace(err)
+}
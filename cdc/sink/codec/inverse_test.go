@@ -0,0 +1,137 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEncoder records the appended events, and builds a message for each.
+type recordingEncoder struct {
+	events []*model.RowChangedEvent
+}
+
+func (e *recordingEncoder) EncodeCheckpointEvent(uint64) (*common.Message, error) {
+	return nil, nil
+}
+
+func (e *recordingEncoder) AppendRowChangedEvent(
+	_ context.Context, _ string, event *model.RowChangedEvent, _ func(),
+) error {
+	e.events = append(e.events, event)
+	return nil
+}
+
+func (e *recordingEncoder) EncodeDDLEvent(*model.DDLEvent) (*common.Message, error) {
+	return nil, nil
+}
+
+func (e *recordingEncoder) Build() ([]*common.Message, error) {
+	messages := make([]*common.Message, 0, len(e.events))
+	for _, event := range e.events {
+		messages = append(messages, &common.Message{Ts: event.CommitTs})
+	}
+	return messages, nil
+}
+
+func TestInverseRowChangedEvent(t *testing.T) {
+	t.Parallel()
+
+	oldImage := []*model.Column{
+		{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag, Value: 1},
+		{Name: "name", Type: mysql.TypeVarchar, Value: "Alice"},
+	}
+	newImage := []*model.Column{
+		{Name: "id", Type: mysql.TypeLong, Flag: model.HandleKeyFlag, Value: 1},
+		{Name: "name", Type: mysql.TypeVarchar, Value: "Bob"},
+	}
+	table := &model.TableName{Schema: "test", Table: "t"}
+
+	// the inverse of an update swaps the old and new images.
+	update := &model.RowChangedEvent{
+		StartTs:    1,
+		CommitTs:   2,
+		Table:      table,
+		PreColumns: oldImage,
+		Columns:    newImage,
+		SplitTxn:   true,
+		Checksum:   &model.RowChecksum{Algorithm: "crc32", Value: "12345"},
+	}
+	inverse := InverseRowChangedEvent(update)
+	require.True(t, inverse.IsUpdate())
+	require.Equal(t, newImage, inverse.PreColumns)
+	require.Equal(t, oldImage, inverse.Columns)
+	require.Equal(t, update.CommitTs, inverse.CommitTs)
+	require.Equal(t, table, inverse.Table)
+	require.Nil(t, inverse.Checksum)
+	require.False(t, inverse.SplitTxn)
+	// the original event is untouched.
+	require.Equal(t, oldImage, update.PreColumns)
+	require.Equal(t, newImage, update.Columns)
+	require.NotNil(t, update.Checksum)
+	// inverting twice gives the images of the original event.
+	twice := InverseRowChangedEvent(inverse)
+	require.Equal(t, update.PreColumns, twice.PreColumns)
+	require.Equal(t, update.Columns, twice.Columns)
+
+	// the inverse of an insert deletes the inserted row.
+	insert := &model.RowChangedEvent{Table: table, Columns: newImage}
+	inverse = InverseRowChangedEvent(insert)
+	require.True(t, inverse.IsDelete())
+	require.Equal(t, newImage, inverse.PreColumns)
+	require.Nil(t, inverse.Columns)
+
+	// the inverse of a delete inserts the deleted row.
+	del := &model.RowChangedEvent{Table: table, PreColumns: oldImage}
+	inverse = InverseRowChangedEvent(del)
+	require.True(t, inverse.IsInsert())
+	require.Equal(t, oldImage, inverse.Columns)
+	require.Nil(t, inverse.PreColumns)
+}
+
+func TestEncodeInverse(t *testing.T) {
+	t.Parallel()
+
+	table := &model.TableName{Schema: "test", Table: "t"}
+	row := func(id int64) []*model.Column {
+		return []*model.Column{{Name: "id", Type: mysql.TypeLong, Value: id}}
+	}
+	events := []*model.RowChangedEvent{
+		{CommitTs: 1, Table: table, Columns: row(1)},
+		{CommitTs: 2, Table: table, PreColumns: row(1), Columns: row(2)},
+		{CommitTs: 3, Table: table, PreColumns: row(2)},
+	}
+
+	encoder := &recordingEncoder{}
+	messages, err := EncodeInverse(context.Background(), encoder, "", events)
+	require.Nil(t, err)
+	require.Len(t, messages, 3)
+	// the inverses are encoded in the reverse order.
+	for i, ts := range []uint64{3, 2, 1} {
+		require.Equal(t, ts, messages[i].Ts)
+	}
+	require.True(t, encoder.events[0].IsInsert())
+	require.Equal(t, row(2), encoder.events[0].Columns)
+	require.True(t, encoder.events[1].IsUpdate())
+	require.Equal(t, row(2), encoder.events[1].PreColumns)
+	require.Equal(t, row(1), encoder.events[1].Columns)
+	require.True(t, encoder.events[2].IsDelete())
+	require.Equal(t, row(1), encoder.events[2].PreColumns)
+}
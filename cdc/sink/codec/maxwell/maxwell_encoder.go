@@ -77,9 +77,9 @@ func (d *BatchEncoder) EncodeDDLEvent(e *model.DDLEvent) (*common.Message, error
 }
 
 // Build implements the EventBatchEncoder interface
-func (d *BatchEncoder) Build() []*common.Message {
+func (d *BatchEncoder) Build() ([]*common.Message, error) {
 	if d.batchSize == 0 {
-		return nil
+		return nil, nil
 	}
 
 	ret := common.NewMsg(config.ProtocolMaxwell,
@@ -95,7 +95,7 @@ func (d *BatchEncoder) Build() []*common.Message {
 		d.callbackBuf = make([]func(), 0)
 	}
 	d.reset()
-	return []*common.Message{ret}
+	return []*common.Message{ret}, nil
 }
 
 // reset implements the EventBatchEncoder interface
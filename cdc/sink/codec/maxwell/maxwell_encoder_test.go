@@ -38,7 +38,8 @@ func TestMaxwellBatchCodec(t *testing.T) {
 			err := encoder.AppendRowChangedEvent(context.Background(), "", row, nil)
 			require.Nil(t, err)
 		}
-		messages := encoder.Build()
+		messages, err := encoder.Build()
+		require.NoError(t, err)
 		if len(cs) == 0 {
 			require.Nil(t, messages)
 			continue
@@ -121,7 +122,8 @@ func TestMaxwellAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 
 	// Empty build makes sure that the callback build logic not broken.
-	msgs := encoder.Build()
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 0, "no message should be built and no panic")
 
 	// Append the events.
@@ -131,7 +133,8 @@ func TestMaxwellAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 	require.Equal(t, 0, count, "nothing should be called")
 
-	msgs = encoder.Build()
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 1, "expected one message")
 	msgs[0].Callback()
 	require.Equal(t, 15, count, "expected all callbacks to be called")
@@ -23,7 +23,6 @@ import (
 	"github.com/pingcap/tiflow/cdc/model"
 	"github.com/pingcap/tiflow/cdc/sink/codec"
 	"github.com/pingcap/tiflow/cdc/sink/codec/common"
-
 	"github.com/pingcap/tiflow/pkg/config"
 	cerror "github.com/pingcap/tiflow/pkg/errors"
 	"go.uber.org/zap"
@@ -35,9 +34,22 @@ type BatchEncoder struct {
 	callbackBuff []func()
 	curBatchSize int
 
+	// curTxn is the transaction of the rows of the last message, it's only
+	// used when the TxnAligned is set.
+	curTxn txnKey
+
 	// configs
 	MaxMessageBytes int
 	MaxBatchSize    int
+	// TxnAligned batches the rows of each transaction into a message of its
+	// own, regardless of the MaxBatchSize, see common.Config.
+	TxnAligned bool
+}
+
+// txnKey identifies the transaction of a row.
+type txnKey struct {
+	startTs  uint64
+	commitTs uint64
 }
 
 // AppendRowChangedEvent implements the EventBatchEncoder interface
@@ -71,9 +83,24 @@ func (d *BatchEncoder) AppendRowChangedEvent(
 		return cerror.ErrOpenProtocolCodecRowTooLarge.GenWithStackByArgs()
 	}
 
-	if len(d.messageBuf) == 0 ||
+	newMessage := len(d.messageBuf) == 0 ||
 		d.curBatchSize >= d.MaxBatchSize ||
-		d.messageBuf[len(d.messageBuf)-1].Length()+len(key)+len(value)+16 > d.MaxMessageBytes {
+		d.messageBuf[len(d.messageBuf)-1].Length()+len(key)+len(value)+16 > d.MaxMessageBytes
+	if d.TxnAligned {
+		// the message is only finalized at the transaction boundary, and
+		// the transaction can not be split into the messages.
+		txn := txnKey{startTs: e.StartTs, commitTs: e.CommitTs}
+		newMessage = len(d.messageBuf) == 0 || txn != d.curTxn
+		if !newMessage &&
+			d.messageBuf[len(d.messageBuf)-1].Length()+len(key)+len(value)+16 > d.MaxMessageBytes {
+			log.Warn("Single transaction too large",
+				zap.Int("max-message-size", d.MaxMessageBytes), zap.Uint64("startTs", e.StartTs),
+				zap.Any("table", e.Table))
+			return cerror.ErrOpenProtocolCodecTxnTooLarge.GenWithStackByArgs(e.StartTs, d.MaxMessageBytes)
+		}
+		d.curTxn = txn
+	}
+	if newMessage {
 		// Before we create a new message, we should handle the previous callbacks.
 		d.tryBuildCallback()
 		versionHead := make([]byte, 8)
@@ -161,11 +188,11 @@ func (d *BatchEncoder) EncodeCheckpointEvent(ts uint64) (*common.Message, error)
 }
 
 // Build implements the EventBatchEncoder interface
-func (d *BatchEncoder) Build() (messages []*common.Message) {
+func (d *BatchEncoder) Build() ([]*common.Message, error) {
 	d.tryBuildCallback()
 	ret := d.messageBuf
 	d.messageBuf = make([]*common.Message, 0)
-	return ret
+	return ret, nil
 }
 
 // tryBuildCallback will collect all the callbacks into one message's callback.
@@ -191,6 +218,7 @@ func (b *batchEncoderBuilder) Build() codec.EventBatchEncoder {
 	encoder := NewBatchEncoder()
 	encoder.(*BatchEncoder).MaxMessageBytes = b.config.MaxMessageBytes
 	encoder.(*BatchEncoder).MaxBatchSize = b.config.MaxBatchSize
+	encoder.(*BatchEncoder).TxnAligned = b.config.EnableTxnAlignedBatch
 
 	return encoder
 }
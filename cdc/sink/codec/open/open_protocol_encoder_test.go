@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/tiflow/cdc/sink/codec/common"
 	"github.com/pingcap/tiflow/cdc/sink/codec/internal"
 	"github.com/pingcap/tiflow/pkg/config"
+	cerror "github.com/pingcap/tiflow/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -71,7 +72,8 @@ func TestMaxMessageBytes(t *testing.T) {
 		require.Nil(t, err)
 	}
 
-	messages := encoder.Build()
+	messages, err := encoder.Build()
+	require.NoError(t, err)
 	for _, msg := range messages {
 		require.LessOrEqual(t, msg.Length(), 256)
 	}
@@ -98,7 +100,8 @@ func TestMaxBatchSize(t *testing.T) {
 		require.Nil(t, err)
 	}
 
-	messages := encoder.Build()
+	messages, err := encoder.Build()
+	require.NoError(t, err)
 	sum := 0
 	for _, msg := range messages {
 		decoder, err := NewBatchDecoder(msg.Key, msg.Value)
@@ -122,6 +125,75 @@ func TestMaxBatchSize(t *testing.T) {
 	require.Equal(t, 10000, sum)
 }
 
+func TestTxnAlignedBatch(t *testing.T) {
+	t.Parallel()
+	config := common.NewConfig(config.ProtocolOpen).WithMaxMessageBytes(1048576)
+	config.MaxBatchSize = 2
+	config.EnableTxnAlignedBatch = true
+	encoder := NewBatchEncoderBuilder(config).Build()
+
+	newRow := func(startTs, commitTs uint64) *model.RowChangedEvent {
+		return &model.RowChangedEvent{
+			StartTs:  startTs,
+			CommitTs: commitTs,
+			Table:    &model.TableName{Schema: "a", Table: "b"},
+			Columns: []*model.Column{{
+				Name:  "col1",
+				Type:  mysql.TypeVarchar,
+				Value: []byte("aa"),
+			}},
+		}
+	}
+	// the transactions of 3 rows, 1 row and 2 rows, the first of which
+	// exceeds the max batch size.
+	txns := []struct {
+		startTs, commitTs uint64
+		rows              int
+	}{{1, 2, 3}, {3, 4, 1}, {5, 6, 2}}
+	for _, txn := range txns {
+		for i := 0; i < txn.rows; i++ {
+			err := encoder.AppendRowChangedEvent(context.Background(), "", newRow(txn.startTs, txn.commitTs), nil)
+			require.NoError(t, err)
+		}
+	}
+
+	// each message carries the rows of a single transaction.
+	messages, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, messages, len(txns))
+	for i, msg := range messages {
+		require.Equal(t, txns[i].rows, msg.GetRowsCount())
+		decoder, err := NewBatchDecoder(msg.Key, msg.Value)
+		require.NoError(t, err)
+		count := 0
+		for {
+			_, hasNext, err := decoder.HasNext()
+			require.NoError(t, err)
+			if !hasNext {
+				break
+			}
+			row, err := decoder.NextRowChangedEvent()
+			require.NoError(t, err)
+			require.Equal(t, txns[i].commitTs, row.CommitTs)
+			count++
+		}
+		require.Equal(t, txns[i].rows, count)
+	}
+
+	// the transaction exceeding the max message bytes fails the encoding,
+	// instead of being split, while a single row of it fits.
+	encoder = NewBatchEncoderBuilder(config.WithMaxMessageBytes(200)).Build()
+	err = encoder.AppendRowChangedEvent(context.Background(), "", newRow(1, 2), nil)
+	require.NoError(t, err)
+	err = encoder.AppendRowChangedEvent(context.Background(), "", newRow(1, 2), nil)
+	require.True(t, cerror.ErrOpenProtocolCodecTxnTooLarge.Equal(err))
+	err = encoder.AppendRowChangedEvent(context.Background(), "", newRow(3, 4), nil)
+	require.NoError(t, err)
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+}
+
 func TestOpenProtocolAppendRowChangedEventWithCallback(t *testing.T) {
 	t.Parallel()
 
@@ -182,7 +254,8 @@ func TestOpenProtocolAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 
 	// Empty build makes sure that the callback build logic not broken.
-	msgs := encoder.Build()
+	msgs, err := encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 0, "no message should be built and no panic")
 
 	// Append the events.
@@ -192,7 +265,8 @@ func TestOpenProtocolAppendRowChangedEventWithCallback(t *testing.T) {
 	}
 	require.Equal(t, 0, count, "nothing should be called")
 
-	msgs = encoder.Build()
+	msgs, err = encoder.Build()
+	require.NoError(t, err)
 	require.Len(t, msgs, 3, "expected 3 messages")
 	msgs[0].Callback()
 	require.Equal(t, 3, count, "expected 2 callbacks be called")
@@ -126,6 +126,12 @@ func (s *EventRouter) GetTopicForRowChange(row *model.RowChangedEvent) string {
 	return topicDispatcher.Substitute(row.Table.Schema, row.Table.Table)
 }
 
+// GetTopicForTable returns the target topic for the table.
+func (s *EventRouter) GetTopicForTable(table model.TableName) string {
+	topicDispatcher, _ := s.matchDispatcher(table.Schema, table.Table)
+	return topicDispatcher.Substitute(table.Schema, table.Table)
+}
+
 // GetTopicForDDL returns the target topic for DDL.
 func (s *EventRouter) GetTopicForDDL(ddl *model.DDLEvent) string {
 	var schema, table string
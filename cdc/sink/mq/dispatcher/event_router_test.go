@@ -207,6 +207,11 @@ func TestGetTopicForRowChange(t *testing.T) {
 		Table: &model.TableName{Schema: "a", Table: "table"},
 	})
 	require.Equal(t, "a_table", topicName)
+
+	topicName = d.GetTopicForTable(model.TableName{Schema: "test_table", Table: "table"})
+	require.Equal(t, "hello_test_table_world", topicName)
+	topicName = d.GetTopicForTable(model.TableName{Schema: "test_default1", Table: "table"})
+	require.Equal(t, "test", topicName)
 }
 
 func TestGetPartitionForRowChange(t *testing.T) {
@@ -18,6 +18,7 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/pingcap/errors"
@@ -36,6 +37,7 @@ import (
 	"github.com/pingcap/tiflow/pkg/config"
 	cerror "github.com/pingcap/tiflow/pkg/errors"
 	"github.com/pingcap/tiflow/pkg/util"
+	"github.com/tikv/client-go/v2/oracle"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
@@ -50,6 +52,10 @@ type mqSink struct {
 	eventRouter    *dispatcher.EventRouter
 	encoderBuilder codec.EncoderBuilder
 	protocol       config.Protocol
+	// suppressor drops the events suppressed before they're encoded.
+	suppressor *builder.EventSuppressor
+	// protocolRouter routes the messages of the other protocols.
+	protocolRouter *ProtocolRouter
 
 	topicManager         manager.TopicManager
 	flushWorker          *flushWorker
@@ -57,6 +63,12 @@ type mqSink struct {
 	resolvedBuffer       *chann.Chann[resolvedTsEvent]
 
 	statistics *metrics.Statistics
+	// heartbeatInterval is the interval to emit the heartbeat,
+	// 0 means the heartbeat is disabled.
+	heartbeatInterval time.Duration
+	// broadcastDDL indicates whether the DDL is sent to all partitions,
+	// regardless of the protocol.
+	broadcastDDL bool
 
 	role util.Role
 	id   model.ChangeFeedID
@@ -86,13 +98,17 @@ func newMqSink(
 
 	encoder := encoderBuilder.Build()
 	statistics := metrics.NewStatistics(ctx, captureAddr, metrics.SinkTypeMQ)
-	flushWorker := newFlushWorker(encoder, mqProducer, statistics)
+	protocolRouter := NewProtocolRouter(encoderConfig, topicManager)
+	flushWorker := newFlushWorker(encoder, mqProducer, protocolRouter, statistics)
 
 	s := &mqSink{
 		mqProducer:     mqProducer,
 		eventRouter:    eventRouter,
 		encoderBuilder: encoderBuilder,
 		protocol:       encoderConfig.Protocol,
+		suppressor:     builder.NewEventSuppressor(encoderConfig),
+		protocolRouter: protocolRouter,
+		broadcastDDL:   encoderConfig.BroadcastDDL,
 		topicManager:   topicManager,
 		flushWorker:    flushWorker,
 		resolvedBuffer: chann.New[resolvedTsEvent](),
@@ -100,6 +116,9 @@ func newMqSink(
 		role:           role,
 		id:             changefeedID,
 	}
+	if _, ok := encoder.(codec.HeartbeatEncoder); ok {
+		s.heartbeatInterval = encoderConfig.HeartbeatInterval
+	}
 
 	go func() {
 		if err := s.run(ctx); err != nil && errors.Cause(err) != context.Canceled {
@@ -137,6 +156,9 @@ func (k *mqSink) AddTable(tableID model.TableID) error {
 func (k *mqSink) EmitRowChangedEvents(ctx context.Context, rows ...*model.RowChangedEvent) error {
 	rowsCount := 0
 	for _, row := range rows {
+		if k.suppressor.SuppressRow(row) {
+			continue
+		}
 		topic := k.eventRouter.GetTopicForRowChange(row)
 		partitionNum, err := k.topicManager.GetPartitionNum(topic)
 		if err != nil {
@@ -240,14 +262,48 @@ func (k *mqSink) EmitCheckpointTs(ctx context.Context, ts uint64, tables []*mode
 	if err != nil {
 		return errors.Trace(err)
 	}
+	// The table-scoped watermarks fanned out by the encoder
+	// are broadcast to the topic of each table, while the checkpoints
+	// encoded by the other protocols are emitted as the one returned.
+	watermarks, err := encoder.Build()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, watermark := range watermarks {
+		if watermark.Schema == nil {
+			if err := k.emitCheckpoint(ctx, ts, watermark, tables); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+		topic := k.protocolRouter.Topic(k.eventRouter.GetTopicForTable(model.TableName{
+			Schema: *watermark.Schema, Table: *watermark.Table,
+		}), watermark)
+		partitionNum, err := k.topicManager.GetPartitionNum(topic)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		err = k.mqProducer.SyncBroadcastMessage(ctx, topic, partitionNum, watermark)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
 	if msg == nil {
 		return nil
 	}
+	return k.emitCheckpoint(ctx, ts, msg, tables)
+}
+
+// emitCheckpoint broadcasts the checkpoint message to the default topic or
+// the topics of all tables, routed by the protocol of the message.
+func (k *mqSink) emitCheckpoint(
+	ctx context.Context, ts uint64, msg *common.Message, tables []*model.TableInfo,
+) error {
 	// NOTICE: When there is no table sync,
 	// we need to send checkpoint ts to the default topic. T
 	// This will be compatible with the old behavior.
 	if len(tables) == 0 {
-		topic := k.eventRouter.GetDefaultTopic()
+		topic := k.protocolRouter.Topic(k.eventRouter.GetDefaultTopic(), msg)
 		partitionNum, err := k.topicManager.GetPartitionNum(topic)
 		if err != nil {
 			return errors.Trace(err)
@@ -263,7 +319,14 @@ func (k *mqSink) EmitCheckpointTs(ctx context.Context, ts uint64, tables []*mode
 	}
 	topics := k.eventRouter.GetActiveTopics(tableNames)
 	log.Debug("MQ sink current active topics", zap.Any("topics", topics))
+	// the topics may be routed to the same topic of the protocol.
+	emitted := make(map[string]struct{}, len(topics))
 	for _, topic := range topics {
+		topic = k.protocolRouter.Topic(topic, msg)
+		if _, ok := emitted[topic]; ok {
+			continue
+		}
+		emitted[topic] = struct{}{}
 		partitionNum, err := k.topicManager.GetPartitionNum(topic)
 		if err != nil {
 			return errors.Trace(err)
@@ -281,17 +344,31 @@ func (k *mqSink) EmitCheckpointTs(ctx context.Context, ts uint64, tables []*mode
 // EmitDDLEvent sends a DDL event to the default topic or the table's corresponding topic.
 // Concurrency Note: EmitDDLEvent is thread-safe.
 func (k *mqSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
+	if k.suppressor.SuppressDDL(ddl) {
+		return nil
+	}
 	encoder := k.encoderBuilder.Build()
+	if throttled, ok := encoder.(codec.DDLThrottledEncoder); ok {
+		if err := throttled.WaitDDL(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	msg, err := encoder.EncodeDDLEvent(ddl)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	if msg == nil {
+	// the DDL encoded by the other protocols are returned by Build.
+	msgs, err := encoder.Build()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if msg != nil {
+		msgs = append([]*common.Message{msg}, msgs...)
+	}
+	if len(msgs) == 0 {
 		return nil
 	}
 
-	topic := k.eventRouter.GetTopicForDDL(ddl)
-	partitionRule := k.eventRouter.GetDLLDispatchRuleByProtocol(k.protocol)
 	k.statistics.AddDDLCount()
 	log.Debug("emit ddl event",
 		zap.Uint64("commitTs", ddl.CommitTs),
@@ -299,6 +376,22 @@ func (k *mqSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
 		zap.String("namespace", k.id.Namespace),
 		zap.String("changefeed", k.id.ID),
 		zap.Any("role", k.role))
+	for _, msg := range msgs {
+		if err := k.emitDDL(ctx, ddl, msg); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// emitDDL sends the DDL message to the topic of the DDL,
+// routed by the protocol of the message.
+func (k *mqSink) emitDDL(ctx context.Context, ddl *model.DDLEvent, msg *common.Message) error {
+	topic := k.protocolRouter.Topic(k.eventRouter.GetTopicForDDL(ddl), msg)
+	partitionRule := k.eventRouter.GetDLLDispatchRuleByProtocol(msg.Protocol)
+	if k.broadcastDDL {
+		partitionRule = dispatcher.PartitionAll
+	}
 	if partitionRule == dispatcher.PartitionAll {
 		partitionNum, err := k.topicManager.GetPartitionNum(topic)
 		if err != nil {
@@ -311,7 +404,7 @@ func (k *mqSink) EmitDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
 	// which will be responsible for automatically creating topics when they don't exist.
 	// If it is not called here and kafka has `auto.create.topics.enable` turned on,
 	// then the auto-created topic will not be created as configured by ticdc.
-	_, err = k.topicManager.GetPartitionNum(topic)
+	_, err := k.topicManager.GetPartitionNum(topic)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -360,9 +453,58 @@ func (k *mqSink) run(ctx context.Context) error {
 	wg.Go(func() error {
 		return k.flushWorker.run(ctx)
 	})
+	if k.heartbeatInterval > 0 {
+		wg.Go(func() error {
+			return k.bgHeartbeat(ctx)
+		})
+	}
 	return wg.Wait()
 }
 
+// bgHeartbeat emits a heartbeat to the default topic periodically,
+// so that the consumers can tell an idle changefeed from a stalled one.
+// The encoders wrapping the one of the protocol forward the heartbeat,
+// which is nil if the protocol doesn't support it. The heartbeats encoded by
+// the other protocols are returned by Build, and sent to their topics.
+func (k *mqSink) bgHeartbeat(ctx context.Context) error {
+	ticker := time.NewTicker(k.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case now := <-ticker.C:
+			encoder := k.encoderBuilder.Build()
+			heartbeat, ok := encoder.(codec.HeartbeatEncoder)
+			if !ok {
+				return nil
+			}
+			msg, err := heartbeat.EncodeHeartbeat(oracle.GoTimeToTS(now))
+			if err != nil {
+				return errors.Trace(err)
+			}
+			msgs, err := encoder.Build()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if msg != nil {
+				msgs = append([]*common.Message{msg}, msgs...)
+			}
+			for _, msg := range msgs {
+				topic := k.protocolRouter.Topic(k.eventRouter.GetDefaultTopic(), msg)
+				partitionNum, err := k.topicManager.GetPartitionNum(topic)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				err = k.mqProducer.SyncBroadcastMessage(ctx, topic, partitionNum, msg)
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+		}
+	}
+}
+
 // asyncFlushToPartitionZero writes message to
 // partition zero asynchronously and flush it immediately.
 func (k *mqSink) asyncFlushToPartitionZero(
@@ -426,6 +568,12 @@ func NewKafkaSaramaSink(ctx context.Context, sinkURI *url.URL,
 	// always set encoder's `MaxMessageBytes` equal to producer's `MaxMessageBytes`
 	// to prevent that the encoder generate batched message too large then cause producer meet `message too large`
 	encoderConfig = encoderConfig.WithMaxMessageBytes(saramaConfig.Producer.MaxMessageBytes)
+	// the record headers are not supported by the Kafka before 0.11.
+	encoderConfig = encoderConfig.WithHeadersUnsupported(
+		!saramaConfig.Version.IsAtLeast(sarama.V0_11_0_0))
+	// the partition count has been adjusted to the topic's real one,
+	// make the encoder aware of it.
+	encoderConfig = encoderConfig.WithPartitionNum(baseConfig.PartitionNum)
 
 	if err := encoderConfig.Validate(); err != nil {
 		return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
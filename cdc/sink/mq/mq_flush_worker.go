@@ -62,23 +62,26 @@ type flushWorker struct {
 	// It is also used to notify that the flush has completed.
 	needsFlush chan<- struct{}
 
-	encoder    codec.EventBatchEncoder
-	producer   producer.Producer
-	statistics *metrics.Statistics
+	encoder        codec.EventBatchEncoder
+	producer       producer.Producer
+	protocolRouter *ProtocolRouter
+	statistics     *metrics.Statistics
 }
 
 // newFlushWorker creates a new flush worker.
 func newFlushWorker(
 	encoder codec.EventBatchEncoder,
 	producer producer.Producer,
+	protocolRouter *ProtocolRouter,
 	statistics *metrics.Statistics,
 ) *flushWorker {
 	w := &flushWorker{
-		msgChan:    chann.New[mqEvent](),
-		ticker:     time.NewTicker(FlushInterval),
-		encoder:    encoder,
-		producer:   producer,
-		statistics: statistics,
+		msgChan:        chann.New[mqEvent](),
+		ticker:         time.NewTicker(FlushInterval),
+		encoder:        encoder,
+		producer:       producer,
+		protocolRouter: protocolRouter,
+		statistics:     statistics,
 	}
 	return w
 }
@@ -167,20 +170,15 @@ func (w *flushWorker) asyncSend(
 			if err != nil {
 				return err
 			}
-		}
-
-		err := w.statistics.RecordBatchExecution(func() (int, error) {
-			thisBatchSize := 0
-			for _, message := range w.encoder.Build() {
-				err := w.producer.AsyncSendMessage(ctx, key.Topic, key.Partition, message)
-				if err != nil {
-					return 0, err
+			// build the batch early if the encoder asks to.
+			if hint, ok := w.encoder.(codec.FlushHintEncoder); ok && hint.ShouldFlush() {
+				if err := w.sendBatch(ctx, key); err != nil {
+					return err
 				}
-				thisBatchSize += message.GetRowsCount()
 			}
-			return thisBatchSize, nil
-		})
-		if err != nil {
+		}
+
+		if err := w.sendBatch(ctx, key); err != nil {
 			return err
 		}
 		w.statistics.ObserveRows(events...)
@@ -196,6 +194,31 @@ func (w *flushWorker) asyncSend(
 	return nil
 }
 
+// sendBatch builds the batch of the encoder and sends the messages to the
+// partition of the key, the messages of the other protocols are sent to the
+// topics of theirs.
+func (w *flushWorker) sendBatch(ctx context.Context, key TopicPartitionKey) error {
+	return w.statistics.RecordBatchExecution(func() (int, error) {
+		thisBatchSize := 0
+		messages, err := w.encoder.Build()
+		if err != nil {
+			return 0, err
+		}
+		for _, message := range messages {
+			routed, err := w.protocolRouter.Route(key, message)
+			if err != nil {
+				return 0, err
+			}
+			err = w.producer.AsyncSendMessage(ctx, routed.Topic, routed.Partition, message)
+			if err != nil {
+				return 0, err
+			}
+			thisBatchSize += message.GetRowsCount()
+		}
+		return thisBatchSize, nil
+	})
+}
+
 // run starts a loop that keeps collecting, sorting and sending messages
 // until it encounters an error or is interrupted.
 func (w *flushWorker) run(ctx context.Context) (retErr error) {
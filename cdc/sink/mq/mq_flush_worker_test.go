@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/tidb/parser/mysql"
 	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec"
 	"github.com/pingcap/tiflow/cdc/sink/codec/builder"
 	"github.com/pingcap/tiflow/cdc/sink/codec/common"
 	"github.com/pingcap/tiflow/cdc/sink/metrics"
@@ -95,7 +96,7 @@ func newTestWorker(ctx context.Context) (*flushWorker, *mockProducer) {
 		panic(err)
 	}
 	producer := NewMockProducer()
-	return newFlushWorker(encoder, producer,
+	return newFlushWorker(encoder, producer, nil,
 		metrics.NewStatistics(ctx, "", metrics.SinkTypeMQ)), producer
 }
 
@@ -391,6 +392,62 @@ func TestAsyncSend(t *testing.T) {
 	require.Len(t, producer.mqEvent[key3], 2)
 }
 
+// flushHintEncoder hints the worker to build the batch after every row.
+type flushHintEncoder struct {
+	codec.EventBatchEncoder
+	builds int
+}
+
+func (e *flushHintEncoder) Build() ([]*common.Message, error) {
+	e.builds++
+	return e.EventBatchEncoder.Build()
+}
+
+func (e *flushHintEncoder) ShouldFlush() bool {
+	return true
+}
+
+func TestAsyncSendFlushHint(t *testing.T) {
+	t.Parallel()
+
+	key := TopicPartitionKey{
+		Topic:     "test",
+		Partition: 1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	worker, producer := newTestWorker(ctx)
+	defer worker.close()
+	encoder := &flushHintEncoder{EventBatchEncoder: worker.encoder}
+	worker.encoder = encoder
+
+	events := []mqEvent{
+		{
+			row: &model.RowChangedEvent{
+				CommitTs: 1,
+				Table:    &model.TableName{Schema: "a", Table: "b"},
+				Columns:  []*model.Column{{Name: "col1", Type: 1, Value: "aa"}},
+			},
+			key: key,
+		},
+		{
+			row: &model.RowChangedEvent{
+				CommitTs: 2,
+				Table:    &model.TableName{Schema: "a", Table: "b"},
+				Columns:  []*model.Column{{Name: "col1", Type: 1, Value: "bb"}},
+			},
+			key: key,
+		},
+	}
+
+	err := worker.asyncSend(ctx, worker.group(events))
+	require.NoError(t, err)
+	// One early build for each row, and the last one for the rest.
+	require.Equal(t, 3, encoder.builds)
+	require.Len(t, producer.mqEvent[key], 2)
+}
+
 func TestFlush(t *testing.T) {
 	t.Parallel()
 
@@ -108,7 +108,9 @@ func (k *kafkaSaramaProducer) AsyncSendMessage(
 		Topic:     topic,
 		Key:       sarama.ByteEncoder(message.Key),
 		Value:     sarama.ByteEncoder(message.Value),
+		Timestamp: message.Timestamp,
 		Partition: partition,
+		Headers:   message.RecordHeaders(),
 	}
 	k.mu.Lock()
 	k.mu.inflight++
@@ -136,7 +138,9 @@ func (k *kafkaSaramaProducer) SyncBroadcastMessage(
 			Topic:     topic,
 			Key:       sarama.ByteEncoder(message.Key),
 			Value:     sarama.ByteEncoder(message.Value),
+			Timestamp: message.Timestamp,
 			Partition: int32(i),
+			Headers:   message.RecordHeaders(),
 		}
 	}
 	select {
@@ -0,0 +1,69 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mq
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/cdc/sink/mq/manager"
+	"github.com/pingcap/tiflow/pkg/config"
+)
+
+// ProtocolRouter routes the messages of the protocols other than the one of
+// the sink, e.g. the ones of the TableProtocols, to the topics of their
+// protocols in the ProtocolTopics, so that the consumers of each topic decode
+// a single protocol. A nil ProtocolRouter routes nothing.
+type ProtocolRouter struct {
+	topics       map[config.Protocol]string
+	topicManager manager.TopicManager
+}
+
+// NewProtocolRouter creates a ProtocolRouter, nil is returned if no protocol
+// has a topic of its own.
+func NewProtocolRouter(c *common.Config, topicManager manager.TopicManager) *ProtocolRouter {
+	if len(c.ProtocolTopics) == 0 {
+		return nil
+	}
+	return &ProtocolRouter{
+		topics:       c.ProtocolTopics,
+		topicManager: topicManager,
+	}
+}
+
+// Topic returns the topic of the message routed to the topic, which is the
+// topic of the protocol of the message if it has one.
+func (r *ProtocolRouter) Topic(topic string, msg *common.Message) string {
+	if r == nil {
+		return topic
+	}
+	if protocolTopic, ok := r.topics[msg.Protocol]; ok {
+		return protocolTopic
+	}
+	return topic
+}
+
+// Route returns the topic and the partition of the message routed to the key.
+// The partition is kept in the topic of the protocol, modulo the number of
+// the partitions of it.
+func (r *ProtocolRouter) Route(key TopicPartitionKey, msg *common.Message) (TopicPartitionKey, error) {
+	topic := r.Topic(key.Topic, msg)
+	if topic == key.Topic {
+		return key, nil
+	}
+	partitionNum, err := r.topicManager.GetPartitionNum(topic)
+	if err != nil {
+		return key, errors.Trace(err)
+	}
+	return TopicPartitionKey{Topic: topic, Partition: key.Partition % partitionNum}, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mq
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/cdc/model"
+	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	"github.com/pingcap/tiflow/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedTopicManager returns the same partition number for all the topics.
+type fixedTopicManager struct {
+	partitionNum int32
+}
+
+func (m fixedTopicManager) GetPartitionNum(string) (int32, error) {
+	return m.partitionNum, nil
+}
+
+func (m fixedTopicManager) CreateTopicAndWaitUntilVisible(string) (int32, error) {
+	return m.partitionNum, nil
+}
+
+func TestProtocolRouter(t *testing.T) {
+	t.Parallel()
+
+	// nothing is routed without the protocol topics.
+	router := NewProtocolRouter(common.NewConfig(config.ProtocolCanal), fixedTopicManager{2})
+	require.Nil(t, router)
+	key := TopicPartitionKey{Topic: "abc", Partition: 3}
+	msg := common.NewMsg(config.ProtocolCanalJSON, nil, nil, 0, model.MessageTypeRow, nil, nil)
+	routed, err := router.Route(key, msg)
+	require.NoError(t, err)
+	require.Equal(t, key, routed)
+
+	codecConfig := common.NewConfig(config.ProtocolCanal)
+	codecConfig.ProtocolTopics = map[config.Protocol]string{config.ProtocolCanalJSON: "abc-json"}
+	router = NewProtocolRouter(codecConfig, fixedTopicManager{2})
+	require.Equal(t, "abc-json", router.Topic("abc", msg))
+
+	// the partition is kept in the topic of the protocol.
+	routed, err = router.Route(key, msg)
+	require.NoError(t, err)
+	require.Equal(t, TopicPartitionKey{Topic: "abc-json", Partition: 1}, routed)
+
+	// the messages of the protocol stay in the topic routed.
+	msg = common.NewMsg(config.ProtocolCanal, nil, nil, 0, model.MessageTypeRow, nil, nil)
+	require.Equal(t, "abc", router.Topic("abc", msg))
+	routed, err = router.Route(key, msg)
+	require.NoError(t, err)
+	require.Equal(t, key, routed)
+}
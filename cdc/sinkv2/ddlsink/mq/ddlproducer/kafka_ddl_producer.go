@@ -114,7 +114,9 @@ func (k *kafkaDDLProducer) SyncBroadcastMessage(ctx context.Context, topic strin
 			Topic:     topic,
 			Key:       sarama.ByteEncoder(message.Key),
 			Value:     sarama.ByteEncoder(message.Value),
+			Timestamp: message.Timestamp,
 			Partition: int32(i),
+			Headers:   message.RecordHeaders(),
 		}
 	}
 	select {
@@ -140,7 +142,9 @@ func (k *kafkaDDLProducer) SyncSendMessage(ctx context.Context, topic string,
 		Topic:     topic,
 		Key:       sarama.ByteEncoder(message.Key),
 		Value:     sarama.ByteEncoder(message.Value),
+		Timestamp: message.Timestamp,
 		Partition: partitionNum,
+		Headers:   message.RecordHeaders(),
 	}
 	select {
 	case <-ctx.Done():
@@ -16,6 +16,7 @@ package ddlproducer
 import (
 	"context"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -124,6 +125,68 @@ func TestSyncSendMessage(t *testing.T) {
 	cancel()
 }
 
+// headersInterceptor records the headers of the messages sent.
+type headersInterceptor struct {
+	mu      sync.Mutex
+	headers [][]sarama.RecordHeader
+}
+
+func (i *headersInterceptor) OnSend(msg *sarama.ProducerMessage) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.headers = append(i.headers, msg.Headers)
+}
+
+func TestSyncSendMessageHeaders(t *testing.T) {
+	t.Parallel()
+
+	// The responses of the initBroker are not compatible with the Kafka 0.11.
+	topic := kafka.DefaultMockTopicName
+	leader := sarama.NewMockBroker(t, 1)
+	defer leader.Close()
+	leader.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(leader.Addr(), leader.BrokerID()).
+			SetLeader(topic, 0, leader.BrokerID()),
+		// The produce request of the Kafka 0.11 is the version 3.
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config := getConfig(leader.Addr())
+	// The record headers require the Kafka 0.11 at least.
+	config.Version = "0.11.0.0"
+	saramaConfig, err := kafkav1.NewSaramaConfig(context.Background(), config)
+	require.Nil(t, err)
+	saramaConfig.Producer.Flush.MaxMessages = 1
+	interceptor := &headersInterceptor{}
+	saramaConfig.Producer.Interceptors = []sarama.ProducerInterceptor{interceptor}
+
+	client, err := sarama.NewClient(config.BrokerEndpoints, saramaConfig)
+	require.Nil(t, err)
+	adminClient, err := kafka.NewMockAdminClient(config.BrokerEndpoints, saramaConfig)
+	require.Nil(t, err)
+	p, err := NewKafkaDDLProducer(ctx, client, adminClient)
+	require.Nil(t, err)
+	defer p.Close()
+
+	// The signature and the key id of the message signed are produced in the
+	// record headers.
+	err = p.SyncSendMessage(ctx, topic, 0, &common.Message{
+		Ts:           417318403368288260,
+		Signature:    []byte("signature"),
+		SigningKeyID: "key-1",
+	})
+	require.Nil(t, err)
+	interceptor.mu.Lock()
+	defer interceptor.mu.Unlock()
+	require.Equal(t, [][]sarama.RecordHeader{{
+		{Key: []byte(common.HeaderSignature), Value: []byte("signature")},
+		{Key: []byte(common.HeaderSigningKeyID), Value: []byte("key-1")},
+	}}, interceptor.headers)
+}
+
 func TestProducerSendMsgFailed(t *testing.T) {
 	t.Parallel()
 
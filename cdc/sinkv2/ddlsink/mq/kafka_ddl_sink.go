@@ -113,8 +113,9 @@ func NewKafkaDDLSink(
 		return nil, errors.Trace(err)
 	}
 
+	// The record headers are not supported by the Kafka before 0.11.
 	encoderConfig, err := mqutil.GetEncoderConfig(sinkURI, protocol, replicaConfig,
-		saramaConfig.Producer.MaxMessageBytes)
+		saramaConfig.Producer.MaxMessageBytes, !saramaConfig.Version.IsAtLeast(sarama.V0_11_0_0))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -23,6 +23,7 @@ import (
 	"github.com/pingcap/tiflow/cdc/sink/codec"
 	"github.com/pingcap/tiflow/cdc/sink/codec/builder"
 	"github.com/pingcap/tiflow/cdc/sink/codec/common"
+	mqv1 "github.com/pingcap/tiflow/cdc/sink/mq"
 	"github.com/pingcap/tiflow/cdc/sink/mq/dispatcher"
 	"github.com/pingcap/tiflow/cdc/sink/mq/manager"
 	"github.com/pingcap/tiflow/cdc/sinkv2/ddlsink"
@@ -49,6 +50,13 @@ type ddlSink struct {
 	topicManager manager.TopicManager
 	// encoderBuilder builds encoder for the sink.
 	encoderBuilder codec.EncoderBuilder
+	// suppressor drops the events suppressed before they're encoded.
+	suppressor *builder.EventSuppressor
+	// protocolRouter routes the messages of the other protocols.
+	protocolRouter *mqv1.ProtocolRouter
+	// broadcastDDL indicates whether the DDL is sent to all partitions,
+	// regardless of the protocol.
+	broadcastDDL bool
 	// producer used to send events to the MQ system.
 	// Usually it is a sync producer.
 	producer ddlproducer.DDLProducer
@@ -75,6 +83,9 @@ func newDDLSink(ctx context.Context,
 		eventRouter:    eventRouter,
 		topicManager:   topicManager,
 		encoderBuilder: encoderBuilder,
+		suppressor:     builder.NewEventSuppressor(encoderConfig),
+		protocolRouter: mqv1.NewProtocolRouter(encoderConfig, topicManager),
+		broadcastDDL:   encoderConfig.BroadcastDDL,
 		producer:       producer,
 		statistics:     metrics.NewStatistics(ctx, sink.RowSink),
 	}
@@ -83,12 +94,28 @@ func newDDLSink(ctx context.Context,
 }
 
 func (k *ddlSink) WriteDDLEvent(ctx context.Context, ddl *model.DDLEvent) error {
+	if k.suppressor.SuppressDDL(ddl) {
+		return nil
+	}
 	encoder := k.encoderBuilder.Build()
+	if throttled, ok := encoder.(codec.DDLThrottledEncoder); ok {
+		if err := throttled.WaitDDL(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	msg, err := encoder.EncodeDDLEvent(ddl)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	if msg == nil {
+	// the DDL encoded by the other protocols are returned by Build.
+	msgs, err := encoder.Build()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if msg != nil {
+		msgs = append([]*common.Message{msg}, msgs...)
+	}
+	if len(msgs) == 0 {
 		log.Info("Skip ddl event", zap.Uint64("commitTs", ddl.CommitTs),
 			zap.String("query", ddl.Query),
 			zap.String("protocol", k.protocol.String()),
@@ -97,13 +124,27 @@ func (k *ddlSink) WriteDDLEvent(ctx context.Context, ddl *model.DDLEvent) error
 		return nil
 	}
 
-	topic := k.eventRouter.GetTopicForDDL(ddl)
-	partitionRule := k.eventRouter.GetDLLDispatchRuleByProtocol(k.protocol)
 	log.Debug("Emit ddl event",
 		zap.Uint64("commitTs", ddl.CommitTs),
 		zap.String("query", ddl.Query),
 		zap.String("namespace", k.id.Namespace),
 		zap.String("changefeed", k.id.ID))
+	for _, msg := range msgs {
+		if err := k.writeDDL(ctx, ddl, msg); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// writeDDL sends the DDL message to the topic of the DDL,
+// routed by the protocol of the message.
+func (k *ddlSink) writeDDL(ctx context.Context, ddl *model.DDLEvent, msg *common.Message) error {
+	topic := k.protocolRouter.Topic(k.eventRouter.GetTopicForDDL(ddl), msg)
+	partitionRule := k.eventRouter.GetDLLDispatchRuleByProtocol(msg.Protocol)
+	if k.broadcastDDL {
+		partitionRule = dispatcher.PartitionAll
+	}
 	if partitionRule == dispatcher.PartitionAll {
 		partitionNum, err := k.topicManager.GetPartitionNum(topic)
 		if err != nil {
@@ -118,7 +159,7 @@ func (k *ddlSink) WriteDDLEvent(ctx context.Context, ddl *model.DDLEvent) error
 	// which will be responsible for automatically creating topics when they don't exist.
 	// If it is not called here and kafka has `auto.create.topics.enable` turned on,
 	// then the auto-created topic will not be created as configured by ticdc.
-	_, err = k.topicManager.GetPartitionNum(topic)
+	_, err := k.topicManager.GetPartitionNum(topic)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -136,14 +177,32 @@ func (k *ddlSink) WriteCheckpointTs(ctx context.Context,
 	if err != nil {
 		return errors.Trace(err)
 	}
-	if msg == nil {
-		return nil
+	// the checkpoints encoded by the other protocols are returned by Build.
+	msgs, err := encoder.Build()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if msg != nil {
+		msgs = append([]*common.Message{msg}, msgs...)
+	}
+	for _, msg := range msgs {
+		if err := k.writeCheckpoint(ctx, ts, msg, tables); err != nil {
+			return errors.Trace(err)
+		}
 	}
+	return nil
+}
+
+// writeCheckpoint broadcasts the checkpoint message to the default topic or
+// the topics of all tables, routed by the protocol of the message.
+func (k *ddlSink) writeCheckpoint(ctx context.Context,
+	ts uint64, msg *common.Message, tables []*model.TableInfo,
+) error {
 	// NOTICE: When there are no tables to replicate,
 	// we need to send checkpoint ts to the default topic.
 	// This will be compatible with the old behavior.
 	if len(tables) == 0 {
-		topic := k.eventRouter.GetDefaultTopic()
+		topic := k.protocolRouter.Topic(k.eventRouter.GetDefaultTopic(), msg)
 		partitionNum, err := k.topicManager.GetPartitionNum(topic)
 		if err != nil {
 			return errors.Trace(err)
@@ -158,7 +217,14 @@ func (k *ddlSink) WriteCheckpointTs(ctx context.Context,
 		tableNames = append(tableNames, table.TableName)
 	}
 	topics := k.eventRouter.GetActiveTopics(tableNames)
+	// the topics may be routed to the same topic of the protocol.
+	written := make(map[string]struct{}, len(topics))
 	for _, topic := range topics {
+		topic = k.protocolRouter.Topic(topic, msg)
+		if _, ok := written[topic]; ok {
+			continue
+		}
+		written[topic] = struct{}{}
 		partitionNum, err := k.topicManager.GetPartitionNum(topic)
 		if err != nil {
 			return errors.Trace(err)
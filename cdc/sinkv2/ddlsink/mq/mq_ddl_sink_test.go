@@ -175,6 +175,113 @@ func TestWriteDDLEventToZeroPartition(t *testing.T) {
 	}), 0)
 }
 
+func TestWriteDDLEventToAllPartitionsWithBroadcast(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leader, topic := initBroker(t, kafka.DefaultMockPartitionNum)
+	defer leader.Close()
+	uriTemplate := "kafka://%s/%s?kafka-version=0.9.0.0&max-batch-size=1" +
+		"&max-message-bytes=1048576&partition-num=1" +
+		"&kafka-client-id=unit-test&auto-create-topic=false&compression=gzip&protocol=canal&broadcast-ddl=true"
+	uri := fmt.Sprintf(uriTemplate, leader.Addr(), topic)
+
+	sinkURI, err := url.Parse(uri)
+	require.Nil(t, err)
+	replicaConfig := config.GetDefaultReplicaConfig()
+	require.Nil(t, replicaConfig.ValidateAndAdjust(sinkURI))
+
+	s, err := NewKafkaDDLSink(ctx, sinkURI, replicaConfig,
+		kafka.NewMockAdminClient, ddlproducer.NewMockDDLProducer)
+	require.Nil(t, err)
+	require.NotNil(t, s)
+
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288260,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{
+				Schema: "cdc", Table: "person",
+			},
+		},
+		Query: "create table person(id int, name varchar(32), primary key(id))",
+		Type:  mm.ActionCreateTable,
+	}
+	err = s.WriteDDLEvent(ctx, ddl)
+	require.Nil(t, err)
+	require.Len(t, s.producer.(*ddlproducer.MockDDLProducer).GetAllEvents(),
+		kafka.DefaultMockPartitionNum, "All the partitions should be broadcast")
+	require.Len(t, s.producer.(*ddlproducer.MockDDLProducer).GetEvents(mqv1.TopicPartitionKey{
+		Topic:     "mock_topic",
+		Partition: 0,
+	}), 1)
+	require.Len(t, s.producer.(*ddlproducer.MockDDLProducer).GetEvents(mqv1.TopicPartitionKey{
+		Topic:     "mock_topic",
+		Partition: 1,
+	}), 1)
+	require.Len(t, s.producer.(*ddlproducer.MockDDLProducer).GetEvents(mqv1.TopicPartitionKey{
+		Topic:     "mock_topic",
+		Partition: 2,
+	}), 1)
+}
+
+func TestWriteDDLEventToProtocolTopics(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leader, topic := initBroker(t, kafka.DefaultMockPartitionNum)
+	defer leader.Close()
+	// Notice: auto create topic is true. Auto created topic will have 1 partition.
+	uriTemplate := "kafka://%s/%s?kafka-version=0.9.0.0&max-batch-size=1" +
+		"&max-message-bytes=1048576&partition-num=1" +
+		"&kafka-client-id=unit-test&auto-create-topic=true&compression=gzip" +
+		"&protocol=open-protocol&fanout-protocols=canal-json&protocol-topics=canal-json:mock_topic_json"
+	uri := fmt.Sprintf(uriTemplate, leader.Addr(), topic)
+
+	sinkURI, err := url.Parse(uri)
+	require.Nil(t, err)
+	replicaConfig := config.GetDefaultReplicaConfig()
+	require.Nil(t, replicaConfig.ValidateAndAdjust(sinkURI))
+
+	s, err := NewKafkaDDLSink(ctx, sinkURI, replicaConfig,
+		kafka.NewMockAdminClient, ddlproducer.NewMockDDLProducer)
+	require.Nil(t, err)
+	require.NotNil(t, s)
+
+	ddl := &model.DDLEvent{
+		CommitTs: 417318403368288260,
+		TableInfo: &model.TableInfo{
+			TableName: model.TableName{
+				Schema: "cdc", Table: "person",
+			},
+		},
+		Query: "create table person(id int, name varchar(32), primary key(id))",
+		Type:  mm.ActionCreateTable,
+	}
+	err = s.WriteDDLEvent(ctx, ddl)
+	require.Nil(t, err)
+	// the DDL of each protocol is sent to the topic of the protocol,
+	// by the partition rule of the protocol.
+	require.Len(t, s.producer.(*ddlproducer.MockDDLProducer).GetAllEvents(), 4)
+	for i := int32(0); i < 3; i++ {
+		events := s.producer.(*ddlproducer.MockDDLProducer).GetEvents(mqv1.TopicPartitionKey{
+			Topic:     "mock_topic",
+			Partition: i,
+		})
+		require.Len(t, events, 1)
+		require.Equal(t, config.ProtocolOpen, events[0].Protocol)
+	}
+	events := s.producer.(*ddlproducer.MockDDLProducer).GetEvents(mqv1.TopicPartitionKey{
+		Topic:     "mock_topic_json",
+		Partition: 0,
+	})
+	require.Len(t, events, 1)
+	require.Equal(t, config.ProtocolCanalJSON, events[0].Protocol)
+}
+
 func TestWriteCheckpointTsToDefaultTopic(t *testing.T) {
 	t.Parallel()
 
@@ -161,6 +161,8 @@ func (k *kafkaDMLProducer) AsyncSendMessage(
 		Partition: partition,
 		Key:       sarama.StringEncoder(message.Key),
 		Value:     sarama.ByteEncoder(message.Value),
+		Timestamp: message.Timestamp,
+		Headers:   message.RecordHeaders(),
 		Metadata:  messageMetaData{callback: message.Callback},
 	}
 
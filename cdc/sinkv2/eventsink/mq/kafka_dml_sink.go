@@ -111,11 +111,15 @@ func NewKafkaDMLSink(
 		return nil, errors.Trace(err)
 	}
 
+	// The record headers are not supported by the Kafka before 0.11.
 	encoderConfig, err := mqutil.GetEncoderConfig(sinkURI, protocol, replicaConfig,
-		saramaConfig.Producer.MaxMessageBytes)
+		saramaConfig.Producer.MaxMessageBytes, !saramaConfig.Version.IsAtLeast(sarama.V0_11_0_0))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	// The partition count has been adjusted to the topic's real one,
+	// make the encoder aware of it.
+	encoderConfig = encoderConfig.WithPartitionNum(baseConfig.PartitionNum)
 
 	s, err := newSink(ctx, p, topicManager, eventRouter, encoderConfig, errCh)
 	if err != nil {
@@ -56,6 +56,8 @@ type dmlSink struct {
 
 	// encoderBuilder builds encoder for the sink.
 	encoderBuilder codec.EncoderBuilder
+	// suppressor drops the events suppressed before they're encoded.
+	suppressor *builder.EventSuppressor
 }
 
 func newSink(ctx context.Context,
@@ -74,7 +76,8 @@ func newSink(ctx context.Context,
 	encoder := encoderBuilder.Build()
 
 	statistics := metrics.NewStatistics(ctx, sink.RowSink)
-	w := newWorker(changefeedID, encoderConfig.Protocol, encoder, producer, statistics)
+	w := newWorker(changefeedID, encoderConfig.Protocol, encoder, producer,
+		mqv1.NewProtocolRouter(encoderConfig, topicManager), statistics)
 
 	s := &dmlSink{
 		id:             changefeedID,
@@ -83,6 +86,7 @@ func newSink(ctx context.Context,
 		eventRouter:    eventRouter,
 		topicManager:   topicManager,
 		encoderBuilder: encoderBuilder,
+		suppressor:     builder.NewEventSuppressor(encoderConfig),
 	}
 
 	// Spawn a goroutine to send messages by the worker.
@@ -113,6 +117,11 @@ func (s *dmlSink) WriteEvents(rows ...*eventsink.RowChangeCallbackableEvent) err
 			row.Callback()
 			continue
 		}
+		if s.suppressor.SuppressRow(row.Event) {
+			// Nothing is sent for the event suppressed.
+			row.Callback()
+			continue
+		}
 		topic := s.eventRouter.GetTopicForRowChange(row.Event)
 		partitionNum, err := s.topicManager.GetPartitionNum(topic)
 		if err != nil {
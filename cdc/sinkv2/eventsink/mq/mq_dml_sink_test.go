@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/Shopify/sarama"
+	timodel "github.com/pingcap/tidb/parser/model"
 	"github.com/pingcap/tiflow/cdc/model"
 	"github.com/pingcap/tiflow/cdc/sinkv2/eventsink"
 	"github.com/pingcap/tiflow/cdc/sinkv2/eventsink/mq/dmlproducer"
@@ -124,3 +125,68 @@ func TestWriteEvents(t *testing.T) {
 	err = s.Close()
 	require.Nil(t, err)
 }
+
+func TestWriteEventsSuppressed(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leader, topic := initBroker(t, kafka.DefaultMockPartitionNum)
+	defer leader.Close()
+	uriTemplate := "kafka://%s/%s?kafka-version=0.9.0.0&max-batch-size=1" +
+		"&max-message-bytes=1048576&partition-num=1" +
+		"&kafka-client-id=unit-test&auto-create-topic=false&compression=gzip&protocol=open-protocol" +
+		"&suppressed-schemas=mysql"
+	uri := fmt.Sprintf(uriTemplate, leader.Addr(), topic)
+
+	sinkURI, err := url.Parse(uri)
+	require.Nil(t, err)
+	replicaConfig := config.GetDefaultReplicaConfig()
+	require.Nil(t, replicaConfig.ValidateAndAdjust(sinkURI))
+	errCh := make(chan error, 1)
+
+	s, err := NewKafkaDMLSink(ctx, sinkURI, replicaConfig, errCh,
+		kafka.NewMockAdminClient, dmlproducer.NewDMLMockProducer)
+	require.Nil(t, err)
+	require.NotNil(t, s)
+
+	// The rows of the schemas configured are suppressed.
+	tableStatus := state.TableSinkSinking
+	row := &model.RowChangedEvent{
+		CommitTs: 1,
+		Table:    &model.TableName{Schema: "mysql", Table: "b"},
+		Columns:  []*model.Column{{Name: "col1", Type: 1, Value: "aa"}},
+	}
+	called := 0
+	err = s.WriteEvents(&eventsink.RowChangeCallbackableEvent{
+		Event:     row,
+		Callback:  func() { called++ },
+		SinkState: &tableStatus,
+	})
+	require.Nil(t, err)
+	// The callback of the row suppressed is called at once.
+	require.Equal(t, 1, called)
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, s.worker.producer.(*dmlproducer.MockDMLProducer).GetAllEvents(), 0)
+
+	// The rows of the cached tables are emitted by default.
+	info := &timodel.TableInfo{ID: 1, Name: timodel.NewCIStr("c")}
+	info.TableCacheStatusType = timodel.TableCacheStatusEnable
+	err = s.WriteEvents(&eventsink.RowChangeCallbackableEvent{
+		Event: &model.RowChangedEvent{
+			CommitTs:  2,
+			Table:     &model.TableName{Schema: "test", Table: "c"},
+			TableInfo: model.WrapTableInfo(1, "test", 1, info),
+			Columns:   []*model.Column{{Name: "col1", Type: 1, Value: "aa"}},
+		},
+		Callback:  func() {},
+		SinkState: &tableStatus,
+	})
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		return len(s.worker.producer.(*dmlproducer.MockDMLProducer).GetAllEvents()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	err = s.Close()
+	require.Nil(t, err)
+}
@@ -63,6 +63,8 @@ type worker struct {
 	encoder codec.EventBatchEncoder
 	// producer is used to send the messages to the Kafka broker.
 	producer dmlproducer.DMLProducer
+	// protocolRouter routes the messages of the other protocols.
+	protocolRouter *mqv1.ProtocolRouter
 	// metricMQWorkerFlushDuration is the metric of the flush duration.
 	// We record the flush duration for each batch.
 	metricMQWorkerFlushDuration prometheus.Observer
@@ -76,6 +78,7 @@ func newWorker(
 	protocol config.Protocol,
 	encoder codec.EventBatchEncoder,
 	producer dmlproducer.DMLProducer,
+	protocolRouter *mqv1.ProtocolRouter,
 	statistics *metrics.Statistics,
 ) *worker {
 	w := &worker{
@@ -85,6 +88,7 @@ func newWorker(
 		ticker:                      time.NewTicker(flushInterval),
 		encoder:                     encoder,
 		producer:                    producer,
+		protocolRouter:              protocolRouter,
 		metricMQWorkerFlushDuration: mq.WorkerFlushDuration.WithLabelValues(id.Namespace, id.ID),
 		statistics:                  statistics,
 	}
@@ -138,17 +142,8 @@ func (w *worker) nonBatchEncodeRun(ctx context.Context) error {
 				return err
 			}
 			w.statistics.ObserveRows(event.rowEvent.Event)
-			for _, message := range w.encoder.Build() {
-				err := w.statistics.RecordBatchExecution(func() (int, error) {
-					err := w.producer.AsyncSendMessage(ctx, event.key.Topic, event.key.Partition, message)
-					if err != nil {
-						return 0, err
-					}
-					return message.GetRowsCount(), nil
-				})
-				if err != nil {
-					return err
-				}
+			if err := w.sendBatch(ctx, event.key); err != nil {
+				return err
 			}
 			duration := time.Since(start)
 			w.metricMQWorkerFlushDuration.Observe(duration.Seconds())
@@ -270,22 +265,46 @@ func (w *worker) asyncSend(
 			}
 			rowsCount++
 			w.statistics.ObserveRows(event.Event)
+			// build the batch early if the encoder asks to.
+			if hint, ok := w.encoder.(codec.FlushHintEncoder); ok && hint.ShouldFlush() {
+				if err := w.sendBatch(ctx, key); err != nil {
+					return err
+				}
+			}
 		}
 
-		for _, message := range w.encoder.Build() {
-			err := w.statistics.RecordBatchExecution(func() (int, error) {
-				err := w.producer.AsyncSendMessage(ctx, key.Topic, key.Partition, message)
-				if err != nil {
-					return 0, err
-				}
-				return message.GetRowsCount(), nil
-			})
+		if err := w.sendBatch(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendBatch builds the batch of the encoder and sends the messages to the
+// partition of the key, the messages of the other protocols are sent to the
+// topics of theirs.
+func (w *worker) sendBatch(ctx context.Context, key mqv1.TopicPartitionKey) error {
+	messages, err := w.encoder.Build()
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		routed, err := w.protocolRouter.Route(key, message)
+		if err != nil {
+			return err
+		}
+		err = w.statistics.RecordBatchExecution(func() (int, error) {
+			err := w.producer.AsyncSendMessage(ctx, routed.Topic, routed.Partition, message)
 			if err != nil {
-				return err
+				return 0, err
 			}
+			return message.GetRowsCount(), nil
+		})
+		if err != nil {
+			return err
 		}
 	}
-
 	return nil
 }
 
@@ -42,7 +42,7 @@ func newBatchEncodeWorker(ctx context.Context, t *testing.T) (*worker, dmlproduc
 	p, err := dmlproducer.NewDMLMockProducer(context.Background(), nil, nil, nil)
 	require.Nil(t, err)
 	id := model.DefaultChangeFeedID("test")
-	return newWorker(id, config.ProtocolOpen, encoder, p, metrics.NewStatistics(ctx, sink.RowSink)), p
+	return newWorker(id, config.ProtocolOpen, encoder, p, nil, metrics.NewStatistics(ctx, sink.RowSink)), p
 }
 
 func newNonBatchEncodeWorker(ctx context.Context, t *testing.T) (*worker, dmlproducer.DMLProducer) {
@@ -55,7 +55,7 @@ func newNonBatchEncodeWorker(ctx context.Context, t *testing.T) (*worker, dmlpro
 	p, err := dmlproducer.NewDMLMockProducer(context.Background(), nil, nil, nil)
 	require.Nil(t, err)
 	id := model.DefaultChangeFeedID("test")
-	return newWorker(id, config.ProtocolCanalJSON, encoder, p, metrics.NewStatistics(ctx, sink.RowSink)), p
+	return newWorker(id, config.ProtocolCanalJSON, encoder, p, nil, metrics.NewStatistics(ctx, sink.RowSink)), p
 }
 
 func TestBatchEncode_Batch(t *testing.T) {
@@ -53,6 +53,7 @@ func GetEncoderConfig(
 	protocol config.Protocol,
 	replicaConfig *config.ReplicaConfig,
 	maxMsgBytes int,
+	headersUnsupported bool,
 ) (*common.Config, error) {
 	encoderConfig := common.NewConfig(protocol)
 	if err := encoderConfig.Apply(sinkURI, replicaConfig); err != nil {
@@ -62,10 +63,22 @@ func GetEncoderConfig(
 	// to prevent that the encoder generate batched message too large
 	// then cause producer meet `message too large`.
 	encoderConfig = encoderConfig.WithMaxMessageBytes(maxMsgBytes)
+	encoderConfig = encoderConfig.WithHeadersUnsupported(headersUnsupported)
 
 	if err := encoderConfig.Validate(); err != nil {
 		return nil, cerror.WrapError(cerror.ErrKafkaInvalidConfig, err)
 	}
+	// The DDL sink and the DML sink build the encoders of their own, so the
+	// tables active in the DML sink are unknown to the checkpoint encoder,
+	// and there is no background task to emit the heartbeat.
+	if encoderConfig.EnableTableWatermark {
+		return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+			"enable-table-watermark is not supported by the sink")
+	}
+	if encoderConfig.HeartbeatInterval != 0 {
+		return nil, cerror.ErrKafkaInvalidConfig.GenWithStack(
+			"heartbeat-interval is not supported by the sink")
+	}
 
 	return encoderConfig, nil
 }
@@ -17,6 +17,7 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/pingcap/tiflow/pkg/config"
 	"github.com/stretchr/testify/require"
 )
 
@@ -60,3 +61,40 @@ func TestGetTopic(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEncoderConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		sinkURI string
+		wantErr string
+	}{
+		"valid": {
+			sinkURI: "kafka://localhost:9092/test?protocol=canal",
+		},
+		"table watermark": {
+			sinkURI: "kafka://localhost:9092/test?protocol=canal&enable-table-watermark=true",
+			wantErr: "enable-table-watermark is not supported by the sink",
+		},
+		"heartbeat": {
+			sinkURI: "kafka://localhost:9092/test?protocol=canal&heartbeat-interval=1s",
+			wantErr: "heartbeat-interval is not supported by the sink",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			sinkURI, err := url.Parse(tc.sinkURI)
+			require.NoError(t, err)
+			_, err = GetEncoderConfig(sinkURI, config.ProtocolCanal,
+				config.GetDefaultReplicaConfig(), config.DefaultMaxMessageBytes, false)
+			if tc.wantErr != "" {
+				require.Regexp(t, tc.wantErr, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ package config
 
 import (
 	"strings"
+	"sync"
 
 	cerror "github.com/pingcap/tiflow/pkg/errors"
 )
@@ -38,40 +39,102 @@ const (
 	ProtocolCraft
 	ProtocolOpen
 	ProtocolCsv
+	ProtocolCanalColumnar
+	ProtocolMySQLBinlog
 )
 
+// protocolCustomBase is the first Protocol allocated by RegisterProtocol.
+const protocolCustomBase Protocol = 1024
+
+var (
+	customProtocolsMu sync.RWMutex
+	// customProtocols maps the names registered by RegisterProtocol to the
+	// protocols allocated, and customProtocolNames is the reverse of it.
+	customProtocols     = make(map[string]Protocol)
+	customProtocolNames = make(map[Protocol]string)
+)
+
+// RegisterProtocol allocates a Protocol for the name of a protocol not built
+// in, so that it can be selected by the protocol of the sink URI. The name is
+// case-insensitive and can only be registered once.
+func RegisterProtocol(name string) (Protocol, error) {
+	name = strings.ToLower(name)
+	if name == "" {
+		return ProtocolUnknown, cerror.ErrSinkInvalidConfig.GenWithStack(
+			"the name of the protocol is empty")
+	}
+
+	customProtocolsMu.Lock()
+	defer customProtocolsMu.Unlock()
+	if _, ok := parseBuiltinProtocol(name); ok {
+		return ProtocolUnknown, cerror.ErrSinkInvalidConfig.GenWithStack(
+			"protocol %s is already registered", name)
+	}
+	if _, ok := customProtocols[name]; ok {
+		return ProtocolUnknown, cerror.ErrSinkInvalidConfig.GenWithStack(
+			"protocol %s is already registered", name)
+	}
+	protocol := protocolCustomBase + Protocol(len(customProtocols))
+	customProtocols[name] = protocol
+	customProtocolNames[protocol] = name
+	return protocol, nil
+}
+
 // IsBatchEncode returns whether the protocol is a batch encoder.
 func (p Protocol) IsBatchEncode() bool {
-	return p == ProtocolOpen || p == ProtocolCanal || p == ProtocolMaxwell || p == ProtocolCraft
+	return p == ProtocolOpen || p == ProtocolCanal || p == ProtocolMaxwell || p == ProtocolCraft ||
+		p == ProtocolCanalColumnar || p == ProtocolMySQLBinlog
 }
 
-// ParseSinkProtocolFromString converts the protocol from string to Protocol enum type.
+// ParseSinkProtocolFromString converts the protocol from string to Protocol enum type,
+// the protocols registered by RegisterProtocol are resolved by their names.
 func ParseSinkProtocolFromString(protocol string) (Protocol, error) {
-	switch strings.ToLower(protocol) {
+	name := strings.ToLower(protocol)
+	if p, ok := parseBuiltinProtocol(name); ok {
+		return p, nil
+	}
+
+	customProtocolsMu.RLock()
+	defer customProtocolsMu.RUnlock()
+	if p, ok := customProtocols[name]; ok {
+		return p, nil
+	}
+	return ProtocolUnknown, cerror.ErrSinkUnknownProtocol.GenWithStackByArgs(protocol)
+}
+
+// parseBuiltinProtocol converts the lower-cased name of a built-in protocol
+// to Protocol enum type.
+func parseBuiltinProtocol(name string) (Protocol, bool) {
+	switch name {
 	case "default":
-		return ProtocolOpen, nil
+		return ProtocolOpen, true
 	case "canal":
-		return ProtocolCanal, nil
+		return ProtocolCanal, true
 	case "avro":
-		return ProtocolAvro, nil
+		return ProtocolAvro, true
 	case "flat-avro":
-		return ProtocolAvro, nil
+		return ProtocolAvro, true
 	case "maxwell":
-		return ProtocolMaxwell, nil
+		return ProtocolMaxwell, true
 	case "canal-json":
-		return ProtocolCanalJSON, nil
+		return ProtocolCanalJSON, true
 	case "craft":
-		return ProtocolCraft, nil
+		return ProtocolCraft, true
 	case "open-protocol":
-		return ProtocolOpen, nil
+		return ProtocolOpen, true
 	case "csv":
-		return ProtocolCsv, nil
+		return ProtocolCsv, true
+	case "canal-columnar":
+		return ProtocolCanalColumnar, true
+	case "mysql-binlog":
+		return ProtocolMySQLBinlog, true
 	default:
-		return ProtocolUnknown, cerror.ErrSinkUnknownProtocol.GenWithStackByArgs(protocol)
+		return ProtocolUnknown, false
 	}
 }
 
-// String converts the Protocol enum type string to string.
+// String converts the Protocol enum type string to string, the protocols
+// registered by RegisterProtocol are converted to their names.
 func (p Protocol) String() string {
 	switch p {
 	case ProtocolDefault:
@@ -90,7 +153,16 @@ func (p Protocol) String() string {
 		return "open-protocol"
 	case ProtocolCsv:
 		return "csv"
-	default:
-		panic("unreachable")
+	case ProtocolCanalColumnar:
+		return "canal-columnar"
+	case ProtocolMySQLBinlog:
+		return "mysql-binlog"
+	}
+
+	customProtocolsMu.RLock()
+	defer customProtocolsMu.RUnlock()
+	if name, ok := customProtocolNames[p]; ok {
+		return name
 	}
+	panic("unreachable")
 }
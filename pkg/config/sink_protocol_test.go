@@ -62,6 +62,14 @@ func TestParseSinkProtocolFromString(t *testing.T) {
 			protocol:             "open-protocol",
 			expectedProtocolEnum: ProtocolOpen,
 		},
+		{
+			protocol:             "canal-columnar",
+			expectedProtocolEnum: ProtocolCanalColumnar,
+		},
+		{
+			protocol:             "mysql-binlog",
+			expectedProtocolEnum: ProtocolMySQLBinlog,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -109,6 +117,14 @@ func TestString(t *testing.T) {
 			protocolEnum:     ProtocolOpen,
 			expectedProtocol: "open-protocol",
 		},
+		{
+			protocolEnum:     ProtocolCanalColumnar,
+			expectedProtocol: "canal-columnar",
+		},
+		{
+			protocolEnum:     ProtocolMySQLBinlog,
+			expectedProtocol: "mysql-binlog",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -151,9 +167,35 @@ func TestIsBatchEncoder(t *testing.T) {
 			protocolEnum: ProtocolOpen,
 			expect:       true,
 		},
+		{
+			protocolEnum: ProtocolCanalColumnar,
+			expect:       true,
+		},
+		{
+			protocolEnum: ProtocolMySQLBinlog,
+			expect:       true,
+		},
 	}
 
 	for _, tc := range testCases {
 		require.Equal(t, tc.expect, tc.protocolEnum.IsBatchEncode())
 	}
 }
+
+func TestRegisterProtocol(t *testing.T) {
+	t.Parallel()
+
+	protocol, err := RegisterProtocol("Custom-Protocol")
+	require.NoError(t, err)
+	require.Equal(t, "custom-protocol", protocol.String())
+	parsed, err := ParseSinkProtocolFromString("CUSTOM-protocol")
+	require.NoError(t, err)
+	require.Equal(t, protocol, parsed)
+
+	_, err = RegisterProtocol("custom-protocol")
+	require.ErrorContains(t, err, "protocol custom-protocol is already registered")
+	_, err = RegisterProtocol("canal-json")
+	require.ErrorContains(t, err, "protocol canal-json is already registered")
+	_, err = RegisterProtocol("")
+	require.ErrorContains(t, err, "the name of the protocol is empty")
+}
@@ -387,6 +387,10 @@ var (
 		"open-protocol codec single row too large",
 		errors.RFCCodeText("CDC:ErrOpenProtocolCodecRowTooLarge"),
 	)
+	ErrOpenProtocolCodecTxnTooLarge = errors.Normalize(
+		"open-protocol codec transaction %d too large for max-message-bytes %d",
+		errors.RFCCodeText("CDC:ErrOpenProtocolCodecTxnTooLarge"),
+	)
 	ErrCanalDecodeFailed = errors.Normalize(
 		"canal decode failed",
 		errors.RFCCodeText("CDC:ErrCanalDecodeFailed"),
@@ -395,6 +399,50 @@ var (
 		"canal encode failed",
 		errors.RFCCodeText("CDC:ErrCanalEncodeFailed"),
 	)
+	ErrCanalUnsupportedType = errors.Normalize(
+		"canal encode unsupported type",
+		errors.RFCCodeText("CDC:ErrCanalUnsupportedType"),
+	)
+	ErrCanalValueTooLarge = errors.Normalize(
+		"canal encoded value too large, size: %d, max-message-bytes: %d",
+		errors.RFCCodeText("CDC:ErrCanalValueTooLarge"),
+	)
+	ErrCanalMarshalFailed = errors.Normalize(
+		"canal marshal failed",
+		errors.RFCCodeText("CDC:ErrCanalMarshalFailed"),
+	)
+	ErrCanalExternalStoreFailed = errors.Normalize(
+		"canal external store failed",
+		errors.RFCCodeText("CDC:ErrCanalExternalStoreFailed"),
+	)
+	ErrCanalChecksumMismatch = errors.Normalize(
+		"canal row checksum mismatch, upstream: %s, computed: %s",
+		errors.RFCCodeText("CDC:ErrCanalChecksumMismatch"),
+	)
+	ErrCanalInvalidBoolean = errors.Normalize(
+		"the value %s of column %s is not a boolean",
+		errors.RFCCodeText("CDC:ErrCanalInvalidBoolean"),
+	)
+	ErrCanalInvalidKeyIndex = errors.Normalize(
+		"index %s of table %s is not a unique index on not null columns",
+		errors.RFCCodeText("CDC:ErrCanalInvalidKeyIndex"),
+	)
+	ErrCanalJSONInvalidChar = errors.Normalize(
+		"the value of column %s contains the character %U not allowed in JSON",
+		errors.RFCCodeText("CDC:ErrCanalJSONInvalidChar"),
+	)
+	ErrCanalSchemaMismatch = errors.Normalize(
+		"the row of table %s does not match the schema of the table: %s",
+		errors.RFCCodeText("CDC:ErrCanalSchemaMismatch"),
+	)
+	ErrCanalBreakingSchemaChange = errors.Normalize(
+		"the DDL of table %s changes the schema incompatibly: %s",
+		errors.RFCCodeText("CDC:ErrCanalBreakingSchemaChange"),
+	)
+	ErrCanalTooManyProps = errors.Normalize(
+		"the entry of table %s carries %d props, more than the max props %d",
+		errors.RFCCodeText("CDC:ErrCanalTooManyProps"),
+	)
 	ErrOldValueNotEnabled = errors.Normalize(
 		"old value is not enabled",
 		errors.RFCCodeText("CDC:ErrOldValueNotEnabled"),
@@ -419,6 +467,10 @@ var (
 		"csv decode failed",
 		errors.RFCCodeText("CDC:ErrCSVDecodeFailed"),
 	)
+	ErrBinlogEncodeFailed = errors.Normalize(
+		"binlog encode failed",
+		errors.RFCCodeText("CDC:ErrBinlogEncodeFailed"),
+	)
 
 	// utilities related errors
 	ErrToTLSConfigFailed = errors.Normalize(